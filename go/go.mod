@@ -1,3 +1,10 @@
 module jira-spillover-get
 
 go 1.25
+
+require (
+	golang.org/x/crypto v0.48.0
+	golang.org/x/term v0.40.0
+)
+
+require golang.org/x/sys v0.41.0 // indirect
@@ -0,0 +1,2357 @@
+package spillover
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files from the current output: go test ./internal/spillover -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// testNow is the run clock for every test in the package, so staleness, ages, and scores are reproducible
+var testNow = time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	timeNow = func() time.Time { return testNow }
+	os.Exit(m.Run())
+}
+
+// fakeJira serves the JSON fixtures in a testdata directory as a Jira REST API:
+//
+//	/rest/api/2/search           search-<startAt>.json (the JQL, page size, and fields are recorded, not matched)
+//	                             search-key-<KEY>.json for a "key = <KEY>" search
+//	/rest/api/2/project/<KEY>    project-<KEY>.json
+//	/rest/api/2/issue/<KEY>      issue-<KEY>.json
+//	/rest/api/2/<name>           <name>.json
+//	/rest/agile/1.0/sprint/<ID>  sprint-<ID>.json
+//
+// A request with no fixture gets HTTP 404 and a Jira style error body. Faults are injected by request: a search
+// page is named by its path and startAt (e.g. "/rest/api/2/search?startAt=4"), anything else by its path.
+type fakeJira struct {
+	*httptest.Server
+	dir    string
+	faults map[string]fakeFault // Fault injected into the first matching request only
+
+	mutex    sync.Mutex
+	requests []string // "METHOD path" of each request, in arrival order
+	searches []searchRequest
+}
+
+// fakeFault is what a fakeJira sends instead of the fixture
+type fakeFault struct {
+	Status   int    // HTTP status, sent with Retry-After: 0 (e.g. 429)
+	Body     string // Body sent with HTTP 200 in place of the fixture (e.g. malformed JSON)
+	Truncate bool   // Send half the fixture under a Content-Length for all of it
+}
+
+// newFakeJira starts a fakeJira serving the fixtures in dir, shut down when the test ends.
+func newFakeJira(t testing.TB, dir string) *fakeJira {
+	t.Helper()
+	fake := &fakeJira{dir: dir}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (fake *fakeJira) serve(w http.ResponseWriter, r *http.Request) {
+	fixture := ""
+	request := r.URL.Path
+	path := strings.TrimPrefix(r.URL.Path, "/rest/api/2/")
+	switch {
+	case path == "search":
+		search := searchRequest{JQL: r.URL.Query().Get("jql")}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			fmt.Sscan(r.URL.Query().Get("startAt"), &search.StartAt)
+			fmt.Sscan(r.URL.Query().Get("maxResults"), &search.MaxResults)
+			if fields := r.URL.Query().Get("fields"); fields != "" {
+				search.Fields = strings.Split(fields, ",")
+			}
+		}
+		fake.mutex.Lock()
+		fake.searches = append(fake.searches, search)
+		fake.mutex.Unlock()
+		fixture = fmt.Sprintf("search-%d.json", search.StartAt)
+		request = fmt.Sprintf("%s?startAt=%d", r.URL.Path, search.StartAt)
+		if key, found := strings.CutPrefix(search.JQL, "key = "); found {
+			// A search for one key, e.g. for a moved epic, has no fixture unless the test adds one
+			fixture = "search-key-" + strings.Trim(key, `"`) + ".json"
+		}
+	case strings.HasPrefix(path, "project/"):
+		fixture = "project-" + strings.TrimPrefix(path, "project/") + ".json"
+	case strings.HasPrefix(path, "issue/"):
+		fixture = "issue-" + strings.TrimPrefix(path, "issue/") + ".json"
+	case strings.HasPrefix(r.URL.Path, "/rest/agile/1.0/sprint/"):
+		fixture = "sprint-" + strings.TrimPrefix(r.URL.Path, "/rest/agile/1.0/sprint/") + ".json"
+	case path != r.URL.Path && !strings.Contains(path, "/"):
+		fixture = path + ".json"
+	}
+
+	fake.mutex.Lock()
+	fake.requests = append(fake.requests, r.Method+" "+r.URL.Path)
+	fault, faulty := fake.faults[request]
+	delete(fake.faults, request)
+	fake.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case faulty && fault.Status != 0:
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(fault.Status)
+		return
+	case faulty && fault.Body != "":
+		w.Write([]byte(fault.Body))
+		return
+	}
+	body, err := os.ReadFile(filepath.Join(fake.dir, fixture))
+	if fixture == "" || err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"errorMessages":["No fixture for %s"]}`, r.URL.Path)
+		return
+	}
+	if faulty && fault.Truncate {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		body = body[:len(body)/2]
+	}
+	w.Write(body)
+}
+
+// Requests returns the "METHOD path" of each request served so far.
+func (fake *fakeJira) Requests() []string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]string(nil), fake.requests...)
+}
+
+// Searches returns the search requests served so far.
+func (fake *fakeJira) Searches() []searchRequest {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]searchRequest(nil), fake.searches...)
+}
+
+// logRecorder collects the messages a run sends to Hooks.OnLog.
+type logRecorder struct {
+	mutex    sync.Mutex
+	messages []string // "LEVEL message"
+}
+
+func (recorder *logRecorder) log(level string, logContext LogContext, message string) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.messages = append(recorder.messages, level+" "+message)
+}
+
+// Contains reports whether any recorded message contains text.
+func (recorder *logRecorder) Contains(text string) bool {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	for _, message := range recorder.messages {
+		if strings.Contains(message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns how many recorded messages have the given level.
+func (recorder *logRecorder) Count(level string) int {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	count := 0
+	for _, message := range recorder.messages {
+		if strings.HasPrefix(message, level+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+// testConfig returns a Config running against fake and writing outputFile, paging the search two issues at a
+// time and leaving the user's project cache alone.
+func testConfig(fake *fakeJira, outputFile string) Config {
+	return Config{
+		JiraBaseURL:     fake.URL,
+		AuthToken:       "dGVzdDp0b2tlbg==",
+		ProjectKey:      "EXPD",
+		DaysPrior:       10,
+		OutputFile:      outputFile,
+		Location:        time.UTC,
+		BatchSize:       2,
+		FixedBatch:      true,
+		ProjectCacheTTL: -1,
+	}
+}
+
+// checkGolden compares got byte for byte with testdata/golden/<name>, or rewrites the golden file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if string(got) == string(want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < max(len(gotLines), len(wantLines)); i++ {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if gotLine != wantLine {
+			t.Fatalf("output differs from %s at line %d:\n got: %q\nwant: %q", golden, i+1, gotLine, wantLine)
+		}
+	}
+}
+
+// readTSV returns the rows of a TSV file as cells keyed by the header's column names.
+func readTSV(t *testing.T, filename string) []map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	header := strings.Split(lines[0], "\t")
+	var rows []map[string]string
+	for _, line := range lines[1:] {
+		cells := strings.Split(line, "\t")
+		if len(cells) != len(header) {
+			t.Fatalf("%s: row has %d cells, header has %d: %q", filename, len(cells), len(header), line)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = cells[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestRunAgainstFakeJira(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	recorder := &logRecorder{}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.Hooks.OnLog = recorder.log
+
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if report.FetchedCount != 5 {
+		t.Errorf("FetchedCount = %d, want 5", report.FetchedCount)
+	}
+	var keys []string
+	for _, issue := range report.Issues {
+		keys = append(keys, issue.Issue.Key)
+	}
+	if got, want := strings.Join(keys, " "), "EXPD-1 EXPD-3 EXPD-4"; got != want {
+		t.Errorf("spillover issues = %s, want %s", got, want)
+	}
+	if got := report.Epics["EXPD-100"].Summary; got != "Customer data imports" {
+		t.Errorf("epic EXPD-100 summary = %q, want %q", got, "Customer data imports")
+	}
+
+	var startAts []int
+	for _, search := range fake.Searches() {
+		startAts = append(startAts, search.StartAt)
+		if search.MaxResults != 2 {
+			t.Errorf("search at %d asked for %d issues, want 2", search.StartAt, search.MaxResults)
+		}
+		if !strings.HasPrefix(search.JQL, "project = EXPD ") {
+			t.Errorf("search JQL = %q, want the EXPD project query", search.JQL)
+		}
+	}
+	if got, want := fmt.Sprint(startAts), "[0 2 4]"; got != want {
+		t.Errorf("search pages started at %s, want %s", got, want)
+	}
+
+	rows := readTSV(t, cfg.OutputFile)
+	if len(rows) != 3 {
+		t.Fatalf("output has %d rows, want 3", len(rows))
+	}
+	for i, want := range []struct{ key, sprints, epicSummary, points string }{
+		{"EXPD-1", "2", "Customer data imports", "3"},
+		{"EXPD-3", "3", "No Epic Summary", "5"},
+		{"EXPD-4", "2", "Customer data imports", "N/A"},
+	} {
+		row := rows[i]
+		if row["Issue Key"] != want.key || row["Number of Sprints"] != want.sprints ||
+			row["Epic Summary"] != want.epicSummary || row["Story Points"] != want.points {
+			t.Errorf("row %d = %s, %s sprints, epic %q, %q points; want %s, %s sprints, epic %q, %q points", i+1,
+				row["Issue Key"], row["Number of Sprints"], row["Epic Summary"], row["Story Points"],
+				want.key, want.sprints, want.epicSummary, want.points)
+		}
+	}
+
+	if recorder.Count("INFO") == 0 {
+		t.Error("Hooks.OnLog received no INFO messages")
+	}
+	if n := recorder.Count("ERROR"); n != 0 {
+		t.Errorf("Hooks.OnLog received %d ERROR messages, want none", n)
+	}
+}
+
+func TestRunMissingProject(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.ProjectKey = "NOPE"
+
+	_, err := Run(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("Run succeeded for a project Jira does not have")
+	}
+	if !errors.Is(err, ErrProjectValidation) {
+		t.Errorf("Run error = %v, want ErrProjectValidation", err)
+	}
+	if _, statErr := os.Stat(cfg.OutputFile); statErr == nil {
+		t.Error("Run wrote an output file for a project that failed validation")
+	}
+}
+
+// TestRunConcurrent runs several reports at once with different settings. Run keeps each run's settings in its
+// own state, so under -race the runs must neither race nor see each other's settings.
+func TestRunConcurrent(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	dir := t.TempDir()
+
+	const runs = 6
+	var wait sync.WaitGroup
+	reports := make([]Report, runs)
+	errs := make([]error, runs)
+	recorders := make([]*logRecorder, runs)
+	for i := 0; i < runs; i++ {
+		cfg := testConfig(fake, filepath.Join(dir, fmt.Sprintf("spillover-%d.tsv", i)))
+		// Odd runs skip Done issues and order by update date, so a setting leaking between runs changes the rows.
+		if i%2 == 1 {
+			cfg.OrderBy = "updated"
+			cfg.OpenCategoryOnly = true
+		}
+		recorders[i] = &logRecorder{}
+		cfg.Hooks.OnLog = recorders[i].log
+		wait.Add(1)
+		go func(i int, cfg Config) {
+			defer wait.Done()
+			reports[i], errs[i] = Run(context.Background(), cfg)
+		}(i, cfg)
+	}
+	wait.Wait()
+
+	for i := 0; i < runs; i++ {
+		if errs[i] != nil {
+			t.Errorf("run %d: %v", i, errs[i])
+			continue
+		}
+		want := []string{"EXPD-1", "EXPD-3", "EXPD-4"}
+		if i%2 == 1 {
+			want = []string{"EXPD-4", "EXPD-1"}
+		}
+		var keys []string
+		for _, row := range readTSV(t, filepath.Join(dir, fmt.Sprintf("spillover-%d.tsv", i))) {
+			keys = append(keys, row["Issue Key"])
+		}
+		if strings.Join(keys, " ") != strings.Join(want, " ") {
+			t.Errorf("run %d wrote %v, want %v", i, keys, want)
+		}
+		if recorders[i].Count("INFO") == 0 {
+			t.Errorf("run %d logged nothing to its own Hooks.OnLog", i)
+		}
+	}
+}
+
+// TestRunHookOrder records every progress hook a run calls and checks they arrive in pipeline order, with a
+// rejected first search request to bring in OnRetry.
+func TestRunHookOrder(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	fake.faults = map[string]fakeFault{"/rest/api/2/search?startAt=0": {Status: http.StatusUnauthorized}}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+
+	var events []string
+	cfg.Hooks = Hooks{
+		OnPhase: func(name string) { events = append(events, "phase "+name) },
+		OnRetry: func(url string, attempt int, wait time.Duration) {
+			events = append(events, fmt.Sprintf("retry %s %d %s", strings.TrimPrefix(url, fake.URL), attempt, wait))
+		},
+		OnBatchFetched:   func(fetched, total int) { events = append(events, fmt.Sprintf("batch %d/%d", fetched, total)) },
+		OnIssueProcessed: func(done, total int) { events = append(events, fmt.Sprintf("issue %d/%d", done, total)) },
+		OnEpicLookup:     func(done, total int) { events = append(events, fmt.Sprintf("epic %d/%d", done, total)) },
+	}
+
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []string{
+		"phase Fetching issues from Jira",
+		"retry /rest/api/2/search 2 0s",
+		"batch 2/5",
+		"batch 4/5",
+		"batch 5/5",
+		"issue 0/5",
+		"issue 1/5",
+		"issue 2/5",
+		"issue 3/5",
+		"issue 4/5",
+		"issue 5/5",
+		"epic 0/1",
+		"epic 1/1",
+		"phase Formatting output data",
+	}
+	if got := strings.Join(events, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("hook calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+// TestRunGolden runs the whole pipeline against the fake Jira, with faults injected into some runs, and
+// compares the TSV it writes byte for byte with the golden files. The fixtures page two issues at a time and
+// mix sprint maps with the legacy greenhopper sprint strings.
+func TestRunGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		golden    string
+		faults    map[string]fakeFault
+		configure func(cfg *Config)
+		wantLog   string // Text one of the run's log messages must contain
+	}{
+		{
+			name:   "plain",
+			golden: "run.tsv",
+		},
+		{
+			name:    "rate limited on page 3",
+			golden:  "run.tsv",
+			faults:  map[string]fakeFault{"/rest/api/2/search?startAt=4": {Status: http.StatusTooManyRequests}},
+			wantLog: "Jira is rate limiting requests",
+		},
+		{
+			name:      "truncated page 2 retried",
+			golden:    "run.tsv",
+			faults:    map[string]fakeFault{"/rest/api/2/search?startAt=2": {Truncate: true}},
+			configure: func(cfg *Config) { cfg.SkipFailedPages = true },
+			wantLog:   "Batch 2 starting at record 2 failed, retrying once",
+		},
+		{
+			name:    "malformed epic",
+			golden:  "run-epic-malformed.tsv",
+			faults:  map[string]fakeFault{"/rest/api/2/issue/EXPD-100": {Body: `{"key":"EXPD-100","fields":{"summary":`}},
+			wantLog: "EXPD-100",
+		},
+		{
+			name:   "sprint links and score order",
+			golden: "run-links-score.tsv",
+			configure: func(cfg *Config) {
+				cfg.SprintLinks = true
+				cfg.OrderBy = "score"
+				cfg.IdentityMode = "display+email"
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			fake.faults = test.faults
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.Hooks.OnLog = recorder.log
+			if test.configure != nil {
+				test.configure(&cfg)
+			}
+
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			got, err := os.ReadFile(cfg.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The fake's address changes every run, so sprint report links are compared with a fixed host
+			got = []byte(strings.ReplaceAll(string(got), fake.URL, "https://jira.example.com"))
+			checkGolden(t, test.golden, got)
+			if test.wantLog != "" && !recorder.Contains(test.wantLog) {
+				t.Errorf("no log message contains %q", test.wantLog)
+			}
+		})
+	}
+}
+
+// TestRunTruncatedPage checks a search page cut off mid-body fails the run when failed pages are not skipped.
+func TestRunTruncatedPage(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	fake.faults = map[string]fakeFault{"/rest/api/2/search?startAt=2": {Truncate: true}}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+
+	_, err := Run(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "batch 2") {
+		t.Fatalf("Run error = %v, want a failure reading batch 2", err)
+	}
+}
+
+// TestRunGoalContains filters on the first sprint's goal. EXPD-1 lists Sprint 42 ("Reporting") before its first
+// sprint, Sprint 41 ("Stabilise imports"), so only the chronological first sprint's goal may match.
+func TestRunGoalContains(t *testing.T) {
+	for _, test := range []struct{ goalContains, want string }{
+		{"STABILISE", "EXPD-1"},
+		{"reporting", "EXPD-4"},
+		{"ship", "EXPD-3"},
+		{"polish", ""},
+	} {
+		t.Run(test.goalContains, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.GoalContains = test.goalContains
+
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var keys []string
+			for _, issue := range report.Issues {
+				keys = append(keys, issue.Issue.Key)
+			}
+			if got := strings.Join(keys, " "); got != test.want {
+				t.Errorf("issues whose first sprint goal contains %q = %q, want %q", test.goalContains, got, test.want)
+			}
+		})
+	}
+}
+
+// TestRunSQLiteIndex adds runs to a -sqlitefile database that holds an index, then reads the database back:
+// every run's rows must be there and the index, rebuilt on each write, must list every issue row in key order.
+func TestRunSQLiteIndex(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	dir := t.TempDir()
+	cfg := testConfig(fake, filepath.Join(dir, "spillover.tsv"))
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	database := filepath.Join(dir, "spillover.db")
+	if err := report.ExportSQLite(cfg, database); err != nil {
+		t.Fatalf("ExportSQLite: %v", err)
+	}
+
+	// Add the index as an analyst would with CREATE INDEX ix ON issues(issue_key)
+	objects, header, err := readSQLiteDatabase(database)
+	if err != nil {
+		t.Fatalf("readSQLiteDatabase: %v", err)
+	}
+	index := sqliteObject{Type: "index", Name: "ix", TableName: "issues", RootPage: 1, SQL: "CREATE INDEX ix ON issues(issue_key)"}
+	for _, object := range objects {
+		if object.Name == "issues" {
+			index.Index, err = parseSQLiteIndex(index.SQL, object.SQL)
+		}
+	}
+	if err != nil {
+		t.Fatalf("parseSQLiteIndex: %v", err)
+	}
+	if err := rewriteSQLiteDatabase(database, append(objects, index), header); err != nil {
+		t.Fatalf("rewriteSQLiteDatabase: %v", err)
+	}
+
+	for run := 2; run <= 3; run++ {
+		if err := report.ExportSQLite(cfg, database); err != nil {
+			t.Fatalf("ExportSQLite run %d into a database with an index: %v", run, err)
+		}
+	}
+
+	objects, _, err = readSQLiteDatabase(database)
+	if err != nil {
+		t.Fatalf("readSQLiteDatabase: %v", err)
+	}
+	rows := make(map[string][]sqliteRow)
+	rootPage := 0
+	for _, object := range objects {
+		rows[object.Name] = object.Rows
+		if object.Name == "ix" {
+			rootPage = int(object.RootPage)
+		}
+	}
+	for table, want := range map[string]int{"runs": 3, "issues": 3 * len(report.Issues), "sprints": 3 * 7} {
+		if got := len(rows[table]); got != want {
+			t.Errorf("%s has %d rows, want %d", table, got, want)
+		}
+	}
+	issueKeys := make(map[int64]string)
+	for _, row := range rows["issues"] {
+		values, err := decodeSQLiteRecord(row.Payload)
+		if err != nil {
+			t.Fatalf("issues row %d: %v", row.RowID, err)
+		}
+		issueKeys[row.RowID] = values[2].(string)
+	}
+
+	data, err := os.ReadFile(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := readSQLiteIndexKeys(t, sqliteReader{data: data, pageSize: sqlitePageSize, usableSize: sqlitePageSize}, rootPage)
+	if len(keys) != len(issueKeys) {
+		t.Fatalf("index ix has %d keys, want one per issues row (%d)", len(keys), len(issueKeys))
+	}
+	counts := make(map[string]int)
+	for i, key := range keys {
+		issueKey, rowID := key[0].(string), key[1].(int64)
+		if issueKeys[rowID] != issueKey {
+			t.Errorf("index key %d is %s for row %d, whose issue_key is %q", i, issueKey, rowID, issueKeys[rowID])
+		}
+		if i > 0 && (issueKey < keys[i-1][0].(string) || issueKey == keys[i-1][0].(string) && rowID < keys[i-1][1].(int64)) {
+			t.Errorf("index key %d (%s, row %d) is out of order", i, issueKey, rowID)
+		}
+		counts[issueKey]++
+	}
+	if counts["EXPD-1"] != 3 {
+		t.Errorf("index ix finds EXPD-1 in %d rows, want 3", counts["EXPD-1"])
+	}
+}
+
+// readSQLiteIndexKeys returns the keys of an index b-tree in order, decoded
+func readSQLiteIndexKeys(t *testing.T, reader sqliteReader, pageNumber int) [][]any {
+	t.Helper()
+	page, offset, err := reader.page(pageNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageType := page[offset]
+	headerSize := sqlitePageHeaderSize(pageType)
+	var keys [][]any
+	for i := 0; i < int(binary.BigEndian.Uint16(page[offset+3:])); i++ {
+		cell := int(binary.BigEndian.Uint16(page[offset+headerSize+2*i:]))
+		switch pageType {
+		case sqliteInteriorIndexPage:
+			keys = append(keys, readSQLiteIndexKeys(t, reader, int(binary.BigEndian.Uint32(page[cell:])))...)
+			cell += 4
+		case sqliteLeafIndexPage:
+		default:
+			t.Fatalf("page %d has type %#x, not an index page", pageNumber, pageType)
+		}
+		size, n := readSQLiteVarint(page[cell:])
+		local := sqliteIndexLocalPayload(int(size), reader.usableSize)
+		payload := append([]byte{}, page[cell+n:cell+n+local]...)
+		if local < int(size) {
+			next := int(binary.BigEndian.Uint32(page[cell+n+local:]))
+			for len(payload) < int(size) {
+				overflow, _, err := reader.page(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				payload = append(payload, overflow[4:4+min(int(size)-len(payload), reader.usableSize-4)]...)
+				next = int(binary.BigEndian.Uint32(overflow))
+			}
+		}
+		values, err := decodeSQLiteRecord(payload)
+		if err != nil {
+			t.Fatalf("page %d key %d: %v", pageNumber, i, err)
+		}
+		keys = append(keys, values)
+	}
+	if pageType == sqliteInteriorIndexPage {
+		keys = append(keys, readSQLiteIndexKeys(t, reader, int(binary.BigEndian.Uint32(page[offset+8:])))...)
+	}
+	return keys
+}
+
+// TestValidateJSONDocument checks that the report and stats documents of a real run match their schemas, and that
+// deliberately broken copies of them fail with the path of the part that was broken.
+func TestValidateJSONDocument(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	reportJSON, err := json.Marshal(report.Posted(cfg))
+	if err != nil {
+		t.Fatalf("encoding the report: %v", err)
+	}
+	statsJSON, err := json.Marshal(report.Stats)
+	if err != nil {
+		t.Fatalf("encoding the stats: %v", err)
+	}
+	documents := map[string][]byte{"report": reportJSON, "stats": statsJSON}
+	for schemaName, document := range documents {
+		if err := ValidateJSONDocument(schemaName, document); err != nil {
+			t.Errorf("the %s of a clean run does not validate: %v", schemaName, err)
+		}
+	}
+
+	issue := func(document map[string]interface{}, i int) map[string]interface{} {
+		return document["issues"].([]interface{})[i].(map[string]interface{})
+	}
+	for _, tc := range []struct {
+		name    string
+		schema  string
+		breakIt func(document map[string]interface{})
+		want    string
+	}{
+		{"missing required property", "report", func(d map[string]interface{}) { delete(issue(d, 1), "key") },
+			`$.issues[1]: required property "key" is missing`},
+		{"wrong type", "report", func(d map[string]interface{}) { issue(d, 0)["sprintCount"] = "2" },
+			"$.issues[0].sprintCount: expected integer, got string"},
+		{"fraction for an integer", "report", func(d map[string]interface{}) { issue(d, 2)["commentCount"] = 1.5 },
+			"$.issues[2].commentCount: expected integer, got number"},
+		{"below the minimum", "report", func(d map[string]interface{}) { issue(d, 0)["spilloverScore"] = -1 },
+			"$.issues[0].spilloverScore: -1 is less than the minimum of 0"},
+		{"null where not allowed", "report", func(d map[string]interface{}) { issue(d, 0)["summary"] = nil },
+			"$.issues[0].summary: expected string, got null"},
+		{"bad array item", "report", func(d map[string]interface{}) { issue(d, 1)["sprints"] = []interface{}{"Sprint 1", 2} },
+			"$.issues[1].sprints[1]: expected string, got integer"},
+		{"unknown property", "report", func(d map[string]interface{}) { d["summary"].(map[string]interface{})["extra"] = true },
+			`$.summary: property "extra" is not in the schema`},
+		{"wrong top-level type", "report", func(d map[string]interface{}) { d["issues"] = map[string]interface{}{} },
+			"$.issues: expected array, got object"},
+		{"stats missing required property", "stats", func(d map[string]interface{}) { delete(d, "partial") },
+			`$: required property "partial" is missing`},
+		{"stats wrong type", "stats", func(d map[string]interface{}) { d["sampled"] = "false" },
+			"$.sampled: expected boolean, got string"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var document map[string]interface{}
+			if err := json.Unmarshal(documents[tc.schema], &document); err != nil {
+				t.Fatalf("decoding the %s: %v", tc.schema, err)
+			}
+			tc.breakIt(document)
+			broken, err := json.Marshal(document)
+			if err != nil {
+				t.Fatalf("encoding the broken %s: %v", tc.schema, err)
+			}
+			err = ValidateJSONDocument(tc.schema, broken)
+			if err == nil {
+				t.Fatalf("broken %s validated", tc.schema)
+			}
+			if !strings.HasSuffix(err.Error(), tc.want) {
+				t.Errorf("error = %q, want it to end with %q", err, tc.want)
+			}
+		})
+	}
+
+	if err := ValidateJSONDocument("report", []byte("{")); err == nil {
+		t.Error("a document that is not JSON validated")
+	}
+	if err := ValidateJSONDocument("nope", reportJSON); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("unknown schema error = %v, want ErrUnknownSchema", err)
+	}
+}
+
+// TestNewSearchRequest checks that a search sends the same parameters whether it goes as POST or, with
+// -searchget, as GET.
+func TestNewSearchRequest(t *testing.T) {
+	jql := `project = EXPD AND summary ~ "a&b=c" AND key not in (EXPD-1, EXPD-2) ORDER BY key`
+	want := searchRequest{JQL: jql, StartAt: 40, MaxResults: 20, Fields: []string{"summary", "customfield_10020"}}
+	for _, searchGET := range []bool{false, true} {
+		t.Run(fmt.Sprintf("searchget=%t", searchGET), func(t *testing.T) {
+			rs := newTestRunState(t, &logRecorder{})
+			rs.searchUsesGET = searchGET
+			req, err := rs.newSearchRequest("https://jira.example.com", "dGVzdDp0b2tlbg==", jql, 40, 20, "summary,customfield_10020")
+			if err != nil {
+				t.Fatalf("newSearchRequest: %v", err)
+			}
+			if got := req.Header.Get("Authorization"); got != "Basic dGVzdDp0b2tlbg==" {
+				t.Errorf("Authorization = %q", got)
+			}
+			if req.URL.Path != "/rest/api/2/search" {
+				t.Errorf("path = %s, want /rest/api/2/search", req.URL.Path)
+			}
+
+			var got searchRequest
+			if searchGET {
+				if req.Method != http.MethodGet || req.Body != nil {
+					t.Fatalf("method = %s with body %v, want GET without a body", req.Method, req.Body != nil)
+				}
+				query := req.URL.Query()
+				got.JQL = query.Get("jql")
+				fmt.Sscan(query.Get("startAt"), &got.StartAt)
+				fmt.Sscan(query.Get("maxResults"), &got.MaxResults)
+				got.Fields = strings.Split(query.Get("fields"), ",")
+			} else {
+				if req.Method != http.MethodPost || req.URL.RawQuery != "" {
+					t.Fatalf("method = %s with query %q, want POST without a query", req.Method, req.URL.RawQuery)
+				}
+				if got := req.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				// Retries send the body again, so it must be replayable
+				for attempt := 0; attempt < 2; attempt++ {
+					body, err := req.GetBody()
+					if err != nil {
+						t.Fatalf("GetBody: %v", err)
+					}
+					got = searchRequest{}
+					if err := json.NewDecoder(body).Decode(&got); err != nil {
+						t.Fatalf("decoding the body: %v", err)
+					}
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("search = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestSelfTestSearch checks that -selftest searches with the same request method as a report run.
+func TestSelfTestSearch(t *testing.T) {
+	for _, searchGET := range []bool{false, true} {
+		t.Run(fmt.Sprintf("searchget=%t", searchGET), func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, "")
+			cfg.SearchGET = searchGET
+			checks, err := SelfTest(context.Background(), cfg, "EXPD", "")
+			if err != nil {
+				t.Fatalf("SelfTest: %v", err)
+			}
+			for _, check := range checks {
+				if check.Name == "Search permission" || check.Name == "Sprint field" {
+					if !check.Passed {
+						t.Errorf("%s check failed: %s", check.Name, check.Detail)
+					}
+				}
+			}
+
+			wantMethod := http.MethodPost
+			if searchGET {
+				wantMethod = http.MethodGet
+			}
+			searchCount := 0
+			for _, request := range fake.Requests() {
+				method, path, _ := strings.Cut(request, " ")
+				if path == "/rest/api/2/search" {
+					searchCount++
+					if method != wantMethod {
+						t.Errorf("self-test searched with %s, want %s", method, wantMethod)
+					}
+				}
+			}
+			if searchCount != 2 {
+				t.Errorf("self-test sent %d searches, want 2", searchCount)
+			}
+
+			var maxResults []int
+			for _, search := range fake.Searches() {
+				maxResults = append(maxResults, search.MaxResults)
+				if !strings.HasPrefix(search.JQL, "project = EXPD ") {
+					t.Errorf("search JQL = %q, want the EXPD project query", search.JQL)
+				}
+			}
+			if got, want := fmt.Sprint(maxResults), "[1 20]"; got != want {
+				t.Errorf("search page sizes = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestRunPairFieldMissing checks that the "Pair field not found" warning fires only when no spillover issue has a value.
+func TestRunPairFieldMissing(t *testing.T) {
+	for _, tt := range []struct {
+		pairField   string
+		wantMissing bool
+	}{
+		{"customfield_10186", false}, // set on EXPD-1 only
+		{"customfield_99999", true},  // on no issue
+	} {
+		t.Run(tt.pairField, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.PairField = tt.pairField
+			cfg.Hooks.OnLog = recorder.log
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			warned := recorder.Contains("was requested but not found on any issues")
+			if report.PairFieldMissing != tt.wantMissing || warned != tt.wantMissing {
+				t.Errorf("PairFieldMissing = %t, warned = %t; want %t", report.PairFieldMissing, warned, tt.wantMissing)
+			}
+			if !tt.wantMissing {
+				if got := readTSV(t, cfg.OutputFile)[0]["Pair"]; got != "Carol Example" {
+					t.Errorf("EXPD-1 Pair = %q, want Carol Example", got)
+				}
+			}
+		})
+	}
+}
+
+// TestRunTimezoneMidnight runs the same report in timezones either side of UTC. EXPD-5 was resolved at
+// 2026-10-09 10:00 UTC, which is 22:00 the day before at UTC-12, and EXPD-3 at 2026-10-12 15:30 UTC, which is
+// 05:30 the next day at UTC+14; the run is at 2026-10-15 12:00 UTC.
+func TestRunTimezoneMidnight(t *testing.T) {
+	tests := []struct {
+		name         string
+		location     *time.Location
+		wantResolved string // Resolved Date of EXPD-3
+		wantUpdated  string // Updated Date of EXPD-3
+		wantExcluded int    // Issues resolved before the -resolvedwithin 6 window
+	}{
+		{"UTC", time.UTC, "2026-10-12", "2026-10-12", 0},
+		// The window starts at midnight on 2026-10-09 local time, 12:00 UTC, so EXPD-5 is outside it
+		{"UTC-12", time.FixedZone("UTC-12", -12*3600), "2026-10-12", "2026-10-12", 1},
+		{"UTC+14", time.FixedZone("UTC+14", 14*3600), "2026-10-13", "2026-10-13", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.Location = tt.location
+			cfg.ResolvedWithin = 6
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if report.ResolvedExcludedCount != tt.wantExcluded {
+				t.Errorf("ResolvedExcludedCount = %d, want %d", report.ResolvedExcludedCount, tt.wantExcluded)
+			}
+			for _, row := range readTSV(t, cfg.OutputFile) {
+				if row["Issue Key"] == "EXPD-3" && (row["Resolved Date"] != tt.wantResolved || row["Updated Date"] != tt.wantUpdated) {
+					t.Errorf("EXPD-3 resolved %s, updated %s; want %s, %s",
+						row["Resolved Date"], row["Updated Date"], tt.wantResolved, tt.wantUpdated)
+				}
+			}
+		})
+	}
+}
+
+// TestRunAppendDedupe appends the same report twice with -dedupe, then again after a row was removed by hand.
+func TestRunAppendDedupe(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	recorder := &logRecorder{}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.AppendMode = true
+	cfg.Dedupe = true
+	cfg.Hooks.OnLog = recorder.log
+
+	keys := func() string {
+		var keys []string
+		for _, row := range readTSV(t, cfg.OutputFile) {
+			keys = append(keys, row["Issue Key"])
+		}
+		return strings.Join(keys, " ")
+	}
+	for run := 1; run <= 2; run++ {
+		if _, err := Run(context.Background(), cfg); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if got, want := keys(), "EXPD-1 EXPD-3 EXPD-4"; got != want {
+			t.Fatalf("after run %d the file holds %s, want %s", run, got, want)
+		}
+	}
+	if !recorder.Contains("Skipped 3 duplicate issues already present in output") {
+		t.Error("the second run did not log the skipped duplicates")
+	}
+
+	// Only the issue no longer in the file is appended
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.Contains(line, "\tEXPD-3\t") {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(cfg.OutputFile, []byte(strings.Join(kept, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("run 3: %v", err)
+	}
+	if got, want := keys(), "EXPD-1 EXPD-4 EXPD-3"; got != want {
+		t.Errorf("after removing EXPD-3 the file holds %s, want %s", got, want)
+	}
+	if !recorder.Contains("Skipped 2 duplicate issues already present in output") {
+		t.Error("the third run did not log the two skipped duplicates")
+	}
+}
+
+// TestRunDeterministicOutput checks that the output does not depend on the order Jira returns issues in: a
+// second Jira returning the same issues on other pages, and reversed within each page, gives a byte-identical file.
+func TestRunDeterministicOutput(t *testing.T) {
+	shuffled := t.TempDir()
+	entries, err := os.ReadDir("testdata/jira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("testdata/jira", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(shuffled, entry.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pages := make([]map[string]json.RawMessage, 2)
+	for i, name := range []string{"search-0.json", "search-2.json"} {
+		data, err := os.ReadFile(filepath.Join(shuffled, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &pages[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, name := range []string{"search-0.json", "search-2.json"} {
+		// Each page gets the other page's issues, last first
+		var issues []json.RawMessage
+		if err := json.Unmarshal(pages[1-i]["issues"], &issues); err != nil {
+			t.Fatal(err)
+		}
+		for left, right := 0, len(issues)-1; left < right; left, right = left+1, right-1 {
+			issues[left], issues[right] = issues[right], issues[left]
+		}
+		page := map[string]json.RawMessage{"issues": mustMarshal(t, issues)}
+		for _, field := range []string{"startAt", "maxResults", "total"} {
+			page[field] = pages[i][field]
+		}
+		if err := os.WriteFile(filepath.Join(shuffled, name), mustMarshal(t, page), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, orderBy := range []string{"key", "updated", "created", "score"} {
+		t.Run(orderBy, func(t *testing.T) {
+			var outputs [2][]byte
+			for i, dir := range []string{"testdata/jira", shuffled} {
+				fake := newFakeJira(t, dir)
+				cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+				cfg.OrderBy = orderBy
+				if _, err := Run(context.Background(), cfg); err != nil {
+					t.Fatalf("Run against %s: %v", dir, err)
+				}
+				if outputs[i], err = os.ReadFile(cfg.OutputFile); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(outputs[0]) != string(outputs[1]) {
+				t.Errorf("output differs when Jira returns the issues in another order:\n%s\n---\n%s", outputs[0], outputs[1])
+			}
+		})
+	}
+
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.OrderBy = "summary"
+	if _, err := Run(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "unsupported order by field 'summary'") {
+		t.Errorf("Run with -orderby summary = %v, want an unsupported order by field error", err)
+	}
+}
+
+// mustMarshal encodes value as JSON, failing the test if it cannot.
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// postedRequest is what a report endpoint received
+type postedRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// TestRunPost POSTs a run's report to an httptest endpoint and checks the headers, the retry on a server error,
+// and that the payload matches the published report schema and the run.
+func TestRunPost(t *testing.T) {
+	fake := newFakeJira(t, "testdata/jira")
+	recorder := &logRecorder{}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.Program, cfg.Version = "jira-spillover-get", "test"
+	cfg.Hooks.OnLog = recorder.log
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	payload, err := json.Marshal(report.Posted(cfg))
+	if err != nil {
+		t.Fatalf("encoding the report: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		statuses   []int // Status of each attempt; 200 once these run out
+		wantErr    string
+		wantPosts  int
+		wantHeader [2]string
+	}{
+		{name: "accepted", authHeader: "Bearer abc123", wantPosts: 1, wantHeader: [2]string{"Authorization", "Bearer abc123"}},
+		{name: "named header", authHeader: "X-Api-Key: abc123", statuses: []int{http.StatusAccepted}, wantPosts: 1,
+			wantHeader: [2]string{"X-Api-Key", "abc123"}},
+		{name: "server error retried", statuses: []int{http.StatusServiceUnavailable}, wantPosts: 2},
+		{name: "client error not retried", statuses: []int{http.StatusBadRequest}, wantPosts: 1,
+			wantErr: `HTTP 400 posting report: {"error":"rejected"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var posts []postedRequest
+			endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				if r.Method != http.MethodPost {
+					t.Errorf("endpoint received %s, want POST", r.Method)
+				}
+				posts = append(posts, postedRequest{Header: r.Header.Clone(), Body: body})
+				status := http.StatusOK
+				if len(posts) <= len(tt.statuses) {
+					status = tt.statuses[len(posts)-1]
+				}
+				w.WriteHeader(status)
+				if status >= 300 {
+					w.Write([]byte(`{"error":"rejected"}` + "\n"))
+				}
+			}))
+			defer endpoint.Close()
+
+			err := report.Post(endpoint.URL, tt.authHeader, payload)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Post: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("Post error = %v, want %s", err, tt.wantErr)
+			}
+			if len(posts) != tt.wantPosts {
+				t.Fatalf("endpoint received %d POSTs, want %d", len(posts), tt.wantPosts)
+			}
+			for _, post := range posts {
+				if got := post.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				if tt.wantHeader[0] != "" && post.Header.Get(tt.wantHeader[0]) != tt.wantHeader[1] {
+					t.Errorf("%s = %q, want %q", tt.wantHeader[0], post.Header.Get(tt.wantHeader[0]), tt.wantHeader[1])
+				}
+				if tt.wantHeader[0] == "" && post.Header.Get("Authorization") != "" {
+					t.Errorf("Authorization = %q sent without -postauthheader", post.Header.Get("Authorization"))
+				}
+				if string(post.Body) != string(payload) {
+					t.Error("the POSTed body is not the payload")
+				}
+			}
+		})
+	}
+
+	// The payload matches its schema and describes the run
+	if err := ValidateJSONDocument("report", payload); err != nil {
+		t.Fatal(err)
+	}
+	var posted struct {
+		Run struct {
+			Program, Version, Project, JQL string
+		}
+		Issues []struct {
+			Key         string
+			SprintCount int
+			Sprints     []string
+		}
+		Summary struct {
+			FetchedCount, SpilloverCount int
+		}
+	}
+	if err := json.Unmarshal(payload, &posted); err != nil {
+		t.Fatal(err)
+	}
+	if posted.Run.Program != "jira-spillover-get" || posted.Run.Version != "test" || posted.Run.Project != "EXPD" ||
+		!strings.HasPrefix(posted.Run.JQL, "project = EXPD ") {
+		t.Errorf("run = %+v, want the EXPD run's metadata", posted.Run)
+	}
+	if posted.Summary.FetchedCount != 5 || posted.Summary.SpilloverCount != 3 {
+		t.Errorf("summary counts = %+v, want 5 fetched and 3 spillover", posted.Summary)
+	}
+	var issues []string
+	for _, issue := range posted.Issues {
+		issues = append(issues, fmt.Sprintf("%s:%d", issue.Key, issue.SprintCount))
+		if len(issue.Sprints) != issue.SprintCount {
+			t.Errorf("%s lists %d sprints for a sprint count of %d", issue.Key, len(issue.Sprints), issue.SprintCount)
+		}
+	}
+	if got, want := strings.Join(issues, " "), "EXPD-1:2 EXPD-3:3 EXPD-4:2"; got != want {
+		t.Errorf("posted issues = %s, want %s", got, want)
+	}
+	if !recorder.Contains("Retrying report POST in 1s (retry 1 of 3)") {
+		t.Error("the retry after HTTP 503 was not logged")
+	}
+}
+
+// TestRunSprintLinks checks the -sprintlinks columns, in both link styles, for the first and last sprints of an issue.
+func TestRunSprintLinks(t *testing.T) {
+	for _, tt := range []struct {
+		cloud     bool
+		wantFirst string
+		wantLast  string
+	}{
+		{false, "/secure/RapidBoard.jspa?rapidView=7&view=reporting&chart=sprintRetrospective&sprint=41",
+			"/secure/RapidBoard.jspa?rapidView=7&view=reporting&chart=sprintRetrospective&sprint=42"},
+		{true, "/jira/software/c/projects/EXPD/boards/7/reports/sprint-retrospective?sprint=41",
+			"/jira/software/c/projects/EXPD/boards/7/reports/sprint-retrospective?sprint=42"},
+	} {
+		t.Run(fmt.Sprintf("cloudlinks=%t", tt.cloud), func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.SprintLinks = true
+			cfg.CloudLinks = tt.cloud
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			row := readTSV(t, cfg.OutputFile)[0]
+			if row["Issue Key"] != "EXPD-1" {
+				t.Fatalf("first row is %s, want EXPD-1", row["Issue Key"])
+			}
+			if got := row["First Sprint Report URL"]; got != fake.URL+tt.wantFirst {
+				t.Errorf("First Sprint Report URL = %q, want %q", got, fake.URL+tt.wantFirst)
+			}
+			if got := row["Last Sprint Report URL"]; got != fake.URL+tt.wantLast {
+				t.Errorf("Last Sprint Report URL = %q, want %q", got, fake.URL+tt.wantLast)
+			}
+		})
+	}
+}
+
+// TestRunGracePeriod checks -graceperiod either side of the day EXPD-4's second sprint started (16 days before the
+// run) and EXPD-1's (30 days before). EXPD-3 is in three sprints, so it is never in a grace period.
+func TestRunGracePeriod(t *testing.T) {
+	for _, tt := range []struct {
+		graceDays int
+		want      string
+	}{
+		{0, "EXPD-1 EXPD-3 EXPD-4"},
+		{16, "EXPD-1 EXPD-3 EXPD-4"},
+		{17, "EXPD-1 EXPD-3"},
+		{30, "EXPD-1 EXPD-3"},
+		{31, "EXPD-3"},
+	} {
+		t.Run(strconv.Itoa(tt.graceDays), func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.GracePeriod = tt.graceDays
+			cfg.Hooks.OnLog = recorder.log
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var keys []string
+			for _, issue := range report.Issues {
+				keys = append(keys, issue.Issue.Key)
+			}
+			if got := strings.Join(keys, " "); got != tt.want {
+				t.Errorf("spillover issues = %s, want %s", got, tt.want)
+			}
+			excluded := 3 - len(keys)
+			wantSummary := fmt.Sprintf("%d issues within grace period excluded (second sprint started less than %d days ago)", excluded, tt.graceDays)
+			if tt.graceDays == 0 {
+				wantSummary = ""
+			}
+			if report.GraceSummary != wantSummary {
+				t.Errorf("GraceSummary = %q, want %q", report.GraceSummary, wantSummary)
+			}
+			if wantSummary != "" && !recorder.Contains(wantSummary) {
+				t.Error("the grace period summary was not logged")
+			}
+		})
+	}
+}
+
+// recordingWriter is a ReportWriter that keeps what it is given, to check what the pipeline hands a writer.
+type recordingWriter struct {
+	header  []string
+	headers int
+	records []ReportRecord
+	closed  bool
+}
+
+func (w *recordingWriter) WriteHeader(columns []string) error {
+	w.header = columns
+	w.headers++
+	return nil
+}
+
+func (w *recordingWriter) WriteRow(record ReportRecord) error {
+	w.records = append(w.records, record)
+	return nil
+}
+
+func (w *recordingWriter) Flush() error         { return nil }
+func (w *recordingWriter) Close() error         { w.closed = true; return nil }
+func (w *recordingWriter) SupportsAppend() bool { return false }
+
+// TestReportWriters runs the fixtures through every registered output format, comparing each with its golden
+// file, and through a recording writer to check the rows every writer is given.
+func TestReportWriters(t *testing.T) {
+	for name, format := range ReportFormats {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover"))
+			cfg.Format = name
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			outputFile := ReportFilePath(cfg)
+			if !strings.HasSuffix(outputFile, format.Extension) {
+				t.Errorf("output file %s does not have the %s extension", outputFile, format.Extension)
+			}
+			got, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "run"+format.Extension, got)
+		})
+	}
+
+	var recorder *recordingWriter
+	ReportFormats["recording"] = reportFormat{Extension: ".rec", NewWriter: func(io.Writer) ReportWriter {
+		recorder = &recordingWriter{}
+		return recorder
+	}}
+	defer delete(ReportFormats, "recording")
+
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover"))
+	cfg.Format = "recording"
+	cfg.NoVerify = true // The check counts lines in the file, which this writer does not write
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// The output file takes the extension of the selected format
+	if want := cfg.OutputFile + ".rec"; report.OutputFile != want || ReportFilePath(cfg) != want {
+		t.Errorf("output file = %s (ReportFilePath %s), want %s", report.OutputFile, ReportFilePath(cfg), want)
+	}
+	if _, err := os.Stat(cfg.OutputFile + ".tsv"); !os.IsNotExist(err) {
+		t.Errorf("a .tsv file was created for the recording format (stat: %v)", err)
+	}
+	if recorder == nil || recorder.headers != 1 || !recorder.closed {
+		t.Fatalf("writer = %+v, want one header and Close called", recorder)
+	}
+	tsvRows := readTSV(t, filepath.Join("testdata", "golden", "run.tsv"))
+	if len(recorder.records) != len(tsvRows) {
+		t.Fatalf("writer received %d rows, want %d", len(recorder.records), len(tsvRows))
+	}
+	for i, record := range recorder.records {
+		if fmt.Sprint(record.Columns) != fmt.Sprint(recorder.header) || len(record.Values) != len(record.Columns) {
+			t.Errorf("row %d has %d values for columns %v, want the header's %d columns", i+1, len(record.Values), record.Columns, len(recorder.header))
+		}
+		for j, column := range record.Columns {
+			if j < len(record.Values) && record.Values[j] != tsvRows[i][column] {
+				t.Errorf("row %d %s = %q, TSV writer wrote %q", i+1, column, record.Values[j], tsvRows[i][column])
+			}
+		}
+	}
+}
+
+// rewriteFixtures copies testdata/jira to a temporary directory, passing the fields of every issue in the search
+// pages to edit first, and returns the directory for newFakeJira.
+func rewriteFixtures(t *testing.T, edit func(key string, fields map[string]interface{})) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir("testdata/jira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("testdata/jira", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(entry.Name(), "search-") {
+			var page map[string]interface{}
+			if err := json.Unmarshal(data, &page); err != nil {
+				t.Fatal(err)
+			}
+			for _, issue := range page["issues"].([]interface{}) {
+				issue := issue.(map[string]interface{})
+				edit(issue["key"].(string), issue["fields"].(map[string]interface{}))
+			}
+			data = mustMarshal(t, page)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestRunResolveSprintIDs runs the fixtures with every sprint field reduced to bare sprint IDs, as team-managed
+// projects return it, and resolves them through a fake Agile API that has no sprint 43.
+func TestRunResolveSprintIDs(t *testing.T) {
+	sprints := map[float64]map[string]interface{}{
+		// Sprint 40 is only in a legacy sprint string in the fixtures
+		40: {"id": 40, "name": "Sprint 40", "state": "closed", "boardId": 7,
+			"startDate": "2026-08-18T09:00:00.000Z", "endDate": "2026-08-31T17:00:00.000Z"},
+	}
+	dir := rewriteFixtures(t, func(key string, fields map[string]interface{}) {
+		var ids []interface{}
+		for _, sprint := range fields[defaultSprintField].([]interface{}) {
+			switch sprint := sprint.(type) {
+			case map[string]interface{}:
+				sprints[sprint["id"].(float64)] = sprint
+				ids = append(ids, sprint["id"])
+			case string:
+				id, _ := strconv.ParseFloat(parseLegacySprintAttributes(sprint)["id"], 64)
+				ids = append(ids, id)
+			}
+		}
+		fields[defaultSprintField] = ids
+	})
+	for id, sprint := range sprints {
+		if id != 43 {
+			name := fmt.Sprintf("sprint-%d.json", int(id))
+			if err := os.WriteFile(filepath.Join(dir, name), mustMarshal(t, sprint), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Issue Key | Number of Sprints | All Sprints | First Sprint Start | Last Sprint End
+	want := []string{
+		"EXPD-1 | 2 | Sprint 41, Sprint 42 | 2026-09-01 | 2026-09-28",
+		"EXPD-3 | 3 | Sprint 40, Sprint 41, Sprint 42 | 2026-08-18 | 2026-09-28",
+		// Sprint 43 could not be resolved, so it keeps its placeholder name and has no dates
+		"EXPD-4 | 2 | Sprint 42, Sprint 43 | 2026-09-15 | ",
+	}
+	// Bare sprint IDs switch resolution on even without -resolvesprintids
+	for _, resolve := range []bool{true, false} {
+		t.Run(fmt.Sprintf("resolvesprintids=%t", resolve), func(t *testing.T) {
+			fake := newFakeJira(t, dir)
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.ResolveSprintIDs = resolve
+			cfg.Hooks.OnLog = recorder.log
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var got []string
+			for _, row := range readTSV(t, cfg.OutputFile) {
+				got = append(got, strings.Join([]string{row["Issue Key"], row["Number of Sprints"], row["All Sprints"],
+					row["First Sprint Start"], row["Last Sprint End"]}, " | "))
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("rows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+
+			// Each sprint is looked up once, however many issues it is on
+			lookups := make(map[string]int)
+			for _, request := range fake.Requests() {
+				if path := strings.TrimPrefix(request, "GET /rest/agile/1.0/sprint/"); path != request {
+					lookups[path]++
+				}
+			}
+			if got := fmt.Sprint(lookups); got != "map[40:1 41:1 42:1 43:1]" {
+				t.Errorf("sprint lookups = %s, want one per sprint", got)
+			}
+			if !recorder.Contains("Failed to lookup sprint 43") || !recorder.Contains("Resolved 3 of 4 sprint IDs") {
+				t.Error("the failed lookup of sprint 43 was not logged")
+			}
+			if got := recorder.Contains("resolving them via the Agile API"); got == resolve {
+				t.Errorf("automatic sprint ID resolution logged = %t with -resolvesprintids=%t", got, resolve)
+			}
+		})
+	}
+}
+
+// TestRunInputRoundTrip writes a report from the fixtures, reads it back with -input and writes it again, twice:
+// for the current schema every write must be byte-identical to the first, without a single Jira request.
+func TestRunInputRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(cfg *Config)
+	}{
+		{"default", func(cfg *Config) {}},
+		{"optional columns", func(cfg *Config) {
+			cfg.IncludeDescription = true
+			cfg.IncludeComments = true
+			cfg.IncludeTime = true
+			cfg.IncludeLinks = true
+			cfg.SprintLinks = true
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fake := newFakeJira(t, "testdata/jira")
+			cfg := testConfig(fake, filepath.Join(dir, "report-0.tsv"))
+			tt.opts(&cfg)
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			want, err := os.ReadFile(cfg.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			queried := len(fake.Requests())
+
+			for i := 1; i <= 2; i++ {
+				recorder := &logRecorder{}
+				input := Config{
+					InputFile:  cfg.OutputFile,
+					OutputFile: filepath.Join(dir, fmt.Sprintf("report-%d.tsv", i)),
+					Location:   time.UTC,
+					Hooks:      Hooks{OnLog: recorder.log},
+				}
+				tt.opts(&input)
+				report, err := Run(context.Background(), input)
+				if err != nil {
+					t.Fatalf("Run -input (pass %d): %v", i, err)
+				}
+				got, err := os.ReadFile(input.OutputFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != string(want) {
+					t.Errorf("pass %d changed the report:\n%s\nwant:\n%s", i, got, want)
+				}
+				if report.FetchedCount != 3 {
+					t.Errorf("pass %d: FetchedCount = %d, want 3", i, report.FetchedCount)
+				}
+				if recorder.Contains("Could not reconstruct") {
+					t.Errorf("pass %d: a report of the current schema was not fully reconstructed", i)
+				}
+				cfg.OutputFile = input.OutputFile
+			}
+			if got := len(fake.Requests()); got != queried {
+				t.Errorf("-input sent %d Jira requests", got-queried)
+			}
+		})
+	}
+}
+
+// TestRunInputOlderReport reads a report missing some of today's columns: they are written back blank, recomputed
+// columns are filled in, and the missing ones are logged.
+func TestRunInputOlderReport(t *testing.T) {
+	dir := t.TempDir()
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(dir, "current.tsv"))
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	current := readTSV(t, cfg.OutputFile)
+
+	// Drop columns an older version did not write
+	dropped := map[string]bool{"Labels": true, "Epic Summary": true, "Churn Score": true, "Spillover Score": true}
+	content, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	header := strings.Split(lines[0], "\t")
+	var older strings.Builder
+	for _, line := range lines {
+		var kept []string
+		for i, cell := range strings.Split(line, "\t") {
+			if !dropped[header[i]] {
+				kept = append(kept, cell)
+			}
+		}
+		older.WriteString(strings.Join(kept, "\t") + "\n")
+	}
+	olderFile := filepath.Join(dir, "older.tsv")
+	if err := os.WriteFile(olderFile, []byte(older.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &logRecorder{}
+	input := Config{InputFile: olderFile, OutputFile: filepath.Join(dir, "rewritten.tsv"), Location: time.UTC,
+		Hooks: Hooks{OnLog: recorder.log}}
+	if _, err := Run(context.Background(), input); err != nil {
+		t.Fatalf("Run -input: %v", err)
+	}
+	rows := readTSV(t, input.OutputFile)
+	if len(rows) != len(current) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(current))
+	}
+	for i, row := range rows {
+		for column, value := range current[i] {
+			want := value
+			if column == "Labels" || column == "Epic Summary" {
+				want = ""
+			}
+			if row[column] != want {
+				t.Errorf("%s %s = %q, want %q", row["Issue Key"], column, row[column], want)
+			}
+		}
+	}
+	if !recorder.Contains("columns Epic Summary, Labels (left blank)") {
+		t.Error("the columns that could not be reconstructed were not logged")
+	}
+}
+
+// TestRunHistoricalResolvedWindow generates reports for windows that ended well before the run, as a report for
+// October generated in December does. EXPD-5 was resolved on 2026-10-09 and EXPD-3 on 2026-10-12.
+func TestRunHistoricalResolvedWindow(t *testing.T) {
+	tests := []struct {
+		name           string
+		now            time.Time
+		resolvedFrom   time.Time // -fromdate, zero for a window counted back from now
+		resolvedWithin int
+		wantExcluded   int
+		wantLog        string
+	}{
+		// Two months after the window opened, both issues resolved inside it are kept
+		{"fromdate two months ago", time.Date(2026, time.December, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), 75, 0,
+			"Excluded 0 issues resolved outside 2026-10-01 00:00 to 2026-12-15 23:59 (-resolvedwithin 75)"},
+		// The window starts on -fromdate, not resolvedWithin days back, so a day that is not whole still counts
+		{"fromdate at noon", time.Date(2026, time.December, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, time.October, 9, 12, 0, 0, 0, time.UTC), 1, 1,
+			"Excluded 1 issues resolved outside 2026-10-09 12:00 to 2026-12-15 23:59 (-resolvedwithin 1)"},
+		{"fromdate between the resolutions", time.Date(2026, time.December, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, time.October, 10, 0, 0, 0, 0, time.UTC), 67, 1,
+			"Excluded 1 issues resolved outside 2026-10-10 00:00 to 2026-12-15 23:59 (-resolvedwithin 67)"},
+		// Without -fromdate the window is counted back from now and both resolutions are too old
+		{"no fromdate", time.Date(2026, time.December, 15, 12, 0, 0, 0, time.UTC), time.Time{}, 30, 2,
+			"Excluded 2 issues resolved outside 2026-11-15 00:00 to 2026-12-15 23:59 (-resolvedwithin 30)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNow = func() time.Time { return tt.now }
+			t.Cleanup(func() { timeNow = func() time.Time { return testNow } })
+
+			fake := newFakeJira(t, "testdata/jira")
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.DaysPrior = tt.resolvedWithin
+			cfg.ResolvedWithin = tt.resolvedWithin
+			cfg.ResolvedFrom = tt.resolvedFrom
+			cfg.Hooks.OnLog = recorder.log
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if report.ResolvedExcludedCount != tt.wantExcluded {
+				t.Errorf("ResolvedExcludedCount = %d, want %d", report.ResolvedExcludedCount, tt.wantExcluded)
+			}
+			keptEXPD3 := false
+			for _, row := range readTSV(t, cfg.OutputFile) {
+				keptEXPD3 = keptEXPD3 || row["Issue Key"] == "EXPD-3"
+			}
+			if keptEXPD3 != (tt.wantExcluded < 2) {
+				t.Errorf("EXPD-3 kept = %t with %d issues excluded", keptEXPD3, tt.wantExcluded)
+			}
+			if !recorder.Contains(tt.wantLog) {
+				t.Errorf("window not logged as %q", tt.wantLog)
+			}
+		})
+	}
+}
+
+// TestRunIncludeLinks links the fixture issues: EXPD-4 is blocked by EXPD-1, both spillover issues, and EXPD-3 by
+// EXPD-2, which did not spill over. Only the first is a blocked chain.
+func TestRunIncludeLinks(t *testing.T) {
+	blocks := func(inward, outward string) map[string]interface{} {
+		link := map[string]interface{}{"type": map[string]interface{}{"name": "Blocks", "inward": "is blocked by", "outward": "blocks"}}
+		if inward != "" {
+			link["inwardIssue"] = map[string]interface{}{"key": inward}
+		}
+		if outward != "" {
+			link["outwardIssue"] = map[string]interface{}{"key": outward}
+		}
+		return link
+	}
+	dir := rewriteFixtures(t, func(key string, fields map[string]interface{}) {
+		switch key {
+		case "EXPD-1":
+			fields["issuelinks"] = []interface{}{blocks("", "EXPD-4"),
+				map[string]interface{}{"type": map[string]interface{}{"name": "Relates"}, "outwardIssue": map[string]interface{}{"key": "EXPD-5"}}}
+		case "EXPD-3":
+			fields["issuelinks"] = []interface{}{blocks("EXPD-2", "")}
+		case "EXPD-4":
+			fields["issuelinks"] = []interface{}{blocks("EXPD-1", "")}
+		}
+	})
+
+	fake := newFakeJira(t, dir)
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.IncludeLinks = true
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var got []string
+	for _, row := range readTSV(t, cfg.OutputFile) {
+		got = append(got, row["Issue Key"]+" blocked by ["+row["Blocked By"]+"] blocks ["+row["Blocks"]+"]")
+	}
+	want := "EXPD-1 blocked by [] blocks [EXPD-4]; EXPD-3 blocked by [EXPD-2] blocks []; EXPD-4 blocked by [EXPD-1] blocks []"
+	if strings.Join(got, "; ") != want {
+		t.Errorf("links:\n%s\nwant:\n%s", strings.Join(got, "; "), want)
+	}
+	if report.BlockedChainCount != 1 {
+		t.Errorf("BlockedChainCount = %d, want 1", report.BlockedChainCount)
+	}
+	if !strings.Contains(report.BlockedChainSummary, "1 spillover issues are blocked by another spillover issue") {
+		t.Errorf("BlockedChainSummary = %q", report.BlockedChainSummary)
+	}
+	for _, search := range fake.Searches() {
+		if !strings.Contains(strings.Join(search.Fields, ","), "issuelinks") {
+			t.Errorf("search at %d did not request the issuelinks field", search.StartAt)
+		}
+	}
+}
+
+// tailWriter is a TSV writer that, before each row, counts the lines a follower (tail -f) of the output file, and of
+// the temporary file it is written to until complete, would see (-1 while there is no such file).
+type tailWriter struct {
+	ReportWriter
+	t         *testing.T
+	path      string
+	visible   []int
+	temporary []int
+}
+
+func (w *tailWriter) WriteRow(record ReportRecord) error {
+	extension := outputFileExtension(w.path)
+	temporary, err := filepath.Glob(filepath.Join(filepath.Dir(w.path), "."+strings.TrimSuffix(filepath.Base(w.path), extension)+"-*"+extension))
+	if err != nil || len(temporary) > 1 {
+		w.t.Fatalf("temporary files %v (%v)", temporary, err)
+	}
+	w.visible = append(w.visible, w.lines(w.path))
+	if len(temporary) == 0 {
+		w.temporary = append(w.temporary, -1)
+	} else {
+		w.temporary = append(w.temporary, w.lines(temporary[0]))
+	}
+	return w.ReportWriter.WriteRow(record)
+}
+
+// lines counts the lines of a file as a follower sees them, -1 if it does not exist.
+func (w *tailWriter) lines(path string) int {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return -1
+	}
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	if strings.HasSuffix(path, ".gz") && len(content) > 0 {
+		// A flushed gzip stream decodes up to the flush point, then ends early
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			w.t.Fatal(err)
+		}
+		content, _ = io.ReadAll(reader)
+	}
+	return bytes.Count(content, []byte("\n"))
+}
+
+// TestRunFlushEvery follows the output file while it is written, for each -flushevery setting with and without
+// -noatomic: the header is on disk as soon as the file is opened, and rows reach it every -flushevery rows, also
+// when compressing. By default they reach the temporary file, and the output file only appears once complete; with
+// -noatomic, or when appending, the output file itself is written. No temporary file is left behind.
+func TestRunFlushEvery(t *testing.T) {
+	tests := []struct {
+		name          string
+		flushEvery    int
+		compress      bool
+		append        bool
+		noAtomic      bool
+		wantVisible   string // Lines in the output file before each of the three rows is written
+		wantTemporary string // Lines in the temporary file before each row
+	}{
+		{name: "every row", flushEvery: 1, wantVisible: "[-1 -1 -1]", wantTemporary: "[1 2 3]"},
+		{name: "every row in place", flushEvery: 1, noAtomic: true, wantVisible: "[1 2 3]", wantTemporary: "[-1 -1 -1]"},
+		{name: "every 2 rows", flushEvery: 2, wantVisible: "[-1 -1 -1]", wantTemporary: "[1 1 3]"},
+		{name: "every 2 rows in place", flushEvery: 2, noAtomic: true, wantVisible: "[1 1 3]", wantTemporary: "[-1 -1 -1]"},
+		{name: "default", flushEvery: 0, wantVisible: "[-1 -1 -1]", wantTemporary: "[1 1 1]"},
+		{name: "default in place", flushEvery: 0, noAtomic: true, wantVisible: "[1 1 1]", wantTemporary: "[-1 -1 -1]"},
+		{name: "only at the end", flushEvery: -1, wantVisible: "[-1 -1 -1]", wantTemporary: "[1 1 1]"},
+		{name: "only at the end in place", flushEvery: -1, noAtomic: true, wantVisible: "[1 1 1]", wantTemporary: "[-1 -1 -1]"},
+		{name: "compressed", flushEvery: 1, compress: true, wantVisible: "[-1 -1 -1]", wantTemporary: "[1 2 3]"},
+		{name: "compressed in place", flushEvery: 1, compress: true, noAtomic: true, wantVisible: "[1 2 3]", wantTemporary: "[-1 -1 -1]"},
+		// The second run appends to the first run's header and three rows, in place either way
+		{name: "appending", flushEvery: 1, append: true, wantVisible: "[4 5 6]", wantTemporary: "[-1 -1 -1]"},
+		{name: "appending in place", flushEvery: 1, append: true, noAtomic: true, wantVisible: "[4 5 6]", wantTemporary: "[-1 -1 -1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			dir := t.TempDir()
+			cfg := testConfig(fake, filepath.Join(dir, "spillover.tsv"))
+			cfg.FlushEvery = tt.flushEvery
+			cfg.Compress = tt.compress
+			cfg.AppendMode = tt.append
+			cfg.NoAtomic = tt.noAtomic
+			if tt.append {
+				if _, err := Run(context.Background(), cfg); err != nil {
+					t.Fatalf("first run: %v", err)
+				}
+			}
+
+			writer := &tailWriter{t: t, path: ReportFilePath(cfg)}
+			ReportFormats["tail"] = reportFormat{Extension: ".tsv", NewWriter: func(w io.Writer) ReportWriter {
+				writer.ReportWriter = ReportFormats["tsv"].NewWriter(w)
+				return writer
+			}}
+			defer delete(ReportFormats, "tail")
+			cfg.Format = "tail"
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := fmt.Sprint(writer.visible); got != tt.wantVisible {
+				t.Errorf("output file lines visible before each row = %s, want %s", got, tt.wantVisible)
+			}
+			if got := fmt.Sprint(writer.temporary); got != tt.wantTemporary {
+				t.Errorf("temporary file lines visible before each row = %s, want %s", got, tt.wantTemporary)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".") {
+					t.Errorf("temporary file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+// failingWriter is a TSV writer that fails on the second row, as a full disk would.
+type failingWriter struct {
+	ReportWriter
+	rows int
+}
+
+func (w *failingWriter) WriteRow(record ReportRecord) error {
+	if w.rows++; w.rows == 2 {
+		return errors.New("no space left on device")
+	}
+	return w.ReportWriter.WriteRow(record)
+}
+
+// TestRunAtomicOutput fails a run part way through writing over an earlier report: by default the earlier report is
+// left as it was and the temporary file removed, while with -noatomic the partly written file replaces it.
+func TestRunAtomicOutput(t *testing.T) {
+	for _, noAtomic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("noatomic=%v", noAtomic), func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			dir := t.TempDir()
+			cfg := testConfig(fake, filepath.Join(dir, "spillover.tsv"))
+			cfg.NoAtomic = noAtomic
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("first run: %v", err)
+			}
+			earlier, err := os.ReadFile(cfg.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ReportFormats["failing"] = reportFormat{Extension: ".tsv", NewWriter: func(w io.Writer) ReportWriter {
+				return &failingWriter{ReportWriter: ReportFormats["tsv"].NewWriter(w)}
+			}}
+			defer delete(ReportFormats, "failing")
+			cfg.Format = "failing"
+			if _, err := Run(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "no space left on device") {
+				t.Fatalf("Run error = %v, want the write failure", err)
+			}
+			got, err := os.ReadFile(cfg.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unchanged := string(got) == string(earlier); unchanged == noAtomic {
+				t.Errorf("earlier report unchanged = %v after a failed run, want %v:\n%s", unchanged, !noAtomic, got)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("files after the failed run: %v, want only spillover.tsv", entries)
+			}
+		})
+	}
+}
+
+// TestRunServerTimestamps runs the fixtures with resolution dates in the other formats Jira sends: both are
+// read for the Resolved Date column and the -resolvedwithin filter, and an unreadable created date is counted.
+func TestRunServerTimestamps(t *testing.T) {
+	dir := rewriteFixtures(t, func(key string, fields map[string]interface{}) {
+		switch key {
+		case "EXPD-1":
+			fields["created"] = "01/Sep/26 10:00 AM"
+		case "EXPD-3":
+			fields["resolutiondate"] = "2026-10-13T01:30:00+10:00"
+		case "EXPD-5":
+			fields["resolutiondate"] = "2026-10-09T10:00:00Z"
+		}
+	})
+	fake := newFakeJira(t, dir)
+	recorder := &logRecorder{}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.ResolvedWithin = 5 // From 2026-10-10, so EXPD-5 is excluded and EXPD-3 kept
+	cfg.Hooks.OnLog = recorder.log
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.ResolvedExcludedCount != 1 {
+		t.Errorf("ResolvedExcludedCount = %d, want 1", report.ResolvedExcludedCount)
+	}
+	rows := readTSV(t, cfg.OutputFile)
+	for _, row := range rows {
+		switch row["Issue Key"] {
+		case "EXPD-1":
+			if row["Created Date"] != "" {
+				t.Errorf("EXPD-1 Created Date = %q, want blank", row["Created Date"])
+			}
+		case "EXPD-3":
+			if row["Resolved Date"] != "2026-10-12" {
+				t.Errorf("EXPD-3 Resolved Date = %q, want 2026-10-12", row["Resolved Date"])
+			}
+		}
+	}
+	if !recorder.Contains(`1 distinct Jira timestamps could not be parsed (e.g. "01/Sep/26 10:00 AM")`) {
+		t.Error("the unparseable created date was not logged")
+	}
+}
+
+// TestRunServerInfo runs the fixtures against each serverInfo answer: the detected or guessed product is reported,
+// chooses the sprint link style and whether updatedBy() is probed, and flags the product may not support are warned
+// about.
+func TestRunServerInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverInfo fakeFault // Replaces the Server 9.12.0 fixture when set
+		want       JiraServerInfo
+		wantLinks  string // Start of EXPD-1's First Sprint Report URL after the base URL
+		wantProbe  bool   // Whether updatedBy() is probed before use
+		wantWarn   []string
+	}{
+		{name: "server",
+			want:      JiraServerInfo{DeploymentType: "Server", Version: "9.12.0", Detected: true},
+			wantLinks: "/secure/RapidBoard.jspa", wantProbe: true,
+			wantWarn: []string{"-cloudlinks was requested but", "-jqlupdatedby skips the updatedBy() probe but"}},
+		{name: "data center",
+			serverInfo: fakeFault{Body: `{"version": "9.4.2", "deploymentType": "DataCenter"}`},
+			want:       JiraServerInfo{DeploymentType: "DataCenter", Version: "9.4.2", Detected: true},
+			wantLinks:  "/secure/RapidBoard.jspa",
+			wantWarn:   []string{"-cloudlinks was requested but"}},
+		{name: "cloud",
+			serverInfo: fakeFault{Body: `{"version": "1001.0.0-SNAPSHOT", "deploymentType": "Cloud"}`},
+			want:       JiraServerInfo{DeploymentType: "Cloud", Version: "1001.0.0-SNAPSHOT", Detected: true},
+			wantLinks:  "/jira/software/c/projects/EXPD/boards/7/"},
+		// A proxy blocking serverInfo, or answering for it, leaves the product guessed from the URL with conservative defaults
+		{name: "blocked by a proxy",
+			serverInfo: fakeFault{Status: http.StatusForbidden},
+			want:       JiraServerInfo{DeploymentType: "Server"},
+			wantLinks:  "/secure/RapidBoard.jspa", wantProbe: true,
+			wantWarn: []string{"Could not identify the Jira product at", "-cloudlinks was requested but", "-jqlupdatedby skips the updatedBy() probe but"}},
+		{name: "not jira",
+			serverInfo: fakeFault{Body: `<html><body>Sign in to continue</body></html>`},
+			want:       JiraServerInfo{DeploymentType: "Server"},
+			wantLinks:  "/secure/RapidBoard.jspa", wantProbe: true,
+			wantWarn: []string{"the server info response is not from Jira", "-cloudlinks was requested but", "-jqlupdatedby skips the updatedBy() probe but"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			if tt.serverInfo != (fakeFault{}) {
+				fake.faults = map[string]fakeFault{"/rest/api/2/serverInfo": tt.serverInfo}
+			}
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.SprintLinks = true
+			cfg.ExcludeUpdatedBy = []string{"automation"}
+			cfg.Hooks.OnLog = recorder.log
+			report, err := Run(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			tt.want.BaseURL = fake.URL
+			if len(report.JiraServers) != 1 || report.JiraServers[0] != tt.want {
+				t.Errorf("JiraServers = %+v, want %+v", report.JiraServers, tt.want)
+			}
+			if link := readTSV(t, cfg.OutputFile)[0]["First Sprint Report URL"]; !strings.HasPrefix(link, fake.URL+tt.wantLinks) {
+				t.Errorf("sprint report link %s, want %s...", link, fake.URL+tt.wantLinks)
+			}
+			probed := false
+			for _, search := range fake.Searches() {
+				probed = probed || strings.HasPrefix(search.JQL, "project = EXPD AND issue in updatedBy(")
+			}
+			if probed != tt.wantProbe {
+				t.Errorf("updatedBy() probed = %t, want %t", probed, tt.wantProbe)
+			}
+
+			// Asking for the options the product may not support only warns
+			if tt.serverInfo != (fakeFault{}) {
+				fake.faults = map[string]fakeFault{"/rest/api/2/serverInfo": tt.serverInfo} // Faults are sent once
+			}
+			recorder = &logRecorder{}
+			cfg.CloudLinks = true
+			cfg.JQLUpdatedBy = true
+			cfg.Hooks.OnLog = recorder.log
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run with -cloudlinks -jqlupdatedby: %v", err)
+			}
+			for _, want := range tt.wantWarn {
+				if !recorder.Contains(want) {
+					t.Errorf("no warning contains %q", want)
+				}
+			}
+			if got := recorder.Count("WARNING"); got != len(tt.wantWarn) {
+				t.Errorf("%d warnings, want %d", got, len(tt.wantWarn))
+			}
+		})
+	}
+}
+
+// TestRunIncludeTime adds time tracking to the fixtures: EXPD-1 has an estimate and time logged on it and its
+// sub-tasks, EXPD-3 a negative time spent as some migrated instances return, and EXPD-4 no time tracking.
+func TestRunIncludeTime(t *testing.T) {
+	dir := rewriteFixtures(t, func(key string, fields map[string]interface{}) {
+		switch key {
+		case "EXPD-1":
+			fields["timeoriginalestimate"] = 28800
+			fields["timespent"] = 27000
+			fields["aggregatetimespent"] = 36000
+		case "EXPD-3":
+			fields["timespent"] = -60
+			fields["aggregatetimespent"] = 5400.5
+		}
+	})
+	fake := newFakeJira(t, dir)
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.IncludeTime = true
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var got []string
+	for _, row := range readTSV(t, cfg.OutputFile) {
+		got = append(got, fmt.Sprintf("%s [%s] [%s] [%s]", row["Issue Key"], row["Original Estimate (h)"], row["Time Spent (h)"], row["Aggregate Time Spent (h)"]))
+	}
+	if want := "EXPD-1 [8.0] [7.5] [10.0]; EXPD-3 [] [] [1.5]; EXPD-4 [] [] []"; strings.Join(got, "; ") != want {
+		t.Errorf("time columns:\n%s\nwant:\n%s", strings.Join(got, "; "), want)
+	}
+	if report.TimeSpentHours != 11.5 {
+		t.Errorf("TimeSpentHours = %v, want 11.5", report.TimeSpentHours)
+	}
+	if want := "Time spent: 11.5 hours logged on 2 of 3 spillover issues (including sub-tasks)"; report.TimeSpentSummary != want {
+		t.Errorf("TimeSpentSummary = %q, want %q", report.TimeSpentSummary, want)
+	}
+	for _, search := range fake.Searches() {
+		if fields := strings.Join(search.Fields, ","); !strings.Contains(fields, "timeoriginalestimate") || !strings.Contains(fields, "aggregatetimespent") {
+			t.Errorf("search at %d did not request the time tracking fields: %s", search.StartAt, fields)
+		}
+	}
+}
+
+// changelogFixtures copies testdata/jira to a temporary directory and adds a changelog for each spillover issue,
+// served the Jira Server way: the paginated changelog endpoint is missing, so the expanded issue is read.
+func changelogFixtures(t *testing.T) string {
+	t.Helper()
+	dir := rewriteFixtures(t, func(string, map[string]interface{}) {})
+	changelogs := map[string]string{
+		"EXPD-1": `[{"created":"2026-10-14T00:00:00.000+0000","author":{"displayName":"Dana Example"},"items":[{"field":"status","fieldId":"status","fromString":"To Do","toString":"In Progress"}]}]`,
+		"EXPD-3": `[]`,
+		"EXPD-4": `[]`,
+	}
+	for key, histories := range changelogs {
+		body := fmt.Sprintf(`{"key":%q,"changelog":{"histories":%s}}`, key, histories)
+		if err := os.WriteFile(filepath.Join(dir, "issue-"+key+".json"), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestRunChangelogAnalyses switches on every changelog analysis and -excludeupdatedby at once: the changelogs are
+// fetched by the shared worker pool, each issue's once, and every analysis fills in its columns from them.
+func TestRunChangelogAnalyses(t *testing.T) {
+	fake := newFakeJira(t, changelogFixtures(t))
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.Commitment = true
+	cfg.EstimateChanges = true
+	cfg.AssigneeChanges = true
+	cfg.Escalations = true
+	cfg.EstimatedLate = true
+	cfg.TimeInStatus = true
+	cfg.ExcludeUpdatedBy = []string{"Someone Else"}
+	cfg.JQLUpdatedBy = false
+	recorder := &logRecorder{}
+	cfg.Hooks.OnLog = recorder.log
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if recorder.Contains("Failed to fetch changelog") {
+		t.Error("a changelog could not be fetched")
+	}
+	rows := readTSV(t, cfg.OutputFile)
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+	// Committed At Sprint Start stays unknown: the fixture changelogs do not say when the issues entered their sprints
+	for _, row := range rows {
+		for _, column := range []string{"SP Changed In Flight", "Assignee Changes", "Escalated", "Estimated Late", "Time In Status"} {
+			if row[column] == "" || row[column] == "unknown" {
+				t.Errorf("%s %s = %q, want it worked out from the changelog", row["Issue Key"], column, row[column])
+			}
+		}
+	}
+	fetches := make(map[string]int)
+	for _, request := range fake.Requests() {
+		if key, found := strings.CutSuffix(strings.TrimPrefix(request, "GET /rest/api/2/issue/"), "/changelog"); found {
+			fetches[key]++
+		}
+	}
+	if want := map[string]int{"EXPD-1": 1, "EXPD-3": 1, "EXPD-4": 1}; fmt.Sprint(fetches) != fmt.Sprint(want) {
+		t.Errorf("changelog requests per issue = %v, want %v", fetches, want)
+	}
+}
+
+// TestRunTimeInStatusRunClock checks that unresolved issues count their current status up to the run clock, not the
+// wall clock.
+func TestRunTimeInStatusRunClock(t *testing.T) {
+	fake := newFakeJira(t, changelogFixtures(t))
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.TimeInStatus = true
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var got []string
+	for _, row := range readTSV(t, cfg.OutputFile) {
+		got = append(got, row["Issue Key"]+" ["+row["Time In Status"]+"]")
+	}
+	// EXPD-3 was resolved, so it ends at its resolution date whatever the clock
+	if want := "EXPD-1 [To Do=54.6;In Progress=1.5]; EXPD-3 [Done=63.2]; EXPD-4 [To Do=44.1]"; strings.Join(got, "; ") != want {
+		t.Errorf("time in status:\n%s\nwant:\n%s", strings.Join(got, "; "), want)
+	}
+}
+
+// TestRunEpicLookupFailures answers the lookup of epic EXPD-100, the epic of EXPD-1 and EXPD-4, with each class of
+// failure: the Epic Summary names the cause, the epic is looked up once however many issues are in it, and the
+// end of run summary counts it under its cause.
+func TestRunEpicLookupFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		fault       fakeFault
+		want        string // Epic Summary of EXPD-1 and EXPD-4
+		wantLog     string
+		wantSummary string
+	}{
+		{name: "forbidden", fault: fakeFault{Status: http.StatusForbidden}, want: epicNotAccessible,
+			wantLog:     "HTTP 403 looking up Epic EXPD-100: the reporting account may not browse its project",
+			wantSummary: "1 epics shown as 'Epic not accessible' (e.g. EXPD-100): grant the reporting account Browse Projects"},
+		// Not found by key, nor by searching for it as a moved epic
+		{name: "not found", fault: fakeFault{Status: http.StatusNotFound}, want: epicNotFound,
+			wantLog:     "HTTP 404 looking up Epic EXPD-100: it does not exist or is hidden from the reporting account",
+			wantSummary: "1 epics shown as 'Epic not found' (e.g. EXPD-100)"},
+		{name: "server error", fault: fakeFault{Status: http.StatusBadGateway}, want: epicLookupError,
+			wantLog:     "HTTP 502 error looking up Epic EXPD-100",
+			wantSummary: "1 epics shown as 'Epic lookup error' (e.g. EXPD-100): usually transient"},
+		{name: "unreadable", fault: fakeFault{Body: `<html>Service unavailable</html>`}, want: epicLookupError,
+			wantLog:     "Failed to parse Epic response for EXPD-100",
+			wantSummary: "1 epics shown as 'Epic lookup error' (e.g. EXPD-100): usually transient"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			fake.faults = map[string]fakeFault{"/rest/api/2/issue/EXPD-100": tt.fault}
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.Hooks.OnLog = recorder.log
+			if _, err := Run(context.Background(), cfg); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			for _, row := range readTSV(t, cfg.OutputFile) {
+				if row["Epic Link"] == "EXPD-100" && row["Epic Summary"] != tt.want {
+					t.Errorf("%s Epic Summary = %q, want %q", row["Issue Key"], row["Epic Summary"], tt.want)
+				}
+			}
+			lookups := 0
+			for _, request := range fake.Requests() {
+				if request == "GET /rest/api/2/issue/EXPD-100" {
+					lookups++
+				}
+			}
+			if lookups != 1 {
+				t.Errorf("EXPD-100 looked up %d times, want once", lookups)
+			}
+			for _, want := range []string{tt.wantLog, tt.wantSummary} {
+				if !recorder.Contains(want) {
+					t.Errorf("no log message contains %q", want)
+				}
+			}
+		})
+	}
+
+	// An epic moved to another project is found by searching for its old key
+	dir := rewriteFixtures(t, func(string, map[string]interface{}) {})
+	if err := os.Remove(filepath.Join(dir, "issue-EXPD-100.json")); err != nil {
+		t.Fatal(err)
+	}
+	moved := `{"issues": [{"key": "PLAT-7", "fields": {"summary": "Import pipeline", "status": {"name": "In Progress"}}}]}`
+	if err := os.WriteFile(filepath.Join(dir, "search-key-EXPD-100.json"), []byte(moved), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeJira(t, dir)
+	recorder := &logRecorder{}
+	cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+	cfg.Hooks.OnLog = recorder.log
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	row := readTSV(t, cfg.OutputFile)[0]
+	if row["Epic Summary"] != "Import pipeline" || row["Epic Key (current)"] != "PLAT-7" {
+		t.Errorf("moved epic: Epic Summary %q, current key %q; want Import pipeline, PLAT-7", row["Epic Summary"], row["Epic Key (current)"])
+	}
+	if recorder.Contains("epics shown as") {
+		t.Error("a moved epic was reported as a failed lookup")
+	}
+}
+
+// TestRunFieldNames gives the field options display names, resolved against the fixtures' field catalog.
+func TestRunFieldNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *Config)
+		fault     *fakeFault // Fault for the field catalog
+		wantErr   string
+		wantLog   []string
+		wantPair  string // Pair of EXPD-1
+	}{
+		{name: "pair and group by names",
+			configure: func(cfg *Config) { cfg.PairField, cfg.GroupByField = "pair", "Squad" },
+			wantLog:   []string{"Resolved -pair 'pair' to field customfield_10186", "Resolved -groupbyfield 'Squad' to field customfield_10300"},
+			wantPair:  "Carol Example"},
+		{name: "flagged field name",
+			configure: func(cfg *Config) { cfg.FlaggedField = "Flagged" },
+			wantLog:   []string{"Resolved -flaggedfield 'Flagged' to field customfield_10021"}},
+		// IDs need no catalog
+		{name: "field ID",
+			configure: func(cfg *Config) { cfg.PairField = "customfield_10186" },
+			wantPair:  "Carol Example"},
+		{name: "unknown name",
+			configure: func(cfg *Config) { cfg.PairField = "Pear" },
+			wantErr:   "-pair 'Pear' is not the ID or name of any Jira field (close matches: Pair (customfield_10186))"},
+		// Without the catalog a name cannot be resolved, and is not sent to Jira as if it were an ID
+		{name: "catalog blocked",
+			configure: func(cfg *Config) { cfg.PairField, cfg.GroupByField = "customfield_10186", "Squad" },
+			fault:     &fakeFault{Status: http.StatusForbidden},
+			wantErr:   "-groupbyfield 'Squad' cannot be looked up because the field list of"},
+		{name: "catalog blocked with field IDs",
+			configure: func(cfg *Config) { cfg.PairField = "customfield_10186" },
+			fault:     &fakeFault{Status: http.StatusForbidden},
+			wantPair:  "Carol Example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeJira(t, "testdata/jira")
+			if tt.fault != nil {
+				fake.faults = map[string]fakeFault{"/rest/api/2/field": *tt.fault}
+			}
+			recorder := &logRecorder{}
+			cfg := testConfig(fake, filepath.Join(t.TempDir(), "spillover.tsv"))
+			cfg.Hooks.OnLog = recorder.log
+			tt.configure(&cfg)
+			_, err := Run(context.Background(), cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			for _, want := range tt.wantLog {
+				if !recorder.Contains(want) {
+					t.Errorf("no log message contains %q", want)
+				}
+			}
+			catalogReads := 0
+			for _, request := range fake.Requests() {
+				if request == "GET /rest/api/2/field" {
+					catalogReads++
+				}
+			}
+			if catalogReads > 1 {
+				t.Errorf("field catalog read %d times, want at most once", catalogReads)
+			}
+			if tt.wantPair != "" {
+				if got := readTSV(t, cfg.OutputFile)[0]["Pair"]; got != tt.wantPair {
+					t.Errorf("EXPD-1 Pair = %q, want %q", got, tt.wantPair)
+				}
+			}
+		})
+	}
+}
+
+// TestRunSampled cuts the run short with -sample: the output file carries a Sampled column, compared with its golden
+// file, which a -input run of the file keeps. A sample that covers every issue is a full run without the column.
+func TestRunSampled(t *testing.T) {
+	dir := t.TempDir()
+	fake := newFakeJira(t, "testdata/jira")
+	cfg := testConfig(fake, filepath.Join(dir, "sampled.tsv"))
+	cfg.Sample = 2
+	report, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !report.Sampled {
+		t.Error("Sampled = false for a run cut short by -sample")
+	}
+	got, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "run-sampled.tsv", got)
+
+	input := Config{InputFile: cfg.OutputFile, OutputFile: filepath.Join(dir, "input.tsv"), Location: time.UTC}
+	report, err = Run(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Run -input: %v", err)
+	}
+	rewritten, err := os.ReadFile(input.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rewritten) != string(got) {
+		t.Errorf("-input changed the sampled report:\n%s\nwant:\n%s", rewritten, got)
+	}
+	if !report.Sampled {
+		t.Error("-input of a sampled report: Sampled = false")
+	}
+
+	cfg.OutputFile = filepath.Join(dir, "full.tsv")
+	cfg.Sample = 10
+	if report, err = Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, column := readTSV(t, cfg.OutputFile)[0]["Sampled"]; report.Sampled || column {
+		t.Errorf("a sample covering every issue: Sampled = %v, Sampled column %v", report.Sampled, column)
+	}
+}
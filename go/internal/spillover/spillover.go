@@ -0,0 +1,13203 @@
+// Package spillover finds Jira issues that were worked on in more than one sprint and writes the spillover
+// report. Run produces one report from a Config; it keeps every setting in the run's own state, so several
+// runs may go on at once, and reports its log messages and progress through Config.Hooks rather than writing
+// to the console. The jira-spillover-get command line gathers a Config from flags and prompts and calls Run.
+package spillover
+
+import (
+	"bufio"           // For reading saved reports and writing output files
+	"bytes"           // For pretty-printing raw issue JSON (DumpIssue)
+	"compress/gzip"   // For compressed output files and gzip-encoded Jira responses
+	"context"         // For cancelling requests and rate limiter waits
+	"embed"           // For the JSON Schemas of the report and stats documents
+	"encoding/binary" // For the big-endian integers of the SQLite file format
+	"encoding/json"   // For parsing JSON responses from Jira API
+	"errors"          // For identifying authentication failures
+	"fmt"             // For formatted printing and string formatting
+	"html"            // For escaping the HTML summary
+	"io"              // For reading HTTP response bodies
+	"log"             // For reporting failures to close files
+	"math"            // For rounding churn scores in posted reports
+	"net/http"        // For making HTTP requests to Jira API
+	"net/url"         // For URL encoding JQL queries
+	"os"              // For file operations
+	"path/filepath"   // For validating output paths and creating output directories
+	"regexp"          // For parsing sprint field values
+	"runtime"         // For recognising locked output files on Windows
+	"sort"            // For ordering sprints and report rows
+	"strconv"         // For string to number conversion
+	"strings"         // For string manipulation and processing
+	"sync"            // For the shared request rate limiter
+	"syscall"         // For recognising Windows sharing violations on locked output files
+	"time"            // For date validation and timestamp formatting
+	"unicode"         // For measuring the display width of output cells
+)
+
+// Default configuration constants
+const (
+	defaultStoryPointsField = "customfield_10059" // Default story points field
+	defaultSprintField      = "customfield_10020" // Default sprint field
+	defaultEpicLinkField    = "customfield_10014" // Default epic link field
+	defaultFlaggedField     = "customfield_10021" // Default Flagged (impediment) field on Jira Cloud (-flaggedfield)
+	notVisibleValue         = "(not visible)"     // Cell value for a field Jira returned for no issue, instead of the column's default
+	splitGroupColumn        = "Split Group"       // Column holding each issue's -splitby group, so concatenated split files stay self-describing
+	DefaultBatchSize        = 100                 // Number of issues to fetch per API call (search starts here unless -batchsize is given)
+	minBatchSize            = 25                  // Smallest search page size reached by halving after timeouts or HTTP 429
+	batchGrowthStreak       = 3                   // Consecutive fast search pages before the page size is grown back
+	fastBatchLatency        = 10 * time.Second    // Search pages answered quicker than this count towards growing the page size
+	rateLimitedRetryDelay   = 5 * time.Second     // Wait before retrying a search rejected with HTTP 429 and no Retry-After header
+	DefaultDaysPrior        = 10                  // Default number of days to look back
+	keysPerQuery            = 100                 // Maximum issue keys in one key in (...) query (-keysfile)
+	outputLockRetries       = 5                   // Attempts to open an output file held open by another program
+	outputLockRetryDelay    = 2 * time.Second     // Wait between attempts to open a locked output file
+	sprintLookupWorkers     = 4                   // Sprint IDs looked up at once via the Agile API (requests still honour -ratelimit)
+	changelogWorkers        = 4                   // Issue changelogs fetched at once by the changelog analyses (requests still honour -ratelimit)
+	utf8BOM                 = "\ufeff"            // UTF-8 byte order mark, so Excel does not read output files as ANSI (-bom)
+	fieldAuditTopValues     = 20                  // Distinct values listed per field by -auditfields; the rest are counted as other
+	fieldAuditValueWidth    = 60                  // Characters of a value shown by -auditfields before it is truncated
+	defaultLinkType         = "Blocks"            // Issue link type read by -includelinks unless -linktypes is given
+	DefaultFlushEvery       = 50                  // Output rows written between flushes, so files can be followed with tail -f (-flushevery)
+	outputSyncInterval      = 5 * time.Second     // Minimum time between syncs of a file being followed to disk
+)
+
+// Default thresholds and weights, used unless -stalebuckets or -scoreweights is given
+var (
+	defaultStaleBuckets = [3]int{7, 21, 60}                             // Fresh/Aging, Aging/Stale, and Stale/Abandoned day thresholds
+	DefaultScoreWeights = ScoreWeights{Sprints: 3, Points: 1, Age: 0.1} // Spillover Score weights
+)
+
+// timeNow is the run clock: each run's ages and day counts are measured to the time it returns at the start
+// of the run, and its duration to the time it returns at the end. Tests replace it to get reproducible reports.
+var timeNow = time.Now
+
+// IssueFields contains all standard and custom fields for a Jira issue.
+type IssueFields struct {
+	IssueType        IssueType                  `json:"issuetype"`         // Type of the issue (e.g., Story, Task)
+	Status           Status                     `json:"status"`            // Current status
+	Summary          string                     `json:"summary"`           // Issue summary/title
+	Updated          *string                    `json:"updated"`           // Last updated date (RFC3339)
+	Created          *string                    `json:"created"`           // Creation date (RFC3339)
+	ResolutionDate   *string                    `json:"resolutiondate"`    // Resolution date (nullable)
+	Assignee         *Assignee                  `json:"assignee"`          // Current assignee (nullable)
+	Creator          *Creator                   `json:"creator"`           // Issue creator
+	Project          Project                    `json:"project"`           // Project details
+	FixVersions      []FixVersion               `json:"fixVersions"`       // Target release versions
+	Components       []Component                `json:"components"`        // Associated components
+	Labels           []string                   `json:"labels"`            // Issue labels
+	Resolution       *Resolution                `json:"resolution"`        // Resolution status (nullable)
+	Priority         *Priority                  `json:"priority"`          // Priority (nullable)
+	DueDate          *string                    `json:"duedate"`           // Due date, date only (nullable)
+	Description      interface{}                `json:"description"`       // Plain string (API v2) or ADF document (API v3), only with -includedescription
+	Comment          *CommentField              `json:"comment"`           // Comment summary, only with -includecomments
+	IssueLinks       []IssueLink                `json:"issuelinks"`        // Links to other issues, only with -includelinks
+	StoryPoints      interface{}                `json:"customfield_10059"` // Story points (type varies)
+	SprintField      interface{}                `json:"customfield_10020"` // Sprint field (array or null)
+	EpicLinkField    interface{}                `json:"customfield_10014"` // Epic link (string or null)
+	AdditionalFields map[string]json.RawMessage `json:"-"`                 // Unmapped custom fields
+	ReturnedFields   map[string]bool            `json:"-"`                 // Every field Jira returned, null or not
+
+	// Time tracking in seconds (nil when not set or time tracking is off), only with -includetime
+	TimeOriginalEstimate *float64 `json:"timeoriginalestimate"` // Original estimate
+	TimeSpent            *float64 `json:"timespent"`            // Time logged on the issue itself
+	AggregateTimeSpent   *float64 `json:"aggregatetimespent"`   // Time logged on the issue and its sub-tasks
+}
+
+// Issue represents a Jira issue from the search API response
+type Issue struct {
+	Key      string      `json:"key"`    // Issue key (e.g., "EXPD-1234")
+	Fields   IssueFields `json:"fields"` // Issue field data
+	Instance string      `json:"-"`      // Label of the Jira instance the issue was fetched from (empty for single-instance runs)
+}
+
+// UnmarshalJSON implements custom unmarshalling to capture both known fields and any additional custom fields
+func (f *IssueFields) UnmarshalJSON(data []byte) error {
+	// Define an alias type to avoid recursion
+	type Alias IssueFields
+	aux := &struct {
+		*Alias
+	}{Alias: (*Alias)(f)}
+
+	// First unmarshal into a generic map to capture raw fields
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawMap); err != nil {
+		return err
+	}
+
+	// Unmarshal known fields from the raw map into aux
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// Store any additional fields (those not represented by the struct tags above)
+	f.AdditionalFields = make(map[string]json.RawMessage)
+	// Known keys from IssueFields tags
+	knownKeys := map[string]bool{
+		"issuetype":         true,
+		"status":            true,
+		"summary":           true,
+		"updated":           true,
+		"created":           true,
+		"resolutiondate":    true,
+		"assignee":          true,
+		"creator":           true,
+		"project":           true,
+		"fixVersions":       true,
+		"components":        true,
+		"labels":            true,
+		"resolution":        true,
+		"priority":          true,
+		"duedate":           true,
+		"description":       true,
+		"comment":           true,
+		"issuelinks":        true,
+		"customfield_10002": true,
+		"customfield_14181": true,
+		"customfield_14182": true,
+		// Do NOT include customfield_10186 (Pair) so it is added to AdditionalFields
+
+		// Time tracking, only with -includetime
+		"timeoriginalestimate": true,
+		"timespent":            true,
+		"aggregatetimespent":   true,
+	}
+
+	// Record every field returned, so a field Jira leaves out (e.g. hidden by field-level security) can be told from one that is null
+	f.ReturnedFields = make(map[string]bool, len(rawMap))
+	for k, v := range rawMap {
+		f.ReturnedFields[k] = true
+		if !knownKeys[k] {
+			f.AdditionalFields[k] = v
+		}
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// additionalField returns the raw JSON of an unmapped field, treating a JSON null the same as a missing field
+//
+// Parameters:
+//   name - field name (e.g., "customfield_10186")
+//
+// Returns:
+//   json.RawMessage - raw field value
+//   bool            - false if the field is missing or null
+func (f *IssueFields) additionalField(name string) (json.RawMessage, bool) {
+	raw, ok := f.AdditionalFields[name]
+	if !ok || len(raw) == 0 || strings.TrimSpace(string(raw)) == "null" {
+		return nil, false
+	}
+	return raw, true
+}
+
+// IssueType represents issue type information
+type IssueType struct {
+	Name string `json:"name"` // Issue type name (Story, Task, Bug, etc.)
+}
+
+// Status represents issue status information
+type Status struct {
+	Name           string         `json:"name"`           // Status name (Closed, Story Done, etc.)
+	StatusCategory StatusCategory `json:"statusCategory"` // Category the status belongs to, whatever the workflow calls it
+}
+
+// StatusCategory is one of the fixed categories Jira groups every workflow status into.
+type StatusCategory struct {
+	Key  string `json:"key"`  // new, indeterminate, or done
+	Name string `json:"name"` // Display name (To Do, In Progress, or Done on an English site)
+}
+
+// Assignee contains the identity of the assignee.
+type Assignee struct {
+	DisplayName  string `json:"displayName"`
+	AccountID    string `json:"accountId"`    // Jira Cloud account ID (absent on Jira Server)
+	EmailAddress string `json:"emailAddress"` // Often hidden by profile privacy settings
+	Name         string `json:"name"`         // Username on Jira Server/Data Center (absent on Jira Cloud)
+}
+
+// Creator contains the identity of the creator/reporter.
+type Creator struct {
+	DisplayName  string `json:"displayName"`
+	AccountID    string `json:"accountId"`    // Jira Cloud account ID (absent on Jira Server)
+	EmailAddress string `json:"emailAddress"` // Often hidden by profile privacy settings
+}
+
+// Project contains the key and name of a Jira project.
+type Project struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// FixVersion contains the name of a fix version.
+type FixVersion struct {
+	Name string `json:"name"`
+}
+
+// Component contains the name of a component.
+type Component struct {
+	Name string `json:"name"`
+}
+
+// Resolution contains the name of a resolution.
+type Resolution struct {
+	Name string `json:"name"`
+}
+
+// Priority contains the name of a priority.
+type Priority struct {
+	Name string `json:"name"`
+}
+
+// CommentField contains the comment total of an issue; comment bodies are not used.
+type CommentField struct {
+	Total int `json:"total"`
+}
+
+// IssueLink is one link from an issue to another. Jira sets InwardIssue when the other issue is on the
+// inward side of the link type (e.g. "is blocked by" for Blocks), OutwardIssue when it is on the outward side.
+type IssueLink struct {
+	Type         IssueLinkType `json:"type"`
+	InwardIssue  *LinkedIssue  `json:"inwardIssue"`  // Set when the other issue is inward, e.g. the blocker
+	OutwardIssue *LinkedIssue  `json:"outwardIssue"` // Set when the other issue is outward, e.g. the blocked issue
+}
+
+// IssueLinkType identifies the kind of an issue link.
+type IssueLinkType struct {
+	Name    string `json:"name"`    // Link type name (e.g., "Blocks")
+	Inward  string `json:"inward"`  // Inward description (e.g., "is blocked by")
+	Outward string `json:"outward"` // Outward description (e.g., "blocks")
+}
+
+// LinkedIssue is the other issue of an issue link; only its key is used.
+type LinkedIssue struct {
+	Key string `json:"key"`
+}
+
+// PairMember contains the identity of a pair programming member.
+type PairMember struct {
+	DisplayName  string `json:"displayName"`
+	AccountID    string `json:"accountId"`    // Jira Cloud account ID (absent on Jira Server)
+	EmailAddress string `json:"emailAddress"` // Often hidden by profile privacy settings
+}
+
+// SearchResponse is the response from Jira's search API.
+type SearchResponse struct {
+	Issues     []Issue `json:"issues"`
+	Total      int     `json:"total"`
+	StartAt    int     `json:"startAt"`
+	MaxResults int     `json:"maxResults"`
+}
+
+// searchRequest is the JSON body of a POST to Jira's search API.
+type searchRequest struct {
+	JQL        string   `json:"jql"`
+	StartAt    int      `json:"startAt"`
+	MaxResults int      `json:"maxResults"`
+	Fields     []string `json:"fields,omitempty"`
+}
+
+// SelfTestCheck is one row of the -selftest results table.
+type SelfTestCheck struct {
+	Name     string // What was checked
+	Required bool   // A failed required check makes -selftest exit with status 1; others are reported as warnings
+	Passed   bool   // The check succeeded
+	Skipped  bool   // The check was not run (an earlier check it depends on failed, or it does not apply)
+	Detail   string // What was found, or why the check failed or was skipped
+	Hint     string // One-line remediation shown when the check fails
+}
+
+// ProjectInfo contains basic project information for validation.
+type ProjectInfo struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// EpicInfo contains epic information for title lookups.
+type EpicInfo struct {
+	Key    string           `json:"key"`
+	Fields EpicFieldsLookup `json:"fields"`
+}
+
+// EpicFieldsLookup contains the fields requested when looking up an epic.
+type EpicFieldsLookup struct {
+	Summary        string  `json:"summary"`
+	Status         Status  `json:"status"`
+	ResolutionDate *string `json:"resolutiondate"` // nil while the epic is unresolved
+}
+
+// EpicMeta holds what the report shows about a spillover issue's epic.
+type EpicMeta struct {
+	Summary        string  // Epic summary (epicNotAccessible, epicNotFound, or epicLookupError if the lookup failed)
+	Status         string  // Epic status name (empty if unknown)
+	ResolutionDate *string // When the epic was resolved (nil if unresolved or unknown)
+	CurrentKey     string  // Key the epic has now, when it was moved to another project since being linked (empty otherwise)
+}
+
+// SprintDetail contains the attributes of a single sprint parsed from the sprint field.
+type SprintDetail struct {
+	ID        string     // Sprint ID (empty if not supplied)
+	Name      string     // Sprint name, with " (board <id>)" appended when another sprint in the same issue shares it
+	BoardID   string     // ID of the board the sprint belongs to (empty if not supplied)
+	State     string     // Sprint state (active, closed, future)
+	StartDate *time.Time // Sprint start date (nil if not started or unparseable)
+	EndDate   *time.Time // Sprint end date (nil if not set or unparseable)
+	Goal      string     // Sprint goal (empty if not set)
+}
+
+// SprintInfo contains parsed sprint information for an issue.
+type SprintInfo struct {
+	SprintCount int            // Number of sprints the issue has been in
+	SprintNames []string       // List of unique sprint names, in chronological order (see sprintBefore)
+	Sprints     []SprintDetail // Details of each unique sprint, in the same order as SprintNames
+	FirstSprint string         // Name of the chronologically first sprint
+	FirstGoal   string         // Goal of the first sprint (empty if not set)
+	LastSprint  string         // Name of the chronologically last sprint
+	AllSprints  string         // Comma-separated list of all sprint names
+
+	// Names of the sprints whose state is active: the issue's current sprint (normally at most one)
+	ActiveSprints []string
+
+	// Planned dates of FirstSprint and LastSprint (nil when the sprint field does not supply them)
+	FirstStart *time.Time
+	FirstEnd   *time.Time
+	LastStart  *time.Time
+	LastEnd    *time.Time
+}
+
+// ScoreWeights are the weights of the Spillover Score column (-scoreweights).
+type ScoreWeights struct {
+	Sprints float64 // Weight of each sprint after the first
+	Points  float64 // Weight of each story point
+	Age     float64 // Weight of each day since the issue was created
+}
+
+// SprintPairStat contains the spillover totals for one boundary between two consecutive sprints.
+type SprintPairStat struct {
+	FromSprint  SprintDetail // Sprint the issues spilled out of
+	ToSprint    SprintDetail // Sprint the issues spilled into
+	IssueCount  int          // Number of spillover issues that spanned this boundary
+	StoryPoints float64      // Sum of story points of those issues
+}
+
+// SprintFlowStat holds the spillover entering and leaving one sprint (-persprint).
+type SprintFlowStat struct {
+	Sprint           SprintDetail // The sprint
+	SpilledIn        int          // Spillover issues that came into this sprint from an earlier one
+	SpilledInPoints  float64      // Sum of story points of those issues
+	SpilledOut       int          // Spillover issues that left this sprint unfinished for a later one
+	SpilledOutPoints float64      // Sum of story points of those issues
+}
+
+// jiraField is one entry of Jira's field list (/rest/api/2/field), used to resolve field names given to options.
+type jiraField struct {
+	ID   string `json:"id"`   // Field ID (e.g., "customfield_10186", "components")
+	Name string `json:"name"` // Display name (e.g., "Pair")
+}
+
+// FieldAudit summarises the values of one configured custom field across the fetched issues (-auditfields).
+type FieldAudit struct {
+	Setting string         // Option the field is configured with (e.g., "-pair")
+	FieldID string         // Jira field ID
+	Values  map[string]int // Issue count per distinct value, as extracted for the report
+	Missing int            // Issues Jira did not return the field for (it does not exist, or is not on the issue's screens)
+	Null    int            // Issues where the field is null
+	Empty   int            // Issues where the field holds an empty string or array, or nothing the report can use
+}
+
+// EpicRollupStat holds spillover totals for one epic (-epicrollup).
+type EpicRollupStat struct {
+	EpicKey         string   // Epic key, or "No Epic" for issues without an epic link
+	EpicSummary     string   // Epic summary from the epic lookups already made for the report
+	IssueCount      int      // Number of spillover issues in this epic
+	StoryPoints     float64  // Sum of story points of those issues
+	EarliestCreated *string  // Created date of the oldest of those issues (nil if none could be parsed)
+	IssueKeys       []string // Keys of those issues, in report order
+}
+
+// ReleaseStat holds spillover totals for one fix version (-byrelease).
+type ReleaseStat struct {
+	Version     string  // Fix version name, or "(no version)"
+	IssueCount  int     // Number of spillover issues targeted at this version
+	StoryPoints float64 // Sum of story points of those issues
+}
+
+// StatusDuration holds the time one issue spent in one status (-timeinstatus).
+type StatusDuration struct {
+	Status string  // Status name as shown in the changelog
+	Days   float64 // Days spent in the status, summed over every visit
+}
+
+// MultisprintIssue represents an issue that has been in multiple sprints.
+type MultisprintIssue struct {
+	Issue         Issue      // The original issue data
+	WorkedSprints int        // Number of sprints worked
+	EpicLink      string     // Epic key or "No Epic"
+	ResolvedDate  *time.Time // When issue was resolved (if applicable)
+	SprintInfo    SprintInfo // Sprint information for the issue
+	Group         string     // Value of the -groupbyfield field, or "(none)" (empty when not grouping)
+	Staleness     string     // Fresh, Aging, Stale, Abandoned, or Unknown based on days since last update
+	Overdue       bool       // Resolved after its due date, or unresolved and past it
+
+	FirstSprintReportURL string // Sprint report link for the first sprint (-sprintlinks, empty if the sprint or board ID is unknown)
+	LastSprintReportURL  string // Sprint report link for the last sprint (-sprintlinks, empty if the sprint or board ID is unknown)
+
+	CommittedAtStart   string // "yes"/"no" if the issue was in its first sprint when it started, "unknown" if undeterminable (-commitment)
+	EstimateChanged    string // "yes"/"no" if story points changed after the issue entered its first sprint, "unknown" if undeterminable (-estimatechanges)
+	OriginalSP         string // Story points when the issue entered its first sprint (-estimatechanges, empty if undeterminable)
+	AssigneeChanges    string // Assignee changes since the issue entered its first sprint, "unknown" if undeterminable (-assigneechanges)
+	Assignees          string // Distinct assignees since the issue entered its first sprint, "unknown" if undeterminable (-assigneechanges)
+	Escalated          string // "yes"/"no" if the priority was raised after the issue entered its first sprint, "unknown" if undeterminable (-escalations)
+	UnestimatedSprints string // Sprints the issue was in that started before it was first estimated (-estimatedlate, empty if undeterminable)
+	EstimatedLate      string // "yes"/"no" if UnestimatedSprints is at least 1, "unknown" if undeterminable (-estimatedlate)
+	TimeInStatus       string // Days per status packed as "status=days;status=days", "unknown" if undeterminable (-timeinstatus)
+	SinceLast          string // "New" or "Carried" against the -compare report (empty without -compare)
+	ChangesSinceLast   string // Tracked columns that changed since the -compare report, e.g. "status: To Do→In Progress" (carried issues only)
+	FirstReported      string // yyyy-MM-dd the issue was first reported according to the -registry file (empty without -registry)
+	WeeksOnReport      string // Weeks since FirstReported, rounded up and at least 1 (empty without -registry)
+	SplitGroup         string // Output file group: the last sprint or the resolution month (-splitby, empty otherwise)
+
+	InputValues map[string]string // Cells of the row read from a saved report, keyed by column name (-input, nil otherwise)
+}
+
+// WrittenOutputFile is one file written by writeOutputFile: the whole report, or one group's issues with -splitby.
+type WrittenOutputFile struct {
+	Group string // Split group the file holds (empty without -splitby)
+	Path  string // File written, which is a timestamped substitute if the requested file was locked
+	Rows  int    // Issues written to the file
+}
+
+// JiraInstance is one Jira site queried by a multi-instance run (-url and -tokenfile given more than once).
+// Each instance has its own token (re-read from its own token file if rejected) and its own connection pool.
+type JiraInstance struct {
+	Label       string // Short name shown in the Instance column (e.g., "cloud", "server")
+	JiraBaseURL string // Jira base URL
+	AuthToken   string // Base64 username:api-token
+	TokenFile   string // Token file re-read if Jira rejects the token mid-run (optional)
+
+	transport *jiraTransport // HTTP layer used for this instance's requests, set by Run
+	server    JiraServerInfo // Product and version behind the instance, detected by Run
+}
+
+// JiraServerInfo identifies the Jira product behind an instance, read from /rest/api/2/serverInfo at the start of
+// a run. Behaviour that differs between Cloud, Server, and Data Center is chosen from it (see decideCapabilities).
+type JiraServerInfo struct {
+	Instance       string // Instance label (empty when a single instance is queried)
+	BaseURL        string // Jira base URL
+	DeploymentType string // Cloud, Server, or DataCenter; guessed from the URL when not Detected
+	Version        string // Jira version (e.g., "9.12.0"); empty when not Detected
+	Detected       bool   // False if serverInfo could not be read (e.g., blocked by a proxy), so conservative defaults apply
+}
+
+// jiraCapabilities are the behaviour choices made for an instance from its JiraServerInfo.
+type jiraCapabilities struct {
+	CloudLinks   bool // Sprint report links use the Cloud board URL format rather than the RapidBoard one
+	UpdatedByJQL bool // The updatedBy() JQL function is known to exist, so -excludeupdatedby needs no probe query
+}
+
+// Config holds the settings for one report run. The CLI fills it from command line flags and prompts;
+// Run never prompts, so callers must supply every required field.
+type Config struct {
+	JiraBaseURL        string         // Jira base URL (required)
+	AuthToken          string         // Base64 username:api-token (required unless OAuth is set)
+	OAuth              *OAuthSession  // OAuth 2.0 session supplying Bearer tokens instead of AuthToken (optional)
+	Location           *time.Location // Timezone for output dates and day calculations (time.Local when nil)
+	ProjectKey         string         // Jira project key (required)
+	DaysPrior          int            // Number of days prior to today to check (DefaultDaysPrior when 0)
+	ResolvedWithin     int            // Skip issues resolved longer ago than this many days (0 = never skip)
+	ResolvedFrom       time.Time      // With ResolvedWithin, the resolved window start (zero = ResolvedWithin days ago)
+	OutputFile         string         // Output filename (required)
+	AppendMode         bool           // Append to the output file instead of overwriting
+	Dedupe             bool           // With AppendMode, skip issue keys already in the output file
+	Repair             bool           // With AppendMode, truncate a partial last line left by an interrupted run instead of refusing to append
+	IgnoreLabels       []string       // Spillover issues carrying any of these labels are excluded
+	ExcludedFile       string         // With IgnoreLabels, optional filename for the excluded issues
+	FixVersions        []string       // Only check issues targeted at these fix versions
+	ByRelease          bool           // Summarise spillover per fix version into Report.ReleaseStats
+	StaleBuckets       [3]int         // Staleness thresholds in days (current thresholds when zero)
+	ScoreWeights       ScoreWeights   // Spillover Score weights (current weights when zero)
+	SprintPairsFile    string         // Optional filename for spillover totals per consecutive sprint pair
+	EpicRollupFile     string         // Optional filename for spillover totals per epic
+	PerSprintFile      string         // Optional filename for spillover into and out of each sprint
+	AllIssuesFile      string         // Optional filename for every processed issue
+	RequestsPerSecond  float64        // Maximum Jira requests per second (0 = unlimited)
+	ResolveSprintIDs   bool           // Resolve bare sprint IDs via the Agile API
+	StrictNames        bool           // Compare sprint names exactly instead of ignoring case and extra whitespace
+	AllSprintsMax      int            // Maximum length of the All Sprints column (0 = no limit)
+	PairField          string         // Optional custom field name for Pair data
+	Commitment         bool           // Fetch changelogs for the Committed At Sprint Start column
+	EstimateChanges    bool           // Fetch changelogs for the SP Changed In Flight and Original SP columns
+	AssigneeChanges    bool           // Fetch changelogs for the Assignee Changes and Distinct Assignees columns
+	Escalations        bool           // Fetch changelogs for the Escalated column (priority raised in flight)
+	EstimatedLate      bool           // Fetch changelogs for the Unestimated Sprints and Estimated Late columns
+	TimeInStatus       bool           // Fetch changelogs for the days each issue spent in each status
+	StatusColumns      []string       // With TimeInStatus, statuses given their own days column (all statuses packed into one column when empty)
+	SkipFailedPages    bool           // Retry a failing search page once, then skip it and continue instead of failing the run
+	Sample             int            // Fetch only the first Sample matching issues for a quick trial run (0 = all)
+	IdentityMode       string         // People column format: display (default), email, accountid, or display+email
+	IncludeEpics       bool           // Include Epics placed directly into sprints
+	GroupByField       string         // Optional custom field to group output rows by
+	Subtotals          bool           // With GroupByField, write a subtotal row after each group
+	OrderBy            string         // Row order: key (default), updated, created, priority, or score
+	IncludeDescription bool           // Add a Description column with a one-line excerpt
+	DescriptionLength  int            // Maximum Description length in characters (200 when 0)
+	IncludeComments    bool           // Add a Comments column with the comment count
+	IncludeTime        bool           // Add Original Estimate, Time Spent and Aggregate Time Spent columns in hours
+	IncludeLinks       bool           // Add Blocked By and Blocks columns from the issue links
+	LinkTypes          []string       // With IncludeLinks, the link type names to read (Blocks when empty)
+	SprintLinks        bool           // Add First/Last Sprint Report URL columns linking to each sprint's report
+	ProjectCacheTTL    time.Duration  // How long a validated project is trusted without asking Jira (DefaultProjectCacheTTL when 0, negative disables)
+	RefreshCache       bool           // Revalidate the project even if the project cache holds a recent entry
+	CloudLinks         bool           // With SprintLinks, use Jira Cloud report URLs instead of Server/Data Center RapidBoard URLs
+	NoLockFallback     bool           // Fail instead of writing a timestamped substitute when the output file is locked
+	NoVerify           bool           // Skip re-reading the output file to check every row was written
+	FlushEvery         int            // Flush the output files to disk every this many rows (DefaultFlushEvery when 0, negative only at the end)
+	NoAtomic           bool           // Write the output file in place instead of to a temporary file renamed over it when complete
+	BatchSize          int            // Starting search page size (DefaultBatchSize when 0)
+	FixedBatch         bool           // Keep the search page size fixed instead of adapting it to timeouts, HTTP 429, and latency
+	SearchGET          bool           // Send searches as GET with the JQL in the URL, for instances that reject POST /rest/api/2/search
+	GoalContains       string         // Only report spillover issues whose first sprint goal contains this text (case-insensitive)
+	DateField          string         // Date field for the JQL date range: updated (default), statusCategoryChangedDate, or resolved
+	MaxCellWidth       int            // Maximum characters in any output cell, longer cells end in "..." (0 = no limit)
+	GracePeriod        int            // Exclude two-sprint issues whose latest sprint started fewer than this many days ago (0 = off)
+	OpenCategoryOnly   bool           // Only report spillover issues whose status category is not Done (also added to the JQL)
+	FlaggedField       string         // Flagged (impediment) field ID (defaultFlaggedField when empty)
+	FlaggedOnly        bool           // Only report spillover issues that are flagged as an impediment
+	ActiveOnly         bool           // Only report spillover issues that are in an active sprint now
+	PrioritiesOnly     []string       // Only report spillover issues with one of these priorities (case-insensitive)
+	ExcludeUpdatedBy   []string       // Exclude issues updated by these users (e.g., automation accounts), via JQL updatedBy() or changelogs
+	JQLUpdatedBy       bool           // Use JQL updatedBy() for ExcludeUpdatedBy without probing the instances for support
+	MinChurn           float64        // Only report spillover issues with at least this churn score (0 disables the filter)
+	Format             string         // Output file format, a ReportFormats key (tsv when empty)
+	BOM                bool           // Start new output files with a UTF-8 byte order mark (ignored by formats that do not allow one)
+	Compress           bool           // Write the output file gzip-compressed, with ".gz" appended to its name (not with AppendMode)
+	SplitBy            string         // Write one output file per group: none (default), lastsprint, or month (of resolution)
+	IssueKeys          []string       // Check exactly these issues instead of querying ProjectKey; ProjectKey and DaysPrior are then unused
+	InputFile          string         // Rebuild the spillover issues from this saved report instead of querying Jira; Jira settings are then unused
+	CompareFile        string         // Earlier report to mark each spillover issue New or Carried against, listing what changed on carried ones
+	RegistryFile       string         // Persistent registry of when each spillover issue was first and last reported, updated by the run (optional)
+	SummaryOnly        bool           // Write a Summary document instead of the per-issue rows; Format is then text (default) or html
+	Instances          []JiraInstance // Query each of these instances and merge the results; JiraBaseURL and AuthToken are then unused
+	PreferInstance     string         // Label of the instance whose copy is kept when several return the same key (default: first Cloud instance)
+	AuditFields        bool           // Summarise the configured custom fields' values in Report.FieldAudits; with no OutputFile, stop there
+	ExtraOutputFiles   []string       // Files the caller writes itself (e.g. a problems file), checked for writability with the output files
+
+	Program     string   // Program name recorded in posted reports and summaries
+	Version     string   // Program version recorded alongside Program
+	Debug       bool     // Log debugging detail and send every issue's debug dumps to Hooks.OnIssueDebug
+	DebugIssues []string // Send the debug dumps of just these issues to Hooks.OnIssueDebug
+
+	Confirm     func(plan RunPlan) bool                                          // Optional; called with the run plan before fetching, returning false aborts the run
+	ReloadToken func(ctx context.Context, instance JiraInstance) (string, error) // Optional; re-reads the token after Jira rejects it mid-run ("" keeps the current token)
+	Hooks       Hooks                                                            // Optional progress callbacks, e.g. to drive a progress bar when embedding Run
+}
+
+// RunPlan describes what a run is about to fetch. It is passed to Config.Confirm before any issues are fetched.
+type RunPlan struct {
+	JQL             string // JQL query the run will execute (one query per line for IssueKeys chunks)
+	User            string // Display name of the authenticated user ("unknown" if Jira did not say)
+	EstimatedIssues int    // Issues the JQL matches (-1 if Jira could not say)
+}
+
+// Hooks are optional callbacks reporting a run's progress. They are called synchronously from the goroutine
+// running Run, so they must return quickly; a callback left nil is not called. The CLI's console progress
+// messages are written by its own Hooks (see cliHooks).
+type Hooks struct {
+	OnBatchFetched   func(fetched, total int)                                  // After each search page: issues fetched so far and the query's total
+	OnEpicLookup     func(done, total int)                                     // Before each epic summary lookup and once all are done
+	OnIssueProcessed func(done, total int)                                     // Before each fetched issue is checked for spillover and once all are done
+	OnRetry          func(url string, attempt int, wait time.Duration)         // Before a request is retried; attempt is the number of the attempt about to be made
+	OnPhase          func(name string)                                         // When the run starts a new phase, e.g. "Fetching issues from Jira"
+	OnLog            func(level string, logContext LogContext, message string) // Each DEBUG, INFO, WARNING, or ERROR message; may be called from several goroutines at once
+	OnIssueDebug     func(issueKey, message string)                            // Each per-issue debug dump with Config.Debug or Config.DebugIssues
+}
+
+// Report holds the results of one Run.
+type Report struct {
+	Issues                []MultisprintIssue  // Spillover issues written to the output file, in output order
+	IgnoredIssues         []MultisprintIssue  // Spillover issues excluded by IgnoreLabels
+	Epics                 map[string]EpicMeta // Epic summary, status, and resolution date keyed by epic key
+	FetchedCount          int                 // Number of issues returned by the JQL query
+	ResolvedExcludedCount int                 // Number of issues skipped by ResolvedWithin
+	PairFieldMissing      bool                // PairField was requested but not found on any issue
+	NotVisibleFields      []string            // Requested fields Jira returned for no issue, e.g. hidden by field-level security
+	ReleaseStats          []ReleaseStat       // Spillover per fix version (ByRelease only)
+	EpicRollup            []EpicRollupStat    // Spillover per epic, most issues first (EpicRollupFile only)
+	StalenessCounts       map[string]int      // Spillover issue count per staleness bucket
+	StalenessSummary      string              // Staleness counts formatted for display
+	StatusCategoryCounts  map[string]int      // Spillover issue count per status category name ("Unknown" when Jira gave none)
+	StatusCategorySummary string              // Status category counts formatted for display
+	PriorityCounts        map[string]int      // Spillover issue count per priority name ("No priority" when Jira gave none)
+	PrioritySummary       string              // Priority counts formatted for display
+	OverdueCount          int                 // Spillover issues resolved after, or still open past, their due date
+	ResolvedEpicCount     int                 // Spillover issues whose epic is already resolved
+	InconsistentRows      int                 // Output rows whose derived fields disagreed before writing (see verifyReportRows)
+	BlockedChainCount     int                 // Spillover issues blocked by another spillover issue (IncludeLinks only)
+	BlockedChainSummary   string              // BlockedChainCount formatted for display (empty without IncludeLinks)
+	TimeSpentHours        float64             // Aggregate time spent on the spillover issues in hours (IncludeTime only)
+	TimeSpentSummary      string              // TimeSpentHours formatted for display (empty without IncludeTime)
+	IgnoreSummary         string              // Ignore label exclusions formatted for display (empty without IgnoreLabels)
+	GoalFilterSummary     string              // GoalContains exclusions formatted for display (empty without GoalContains)
+	OpenCategorySummary   string              // OpenCategoryOnly exclusions formatted for display (empty without OpenCategoryOnly)
+	FlaggedOnlySummary    string              // FlaggedOnly exclusions formatted for display (empty without FlaggedOnly)
+	ActiveOnlySummary     string              // ActiveOnly exclusions formatted for display (empty without ActiveOnly)
+	MinChurnSummary       string              // MinChurn exclusions formatted for display (empty without MinChurn)
+	TopChurn              []MultisprintIssue  // Up to five spillover issues with the highest churn score, highest first
+	TopScores             []MultisprintIssue  // Up to summaryTopCount spillover issues with the highest spillover score, highest first
+	UnscoredPointsCount   int                 // Spillover issues whose story points are not numeric, scored on sprints and age only
+	FlaggedCount          int                 // Spillover issues flagged as an impediment
+	ActiveSprintCount     int                 // Spillover issues in an active sprint now (the rest are not in one)
+	GraceSummary          string              // GracePeriod exclusions formatted for display (empty without GracePeriod)
+	CommitmentSummary     string              // Mid-sprint addition count formatted for display (empty without Commitment)
+	EstimateSummary       string              // In-flight estimate change count formatted for display (empty without EstimateChanges)
+	AssigneeSummary       string              // Average assignee churn formatted for display (empty without AssigneeChanges)
+	EscalationSummary     string              // In-flight priority escalation count formatted for display (empty without Escalations)
+	EstimatedLateSummary  string              // Count of issues estimated after their first sprint started, formatted for display (empty without EstimatedLate)
+	PrioritiesOnlySummary string              // PrioritiesOnly exclusions formatted for display (empty without PrioritiesOnly)
+	UpdatedByExcluded     int                 // Issues excluded by ExcludeUpdatedBy
+	UpdatedBySummary      string              // ExcludeUpdatedBy exclusions and the mechanism used, formatted for display (empty without ExcludeUpdatedBy)
+	TimeInStatusSummary   string              // Average days per status formatted for display (empty without TimeInStatus)
+	CompareSummary        string              // New, carried, and dropped issue counts against CompareFile formatted for display (empty without CompareFile)
+	PreviousCount         int                 // Spillover issues in CompareFile (0 without CompareFile)
+	RegistrySummary       string              // Newly and previously reported issue counts from RegistryFile formatted for display (empty without RegistryFile)
+	StartedAt             time.Time           // When the run started
+	FetchDuration         time.Duration       // Time spent fetching issues from Jira
+	Duration              time.Duration       // Total run time
+	JQL                   string              // JQL query the issues were fetched with
+	JiraServers           []JiraServerInfo    // Product and version of each Jira instance queried (empty with InputFile)
+	OutputFile            string              // File the issues were written to; a timestamped substitute if the requested file was locked (empty with SplitBy)
+	OutputFiles           []WrittenOutputFile // Files the issues were written to, one per group with SplitBy
+	WrittenFiles          []string            // Every report file written, in the order written (copied by -archivedir)
+	MissingKeys           []string            // IssueKeys that Jira could not find (or the user cannot see)
+	TruncatedCells        int                 // Output cells shortened to MaxCellWidth
+	TruncatedIssues       int                 // Issues with at least one output cell shortened to MaxCellWidth
+	SkippedPages          int                 // Search pages skipped after failing twice (SkipFailedPages)
+	SkippedIssues         int                 // Approximate number of issues on the skipped pages
+	Sampled               bool                // Only the first Sample matching issues were fetched, so every count covers the sample only
+	FieldAudits           []FieldAudit        // Values of each configured custom field across the fetched issues (AuditFields only)
+	Stats                 RunStats            // Request, batch, epic, and warning counts for the -statsfile (Parameters left to the caller)
+
+	run *runState // The run's settings, for output built from the report after Run returns
+}
+
+// ReportRecord is one output row: each column name with its cell value, in output order.
+type ReportRecord struct {
+	Columns  []string // Column names, the same for every row of a file
+	Values   []string // Cell values, one per column
+	Subtotal bool     // True for a -subtotals row summarising the group above it
+}
+
+// ReportWriter serialises report rows in one output format (-format). Writers only serialise: the rows
+// are built, filtered, and counted before they reach the writer.
+type ReportWriter interface {
+	WriteHeader(columns []string) error // Called once before the first row, unless appending to a file that has a header
+	WriteRow(record ReportRecord) error // Called for each data or subtotal row
+	Flush() error                       // Writes buffered rows out so the file can be followed while it is written
+	Close() error                       // Flushes buffered output; the caller closes the underlying file
+	SupportsAppend() bool               // True if rows can be added to an existing file of this format
+}
+
+// reportFormat is an output format registered in ReportFormats.
+type reportFormat struct {
+	Extension string                         // File extension, including the dot
+	NewWriter func(w io.Writer) ReportWriter // Creates a writer for one output file
+	AllowsBOM bool                           // True if new files may start with a UTF-8 byte order mark (-bom)
+}
+
+// ReportFormats is the registry of output formats, keyed by -format name.
+var ReportFormats = map[string]reportFormat{
+	"tsv": {Extension: ".tsv", NewWriter: newTSVReportWriter, AllowsBOM: true},
+}
+
+// summaryTopCount is the number of groups listed in each Summary breakdown.
+const summaryTopCount = 10
+
+// Summary is the one-page overview of a run written by -summaryonly. It holds the content only: the renderers in
+// SummaryFormats lay it out, so another channel (e.g. email or chat) needs just another renderer.
+type Summary struct {
+	Title      string             // Heading, e.g. "Spillover summary: EXPD"
+	Run        []SummaryItem      // Run metadata: program, Jira, project or input, JQL, start time, and score formula
+	Totals     []SummaryItem      // Spillover counts, rate, and story point totals
+	TopScores  []SummaryItem      // Issues with the highest spillover score, highest first
+	Breakdowns []SummaryBreakdown // Spillover by issue type, priority, epic, and assignee
+	Trend      []string           // Change against the CompareFile report (empty without CompareFile)
+}
+
+// SummaryItem is one labelled value in a Summary section.
+type SummaryItem struct {
+	Label string
+	Value string
+}
+
+// SummaryBreakdown ranks the spillover issues grouped one way, most issues first.
+type SummaryBreakdown struct {
+	Title string                // Section heading, e.g. "By issue type"
+	Group string                // Heading for the group names, e.g. "Issue type"
+	Rows  []SummaryBreakdownRow // The first summaryTopCount groups
+	More  int                   // Groups left out after summaryTopCount
+}
+
+// SummaryBreakdownRow is one group of a SummaryBreakdown.
+type SummaryBreakdownRow struct {
+	Name        string  // Group name, e.g. an issue type or "EXPD-1 Checkout redesign"
+	Issues      int     // Spillover issues in the group
+	StoryPoints float64 // Story points of those issues (unestimated issues add nothing)
+}
+
+// summaryFormat is a -summaryonly document format registered in SummaryFormats.
+type summaryFormat struct {
+	Extension string                                   // File extension, including the dot
+	Render    func(w io.Writer, summary Summary) error // Writes the whole document
+}
+
+// SummaryFormats is the registry of -summaryonly document formats, keyed by -format name.
+var SummaryFormats = map[string]summaryFormat{
+	"text": {Extension: ".txt", Render: renderSummaryText},
+	"html": {Extension: ".html", Render: renderSummaryHTML},
+}
+
+// timeTrackingFields are the time tracking fields requested with -includetime, each in seconds.
+var timeTrackingFields = []string{"timeoriginalestimate", "timespent", "aggregatetimespent"}
+
+// jsonSchemas maps each -printschema name to its embedded JSON Schema file. Every document the tool writes is
+// validated against its schema first, so the published contract and the code cannot drift apart unnoticed.
+var jsonSchemas = map[string]string{
+	"report": "schemas/report.schema.json", // PostedReport, sent to -posturl
+	"stats":  "schemas/stats.schema.json",  // RunStats, written to -statsfile
+}
+
+// schemaFiles holds the files listed in jsonSchemas.
+//
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+// PostedReport is the JSON document sent to -posturl.
+type PostedReport struct {
+	Run     postedRunInfo `json:"run"`
+	Issues  []postedIssue `json:"issues"`
+	Summary postedSummary `json:"summary"`
+}
+
+// postedRunInfo describes the run that produced a posted report.
+type postedRunInfo struct {
+	Program              string             `json:"program"`
+	Version              string             `json:"version"`
+	JiraBaseURL          string             `json:"jiraBaseUrl"`
+	Project              string             `json:"project"`
+	JQL                  string             `json:"jql"`
+	Timezone             string             `json:"timezone"`
+	StartedAt            string             `json:"startedAt"` // RFC3339
+	DurationSeconds      float64            `json:"durationSeconds"`
+	FetchDurationSeconds float64            `json:"fetchDurationSeconds"`
+	Sampled              bool               `json:"sampled"`                    // true if -sample limited the issues fetched
+	SampleSize           int                `json:"sampleSize,omitempty"`       // Only with -sample
+	ScoreFormula         string             `json:"scoreFormula"`               // How the Spillover Score is calculated
+	NotVisibleFields     []string           `json:"notVisibleFields,omitempty"` // Requested fields Jira returned for no issue
+	JiraServers          []jiraServerRecord `json:"jiraServers,omitempty"`      // Product and version of each instance queried
+}
+
+// jiraServerRecord is a JiraServerInfo in the posted report and -statsfile documents.
+type jiraServerRecord struct {
+	Instance       string `json:"instance,omitempty"` // Only when querying several Jira instances
+	BaseURL        string `json:"baseUrl"`
+	DeploymentType string `json:"deploymentType"`    // Cloud, Server, or DataCenter
+	Version        string `json:"version,omitempty"` // Empty if serverInfo could not be read
+	Detected       bool   `json:"detected"`          // false if deploymentType was guessed from the URL
+}
+
+// postedIssue is one spillover issue in a posted report, mirroring the output file columns.
+type postedIssue struct {
+	Key                string   `json:"key"`
+	IssueType          string   `json:"issueType"`
+	Summary            string   `json:"summary"`
+	Status             string   `json:"status"`
+	StatusCategory     string   `json:"statusCategory"`
+	Priority           string   `json:"priority"`
+	Assignee           string   `json:"assignee"`
+	Reporter           string   `json:"reporter"`
+	Created            string   `json:"created"`  // yyyy-MM-dd in the report timezone
+	Updated            string   `json:"updated"`  // yyyy-MM-dd in the report timezone
+	Resolved           string   `json:"resolved"` // Empty if unresolved
+	DueDate            string   `json:"dueDate"`  // Empty if not set
+	Overdue            bool     `json:"overdue"`
+	Flagged            bool     `json:"flagged"`
+	StoryPoints        *float64 `json:"storyPoints"` // null if not estimated
+	ChurnScore         *float64 `json:"churnScore"`  // null if story points are not numeric
+	SpilloverScore     float64  `json:"spilloverScore"`
+	FixVersions        []string `json:"fixVersions"`
+	Labels             []string `json:"labels"`
+	EpicLink           string   `json:"epicLink"`
+	EpicSummary        string   `json:"epicSummary"`
+	EpicStatus         string   `json:"epicStatus"`
+	EpicResolved       string   `json:"epicResolved"`             // Empty if the epic is unresolved
+	EpicCurrentKey     string   `json:"epicCurrentKey,omitempty"` // Only when the epic has moved to another key
+	SprintCount        int      `json:"sprintCount"`
+	Sprints            []string `json:"sprints"`
+	FirstSprint        string   `json:"firstSprint"`
+	FirstSprintGoal    string   `json:"firstSprintGoal"`
+	LastSprint         string   `json:"lastSprint"`
+	FirstSprintStart   string   `json:"firstSprintStart"` // Empty if the sprint field has no dates
+	FirstSprintEnd     string   `json:"firstSprintEnd"`
+	LastSprintStart    string   `json:"lastSprintStart"`
+	LastSprintEnd      string   `json:"lastSprintEnd"`
+	CurrentSprint      string   `json:"currentSprint"` // Active sprint name(s); empty if not in an active sprint
+	InActiveSprint     bool     `json:"inActiveSprint"`
+	Staleness          string   `json:"staleness"`
+	CommittedAtStart   string   `json:"committedAtStart,omitempty"`   // Only with -commitment
+	EstimateChanged    string   `json:"estimateChanged,omitempty"`    // Only with -estimatechanges
+	OriginalSP         string   `json:"originalSp,omitempty"`         // Only with -estimatechanges
+	AssigneeChanges    string   `json:"assigneeChanges,omitempty"`    // Only with -assigneechanges
+	Assignees          string   `json:"assignees,omitempty"`          // Only with -assigneechanges; distinct assignees
+	Escalated          string   `json:"escalated,omitempty"`          // Only with -escalations
+	UnestimatedSprints string   `json:"unestimatedSprints,omitempty"` // Only with -estimatedlate
+	EstimatedLate      string   `json:"estimatedLate,omitempty"`      // Only with -estimatedlate
+	TimeInStatus       string   `json:"timeInStatus,omitempty"`       // Only with -timeinstatus; "status=days;status=days"
+	SinceLast          string   `json:"sinceLast,omitempty"`          // Only with -compare; New or Carried
+	ChangesSinceLast   string   `json:"changesSinceLast,omitempty"`   // Only with -compare; empty when nothing tracked changed
+	FirstReported      string   `json:"firstReported,omitempty"`      // Only with -registry; yyyy-MM-dd
+	WeeksOnReport      string   `json:"weeksOnReport,omitempty"`      // Only with -registry
+	Group              string   `json:"group,omitempty"`              // Only with -groupbyfield
+	Description        string   `json:"description,omitempty"`        // Only with -includedescription
+	CommentCount       *int     `json:"commentCount,omitempty"`       // Only with -includecomments
+	EstimateHours      *float64 `json:"estimateHours,omitempty"`      // Only with -includetime: original estimate, when set
+	TimeSpentHours     *float64 `json:"timeSpentHours,omitempty"`     // Only with -includetime: time logged on the issue, when set
+	TotalSpentHours    *float64 `json:"totalSpentHours,omitempty"`    // Only with -includetime: time logged including sub-tasks, when set
+	BlockedBy          []string `json:"blockedBy,omitempty"`          // Only with -includelinks
+	Blocks             []string `json:"blocks,omitempty"`             // Only with -includelinks
+	FirstSprintURL     string   `json:"firstSprintUrl,omitempty"`     // Only with -sprintlinks
+	LastSprintURL      string   `json:"lastSprintUrl,omitempty"`      // Only with -sprintlinks
+	Instance           string   `json:"instance,omitempty"`           // Only when querying several Jira instances
+}
+
+// postedSummary holds the summary statistics of a posted report.
+type postedSummary struct {
+	FetchedCount          int             `json:"fetchedCount"`
+	SpilloverCount        int             `json:"spilloverCount"`
+	IgnoredCount          int             `json:"ignoredCount"`
+	ResolvedExcludedCount int             `json:"resolvedExcludedCount"`
+	OverdueCount          int             `json:"overdueCount"`
+	FlaggedCount          int             `json:"flaggedCount"`
+	ActiveSprintCount     int             `json:"activeSprintCount"`
+	ResolvedEpicCount     int             `json:"resolvedEpicCount"`
+	BlockedChainCount     *int            `json:"blockedChainCount,omitempty"` // Only with -includelinks
+	TimeSpentHours        *float64        `json:"timeSpentHours,omitempty"`    // Only with -includetime
+	StalenessCounts       map[string]int  `json:"stalenessCounts"`
+	StatusCategoryCounts  map[string]int  `json:"statusCategoryCounts"`
+	Releases              []postedRelease `json:"releases,omitempty"`    // Only with -byrelease
+	MissingKeys           []string        `json:"missingKeys,omitempty"` // Only with -keysfile
+}
+
+// postedRelease is one -byrelease line in a posted report.
+type postedRelease struct {
+	Version     string  `json:"version"`
+	IssueCount  int     `json:"issueCount"`
+	StoryPoints float64 `json:"storyPoints"`
+}
+
+// projectCacheEntry records a project that validateProject found on a Jira instance.
+type projectCacheEntry struct {
+	Name        string    `json:"name"`        // Project name reported by Jira
+	ValidatedAt time.Time `json:"validatedAt"` // When the project was last validated
+	JiraHost    string    `json:"jiraHost"`    // Jira base URL the project was validated on
+}
+
+// projectCache is the persistent project metadata cache (see projectCacheFileName).
+type projectCache struct {
+	Projects map[string]projectCacheEntry `json:"projects"` // Keyed by Jira base URL and project key (see projectCacheKey)
+}
+
+// registryEntry records when an issue was first and last reported (see issueRegistry).
+type registryEntry struct {
+	FirstSeen string `json:"firstSeen"` // yyyy-MM-dd of the first run that reported the issue
+	LastSeen  string `json:"lastSeen"`  // yyyy-MM-dd of the latest run that reported the issue
+}
+
+// issueRegistry is the persistent -registry file. Issues no longer reported keep their entries, so an issue
+// that returns shows when it was first reported.
+type issueRegistry struct {
+	Issues map[string]registryEntry `json:"issues"` // Keyed by issue key
+}
+
+// SQLite file format constants used by -sqlitefile
+const (
+	sqliteHeaderMagic       = "SQLite format 3\x00"
+	sqlitePageSize          = 4096    // Page size of the databases written
+	sqliteWriterVersion     = 3046000 // SQLite version number recorded as the last writer, for tools that report it
+	sqliteLeafTablePage     = 0x0d    // B-tree page type of a table leaf page
+	sqliteInteriorTablePage = 0x05    // B-tree page type of a table interior page
+	sqliteLeafIndexPage     = 0x0a    // B-tree page type of an index leaf page
+	sqliteInteriorIndexPage = 0x02    // B-tree page type of an index interior page
+)
+
+// sqliteRow is a row of a table b-tree in a -sqlitefile database.
+type sqliteRow struct {
+	RowID   int64
+	Payload []byte // Column values in the SQLite record format
+}
+
+// sqliteObject is a sqlite_schema entry of a -sqlitefile database: a table, index, view, or trigger.
+type sqliteObject struct {
+	Type      string       // table, index, view, or trigger
+	Name      string       // Object name
+	TableName string       // Table the object belongs to (its own name for tables)
+	RootPage  int64        // Root page of a table's or index's b-tree (0 for views, triggers, and virtual tables)
+	SQL       string       // Statement that created the object
+	Rows      []sqliteRow  // Table rows in rowid order
+	Index     *sqliteIndex // How an index is rebuilt from its table's rows
+}
+
+// sqliteIndex is how an index of a -sqlitefile database is rebuilt when the database is rewritten.
+type sqliteIndex struct {
+	Unique  bool                // UNIQUE index: two rows may not have the same non-NULL key
+	Columns []sqliteIndexColumn // Indexed columns, in key order
+}
+
+// sqliteIndexColumn is one column of a sqliteIndex.
+type sqliteIndexColumn struct {
+	Name       string // Column name, for error messages
+	Position   int    // Position of the column's value in the table record, or -1 for an INTEGER PRIMARY KEY (the rowid)
+	Collation  string // BINARY, NOCASE, or RTRIM
+	Descending bool
+	HasDefault bool // The column has a DEFAULT, which SQLite gives rows written before the column was added
+}
+
+// sqliteColumn is a column of a table, as far as rebuilding its indexes needs.
+type sqliteColumn struct {
+	Name       string
+	RowID      bool   // INTEGER PRIMARY KEY column, whose value is the rowid
+	Collation  string // Collation from the column definition (BINARY if none)
+	HasDefault bool
+}
+
+// sqliteToken is a token of a CREATE statement in the schema of a -sqlitefile database.
+type sqliteToken struct {
+	Text   string // Identifier or keyword (unquoted), or a single punctuation character
+	Quoted bool   // Quoted identifier or string, so never a keyword
+}
+
+// sqliteReader reads the pages of a SQLite database file held in memory.
+type sqliteReader struct {
+	data       []byte
+	pageSize   int
+	usableSize int // Page size less the bytes reserved at the end of each page
+}
+
+// sqliteWriter builds the pages of a SQLite database file in memory.
+type sqliteWriter struct {
+	pageSize int
+	pages    [][]byte // Page 1 first
+}
+
+// sqliteExportTables are the tables -sqlitefile creates in a database that does not have them yet.
+var sqliteExportTables = []struct{ Name, SQL string }{
+	{"runs", `CREATE TABLE runs (
+  id INTEGER PRIMARY KEY,
+  started_at TEXT NOT NULL,
+  program TEXT NOT NULL,
+  version TEXT NOT NULL,
+  jira_base_url TEXT,
+  project TEXT,
+  jql TEXT,
+  timezone TEXT,
+  duration_seconds REAL,
+  sampled INTEGER NOT NULL,
+  fetched_count INTEGER NOT NULL,
+  spillover_count INTEGER NOT NULL,
+  ignored_count INTEGER NOT NULL,
+  resolved_excluded_count INTEGER NOT NULL
+)`},
+	{"issues", `CREATE TABLE issues (
+  id INTEGER PRIMARY KEY,
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  issue_key TEXT NOT NULL,
+  issue_type TEXT,
+  summary TEXT,
+  status TEXT,
+  status_category TEXT,
+  priority TEXT,
+  assignee TEXT,
+  reporter TEXT,
+  created TEXT,
+  updated TEXT,
+  resolved TEXT,
+  due_date TEXT,
+  story_points REAL,
+  churn_score REAL,
+  sprint_count INTEGER NOT NULL,
+  first_sprint TEXT,
+  last_sprint TEXT,
+  epic_link TEXT,
+  epic_summary TEXT,
+  staleness TEXT,
+  flagged INTEGER NOT NULL,
+  overdue INTEGER NOT NULL,
+  instance TEXT
+)`},
+	{"sprints", `CREATE TABLE sprints (
+  id INTEGER PRIMARY KEY,
+  issue_id INTEGER NOT NULL REFERENCES issues(id),
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  issue_key TEXT NOT NULL,
+  sprint_order INTEGER NOT NULL,
+  sprint_name TEXT NOT NULL
+)`},
+}
+
+// RunStats is the JSON document written to -statsfile. It is written at exit even when the run fails or is
+// interrupted, in which case Partial is true and the result counts may be incomplete.
+type RunStats struct {
+	Program           string             `json:"program"`
+	Version           string             `json:"version"`
+	Partial           bool               `json:"partial"`                    // true unless the run completed
+	Sampled           bool               `json:"sampled"`                    // true if -sample limited the issues fetched
+	DurationSeconds   float64            `json:"durationSeconds"`            // Whole program run time
+	JQLTotal          int                `json:"jqlTotal"`                   // Issues matching the JQL as reported by Jira (summed over queries and instances)
+	ProcessedCount    int                `json:"processedCount"`             // Issues checked for spillover after the -resolvedwithin filter
+	SpilloverCount    int                `json:"spilloverCount"`             // Spillover issues written to the output file
+	BatchesFetched    int                `json:"batchesFetched"`             // Search requests made
+	EpicLookups       int                `json:"epicLookups"`                // Epic summary lookups attempted
+	EpicLookupsFailed int                `json:"epicLookupsFailed"`          // Epic summary lookups that failed
+	EpicsInaccessible int                `json:"epicsNotAccessible"`         // Failed lookups answered HTTP 403 (included in EpicLookupsFailed)
+	EpicsNotFound     int                `json:"epicsNotFound"`              // Failed lookups answered HTTP 404 (included in EpicLookupsFailed)
+	WarningCount      int                `json:"warningCount"`               // WARNING messages logged
+	SkippedPages      int                `json:"skippedPages"`               // Search pages skipped after failing twice (-skipfailedpages)
+	SkippedIssues     int                `json:"skippedIssues"`              // Approximate number of issues on the skipped pages
+	NotVisibleFields  []string           `json:"notVisibleFields,omitempty"` // Requested fields Jira returned for no issue
+	JiraServers       []jiraServerRecord `json:"jiraServers,omitempty"`      // Product and version of each instance queried
+	Parameters        StatsParameters    `json:"parameters"`
+}
+
+// StatsParameters records the effective settings of the run in a -statsfile document.
+type StatsParameters struct {
+	JiraBaseURLs   []string `json:"jiraBaseUrls"`
+	Project        string   `json:"project,omitempty"`
+	IssueKeyCount  int      `json:"issueKeyCount,omitempty"` // Only with -keysfile
+	Sample         int      `json:"sample,omitempty"`        // Only with -sample
+	DaysPrior      int      `json:"daysPrior"`
+	ResolvedWithin int      `json:"resolvedWithin"`
+	OutputFile     string   `json:"outputFile"`
+	Append         bool     `json:"append"`
+	FixVersions    []string `json:"fixVersions,omitempty"`
+	IgnoreLabels   []string `json:"ignoreLabels,omitempty"`
+	GoalContains   string   `json:"goalContains,omitempty"`
+	OrderBy        string   `json:"orderBy"`
+	DateField      string   `json:"dateField"`
+	ScoreFormula   string   `json:"scoreFormula"`
+	JQL            string   `json:"jql,omitempty"` // Empty if the run stopped before querying Jira
+}
+
+// ChangelogItem is a single field change within a changelog history entry.
+type ChangelogItem struct {
+	Field      string `json:"field"`      // Field name (e.g., "Sprint")
+	FieldID    string `json:"fieldId"`    // Field ID (e.g., "customfield_10020"), not supplied by older Jira Server versions
+	From       string `json:"from"`       // Raw value before the change (sprint IDs are comma-separated)
+	FromString string `json:"fromString"` // Display value before the change
+	To         string `json:"to"`         // Raw value after the change
+	ToString   string `json:"toString"`   // Display value after the change
+}
+
+// ChangelogHistory is one changelog entry: a set of field changes made together.
+type ChangelogHistory struct {
+	ID      string          `json:"id"`
+	Created string          `json:"created"` // When the change was made
+	Author  *Assignee       `json:"author"`  // Who made the change (nil for changes made anonymously)
+	Items   []ChangelogItem `json:"items"`
+}
+
+// ChangelogPage is a page of changelog entries from /rest/api/2/issue/{key}/changelog (Jira Cloud).
+type ChangelogPage struct {
+	StartAt    int                `json:"startAt"`
+	MaxResults int                `json:"maxResults"`
+	Total      int                `json:"total"`
+	IsLast     bool               `json:"isLast"`
+	Values     []ChangelogHistory `json:"values"`
+}
+
+// ExpandedChangelog is the issue response shape for ?expand=changelog (Jira Server/Data Center).
+type ExpandedChangelog struct {
+	Changelog struct {
+		Histories []ChangelogHistory `json:"histories"`
+	} `json:"changelog"`
+}
+
+// rateLimiter is a token-bucket limiter shared by every request made to Jira.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64   // Tokens added per second (requests per second)
+	tokens float64   // Tokens currently available (at most one, so requests are never bunched)
+	last   time.Time // When tokens were last replenished
+}
+
+// apiRequestStats records how many requests were sent to Jira and when, for the end-of-run summary.
+type apiRequestStats struct {
+	mu    sync.Mutex
+	count int       // Number of requests sent
+	first time.Time // When the first request was sent
+	last  time.Time // When the most recent request was sent
+
+	receivedBytes int64 // Response body bytes received, as sent by Jira (gzip-compressed when Jira compressed them)
+	decodedBytes  int64 // Response body bytes after decompression
+}
+
+// countingWriter counts the bytes written through it (the uncompressed size of a -compress output file).
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+// countingReader adds the bytes read through it to one of the apiStats byte counts.
+type countingReader struct {
+	reader  io.Reader
+	counter *int64           // stats.receivedBytes or stats.decodedBytes
+	stats   *apiRequestStats // Statistics the counter belongs to, locked while it is updated
+}
+
+// countedResponseBody replaces a Jira response body: it decompresses a gzip-encoded body and counts the bytes
+// received and decoded for logAPIRequestStats.
+type countedResponseBody struct {
+	wire     io.ReadCloser // Body as received from Jira, closed by Close
+	received io.Reader     // wire, counting the bytes received
+	gzipped  bool          // true if Jira sent the body gzip-encoded
+	decoded  io.Reader     // Decoded body, counting its bytes; created on the first Read
+
+	stats *apiRequestStats // Statistics of the run the request belongs to
+}
+
+// jiraTransport is the shared HTTP layer: it applies the rate limit and records statistics for every request.
+type jiraTransport struct {
+	base http.RoundTripper
+	rs   *runState // Run the requests belong to, for its rate limit, statistics, hooks, and OAuth session
+}
+
+// OAuthConfig is the -oauthconfig file for an OAuth 2.0 (3LO) app. It is rewritten when the refresh token is rotated.
+type OAuthConfig struct {
+	ClientID      string `json:"client_id"`          // OAuth app client ID
+	ClientSecret  string `json:"client_secret"`      // OAuth app client secret
+	RefreshToken  string `json:"refresh_token"`      // Refresh token exchanged for access tokens
+	TokenEndpoint string `json:"token_endpoint"`     // Token endpoint (e.g., https://auth.atlassian.com/oauth/token)
+	CloudID       string `json:"cloud_id,omitempty"` // Optional Atlassian site ID; requests then go to https://api.atlassian.com/ex/jira/{cloud_id}
+}
+
+// oauthTokenResponse is the token endpoint's reply to a refresh_token grant.
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`     // Present when the server rotates the refresh token
+	ExpiresIn        int    `json:"expires_in"`        // Access token lifetime in seconds
+	Error            string `json:"error"`             // Error code (e.g., invalid_grant) when the request fails
+	ErrorDescription string `json:"error_description"` // Human readable error detail
+}
+
+// OAuthSession holds the OAuth configuration and the current access token for the run.
+type OAuthSession struct {
+	mu          sync.Mutex
+	configPath  string      // Path of the -oauthconfig file, rewritten when the refresh token is rotated
+	Config      OAuthConfig // Current configuration (including the latest refresh token)
+	accessToken string      // Current access token (empty until the first refresh)
+	expiresAt   time.Time   // When the access token expires
+
+	logMessage func(level, message string) // Receives the session's INFO and ERROR messages (nil discards them)
+}
+
+// runState holds the settings and progress of one Run. Every function that depends on the run's settings is a
+// method on it, so concurrent runs (e.g. a server embedding Run) never share state.
+type runState struct {
+	runContext context.Context // runContext is cancelled on Ctrl-C so waits and requests stop promptly
+	runHooks   Hooks           // runHooks are the Config.Hooks of the run, called through their nil-safe methods
+	runStats   RunStats        // runStats collects the -statsfile counts as the run progresses, returned in Report.Stats
+	statsMutex sync.Mutex      // statsMutex guards runStats.WarningCount, which goroutines logging at once all update
+	startTime  time.Time       // startTime is when the run started: ages and day counts are measured to it
+
+	apiRateLimiter  *rateLimiter                                                     // apiRateLimiter limits requests per second when -ratelimit is set (nil = unlimited)
+	apiStats        apiRequestStats                                                  // apiStats counts requests sent to Jira
+	sharedTransport *jiraTransport                                                   // sharedTransport is used by every Jira client (the current instance's with several instances)
+	activeOAuth     *OAuthSession                                                    // activeOAuth supplies Bearer tokens when -oauthconfig is set (nil = Basic auth token file)
+	reloadToken     func(ctx context.Context, instance JiraInstance) (string, error) // reloadToken is Config.ReloadToken (nil = never re-read)
+	tokenInstance   JiraInstance                                                     // tokenInstance is the instance whose token is in use, passed to reloadToken
+
+	pairFieldName     string // pairFieldName is the JSON field name to look up for Pair information when provided
+	pairFieldProvided bool   // pairFieldProvided is true when Config.PairField was given
+
+	debug          bool            // debug is Config.Debug: log debugging detail and every issue's debug dumps
+	debugIssueKeys map[string]bool // debugIssueKeys are the Config.DebugIssues issues whose debug dumps are wanted
+
+	resolveSprintIDsEnabled bool                    // resolveSprintIDsEnabled is true when -resolvesprintids was provided
+	resolvedSprints         map[string]SprintDetail // resolvedSprints caches sprint details looked up by sprint ID
+	strictSprintNames       bool                    // strictSprintNames keeps sprint names as Jira gives them (-strictnames)
+	allSprintsMaxLength     int                     // allSprintsMaxLength caps the All Sprints output cell (0 = no limit)
+	maxCellWidth            int                     // maxCellWidth caps every output cell in characters (-maxcellwidth, 0 = no limit)
+	truncatedCellCount      int                     // truncatedCellCount counts output cells shortened to maxCellWidth
+	truncatedRowCount       int                     // truncatedRowCount counts output rows with at least one shortened cell
+	skipFailedPages         bool                    // skipFailedPages is true when -skipfailedpages was provided, so a failing search page is skipped
+	skippedPageCount        int                     // skippedPageCount counts search pages skipped after failing twice
+	skippedIssueCount       int                     // skippedIssueCount approximates the issues on the skipped search pages
+	sampleSize              int                     // sampleSize caps the issues fetched when -sample was provided (0 = fetch all)
+	sampleLimited           bool                    // sampleLimited is true once -sample stopped a search before every matching issue was fetched, adding a Sampled column
+	unparseableTimes        map[string]bool         // unparseableTimes holds the distinct timestamps parseJiraTime could not parse this run
+	failedEpicKeys          map[string][]string     // failedEpicKeys holds the epics whose lookup failed this run, by Epic Summary value
+
+	identityMode          string // identityMode selects what identifies people in output: display, email, accountid, or display+email
+	identityFallbackCount int    // identityFallbackCount counts identities that fell back to display name because email/accountId was missing
+
+	commitmentAnalysis   bool                          // commitmentAnalysis is true when -commitment was provided
+	estimateAnalysis     bool                          // estimateAnalysis is true when -estimatechanges was provided
+	assigneeAnalysis     bool                          // assigneeAnalysis is true when -assigneechanges was provided
+	escalationAnalysis   bool                          // escalationAnalysis is true when -escalations was provided
+	lateEstimateAnalysis bool                          // lateEstimateAnalysis is true when -estimatedlate was provided
+	changelogCache       map[string][]ChangelogHistory // changelogCache holds changelogs already fetched, keyed by issue key
+	changelogMutex       sync.Mutex                    // changelogMutex guards changelogCache, which the fetchChangelogs workers all update
+
+	timeInStatusAnalysis bool     // timeInStatusAnalysis is true when -timeinstatus or -statuscolumns was provided
+	compareEnabled       bool     // compareEnabled is true when -compare was provided, adding Since Last and Changes Since Last columns
+	registryEnabled      bool     // registryEnabled is true when -registry was provided, adding First Reported and Weeks On Report columns
+	statusColumnNames    []string // statusColumnNames are the statuses given their own days column (-statuscolumns)
+
+	includeEpics     bool   // includeEpics is true when -includeepics was provided, so Epics are checked for spillover too
+	groupByFieldName string // groupByFieldName is the JSON field name used to group output rows when -groupbyfield is provided
+	subtotalsEnabled bool   // subtotalsEnabled is true when -subtotals was provided, adding a subtotal row after each group
+	orderByField     string // orderByField is the -orderby field (key, updated, created, priority, or score) for the JQL and output rows
+	dateFieldName    string // dateFieldName is the -datefield used by the JQL date range (updated, statusCategoryChangedDate, or resolved)
+	outputFormat     string // outputFormat is the -format of the output file, a key of ReportFormats
+	writeBOM         bool   // writeBOM is true when new output files start with a UTF-8 byte order mark (-bom)
+	compressOutput   bool   // compressOutput is true when -compress was provided, so the output file is written gzip-compressed
+
+	includeDescription   bool // includeDescription is true when -includedescription was provided, adding a Description column
+	descriptionMaxLength int  // descriptionMaxLength caps the Description column (-descriptionlength, default 200)
+	includeComments      bool // includeComments is true when -includecomments was provided, adding a Comments count column
+	includeTime          bool // includeTime is true when -includetime was provided, adding time tracking columns in hours
+	sprintLinksEnabled   bool // sprintLinksEnabled is true when -sprintlinks was provided, adding sprint report URL columns
+
+	includeLinks     bool            // includeLinks is true when -includelinks was provided, adding Blocked By and Blocks columns
+	linkTypeNames    []string        // linkTypeNames are the issue link types -includelinks reads (-linktypes)
+	flaggedFieldName string          // flaggedFieldName is the -flaggedfield ID read for the Flagged column
+	notVisibleFields map[string]bool // notVisibleFields are the requested fields Jira returned for no issue, whose columns show notVisibleValue
+
+	projectCacheTTL     time.Duration  // projectCacheTTL is how long a validated project is trusted (-projectcachettl, 0 disables the cache)
+	refreshProjectCache bool           // refreshProjectCache is true when -refreshcache was provided, so projects are always revalidated
+	reportLocation      *time.Location // reportLocation is the -timezone used for output dates and day calculations
+
+	dedupeEnabled         bool   // dedupeEnabled is true when -dedupe was provided, so append mode skips issue keys already in the file
+	repairEnabled         bool   // repairEnabled is true when -repair was provided, so append mode truncates a partial last line
+	lockFallbackEnabled   bool   // lockFallbackEnabled is false when -nolockfallback was provided, so a locked output file fails the run
+	instanceColumnEnabled bool   // instanceColumnEnabled is true when several Jira instances are queried, adding an Instance column
+	splitBy               string // splitBy is the -splitby grouping ("lastsprint" or "month") writing one output file per group, empty for a single file
+	verifyOutputEnabled   bool   // verifyOutputEnabled is false when -noverify was provided, skipping the output row count check
+	flushEvery            int    // flushEvery is the -flushevery row count between output flushes (0 or less flushes only at the end)
+	atomicOutput          bool   // atomicOutput is false when -noatomic was provided, so new output files are written in place
+
+	searchBatchSize int  // searchBatchSize is the starting search page size (-batchsize)
+	fixedBatchSize  bool // fixedBatchSize is true when -fixedbatch was provided, so the search page size never adapts
+	searchUsesGET   bool // searchUsesGET is true when -searchget was provided, so searches put the JQL in the URL instead of a POST body
+
+	staleBuckets     [3]int       // staleBuckets are the Fresh/Aging, Aging/Stale, and Stale/Abandoned day thresholds (-stalebuckets)
+	spilloverWeights ScoreWeights // spilloverWeights weight the Spillover Score column (-scoreweights)
+}
+
+// errAuthRejected is returned when Jira still rejects a request with 401/403 after re-reading the token and retrying
+var errAuthRejected = errors.New("authentication rejected by Jira")
+
+// Epic Summary values written when an epic lookup fails, one per cause so permission problems stand out
+const (
+	epicNotAccessible = "Epic not accessible" // HTTP 403: the reporting account may not browse the epic's project
+	epicNotFound      = "Epic not found"      // HTTP 404: no such epic, or one Jira hides from the reporting account
+	epicLookupError   = "Epic lookup error"   // Anything else, usually transient (network, HTTP 5xx, unreadable response)
+)
+
+// Validated projects are remembered in this file under the user cache directory, so repeat runs skip validation
+const (
+	projectCacheDirName    = "jira-spillover-get"
+	projectCacheFileName   = "project-cache.json"
+	DefaultProjectCacheTTL = 24 * time.Hour
+)
+
+// ErrRefreshTokenRejected is returned when the OAuth token endpoint refuses the refresh token, so the app must be re-authorised
+var ErrRefreshTokenRejected = errors.New("OAuth refresh token rejected by token endpoint")
+
+// Errors returned by Run so callers can tell configuration and user aborts apart from Jira failures
+var (
+	errMissingConfig     = errors.New("missing required configuration")
+	ErrProjectValidation = errors.New("project validation failed")
+	ErrRunAborted        = errors.New("run aborted at the confirmation step")
+	errIssueNotFound     = errors.New("issue not found")
+	errFieldResolution   = errors.New("field could not be resolved")
+	ErrUnknownSchema     = errors.New("unknown schema name")
+)
+
+// Precompiled regular expressions used in per-issue processing
+var (
+	ProjectKeyRegex        = regexp.MustCompile(`^[A-Z0-9]+$`)                                 // Validates project key format
+	cellWhitespaceReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ") // Keeps free text in one TSV cell
+	customFieldIDRegex     = regexp.MustCompile(`^customfield_\d+$`)                           // Custom field IDs are used without looking up the field list
+)
+
+// compareColumns are the output columns diffed for carried issues (-compare), with their label in Changes Since Last
+var compareColumns = []struct{ Column, Label string }{
+	{"Status", "status"},
+	{"Assignee", "assignee"},
+	{"Story Points", "points"},
+	{"Number of Sprints", "sprints"},
+	{"Last Sprint", "last sprint"},
+}
+
+// jiraTimeLayouts are the timestamp formats parseJiraTime accepts, tried in order. Jira Cloud and Server/Data
+// Center 8.x and 9.x send issue dates with milliseconds and an offset without a colon ("2025-08-01T14:22:07.000+1000",
+// "+0000" on Cloud); the Agile API and some Server versions send RFC3339 ("2025-08-01T04:22:07.000Z",
+// "2025-08-01T14:22:07+10:00"); due dates and saved reports use plain dates. The fractional seconds are optional.
+var jiraTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z0700",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// LogContext carries optional structured fields for a log message. They appear as separate
+// fields in JSON log output and identify the key a WARNING/ERROR relates to in the problems file.
+type LogContext struct {
+	Issue  string // Issue key the message relates to
+	Epic   string // Epic key the message relates to
+	Sprint string // Sprint ID the message relates to
+	Batch  int    // Search batch number (0 when not applicable)
+}
+
+/********************************************************************************************************************************/
+// writeLog passes a log message to Hooks.OnLog, counting warnings towards the run's statistics
+//
+// Parameters:
+//   level   - log level ("INFO", "WARNING", "ERROR")
+//   message - message to log
+func (rs *runState) writeLog(level, message string) {
+	rs.writeLogWithContext(level, LogContext{}, message)
+}
+
+/********************************************************************************************************************************/
+// writeLogWithContext passes a log message with structured fields to Hooks.OnLog, counting warnings towards the
+// run's statistics
+//
+// Parameters:
+//   level      - log level ("INFO", "WARNING", "ERROR")
+//   logContext - structured fields for the message (zero value for none)
+//   message    - message to log
+//
+// Safe for concurrent use: the warning count is updated under statsMutex.
+func (rs *runState) writeLogWithContext(level string, logContext LogContext, message string) {
+	if level == "WARNING" {
+		rs.statsMutex.Lock()
+		rs.runStats.WarningCount++
+		rs.statsMutex.Unlock()
+	}
+	rs.runHooks.log(level, logContext, message)
+}
+
+/***********************************************************************************************************************************/
+// wantIssueDebug reports whether the per-issue debug dumps of an issue are wanted
+//
+// Parameters:
+//   issueKey - key of the issue
+//
+// Returns:
+//   bool - true with Config.Debug, or when the issue is one of Config.DebugIssues
+func (rs *runState) wantIssueDebug(issueKey string) bool {
+	return rs.debug || rs.debugIssueKeys[issueKey]
+}
+
+/***********************************************************************************************************************************/
+// JSONSchema returns the embedded JSON Schema of a document the tool writes (-printschema)
+//
+// Parameters:
+//   schemaName - jsonSchemas key: report or stats
+//
+// Returns:
+//   []byte - the schema document
+//   error  - ErrUnknownSchema for any other name
+func JSONSchema(schemaName string) ([]byte, error) {
+	schemaFile, known := jsonSchemas[schemaName]
+	if !known {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSchema, schemaName)
+	}
+	return schemaFiles.ReadFile(schemaFile)
+}
+
+/***********************************************************************************************************************************/
+// ValidateJSONDocument checks a document the tool is about to write against its embedded JSON Schema
+//
+// Parameters:
+//   schemaName - jsonSchemas key of the schema the document must match
+//   document   - encoded JSON document
+//
+// Returns:
+//   error - nil if the document matches, otherwise the JSON path of the first mismatch (always a bug in the tool)
+func ValidateJSONDocument(schemaName string, document []byte) error {
+	schemaJSON, err := JSONSchema(schemaName)
+	if err != nil {
+		return fmt.Errorf("failed to read the %s schema: %w", schemaName, err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		return fmt.Errorf("failed to parse the %s schema: %w", schemaName, err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return fmt.Errorf("failed to parse the %s document for validation: %w", schemaName, err)
+	}
+	if err := checkJSONSchema(schema, value, "$"); err != nil {
+		return fmt.Errorf("%s document does not match its schema (a bug, please report it): %w", schemaName, err)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// checkJSONSchema validates a decoded JSON value against a JSON Schema
+//
+// Only the keywords the embedded schemas use are supported: type (a name or a list of names), properties,
+// required, additionalProperties (false or a schema), items, and minimum. Other keywords, such as title and
+// description, are ignored. Object properties are checked in name order so the error reported is stable.
+//
+// Parameters:
+//   schema - schema, decoded from JSON
+//   value  - value to check, decoded from JSON
+//   path   - JSON path of value for error messages (e.g., "$.issues[3].storyPoints")
+//
+// Returns:
+//   error - the first mismatch found, prefixed with its path
+func checkJSONSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if types, ok := schema["type"]; ok {
+		var allowed []string
+		switch types := types.(type) {
+		case string:
+			allowed = []string{types}
+		case []interface{}:
+			for _, name := range types {
+				allowed = append(allowed, fmt.Sprint(name))
+			}
+		}
+		actual := jsonTypeName(value)
+		matched := false
+		for _, name := range allowed {
+			matched = matched || name == actual || (name == "number" && actual == "integer")
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(allowed, " or "), actual)
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if number, isNumber := value.(float64); isNumber && number < minimum {
+			return fmt.Errorf("%s: %v is less than the minimum of %v", path, number, minimum)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, present := value[fmt.Sprint(name)]; !present {
+					return fmt.Errorf("%s: required property %q is missing", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propertySchema, known := properties[name].(map[string]interface{})
+			if !known {
+				switch additional := schema["additionalProperties"].(type) {
+				case bool:
+					if !additional {
+						return fmt.Errorf("%s: property %q is not in the schema", path, name)
+					}
+					continue
+				case map[string]interface{}:
+					propertySchema = additional
+				default:
+					continue
+				}
+			}
+			if err := checkJSONSchema(propertySchema, value[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				if err := checkJSONSchema(itemSchema, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// jsonTypeName returns the JSON Schema type name of a decoded JSON value
+//
+// Parameters:
+//   value - value decoded by encoding/json into an interface{}
+//
+// Returns:
+//   string - object, array, string, integer (a number without a fraction), number, boolean, or null
+func jsonTypeName(value interface{}) string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+/***********************************************************************************************************************************/
+// newRateLimiter creates a token-bucket limiter allowing the given number of requests per second
+//
+// Parameters:
+//   requestsPerSecond - maximum sustained request rate (must be greater than zero)
+//
+// Returns:
+//   *rateLimiter - limiter with one token available immediately
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	return &rateLimiter{rate: requestsPerSecond, tokens: 1, last: time.Now()}
+}
+
+/***********************************************************************************************************************************/
+// Wait blocks until a request may be sent or the context is cancelled
+//
+// Parameters:
+//   ctx - context whose cancellation (e.g., Ctrl-C) abandons the wait
+//
+// Returns:
+//   error - the context's error if it was cancelled before a token became available
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(1, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+/***********************************************************************************************************************************/
+// RoundTrip sends a request through the shared HTTP layer, using an OAuth Bearer token when -oauthconfig is set
+//
+// With OAuth, a 401 response is treated as an expired access token: the token is refreshed and the
+// request is retried once.
+//
+// Parameters:
+//   req - outgoing HTTP request
+//
+// Returns:
+//   *http.Response - response from the underlying transport
+//   error          - context cancellation, ErrRefreshTokenRejected, or any transport error
+func (t *jiraTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.rs.activeOAuth == nil {
+		return t.send(req)
+	}
+
+	token, err := t.rs.activeOAuth.CurrentAccessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.send(withBearerToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	// Discard the 401 response, refresh the access token and retry once
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.rs.writeLog("WARNING", fmt.Sprintf("failed to drain response body: %v", err))
+	}
+	if cerr := resp.Body.Close(); cerr != nil {
+		t.rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	t.rs.writeLog("WARNING", "HTTP 401 from Jira, refreshing OAuth access token and retrying once")
+	t.rs.runHooks.retry(req.URL.String(), 2, 0)
+	token, err = t.rs.activeOAuth.refreshAfterRejection(req.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	retry := withBearerToken(req, token)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.send(retry)
+}
+
+/***********************************************************************************************************************************/
+// withBearerToken returns a copy of a request carrying an OAuth Bearer Authorization header
+//
+// Parameters:
+//   req   - outgoing HTTP request (not modified)
+//   token - OAuth access token
+//
+// Returns:
+//   *http.Request - cloned request with the Authorization header replaced
+func withBearerToken(req *http.Request, token string) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "Bearer "+token)
+	return clone
+}
+
+/***********************************************************************************************************************************/
+// send applies the shared rate limit and records request statistics before sending a request
+//
+// Parameters:
+//   req - outgoing HTTP request
+//
+// Returns:
+//   *http.Response - response from the underlying transport
+//   error          - context cancellation while waiting for the rate limiter, or any transport error
+func (t *jiraTransport) send(req *http.Request) (*http.Response, error) {
+	if t.rs.apiRateLimiter != nil {
+		if err := t.rs.apiRateLimiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	t.rs.apiStats.mu.Lock()
+	now := time.Now()
+	if t.rs.apiStats.count == 0 {
+		t.rs.apiStats.first = now
+	}
+	t.rs.apiStats.count++
+	t.rs.apiStats.last = now
+	t.rs.apiStats.mu.Unlock()
+
+	// Ask for gzip explicitly, so the body is decompressed (and its sizes counted) by countedResponseBody
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body := &countedResponseBody{wire: resp.Body, stats: &t.rs.apiStats,
+		received: &countingReader{reader: resp.Body, counter: &t.rs.apiStats.receivedBytes, stats: &t.rs.apiStats}}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		body.gzipped = true
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+/***********************************************************************************************************************************/
+// Write writes through the writer and adds the bytes written to its count
+//
+// Parameters:
+//   p - bytes to write
+//
+// Returns:
+//   int   - number of bytes written
+//   error - any error from the underlying writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	*c.count += int64(n)
+	return n, err
+}
+
+/***********************************************************************************************************************************/
+// Read reads through the reader and adds the bytes read to its counter
+//
+// Parameters:
+//   p - buffer to read into
+//
+// Returns:
+//   int   - number of bytes read
+//   error - any error from the underlying reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.stats.mu.Lock()
+	*c.counter += int64(n)
+	c.stats.mu.Unlock()
+	return n, err
+}
+
+/***********************************************************************************************************************************/
+// Read reads the decoded response body, starting the gzip decoder on the first call for a gzip-encoded body
+//
+// Parameters:
+//   p - buffer to read into
+//
+// Returns:
+//   int   - number of decoded bytes read
+//   error - any error reading or decompressing the body
+func (b *countedResponseBody) Read(p []byte) (int, error) {
+	if b.decoded == nil {
+		source := b.received
+		if b.gzipped {
+			gzipReader, err := gzip.NewReader(b.received)
+			if err != nil {
+				return 0, fmt.Errorf("failed to decompress gzip response: %w", err)
+			}
+			source = gzipReader
+		}
+		b.decoded = &countingReader{reader: source, counter: &b.stats.decodedBytes, stats: b.stats}
+	}
+	return b.decoded.Read(p)
+}
+
+/***********************************************************************************************************************************/
+// Close closes the response body as received from Jira
+//
+// Returns:
+//   error - any error closing the body
+func (b *countedResponseBody) Close() error {
+	return b.wire.Close()
+}
+
+/***********************************************************************************************************************************/
+// formatByteSize formats a byte count for log messages
+//
+// Parameters:
+//   size - number of bytes
+//
+// Returns:
+//   string - size in B, KB, or MB (e.g., "7.3 KB")
+func formatByteSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/1024/1024)
+	}
+}
+
+/***********************************************************************************************************************************/
+// newJiraClient creates an HTTP client that sends requests through the shared HTTP layer
+//
+// Parameters:
+//   timeout - overall timeout for each request (includes any rate limit wait)
+//
+// Returns:
+//   *http.Client - client using the shared rate-limited transport
+func (rs *runState) newJiraClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: rs.sharedTransport}
+}
+
+/***********************************************************************************************************************************/
+// logAPIRequestStats logs the number of Jira requests made, the effective average request rate, and the response sizes
+//
+// Side effects:
+//   - Writes INFO log messages (nothing is logged if no requests were made)
+func (rs *runState) logAPIRequestStats() {
+	rs.apiStats.mu.Lock()
+	count, first, last := rs.apiStats.count, rs.apiStats.first, rs.apiStats.last
+	receivedBytes, decodedBytes := rs.apiStats.receivedBytes, rs.apiStats.decodedBytes
+	rs.apiStats.mu.Unlock()
+
+	if count == 0 {
+		return
+	}
+	elapsed := last.Sub(first).Seconds()
+	rate := float64(count)
+	if elapsed > 0 {
+		rate = float64(count-1) / elapsed
+	}
+	limit := "unlimited"
+	if rs.apiRateLimiter != nil {
+		limit = strconv.FormatFloat(rs.apiRateLimiter.rate, 'f', -1, 64) + " requests/second"
+	}
+	rs.writeLog("INFO", fmt.Sprintf("API requests: %d in %.2f seconds, average %.2f requests/second (rate limit: %s)",
+		count, elapsed, rate, limit))
+	if receivedBytes < decodedBytes {
+		rs.writeLog("INFO", fmt.Sprintf("API responses: %s received, %s uncompressed (gzip saved %.0f%%)",
+			formatByteSize(receivedBytes), formatByteSize(decodedBytes), 100*(1-float64(receivedBytes)/float64(decodedBytes))))
+	} else if decodedBytes > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("API responses: %s received (Jira did not compress them)", formatByteSize(receivedBytes)))
+	}
+}
+
+/***********************************************************************************************************************************/
+// reloadAuthToken re-reads the token in use through Config.ReloadToken after Jira rejects authentication
+//
+// A rejected request may be caused by an SSO proxy invalidating its session or by the token
+// file being rotated mid-run, so the token is read again before the single retry.
+//
+// Parameters:
+//   currentToken - the Base64 encoded token currently in use
+//
+// Returns:
+//   string - the newly read token, or currentToken if it cannot be re-read
+func (rs *runState) reloadAuthToken(currentToken string) string {
+	// OAuth access tokens are refreshed by the shared transport, so there is nothing to re-read
+	if rs.activeOAuth != nil || rs.reloadToken == nil {
+		return currentToken
+	}
+	token, err := rs.reloadToken(rs.runContext, rs.tokenInstance)
+	if err != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("Failed to re-read the authentication token, retrying with the current token: %v", err))
+		return currentToken
+	}
+	if token == "" {
+		return currentToken
+	}
+	return token
+}
+
+/***********************************************************************************************************************************/
+// useInstance makes a Jira instance's HTTP layer and token current for the requests that follow
+//
+// Parameters:
+//   instances - Jira instances queried by the run
+//   label     - label of the instance to use (the issue's Instance)
+//
+// Returns:
+//   JiraInstance - the matching instance, or the first instance if no label matches
+//
+// Side effects:
+//   - Sets sharedTransport and tokenInstance to the instance's own
+func (rs *runState) useInstance(instances []JiraInstance, label string) JiraInstance {
+	instance := instances[0]
+	for _, candidate := range instances {
+		if candidate.Label == label {
+			instance = candidate
+			break
+		}
+	}
+	if instance.transport != nil {
+		rs.sharedTransport = instance.transport
+	}
+	rs.tokenInstance = instance
+	return instance
+}
+
+/***********************************************************************************************************************************/
+// IsCloudURL reports whether a Jira base URL points at Atlassian Cloud
+//
+// Parameters:
+//   jiraBaseURL - Jira base URL
+//
+// Returns:
+//   bool - true for *.atlassian.net, *.jira.com, and api.atlassian.com (OAuth) URLs
+func IsCloudURL(jiraBaseURL string) bool {
+	parsedURL, err := url.Parse(jiraBaseURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsedURL.Hostname())
+	return strings.HasSuffix(host, ".atlassian.net") || strings.HasSuffix(host, ".jira.com") || host == "api.atlassian.com"
+}
+
+/***********************************************************************************************************************************/
+// mergeInstanceIssues combines the issues fetched from several Jira instances, keeping one copy of each key
+//
+// When instances return the same issue key (e.g., mid-migration from Server to Cloud), the copy
+// from the preferred instance is kept in place of the other.
+//
+// Parameters:
+//   issuesByInstance - issues fetched from each instance, in instance order
+//   preferredLabel   - label of the instance whose copy wins
+//
+// Returns:
+//   []Issue - merged issues
+//   int     - number of duplicate copies dropped
+func mergeInstanceIssues(issuesByInstance [][]Issue, preferredLabel string) ([]Issue, int) {
+	var merged []Issue
+	keyIndex := make(map[string]int)
+	duplicates := 0
+	for _, issues := range issuesByInstance {
+		for _, issue := range issues {
+			idx, exists := keyIndex[issue.Key]
+			if !exists {
+				keyIndex[issue.Key] = len(merged)
+				merged = append(merged, issue)
+				continue
+			}
+			duplicates++
+			if issue.Instance == preferredLabel {
+				merged[idx] = issue
+			}
+		}
+	}
+	return merged, duplicates
+}
+
+/***********************************************************************************************************************************/
+// isAuthFailure reports whether an HTTP status code indicates Jira rejected the request's authentication
+//
+// Parameters:
+//   statusCode - HTTP response status code
+//
+// Returns:
+//   bool - true for 401 Unauthorized and 403 Forbidden
+func isAuthFailure(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
+
+/***********************************************************************************************************************************/
+// LoadOAuthConfig reads and validates an -oauthconfig file
+//
+// Example config file content:
+//   {"client_id": "...", "client_secret": "...", "refresh_token": "...",
+//    "token_endpoint": "https://auth.atlassian.com/oauth/token", "cloud_id": "..."}
+//
+// Parameters:
+//   configPath - path to the JSON configuration file
+//   logMessage - receives the session's messages, such as a rotated refresh token being saved (nil discards them)
+//
+// Returns:
+//   *OAuthSession - session with no access token yet (call CurrentAccessToken to obtain one)
+//   error         - any error reading or validating the file (secrets are never included)
+func LoadOAuthConfig(configPath string, logMessage func(level, message string)) (*OAuthSession, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OAuth config file: %w", err)
+	}
+
+	var config OAuthConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse OAuth config file %s: %w", configPath, err)
+	}
+
+	var missing []string
+	if config.ClientID == "" {
+		missing = append(missing, "client_id")
+	}
+	if config.ClientSecret == "" {
+		missing = append(missing, "client_secret")
+	}
+	if config.RefreshToken == "" {
+		missing = append(missing, "refresh_token")
+	}
+	if config.TokenEndpoint == "" {
+		missing = append(missing, "token_endpoint")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("OAuth config file %s is missing: %s", configPath, strings.Join(missing, ", "))
+	}
+
+	return &OAuthSession{configPath: configPath, Config: config, logMessage: logMessage}, nil
+}
+
+/***********************************************************************************************************************************/
+// saveOAuthConfig rewrites the -oauthconfig file, replacing it atomically so a failed write never loses the refresh token
+//
+// Parameters:
+//   configPath - path to the JSON configuration file
+//   config     - configuration to write
+//
+// Returns:
+//   error - any error writing or replacing the file
+func saveOAuthConfig(configPath string, config OAuthConfig) error {
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OAuth config: %w", err)
+	}
+
+	tempPath := configPath + ".tmp"
+	if err := os.WriteFile(tempPath, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write OAuth config file: %w", err)
+	}
+	if err := os.Rename(tempPath, configPath); err != nil {
+		if rerr := os.Remove(tempPath); rerr != nil {
+			return fmt.Errorf("failed to replace OAuth config file: %w (and failed to remove the temporary file: %v)", err, rerr)
+		}
+		return fmt.Errorf("failed to replace OAuth config file: %w", err)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// log passes a message to the session's logMessage callback if it has one
+//
+// Parameters:
+//   level   - log level ("INFO", "WARNING", "ERROR")
+//   message - message to log
+func (s *OAuthSession) log(level, message string) {
+	if s.logMessage != nil {
+		s.logMessage(level, message)
+	}
+}
+
+/***********************************************************************************************************************************/
+// refresh exchanges the refresh token for a new access token (caller must hold s.mu)
+//
+// The token endpoint's response is never logged, and errors only carry its error code and
+// description, so secrets cannot leak into logs or the problems file.
+//
+// Parameters:
+//   ctx - context for cancellation
+//
+// Returns:
+//   error - ErrRefreshTokenRejected if the endpoint refuses the refresh token, or any other request error
+//
+// Side effects:
+//   - Rewrites the -oauthconfig file when the server rotates the refresh token
+func (s *OAuthSession) refresh(ctx context.Context) error {
+	payload, err := json.Marshal(map[string]string{
+		"grant_type":    "refresh_token",
+		"client_id":     s.Config.ClientID,
+		"client_secret": s.Config.ClientSecret,
+		"refresh_token": s.Config.RefreshToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode OAuth token request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Config.TokenEndpoint, strings.NewReader(string(payload)))
+	if err != nil {
+		return fmt.Errorf("failed to create OAuth token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	// The token endpoint is not Jira, so it bypasses the shared Jira transport
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact OAuth token endpoint: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		s.log("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read OAuth token response: %w", err)
+	}
+
+	var tokenResponse oauthTokenResponse
+	parseErr := json.Unmarshal(body, &tokenResponse)
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w (HTTP %d %s %s): re-authorise the app and update refresh_token in %s",
+			ErrRefreshTokenRejected, resp.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription, s.configPath)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OAuth token endpoint returned HTTP %d", resp.StatusCode)
+	}
+	if parseErr != nil {
+		return fmt.Errorf("failed to parse OAuth token response: %w", parseErr)
+	}
+	if tokenResponse.AccessToken == "" {
+		return fmt.Errorf("OAuth token response did not contain an access token")
+	}
+
+	s.accessToken = tokenResponse.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	s.log("INFO", fmt.Sprintf("Obtained OAuth access token (expires in %d seconds)", tokenResponse.ExpiresIn))
+
+	// Persist a rotated refresh token, as the previous one is no longer valid
+	if tokenResponse.RefreshToken != "" && tokenResponse.RefreshToken != s.Config.RefreshToken {
+		s.Config.RefreshToken = tokenResponse.RefreshToken
+		if err := saveOAuthConfig(s.configPath, s.Config); err != nil {
+			s.log("ERROR", fmt.Sprintf("Refresh token was rotated but could not be saved, the next run will need re-authorisation: %v", err))
+		} else {
+			s.log("INFO", fmt.Sprintf("Saved rotated refresh token to %s", s.configPath))
+		}
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// CurrentAccessToken returns a valid access token, refreshing it first if it is missing or about to expire
+//
+// Parameters:
+//   ctx - context for cancellation
+//
+// Returns:
+//   string - OAuth access token
+//   error  - any error refreshing the token
+func (s *OAuthSession) CurrentAccessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Refresh a minute early so a token never expires mid-request
+	if s.accessToken == "" || time.Now().Add(time.Minute).After(s.expiresAt) {
+		if err := s.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return s.accessToken, nil
+}
+
+/***********************************************************************************************************************************/
+// refreshAfterRejection refreshes the access token after Jira rejected it with 401
+//
+// If another request has already refreshed the token since rejectedToken was issued, the
+// newer token is returned without refreshing again.
+//
+// Parameters:
+//   ctx           - context for cancellation
+//   rejectedToken - access token Jira rejected
+//
+// Returns:
+//   string - OAuth access token to retry with
+//   error  - any error refreshing the token
+func (s *OAuthSession) refreshAfterRejection(ctx context.Context, rejectedToken string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken == rejectedToken {
+		if err := s.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return s.accessToken, nil
+}
+
+/***********************************************************************************************************************************/
+// DescribeLocation formats a timezone with its current abbreviation and UTC offset for logging
+//
+// Parameters:
+//   location - timezone to describe
+//
+// Returns:
+//   string - description such as "Australia/Sydney (AEST, UTC+10:00)"
+func DescribeLocation(location *time.Location) string {
+	abbreviation, offsetSeconds := time.Now().In(location).Zone()
+	sign := "+"
+	if offsetSeconds < 0 {
+		sign = "-"
+		offsetSeconds = -offsetSeconds
+	}
+	return fmt.Sprintf("%s (%s, UTC%s%02d:%02d)", location.String(), abbreviation, sign, offsetSeconds/3600, (offsetSeconds%3600)/60)
+}
+
+/***********************************************************************************************************************************/
+// validateProject checks if a Jira project exists and is accessible
+//
+// This function makes an HTTP GET request to the project endpoint to verify
+// that the project exists and the user has permission to view it. A project validated on
+// the same Jira instance within projectCacheTTL is taken from the project cache instead,
+// unless -refreshcache was given.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   projectKey  - project key to validate
+//
+// Returns:
+//   error - any error if project doesn't exist or isn't accessible, nil if valid
+//
+// Side effects:
+//   - Makes HTTP request to Jira API (unless the project cache holds a recent entry)
+//   - Writes log messages about validation results
+//   - Records the validated project in the project cache
+func (rs *runState) validateProject(jiraBaseURL, authToken, projectKey string) error {
+	// Skip the request if this project was validated on this instance recently
+	if entry, ok := rs.lookupProjectCache(jiraBaseURL, projectKey); ok {
+		rs.writeLog("INFO", fmt.Sprintf("Project '%s' found: %s (validated %s ago, cached)", projectKey, entry.Name,
+			time.Since(entry.ValidatedAt).Round(time.Minute)))
+		return nil
+	}
+
+	// Build project validation URL
+	projectURL := fmt.Sprintf("%s/rest/api/2/project/%s", jiraBaseURL, projectKey)
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", projectURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create project validation request: %w", err)
+	}
+
+	// Set authentication header
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	// Make HTTP request
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to validate project: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	// Check response status
+	if resp.StatusCode == 404 {
+		return fmt.Errorf("project '%s' does not exist (HTTP 404 Not Found)", projectKey)
+	} else if resp.StatusCode != 200 {
+		return fmt.Errorf("failed to validate project '%s' (HTTP %d)", projectKey, resp.StatusCode)
+	}
+
+	// Parse response to validate project data
+	var projectInfo ProjectInfo
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read project validation response: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &projectInfo); err != nil {
+		return fmt.Errorf("failed to parse project validation response: %w", err)
+	}
+
+	// Validate that the project key matches
+	if projectInfo.Key != projectKey {
+		return fmt.Errorf("project key mismatch: expected '%s', got '%s'", projectKey, projectInfo.Key)
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Project '%s' found: %s", projectKey, projectInfo.Name))
+	rs.storeProjectCache(jiraBaseURL, projectKey, projectInfo.Name)
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// projectCachePath returns the path of the project cache file
+//
+// Returns:
+//   string - path under the user cache directory
+//   error  - the user cache directory could not be determined
+func projectCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, projectCacheDirName, projectCacheFileName), nil
+}
+
+/***********************************************************************************************************************************/
+// projectCacheKey identifies a project in the project cache, so the same key on Server and Cloud never collide
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   projectKey  - project key
+//
+// Returns:
+//   string - cache key
+func projectCacheKey(jiraBaseURL, projectKey string) string {
+	return strings.ToLower(jiraBaseURL) + "|" + projectKey
+}
+
+/***********************************************************************************************************************************/
+// readProjectCache reads the project cache file
+//
+// A missing, unreadable, or corrupt cache is treated as empty so it can never fail a run.
+//
+// Returns:
+//   projectCache - cached projects (empty if the file could not be used)
+func (rs *runState) readProjectCache() projectCache {
+	cache := projectCache{Projects: make(map[string]projectCacheEntry)}
+	path, err := projectCachePath()
+	if err != nil {
+		return cache
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) && rs.debug {
+			rs.writeLog("DEBUG", fmt.Sprintf("Project cache %s could not be read, ignoring it: %v", path, err))
+		}
+		return cache
+	}
+	if err := json.Unmarshal(content, &cache); err != nil || cache.Projects == nil {
+		if rs.debug {
+			rs.writeLog("DEBUG", fmt.Sprintf("Project cache %s is corrupt, ignoring it", path))
+		}
+		return projectCache{Projects: make(map[string]projectCacheEntry)}
+	}
+	return cache
+}
+
+/***********************************************************************************************************************************/
+// lookupProjectCache finds a recently validated project in the project cache
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   projectKey  - project key
+//
+// Returns:
+//   projectCacheEntry - the cached project
+//   bool              - true if an entry younger than projectCacheTTL was found (always false with -refreshcache)
+func (rs *runState) lookupProjectCache(jiraBaseURL, projectKey string) (projectCacheEntry, bool) {
+	if rs.projectCacheTTL <= 0 || rs.refreshProjectCache {
+		return projectCacheEntry{}, false
+	}
+	entry, ok := rs.readProjectCache().Projects[projectCacheKey(jiraBaseURL, projectKey)]
+	age := time.Since(entry.ValidatedAt)
+	if !ok || age < 0 || age > rs.projectCacheTTL {
+		if rs.debug {
+			rs.writeLog("DEBUG", fmt.Sprintf("Project cache miss for %s on %s", projectKey, jiraBaseURL))
+		}
+		return projectCacheEntry{}, false
+	}
+	if rs.debug {
+		rs.writeLog("DEBUG", fmt.Sprintf("Project cache hit for %s on %s (validated %s)", projectKey, jiraBaseURL, entry.ValidatedAt.Format(time.RFC3339)))
+	}
+	return entry, true
+}
+
+/***********************************************************************************************************************************/
+// storeProjectCache records a validated project in the project cache
+//
+// Failures are only logged at DEBUG, as the cache is an optimisation. The file is replaced
+// atomically so a concurrent run never reads a half-written cache.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   projectKey  - project key
+//   projectName - project name reported by Jira
+func (rs *runState) storeProjectCache(jiraBaseURL, projectKey, projectName string) {
+	if rs.projectCacheTTL <= 0 {
+		return
+	}
+	path, err := projectCachePath()
+	if err != nil {
+		return
+	}
+
+	cache := rs.readProjectCache()
+	cache.Projects[projectCacheKey(jiraBaseURL, projectKey)] = projectCacheEntry{Name: projectName, ValidatedAt: time.Now(), JiraHost: jiraBaseURL}
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	tempPath := path + ".tmp"
+	if err == nil {
+		err = os.WriteFile(tempPath, append(content, '\n'), 0644)
+	}
+	if err == nil {
+		if err = os.Rename(tempPath, path); err != nil {
+			_ = os.Remove(tempPath)
+		}
+	}
+	if err != nil && rs.debug {
+		rs.writeLog("DEBUG", fmt.Sprintf("Failed to update project cache %s: %v", path, err))
+	}
+}
+
+/***********************************************************************************************************************************/
+// quoteJQLValue quotes a value for use in a JQL query, escaping backslashes and double quotes
+//
+// Parameters:
+//   value - raw value (e.g., a fix version name such as 3.2 "Hotfix")
+//
+// Returns:
+//   string - double-quoted JQL string literal
+func quoteJQLValue(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	return `"` + escaped + `"`
+}
+
+/***********************************************************************************************************************************/
+// buildJQLQuery constructs a JQL (Jira Query Language) query string for retrieving spillover issues
+//
+// This function creates a properly formatted JQL query to filter issues based on:
+// - Project key (required)
+// - Issue types (excludes Epic, Risk, Sub Task; Epics are kept when -includeepics is set)
+// - Sprint field is not empty (only issues that have been in sprints)
+// - Date range (based on days prior) on the -datefield: updated, statusCategoryChangedDate, or resolved
+// - Fix versions (only when -fixversion is supplied)
+// - Not updated in the date range by the -excludeupdatedby users (only when supplied)
+// - Ordered by the -orderby field, then issue key, so repeated runs return issues in the same order
+//
+// Parameters:
+//   projectKey  - the Jira project key to filter by (e.g., "PROJ", "TEAM")
+//   daysPrior   - number of days to look back
+//   dateField   - JQL date field the range applies to (updated, statusCategoryChangedDate, or resolved)
+//   withEpics   - if true, Epics are not excluded from the issue types
+//   fixVersions - fix version names to restrict to (nil for no restriction)
+//   openOnly    - if true, issues whose status is in the Done category are excluded
+//   orderBy     - sort field: key, updated, created, priority, or score (calculated after fetching, so the JQL orders by key)
+//   excludeUpdatedBy - users whose updates in the date range exclude an issue (nil for no restriction); the
+//                      instance must support the updatedBy() JQL function
+//
+// Returns:
+//   string - complete JQL query ready for use with Jira REST API
+func buildJQLQuery(projectKey string, daysPrior int, dateField string, withEpics bool, fixVersions []string, openOnly bool, orderBy string, excludeUpdatedBy []string) string {
+	// Build JQL query to find spillover candidates
+	// Excludes Epics (unless requested), Risks, and Sub Tasks
+	// Only includes issues with Sprint field populated
+	// Only includes issues whose date field falls within the specified time frame
+	excludedTypes := "Epic, Risk, 'Sub-Task'"
+	if withEpics {
+		excludedTypes = "Risk, 'Sub-Task'"
+	}
+	if dateField == "" {
+		dateField = "updated"
+	}
+	jqlQuery := fmt.Sprintf("project = %s AND issuetype not in (%s) AND Sprint is not EMPTY AND %s >= -%dd",
+		projectKey, excludedTypes, jqlFieldName(dateField), daysPrior)
+	if len(fixVersions) > 0 {
+		quotedVersions := make([]string, 0, len(fixVersions))
+		for _, version := range fixVersions {
+			quotedVersions = append(quotedVersions, quoteJQLValue(version))
+		}
+		jqlQuery += fmt.Sprintf(" AND fixVersion in (%s)", strings.Join(quotedVersions, ", "))
+	}
+	if openOnly {
+		jqlQuery += " AND statusCategory != Done"
+	}
+	for _, user := range excludeUpdatedBy {
+		jqlQuery += fmt.Sprintf(" AND issue not in updatedBy(%s, \"-%dd\")", quoteJQLValue(user), daysPrior)
+	}
+	if orderBy == "" || orderBy == "key" || orderBy == "score" {
+		jqlQuery += " ORDER BY key ASC"
+	} else {
+		jqlQuery += fmt.Sprintf(" ORDER BY %s ASC, key ASC", orderBy)
+	}
+	return jqlQuery
+}
+
+/***********************************************************************************************************************************/
+// NormalizeDateField returns the canonical spelling of a -datefield choice
+//
+// Parameters:
+//   name - field name as typed, matched case-insensitively ("" selects updated)
+//
+// Returns:
+//   string - updated, statusCategoryChangedDate, or resolved
+//   bool   - false if name is not a supported date field
+func NormalizeDateField(name string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "updated":
+		return "updated", true
+	case "statuscategorychangeddate":
+		return "statusCategoryChangedDate", true
+	case "resolved":
+		return "resolved", true
+	}
+	return "", false
+}
+
+/***********************************************************************************************************************************/
+// jqlFieldName formats a field name for use on the left of a JQL clause
+//
+// Single-word names are used as is; names containing spaces or JQL punctuation are double quoted.
+//
+// Parameters:
+//   name - Jira field name
+//
+// Returns:
+//   string - the field name, quoted if needed
+func jqlFieldName(name string) string {
+	if strings.ContainsAny(name, " \t\"'=!<>~(),") {
+		return quoteJQLValue(name)
+	}
+	return name
+}
+
+/***********************************************************************************************************************************/
+// buildKeysJQLQueries builds the JQL queries for an explicit set of issue keys (-keysfile)
+//
+// Keys are split into chunks of at most keysPerQuery so each query stays within Jira's URL and
+// clause limits. No project, issue type, sprint, or date restrictions are applied: the keys are
+// the issue set.
+//
+// Parameters:
+//   issueKeys - issue keys to fetch
+//   orderBy   - sort field: key, updated, created, priority, or score (the JQL orders by key for score)
+//
+// Returns:
+//   [][]string - the keys in each chunk
+//   []string   - the JQL query for each chunk
+func (rs *runState) buildKeysJQLQueries(issueKeys []string, orderBy string) ([][]string, []string) {
+	orderClause := " ORDER BY key ASC"
+	if orderBy != "" && orderBy != "key" && orderBy != "score" {
+		orderClause = fmt.Sprintf(" ORDER BY %s ASC, key ASC", orderBy)
+	}
+
+	var chunks [][]string
+	var queries []string
+	for start := 0; start < len(issueKeys); start += keysPerQuery {
+		end := start + keysPerQuery
+		if end > len(issueKeys) {
+			end = len(issueKeys)
+		}
+		chunk := issueKeys[start:end]
+		chunks = append(chunks, chunk)
+		queries = append(queries, fmt.Sprintf("key in (%s)%s", strings.Join(chunk, ", "), orderClause))
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Using %d key in (...) JQL queries for %d issue keys", len(queries), len(issueKeys)))
+	return chunks, queries
+}
+
+/***********************************************************************************************************************************/
+// fetchIssuesByKeys retrieves an explicit set of issues chunk by chunk (-keysfile)
+//
+// Jira rejects a whole key in (...) query when any key in it does not exist (or is not visible to
+// the user), so a chunk that fails is retried one key at a time and the keys Jira cannot find are
+// reported rather than failing the run.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   chunks      - issue keys in each chunk, as returned by buildKeysJQLQueries
+//   queries     - JQL query for each chunk
+//   fields      - comma-separated list of fields to retrieve
+//
+// Returns:
+//   []Issue  - issues found, in chunk order
+//   []string - keys Jira could not find
+//   error    - any error other than a missing key
+//
+// Side effects:
+//   - Makes one search request per chunk, plus one request per key in a chunk that failed
+func (rs *runState) fetchIssuesByKeys(jiraBaseURL, authToken string, chunks [][]string, queries []string, fields string) ([]Issue, []string, error) {
+	var allIssues []Issue
+	var missingKeys []string
+
+	for i, query := range queries {
+		issues, err := rs.fetchAllJiraIssues(jiraBaseURL, authToken, query, fields)
+		if err == nil {
+			allIssues = append(allIssues, issues...)
+			continue
+		}
+		if errors.Is(err, errAuthRejected) || rs.runContext.Err() != nil {
+			return nil, nil, err
+		}
+
+		rs.writeLog("WARNING", fmt.Sprintf("Key chunk %d of %d failed (%v), fetching its %d keys individually",
+			i+1, len(queries), err, len(chunks[i])))
+		for _, issueKey := range chunks[i] {
+			body, err := rs.fetchRawIssue(jiraBaseURL, authToken, issueKey, fields)
+			if errors.Is(err, errIssueNotFound) {
+				rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Issue %s does not exist or is not visible to you", issueKey))
+				missingKeys = append(missingKeys, issueKey)
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			var issue Issue
+			if err := json.Unmarshal(body, &issue); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse issue %s: %w", issueKey, err)
+			}
+			allIssues = append(allIssues, issue)
+		}
+	}
+
+	return allIssues, missingKeys, nil
+}
+
+/***********************************************************************************************************************************/
+// batchFetched calls OnBatchFetched if it is set
+//
+// Parameters:
+//   fetched - issues fetched so far by the query
+//   total   - issues matching the query
+func (h Hooks) batchFetched(fetched, total int) {
+	if h.OnBatchFetched != nil {
+		h.OnBatchFetched(fetched, total)
+	}
+}
+
+/***********************************************************************************************************************************/
+// epicLookup calls OnEpicLookup if it is set
+//
+// Parameters:
+//   done  - epic summary lookups completed
+//   total - epic summaries to look up
+func (h Hooks) epicLookup(done, total int) {
+	if h.OnEpicLookup != nil {
+		h.OnEpicLookup(done, total)
+	}
+}
+
+/***********************************************************************************************************************************/
+// issueProcessed calls OnIssueProcessed if it is set
+//
+// Parameters:
+//   done  - fetched issues checked for spillover so far
+//   total - fetched issues to check
+func (h Hooks) issueProcessed(done, total int) {
+	if h.OnIssueProcessed != nil {
+		h.OnIssueProcessed(done, total)
+	}
+}
+
+/***********************************************************************************************************************************/
+// retry calls OnRetry if it is set
+//
+// Parameters:
+//   url     - URL of the request being retried
+//   attempt - number of the attempt about to be made (2 for the first retry)
+//   wait    - time waited before the attempt (0 if it is made at once)
+func (h Hooks) retry(url string, attempt int, wait time.Duration) {
+	if h.OnRetry != nil {
+		h.OnRetry(url, attempt, wait)
+	}
+}
+
+/***********************************************************************************************************************************/
+// phase calls OnPhase if it is set
+//
+// Parameters:
+//   name - phase the run is starting
+func (h Hooks) phase(name string) {
+	if h.OnPhase != nil {
+		h.OnPhase(name)
+	}
+}
+
+/***********************************************************************************************************************************/
+// log calls OnLog if it is set
+//
+// Parameters:
+//   level      - log level ("DEBUG", "INFO", "WARNING", "ERROR")
+//   logContext - structured fields for the message (zero value for none)
+//   message    - message to log
+func (h Hooks) log(level string, logContext LogContext, message string) {
+	if h.OnLog != nil {
+		h.OnLog(level, logContext, message)
+	}
+}
+
+/***********************************************************************************************************************************/
+// issueDebug calls OnIssueDebug if it is set
+//
+// Parameters:
+//   issueKey - key of the issue the dump describes
+//   message  - the dump, which may run to several lines
+func (h Hooks) issueDebug(issueKey, message string) {
+	if h.OnIssueDebug != nil {
+		h.OnIssueDebug(issueKey, message)
+	}
+}
+
+/***********************************************************************************************************************************/
+// waitForRetryAfter waits before retrying a request Jira rejected with HTTP 429
+//
+// Parameters:
+//   resp - the 429 response; its Retry-After header (in seconds) sets the wait, rateLimitedRetryDelay if absent
+//
+// Returns:
+//   error - context cancellation while waiting
+func (rs *runState) waitForRetryAfter(resp *http.Response) error {
+	delay := rateLimitedRetryDelay
+	if seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Waiting %.0fs before retrying as Jira is rate limiting requests", delay.Seconds()))
+	if resp.Request != nil {
+		rs.runHooks.retry(resp.Request.URL.String(), 2, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-rs.runContext.Done():
+		return rs.runContext.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+/***********************************************************************************************************************************/
+// newSearchRequest builds a /rest/api/2/search request for one page of a JQL query
+//
+// Searches are sent as POST with the query in a JSON body, so long JQL (multi-project lists, exclusions,
+// key in (...) chunks) cannot hit proxy or Jira URL length limits. With -searchget the same parameters
+// are sent as a GET query string instead, for old instances that reject the POST variant.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   jqlQuery    - JQL query string
+//   startAt     - index of the first issue to return
+//   maxResults  - maximum number of issues to return (0 returns only the total)
+//   fields      - comma-separated list of fields to retrieve, or empty for Jira's default
+//
+// Returns:
+//   *http.Request - request ready to send, with its body replayable for retries
+//   error         - any error creating the request
+func (rs *runState) newSearchRequest(jiraBaseURL, authToken, jqlQuery string, startAt, maxResults int, fields string) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if rs.searchUsesGET {
+		requestURL := fmt.Sprintf("%s/rest/api/2/search?jql=%s&startAt=%d&maxResults=%d",
+			jiraBaseURL, url.QueryEscape(jqlQuery), startAt, maxResults)
+		if fields != "" {
+			requestURL += "&fields=" + url.QueryEscape(fields)
+		}
+		req, err = http.NewRequestWithContext(rs.runContext, "GET", requestURL, nil)
+	} else {
+		searchBody := searchRequest{JQL: jqlQuery, StartAt: startAt, MaxResults: maxResults}
+		if fields != "" {
+			searchBody.Fields = strings.Split(fields, ",")
+		}
+		payload, marshalErr := json.Marshal(searchBody)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to encode search request: %w", marshalErr)
+		}
+		req, err = http.NewRequestWithContext(rs.runContext, "POST", jiraBaseURL+"/rest/api/2/search", bytes.NewReader(payload))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+/***********************************************************************************************************************************/
+// searchMethodHint explains a search response that points at the wrong request method or a URL that is too long
+//
+// Parameters:
+//   statusCode - HTTP status code of the search response
+//
+// Returns:
+//   string - suggestion to append to the error message, or empty string if none applies
+func (rs *runState) searchMethodHint(statusCode int) string {
+	switch {
+	case !rs.searchUsesGET && statusCode == http.StatusMethodNotAllowed:
+		return " (this Jira does not accept POST searches, retry with -searchget)"
+	case rs.searchUsesGET && (statusCode == http.StatusRequestURITooLong || statusCode == http.StatusRequestEntityTooLarge):
+		return " (the JQL is too long for a GET request, retry without -searchget)"
+	}
+	return ""
+}
+
+/***********************************************************************************************************************************/
+// fetchAllJiraIssues retrieves all issues matching the JQL query using pagination
+//
+// This function handles Jira's REST API pagination limits by making multiple requests
+// to fetch all matching issues. Jira typically limits responses to 50-100 issues per
+// request for performance reasons, so this function automatically handles batching.
+//
+// Unless -fixedbatch is set, the page size adapts: it is halved (down to minBatchSize) and the page
+// retried after a timeout or HTTP 429, and grown back towards -batchsize after batchGrowthStreak
+// consecutive pages answered within fastBatchLatency. startAt advances by the issues actually returned.
+// A page rejected with HTTP 429 that cannot shrink (or with -fixedbatch) is retried once at the same size
+// after the wait Jira asks for.
+//
+// With -skipfailedpages, a page that fails with a server error, network error, or unreadable response is
+// retried once and then skipped: the gap is logged and counted and the following pages are still fetched.
+// The first page cannot be skipped, as the number of matching issues is not yet known.
+//
+// With -sample, pages are only requested until sampleSize issues have been fetched.
+//
+// Each page is requested by newSearchRequest (POST, or GET with -searchget).
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   jqlQuery    - JQL query string to execute
+//   fields      - comma-separated list of fields to retrieve
+//
+// Returns:
+//   []Issue - slice containing all matching issues from all paginated requests
+//   error   - any error encountered during fetching
+//
+// Side effects:
+//   - Makes multiple HTTP requests to Jira REST API
+//   - Prints progress messages to console for large result sets
+//   - Writes detailed log messages about fetch progress and completion
+func (rs *runState) fetchAllJiraIssues(jiraBaseURL, authToken, jqlQuery, fields string) ([]Issue, error) {
+	var allIssues []Issue
+	startAt := 0
+	batchCount := 0
+	totalBodyBytes := 0
+	pageSize := rs.searchBatchSize
+	fastStreak := 0
+	knownTotal := -1
+	pageRetried := false
+	rateLimitRetried := false
+
+	// shrinkPageSize halves the page size after a slow or rejected request, returning false if it cannot shrink further
+	shrinkPageSize := func(reason string) bool {
+		floor := min(minBatchSize, rs.searchBatchSize)
+		if rs.fixedBatchSize || pageSize <= floor {
+			return false
+		}
+		newSize := max(pageSize/2, floor)
+		rs.writeLogWithContext("WARNING", LogContext{Batch: batchCount}, fmt.Sprintf("Reducing batch size from %d to %d: %s", pageSize, newSize, reason))
+		pageSize = newSize
+		fastStreak = 0
+		return true
+	}
+
+	// skipFailedPage retries a failed page once, then skips it (-skipfailedpages), returning false if the run must fail instead
+	skipFailedPage := func(pageErr error, body []byte) bool {
+		if !rs.skipFailedPages || knownTotal < 0 || errors.Is(pageErr, context.Canceled) {
+			return false
+		}
+		if !pageRetried {
+			pageRetried = true
+			rs.writeLogWithContext("WARNING", LogContext{Batch: batchCount}, fmt.Sprintf("Batch %d starting at record %d failed, retrying once: %v", batchCount, startAt, pageErr))
+			rs.runHooks.retry(jiraBaseURL+"/rest/api/2/search", 2, 0)
+			return true
+		}
+		skippedIssues := min(pageSize, knownTotal-startAt)
+		rs.writeLogWithContext("ERROR", LogContext{Batch: batchCount}, fmt.Sprintf("Skipping batch %d starting at record %d (about %d issues) after retry: %v; response body: %s",
+			batchCount, startAt, skippedIssues, pageErr, string(body)))
+		rs.skippedPageCount++
+		rs.skippedIssueCount += skippedIssues
+		rs.runStats.SkippedPages++
+		rs.runStats.SkippedIssues += skippedIssues
+		startAt += pageSize
+		pageRetried = false
+		return true
+	}
+
+fetchLoop:
+	for {
+		// A skipped page may have been the last one
+		if knownTotal >= 0 && startAt >= knownTotal {
+			break
+		}
+		batchCount++
+		rs.runStats.BatchesFetched++
+		rs.writeLogWithContext("INFO", LogContext{Batch: batchCount}, fmt.Sprintf("Fetching batch %d, starting at record %d...", batchCount, startAt))
+
+		// Page size, asking for no more than the rest of the sample (-sample)
+		requestSize := pageSize
+		if rs.sampleSize > 0 {
+			requestSize = min(pageSize, rs.sampleSize-len(allIssues))
+		}
+
+		// Make HTTP request, retrying once on 401/403 in case the session was invalidated or the token rotated
+		client := rs.newJiraClient(60 * time.Second)
+		var resp *http.Response
+		var body []byte
+		requestStart := time.Now()
+		for authAttempt := 1; ; authAttempt++ {
+			// Create HTTP request
+			req, err := rs.newSearchRequest(jiraBaseURL, authToken, jqlQuery, startAt, requestSize, fields)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create request for batch %d: %w", batchCount, err)
+			}
+
+			resp, err = client.Do(req)
+			var urlErr *url.Error
+			if err != nil && errors.As(err, &urlErr) && urlErr.Timeout() &&
+				shrinkPageSize(fmt.Sprintf("request timed out after %.1fs", time.Since(requestStart).Seconds())) {
+				continue fetchLoop
+			}
+			if err != nil {
+				pageErr := fmt.Errorf("failed to fetch batch %d: %w", batchCount, err)
+				if skipFailedPage(pageErr, nil) {
+					continue fetchLoop
+				}
+				return nil, pageErr
+			}
+
+			// Read response body
+			body, err = io.ReadAll(resp.Body)
+			if cerr := resp.Body.Close(); cerr != nil {
+				rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+			}
+			if err != nil {
+				pageErr := fmt.Errorf("failed to read response body for batch %d: %w", batchCount, err)
+				if skipFailedPage(pageErr, body) {
+					continue fetchLoop
+				}
+				return nil, pageErr
+			}
+
+			// Jira is rate limiting: wait as asked, then retry the same records with a smaller page, or once
+			// more at the same size when the page cannot shrink any further
+			if resp.StatusCode == http.StatusTooManyRequests &&
+				(shrinkPageSize(fmt.Sprintf("HTTP 429 after %.1fs", time.Since(requestStart).Seconds())) || !rateLimitRetried) {
+				rateLimitRetried = true
+				if err := rs.waitForRetryAfter(resp); err != nil {
+					return nil, err
+				}
+				continue fetchLoop
+			}
+
+			if !isAuthFailure(resp.StatusCode) || authAttempt > 1 {
+				break
+			}
+			rs.writeLogWithContext("WARNING", LogContext{Batch: batchCount}, fmt.Sprintf("HTTP %d in batch %d, re-reading token and retrying once", resp.StatusCode, batchCount))
+			rs.runHooks.retry(jiraBaseURL+"/rest/api/2/search", authAttempt+1, 0)
+			authToken = rs.reloadAuthToken(authToken)
+		}
+
+		// Fail the run if authentication was still rejected after the retry
+		if isAuthFailure(resp.StatusCode) {
+			return nil, fmt.Errorf("%w: HTTP %d in batch %d after retry (%d issues fetched so far)",
+				errAuthRejected, resp.StatusCode, batchCount, len(allIssues))
+		}
+
+		// Check HTTP status (only server errors may be skipped; other failures would repeat on every page)
+		if resp.StatusCode >= 500 && skipFailedPage(fmt.Errorf("HTTP %d", resp.StatusCode), body) {
+			continue
+		}
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("HTTP %d error in batch %d%s: %s", resp.StatusCode, batchCount, rs.searchMethodHint(resp.StatusCode), string(body))
+		}
+
+		totalBodyBytes += len(body)
+		latency := time.Since(requestStart)
+
+		// Parse JSON response
+		var searchResponse SearchResponse
+		if err := json.Unmarshal(body, &searchResponse); err != nil {
+			pageErr := fmt.Errorf("failed to parse JSON response for batch %d: %w", batchCount, err)
+			if skipFailedPage(pageErr, body) {
+				continue
+			}
+			return nil, pageErr
+		}
+		pageRetried = false
+		rateLimitRetried = false
+
+		if startAt == 0 {
+			rs.runStats.JQLTotal += searchResponse.Total
+			knownTotal = searchResponse.Total
+		}
+
+		// Add issues to collection
+		allIssues = append(allIssues, searchResponse.Issues...)
+		rs.runHooks.batchFetched(len(allIssues), searchResponse.Total)
+
+		// Move to the next batch by the issues actually returned, as Jira may return fewer than requested
+		startAt += len(searchResponse.Issues)
+		if len(searchResponse.Issues) == 0 || startAt >= searchResponse.Total {
+			break
+		}
+		if rs.sampleSize > 0 && len(allIssues) >= rs.sampleSize {
+			allIssues = allIssues[:rs.sampleSize]
+			rs.sampleLimited = true
+			rs.writeLog("INFO", fmt.Sprintf("Stopping after %d of %d matching issues (-sample %d)", len(allIssues), knownTotal, rs.sampleSize))
+			break
+		}
+
+		// Grow the page size back after several quick responses
+		if rs.fixedBatchSize || pageSize >= rs.searchBatchSize || latency >= fastBatchLatency {
+			fastStreak = 0
+			continue
+		}
+		fastStreak++
+		if fastStreak >= batchGrowthStreak {
+			newSize := min(pageSize+pageSize/2, rs.searchBatchSize)
+			rs.writeLogWithContext("INFO", LogContext{Batch: batchCount}, fmt.Sprintf("Increasing batch size from %d to %d: last %d batches each took under %.0fs (latest %.1fs, %.1f KB)",
+				pageSize, newSize, fastStreak, fastBatchLatency.Seconds(), latency.Seconds(), float64(len(body))/1024))
+			pageSize = newSize
+			fastStreak = 0
+		}
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Completed fetching %d issues in %d batches", len(allIssues), batchCount))
+	// Show the cost of the requested fields (e.g., -includedescription) in response size
+	averageIssueBytes := 0
+	if len(allIssues) > 0 {
+		averageIssueBytes = totalBodyBytes / len(allIssues)
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Search responses averaged %.1f KB per batch (%d bytes per issue, %.1f KB in total)",
+		float64(totalBodyBytes)/1024/float64(batchCount), averageIssueBytes, float64(totalBodyBytes)/1024))
+	return allIssues, nil
+}
+
+/***********************************************************************************************************************************/
+// parseSprintField extracts sprint information from the Jira sprint field
+//
+// This function parses the sprint field which can contain multiple sprint objects
+// and extracts sprint names and other information. The sprints are put in chronological order
+// (see sprintBefore) and the first and last sprint's names, goal and dates are all taken from that
+// order, as Jira lists an issue's sprints in no particular order.
+//
+// Parameters:
+//   sprintField - the sprint field value from Jira (can be array or null)
+//
+// Returns:
+//   SprintInfo - parsed sprint information including names and counts
+func (rs *runState) parseSprintField(sprintField interface{}) SprintInfo {
+	info := SprintInfo{
+		SprintNames: []string{},
+		Sprints:     []SprintDetail{},
+	}
+
+	if sprintField == nil {
+		return info
+	}
+
+	// Pre-size collections for the common array case to avoid repeated growth
+	sizeHint := 1
+	switch v := sprintField.(type) {
+	case []interface{}:
+		sizeHint = len(v)
+	case []string:
+		sizeHint = len(v)
+	}
+	info.SprintNames = make([]string, 0, sizeHint)
+	info.Sprints = make([]SprintDetail, 0, sizeHint)
+	uniqueSprints := make(map[string]int, sizeHint)
+
+	// addSprint records a sprint the first time its ID (or name, when there is no ID) is seen; a later entry for the
+	// same sprint is the more recent one, so its name is the one shown (a sprint renamed mid-sprint)
+	addSprint := func(detail SprintDetail) {
+		detail.Name = rs.normalizeSprintName(detail.Name)
+		identity := rs.sprintIdentity(detail)
+		idx, seen := uniqueSprints[identity]
+		if !seen {
+			uniqueSprints[identity] = len(info.Sprints)
+			info.Sprints = append(info.Sprints, detail)
+			return
+		}
+		if info.Sprints[idx].Name != detail.Name {
+			if rs.debug {
+				rs.writeLog("DEBUG", fmt.Sprintf("Sprint '%s' is listed again as '%s'; counted once under the later name", info.Sprints[idx].Name, detail.Name))
+			}
+			info.Sprints[idx].Name = detail.Name
+		}
+	}
+
+	// addLegacySprint adds the sprint in a legacy sprint string, returning false if the string has no name
+	addLegacySprint := func(sprintStr string) bool {
+		attributes := parseLegacySprintAttributes(sprintStr)
+		if attributes["name"] == "" {
+			return false
+		}
+		addSprint(rs.sprintDetailFromLegacyString(attributes))
+		return true
+	}
+
+	switch v := sprintField.(type) {
+	case []interface{}:
+		for _, sprint := range v {
+			interpreted := false
+			// Handle map[string]interface{} (Jira API format)
+			if sprintMap, ok := sprint.(map[string]interface{}); ok {
+				if nameVal, exists := sprintMap["name"]; exists {
+					if sprintName, ok := nameVal.(string); ok {
+						addSprint(rs.sprintDetailFromMap(sprintName, sprintMap))
+						interpreted = true
+					}
+				}
+			} else if sprintStr, ok := sprint.(string); ok {
+				// Fallback: handle string format (legacy)
+				interpreted = addLegacySprint(sprintStr)
+			} else if sprintID, ok := sprint.(float64); ok {
+				// Bare sprint ID (JSON numbers decode to float64)
+				addSprint(rs.sprintDetailFromID(strconv.FormatInt(int64(sprintID), 10)))
+				interpreted = true
+			}
+
+			if !interpreted && rs.debug {
+				rs.writeLog("DEBUG", fmt.Sprintf("Unable to interpret sprint entry of type %T: %v", sprint, sprint))
+			}
+		}
+	case []string:
+		for _, sprintStr := range v {
+			addLegacySprint(sprintStr)
+		}
+	case string:
+		addLegacySprint(v)
+	}
+
+	// Distinct sprints on different boards can share a name, so make their display names unique
+	disambiguateSprintNames(info.Sprints)
+
+	// Jira does not list an issue's sprints in order, so every sprint column comes from the chronological list
+	sort.SliceStable(info.Sprints, func(i, j int) bool {
+		return sprintBefore(info.Sprints[i], info.Sprints[j])
+	})
+	for _, sprint := range info.Sprints {
+		info.SprintNames = append(info.SprintNames, sprint.Name)
+	}
+
+	// A sprint still running is the issue's current sprint
+	for _, sprint := range info.Sprints {
+		if strings.EqualFold(sprint.State, "active") {
+			info.ActiveSprints = append(info.ActiveSprints, sprint.Name)
+		}
+	}
+
+	// Every sprint column is derived from the final unique list only, so Number of Sprints always agrees with All Sprints
+	info.SprintCount = len(info.SprintNames)
+	if len(info.SprintNames) > 0 {
+		// The names and dates of the first and last sprints come from the same sprints
+		first, last := info.Sprints[0], info.Sprints[len(info.Sprints)-1]
+		info.FirstSprint, info.FirstStart, info.FirstEnd = first.Name, first.StartDate, first.EndDate
+		info.FirstGoal = first.Goal
+		info.LastSprint, info.LastStart, info.LastEnd = last.Name, last.StartDate, last.EndDate
+		info.AllSprints = strings.Join(info.SprintNames, ", ")
+	}
+
+	return info
+}
+
+/***********************************************************************************************************************************/
+// checkSprintInfo checks that the derived sprint fields agree with the unique sprint list they come from
+//
+// Parameters:
+//   info - sprint information from parseSprintField
+//
+// Returns:
+//   []string - each disagreement found, e.g. "Number of Sprints is 3 but All Sprints lists 2"; empty if none
+func checkSprintInfo(info SprintInfo) []string {
+	var problems []string
+	if info.SprintCount != len(info.SprintNames) {
+		problems = append(problems, fmt.Sprintf("Number of Sprints is %d but All Sprints lists %d", info.SprintCount, len(info.SprintNames)))
+	}
+	if len(info.Sprints) != len(info.SprintNames) {
+		problems = append(problems, fmt.Sprintf("%d sprint names for %d sprints", len(info.SprintNames), len(info.Sprints)))
+	}
+	if allSprints := strings.Join(info.SprintNames, ", "); info.AllSprints != allSprints {
+		problems = append(problems, fmt.Sprintf("All Sprints is '%s' but the sprint list is '%s'", info.AllSprints, allSprints))
+	}
+	if len(info.SprintNames) > 0 && (info.FirstSprint != info.SprintNames[0] || info.LastSprint != info.SprintNames[len(info.SprintNames)-1]) {
+		problems = append(problems, fmt.Sprintf("First/Last Sprint '%s'/'%s' are not the ends of the sprint list", info.FirstSprint, info.LastSprint))
+	}
+	seen := make(map[string]bool, len(info.SprintNames))
+	for _, name := range info.SprintNames {
+		if seen[name] {
+			problems = append(problems, fmt.Sprintf("sprint '%s' is listed twice", name))
+		}
+		seen[name] = true
+	}
+	return problems
+}
+
+/***********************************************************************************************************************************/
+// sprintIdentity returns the key that identifies a distinct sprint: its ID when known, otherwise its name
+//
+// Names are compared ignoring case unless -strictnames is given, so "Sprint 14" and "sprint 14" are one sprint.
+//
+// Parameters:
+//   detail - sprint to identify
+//
+// Returns:
+//   string - identity key, prefixed so an ID can never collide with a name
+func (rs *runState) sprintIdentity(detail SprintDetail) string {
+	if detail.ID != "" {
+		return "id:" + detail.ID
+	}
+	if rs.strictSprintNames {
+		return "name:" + detail.Name
+	}
+	return "name:" + strings.ToLower(detail.Name)
+}
+
+/***********************************************************************************************************************************/
+// normalizeSprintName tidies the whitespace in a sprint name so that variants of the same name compare equal
+//
+// Leading and trailing whitespace is removed and each run of internal whitespace becomes a single space,
+// e.g. "Sprint  14 " becomes "Sprint 14". With -strictnames the name is returned unchanged.
+//
+// Parameters:
+//   name - sprint name as Jira supplied it
+//
+// Returns:
+//   string - normalized sprint name
+func (rs *runState) normalizeSprintName(name string) string {
+	if rs.strictSprintNames {
+		return name
+	}
+	return strings.Join(strings.Fields(name), " ")
+}
+
+/***********************************************************************************************************************************/
+// disambiguateSprintNames renames sprints that share a name with a different sprint
+//
+// Names become "Sprint 12 (board 34)" using the board ID, or "Sprint 12 (id 123)" when the board is
+// unknown or both sprints are on the same board.
+//
+// Parameters:
+//   sprints - distinct sprints of one issue (renamed in place)
+func disambiguateSprintNames(sprints []SprintDetail) {
+	nameCounts := make(map[string]int, len(sprints))
+	boardNames := make(map[string]int, len(sprints))
+	for _, sprint := range sprints {
+		nameCounts[sprint.Name]++
+		boardNames[sprint.Name+"\x00"+sprint.BoardID]++
+	}
+	for i, sprint := range sprints {
+		if nameCounts[sprint.Name] < 2 {
+			continue
+		}
+		if sprint.BoardID != "" && boardNames[sprint.Name+"\x00"+sprint.BoardID] == 1 {
+			sprints[i].Name = fmt.Sprintf("%s (board %s)", sprint.Name, sprint.BoardID)
+		} else if sprint.ID != "" {
+			sprints[i].Name = fmt.Sprintf("%s (id %s)", sprint.Name, sprint.ID)
+		}
+	}
+}
+
+/***********************************************************************************************************************************/
+// sprintDetailFromMap builds a SprintDetail from a sprint object in the current Jira API format
+//
+// Parameters:
+//   name      - sprint name already extracted from the map
+//   sprintMap - the sprint object from the sprint field
+//
+// Returns:
+//   SprintDetail - sprint attributes, with dates left nil when missing or unparseable
+func (rs *runState) sprintDetailFromMap(name string, sprintMap map[string]interface{}) SprintDetail {
+	detail := SprintDetail{Name: name}
+
+	// Sprint IDs arrive as JSON numbers, which decode to float64
+	if idVal, ok := sprintMap["id"].(float64); ok {
+		detail.ID = strconv.FormatInt(int64(idVal), 10)
+	}
+	if state, ok := sprintMap["state"].(string); ok {
+		detail.State = state
+	}
+	if goal, ok := sprintMap["goal"].(string); ok {
+		detail.Goal = goal
+	}
+	// The sprint field calls it boardId, the Agile sprint API originBoardId
+	if boardVal, ok := sprintMap["boardId"].(float64); ok {
+		detail.BoardID = strconv.FormatInt(int64(boardVal), 10)
+	} else if boardVal, ok := sprintMap["originBoardId"].(float64); ok {
+		detail.BoardID = strconv.FormatInt(int64(boardVal), 10)
+	}
+	if startDate, ok := sprintMap["startDate"].(string); ok {
+		if parsedTime, ok := rs.parseJiraTime(startDate); ok {
+			detail.StartDate = &parsedTime
+		}
+	}
+	if endDate, ok := sprintMap["endDate"].(string); ok {
+		if parsedTime, ok := rs.parseJiraTime(endDate); ok {
+			detail.EndDate = &parsedTime
+		}
+	}
+
+	return detail
+}
+
+/***********************************************************************************************************************************/
+// sprintDetailFromID builds a SprintDetail for a sprint supplied only as a bare ID
+//
+// If the ID was resolved via the Agile API (-resolvesprintids) the resolved details are used,
+// otherwise the sprint is named "Sprint <id>".
+//
+// Parameters:
+//   sprintID - sprint ID as a string
+//
+// Returns:
+//   SprintDetail - resolved sprint attributes or a placeholder named after the ID
+func (rs *runState) sprintDetailFromID(sprintID string) SprintDetail {
+	if detail, ok := rs.resolvedSprints[sprintID]; ok {
+		return detail
+	}
+	return SprintDetail{ID: sprintID, Name: "Sprint " + sprintID}
+}
+
+/***********************************************************************************************************************************/
+// sprintDetailFromLegacyString builds a SprintDetail from a legacy greenhopper sprint string
+//
+// Legacy sprint strings look like:
+//   com.atlassian.greenhopper.service.sprint.Sprint@1a2b[id=42,rapidViewId=7,state=CLOSED,name=Sprint 42,startDate=2025-01-06T00:00:00.000Z,...]
+//
+// Parameters:
+//   attributes - the string's attributes, from parseLegacySprintAttributes
+//
+// Returns:
+//   SprintDetail - sprint attributes, with dates left nil when missing or "<null>"
+func (rs *runState) sprintDetailFromLegacyString(attributes map[string]string) SprintDetail {
+	detail := SprintDetail{
+		Name:    attributes["name"],
+		ID:      attributes["id"],
+		BoardID: attributes["rapidViewId"],
+		State:   strings.ToLower(attributes["state"]),
+		Goal:    attributes["goal"],
+	}
+	if parsedTime, ok := rs.parseJiraTime(attributes["startDate"]); ok {
+		detail.StartDate = &parsedTime
+	}
+	if parsedTime, ok := rs.parseJiraTime(attributes["endDate"]); ok {
+		detail.EndDate = &parsedTime
+	}
+	return detail
+}
+
+/***********************************************************************************************************************************/
+// parseLegacySprintAttributes splits the key=value attributes of a legacy sprint string
+//
+// The attributes are held between the first '[' and the last ']', so a value may itself contain brackets,
+// commas, and equals signs: each value runs until the next ",key=" boundary, where key is a camelCase
+// attribute name, or the closing bracket. For example, name=Sprint 12 [Payments, EU] is read whole.
+// Only a value containing a comma followed by such a name (e.g. ",phase=2") is still cut short.
+//
+// Parameters:
+//   sprintStr - the full legacy sprint string
+//
+// Returns:
+//   map[string]string - attribute values by name; "<null>" values are returned as empty strings
+func parseLegacySprintAttributes(sprintStr string) map[string]string {
+	if idx := strings.Index(sprintStr, "["); idx >= 0 {
+		sprintStr = sprintStr[idx+1:]
+		if end := strings.LastIndex(sprintStr, "]"); end >= 0 {
+			sprintStr = sprintStr[:end]
+		}
+	}
+
+	// attributeAt returns the length of the attribute name at the start of s, or 0 unless it is followed by '='
+	attributeAt := func(s string) int {
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case c >= 'a' && c <= 'z', i > 0 && (c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'):
+			case c == '=' && i > 0:
+				return i
+			default:
+				return 0
+			}
+		}
+		return 0
+	}
+
+	// An attribute starts at the beginning or straight after a comma, with its name followed by '='
+	var starts []int
+	for i := 0; i < len(sprintStr); i++ {
+		if (i == 0 || sprintStr[i-1] == ',') && attributeAt(sprintStr[i:]) > 0 {
+			starts = append(starts, i)
+		}
+	}
+
+	attributes := make(map[string]string, len(starts))
+	for j, start := range starts {
+		end := len(sprintStr)
+		if j+1 < len(starts) {
+			end = starts[j+1] - 1 // The comma before the next attribute
+		}
+		nameLength := attributeAt(sprintStr[start:])
+		value := sprintStr[start+nameLength+1 : end]
+		if value == "<null>" {
+			value = ""
+		}
+		attributes[sprintStr[start:start+nameLength]] = value
+	}
+	return attributes
+}
+
+/***********************************************************************************************************************************/
+// fetchSprintDetails resolves bare sprint IDs found in the sprint field via the Jira Agile API
+//
+// Some issues carry sprint entries that are just a numeric sprint ID. This function collects
+// every such ID across all issues and looks each one up once via /rest/agile/1.0/sprint/{id},
+// sprintLookupWorkers at a time. IDs that cannot be resolved keep their "Sprint <id>" names.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   issues      - issues whose sprint fields should be scanned for bare IDs
+//
+// Returns:
+//   map[string]SprintDetail - mapping of sprint ID to resolved sprint details (failed lookups are omitted)
+func (rs *runState) fetchSprintDetails(jiraBaseURL, authToken string, issues []Issue) map[string]SprintDetail {
+	sprintDetails := make(map[string]SprintDetail)
+
+	// Collect unique bare sprint IDs
+	var sprintIDs []string
+	seen := make(map[string]bool)
+	for _, issue := range issues {
+		sprints, ok := issue.Fields.SprintField.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, sprint := range sprints {
+			if sprintID, ok := sprint.(float64); ok {
+				idStr := strconv.FormatInt(int64(sprintID), 10)
+				if !seen[idStr] {
+					seen[idStr] = true
+					sprintIDs = append(sprintIDs, idStr)
+				}
+			}
+		}
+	}
+
+	if len(sprintIDs) == 0 {
+		return sprintDetails
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Resolving %d sprint IDs via the Agile API", len(sprintIDs)))
+	client := rs.newJiraClient(30 * time.Second)
+
+	// Look the IDs up concurrently; results are collected and logged here rather than in the workers
+	type sprintLookup struct {
+		sprintID string
+		detail   SprintDetail
+		err      error
+	}
+	jobs := make(chan string)
+	results := make(chan sprintLookup)
+	var workers sync.WaitGroup
+	for range min(sprintLookupWorkers, len(sprintIDs)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for sprintID := range jobs {
+				detail, err := rs.lookupSprint(client, jiraBaseURL, authToken, sprintID)
+				results <- sprintLookup{sprintID: sprintID, detail: detail, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, sprintID := range sprintIDs {
+			// Stop promptly on Ctrl-C
+			if rs.runContext.Err() != nil {
+				break
+			}
+			jobs <- sprintID
+		}
+		close(jobs)
+		workers.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		if result.err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Sprint: result.sprintID}, fmt.Sprintf("Failed to lookup sprint %s: %v", result.sprintID, result.err))
+			continue
+		}
+		sprintDetails[result.sprintID] = result.detail
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Resolved %d of %d sprint IDs", len(sprintDetails), len(sprintIDs)))
+	return sprintDetails
+}
+
+/***********************************************************************************************************************************/
+// lookupSprint fetches one sprint's details from /rest/agile/1.0/sprint/{id}
+//
+// Safe to call from several goroutines: it does not log or touch shared state other than the HTTP layer.
+//
+// Parameters:
+//   client      - HTTP client to send the request with
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   sprintID    - sprint ID to look up
+//
+// Returns:
+//   SprintDetail - the sprint's name, state, board, goal, and dates
+//   error        - any request, HTTP status, or parse error
+func (rs *runState) lookupSprint(client *http.Client, jiraBaseURL, authToken, sprintID string) (SprintDetail, error) {
+	sprintURL := fmt.Sprintf("%s/rest/agile/1.0/sprint/%s", jiraBaseURL, sprintID)
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", sprintURL, nil)
+	if err != nil {
+		return SprintDetail{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	// Make HTTP request
+	resp, err := client.Do(req)
+	if err != nil {
+		return SprintDetail{}, err
+	}
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return SprintDetail{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check HTTP status
+	if resp.StatusCode != 200 {
+		return SprintDetail{}, fmt.Errorf("HTTP %d error", resp.StatusCode)
+	}
+
+	// Parse JSON response using the same shape as the sprint field entries
+	var sprintMap map[string]interface{}
+	if err := json.Unmarshal(body, &sprintMap); err != nil {
+		return SprintDetail{}, fmt.Errorf("failed to parse sprint response: %w", err)
+	}
+	name, _ := sprintMap["name"].(string)
+	if name == "" {
+		name = "Sprint " + sprintID
+	}
+	return rs.sprintDetailFromMap(name, sprintMap), nil
+}
+
+/***********************************************************************************************************************************/
+// hasBareSprintIDField reports whether any issue's sprint field holds nothing but sprint IDs
+//
+// Team-managed (next-gen) Cloud projects can return the sprint field as an array of numbers, which
+// carries no names or dates until the IDs are resolved via the Agile API.
+//
+// Parameters:
+//   issues - issues to check
+//
+// Returns:
+//   bool - true if at least one non-empty sprint field consists only of numeric IDs
+func hasBareSprintIDField(issues []Issue) bool {
+	for _, issue := range issues {
+		sprints, ok := issue.Fields.SprintField.([]interface{})
+		if !ok || len(sprints) == 0 {
+			continue
+		}
+		allIDs := true
+		for _, sprint := range sprints {
+			if _, ok := sprint.(float64); !ok {
+				allIDs = false
+				break
+			}
+		}
+		if allIDs {
+			return true
+		}
+	}
+	return false
+}
+
+/***********************************************************************************************************************************/
+// descriptionText flattens an issue description to a single line of plain text
+//
+// API v2 returns the description as a string; API v3 returns an Atlassian Document Format (ADF)
+// document, whose text nodes are collected in order. Newlines, tabs and repeated spaces are collapsed
+// so the value is safe in a TSV cell.
+//
+// Parameters:
+//   description - the description field value (string, ADF document map, or nil)
+//
+// Returns:
+//   string - single-line description text, or empty string if there is none
+func descriptionText(description interface{}) string {
+	var parts []string
+	var collect func(node interface{})
+	collect = func(node interface{}) {
+		switch v := node.(type) {
+		case string:
+			parts = append(parts, v)
+		case map[string]interface{}:
+			if text, ok := v["text"].(string); ok {
+				parts = append(parts, text)
+			}
+			if content, ok := v["content"].([]interface{}); ok {
+				for _, child := range content {
+					collect(child)
+				}
+				// Keep words in adjacent paragraphs apart
+				parts = append(parts, " ")
+			}
+		}
+	}
+	collect(description)
+	return strings.Join(strings.Fields(strings.Join(parts, "")), " ")
+}
+
+/***********************************************************************************************************************************/
+// isFlagged reports whether an issue is flagged as an impediment in the -flaggedfield field
+//
+// Jira returns the Flagged field as an array of option objects ([{"value": "Impediment"}]), null or an
+// empty array when the issue is not flagged; a single option object is also accepted.
+//
+// Parameters:
+//   issue - the Jira issue to check
+//
+// Returns:
+//   bool - true if the field holds at least one option
+func (rs *runState) isFlagged(issue Issue) bool {
+	raw, ok := issue.Fields.additionalField(rs.flaggedFieldName)
+	if !ok {
+		return false
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return false
+	}
+	switch v := decoded.(type) {
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		value, _ := v["value"].(string)
+		return value != ""
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+/***********************************************************************************************************************************/
+// issueLinkKeys lists the issues linked to an issue by the -linktypes link types
+//
+// Link types are matched by name, case-insensitively; links of other types are ignored. For the
+// default Blocks type the inward issues are the blockers and the outward issues the blocked ones.
+//
+// Parameters:
+//   issue - the Jira issue whose links to read
+//
+// Returns:
+//   []string - keys of the inward issues (Blocked By), in link order
+//   []string - keys of the outward issues (Blocks), in link order
+func (rs *runState) issueLinkKeys(issue Issue) ([]string, []string) {
+	var blockedBy, blocks []string
+	for _, link := range issue.Fields.IssueLinks {
+		wanted := false
+		for _, name := range rs.linkTypeNames {
+			if strings.EqualFold(link.Type.Name, name) {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			continue
+		}
+		if link.InwardIssue != nil {
+			blockedBy = append(blockedBy, link.InwardIssue.Key)
+		}
+		if link.OutwardIssue != nil {
+			blocks = append(blocks, link.OutwardIssue.Key)
+		}
+	}
+	return blockedBy, blocks
+}
+
+/***********************************************************************************************************************************/
+// sanitizeCellValue replaces tabs and line breaks with spaces so a free-text value stays in one TSV cell
+//
+// Any invalid UTF-8 is replaced with U+FFFD so a stray byte cannot make the whole file unreadable as UTF-8.
+//
+// Parameters:
+//   value - free-text field value (e.g., summary or sprint goal)
+//
+// Returns:
+//   string - the value on a single line without tabs
+func sanitizeCellValue(value string) string {
+	return cellWhitespaceReplacer.Replace(strings.ToValidUTF8(value, "\uFFFD"))
+}
+
+/***********************************************************************************************************************************/
+// truncateWithEllipsis shortens a string to at most maxLength characters, ending with "..." when truncated
+//
+// The string is cut between runes, never inside a multi-byte character, and the cut is moved back so
+// combining marks, variation selectors, and emoji joined with U+200D stay with the character they belong to.
+//
+// Parameters:
+//   value     - string to truncate
+//   maxLength - maximum number of characters (0 or less means no limit)
+//
+// Returns:
+//   string - the original string, or a truncated copy ending in "..."
+func truncateWithEllipsis(value string, maxLength int) string {
+	runes := []rune(value)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return value
+	}
+	cut, ellipsis := maxLength-3, "..."
+	if maxLength <= 3 {
+		cut, ellipsis = maxLength, ""
+	}
+	for cut > 0 && (isJoiningRune(runes[cut]) || runes[cut-1] == '\u200d') {
+		cut--
+	}
+	return string(runes[:cut]) + ellipsis
+}
+
+/***********************************************************************************************************************************/
+// isJoiningRune reports whether a rune attaches to the character before it
+//
+// Parameters:
+//   r - rune to check
+//
+// Returns:
+//   bool - true for combining marks, variation selectors, emoji skin tone modifiers, and the zero width joiner
+func isJoiningRune(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) || r == '\u200d' ||
+		(r >= 0xFE00 && r <= 0xFE0F) || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+/***********************************************************************************************************************************/
+// limitCellWidths shortens the cells of an output row to at most maxWidth characters
+//
+// Numeric cells (e.g., Number of Sprints and Story Points) are never shortened, so a count cannot be misread.
+//
+// Parameters:
+//   row      - output row cells, shortened in place
+//   maxWidth - maximum number of characters per cell (0 or less means no limit)
+//
+// Returns:
+//   int - number of cells shortened
+func limitCellWidths(row []string, maxWidth int) int {
+	if maxWidth <= 0 {
+		return 0
+	}
+	truncated := 0
+	for i, cell := range row {
+		if len([]rune(cell)) <= maxWidth {
+			continue
+		}
+		if _, err := strconv.ParseFloat(cell, 64); err == nil {
+			continue
+		}
+		row[i] = truncateWithEllipsis(cell, maxWidth)
+		truncated++
+	}
+	return truncated
+}
+
+/***********************************************************************************************************************************/
+// fetchIssueChangelog retrieves the full changelog for an issue, caching the result for reuse
+//
+// Jira Cloud's paginated /rest/api/2/issue/{key}/changelog endpoint is used first. Jira Server
+// and Data Center do not provide it (HTTP 404), so the function falls back to ?expand=changelog.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   issueKey    - key of the issue whose changelog is required
+//
+// Returns:
+//   []ChangelogHistory - changelog entries in the order returned by Jira (oldest first)
+//   error              - any error encountered during fetching
+//
+// Safe for concurrent use: changelogCache is read and updated under changelogMutex.
+func (rs *runState) fetchIssueChangelog(jiraBaseURL, authToken, issueKey string) ([]ChangelogHistory, error) {
+	rs.changelogMutex.Lock()
+	histories, cached := rs.changelogCache[issueKey]
+	rs.changelogMutex.Unlock()
+	if cached {
+		return histories, nil
+	}
+
+	client := rs.newJiraClient(30 * time.Second)
+
+	// getJSON performs a GET request, returning the status code and body
+	getJSON := func(requestURL string) (int, []byte, error) {
+		req, err := http.NewRequestWithContext(rs.runContext, "GET", requestURL, nil)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to create changelog request: %w", err)
+		}
+		req.Header.Set("Authorization", "Basic "+authToken)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to fetch changelog: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if cerr := resp.Body.Close(); cerr != nil {
+			rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to read changelog response: %w", err)
+		}
+		return resp.StatusCode, body, nil
+	}
+
+	startAt := 0
+	for {
+		statusCode, body, err := getJSON(fmt.Sprintf("%s/rest/api/2/issue/%s/changelog?startAt=%d&maxResults=%d",
+			jiraBaseURL, issueKey, startAt, DefaultBatchSize))
+		if err != nil {
+			return nil, err
+		}
+
+		// Jira Server/Data Center: fall back to the expanded issue changelog
+		if statusCode == 404 && startAt == 0 {
+			statusCode, body, err = getJSON(fmt.Sprintf("%s/rest/api/2/issue/%s?expand=changelog&fields=summary", jiraBaseURL, issueKey))
+			if err != nil {
+				return nil, err
+			}
+			if statusCode != 200 {
+				return nil, fmt.Errorf("HTTP %d fetching changelog", statusCode)
+			}
+			var expanded ExpandedChangelog
+			if err := json.Unmarshal(body, &expanded); err != nil {
+				return nil, fmt.Errorf("failed to parse changelog response: %w", err)
+			}
+			histories = expanded.Changelog.Histories
+			break
+		}
+
+		if statusCode != 200 {
+			return nil, fmt.Errorf("HTTP %d fetching changelog", statusCode)
+		}
+		var page ChangelogPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse changelog response: %w", err)
+		}
+		histories = append(histories, page.Values...)
+
+		if page.IsLast || len(page.Values) == 0 || startAt+len(page.Values) >= page.Total {
+			break
+		}
+		startAt += len(page.Values)
+	}
+
+	rs.changelogMutex.Lock()
+	defer rs.changelogMutex.Unlock()
+	if rs.changelogCache == nil {
+		rs.changelogCache = make(map[string][]ChangelogHistory)
+	}
+	rs.changelogCache[issueKey] = histories
+	return histories, nil
+}
+
+// issueChangelog is the changelog of one issue fetched by fetchChangelogs, or the error that prevented it.
+type issueChangelog struct {
+	histories []ChangelogHistory
+	err       error
+}
+
+/***********************************************************************************************************************************/
+// fetchChangelogs fetches the changelogs of the spillover issues for the changelog analyses, changelogWorkers at a time
+//
+// Each issue's changelog is fetched from the instance it came from, one instance after another since useInstance
+// switches the HTTP layer for the whole run. Changelogs already in changelogCache are not fetched again.
+//
+// Parameters:
+//   instances         - Jira instances queried by the run
+//   multisprintIssues - spillover issues whose changelogs are needed
+//
+// Returns:
+//   []issueChangelog - one per issue, in the same order; an issue not fetched because the run was cancelled has the
+//                      cancellation as its error
+//
+// Safe to call only from the run's main goroutine; fetchIssueChangelog is what the workers share.
+func (rs *runState) fetchChangelogs(instances []JiraInstance, multisprintIssues []MultisprintIssue) []issueChangelog {
+	changelogs := make([]issueChangelog, len(multisprintIssues))
+	var labels []string
+	indexesByLabel := make(map[string][]int)
+	for i, multisprintIssue := range multisprintIssues {
+		label := multisprintIssue.Issue.Instance
+		if _, seen := indexesByLabel[label]; !seen {
+			labels = append(labels, label)
+		}
+		indexesByLabel[label] = append(indexesByLabel[label], i)
+	}
+
+	for _, label := range labels {
+		instance := rs.useInstance(instances, label)
+		indexes := indexesByLabel[label]
+
+		// Each worker fills in the changelogs of the issues it is given, so no two write the same element
+		jobs := make(chan int)
+		var workers sync.WaitGroup
+		for range min(changelogWorkers, len(indexes)) {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for i := range jobs {
+					histories, err := rs.fetchIssueChangelog(instance.JiraBaseURL, instance.AuthToken, multisprintIssues[i].Issue.Key)
+					changelogs[i] = issueChangelog{histories: histories, err: err}
+				}
+			}()
+		}
+		for n, i := range indexes {
+			// Stop promptly on Ctrl-C
+			if err := rs.runContext.Err(); err != nil {
+				for _, skipped := range indexes[n:] {
+					changelogs[skipped].err = err
+				}
+				break
+			}
+			jobs <- i
+		}
+		close(jobs)
+		workers.Wait()
+	}
+	return changelogs
+}
+
+/***********************************************************************************************************************************/
+// isSprintChange reports whether a changelog item records a change to the sprint field
+//
+// Parameters:
+//   item - changelog item
+//
+// Returns:
+//   bool - true if the item changed the Sprint field
+func isSprintChange(item ChangelogItem) bool {
+	return item.FieldID == defaultSprintField || strings.EqualFold(item.Field, "Sprint")
+}
+
+/***********************************************************************************************************************************/
+// sprintListContains reports whether a changelog sprint value contains the given sprint
+//
+// Raw sprint values are comma-separated sprint IDs; display values are comma-separated names.
+//
+// Parameters:
+//   sprintList - raw or display value from a changelog item
+//   sprint     - sprint identifier (ID or name) to look for
+//
+// Returns:
+//   bool - true if the sprint is present in the list
+func sprintListContains(sprintList, sprint string) bool {
+	if sprint == "" {
+		return false
+	}
+	for _, entry := range strings.Split(sprintList, ",") {
+		if strings.TrimSpace(entry) == sprint {
+			return true
+		}
+	}
+	return false
+}
+
+/***********************************************************************************************************************************/
+// determineSprintCommitment decides whether an issue was in its first sprint when that sprint started
+//
+// The changelog's Sprint changes show when the first sprint was added. If the earliest Sprint change
+// already lists the sprint in its "from" value, the issue was created in that sprint and the issue's
+// creation date is used instead.
+//
+// Parameters:
+//   issue      - the Jira issue
+//   sprintInfo - parsed sprint information for the issue
+//   histories  - the issue's changelog
+//
+// Returns:
+//   string - "yes" if committed at sprint start, "no" if added mid-sprint, "unknown" if it cannot be determined
+func (rs *runState) determineSprintCommitment(issue Issue, sprintInfo SprintInfo, histories []ChangelogHistory) string {
+	// Identify the chronologically first sprint
+	if len(sprintInfo.Sprints) == 0 {
+		return "unknown"
+	}
+	firstSprint := chronologicalFirstSprint(sprintInfo)
+	if firstSprint.StartDate == nil {
+		return "unknown"
+	}
+
+	// Migrated/imported issues often have no sprint history at all
+	addedAt := rs.findFirstSprintEntry(issue, firstSprint, rs.orderChangelog(histories))
+	if addedAt == nil {
+		return "unknown"
+	}
+	if addedAt.After(*firstSprint.StartDate) {
+		return "no"
+	}
+	return "yes"
+}
+
+/***********************************************************************************************************************************/
+// chronologicalFirstSprint returns the issue's earliest sprint by start date
+//
+// Parameters:
+//   sprintInfo - parsed sprint information for the issue (must contain at least one sprint)
+//
+// Returns:
+//   SprintDetail - the first sprint, ordered as sprintBefore orders sprints
+func chronologicalFirstSprint(sprintInfo SprintInfo) SprintDetail {
+	firstSprint := sprintInfo.Sprints[0]
+	for _, sprint := range sprintInfo.Sprints[1:] {
+		if sprintBefore(sprint, firstSprint) {
+			firstSprint = sprint
+		}
+	}
+	return firstSprint
+}
+
+/***********************************************************************************************************************************/
+// orderChangelog returns a copy of a changelog ordered oldest first
+//
+// Jira normally returns histories oldest first, but this is not guaranteed.
+//
+// Parameters:
+//   histories - the issue's changelog
+//
+// Returns:
+//   []ChangelogHistory - the histories sorted by creation time
+func (rs *runState) orderChangelog(histories []ChangelogHistory) []ChangelogHistory {
+	ordered := append([]ChangelogHistory{}, histories...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		timeI, _ := rs.parseJiraTime(ordered[i].Created)
+		timeJ, _ := rs.parseJiraTime(ordered[j].Created)
+		return timeI.Before(timeJ)
+	})
+	return ordered
+}
+
+/***********************************************************************************************************************************/
+// findFirstSprintEntry finds when an issue was added to its first sprint
+//
+// The changelog's Sprint changes show when the first sprint was added. If the earliest Sprint change
+// already lists the sprint in its "from" value, the issue was created in that sprint and the issue's
+// creation date is used instead.
+//
+// Parameters:
+//   issue       - the Jira issue
+//   firstSprint - the issue's chronologically first sprint
+//   ordered     - the issue's changelog, oldest first
+//
+// Returns:
+//   *time.Time - when the issue entered the sprint, or nil if the changelog has no sprint history
+func (rs *runState) findFirstSprintEntry(issue Issue, firstSprint SprintDetail, ordered []ChangelogHistory) *time.Time {
+	// containsFirst checks raw IDs when known, otherwise display names
+	containsFirst := func(raw, display string) bool {
+		if firstSprint.ID != "" {
+			return sprintListContains(raw, firstSprint.ID)
+		}
+		return sprintListContains(display, firstSprint.Name)
+	}
+
+	var addedAt *time.Time
+	sawSprintChange := false
+	for _, history := range ordered {
+		for _, item := range history.Items {
+			if !isSprintChange(item) {
+				continue
+			}
+			// Present before the earliest sprint change: the issue was created in the sprint
+			if !sawSprintChange && containsFirst(item.From, item.FromString) {
+				if issue.Fields.Created != nil {
+					if createdTime, ok := rs.parseJiraTime(*issue.Fields.Created); ok {
+						addedAt = &createdTime
+					}
+				}
+			} else if !containsFirst(item.From, item.FromString) && containsFirst(item.To, item.ToString) {
+				if changedTime, ok := rs.parseJiraTime(history.Created); ok {
+					addedAt = &changedTime
+				}
+			}
+			sawSprintChange = true
+			if addedAt != nil {
+				break
+			}
+		}
+		if addedAt != nil {
+			break
+		}
+	}
+	return addedAt
+}
+
+/***********************************************************************************************************************************/
+// isStoryPointsChange reports whether a changelog item records a change to the story points field
+//
+// Parameters:
+//   item - changelog item
+//
+// Returns:
+//   bool - true if the item changed the story points field
+func isStoryPointsChange(item ChangelogItem) bool {
+	return item.FieldID == defaultStoryPointsField || strings.EqualFold(item.Field, "Story Points")
+}
+
+/***********************************************************************************************************************************/
+// determineEstimateChange decides whether an issue's story points changed after it entered its first sprint
+//
+// The original estimate is the "from" value of the first story points change after the issue entered its
+// first sprint, or the current value when there was no such change. Changes that leave the value the same
+// (e.g., "3" to "3.0") are ignored.
+//
+// Parameters:
+//   issue      - the Jira issue
+//   sprintInfo - parsed sprint information for the issue
+//   histories  - the issue's changelog
+//
+// Returns:
+//   string - "yes" if the estimate changed in flight, "no" if not, "unknown" if the sprint entry cannot be found
+//   string - story points at first sprint entry ("N/A" if not estimated, empty if unknown)
+func (rs *runState) determineEstimateChange(issue Issue, sprintInfo SprintInfo, histories []ChangelogHistory) (string, string) {
+	currentPoints := FormatStoryPoints(issue.Fields.StoryPoints)
+	ordered := rs.orderChangelog(histories)
+
+	// pointsChanges lists the story points changes with when they were made
+	type pointsChange struct {
+		at   time.Time
+		item ChangelogItem
+	}
+	var pointsChanges []pointsChange
+	for _, history := range ordered {
+		changedTime, ok := rs.parseJiraTime(history.Created)
+		if !ok {
+			continue
+		}
+		for _, item := range history.Items {
+			if isStoryPointsChange(item) {
+				pointsChanges = append(pointsChanges, pointsChange{at: changedTime, item: item})
+			}
+		}
+	}
+	if len(pointsChanges) == 0 {
+		return "no", currentPoints
+	}
+	if len(sprintInfo.Sprints) == 0 {
+		return "unknown", ""
+	}
+	enteredAt := rs.findFirstSprintEntry(issue, chronologicalFirstSprint(sprintInfo), ordered)
+	if enteredAt == nil {
+		return "unknown", ""
+	}
+
+	// samePoints compares estimates numerically where possible, so "3" and "3.0" are equal
+	samePoints := func(a, b string) bool {
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+		numberA, errA := strconv.ParseFloat(a, 64)
+		numberB, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			return numberA == numberB
+		}
+		return a == b
+	}
+
+	changed := "no"
+	originalPoints := currentPoints
+	foundOriginal := false
+	for _, change := range pointsChanges {
+		if !change.at.After(*enteredAt) {
+			continue
+		}
+		if !foundOriginal {
+			originalPoints = strings.TrimSpace(change.item.FromString)
+			if originalPoints == "" {
+				originalPoints = "N/A"
+			}
+			foundOriginal = true
+		}
+		if !samePoints(change.item.FromString, change.item.ToString) {
+			changed = "yes"
+		}
+	}
+	return changed, originalPoints
+}
+
+/***********************************************************************************************************************************/
+// determineUnestimatedSprints counts the sprints an issue was in that started before it was first estimated
+//
+// The first estimate is the first story points change from an empty value. If the earliest story points change
+// is from a value, or there is no change but the issue has story points, they were set when the issue was
+// created and no sprint counts. An issue that has never had story points counts all its sprints. Sprints
+// without a start date (not yet started) are not counted.
+//
+// Parameters:
+//   issue      - the Jira issue
+//   sprintInfo - parsed sprint information for the issue
+//   histories  - the issue's changelog
+//
+// Returns:
+//   string - number of unestimated sprints
+//   string - "yes" if at least one sprint started before the first estimate, "no" if not
+func (rs *runState) determineUnestimatedSprints(issue Issue, sprintInfo SprintInfo, histories []ChangelogHistory) (string, string) {
+	estimated := false
+	var estimatedAt *time.Time // nil when estimated at creation
+	sawChange := false
+	for _, history := range rs.orderChangelog(histories) {
+		for _, item := range history.Items {
+			if !isStoryPointsChange(item) || estimated {
+				continue
+			}
+			if !sawChange && strings.TrimSpace(item.FromString) != "" {
+				estimated = true
+			} else if strings.TrimSpace(item.FromString) == "" && strings.TrimSpace(item.ToString) != "" {
+				if changedTime, ok := rs.parseJiraTime(history.Created); ok {
+					estimated = true
+					estimatedAt = &changedTime
+				}
+			}
+			sawChange = true
+		}
+	}
+	if !sawChange && issue.Fields.StoryPoints != nil {
+		estimated = true
+	}
+
+	unestimated := 0
+	if !estimated {
+		unestimated = sprintInfo.SprintCount
+	} else if estimatedAt != nil {
+		for _, sprint := range sprintInfo.Sprints {
+			if sprint.StartDate != nil && sprint.StartDate.Before(*estimatedAt) {
+				unestimated++
+			}
+		}
+	}
+	if unestimated > 0 {
+		return strconv.Itoa(unestimated), "yes"
+	}
+	return "0", "no"
+}
+
+/***********************************************************************************************************************************/
+// isAssigneeChange reports whether a changelog item records a change of assignee
+//
+// Parameters:
+//   item - changelog item
+//
+// Returns:
+//   bool - true if the item changed the assignee
+func isAssigneeChange(item ChangelogItem) bool {
+	return item.FieldID == "assignee" || strings.EqualFold(item.Field, "assignee")
+}
+
+/***********************************************************************************************************************************/
+// determineAssigneeChurn counts assignee changes and distinct assignees since an issue entered its first sprint
+//
+// Every assignee change after the issue entered its first sprint counts, including reassigning to someone
+// who had it before; unassigning counts as a change to "Unassigned", which is also counted as an assignee.
+// The assignee at sprint entry is the "from" value of the first of those changes, or the current assignee
+// when there were none.
+//
+// Parameters:
+//   issue      - the Jira issue
+//   sprintInfo - parsed sprint information for the issue
+//   histories  - the issue's changelog
+//
+// Returns:
+//   int  - number of assignee changes
+//   int  - number of distinct assignees, counting the assignee at sprint entry
+//   bool - false if the assignee changed but the changelog does not show when the issue entered its first sprint
+func (rs *runState) determineAssigneeChurn(issue Issue, sprintInfo SprintInfo, histories []ChangelogHistory) (int, int, bool) {
+	ordered := rs.orderChangelog(histories)
+
+	// assigneeIdentity prefers the account ID (or user name on Server) over the display name
+	assigneeIdentity := func(raw, display string) string {
+		if strings.TrimSpace(raw) != "" {
+			return strings.TrimSpace(raw)
+		}
+		if strings.TrimSpace(display) != "" {
+			return strings.TrimSpace(display)
+		}
+		return "Unassigned"
+	}
+
+	hasAssigneeChange := false
+	for _, history := range ordered {
+		for _, item := range history.Items {
+			if isAssigneeChange(item) {
+				hasAssigneeChange = true
+			}
+		}
+	}
+	if !hasAssigneeChange {
+		return 0, 1, true
+	}
+	if len(sprintInfo.Sprints) == 0 {
+		return 0, 0, false
+	}
+	enteredAt := rs.findFirstSprintEntry(issue, chronologicalFirstSprint(sprintInfo), ordered)
+	if enteredAt == nil {
+		return 0, 0, false
+	}
+
+	changes := 0
+	assignees := make(map[string]bool)
+	for _, history := range ordered {
+		changedTime, ok := rs.parseJiraTime(history.Created)
+		if !ok || !changedTime.After(*enteredAt) {
+			continue
+		}
+		for _, item := range history.Items {
+			if !isAssigneeChange(item) {
+				continue
+			}
+			if changes == 0 {
+				assignees[assigneeIdentity(item.From, item.FromString)] = true
+			}
+			assignees[assigneeIdentity(item.To, item.ToString)] = true
+			changes++
+		}
+	}
+	if changes == 0 {
+		return 0, 1, true
+	}
+	return changes, len(assignees), true
+}
+
+/***********************************************************************************************************************************/
+// isStatusChange reports whether a changelog item records a status transition
+//
+// Parameters:
+//   item - changelog item
+//
+// Returns:
+//   bool - true if the item changed the status
+func isStatusChange(item ChangelogItem) bool {
+	return item.FieldID == "status" || strings.EqualFold(item.Field, "status")
+}
+
+/***********************************************************************************************************************************/
+// determineTimeInStatus works out how long an issue spent in each status from its status transitions
+//
+// The time from creation to the first transition counts toward the status the issue was created in (the
+// "from" value of that transition, or the current status when it never moved). The final status runs until
+// the issue was resolved, or until now when it is unresolved; Jira clears the resolution date when an issue
+// is reopened, so a reopened issue keeps counting. Time after the resolution date is not counted, and a
+// status visited more than once is credited with every visit.
+//
+// Parameters:
+//   issue     - the Jira issue
+//   histories - the issue's changelog
+//   now       - end of the final status for unresolved issues
+//
+// Returns:
+//   []StatusDuration - days per status, in the order the statuses were first entered
+//   bool             - false if the issue's created date could not be parsed
+func (rs *runState) determineTimeInStatus(issue Issue, histories []ChangelogHistory, now time.Time) ([]StatusDuration, bool) {
+	if issue.Fields.Created == nil {
+		return nil, false
+	}
+	createdTime, ok := rs.parseJiraTime(*issue.Fields.Created)
+	if !ok {
+		return nil, false
+	}
+	endTime := now
+	if issue.Fields.ResolutionDate != nil {
+		if resolvedTime, ok := rs.parseJiraTime(*issue.Fields.ResolutionDate); ok {
+			endTime = resolvedTime
+		}
+	}
+
+	var durations []StatusDuration
+	index := make(map[string]int)
+	credit := func(status string, from, to time.Time) {
+		if status == "" {
+			status = "(unknown)"
+		}
+		if to.After(endTime) {
+			to = endTime
+		}
+		days := 0.0
+		if to.After(from) {
+			days = to.Sub(from).Hours() / 24
+		}
+		if i, seen := index[status]; seen {
+			durations[i].Days += days
+			return
+		}
+		index[status] = len(durations)
+		durations = append(durations, StatusDuration{Status: status, Days: days})
+	}
+
+	status, enteredAt, moved := issue.Fields.Status.Name, createdTime, false
+	for _, history := range rs.orderChangelog(histories) {
+		changedTime, ok := rs.parseJiraTime(history.Created)
+		if !ok {
+			continue
+		}
+		for _, item := range history.Items {
+			if !isStatusChange(item) {
+				continue
+			}
+			if !moved {
+				status, moved = item.FromString, true
+			}
+			credit(status, enteredAt, changedTime)
+			status, enteredAt = item.ToString, changedTime
+		}
+	}
+	credit(status, enteredAt, endTime)
+	return durations, true
+}
+
+/***********************************************************************************************************************************/
+// formatTimeInStatus packs the days per status into the Time In Status cell
+//
+// Parameters:
+//   durations - days per status
+//
+// Returns:
+//   string - "status=days;status=days" with days to one decimal place
+func formatTimeInStatus(durations []StatusDuration) string {
+	parts := make([]string, 0, len(durations))
+	for _, duration := range durations {
+		parts = append(parts, fmt.Sprintf("%s=%.1f", duration.Status, duration.Days))
+	}
+	return strings.Join(parts, ";")
+}
+
+/***********************************************************************************************************************************/
+// statusColumnValue extracts one status's days from a packed Time In Status cell (-statuscolumns)
+//
+// Parameters:
+//   timeInStatus - packed "status=days;status=days" value, or "unknown"
+//   status       - status name, matched case-insensitively
+//
+// Returns:
+//   string - the status's days, "0.0" if the issue was never in it, or the packed value itself if it is empty or "unknown"
+func statusColumnValue(timeInStatus, status string) string {
+	if timeInStatus == "" || timeInStatus == "unknown" {
+		return timeInStatus
+	}
+	for _, part := range strings.Split(timeInStatus, ";") {
+		if separator := strings.LastIndex(part, "="); separator > 0 && strings.EqualFold(part[:separator], status) {
+			return part[separator+1:]
+		}
+	}
+	return "0.0"
+}
+
+/***********************************************************************************************************************************/
+// timeInStatusColumn names the days column for one -statuscolumns status
+//
+// Parameters:
+//   status - status name
+//
+// Returns:
+//   string - column name, e.g. "In Progress (days)"
+func timeInStatusColumn(status string) string {
+	return status + " (days)"
+}
+
+/***********************************************************************************************************************************/
+// analyseSprintCommitment sets CommittedAtStart on each spillover issue from its changelog
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   int - number of issues added to their first sprint after it started
+func (rs *runState) analyseSprintCommitment(instances []JiraInstance, multisprintIssues []MultisprintIssue) int {
+	rs.writeLog("INFO", fmt.Sprintf("Fetching changelogs for %d spillover issues to check sprint commitment", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	midSprintAdditions := 0
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].CommittedAtStart = "unknown"
+			continue
+		}
+		multisprintIssues[i].CommittedAtStart = rs.determineSprintCommitment(multisprintIssues[i].Issue, multisprintIssues[i].SprintInfo, histories)
+		if multisprintIssues[i].CommittedAtStart == "no" {
+			midSprintAdditions++
+		}
+	}
+	return midSprintAdditions
+}
+
+/***********************************************************************************************************************************/
+// analyseEstimateChanges sets EstimateChanged and OriginalSP on each spillover issue from its changelog
+//
+// Changelogs are shared with -commitment through changelogCache, so each one is fetched at most once per run.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   int - number of issues whose story points changed after they entered their first sprint
+func (rs *runState) analyseEstimateChanges(instances []JiraInstance, multisprintIssues []MultisprintIssue) int {
+	rs.writeLog("INFO", fmt.Sprintf("Checking changelogs of %d spillover issues for in-flight estimate changes", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	estimateChanges := 0
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].EstimateChanged = "unknown"
+			continue
+		}
+		multisprintIssues[i].EstimateChanged, multisprintIssues[i].OriginalSP =
+			rs.determineEstimateChange(multisprintIssues[i].Issue, multisprintIssues[i].SprintInfo, histories)
+		if multisprintIssues[i].EstimateChanged == "yes" {
+			estimateChanges++
+		}
+	}
+	return estimateChanges
+}
+
+/***********************************************************************************************************************************/
+// analyseLateEstimates sets UnestimatedSprints and EstimatedLate on each spillover issue from its changelog
+//
+// Changelogs are shared with the other changelog analyses through changelogCache, so each one is fetched at
+// most once per run. If the story points field is not visible to the account, no changelog is read.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   int - number of issues in at least one sprint that started before they were estimated
+func (rs *runState) analyseLateEstimates(instances []JiraInstance, multisprintIssues []MultisprintIssue) int {
+	// Without visible story points every issue would look never estimated
+	if rs.notVisibleFields[defaultStoryPointsField] {
+		rs.writeLog("WARNING", fmt.Sprintf("%s is not visible to this account, so Estimated Late is unknown for every issue", defaultStoryPointsField))
+		for i := range multisprintIssues {
+			multisprintIssues[i].EstimatedLate = "unknown"
+		}
+		return 0
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Checking changelogs of %d spillover issues for sprints started before estimation", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	lateEstimates := 0
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].EstimatedLate = "unknown"
+			continue
+		}
+		multisprintIssues[i].UnestimatedSprints, multisprintIssues[i].EstimatedLate =
+			rs.determineUnestimatedSprints(multisprintIssues[i].Issue, multisprintIssues[i].SprintInfo, histories)
+		if multisprintIssues[i].EstimatedLate == "yes" {
+			lateEstimates++
+		}
+	}
+	return lateEstimates
+}
+
+/***********************************************************************************************************************************/
+// analyseAssigneeChanges sets AssigneeChanges and Assignees on each spillover issue from its changelog
+//
+// Changelogs are shared with -commitment and -estimatechanges through changelogCache, so each one is fetched
+// at most once per run. All changelog pages are read, so long-lived issues are counted in full.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   string - average changes and distinct assignees formatted for display
+func (rs *runState) analyseAssigneeChanges(instances []JiraInstance, multisprintIssues []MultisprintIssue) string {
+	rs.writeLog("INFO", fmt.Sprintf("Checking changelogs of %d spillover issues for assignee changes", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	analysed, totalChanges, totalAssignees := 0, 0, 0
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].AssigneeChanges, multisprintIssues[i].Assignees = "unknown", "unknown"
+			continue
+		}
+		changes, assignees, ok := rs.determineAssigneeChurn(multisprintIssues[i].Issue, multisprintIssues[i].SprintInfo, histories)
+		if !ok {
+			multisprintIssues[i].AssigneeChanges, multisprintIssues[i].Assignees = "unknown", "unknown"
+			continue
+		}
+		multisprintIssues[i].AssigneeChanges, multisprintIssues[i].Assignees = strconv.Itoa(changes), strconv.Itoa(assignees)
+		analysed++
+		totalChanges += changes
+		totalAssignees += assignees
+	}
+
+	if analysed == 0 {
+		return "Assignee churn: no spillover issue's assignee history could be determined"
+	}
+	return fmt.Sprintf("Assignee churn: %.1f assignee changes and %.1f distinct assignees per spillover issue on average (%d of %d issues)",
+		float64(totalChanges)/float64(analysed), float64(totalAssignees)/float64(analysed), analysed, len(multisprintIssues))
+}
+
+/***********************************************************************************************************************************/
+// fetchPriorityRanks fetches the priority scheme's order from Jira
+//
+// Jira lists priorities from highest to lowest, so a priority's position in the list is its rank.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//
+// Returns:
+//   map[string]int - rank of each priority (0 = highest), keyed by lowercase name and by "id:" + priority ID
+//   error          - any error encountered during the request
+func (rs *runState) fetchPriorityRanks(jiraBaseURL, authToken string) (map[string]int, error) {
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", jiraBaseURL+"/rest/api/2/priority", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create priority request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := rs.newJiraClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch priorities: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read priorities response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d fetching priorities", resp.StatusCode)
+	}
+
+	var priorities []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &priorities); err != nil {
+		return nil, fmt.Errorf("failed to parse priorities response: %w", err)
+	}
+	ranks := make(map[string]int, 2*len(priorities))
+	for rank, priority := range priorities {
+		ranks[strings.ToLower(priority.Name)] = rank
+		ranks["id:"+priority.ID] = rank
+	}
+	return ranks, nil
+}
+
+/***********************************************************************************************************************************/
+// isPriorityChange reports whether a changelog item records a change to the priority field
+//
+// Parameters:
+//   item - changelog item
+//
+// Returns:
+//   bool - true if the item changed the priority
+func isPriorityChange(item ChangelogItem) bool {
+	return item.FieldID == "priority" || strings.EqualFold(item.Field, "priority")
+}
+
+/***********************************************************************************************************************************/
+// determineEscalation decides whether an issue's priority was raised after it entered its first sprint
+//
+// Priorities are compared by their rank in the priority scheme, found by name (case-insensitive) or ID.
+// Without ranks (the priority list could not be fetched), lower numeric IDs are taken as higher priorities,
+// as in Jira's default scheme.
+//
+// Parameters:
+//   issue      - the Jira issue
+//   sprintInfo - parsed sprint information for the issue
+//   histories  - the issue's changelog
+//   ranks      - priority ranks from fetchPriorityRanks, or nil
+//
+// Returns:
+//   string - "yes" if the priority was raised in flight, "no" if not, "unknown" if the sprint entry cannot be found
+func (rs *runState) determineEscalation(issue Issue, sprintInfo SprintInfo, histories []ChangelogHistory, ranks map[string]int) string {
+	// rank places a priority in the scheme, returning false if it cannot be placed
+	rank := func(id, name string) (int, bool) {
+		if ranks == nil {
+			number, err := strconv.Atoi(id)
+			return number, err == nil
+		}
+		if r, ok := ranks[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return r, true
+		}
+		r, ok := ranks["id:"+id]
+		return r, ok
+	}
+
+	ordered := rs.orderChangelog(histories)
+	type priorityChange struct {
+		at   time.Time
+		item ChangelogItem
+	}
+	var priorityChanges []priorityChange
+	for _, history := range ordered {
+		changedTime, ok := rs.parseJiraTime(history.Created)
+		if !ok {
+			continue
+		}
+		for _, item := range history.Items {
+			if isPriorityChange(item) {
+				priorityChanges = append(priorityChanges, priorityChange{at: changedTime, item: item})
+			}
+		}
+	}
+	if len(priorityChanges) == 0 {
+		return "no"
+	}
+	if len(sprintInfo.Sprints) == 0 {
+		return "unknown"
+	}
+	enteredAt := rs.findFirstSprintEntry(issue, chronologicalFirstSprint(sprintInfo), ordered)
+	if enteredAt == nil {
+		return "unknown"
+	}
+
+	for _, change := range priorityChanges {
+		if change.at.Before(*enteredAt) {
+			continue
+		}
+		fromRank, fromOK := rank(change.item.From, change.item.FromString)
+		toRank, toOK := rank(change.item.To, change.item.ToString)
+		if fromOK && toOK && toRank < fromRank {
+			return "yes"
+		}
+	}
+	return "no"
+}
+
+/***********************************************************************************************************************************/
+// analyseEscalations sets Escalated on each spillover issue from its changelog
+//
+// Changelogs are shared with the other changelog analyses through changelogCache, so each one is fetched at
+// most once per run. The priority scheme's order is fetched once per Jira instance.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   int - number of issues whose priority was raised after they entered their first sprint
+func (rs *runState) analyseEscalations(instances []JiraInstance, multisprintIssues []MultisprintIssue) int {
+	rs.writeLog("INFO", fmt.Sprintf("Checking changelogs of %d spillover issues for priority escalations", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	ranksByInstance := make(map[string]map[string]int)
+	escalations := 0
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		instance := rs.useInstance(instances, multisprintIssues[i].Issue.Instance)
+		ranks, fetched := ranksByInstance[instance.JiraBaseURL]
+		if !fetched {
+			var err error
+			if ranks, err = rs.fetchPriorityRanks(instance.JiraBaseURL, instance.AuthToken); err != nil {
+				rs.writeLog("WARNING", fmt.Sprintf("Failed to fetch the priority order, comparing priority IDs instead: %v", err))
+			}
+			ranksByInstance[instance.JiraBaseURL] = ranks
+		}
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].Escalated = "unknown"
+			continue
+		}
+		multisprintIssues[i].Escalated = rs.determineEscalation(multisprintIssues[i].Issue, multisprintIssues[i].SprintInfo, histories, ranks)
+		if multisprintIssues[i].Escalated == "yes" {
+			escalations++
+		}
+	}
+	return escalations
+}
+
+/***********************************************************************************************************************************/
+// analyseTimeInStatus sets TimeInStatus on each spillover issue from its changelog
+//
+// Changelogs are shared with the other changelog analyses through changelogCache, so each one is fetched at
+// most once per run. The summary averages the -statuscolumns statuses, or every status seen, busiest first.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to analyse (updated in place)
+//
+// Returns:
+//   string - average days per status formatted for display
+func (rs *runState) analyseTimeInStatus(instances []JiraInstance, multisprintIssues []MultisprintIssue) string {
+	rs.writeLog("INFO", fmt.Sprintf("Checking changelogs of %d spillover issues for time in status", len(multisprintIssues)))
+
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	analysed := 0
+	var totals []StatusDuration
+	index := make(map[string]int)
+	for i := range multisprintIssues {
+		issueKey := multisprintIssues[i].Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s: %v", issueKey, err))
+			multisprintIssues[i].TimeInStatus = "unknown"
+			continue
+		}
+		durations, ok := rs.determineTimeInStatus(multisprintIssues[i].Issue, histories, rs.startTime)
+		if !ok {
+			multisprintIssues[i].TimeInStatus = "unknown"
+			continue
+		}
+		multisprintIssues[i].TimeInStatus = formatTimeInStatus(durations)
+		analysed++
+		for _, duration := range durations {
+			key := strings.ToLower(duration.Status)
+			if j, seen := index[key]; seen {
+				totals[j].Days += duration.Days
+				continue
+			}
+			index[key] = len(totals)
+			totals = append(totals, duration)
+		}
+	}
+
+	if analysed == 0 {
+		return "Time in status: no spillover issue's status history could be determined"
+	}
+	var shown []StatusDuration
+	if len(rs.statusColumnNames) > 0 {
+		for _, status := range rs.statusColumnNames {
+			total := StatusDuration{Status: status}
+			if j, seen := index[strings.ToLower(status)]; seen {
+				total.Days = totals[j].Days
+			}
+			shown = append(shown, total)
+		}
+	} else {
+		shown = totals
+		sort.SliceStable(shown, func(a, b int) bool { return shown[a].Days > shown[b].Days })
+	}
+	parts := make([]string, 0, len(shown))
+	for _, total := range shown {
+		parts = append(parts, fmt.Sprintf("%s %.1f", total.Status, total.Days/float64(analysed)))
+	}
+	return fmt.Sprintf("Time in status: average days per spillover issue: %s (%d of %d issues)",
+		strings.Join(parts, ", "), analysed, len(multisprintIssues))
+}
+
+/***********************************************************************************************************************************/
+// buildSprintReportURL builds the link to a sprint's report (-sprintlinks)
+//
+// Server/Data Center links open the RapidBoard sprint report; Cloud links (-cloudlinks) open the board's
+// sprint report page, which is addressed by project key.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the instance the issue came from
+//   projectKey  - key of the issue's project (used by Cloud links)
+//   sprint      - sprint to link to
+//   cloudStyle  - true for Jira Cloud links
+//
+// Returns:
+//   string - the report URL, or empty if the sprint ID, board ID, or (for Cloud links) project key is unknown
+func buildSprintReportURL(jiraBaseURL, projectKey string, sprint SprintDetail, cloudStyle bool) string {
+	if sprint.ID == "" || sprint.BoardID == "" {
+		return ""
+	}
+	if cloudStyle {
+		if projectKey == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s/jira/software/c/projects/%s/boards/%s/reports/sprint-retrospective?sprint=%s",
+			jiraBaseURL, url.PathEscape(projectKey), url.PathEscape(sprint.BoardID), url.QueryEscape(sprint.ID))
+	}
+	return fmt.Sprintf("%s/secure/RapidBoard.jspa?rapidView=%s&view=reporting&chart=sprintRetrospective&sprint=%s",
+		jiraBaseURL, url.QueryEscape(sprint.BoardID), url.QueryEscape(sprint.ID))
+}
+
+/***********************************************************************************************************************************/
+// isEpic reports whether an issue is an Epic
+//
+// Parameters:
+//   issue - the Jira issue to check
+//
+// Returns:
+//   bool - true if the issue type is Epic (case-insensitive)
+func isEpic(issue Issue) bool {
+	return strings.EqualFold(issue.Fields.IssueType.Name, "Epic")
+}
+
+/***********************************************************************************************************************************/
+// getEpicLink safely extracts epic link from issue fields
+//
+// Parameters:
+//   epicLinkField - the epic link field value from Jira
+//
+// Returns:
+//   string - epic key or "No Epic" if not found
+func getEpicLink(epicLinkField interface{}) string {
+	if epicLinkField == nil {
+		return "No Epic"
+	}
+
+	if epicKey, ok := epicLinkField.(string); ok && epicKey != "" {
+		return epicKey
+	}
+
+	return "No Epic"
+}
+
+/***********************************************************************************************************************************/
+// fetchEpicTitles retrieves epic summaries, statuses, and resolution dates for the given epic keys
+//
+// This function makes API calls to fetch epic summary information for multiple epics.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   epicKeys    - slice of epic keys to look up
+//
+// Returns:
+//   map[string]EpicMeta - mapping of epic key to epic summary, status, and resolution date
+//   error - any error encountered during fetching
+func (rs *runState) fetchEpicTitles(jiraBaseURL, authToken string, epicKeys []string) (map[string]EpicMeta, error) {
+	epics := make(map[string]EpicMeta)
+
+	if len(epicKeys) == 0 {
+		return epics, nil
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Looking up %d unique Epic titles", len(epicKeys)))
+
+	for i, epicKey := range epicKeys {
+		// Stop promptly on Ctrl-C rather than failing every remaining lookup
+		if err := rs.runContext.Err(); err != nil {
+			return epics, err
+		}
+
+		rs.runHooks.epicLookup(i, len(epicKeys))
+		rs.writeLogWithContext("DEBUG", LogContext{Epic: epicKey}, fmt.Sprintf("Looking up Epic %s", epicKey))
+		rs.runStats.EpicLookups++
+
+		// Build epic lookup URL: request only summary, status, and resolution date
+		epicURL := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary,status,resolutiondate", jiraBaseURL, epicKey)
+
+		// Make HTTP request, retrying once on 401 in case the session was invalidated or the token rotated.
+		// A 403 is not retried: the search has just succeeded with the same token, so it means the reporting
+		// account may not see this epic, and retrying would only double the doomed lookups.
+		client := rs.newJiraClient(30 * time.Second)
+		var resp *http.Response
+		var body []byte
+		var err error
+		for authAttempt := 1; ; authAttempt++ {
+			// Create HTTP request
+			var req *http.Request
+			req, err = http.NewRequestWithContext(rs.runContext, "GET", epicURL, nil)
+			if err != nil {
+				rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("Failed to create request for Epic %s: %v", epicKey, err))
+				break
+			}
+
+			// Set headers
+			req.Header.Set("Authorization", "Basic "+authToken)
+			req.Header.Set("Accept", "application/json")
+
+			resp, err = client.Do(req)
+			if errors.Is(err, ErrRefreshTokenRejected) {
+				return epics, err
+			}
+			if err != nil {
+				rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("Failed to lookup Epic %s: %v", epicKey, err))
+				break
+			}
+
+			// Read response body
+			body, err = io.ReadAll(resp.Body)
+			// using a separate variable cerr avoids overwriting the main err from ReadAll.
+			if cerr := resp.Body.Close(); cerr != nil {
+				rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+			}
+			if err != nil {
+				rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("Failed to read response for Epic %s: %v", epicKey, err))
+				break
+			}
+
+			if resp.StatusCode != http.StatusUnauthorized || authAttempt > 1 {
+				break
+			}
+			rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("HTTP %d looking up Epic %s, re-reading token and retrying once", resp.StatusCode, epicKey))
+			rs.runHooks.retry(epicURL, authAttempt+1, 0)
+			authToken = rs.reloadAuthToken(authToken)
+		}
+		if err != nil {
+			rs.recordFailedEpic(epics, epicKey, epicLookupError)
+			continue
+		}
+
+		// Fail the run if authentication was still rejected after the retry
+		if resp.StatusCode == http.StatusUnauthorized {
+			return epics, fmt.Errorf("%w: HTTP %d looking up Epic %s after retry (%d of %d epic summaries retrieved so far)",
+				errAuthRejected, resp.StatusCode, epicKey, len(epics), len(epicKeys))
+		}
+
+		// An epic moved to another project may only be found by searching for its old key
+		if resp.StatusCode == 404 {
+			if movedEpic, found := rs.findMovedEpic(jiraBaseURL, authToken, epicKey); found {
+				rs.storeEpicMeta(epics, epicKey, movedEpic)
+				continue
+			}
+		}
+
+		// Check HTTP status
+		switch resp.StatusCode {
+		case 200:
+		case http.StatusForbidden:
+			rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("HTTP 403 looking up Epic %s: the reporting account may not browse its project", epicKey))
+			rs.recordFailedEpic(epics, epicKey, epicNotAccessible)
+			continue
+		case http.StatusNotFound:
+			rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("HTTP 404 looking up Epic %s: it does not exist or is hidden from the reporting account", epicKey))
+			rs.recordFailedEpic(epics, epicKey, epicNotFound)
+			continue
+		default:
+			rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("HTTP %d error looking up Epic %s", resp.StatusCode, epicKey))
+			rs.recordFailedEpic(epics, epicKey, epicLookupError)
+			continue
+		}
+
+		// Parse JSON response
+		var epicInfo EpicInfo
+		if err := json.Unmarshal(body, &epicInfo); err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("Failed to parse Epic response for %s: %v", epicKey, err))
+			rs.recordFailedEpic(epics, epicKey, epicLookupError)
+			continue
+		}
+
+		rs.storeEpicMeta(epics, epicKey, epicInfo)
+	}
+	rs.runHooks.epicLookup(len(epicKeys), len(epicKeys))
+
+	rs.writeLog("INFO", fmt.Sprintf("Retrieved %d Epic summaries", len(epics)))
+	return epics, nil
+}
+
+/***********************************************************************************************************************************/
+// recordFailedEpic records an epic whose lookup failed, with the Epic Summary value that names the cause
+//
+// Parameters:
+//   epics   - epic map being built by fetchEpicTitles
+//   epicKey - key that was looked up
+//   summary - epicNotAccessible, epicNotFound, or epicLookupError
+//
+// Side effects:
+//   - Adds epicKey to failedEpicKeys and counts the failure in runStats
+func (rs *runState) recordFailedEpic(epics map[string]EpicMeta, epicKey, summary string) {
+	epics[epicKey] = EpicMeta{Summary: summary}
+	if rs.failedEpicKeys == nil {
+		rs.failedEpicKeys = make(map[string][]string)
+	}
+	rs.failedEpicKeys[summary] = append(rs.failedEpicKeys[summary], epicKey)
+	rs.runStats.EpicLookupsFailed++
+	switch summary {
+	case epicNotAccessible:
+		rs.runStats.EpicsInaccessible++
+	case epicNotFound:
+		rs.runStats.EpicsNotFound++
+	}
+}
+
+/***********************************************************************************************************************************/
+// logFailedEpics logs how many epic lookups failed during the run, by cause, so permissions can be fixed
+//
+// Parameters: None (reads failedEpicKeys)
+//
+// Side effects:
+//   - Logs a WARNING per cause with up to five example epic keys if any epic lookup failed
+func (rs *runState) logFailedEpics() {
+	for _, cause := range []struct{ summary, advice string }{
+		{epicNotAccessible, "grant the reporting account Browse Projects on their projects"},
+		{epicNotFound, "the epics were deleted, or Jira hides them from the reporting account"},
+		{epicLookupError, "usually transient, the next run will look them up again"},
+	} {
+		epicKeys := rs.failedEpicKeys[cause.summary]
+		if len(epicKeys) == 0 {
+			continue
+		}
+		sort.Strings(epicKeys)
+		examples := epicKeys
+		if len(examples) > 5 {
+			examples = examples[:5]
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("%d epics shown as '%s' (e.g. %s): %s",
+			len(epicKeys), cause.summary, strings.Join(examples, ", "), cause.advice))
+	}
+}
+
+/***********************************************************************************************************************************/
+// storeEpicMeta records a looked-up epic, under both keys when Jira answered with the key of a moved epic
+//
+// Jira keeps an old key working after an issue is moved between projects, but answers with the new key,
+// while the epic link field of the children may still hold the old one.
+//
+// Parameters:
+//   epics    - epic map being built by fetchEpicTitles
+//   epicKey  - key that was looked up
+//   epicInfo - Jira's response for the epic
+//
+// Side effects:
+//   - Logs an INFO message when the epic is stored under an alias
+func (rs *runState) storeEpicMeta(epics map[string]EpicMeta, epicKey string, epicInfo EpicInfo) {
+	epicTitle := epicInfo.Fields.Summary
+	if epicTitle == "" {
+		epicTitle = "No Epic Title"
+	}
+	epic := EpicMeta{Summary: epicTitle, Status: epicInfo.Fields.Status.Name, ResolutionDate: epicInfo.Fields.ResolutionDate}
+	if epicInfo.Key != "" && epicInfo.Key != epicKey {
+		rs.writeLogWithContext("INFO", LogContext{Epic: epicKey}, fmt.Sprintf("Epic %s has moved and is now %s, recording it under both keys", epicKey, epicInfo.Key))
+		epics[epicInfo.Key] = epic
+		epic.CurrentKey = epicInfo.Key
+	}
+	epics[epicKey] = epic
+}
+
+/***********************************************************************************************************************************/
+// findMovedEpic searches for an epic by its old key after a direct lookup returned HTTP 404
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   epicKey     - key that could not be found directly
+//
+// Returns:
+//   EpicInfo - the epic as Jira knows it now (its Key is the current key)
+//   bool     - true if the search found the epic
+func (rs *runState) findMovedEpic(jiraBaseURL, authToken, epicKey string) (EpicInfo, bool) {
+	req, err := rs.newSearchRequest(jiraBaseURL, authToken, "key = "+quoteJQLValue(epicKey), 0, 1, "summary,status,resolutiondate")
+	if err != nil {
+		return EpicInfo{}, false
+	}
+
+	resp, err := rs.newJiraClient(30 * time.Second).Do(req)
+	if err != nil {
+		rs.writeLogWithContext("WARNING", LogContext{Epic: epicKey}, fmt.Sprintf("Failed to search for moved Epic %s: %v", epicKey, err))
+		return EpicInfo{}, false
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	// Jira answers HTTP 400 when no issue has ever had the key
+	if err != nil || resp.StatusCode != 200 {
+		return EpicInfo{}, false
+	}
+
+	var searchResult struct {
+		Issues []EpicInfo `json:"issues"`
+	}
+	if err := json.Unmarshal(body, &searchResult); err != nil || len(searchResult.Issues) == 0 {
+		return EpicInfo{}, false
+	}
+	return searchResult.Issues[0], true
+}
+
+/***********************************************************************************************************************************/
+// parseJiraTime parses a date/time string in any of the formats returned by the Jira API (see jiraTimeLayouts)
+//
+// Every Jira timestamp the program reads goes through this function, so a format it does not know is
+// counted once per distinct value and reported at the end of the run by logUnparseableTimes.
+//
+// Parameters:
+//   dateStr - date string from Jira API
+//
+// Returns:
+//   time.Time - parsed time
+//   bool      - true if the string was parsed successfully
+//
+// Side effects:
+//   - Records a non-empty string that could not be parsed in unparseableTimes
+func (rs *runState) parseJiraTime(dateStr string) (time.Time, bool) {
+	if dateStr == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range jiraTimeLayouts {
+		if parsedTime, err := time.Parse(layout, dateStr); err == nil {
+			return parsedTime, true
+		}
+	}
+
+	if rs.unparseableTimes == nil {
+		rs.unparseableTimes = make(map[string]bool)
+	}
+	rs.unparseableTimes[dateStr] = true
+	return time.Time{}, false
+}
+
+/***********************************************************************************************************************************/
+// logUnparseableTimes logs how many distinct timestamps parseJiraTime could not parse during the run
+//
+// Parameters: None (reads unparseableTimes)
+//
+// Side effects:
+//   - Logs a WARNING with up to three examples if any timestamp could not be parsed
+func (rs *runState) logUnparseableTimes() {
+	if len(rs.unparseableTimes) == 0 {
+		return
+	}
+	examples := make([]string, 0, len(rs.unparseableTimes))
+	for dateStr := range rs.unparseableTimes {
+		examples = append(examples, fmt.Sprintf("%q", dateStr))
+	}
+	sort.Strings(examples)
+	if len(examples) > 3 {
+		examples = examples[:3]
+	}
+	rs.writeLog("WARNING", fmt.Sprintf("%d distinct Jira timestamps could not be parsed (e.g. %s); those dates are blank in the output and ignored by the date filters",
+		len(rs.unparseableTimes), strings.Join(examples, ", ")))
+}
+
+/***********************************************************************************************************************************/
+// formatDate formats a date pointer to string in yyyy-MM-dd format
+//
+// Parameters:
+//   issueKey - key of the issue the date belongs to (used when reporting unparseable dates)
+//   datePtr  - pointer to date string from Jira API
+//
+// Returns:
+//   string - formatted date or empty string if null/invalid
+func (rs *runState) formatDate(issueKey string, datePtr *string) string {
+	if datePtr == nil || *datePtr == "" {
+		return ""
+	}
+
+	// Try to parse the Jira date format and convert to yyyy-MM-dd in the report timezone
+	if parsedTime, ok := rs.parseJiraTime(*datePtr); ok {
+		return parsedTime.In(rs.reportLocation).Format("2006-01-02")
+	}
+
+	rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Error formatting date '%s'", *datePtr))
+	return ""
+}
+
+/***********************************************************************************************************************************/
+// isWithinGracePeriod reports whether an issue only just rolled over into its second sprint
+//
+// An issue in exactly two sprints (the fewest that count as spillover) whose latest sprint by start date began
+// fewer than graceDays calendar days ago is within the grace period. Issues in more sprints, or whose latest
+// sprint has no start date, never are.
+//
+// Parameters:
+//   sprintInfo - parsed sprint information for the issue
+//   graceDays  - grace period in days (0 or less means no grace period)
+//   now        - time the run started
+//
+// Returns:
+//   bool - true if the issue should be left out of this run
+func (rs *runState) isWithinGracePeriod(sprintInfo SprintInfo, graceDays int, now time.Time) bool {
+	if graceDays <= 0 || sprintInfo.SprintCount != 2 || sprintInfo.LastStart == nil {
+		return false
+	}
+	return CalendarDaysBetween(*sprintInfo.LastStart, now, rs.reportLocation) < graceDays
+}
+
+/***********************************************************************************************************************************/
+// formatSprintDate formats a sprint date in yyyy-MM-dd format in the report timezone
+//
+// Parameters:
+//   date - sprint start or end date (nil if not known)
+//
+// Returns:
+//   string - formatted date or empty string if nil
+func (rs *runState) formatSprintDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.In(rs.reportLocation).Format("2006-01-02")
+}
+
+/***********************************************************************************************************************************/
+// CalendarDaysBetween counts the calendar days from one time to another in the report timezone
+//
+// Both times are converted to the report timezone and compared by date only, so a resolution at 23:30 and a run
+// at 00:30 the next morning are one day apart regardless of the machine's own timezone.
+//
+// Parameters:
+//   from     - earlier time
+//   to       - later time
+//   location - report timezone (-timezone)
+//
+// Returns:
+//   int - number of calendar days between the two dates (negative if from is after to)
+func CalendarDaysBetween(from, to time.Time, location *time.Location) int {
+	from = from.In(location)
+	to = to.In(location)
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+/***********************************************************************************************************************************/
+// getStalenessBucket classifies an issue by the number of days since it was last updated
+//
+// With the default thresholds: Fresh (under 7 days), Aging (7 to 20), Stale (21 to 60), Abandoned (over 60).
+//
+// Parameters:
+//   issue   - the Jira issue to classify
+//   runTime - time the run started, so every issue is measured from the same point
+//
+// Returns:
+//   string - Fresh, Aging, Stale, Abandoned, or Unknown if the updated date is missing or unparseable
+func (rs *runState) getStalenessBucket(issue Issue, runTime time.Time) string {
+	if issue.Fields.Updated == nil {
+		return "Unknown"
+	}
+	updatedTime, ok := rs.parseJiraTime(*issue.Fields.Updated)
+	if !ok {
+		return "Unknown"
+	}
+
+	days := CalendarDaysBetween(updatedTime, runTime, rs.reportLocation)
+	switch {
+	case days < rs.staleBuckets[0]:
+		return "Fresh"
+	case days < rs.staleBuckets[1]:
+		return "Aging"
+	case days <= rs.staleBuckets[2]:
+		return "Stale"
+	default:
+		return "Abandoned"
+	}
+}
+
+/***********************************************************************************************************************************/
+// parseDueDate parses a Jira due date, which is a calendar date with no time or timezone
+//
+// The date is taken as midnight in the report timezone so it is never shifted to the previous or next day.
+//
+// Parameters:
+//   dueDate - due date string from Jira API (yyyy-MM-dd)
+//
+// Returns:
+//   time.Time - parsed due date
+//   bool      - true if the date was parsed successfully
+func (rs *runState) parseDueDate(dueDate string) (time.Time, bool) {
+	if parsedTime, err := time.ParseInLocation("2006-01-02", dueDate, rs.reportLocation); err == nil {
+		return parsedTime, true
+	}
+	return rs.parseJiraTime(dueDate)
+}
+
+/***********************************************************************************************************************************/
+// isOverdue reports whether an issue was resolved after its due date, or is unresolved and past it
+//
+// Parameters:
+//   issue   - the Jira issue to check
+//   runTime - time the run started, used for unresolved issues
+//
+// Returns:
+//   bool - true if overdue, false if on time or there is no usable due date
+func (rs *runState) isOverdue(issue Issue, runTime time.Time) bool {
+	if issue.Fields.DueDate == nil {
+		return false
+	}
+	dueDate, ok := rs.parseDueDate(*issue.Fields.DueDate)
+	if !ok {
+		return false
+	}
+	compareTo := runTime
+	if issue.Fields.ResolutionDate != nil {
+		if resolvedTime, ok := rs.parseJiraTime(*issue.Fields.ResolutionDate); ok {
+			compareTo = resolvedTime
+		}
+	}
+	return CalendarDaysBetween(dueDate, compareTo, rs.reportLocation) > 0
+}
+
+/***********************************************************************************************************************************/
+// StoryPointsValue converts the story points field to a number
+//
+// Parameters:
+//   storyPoints - the story points field value from Jira (number, string, or null)
+//
+// Returns:
+//   float64 - story points value
+//   bool    - true if the field held a numeric value
+func StoryPointsValue(storyPoints interface{}) (float64, bool) {
+	switch v := storyPoints.(type) {
+	case float64:
+		return v, true
+	case string:
+		if points, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return points, true
+		}
+	}
+	return 0, false
+}
+
+/***********************************************************************************************************************************/
+// formatIdentity formats a user for the Assignee, Reporter, and Pair columns according to -identityfields
+//
+// When the requested email address or account ID is missing (email is commonly hidden by privacy
+// settings, account IDs are absent on Jira Server) the display name is used instead and the
+// fallback is counted in identityFallbackCount.
+//
+// Parameters:
+//   displayName - user's display name
+//   accountID   - user's Atlassian account ID (may be empty)
+//   email       - user's email address (may be empty)
+//
+// Returns:
+//   string - formatted identity
+func (rs *runState) formatIdentity(displayName, accountID, email string) string {
+	switch rs.identityMode {
+	case "email":
+		if email != "" {
+			return email
+		}
+	case "accountid":
+		if accountID != "" {
+			return accountID
+		}
+	case "display+email":
+		if email != "" {
+			return fmt.Sprintf("%s <%s>", displayName, email)
+		}
+	default:
+		return displayName
+	}
+
+	rs.identityFallbackCount++
+	return displayName
+}
+
+/***********************************************************************************************************************************/
+// churnScore measures how many sprints an issue took for its size: sprint count divided by story points
+//
+// Story points below 1 (including 0) count as 1, so small and unestimated-but-numeric issues are not
+// inflated without limit.
+//
+// Parameters:
+//   multisprintIssue - spillover issue
+//
+// Returns:
+//   float64 - sprint count divided by max(story points, 1)
+//   bool    - false if the story points are not numeric
+func churnScore(multisprintIssue MultisprintIssue) (float64, bool) {
+	points, ok := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+	if !ok {
+		return 0, false
+	}
+	return float64(multisprintIssue.SprintInfo.SprintCount) / max(points, 1), true
+}
+
+/***********************************************************************************************************************************/
+// FormatChurnScore formats an issue's churn score for the Churn Score column
+//
+// Parameters:
+//   multisprintIssue - spillover issue
+//
+// Returns:
+//   string - churn score to two decimal places, or empty string if the story points are not numeric
+func FormatChurnScore(multisprintIssue MultisprintIssue) string {
+	score, ok := churnScore(multisprintIssue)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(score, 'f', 2, 64)
+}
+
+/***********************************************************************************************************************************/
+// topChurnIssues ranks spillover issues by churn score, highest first
+//
+// Issues without numeric story points are left out. Ties are broken by issue key.
+//
+// Parameters:
+//   multisprintIssues - spillover issues from the report
+//   limit             - maximum number of issues to return
+//
+// Returns:
+//   []MultisprintIssue - up to limit issues with the highest churn scores
+func topChurnIssues(multisprintIssues []MultisprintIssue, limit int) []MultisprintIssue {
+	var ranked []MultisprintIssue
+	for _, multisprintIssue := range multisprintIssues {
+		if _, ok := churnScore(multisprintIssue); ok {
+			ranked = append(ranked, multisprintIssue)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		scoreI, _ := churnScore(ranked[i])
+		scoreJ, _ := churnScore(ranked[j])
+		if scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+		return ranked[i].Issue.Key < ranked[j].Issue.Key
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+/***********************************************************************************************************************************/
+// spilloverScore combines how far an issue spilled over, how big it is, and how old it is into one severity number
+//
+// The score is sprints weight x (sprint count - 1) + points weight x story points + age weight x age in days,
+// rounded to one decimal place. With the default weights (3, 1, 0.1) a 5-point issue in 3 sprints created
+// 40 days ago scores 3x2 + 1x5 + 0.1x40 = 15.0.
+//
+// Parameters:
+//   sprintCount - number of sprints the issue has been in
+//   storyPoints - story points (0 when not numeric)
+//   ageDays     - calendar days since the issue was created (0 when unknown)
+//   weights     - weight of each part
+//
+// Returns:
+//   float64 - the score to one decimal place
+func spilloverScore(sprintCount int, storyPoints float64, ageDays int, weights ScoreWeights) float64 {
+	score := weights.Sprints*float64(max(sprintCount-1, 0)) + weights.Points*storyPoints + weights.Age*float64(ageDays)
+	return math.Round(score*10) / 10
+}
+
+/***********************************************************************************************************************************/
+// issueSpilloverScore calculates the Spillover Score of a spillover issue with the -scoreweights weights
+//
+// Story points that are not numeric contribute nothing, as does the age when the created date is missing.
+//
+// Parameters:
+//   multisprintIssue - spillover issue
+//   runTime          - time the run started, so every issue's age is measured from the same point
+//
+// Returns:
+//   float64 - the score to one decimal place
+//   bool    - false if the story points are not numeric, so the score covers sprints and age only
+func (rs *runState) issueSpilloverScore(multisprintIssue MultisprintIssue, runTime time.Time) (float64, bool) {
+	points, pointsOK := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+	ageDays := 0
+	if created := multisprintIssue.Issue.Fields.Created; created != nil {
+		if createdTime, ok := rs.parseJiraTime(*created); ok {
+			ageDays = max(CalendarDaysBetween(createdTime, runTime, rs.reportLocation), 0)
+		}
+	}
+	return spilloverScore(multisprintIssue.SprintInfo.SprintCount, points, ageDays, rs.spilloverWeights), pointsOK
+}
+
+/***********************************************************************************************************************************/
+// formatSpilloverScore formats an issue's spillover score for the Spillover Score column
+//
+// Parameters:
+//   multisprintIssue - spillover issue
+//
+// Returns:
+//   string - spillover score to one decimal place
+func (rs *runState) formatSpilloverScore(multisprintIssue MultisprintIssue) string {
+	score, _ := rs.issueSpilloverScore(multisprintIssue, rs.startTime)
+	return strconv.FormatFloat(score, 'f', 1, 64)
+}
+
+/***********************************************************************************************************************************/
+// SpilloverScoreFormula describes how the Spillover Score is calculated, for the log and run metadata
+//
+// Parameters:
+//   weights - the weights in use
+//
+// Returns:
+//   string - e.g. "3 x (sprints - 1) + 1 x story points + 0.1 x age in days"
+func SpilloverScoreFormula(weights ScoreWeights) string {
+	weight := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%s x (sprints - 1) + %s x story points + %s x age in days",
+		weight(weights.Sprints), weight(weights.Points), weight(weights.Age))
+}
+
+/***********************************************************************************************************************************/
+// topScoreIssues ranks spillover issues by spillover score, highest first
+//
+// Ties are broken by issue key.
+//
+// Parameters:
+//   multisprintIssues - spillover issues from the report
+//   limit             - maximum number of issues to return
+//
+// Returns:
+//   []MultisprintIssue - up to limit issues with the highest spillover scores
+func (rs *runState) topScoreIssues(multisprintIssues []MultisprintIssue, limit int) []MultisprintIssue {
+	ranked := append([]MultisprintIssue{}, multisprintIssues...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		scoreI, _ := rs.issueSpilloverScore(ranked[i], rs.startTime)
+		scoreJ, _ := rs.issueSpilloverScore(ranked[j], rs.startTime)
+		if scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+		return ranked[i].Issue.Key < ranked[j].Issue.Key
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+/***********************************************************************************************************************************/
+// secondsToHours converts a Jira time tracking value from seconds to hours, rounded to one decimal place
+//
+// Values that cannot be a duration (negative, NaN, or infinite, as some instances and migrations produce)
+// are treated as not set. Anything under three minutes rounds to 0.
+//
+// Parameters:
+//   seconds - time tracking value in seconds (nil if not set)
+//
+// Returns:
+//   *float64 - hours, or nil if not set or not a valid duration
+func secondsToHours(seconds *float64) *float64 {
+	if seconds == nil || *seconds < 0 || math.IsNaN(*seconds) || math.IsInf(*seconds, 0) {
+		return nil
+	}
+	hours := math.Round(*seconds/360) / 10
+	return &hours
+}
+
+/***********************************************************************************************************************************/
+// formatHours formats a Jira time tracking value for the time columns (-includetime)
+//
+// Parameters:
+//   seconds - time tracking value in seconds (nil if not set)
+//
+// Returns:
+//   string - hours to one decimal place (e.g. "7.5"), or empty string if not set or not a valid duration
+func formatHours(seconds *float64) string {
+	hours := secondsToHours(seconds)
+	if hours == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*hours, 'f', 1, 64)
+}
+
+/***********************************************************************************************************************************/
+// hoursToSeconds reads a time column of a saved report back into seconds (-input)
+//
+// Parameters:
+//   hours - cell value, e.g. "7.5" (empty if not set)
+//
+// Returns:
+//   *float64 - seconds, or nil if the cell is empty or not a number
+func hoursToSeconds(hours string) *float64 {
+	value, err := strconv.ParseFloat(hours, 64)
+	if err != nil {
+		return nil
+	}
+	seconds := value * 3600
+	return &seconds
+}
+
+/***********************************************************************************************************************************/
+// FormatStoryPoints formats the story points field for output
+//
+// Parameters:
+//   storyPoints - the story points field value from Jira (number, string, or null)
+//
+// Returns:
+//   string - formatted story points, or "N/A" if the field is empty
+func FormatStoryPoints(storyPoints interface{}) string {
+	switch points := storyPoints.(type) {
+	case nil:
+		return "N/A"
+	case float64:
+		return strconv.FormatFloat(points, 'g', -1, 64)
+	case string:
+		return points
+	default:
+		return fmt.Sprintf("%v", points)
+	}
+}
+
+/***********************************************************************************************************************************/
+// extractFieldValues safely extracts various field values from issue with defaults
+//
+// Parameters:
+//   issue - the Jira issue to extract values from
+//
+// Returns:
+//   map[string]string - map of field names to values with appropriate defaults
+func (rs *runState) extractFieldValues(issue Issue) map[string]string {
+	// Pre-size for the fixed set of columns populated below
+	values := make(map[string]string, 16)
+
+	// Basic fields
+	values["IssueType"] = issue.Fields.IssueType.Name
+	values["Status"] = issue.Fields.Status.Name
+	values["StatusCategory"] = issue.Fields.Status.StatusCategory.Name
+	values["ProjectName"] = issue.Fields.Project.Name
+	values["UpdatedDate"] = rs.formatDate(issue.Key, issue.Fields.Updated)
+	values["CreatedDate"] = rs.formatDate(issue.Key, issue.Fields.Created)
+	values["ResolvedDate"] = rs.formatDate(issue.Key, issue.Fields.ResolutionDate)
+
+	// Assignee
+	if issue.Fields.Assignee != nil {
+		assignee := issue.Fields.Assignee
+		values["Assignee"] = rs.formatIdentity(assignee.DisplayName, assignee.AccountID, assignee.EmailAddress)
+	} else {
+		values["Assignee"] = "Unassigned"
+	}
+
+	// Creator/Reporter
+	if issue.Fields.Creator != nil {
+		creator := issue.Fields.Creator
+		values["Reporter"] = rs.formatIdentity(creator.DisplayName, creator.AccountID, creator.EmailAddress)
+	} else {
+		values["Reporter"] = "Unknown"
+	}
+
+	// Story Points
+	values["StoryPoints"] = FormatStoryPoints(issue.Fields.StoryPoints)
+
+	// Fix Versions
+	fixVersions := make([]string, 0, len(issue.Fields.FixVersions))
+	for _, version := range issue.Fields.FixVersions {
+		fixVersions = append(fixVersions, version.Name)
+	}
+	values["FixVersions"] = strings.Join(fixVersions, ", ")
+
+	// Components
+	components := make([]string, 0, len(issue.Fields.Components))
+	for _, component := range issue.Fields.Components {
+		components = append(components, component.Name)
+	}
+	values["Components"] = strings.Join(components, ", ")
+
+	// Labels
+	values["Labels"] = strings.Join(issue.Fields.Labels, ", ")
+
+	// Resolution
+	if issue.Fields.Resolution != nil {
+		values["Resolution"] = issue.Fields.Resolution.Name
+	} else {
+		values["Resolution"] = ""
+	}
+
+	// Description excerpt and comment count (only requested with -includedescription and -includecomments)
+	values["Description"] = truncateWithEllipsis(descriptionText(issue.Fields.Description), rs.descriptionMaxLength)
+	values["Comments"] = "0"
+	if issue.Fields.Comment != nil {
+		values["Comments"] = strconv.Itoa(issue.Fields.Comment.Total)
+	}
+
+	// Time tracking in hours (only requested with -includetime; blank when not set or time tracking is off)
+	values["OriginalEstimate"] = formatHours(issue.Fields.TimeOriginalEstimate)
+	values["TimeSpent"] = formatHours(issue.Fields.TimeSpent)
+	values["AggregateTimeSpent"] = formatHours(issue.Fields.AggregateTimeSpent)
+
+	// Flagged as an impediment
+	values["Flagged"] = "no"
+	if rs.isFlagged(issue) {
+		values["Flagged"] = "yes"
+	}
+
+	// Linked issues (only requested with -includelinks)
+	blockedBy, blocks := rs.issueLinkKeys(issue)
+	values["BlockedBy"] = strings.Join(blockedBy, ", ")
+	values["Blocks"] = strings.Join(blocks, ", ")
+
+	// Priority and due date
+	values["Priority"] = ""
+	if issue.Fields.Priority != nil {
+		values["Priority"] = issue.Fields.Priority.Name
+	}
+	values["DueDate"] = ""
+	if issue.Fields.DueDate != nil && *issue.Fields.DueDate != "" {
+		if dueDate, ok := rs.parseDueDate(*issue.Fields.DueDate); ok {
+			values["DueDate"] = dueDate.Format("2006-01-02")
+		} else {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issue.Key}, fmt.Sprintf("Error formatting date '%s'", *issue.Fields.DueDate))
+		}
+	}
+
+	// Pair information
+	if rs.pairFieldProvided && rs.pairFieldName != "" {
+		// DEBUG: Show keys in AdditionalFields if debug is enabled
+		if rs.wantIssueDebug(issue.Key) {
+			keys := make([]string, 0, len(issue.Fields.AdditionalFields))
+			for k := range issue.Fields.AdditionalFields {
+				keys = append(keys, k)
+			}
+			rs.runHooks.issueDebug(issue.Key, fmt.Sprintf("Issue %s AdditionalFields keys: %v", issue.Key, keys))
+		}
+		// Attempt to read the configured custom field from AdditionalFields
+		if raw, ok := issue.Fields.additionalField(rs.pairFieldName); ok {
+			if rs.wantIssueDebug(issue.Key) {
+				rs.runHooks.issueDebug(issue.Key, fmt.Sprintf("Issue %s raw Pair field (%s): %s", issue.Key, rs.pairFieldName, string(raw)))
+			}
+			// Try several possible shapes: array of objects, single object, array of strings, or single string
+			// 1) array of objects [{"displayName": "Alice"}, ...]
+			var pairNames []string
+			var parsed bool
+
+			// attempt array of objects
+			var pairMembers []PairMember
+			if err := json.Unmarshal(raw, &pairMembers); err == nil && len(pairMembers) > 0 {
+				for _, pair := range pairMembers {
+					pairNames = append(pairNames, rs.formatIdentity(pair.DisplayName, pair.AccountID, pair.EmailAddress))
+				}
+				parsed = true
+			}
+
+			if !parsed {
+				// attempt single object {"displayName":"Alice"}
+				var single PairMember
+				if err := json.Unmarshal(raw, &single); err == nil && single.DisplayName != "" {
+					pairNames = append(pairNames, rs.formatIdentity(single.DisplayName, single.AccountID, single.EmailAddress))
+					parsed = true
+				}
+			}
+
+			if !parsed {
+				// attempt array of strings ["Alice","Bob"]
+				var strArr []string
+				if err := json.Unmarshal(raw, &strArr); err == nil && len(strArr) > 0 {
+					pairNames = append(pairNames, strArr...)
+					parsed = true
+				}
+			}
+
+			if !parsed {
+				// attempt single string "Alice"
+				var s string
+				if err := json.Unmarshal(raw, &s); err == nil && s != "" {
+					pairNames = append(pairNames, s)
+					parsed = true
+				}
+			}
+
+			// Only genuinely populated values count; empty arrays and blank strings are treated as absent
+			if parsed {
+				values["Pair"] = strings.TrimSpace(strings.Join(pairNames, ", "))
+			} else {
+				values["Pair"] = ""
+			}
+		} else {
+			// Field missing or null in this issue
+			values["Pair"] = ""
+		}
+	} else {
+		// No custom field configured; use literal header/placeholder
+		values["Pair"] = "Pair"
+	}
+
+	// A field Jira returned for no issue is marked rather than shown as empty or unestimated
+	for fieldID := range rs.notVisibleFields {
+		_, valueKeys := rs.requestedFieldColumns(fieldID)
+		for _, key := range valueKeys {
+			values[key] = notVisibleValue
+		}
+	}
+	return values
+}
+
+/***********************************************************************************************************************************/
+// ensureExtension appends an extension to a filename that does not already end with it, leaving an empty filename empty
+//
+// Parameters:
+//   filename  - output filename
+//   extension - file extension, including the dot
+//
+// Returns:
+//   string - filename ending in extension
+func ensureExtension(filename, extension string) string {
+	if filename != "" && !strings.HasSuffix(filename, extension) {
+		return filename + extension
+	}
+	return filename
+}
+
+/***********************************************************************************************************************************/
+// ensureTSVExtension appends ".tsv" to a filename that does not already end with it, leaving an empty filename empty
+//
+// Parameters:
+//   filename - output filename
+//
+// Returns:
+//   string - filename ending in ".tsv"
+func ensureTSVExtension(filename string) string {
+	return ensureExtension(filename, ".tsv")
+}
+
+/***********************************************************************************************************************************/
+// reportFormatExtension returns the file extension of a -format output format
+//
+// Parameters:
+//   format - key of ReportFormats; empty for the default TSV
+//
+// Returns:
+//   string - the format's extension, or ".tsv" for an empty or unknown format (which Run rejects)
+func reportFormatExtension(format string) string {
+	if reportFormat, known := ReportFormats[format]; known {
+		return reportFormat.Extension
+	}
+	return ".tsv"
+}
+
+/***********************************************************************************************************************************/
+// outputFilePath returns the path a report is written to by writeOutputFile
+//
+// Parameters:
+//   filename   - output filename, with or without the extension
+//   format     - -format of the report, a key of ReportFormats (empty for TSV)
+//   compressed - true if the file is written gzip-compressed (-compress)
+//
+// Returns:
+//   string - filename ending in the format's extension, followed by ".gz" with -compress (empty if filename is empty)
+func outputFilePath(filename, format string, compressed bool) string {
+	extension := reportFormatExtension(format)
+	if !compressed || filename == "" {
+		return ensureExtension(filename, extension)
+	}
+	return ensureExtension(strings.TrimSuffix(filename, ".gz"), extension) + ".gz"
+}
+
+/***********************************************************************************************************************************/
+// writeByteOrderMark starts a new output file with a UTF-8 byte order mark when -bom is in effect
+//
+// Only call this for a file that is being created, never when appending, so a file never holds a second BOM.
+//
+// Parameters:
+//   w - the new output file (or a writer over it), before anything else is written
+//
+// Returns:
+//   error - any error writing the byte order mark
+func (rs *runState) writeByteOrderMark(w io.Writer) error {
+	if !rs.writeBOM {
+		return nil
+	}
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return fmt.Errorf("failed to write byte order mark: %w", err)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// validateOutputPath checks an output path is usable before any Jira requests are made
+//
+// This function makes sure output failures happen in the first second of a run rather than after
+// all API work is done:
+// 1. Rejects characters that are invalid in Windows filenames and reserved device names
+// 2. Creates missing parent directories
+// 3. Verifies the file can be opened for writing (without truncating an existing file)
+//
+// Parameters:
+//   path - output file path exactly as it will be written
+//
+// Returns:
+//   error - a specific validation error, or nil if the path is usable
+//
+// Side effects:
+//   - May create parent directories
+//   - Creates and removes an empty file if the path did not already exist
+func (rs *runState) validateOutputPath(path string) error {
+	// A drive letter prefix (C:) is the only place a colon is allowed
+	checkPath := path
+	if len(checkPath) >= 2 && checkPath[1] == ':' {
+		checkPath = checkPath[2:]
+	}
+	if idx := strings.IndexAny(checkPath, "<>:\"|?*"); idx >= 0 {
+		return fmt.Errorf("output filename '%s' contains '%c', which is not allowed in Windows filenames (< > : \" | ? *)", path, checkPath[idx])
+	}
+	for _, r := range checkPath {
+		if r < 32 {
+			return fmt.Errorf("output filename '%s' contains control characters", path)
+		}
+	}
+
+	// Reserved device names cannot be used as filenames on Windows, with or without an extension
+	base := strings.ToUpper(filepath.Base(path))
+	if dot := strings.Index(base, "."); dot >= 0 {
+		base = base[:dot]
+	}
+	switch base {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return fmt.Errorf("output filename '%s' uses the reserved Windows device name %s", path, base)
+	}
+
+	// Create missing parent directories
+	if dir := filepath.Dir(path); dir != "." {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create directory '%s' for output file: %w", dir, err)
+			}
+			rs.writeLog("INFO", fmt.Sprintf("Created output directory: %s", dir))
+		}
+	}
+
+	// Verify the file is writable, removing it again if this check created it so append mode
+	// still sees a new file and writes the header
+	_, statErr := os.Stat(path)
+	existed := statErr == nil
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("output file '%s' is not writable: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close output file '%s' after writability check: %w", path, err)
+	}
+	if !existed {
+		if err := os.Remove(path); err != nil {
+			rs.writeLog("WARNING", fmt.Sprintf("Failed to remove empty file '%s' created by writability check: %v", path, err))
+		}
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// readExistingIssueKeys reads the Issue Key column of an existing output file for -dedupe
+//
+// The column is located by its "Issue Key" header so files written by older versions with
+// different column layouts are read correctly. A file without a header is assumed to use the
+// original layout, with the issue key in the second column. Subtotal rows are ignored.
+//
+// Parameters:
+//   filename - existing output file
+//
+// Returns:
+//   map[string]bool - set of issue keys already present in the file
+//   error           - any error encountered reading the file
+func readExistingIssueKeys(filename string) (map[string]bool, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file to check for duplicates: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	existingKeys := make(map[string]bool)
+	keyColumn := 1
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("failed to read output file to check for duplicates: %w", readErr)
+		}
+
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		columns := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+		isHeader := false
+		if lineNumber == 1 {
+			for i, column := range columns {
+				if strings.TrimSpace(column) == "Issue Key" {
+					keyColumn = i
+					isHeader = true
+					break
+				}
+			}
+		}
+		if !isHeader && columns[0] != "Subtotal" && keyColumn < len(columns) {
+			if key := strings.TrimSpace(columns[keyColumn]); key != "" {
+				existingKeys[key] = true
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return existingKeys, nil
+}
+
+/***********************************************************************************************************************************/
+// readInputReport rebuilds the spillover issues from a report written by an earlier run (-input)
+//
+// Columns are matched by header name, so reports with fewer optional columns, or from older versions, can be
+// read. Each row keeps its cells so it is written back unchanged, and the fields the filters and secondary
+// outputs use (story points, labels, fix versions, sprints and their dates, epic details, group) are rebuilt
+// from them. Sprints are taken in All Sprints order, the first and last getting the first and last sprint
+// dates; sprints in between take their dates from rows where they are first or last. Subtotal rows are
+// skipped; they are recalculated when written.
+//
+// Parameters:
+//   filename - path of the saved TSV report
+//
+// Returns:
+//   []MultisprintIssue  - one issue per data row, in file order
+//   map[string]EpicMeta - epic summary, status, resolution date, and current key per epic key
+//   error               - any error reading the file, or a file without an Issue Key column
+//
+// Side effects:
+//   - Switches on the optional columns present in the file (e.g., includeDescription, groupByFieldName) so they are written back
+//   - Switches on subtotals when the file has subtotal rows
+//   - Logs what could not be reconstructed: output columns the file lacks (left blank) and truncated sprint lists
+func (rs *runState) readInputReport(filename string) ([]MultisprintIssue, map[string]EpicMeta, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read input report: %w", err)
+	}
+	lines := strings.Split(strings.TrimPrefix(string(content), utf8BOM), "\n")
+	header := strings.Split(strings.TrimRight(lines[0], "\r"), "\t")
+	present := make(map[string]bool, len(header))
+	for _, column := range header {
+		present[column] = true
+	}
+	if !present["Issue Key"] {
+		return nil, nil, fmt.Errorf("%s is not a spillover report (no Issue Key column in the header row)", filename)
+	}
+
+	// Carry the file's optional columns through to the output; the Group column stands in for the group field
+	rs.includeDescription = rs.includeDescription || present["Description"]
+	rs.includeComments = rs.includeComments || present["Comments"]
+	rs.includeTime = rs.includeTime || present["Original Estimate (h)"] || present["Time Spent (h)"] || present["Aggregate Time Spent (h)"]
+	rs.includeLinks = rs.includeLinks || present["Blocked By"] || present["Blocks"]
+	rs.commitmentAnalysis = rs.commitmentAnalysis || present["Committed At Sprint Start"]
+	rs.estimateAnalysis = rs.estimateAnalysis || present["SP Changed In Flight"] || present["Original SP"]
+	rs.assigneeAnalysis = rs.assigneeAnalysis || present["Assignee Changes"] || present["Distinct Assignees"]
+	rs.escalationAnalysis = rs.escalationAnalysis || present["Escalated"]
+	rs.lateEstimateAnalysis = rs.lateEstimateAnalysis || present["Unestimated Sprints"] || present["Estimated Late"]
+	rs.timeInStatusAnalysis = rs.timeInStatusAnalysis || present["Time In Status"]
+	rs.compareEnabled = rs.compareEnabled || present["Since Last"] || present["Changes Since Last"]
+	rs.registryEnabled = rs.registryEnabled || present["First Reported"] || present["Weeks On Report"]
+	var fileStatusColumns []string // Statuses of the file's "<status> (days)" columns, rebuilt into TimeInStatus
+	for _, column := range header {
+		if status := strings.TrimSuffix(column, timeInStatusColumn("")); status != column && status != "" {
+			fileStatusColumns = append(fileStatusColumns, status)
+		}
+	}
+	if len(fileStatusColumns) > 0 {
+		rs.timeInStatusAnalysis = true
+		if len(rs.statusColumnNames) == 0 && !present["Time In Status"] {
+			rs.statusColumnNames = fileStatusColumns
+		}
+	}
+	rs.sprintLinksEnabled = rs.sprintLinksEnabled || present["First Sprint Report URL"] || present["Last Sprint Report URL"]
+	rs.instanceColumnEnabled = rs.instanceColumnEnabled || present["Instance"]
+	rs.sampleLimited = rs.sampleLimited || present["Sampled"]
+	if rs.groupByFieldName == "" && present["Group"] {
+		rs.groupByFieldName = "Group"
+	}
+
+	var multisprintIssues []MultisprintIssue
+	epics := make(map[string]EpicMeta)
+	sprintDates := make(map[string]SprintDetail) // Dated first and last sprints by name, for dating the sprints in between
+	truncatedSprintLists := 0
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		cells := strings.Split(line, "\t")
+		if cells[0] == "Subtotal" {
+			rs.subtotalsEnabled = rs.groupByFieldName != ""
+			continue
+		}
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(cells) {
+				values[column] = cells[i]
+			}
+		}
+
+		issue := Issue{Key: values["Issue Key"], Instance: values["Instance"]}
+		issue.Fields.IssueType.Name = values["Issue Type"]
+		issue.Fields.Summary = values["Summary"]
+		issue.Fields.Status.Name = values["Status"]
+		issue.Fields.Status.StatusCategory.Name = values["Status Category"]
+		if strings.EqualFold(values["Status Category"], "Done") {
+			// Only the category name is saved, and the Done filter goes by key
+			issue.Fields.Status.StatusCategory.Key = "done"
+		}
+		issue.Fields.Project.Name = values["Project"]
+		issue.Fields.Updated = rs.inputReportDate(values["Updated Date"])
+		issue.Fields.Created = rs.inputReportDate(values["Created Date"])
+		issue.Fields.ResolutionDate = rs.inputReportDate(values["Resolved Date"])
+		if dueDate := values["Due Date"]; dueDate != "" {
+			issue.Fields.DueDate = &dueDate
+		}
+		if assignee := values["Assignee"]; assignee != "" && assignee != "Unassigned" {
+			issue.Fields.Assignee = &Assignee{DisplayName: assignee}
+		}
+		if reporter := values["Reporter"]; reporter != "" && reporter != "Unknown" {
+			issue.Fields.Creator = &Creator{DisplayName: reporter}
+		}
+		for _, version := range splitInputReportList(values["Fix Versions"]) {
+			issue.Fields.FixVersions = append(issue.Fields.FixVersions, FixVersion{Name: version})
+		}
+		for _, component := range splitInputReportList(values["Components"]) {
+			issue.Fields.Components = append(issue.Fields.Components, Component{Name: component})
+		}
+		issue.Fields.Labels = splitInputReportList(values["Labels"])
+		if resolution := values["Resolution"]; resolution != "" {
+			issue.Fields.Resolution = &Resolution{Name: resolution}
+		}
+		if priority := values["Priority"]; priority != "" {
+			issue.Fields.Priority = &Priority{Name: priority}
+		}
+		// The file does not say which type each link was, so links are rebuilt as the first -linktypes type
+		for _, key := range splitInputReportList(values["Blocked By"]) {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks,
+				IssueLink{Type: IssueLinkType{Name: rs.linkTypeNames[0]}, InwardIssue: &LinkedIssue{Key: key}})
+		}
+		for _, key := range splitInputReportList(values["Blocks"]) {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks,
+				IssueLink{Type: IssueLinkType{Name: rs.linkTypeNames[0]}, OutwardIssue: &LinkedIssue{Key: key}})
+		}
+		if values["Flagged"] == "yes" {
+			issue.Fields.AdditionalFields = map[string]json.RawMessage{rs.flaggedFieldName: json.RawMessage(`[{"value":"Impediment"}]`)}
+		}
+		issue.Fields.TimeOriginalEstimate = hoursToSeconds(values["Original Estimate (h)"])
+		issue.Fields.TimeSpent = hoursToSeconds(values["Time Spent (h)"])
+		issue.Fields.AggregateTimeSpent = hoursToSeconds(values["Aggregate Time Spent (h)"])
+		if points, err := strconv.ParseFloat(values["Story Points"], 64); err == nil {
+			issue.Fields.StoryPoints = points
+		} else if points := values["Story Points"]; points != "" && points != "N/A" {
+			issue.Fields.StoryPoints = points
+		}
+
+		// Rebuild the sprint list from All Sprints, falling back to the first and last sprint when it was truncated
+		sprintCount, _ := strconv.Atoi(values["Number of Sprints"])
+		sprintInfo := SprintInfo{
+			SprintCount: sprintCount,
+			FirstSprint: values["First Sprint"],
+			FirstGoal:   values["First Sprint Goal"],
+			LastSprint:  values["Last Sprint"],
+			AllSprints:  values["All Sprints"],
+			FirstStart:  rs.inputReportSprintDate(values["First Sprint Start"]),
+			FirstEnd:    rs.inputReportSprintDate(values["First Sprint End"]),
+			LastStart:   rs.inputReportSprintDate(values["Last Sprint Start"]),
+			LastEnd:     rs.inputReportSprintDate(values["Last Sprint End"]),
+		}
+		sprintInfo.SprintNames = splitInputReportList(sprintInfo.AllSprints)
+		sprintInfo.ActiveSprints = splitInputReportList(values["Current Sprint"])
+		if len(sprintInfo.SprintNames) != sprintCount {
+			truncatedSprintLists++
+			sprintInfo.SprintNames = []string{sprintInfo.FirstSprint}
+			if sprintInfo.LastSprint != sprintInfo.FirstSprint {
+				sprintInfo.SprintNames = append(sprintInfo.SprintNames, sprintInfo.LastSprint)
+			}
+		}
+		for _, name := range sprintInfo.SprintNames {
+			sprint := SprintDetail{Name: name}
+			if name == sprintInfo.FirstSprint {
+				sprint.StartDate, sprint.EndDate, sprint.Goal = sprintInfo.FirstStart, sprintInfo.FirstEnd, sprintInfo.FirstGoal
+			} else if name == sprintInfo.LastSprint {
+				sprint.StartDate, sprint.EndDate = sprintInfo.LastStart, sprintInfo.LastEnd
+			}
+			if sprint.StartDate != nil {
+				sprintDates[name] = sprint
+			}
+			for _, active := range sprintInfo.ActiveSprints {
+				if name == active {
+					sprint.State = "active"
+				}
+			}
+			sprintInfo.Sprints = append(sprintInfo.Sprints, sprint)
+		}
+
+		multisprintIssue := MultisprintIssue{
+			Issue:                issue,
+			WorkedSprints:        sprintCount,
+			EpicLink:             values["Epic Link"],
+			SprintInfo:           sprintInfo,
+			Group:                values["Group"],
+			Staleness:            values["Staleness"],
+			Overdue:              values["Overdue"] == "yes",
+			FirstSprintReportURL: values["First Sprint Report URL"],
+			LastSprintReportURL:  values["Last Sprint Report URL"],
+			CommittedAtStart:     values["Committed At Sprint Start"],
+			EstimateChanged:      values["SP Changed In Flight"],
+			OriginalSP:           values["Original SP"],
+			AssigneeChanges:      values["Assignee Changes"],
+			Assignees:            values["Distinct Assignees"],
+			Escalated:            values["Escalated"],
+			UnestimatedSprints:   values["Unestimated Sprints"],
+			EstimatedLate:        values["Estimated Late"],
+			TimeInStatus:         values["Time In Status"],
+			SinceLast:            values["Since Last"],
+			ChangesSinceLast:     values["Changes Since Last"],
+			FirstReported:        values["First Reported"],
+			WeeksOnReport:        values["Weeks On Report"],
+			InputValues:          values,
+		}
+		if multisprintIssue.TimeInStatus == "" && len(fileStatusColumns) > 0 {
+			parts := make([]string, 0, len(fileStatusColumns))
+			for _, status := range fileStatusColumns {
+				days := values[timeInStatusColumn(status)]
+				if days == "unknown" {
+					parts = nil
+					multisprintIssue.TimeInStatus = "unknown"
+					break
+				}
+				parts = append(parts, status+"="+days)
+			}
+			if parts != nil {
+				multisprintIssue.TimeInStatus = strings.Join(parts, ";")
+			}
+		}
+		if !present["Churn Score"] {
+			values["Churn Score"] = FormatChurnScore(multisprintIssue)
+		}
+		if !present["Spillover Score"] {
+			values["Spillover Score"] = rs.formatSpilloverScore(multisprintIssue)
+		}
+		if !present["In Active Sprint"] && present["Current Sprint"] {
+			values["In Active Sprint"] = "no"
+			if len(sprintInfo.ActiveSprints) > 0 {
+				values["In Active Sprint"] = "yes"
+			}
+		}
+		if multisprintIssue.EpicLink == "" {
+			multisprintIssue.EpicLink = "No Epic"
+		}
+		if issue.Fields.ResolutionDate != nil {
+			if resolvedTime, ok := rs.parseJiraTime(*issue.Fields.ResolutionDate); ok {
+				multisprintIssue.ResolvedDate = &resolvedTime
+			}
+		}
+		if _, seen := epics[multisprintIssue.EpicLink]; !seen && multisprintIssue.EpicLink != "No Epic" {
+			epic := EpicMeta{
+				Summary:        values["Epic Summary"],
+				Status:         values["Epic Status"],
+				ResolutionDate: rs.inputReportDate(values["Epic Resolved"]),
+				CurrentKey:     values["Epic Key (current)"],
+			}
+			if epic.Summary == "No Epic Summary" {
+				epic.Summary = ""
+			}
+			epics[multisprintIssue.EpicLink] = epic
+		}
+		multisprintIssues = append(multisprintIssues, multisprintIssue)
+	}
+
+	// Date the sprints in the middle of each issue's list from rows where they were first or last
+	for i := range multisprintIssues {
+		for j, sprint := range multisprintIssues[i].SprintInfo.Sprints {
+			if dated, ok := sprintDates[sprint.Name]; ok && sprint.StartDate == nil {
+				multisprintIssues[i].SprintInfo.Sprints[j].StartDate = dated.StartDate
+				multisprintIssues[i].SprintInfo.Sprints[j].EndDate = dated.EndDate
+			}
+		}
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Read %d spillover issues from %s; Jira will not be queried", len(multisprintIssues), filename))
+	var notRebuilt []string
+	var missingColumns []string
+	for _, column := range rs.outputHeader() {
+		// Churn Score and Spillover Score are recomputed from Story Points, Number of Sprints and Created Date, In Active Sprint
+		// from Current Sprint, and the split group from the sprints or resolution date
+		if !present[column] && column != "Churn Score" && column != "Spillover Score" && column != splitGroupColumn &&
+			(column != "In Active Sprint" || !present["Current Sprint"]) {
+			missingColumns = append(missingColumns, column)
+		}
+	}
+	if len(missingColumns) > 0 {
+		notRebuilt = append(notRebuilt, fmt.Sprintf("columns %s (left blank)", strings.Join(missingColumns, ", ")))
+	}
+	if truncatedSprintLists > 0 {
+		notRebuilt = append(notRebuilt, fmt.Sprintf("sprints between the first and last of %d issues (All Sprints truncated)", truncatedSprintLists))
+	}
+	if len(notRebuilt) > 0 {
+		rs.writeLog("WARNING", fmt.Sprintf("Could not reconstruct from %s: %s", filename, strings.Join(notRebuilt, "; ")))
+	}
+	return multisprintIssues, epics, nil
+}
+
+/***********************************************************************************************************************************/
+// inputReportDate converts a yyyy-MM-dd report date back to a Jira timestamp at midnight in the report timezone
+//
+// Parameters:
+//   value - date cell from a saved report
+//
+// Returns:
+//   *string - timestamp that formats back to the same date, or nil if the cell is blank or not a date
+func (rs *runState) inputReportDate(value string) *string {
+	date := rs.inputReportSprintDate(value)
+	if date == nil {
+		return nil
+	}
+	timestamp := date.Format("2006-01-02T15:04:05.000-0700")
+	return &timestamp
+}
+
+/***********************************************************************************************************************************/
+// inputReportSprintDate parses a yyyy-MM-dd report date as midnight in the report timezone
+//
+// Parameters:
+//   value - date cell from a saved report
+//
+// Returns:
+//   *time.Time - parsed date, or nil if the cell is blank or not a date
+func (rs *runState) inputReportSprintDate(value string) *time.Time {
+	date, err := time.ParseInLocation("2006-01-02", value, rs.reportLocation)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+/***********************************************************************************************************************************/
+// splitInputReportList splits a ", "-separated report cell (e.g., Labels, All Sprints) into its values
+//
+// Parameters:
+//   value - list cell from a saved report
+//
+// Returns:
+//   []string - the values, or nil if the cell is blank
+func splitInputReportList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ", ")
+}
+
+/***********************************************************************************************************************************/
+// prepareAppendFile checks an existing output file can be appended to without corrupting it
+//
+// The file's header must be the header this run writes: appending rows of a different column layout (e.g. after
+// changing -includetime or -pair) would misalign every new row, so a changed header is refused. A run killed
+// while writing can leave the file without a trailing newline, and the next appended row would be glued onto its
+// last line. An unterminated last line with fewer columns than the header is a partial row, which is truncated
+// with repair or otherwise refused; any other unterminated last line only needs its newline. A file that ends in
+// a newline is complete, whatever its rows hold.
+//
+// Parameters:
+//   filename - output file to be appended to
+//   header   - column names this run writes (see outputHeader)
+//   repair   - if true, truncate a partial last line instead of returning an error (-repair)
+//
+// Returns:
+//   bool  - true if the file is missing or empty, so the header must be written
+//   error - any error reading or fixing the file, a changed header, or the partial row's location when repair is false
+//
+// Side effects:
+//   - Appends a newline to a file whose complete last line lacks one, with a WARNING
+//   - With repair, truncates the file at the start of a partial last line, with a WARNING
+func (rs *runState) prepareAppendFile(filename string, header []string, repair bool) (bool, error) {
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read output file before appending: %w", err)
+	}
+	if len(strings.TrimSpace(strings.TrimPrefix(string(content), utf8BOM))) == 0 {
+		// An empty file (e.g. created by a run that failed before writing) gets a header rather than headerless rows
+		return true, nil
+	}
+
+	text := string(content)
+	terminated := strings.HasSuffix(text, "\n")
+	expectedHeader := strings.Join(header, "\t")
+	firstLine, _, _ := strings.Cut(strings.TrimPrefix(text, utf8BOM), "\n")
+	firstLine = strings.TrimRight(firstLine, "\r")
+
+	// An unterminated first line that starts the header is a header cut short, not a different layout
+	if !terminated && !strings.Contains(text, "\n") && firstLine != expectedHeader && strings.HasPrefix(expectedHeader, firstLine) {
+		if !repair {
+			return false, fmt.Errorf("cannot append to %s: it holds only part of a header, left by an interrupted run; use -repair to remove it, or delete the file", filename)
+		}
+		if err := os.Truncate(filename, 0); err != nil {
+			return false, fmt.Errorf("failed to remove partial header from %s: %w", filename, err)
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("Removed a partial header (%d bytes) from %s (-repair)", len(text), filename))
+		return true, nil
+	}
+
+	if firstLine != expectedHeader {
+		existing := strings.Split(firstLine, "\t")
+		difference := fmt.Sprintf("the file has %d columns and this run writes %d", len(existing), len(header))
+		for i := 0; i < min(len(existing), len(header)); i++ {
+			if existing[i] != header[i] {
+				difference = fmt.Sprintf("column %d is %q in the file but %q in this run", i+1, existing[i], header[i])
+				break
+			}
+		}
+		return false, fmt.Errorf("cannot append to %s: its column layout differs from this run's (%s), as the report options or program version changed; append with the options that created it, or write to a new file",
+			filename, difference)
+	}
+	if terminated {
+		return false, nil
+	}
+
+	// The unterminated last line: a partial row if it has fewer columns than the header
+	lastStart := strings.LastIndexByte(text, '\n') + 1
+	lastLine := strings.TrimRight(strings.TrimPrefix(text[lastStart:], utf8BOM), "\r")
+	columns := len(strings.Split(lastLine, "\t"))
+	if strings.TrimSpace(lastLine) != "" && !strings.HasPrefix(lastLine, "#") && columns < len(header) {
+		if !repair {
+			return false, fmt.Errorf("cannot append to %s: its last line, at byte offset %d, has %d columns instead of %d and no newline, so it is a partial row left by an interrupted run; use -repair to remove it, or fix the file by hand",
+				filename, lastStart, columns, len(header))
+		}
+		if err := os.Truncate(filename, int64(lastStart)); err != nil {
+			return false, fmt.Errorf("failed to remove partial last row from %s: %w", filename, err)
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("Removed a partial last row (%d of %d columns, %d bytes from byte offset %d) from %s (-repair)",
+			columns, len(header), len(text)-lastStart, lastStart, filename))
+		return false, nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to add missing newline to %s: %w", filename, err)
+	}
+	_, err = file.WriteString("\n")
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to add missing newline to %s: %w", filename, err)
+	}
+	rs.writeLog("WARNING", fmt.Sprintf("%s did not end with a newline (a previous run may have been interrupted); one was added before appending", filename))
+	return false, nil
+}
+
+/***********************************************************************************************************************************/
+// countOutputDataRows counts the issue rows in an output file
+//
+// Header rows (any row with an "Issue Key" column heading), subtotal rows, comment lines starting
+// with #, and blank lines are not counted.
+//
+// Parameters:
+//   filename - path of the output file
+//
+// Returns:
+//   int   - number of issue rows
+//   error - any error reading the file
+func countOutputDataRows(filename string) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output file for verification: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	var source io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read output file for verification: %w", err)
+		}
+		source = gzipReader
+	}
+
+	rowCount := 0
+	reader := bufio.NewReader(source)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return 0, fmt.Errorf("failed to read output file for verification: %w", readErr)
+		}
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "Subtotal\t") {
+			isHeader := false
+			for _, column := range strings.Split(line, "\t") {
+				if column == "Issue Key" {
+					isHeader = true
+					break
+				}
+			}
+			if !isHeader {
+				rowCount++
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return rowCount, nil
+}
+
+/***********************************************************************************************************************************/
+// isFileLockedError reports whether err is a Windows sharing or lock violation, the error returned
+// when another program (typically Excel) has the file open
+//
+// Parameters:
+//   err - error returned by a file operation
+//
+// Returns:
+//   bool - true if the file is locked by another process
+func isFileLockedError(err error) bool {
+	if runtime.GOOS != "windows" {
+		return false
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	// ERROR_SHARING_VIOLATION (32) and ERROR_LOCK_VIOLATION (33)
+	return errno == 32 || errno == 33
+}
+
+/***********************************************************************************************************************************/
+// outputFileExtension returns an output file's extension, including ".gz" for a compressed file
+//
+// Parameters:
+//   filename - output file path
+//
+// Returns:
+//   string - e.g. ".tsv" or ".tsv.gz"
+func outputFileExtension(filename string) string {
+	extension := filepath.Ext(strings.TrimSuffix(filename, ".gz"))
+	if strings.HasSuffix(filename, ".gz") {
+		extension += ".gz"
+	}
+	return extension
+}
+
+/***********************************************************************************************************************************/
+// createTemporaryOutputFile creates the temporary file a new output file is written to before it is renamed into place
+//
+// The file is created beside the output file, so the rename does not cross filesystems, and keeps its
+// extension, so a compressed file is still recognised as one when it is verified.
+//
+// Parameters:
+//   filename - output file path the temporary file will replace
+//
+// Returns:
+//   *os.File - the open temporary file, e.g. .spillover_rpt-123456.tsv
+//   error    - any error creating the file
+func createTemporaryOutputFile(filename string) (*os.File, error) {
+	extension := outputFileExtension(filename)
+	stem := strings.TrimSuffix(filepath.Base(filename), extension)
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+stem+"-*"+extension)
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp makes the file readable by its owner only; the output file is readable by everyone as before
+	if err := file.Chmod(0644); err != nil && runtime.GOOS != "windows" {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+/***********************************************************************************************************************************/
+// replaceOutputFile renames a completed temporary file over its output file
+//
+// A file another program holds open cannot be replaced on Windows, so the output file is first opened as
+// openOutputFile opens it: a locked file is waited for, then replaced by the timestamped substitute
+// openOutputFile falls back to.
+//
+// Parameters:
+//   temporary - path of the completed temporary file
+//   filename  - output file path
+//
+// Returns:
+//   string - the path the file now has, which differs from filename when the substitute was used
+//   error  - any error waiting for the output file or renaming the temporary file
+func (rs *runState) replaceOutputFile(temporary, filename string) (string, error) {
+	target := filename
+	existing, opened, err := rs.openOutputFile(filename, os.O_WRONLY)
+	switch {
+	case err == nil:
+		if err := existing.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+		target = opened
+	case !errors.Is(err, os.ErrNotExist):
+		return filename, fmt.Errorf("failed to replace output file: %w", err)
+	}
+	if err := os.Rename(temporary, target); err != nil {
+		return filename, fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return target, nil
+}
+
+/***********************************************************************************************************************************/
+// openOutputFile opens an output file, waiting for another program to release it if it is locked
+//
+// Excel holds an open workbook's file locked, so a scheduled run would otherwise be lost whenever
+// someone has last week's report open. The open is retried outputLockRetries times; if the file is
+// still locked, a substitute named NAME-YYYYMMDD-HHMMSS.tsv next to it is opened instead, unless
+// -nolockfallback was given.
+//
+// Parameters:
+//   filename - output file path, including the .tsv extension
+//   flag     - os.OpenFile flags
+//
+// Returns:
+//   *os.File - the opened file
+//   string   - the path opened, which differs from filename when the substitute was used
+//   error    - any error opening the file
+//
+// Side effects:
+//   - Sleeps between attempts while the file is locked
+//   - Logs a WARNING naming the substitute file when one is used
+func (rs *runState) openOutputFile(filename string, flag int) (*os.File, string, error) {
+	file, err := os.OpenFile(filename, flag, 0644)
+	for attempt := 1; err != nil && isFileLockedError(err) && attempt < outputLockRetries; attempt++ {
+		rs.writeLog("INFO", fmt.Sprintf("Output file %s is locked by another program, retrying in %v (attempt %d of %d)",
+			filename, outputLockRetryDelay, attempt+1, outputLockRetries))
+		time.Sleep(outputLockRetryDelay)
+		file, err = os.OpenFile(filename, flag, 0644)
+	}
+	if err == nil || !isFileLockedError(err) {
+		return file, filename, err
+	}
+	if !rs.lockFallbackEnabled {
+		return nil, filename, fmt.Errorf("%s is locked by another program (close it, e.g. in Excel, and run again): %w", filename, err)
+	}
+
+	extension := outputFileExtension(filename)
+	substitute := strings.TrimSuffix(filename, extension) + "-" + time.Now().In(rs.reportLocation).Format("20060102-150405") + extension
+	file, err = os.OpenFile(substitute, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, filename, fmt.Errorf("%s is locked by another program and the substitute %s could not be created: %w", filename, substitute, err)
+	}
+	rs.writeLog("WARNING", fmt.Sprintf("*** Output file %s is locked by another program, results written to %s instead ***", filename, substitute))
+	return file, substitute, nil
+}
+
+// tsvReportWriter writes rows as tab-separated values, one line per row.
+type tsvReportWriter struct {
+	writer *bufio.Writer
+}
+
+/***********************************************************************************************************************************/
+// newTSVReportWriter creates the ReportWriter for -format tsv
+//
+// Parameters:
+//   w - destination, normally the output file
+//
+// Returns:
+//   ReportWriter - buffered tab-separated writer
+func newTSVReportWriter(w io.Writer) ReportWriter {
+	return &tsvReportWriter{writer: bufio.NewWriter(w)}
+}
+
+/***********************************************************************************************************************************/
+// WriteHeader writes the column names as the first line
+//
+// Parameters:
+//   columns - column names in output order
+//
+// Returns:
+//   error - any error writing to the buffer
+func (t *tsvReportWriter) WriteHeader(columns []string) error {
+	_, err := t.writer.WriteString(strings.Join(columns, "\t") + "\n")
+	return err
+}
+
+/***********************************************************************************************************************************/
+// WriteRow writes one row's values as a line; subtotal rows are written like any other row
+//
+// Parameters:
+//   record - row to write (values must already be free of tabs and line breaks)
+//
+// Returns:
+//   error - any error writing to the buffer
+func (t *tsvReportWriter) WriteRow(record ReportRecord) error {
+	_, err := t.writer.WriteString(strings.Join(record.Values, "\t") + "\n")
+	return err
+}
+
+/***********************************************************************************************************************************/
+// Close flushes the buffered lines to the underlying writer
+//
+// Returns:
+//   error - any error flushing the buffer
+func (t *tsvReportWriter) Close() error {
+	return t.writer.Flush()
+}
+
+/***********************************************************************************************************************************/
+// Flush writes the buffered lines to the file
+//
+// Returns:
+//   error - any error flushing the buffer
+func (t *tsvReportWriter) Flush() error {
+	return t.writer.Flush()
+}
+
+/***********************************************************************************************************************************/
+// SupportsAppend reports that TSV rows can be added to an existing file (-append)
+//
+// Returns:
+//   bool - always true
+func (t *tsvReportWriter) SupportsAppend() bool {
+	return true
+}
+
+/***********************************************************************************************************************************/
+// flushProgress writes buffered rows to a file every flushEvery rows so it can be followed while it is written
+//
+// tail -f and PowerShell's Get-Content -Wait only see what has reached the file, and a buffered writer
+// otherwise holds rows back until the end. The file is also synced to disk, at most every outputSyncInterval.
+//
+// Parameters:
+//   flush    - flushes the writer's buffer to the file
+//   file     - the file being written
+//   rows     - data rows written so far
+//   lastSync - when the file was last synced; updated when it is synced again
+//
+// Returns:
+//   error - any error flushing the buffer
+func (rs *runState) flushProgress(flush func() error, file *os.File, rows int, lastSync *time.Time) error {
+	if rs.flushEvery <= 0 || rows%rs.flushEvery != 0 {
+		return nil
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if time.Since(*lastSync) >= outputSyncInterval {
+		// A failed sync only delays when the rows are on disk; the final sync and close report real failures
+		_ = file.Sync()
+		*lastSync = time.Now()
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// ReportFormatNames lists the registered output formats for usage and error messages
+//
+// Returns:
+//   []string - sorted format names
+func ReportFormatNames() []string {
+	names := make([]string, 0, len(ReportFormats))
+	for name := range ReportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/***********************************************************************************************************************************/
+// countPairFieldFound counts the issues with a non-empty Pair value
+//
+// A Pair field Jira returned for no issue is marked as not visible in every row; those markers are not values.
+//
+// Parameters:
+//   multisprintIssues - slice of issues that span multiple sprints
+//
+// Returns:
+//   int - number of issues with a non-empty Pair value (0 when no -pair field was given)
+func (rs *runState) countPairFieldFound(multisprintIssues []MultisprintIssue) int {
+	if !rs.pairFieldProvided || rs.pairFieldName == "" {
+		return 0
+	}
+	foundCount := 0
+	for _, multisprintIssue := range multisprintIssues {
+		pairValue := rs.extractFieldValues(multisprintIssue.Issue)["Pair"]
+		// Debug: log the Pair value for each issue
+		if rs.debug {
+			rs.writeLog("DEBUG", fmt.Sprintf("countPairFieldFound: Issue %s Pair value: '%s'", multisprintIssue.Issue.Key, pairValue))
+		}
+		if strings.TrimSpace(pairValue) != "" && pairValue != notVisibleValue {
+			foundCount++
+		}
+	}
+	return foundCount
+}
+
+/***********************************************************************************************************************************/
+// outputHeader returns the output file's column names: the fixed columns, then the optional columns switched on for this run
+//
+// Returns:
+//   []string - column names in output order
+func (rs *runState) outputHeader() []string {
+	header := []string{
+		"Issue Type",
+		"Issue Key",
+		"Summary",
+		"Status",
+		"Updated Date",
+		"Created Date",
+		"Resolved Date",
+		"Assignee",
+		"Pair",
+		"Project",
+		"Fix Versions",
+		"Components",
+		"Story Points",
+		"Epic Link",
+		"Epic Summary",
+		"Labels",
+		"Resolution",
+		"Reporter",
+		"Number of Sprints",
+		"First Sprint",
+		"Last Sprint",
+		"All Sprints",
+		"Staleness",
+		"Priority",
+		"Due Date",
+		"Overdue",
+		"First Sprint Goal",
+		"Epic Status",
+		"Epic Resolved",
+		"Epic Key (current)",
+		"First Sprint Start",
+		"First Sprint End",
+		"Last Sprint Start",
+		"Last Sprint End",
+		"Status Category",
+		"Flagged",
+		"Churn Score",
+		"Current Sprint",
+		"In Active Sprint",
+		"Spillover Score",
+	}
+	if rs.includeDescription {
+		header = append(header, "Description")
+	}
+	if rs.includeComments {
+		header = append(header, "Comments")
+	}
+	if rs.includeTime {
+		header = append(header, "Original Estimate (h)", "Time Spent (h)", "Aggregate Time Spent (h)")
+	}
+	if rs.includeLinks {
+		header = append(header, "Blocked By", "Blocks")
+	}
+	if rs.commitmentAnalysis {
+		header = append(header, "Committed At Sprint Start")
+	}
+	if rs.estimateAnalysis {
+		header = append(header, "SP Changed In Flight", "Original SP")
+	}
+	if rs.assigneeAnalysis {
+		header = append(header, "Assignee Changes", "Distinct Assignees")
+	}
+	if rs.escalationAnalysis {
+		header = append(header, "Escalated")
+	}
+	if rs.lateEstimateAnalysis {
+		header = append(header, "Unestimated Sprints", "Estimated Late")
+	}
+	if rs.timeInStatusAnalysis {
+		if len(rs.statusColumnNames) == 0 {
+			header = append(header, "Time In Status")
+		}
+		for _, status := range rs.statusColumnNames {
+			header = append(header, timeInStatusColumn(status))
+		}
+	}
+	if rs.compareEnabled {
+		header = append(header, "Since Last", "Changes Since Last")
+	}
+	if rs.registryEnabled {
+		header = append(header, "First Reported", "Weeks On Report")
+	}
+	if rs.sprintLinksEnabled {
+		header = append(header, "First Sprint Report URL", "Last Sprint Report URL")
+	}
+	if rs.sampleLimited {
+		header = append(header, "Sampled")
+	}
+	if rs.instanceColumnEnabled {
+		header = append(header, "Instance")
+	}
+	if rs.splitBy != "" {
+		header = append(header, splitGroupColumn)
+	}
+	if rs.groupByFieldName != "" {
+		header = append(header, "Group")
+	}
+	return header
+}
+
+/***********************************************************************************************************************************/
+// writeOutputFile writes the spillover issues to the output file, or to one file per group with -splitby
+//
+// With -splitby each group's issues go to the output filename with the group appended (spillover_rpt-Sprint_42.tsv),
+// each file with its own header and, with -append, appended to that group's file. Groups with no issues get no file.
+//
+// Parameters:
+//   filename          - output filename
+//   multisprintIssues - slice of issues that span multiple sprints
+//   epics             - map of epic keys to epic summary, status, and resolution date
+//   appendMode        - if true, append to existing files; if false, create new files
+//
+// Returns:
+//   []WrittenOutputFile - the files written with their row counts, in file name order when split
+//   error               - any error encountered during file writing
+//
+// Side effects:
+//   - Sets each issue's SplitGroup with -splitby
+func (rs *runState) writeOutputFile(filename string, multisprintIssues []MultisprintIssue, epics map[string]EpicMeta, appendMode bool) ([]WrittenOutputFile, error) {
+	if rs.splitBy == "" {
+		written, rows, err := rs.writeReportFile(filename, multisprintIssues, epics, appendMode)
+		return []WrittenOutputFile{{Path: written, Rows: rows}}, err
+	}
+
+	// Group by file name, so groups whose names differ only in characters unsafe in filenames share a file
+	groups := make(map[string][]MultisprintIssue)
+	var names []string
+	for i := range multisprintIssues {
+		group := rs.splitGroupValue(multisprintIssues[i])
+		multisprintIssues[i].SplitGroup = group
+		// Rows read from a saved report (-input) are written from their cells, so the group is set there too
+		if multisprintIssues[i].InputValues != nil {
+			multisprintIssues[i].InputValues[splitGroupColumn] = group
+		}
+		name := sanitizeFileNamePart(group)
+		if _, seen := groups[name]; !seen {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], multisprintIssues[i])
+	}
+	sort.Strings(names)
+
+	files := make([]WrittenOutputFile, 0, len(names))
+	for _, name := range names {
+		written, rows, err := rs.writeReportFile(rs.splitOutputFileName(filename, name), groups[name], epics, appendMode)
+		files = append(files, WrittenOutputFile{Group: groups[name][0].SplitGroup, Path: written, Rows: rows})
+		if err != nil {
+			return files, err
+		}
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Split %d issues into %d files by %s (-splitby)", len(multisprintIssues), len(files), rs.splitBy))
+	return files, nil
+}
+
+/***********************************************************************************************************************************/
+// splitGroupValue returns the -splitby group of a spillover issue
+//
+// Parameters:
+//   multisprintIssue - spillover issue
+//
+// Returns:
+//   string - the last sprint's name ("No Sprint" if unknown) with lastsprint, or the resolution month (yyyy-mm,
+//            "Unresolved" if not resolved) in the report timezone with month
+func (rs *runState) splitGroupValue(multisprintIssue MultisprintIssue) string {
+	if rs.splitBy == "month" {
+		resolved := rs.formatDate(multisprintIssue.Issue.Key, multisprintIssue.Issue.Fields.ResolutionDate)
+		if len(resolved) < len("2006-01") {
+			return "Unresolved"
+		}
+		return resolved[:len("2006-01")]
+	}
+	if multisprintIssue.SprintInfo.LastSprint == "" {
+		return "No Sprint"
+	}
+	return multisprintIssue.SprintInfo.LastSprint
+}
+
+/***********************************************************************************************************************************/
+// sanitizeFileNamePart makes a group name safe to use in a filename on every platform
+//
+// Letters, digits, '-', '_' and '.' are kept and anything else becomes '_'. Leading and trailing dots and
+// underscores are removed, as Windows does not allow a name to end in a dot.
+//
+// Parameters:
+//   name - group name
+//
+// Returns:
+//   string - filename-safe name ("none" if nothing usable is left)
+func sanitizeFileNamePart(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	safe = strings.Trim(safe, "._")
+	if safe == "" {
+		return "none"
+	}
+	return safe
+}
+
+/***********************************************************************************************************************************/
+// splitOutputFileName returns the output filename for one -splitby group
+//
+// Parameters:
+//   filename - output filename, with or without the extension
+//   name     - filename-safe group name from sanitizeFileNamePart
+//
+// Returns:
+//   string - filename with "-name" added before the extension (added again by outputFilePath)
+func (rs *runState) splitOutputFileName(filename, name string) string {
+	base := strings.TrimSuffix(strings.TrimSuffix(filename, ".gz"), reportFormatExtension(rs.outputFormat))
+	return base + "-" + name
+}
+
+/***********************************************************************************************************************************/
+// writeReportFile writes the spillover issues to one file in the -format output format
+//
+// With -compress the file is written gzip-compressed, with ".gz" appended to its name (see outputFilePath).
+// A new file is written to a temporary file beside it, which is renamed over it once it is complete and
+// verified, so a failed run leaves the previous file as it was; with -noatomic, or when appending, the
+// file is written in place so it can be followed while it is written.
+//
+// Parameters:
+//   filename        - output filename
+//   multisprintIssues - slice of issues that span multiple sprints
+//   epics           - map of epic keys to epic summary, status, and resolution date
+//   appendMode      - if true, append to existing file; if false, create new file
+//
+// Returns:
+//   string - the file written, which is a timestamped substitute if filename was locked
+//   int    - issues written (fewer than given when -dedupe skipped some)
+//   error  - any error encountered during file writing
+func (rs *runState) writeReportFile(filename string, multisprintIssues []MultisprintIssue, epics map[string]EpicMeta, appendMode bool) (string, int, error) {
+	// Ensure filename has the format's extension (and .gz with -compress)
+	filename = outputFilePath(filename, rs.outputFormat, rs.compressOutput)
+
+	var file *os.File
+	var err error
+	var writeHeader bool
+	temporary := "" // Temporary file renamed over filename when complete, empty when writing in place
+
+	// Build header row
+	header := rs.outputHeader()
+	groupColumn := -1
+	if rs.groupByFieldName != "" {
+		groupColumn = len(header) - 1
+	}
+
+	if appendMode {
+		// A missing or empty file needs a header; an existing one must have this run's header and end in a complete row
+		if writeHeader, err = rs.prepareAppendFile(filename, header, rs.repairEnabled); err != nil {
+			return filename, 0, err
+		}
+
+		// Skip issues already present in the file (-dedupe)
+		if rs.dedupeEnabled && !writeHeader {
+			existingKeys, err := readExistingIssueKeys(filename)
+			if err != nil {
+				return filename, 0, err
+			}
+			newIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+			for _, multisprintIssue := range multisprintIssues {
+				if !existingKeys[multisprintIssue.Issue.Key] {
+					newIssues = append(newIssues, multisprintIssue)
+				}
+			}
+			rs.writeLog("INFO", fmt.Sprintf("Skipped %d duplicate issues already present in output", len(multisprintIssues)-len(newIssues)))
+			multisprintIssues = newIssues
+		}
+
+		// Open file in append mode
+		requested := filename
+		file, filename, err = rs.openOutputFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+		if err != nil {
+			return filename, 0, fmt.Errorf("failed to open output file for append: %w", err)
+		}
+		if filename != requested {
+			// The substitute is a new file, so it needs its own header
+			writeHeader = true
+			appendMode = false
+			rs.writeLog("INFO", fmt.Sprintf("Creating new file: %s", filename))
+		} else {
+			rs.writeLog("INFO", fmt.Sprintf("Appending to existing file: %s", filename))
+		}
+	} else if rs.atomicOutput {
+		// Create a temporary file, renamed over the existing one once complete
+		if file, err = createTemporaryOutputFile(filename); err != nil {
+			return filename, 0, fmt.Errorf("failed to create output file: %w", err)
+		}
+		temporary = file.Name()
+		writeHeader = true
+		rs.writeLog("INFO", fmt.Sprintf("Creating new file: %s (written to %s until complete)", filename, temporary))
+	} else {
+		// Create new file (overwrites existing)
+		file, filename, err = rs.openOutputFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return filename, 0, fmt.Errorf("failed to create output file: %w", err)
+		}
+		writeHeader = true
+		rs.writeLog("INFO", fmt.Sprintf("Creating new file: %s", filename))
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			if err := file.Close(); err != nil {
+				log.Printf("failed to close file: %v", err)
+			}
+		}
+		// Left over only if the file could not be completed; the previous output file is untouched
+		if temporary != "" {
+			if err := os.Remove(temporary); err != nil {
+				log.Printf("failed to remove temporary file: %v", err)
+			}
+		}
+	}()
+
+	// Count the rows already in an appended file so only this run's rows are verified afterwards
+	existingRows := 0
+	if rs.verifyOutputEnabled && appendMode {
+		if existingRows, err = countOutputDataRows(filename); err != nil {
+			return filename, 0, err
+		}
+	}
+
+	// Rows go through a gzip stream with -compress, counting the uncompressed size for the log
+	var destination io.Writer = file
+	var gzipWriter *gzip.Writer
+	var uncompressedBytes int64
+	flush := func() error { return nil }
+	if rs.compressOutput {
+		gzipWriter = gzip.NewWriter(file)
+		destination = &countingWriter{writer: gzipWriter, count: &uncompressedBytes}
+		flush = gzipWriter.Flush
+	}
+
+	// Write header row only if needed (new file or append to empty file)
+	writer := ReportFormats[rs.outputFormat].NewWriter(destination)
+	flushRows := func() error {
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		return flush()
+	}
+	if writeHeader {
+		if err := rs.writeByteOrderMark(destination); err != nil {
+			return filename, 0, err
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return filename, 0, fmt.Errorf("failed to write header: %w", err)
+		}
+		// Show the header at once to anyone following the file
+		if err := flushRows(); err != nil {
+			return filename, 0, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+	lastSync := time.Now()
+
+	// Write data rows
+	groupIssueCount := 0
+	groupStoryPoints := 0.0
+	for i, multisprintIssue := range multisprintIssues {
+		issue := multisprintIssue.Issue
+		values := rs.extractFieldValues(issue)
+		// Get epic summary, status, and resolution date
+		epic := epics[multisprintIssue.EpicLink]
+		epicTitle := epic.Summary
+		if epicTitle == "" {
+			epicTitle = "No Epic Summary"
+		}
+		overdueValue := "no"
+		if multisprintIssue.Overdue {
+			overdueValue = "yes"
+		}
+		inActiveSprintValue := "no"
+		if len(multisprintIssue.SprintInfo.ActiveSprints) > 0 {
+			inActiveSprintValue = "yes"
+		}
+		// Build row data
+		row := []string{
+			values["IssueType"],
+			issue.Key,
+			sanitizeCellValue(issue.Fields.Summary),
+			values["Status"],
+			values["UpdatedDate"],
+			values["CreatedDate"],
+			values["ResolvedDate"],
+			values["Assignee"],
+			values["Pair"],
+			values["ProjectName"],
+			values["FixVersions"],
+			values["Components"],
+			values["StoryPoints"],
+			multisprintIssue.EpicLink,
+			epicTitle,
+			values["Labels"],
+			values["Resolution"],
+			values["Reporter"],
+			strconv.Itoa(multisprintIssue.SprintInfo.SprintCount),
+			multisprintIssue.SprintInfo.FirstSprint,
+			multisprintIssue.SprintInfo.LastSprint,
+			truncateWithEllipsis(multisprintIssue.SprintInfo.AllSprints, rs.allSprintsMaxLength),
+			multisprintIssue.Staleness,
+			values["Priority"],
+			values["DueDate"],
+			overdueValue,
+			sanitizeCellValue(multisprintIssue.SprintInfo.FirstGoal),
+			epic.Status,
+			rs.formatDate(multisprintIssue.EpicLink, epic.ResolutionDate),
+			epic.CurrentKey,
+			rs.formatSprintDate(multisprintIssue.SprintInfo.FirstStart),
+			rs.formatSprintDate(multisprintIssue.SprintInfo.FirstEnd),
+			rs.formatSprintDate(multisprintIssue.SprintInfo.LastStart),
+			rs.formatSprintDate(multisprintIssue.SprintInfo.LastEnd),
+			values["StatusCategory"],
+			values["Flagged"],
+			FormatChurnScore(multisprintIssue),
+			strings.Join(multisprintIssue.SprintInfo.ActiveSprints, ", "),
+			inActiveSprintValue,
+			rs.formatSpilloverScore(multisprintIssue),
+		}
+		if rs.includeDescription {
+			row = append(row, values["Description"])
+		}
+		if rs.includeComments {
+			row = append(row, values["Comments"])
+		}
+		if rs.includeTime {
+			row = append(row, values["OriginalEstimate"], values["TimeSpent"], values["AggregateTimeSpent"])
+		}
+		if rs.includeLinks {
+			row = append(row, values["BlockedBy"], values["Blocks"])
+		}
+		if rs.commitmentAnalysis {
+			row = append(row, multisprintIssue.CommittedAtStart)
+		}
+		if rs.estimateAnalysis {
+			row = append(row, multisprintIssue.EstimateChanged, multisprintIssue.OriginalSP)
+		}
+		if rs.assigneeAnalysis {
+			row = append(row, multisprintIssue.AssigneeChanges, multisprintIssue.Assignees)
+		}
+		if rs.escalationAnalysis {
+			row = append(row, multisprintIssue.Escalated)
+		}
+		if rs.lateEstimateAnalysis {
+			row = append(row, multisprintIssue.UnestimatedSprints, multisprintIssue.EstimatedLate)
+		}
+		if rs.timeInStatusAnalysis {
+			if len(rs.statusColumnNames) == 0 {
+				row = append(row, multisprintIssue.TimeInStatus)
+			}
+			for _, status := range rs.statusColumnNames {
+				row = append(row, statusColumnValue(multisprintIssue.TimeInStatus, status))
+			}
+		}
+		if rs.compareEnabled {
+			row = append(row, multisprintIssue.SinceLast, multisprintIssue.ChangesSinceLast)
+		}
+		if rs.registryEnabled {
+			row = append(row, multisprintIssue.FirstReported, multisprintIssue.WeeksOnReport)
+		}
+		if rs.sprintLinksEnabled {
+			row = append(row, multisprintIssue.FirstSprintReportURL, multisprintIssue.LastSprintReportURL)
+		}
+		if rs.sampleLimited {
+			row = append(row, "yes")
+		}
+		if rs.instanceColumnEnabled {
+			row = append(row, issue.Instance)
+		}
+		if rs.splitBy != "" {
+			row = append(row, multisprintIssue.SplitGroup)
+		}
+		if rs.groupByFieldName != "" {
+			row = append(row, multisprintIssue.Group)
+		}
+		// Rows read from a saved report (-input) are written back as read, blank where the report lacked the column
+		if multisprintIssue.InputValues != nil {
+			for c, column := range header {
+				row[c] = multisprintIssue.InputValues[column]
+			}
+		}
+		// Shorten over-long cells (-maxcellwidth), counting them for the end of run summary
+		if truncated := limitCellWidths(row, rs.maxCellWidth); truncated > 0 {
+			rs.truncatedCellCount += truncated
+			rs.truncatedRowCount++
+		}
+		// Write row
+		if err := writer.WriteRow(ReportRecord{Columns: header, Values: row}); err != nil {
+			return filename, 0, fmt.Errorf("failed to write data row: %w", err)
+		}
+		if err := rs.flushProgress(flushRows, file, i+1, &lastSync); err != nil {
+			return filename, 0, fmt.Errorf("failed to write data row: %w", err)
+		}
+
+		// Write a subtotal row when this is the last issue of its group
+		if rs.groupByFieldName != "" && rs.subtotalsEnabled {
+			groupIssueCount++
+			if points, ok := StoryPointsValue(issue.Fields.StoryPoints); ok {
+				groupStoryPoints += points
+			}
+			if i == len(multisprintIssues)-1 || multisprintIssues[i+1].Group != multisprintIssue.Group {
+				subtotal := make([]string, len(header))
+				subtotal[0] = "Subtotal"
+				subtotal[2] = fmt.Sprintf("%s: %d issues", multisprintIssue.Group, groupIssueCount)
+				subtotal[12] = strconv.FormatFloat(groupStoryPoints, 'f', -1, 64)
+				subtotal[groupColumn] = multisprintIssue.Group
+				if err := writer.WriteRow(ReportRecord{Columns: header, Values: subtotal, Subtotal: true}); err != nil {
+					return filename, 0, fmt.Errorf("failed to write subtotal row: %w", err)
+				}
+				groupIssueCount = 0
+				groupStoryPoints = 0
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return filename, 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return filename, 0, fmt.Errorf("failed to write output file: %w", err)
+		}
+		if info, err := file.Stat(); err == nil && uncompressedBytes > 0 {
+			rs.writeLog("INFO", fmt.Sprintf("Compressed %s: %s uncompressed written as %s (gzip saved %.0f%%)", filename,
+				formatByteSize(uncompressedBytes), formatByteSize(info.Size()), 100*(1-float64(info.Size())/float64(uncompressedBytes))))
+		}
+	}
+
+	// Flush the file to disk and check every row reached it, so interference such as antivirus
+	// locking cannot silently truncate the report (-noverify skips this for exotic filesystems)
+	if err := file.Sync(); err != nil {
+		if rs.verifyOutputEnabled {
+			return filename, 0, fmt.Errorf("failed to sync output file: %w", err)
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("Failed to sync output file %s: %v", filename, err))
+	}
+	if rs.verifyOutputEnabled {
+		totalRows, err := countOutputDataRows(file.Name())
+		if err != nil {
+			return filename, 0, err
+		}
+		if writtenRows := totalRows - existingRows; writtenRows != len(multisprintIssues) {
+			return filename, 0, fmt.Errorf("output verification failed for %s: %d issues were written but %d data rows were found (the file is incomplete)",
+				filename, len(multisprintIssues), writtenRows)
+		}
+		rs.writeLog("INFO", fmt.Sprintf("Verified %d data rows in %s", len(multisprintIssues), file.Name()))
+	}
+
+	// Put the completed file in place of the output file
+	if temporary != "" {
+		closed = true
+		if err := file.Close(); err != nil {
+			return filename, 0, fmt.Errorf("failed to write output file: %w", err)
+		}
+		if filename, err = rs.replaceOutputFile(temporary, filename); err != nil {
+			return filename, 0, err
+		}
+		temporary = ""
+	}
+
+	if appendMode {
+		rs.writeLog("INFO", fmt.Sprintf("Successfully appended %d issues to %s", len(multisprintIssues), filename))
+	} else {
+		rs.writeLog("INFO", fmt.Sprintf("Successfully wrote %d issues to %s", len(multisprintIssues), filename))
+	}
+	return filename, len(multisprintIssues), nil
+}
+
+/***********************************************************************************************************************************/
+// filterIgnoredLabels removes issues carrying any of the ignore labels from the spillover set
+//
+// Label matching is case-insensitive. An issue carrying several ignore labels is excluded once
+// but counted against each matching label.
+//
+// Parameters:
+//   multisprintIssues - slice of issues that span multiple sprints
+//   ignoreLabels      - labels whose issues should be excluded
+//
+// Returns:
+//   []MultisprintIssue - issues that remain in the spillover set
+//   []MultisprintIssue - issues excluded due to ignore labels
+//   map[string]int     - number of excluded issues per ignore label (keyed by lowercase label)
+func filterIgnoredLabels(multisprintIssues []MultisprintIssue, ignoreLabels []string) ([]MultisprintIssue, []MultisprintIssue, map[string]int) {
+	ignoreSet := make(map[string]bool, len(ignoreLabels))
+	for _, label := range ignoreLabels {
+		ignoreSet[strings.ToLower(label)] = true
+	}
+
+	kept := make([]MultisprintIssue, 0, len(multisprintIssues))
+	var excluded []MultisprintIssue
+	labelCounts := make(map[string]int, len(ignoreLabels))
+
+	for _, multisprintIssue := range multisprintIssues {
+		matched := false
+		for _, label := range multisprintIssue.Issue.Fields.Labels {
+			if lowerLabel := strings.ToLower(label); ignoreSet[lowerLabel] {
+				labelCounts[lowerLabel]++
+				matched = true
+			}
+		}
+		if matched {
+			excluded = append(excluded, multisprintIssue)
+		} else {
+			kept = append(kept, multisprintIssue)
+		}
+	}
+
+	return kept, excluded, labelCounts
+}
+
+/***********************************************************************************************************************************/
+// hasIgnoreLabel reports whether an issue carries any of the -ignorelabel labels
+//
+// Parameters:
+//   issue        - the Jira issue to check
+//   ignoreLabels - labels to match (case-insensitive)
+//
+// Returns:
+//   bool - true if the issue carries at least one ignore label
+func hasIgnoreLabel(issue Issue, ignoreLabels []string) bool {
+	for _, label := range issue.Fields.Labels {
+		for _, ignoreLabel := range ignoreLabels {
+			if strings.EqualFold(label, ignoreLabel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/***********************************************************************************************************************************/
+// getGroupValue extracts the -groupbyfield value from an issue
+//
+// The field may be a single-select option ({"value": "Squad A"}), a plain string, a user object
+// ({"displayName": "Alice"}), or an array of any of these (values are joined with ", ").
+//
+// Parameters:
+//   issue - the Jira issue to extract the group value from
+//
+// Returns:
+//   string - group value, or "(none)" if the field is missing or empty
+func (rs *runState) getGroupValue(issue Issue) string {
+	if rs.notVisibleFields[rs.groupByFieldName] {
+		return notVisibleValue
+	}
+	raw, ok := issue.Fields.additionalField(rs.groupByFieldName)
+	if !ok {
+		return "(none)"
+	}
+
+	// describe converts a single decoded JSON value to its display text
+	describe := func(value interface{}) string {
+		switch v := value.(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case map[string]interface{}:
+			for _, key := range []string{"value", "displayName", "name"} {
+				if text, ok := v[key].(string); ok && text != "" {
+					return text
+				}
+			}
+		}
+		return ""
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return "(none)"
+	}
+
+	var group string
+	if values, ok := decoded.([]interface{}); ok {
+		names := make([]string, 0, len(values))
+		for _, value := range values {
+			if name := describe(value); name != "" {
+				names = append(names, name)
+			}
+		}
+		group = strings.Join(names, ", ")
+	} else {
+		group = describe(decoded)
+	}
+
+	if strings.TrimSpace(group) == "" {
+		return "(none)"
+	}
+	return group
+}
+
+/***********************************************************************************************************************************/
+// issueKeyLess reports whether issue key a sorts before issue key b
+//
+// Keys are compared by project then by issue number, so EXPD-9 sorts before EXPD-10.
+//
+// Parameters:
+//   a - first issue key
+//   b - second issue key
+//
+// Returns:
+//   bool - true if a sorts before b
+func issueKeyLess(a, b string) bool {
+	projectA, numberA, okA := strings.Cut(a, "-")
+	projectB, numberB, okB := strings.Cut(b, "-")
+	if okA && okB && projectA == projectB {
+		numA, errA := strconv.Atoi(numberA)
+		numB, errB := strconv.Atoi(numberB)
+		if errA == nil && errB == nil {
+			return numA < numB
+		}
+	}
+	return a < b
+}
+
+/***********************************************************************************************************************************/
+// issueDateLess reports whether date string a sorts before date string b, with unparseable or missing dates last
+//
+// Parameters:
+//   a - first Jira date string (nil if absent)
+//   b - second Jira date string (nil if absent)
+//
+// Returns:
+//   bool - true if a sorts before b
+//   bool - true if a and b are equal for ordering purposes
+func (rs *runState) issueDateLess(a, b *string) (bool, bool) {
+	var timeA, timeB time.Time
+	var okA, okB bool
+	if a != nil {
+		timeA, okA = rs.parseJiraTime(*a)
+	}
+	if b != nil {
+		timeB, okB = rs.parseJiraTime(*b)
+	}
+	switch {
+	case okA && okB:
+		return timeA.Before(timeB), timeA.Equal(timeB)
+	case okA != okB:
+		return okA, false
+	default:
+		return false, true
+	}
+}
+
+/***********************************************************************************************************************************/
+// sortMultisprintIssues orders spillover issues deterministically before they are written
+//
+// Issues are ordered by group value (when grouping, so each group is contiguous), then by the -orderby
+// field, then by issue key. Jira alone knows the priority scheme's order, so with -orderby priority the
+// order Jira returned is kept within each group. -orderby score puts the highest Spillover Score first.
+//
+// Parameters:
+//   multisprintIssues - slice of issues to sort in place
+func (rs *runState) sortMultisprintIssues(multisprintIssues []MultisprintIssue) {
+	sort.SliceStable(multisprintIssues, func(i, j int) bool {
+		a, b := multisprintIssues[i], multisprintIssues[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		switch rs.orderByField {
+		case "priority":
+			return false
+		case "score":
+			scoreA, _ := rs.issueSpilloverScore(a, rs.startTime)
+			scoreB, _ := rs.issueSpilloverScore(b, rs.startTime)
+			if scoreA != scoreB {
+				return scoreA > scoreB
+			}
+		case "updated":
+			if less, equal := rs.issueDateLess(a.Issue.Fields.Updated, b.Issue.Fields.Updated); !equal {
+				return less
+			}
+		case "created":
+			if less, equal := rs.issueDateLess(a.Issue.Fields.Created, b.Issue.Fields.Created); !equal {
+				return less
+			}
+		}
+		return issueKeyLess(a.Issue.Key, b.Issue.Key)
+	})
+}
+
+/***********************************************************************************************************************************/
+// sprintBefore reports whether sprint a should be ordered before sprint b
+//
+// Sprints with a start date come first, in start date order; sprints without one (legacy strings and bare IDs
+// that could not be resolved) follow them. Within each group, and between sprints starting at the same time,
+// sprints are ordered by name and then by ID. Keeping the undated sprints in a group of their own makes the
+// ordering transitive, which sort requires: comparing dates where both have one and names otherwise would put a
+// dated sprint both before and after the same undated one.
+//
+// Parameters:
+//   a - first sprint
+//   b - second sprint
+//
+// Returns:
+//   bool - true if a sorts before b
+func sprintBefore(a, b SprintDetail) bool {
+	if (a.StartDate == nil) != (b.StartDate == nil) {
+		return a.StartDate != nil
+	}
+	if a.StartDate != nil && !a.StartDate.Equal(*b.StartDate) {
+		return a.StartDate.Before(*b.StartDate)
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.ID < b.ID
+}
+
+/***********************************************************************************************************************************/
+// buildSprintPairs calculates spillover totals for each boundary between consecutive sprints
+//
+// Each issue's sprints are ordered chronologically and every consecutive pair is counted as a
+// boundary the issue spilled across, so an issue spanning three sprints contributes to two rows.
+//
+// Parameters:
+//   multisprintIssues - slice of issues that span multiple sprints
+//
+// Returns:
+//   []SprintPairStat - boundary totals sorted chronologically by the from-sprint
+func (rs *runState) buildSprintPairs(multisprintIssues []MultisprintIssue) []SprintPairStat {
+	pairIndex := make(map[string]int)
+	var pairs []SprintPairStat
+
+	for _, multisprintIssue := range multisprintIssues {
+		// Order this issue's sprints chronologically without disturbing the original slice
+		sprints := append([]SprintDetail{}, multisprintIssue.SprintInfo.Sprints...)
+		sort.SliceStable(sprints, func(i, j int) bool {
+			return sprintBefore(sprints[i], sprints[j])
+		})
+
+		points, _ := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+
+		for i := 0; i+1 < len(sprints); i++ {
+			key := rs.sprintIdentity(sprints[i]) + "\x00" + rs.sprintIdentity(sprints[i+1])
+			idx, exists := pairIndex[key]
+			if !exists {
+				idx = len(pairs)
+				pairIndex[key] = idx
+				pairs = append(pairs, SprintPairStat{FromSprint: sprints[i], ToSprint: sprints[i+1]})
+			}
+			pairs[idx].IssueCount++
+			pairs[idx].StoryPoints += points
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if sprintBefore(pairs[i].FromSprint, pairs[j].FromSprint) || sprintBefore(pairs[j].FromSprint, pairs[i].FromSprint) {
+			return sprintBefore(pairs[i].FromSprint, pairs[j].FromSprint)
+		}
+		return sprintBefore(pairs[i].ToSprint, pairs[j].ToSprint)
+	})
+
+	return pairs
+}
+
+/***********************************************************************************************************************************/
+// buildSprintFlow calculates how much spillover entered and left each sprint
+//
+// Each issue's sprints are ordered chronologically; the issue spilled out of every sprint but its last
+// and spilled into every sprint but its first. Only the sprint details already parsed are used, so no
+// further requests are made.
+//
+// Parameters:
+//   multisprintIssues - slice of issues that span multiple sprints
+//
+// Returns:
+//   []SprintFlowStat - totals per sprint, sorted by sprint start date (then name)
+func (rs *runState) buildSprintFlow(multisprintIssues []MultisprintIssue) []SprintFlowStat {
+	statIndex := make(map[string]int)
+	var stats []SprintFlowStat
+
+	for _, multisprintIssue := range multisprintIssues {
+		// Order this issue's sprints chronologically without disturbing the original slice
+		sprints := append([]SprintDetail{}, multisprintIssue.SprintInfo.Sprints...)
+		sort.SliceStable(sprints, func(i, j int) bool {
+			return sprintBefore(sprints[i], sprints[j])
+		})
+
+		points, _ := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+
+		for i, sprint := range sprints {
+			key := rs.sprintIdentity(sprint)
+			idx, exists := statIndex[key]
+			if !exists {
+				idx = len(stats)
+				statIndex[key] = idx
+				stats = append(stats, SprintFlowStat{Sprint: sprint})
+			}
+			if i > 0 {
+				stats[idx].SpilledIn++
+				stats[idx].SpilledInPoints += points
+			}
+			if i < len(sprints)-1 {
+				stats[idx].SpilledOut++
+				stats[idx].SpilledOutPoints += points
+			}
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return sprintBefore(stats[i].Sprint, stats[j].Sprint)
+	})
+
+	return stats
+}
+
+/***********************************************************************************************************************************/
+// buildReleaseStats totals spillover issues and story points per fix version
+//
+// An issue with several fix versions counts towards each of them; issues without a fix version
+// are totalled under "(no version)", which is listed last.
+//
+// Parameters:
+//   multisprintIssues - slice of issues that span multiple sprints
+//
+// Returns:
+//   []ReleaseStat - totals per fix version, sorted by version name
+func buildReleaseStats(multisprintIssues []MultisprintIssue) []ReleaseStat {
+	const noVersion = "(no version)"
+	statIndex := make(map[string]int)
+	var stats []ReleaseStat
+
+	for _, multisprintIssue := range multisprintIssues {
+		points, _ := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+
+		versions := make([]string, 0, len(multisprintIssue.Issue.Fields.FixVersions))
+		for _, version := range multisprintIssue.Issue.Fields.FixVersions {
+			versions = append(versions, version.Name)
+		}
+		if len(versions) == 0 {
+			versions = append(versions, noVersion)
+		}
+
+		for _, version := range versions {
+			idx, exists := statIndex[version]
+			if !exists {
+				idx = len(stats)
+				statIndex[version] = idx
+				stats = append(stats, ReleaseStat{Version: version})
+			}
+			stats[idx].IssueCount++
+			stats[idx].StoryPoints += points
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if (stats[i].Version == noVersion) != (stats[j].Version == noVersion) {
+			return stats[j].Version == noVersion
+		}
+		return stats[i].Version < stats[j].Version
+	})
+
+	return stats
+}
+
+/***********************************************************************************************************************************/
+// buildEpicRollup totals spillover issues and story points per epic
+//
+// Epic summaries come from the lookups already made for the report, so no further requests are
+// made. Issues without an epic link are totalled under "No Epic".
+//
+// Parameters:
+//   multisprintIssues - the final spillover issues, in report order
+//   epics             - epic summaries keyed by epic key
+//
+// Returns:
+//   []EpicRollupStat - totals per epic, most issues first, then most story points, then by epic key
+func (rs *runState) buildEpicRollup(multisprintIssues []MultisprintIssue, epics map[string]EpicMeta) []EpicRollupStat {
+	const noEpic = "No Epic"
+	statIndex := make(map[string]int)
+	var stats []EpicRollupStat
+	earliest := make(map[string]time.Time)
+
+	for _, multisprintIssue := range multisprintIssues {
+		issue := multisprintIssue.Issue
+		epicKey := multisprintIssue.EpicLink
+		if epicKey == "" {
+			epicKey = noEpic
+		}
+
+		idx, exists := statIndex[epicKey]
+		if !exists {
+			idx = len(stats)
+			statIndex[epicKey] = idx
+			stat := EpicRollupStat{EpicKey: epicKey}
+			if epicKey != noEpic {
+				stat.EpicSummary = epics[epicKey].Summary
+				if stat.EpicSummary == "" {
+					stat.EpicSummary = "No Epic Summary"
+				}
+			}
+			stats = append(stats, stat)
+		}
+
+		points, _ := StoryPointsValue(issue.Fields.StoryPoints)
+		stats[idx].IssueCount++
+		stats[idx].StoryPoints += points
+		stats[idx].IssueKeys = append(stats[idx].IssueKeys, issue.Key)
+
+		// Keep the oldest created date, comparing parsed times as Jira offsets vary
+		if issue.Fields.Created != nil {
+			if created, ok := rs.parseJiraTime(*issue.Fields.Created); ok {
+				if current, seen := earliest[epicKey]; !seen || created.Before(current) {
+					earliest[epicKey] = created
+					stats[idx].EarliestCreated = issue.Fields.Created
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].IssueCount != stats[j].IssueCount {
+			return stats[i].IssueCount > stats[j].IssueCount
+		}
+		if stats[i].StoryPoints != stats[j].StoryPoints {
+			return stats[i].StoryPoints > stats[j].StoryPoints
+		}
+		return stats[i].EpicKey < stats[j].EpicKey
+	})
+
+	return stats
+}
+
+/***********************************************************************************************************************************/
+// writeEpicRollupFile writes spillover totals per epic in the report's output format (-format)
+//
+// Parameters:
+//   filename - output filename (the format's extension is appended if missing)
+//   stats    - epic totals from buildEpicRollup
+//
+// Returns:
+//   error - any error encountered during file writing
+func (rs *runState) writeEpicRollupFile(filename string, stats []EpicRollupStat) error {
+	// Ensure filename has the format's extension
+	filename = ensureExtension(filename, reportFormatExtension(rs.outputFormat))
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create epic rollup file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	header := []string{"Epic Key", "Epic Summary", "Spillover Issues", "Spillover Story Points", "Earliest Created", "Issue Keys"}
+	writer := ReportFormats[rs.outputFormat].NewWriter(file)
+	if err := rs.writeByteOrderMark(file); err != nil {
+		return err
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write epic rollup header: %w", err)
+	}
+
+	for _, stat := range stats {
+		row := []string{
+			stat.EpicKey,
+			sanitizeCellValue(stat.EpicSummary),
+			strconv.Itoa(stat.IssueCount),
+			strconv.FormatFloat(stat.StoryPoints, 'f', -1, 64),
+			rs.formatDate(stat.EpicKey, stat.EarliestCreated),
+			strings.Join(stat.IssueKeys, "; "),
+		}
+		if err := writer.WriteRow(ReportRecord{Columns: header, Values: row}); err != nil {
+			return fmt.Errorf("failed to write epic rollup row: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write epic rollup file: %w", err)
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Successfully wrote %d epics to %s", len(stats), filename))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// writePerSprintFile writes the spillover into and out of each sprint in the report's output format (-format)
+//
+// Parameters:
+//   filename - output filename (the format's extension is appended if missing)
+//   stats    - per-sprint totals from buildSprintFlow
+//
+// Returns:
+//   error - any error encountered during file writing
+func (rs *runState) writePerSprintFile(filename string, stats []SprintFlowStat) error {
+	// Ensure filename has the format's extension
+	filename = ensureExtension(filename, reportFormatExtension(rs.outputFormat))
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create per-sprint file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	header := []string{"Sprint", "Sprint Start", "Sprint End", "Spilled In", "Spilled In Story Points", "Spilled Out", "Spilled Out Story Points"}
+	writer := ReportFormats[rs.outputFormat].NewWriter(file)
+	if err := rs.writeByteOrderMark(file); err != nil {
+		return err
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write per-sprint header: %w", err)
+	}
+
+	for _, stat := range stats {
+		row := []string{
+			stat.Sprint.Name,
+			rs.formatSprintDate(stat.Sprint.StartDate),
+			rs.formatSprintDate(stat.Sprint.EndDate),
+			strconv.Itoa(stat.SpilledIn),
+			strconv.FormatFloat(stat.SpilledInPoints, 'f', -1, 64),
+			strconv.Itoa(stat.SpilledOut),
+			strconv.FormatFloat(stat.SpilledOutPoints, 'f', -1, 64),
+		}
+		if err := writer.WriteRow(ReportRecord{Columns: header, Values: row}); err != nil {
+			return fmt.Errorf("failed to write per-sprint row: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write per-sprint file: %w", err)
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Successfully wrote %d sprints to %s", len(stats), filename))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// writeSprintPairsFile writes spillover totals per consecutive sprint pair to a tab-separated file
+//
+// Parameters:
+//   filename - output filename (".tsv" is appended if missing)
+//   pairs    - sprint boundary totals from buildSprintPairs
+//
+// Returns:
+//   error - any error encountered during file writing
+func (rs *runState) writeSprintPairsFile(filename string, pairs []SprintPairStat) error {
+	// Ensure filename has .tsv extension
+	filename = ensureTSVExtension(filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create sprint pairs file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	header := []string{"From Sprint", "To Sprint", "Spillover Issues", "Spillover Story Points"}
+	if err := rs.writeByteOrderMark(file); err != nil {
+		return err
+	}
+	if _, err := file.WriteString(strings.Join(header, "\t") + "\n"); err != nil {
+		return fmt.Errorf("failed to write sprint pairs header: %w", err)
+	}
+
+	for _, pair := range pairs {
+		row := []string{
+			pair.FromSprint.Name,
+			pair.ToSprint.Name,
+			strconv.Itoa(pair.IssueCount),
+			strconv.FormatFloat(pair.StoryPoints, 'f', -1, 64),
+		}
+		if _, err := file.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+			return fmt.Errorf("failed to write sprint pairs row: %w", err)
+		}
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Successfully wrote %d sprint pairs to %s", len(pairs), filename))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// openAllIssuesFile creates the -allissuesfile output and writes its header
+//
+// Rows are written with writeAllIssuesRow as each issue is processed, so the file never needs
+// the full issue set held in a second structure.
+//
+// Parameters:
+//   filename - output filename (.tsv is appended if missing)
+//
+// Returns:
+//   *os.File      - the open file, to be closed by the caller
+//   *bufio.Writer - buffered writer for rows, to be flushed by the caller
+//   error         - any error encountered creating the file or writing the header
+func (rs *runState) openAllIssuesFile(filename string) (*os.File, *bufio.Writer, error) {
+	file, err := os.Create(ensureTSVExtension(filename))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create all issues file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	header := []string{"Issue Key", "Issue Type", "Status", "Assignee", "Story Points", "Number of Sprints", "Spillover", "Epic Link"}
+	err = rs.writeByteOrderMark(writer)
+	if err == nil {
+		_, err = writer.WriteString(strings.Join(header, "\t") + "\n")
+	}
+	if err == nil {
+		// Show the header at once to anyone following the file
+		err = writer.Flush()
+	}
+	if err != nil {
+		if cerr := file.Close(); cerr != nil {
+			log.Printf("failed to close file: %v", cerr)
+		}
+		return nil, nil, fmt.Errorf("failed to write all issues header: %w", err)
+	}
+
+	return file, writer, nil
+}
+
+/***********************************************************************************************************************************/
+// writeAllIssuesRow writes one processed issue to the -allissuesfile output
+//
+// Parameters:
+//   writer      - buffered writer from openAllIssuesFile
+//   issue       - the Jira issue
+//   sprintCount - number of sprints the issue has been in
+//   spillover   - true if the issue is in the spillover set
+//   epicLink    - epic key (no epic summary lookup is made for this file)
+//
+// Returns:
+//   error - any error encountered writing the row
+func (rs *runState) writeAllIssuesRow(writer *bufio.Writer, issue Issue, sprintCount int, spillover bool, epicLink string) error {
+	assignee := "Unassigned"
+	if issue.Fields.Assignee != nil {
+		assignee = rs.formatIdentity(issue.Fields.Assignee.DisplayName, issue.Fields.Assignee.AccountID, issue.Fields.Assignee.EmailAddress)
+	}
+	spilloverValue := "no"
+	if spillover {
+		spilloverValue = "yes"
+	}
+
+	row := []string{
+		issue.Key,
+		issue.Fields.IssueType.Name,
+		issue.Fields.Status.Name,
+		assignee,
+		FormatStoryPoints(issue.Fields.StoryPoints),
+		strconv.Itoa(sprintCount),
+		spilloverValue,
+		epicLink,
+	}
+	if _, err := writer.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+		return fmt.Errorf("failed to write all issues row for %s: %w", issue.Key, err)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// fetchCurrentUser looks up the user Jira authenticates the run as
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//
+// Returns:
+//   string - display name, with email address when visible
+//   error  - any error encountered during the request
+func (rs *runState) fetchCurrentUser(jiraBaseURL, authToken string) (string, error) {
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", jiraBaseURL+"/rest/api/2/myself", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create current user request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up current user: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read current user response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("HTTP %d looking up current user", resp.StatusCode)
+	}
+
+	var user struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", fmt.Errorf("failed to parse current user response: %w", err)
+	}
+	if user.EmailAddress != "" {
+		return fmt.Sprintf("%s <%s>", user.DisplayName, user.EmailAddress), nil
+	}
+	return user.DisplayName, nil
+}
+
+/***********************************************************************************************************************************/
+// fetchServerInfo reads the product and version of a Jira instance from /rest/api/2/serverInfo
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//
+// Returns:
+//   JiraServerInfo - deployment type and version, with Detected set (Instance is left empty)
+//   error          - any error encountered, including a response that is not Jira's (e.g., a proxy login page)
+func (rs *runState) fetchServerInfo(jiraBaseURL, authToken string) (JiraServerInfo, error) {
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", jiraBaseURL+"/rest/api/2/serverInfo", nil)
+	if err != nil {
+		return JiraServerInfo{}, fmt.Errorf("failed to create server info request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return JiraServerInfo{}, fmt.Errorf("failed to read server info: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return JiraServerInfo{}, fmt.Errorf("failed to read server info response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return JiraServerInfo{}, fmt.Errorf("HTTP %d reading server info", resp.StatusCode)
+	}
+
+	var serverInfo struct {
+		Version        string `json:"version"`
+		DeploymentType string `json:"deploymentType"`
+	}
+	if err := json.Unmarshal(body, &serverInfo); err != nil || serverInfo.DeploymentType == "" {
+		return JiraServerInfo{}, fmt.Errorf("the server info response is not from Jira")
+	}
+	return JiraServerInfo{BaseURL: jiraBaseURL, DeploymentType: serverInfo.DeploymentType, Version: serverInfo.Version, Detected: true}, nil
+}
+
+/***********************************************************************************************************************************/
+// fetchFieldCatalog reads every field of a Jira instance, system and custom, from /rest/api/2/field
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//
+// Returns:
+//   []jiraField - ID and display name of each field
+//   error       - any error encountered
+func (rs *runState) fetchFieldCatalog(jiraBaseURL, authToken string) ([]jiraField, error) {
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", jiraBaseURL+"/rest/api/2/field", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create field list request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := rs.newJiraClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field list: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field list response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d reading field list", resp.StatusCode)
+	}
+
+	var fields []jiraField
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse field list: %w", err)
+	}
+	return fields, nil
+}
+
+/***********************************************************************************************************************************/
+// matchFieldName finds the field a field-accepting option names, by ID or by display name
+//
+// An exact ID is used as given. Otherwise the value is compared with every ID and display name, ignoring case,
+// and must match exactly one field.
+//
+// Parameters:
+//   setting - option the value was given with (e.g., "-pair"), for messages
+//   value   - field ID or display name as given by the user
+//   fields  - the instance's fields (see fetchFieldCatalog)
+//
+// Returns:
+//   string - ID of the matching field
+//   error  - errFieldResolution naming the close matches when no field or several fields match
+func matchFieldName(setting, value string, fields []jiraField) (string, error) {
+	var matches []jiraField
+	for _, field := range fields {
+		if field.ID == value {
+			return field.ID, nil
+		}
+		if strings.EqualFold(field.ID, value) || strings.EqualFold(strings.TrimSpace(field.Name), value) {
+			matches = append(matches, field)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0].ID, nil
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("%w: %s '%s' matches %d fields (%s); give the field ID instead",
+			errFieldResolution, setting, value, len(matches), describeFields(matches))
+	}
+
+	// Suggest names that contain the value, or that differ from it by a typo or two
+	lowerValue := strings.ToLower(value)
+	var closeMatches []jiraField
+	for _, field := range fields {
+		lowerName := strings.ToLower(strings.TrimSpace(field.Name))
+		if lowerName == "" {
+			continue
+		}
+		if strings.Contains(lowerName, lowerValue) || strings.Contains(lowerValue, lowerName) ||
+			editDistance(lowerName, lowerValue) <= max(2, len([]rune(lowerValue))/4) {
+			closeMatches = append(closeMatches, field)
+		}
+	}
+	if len(closeMatches) == 0 {
+		return "", fmt.Errorf("%w: %s '%s' is not the ID or name of any Jira field; find the field with -dumpissue KEY",
+			errFieldResolution, setting, value)
+	}
+	sort.Slice(closeMatches, func(i, j int) bool { return closeMatches[i].Name < closeMatches[j].Name })
+	if len(closeMatches) > 5 {
+		closeMatches = closeMatches[:5]
+	}
+	return "", fmt.Errorf("%w: %s '%s' is not the ID or name of any Jira field (close matches: %s)",
+		errFieldResolution, setting, value, describeFields(closeMatches))
+}
+
+/***********************************************************************************************************************************/
+// describeFields lists fields for messages as "Name (ID)"
+//
+// Parameters:
+//   fields - fields to list
+//
+// Returns:
+//   string - comma-separated list
+func describeFields(fields []jiraField) string {
+	descriptions := make([]string, 0, len(fields))
+	for _, field := range fields {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", field.Name, field.ID))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+/***********************************************************************************************************************************/
+// editDistance counts the single-character insertions, deletions, and substitutions that turn one string into another
+//
+// Parameters:
+//   a, b - strings to compare
+//
+// Returns:
+//   int - Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
+
+/***********************************************************************************************************************************/
+// resolveFieldSettings turns field names given to -pair, -groupbyfield, and -flaggedfield into field IDs
+//
+// A custom field ID (customfield_NNNNN) is used without a request. Anything else is looked up in each
+// instance's field list (see matchFieldName), which is read at most once per instance. A name cannot be
+// resolved if the field list cannot be read, since sending it to Jira as an ID would match nothing.
+//
+// Parameters:
+//   instances - Jira instances queried by the run; a name must resolve to the same ID in each
+//
+// Returns:
+//   error - errFieldResolution if a name matches no field, several fields, or different fields on different instances,
+//           or if a field list needed for a name cannot be read
+//
+// Side effects:
+//   - Replaces pairFieldName, groupByFieldName, and flaggedFieldName with the resolved IDs
+//   - Logs each resolved ID
+func (rs *runState) resolveFieldSettings(instances []JiraInstance) error {
+	settings := []struct {
+		setting string
+		value   *string
+	}{
+		{"-pair", &rs.pairFieldName},
+		{"-groupbyfield", &rs.groupByFieldName},
+		{"-flaggedfield", &rs.flaggedFieldName},
+	}
+	catalogs := make(map[string][]jiraField)
+	for _, setting := range settings {
+		value := strings.TrimSpace(*setting.value)
+		if value == "" || customFieldIDRegex.MatchString(value) {
+			continue
+		}
+
+		resolvedID := ""
+		for _, instance := range instances {
+			fields, fetched := catalogs[instance.JiraBaseURL]
+			if !fetched {
+				rs.useInstance(instances, instance.Label)
+				var err error
+				fields, err = rs.fetchFieldCatalog(instance.JiraBaseURL, instance.AuthToken)
+				if err != nil {
+					return fmt.Errorf("%w: %s '%s' cannot be looked up because the field list of %s could not be read (%v); give the field ID (customfield_NNNNN) instead",
+						errFieldResolution, setting.setting, value, instance.JiraBaseURL, err)
+				}
+				catalogs[instance.JiraBaseURL] = fields
+			}
+			fieldID, err := matchFieldName(setting.setting, value, fields)
+			if err != nil {
+				if instance.Label != "" {
+					err = fmt.Errorf("%s instance: %w", instance.Label, err)
+				}
+				return err
+			}
+			if resolvedID != "" && fieldID != resolvedID {
+				return fmt.Errorf("%w: %s '%s' is %s on one instance and %s on another; give the field ID instead",
+					errFieldResolution, setting.setting, value, resolvedID, fieldID)
+			}
+			resolvedID = fieldID
+		}
+		if resolvedID != value {
+			rs.writeLog("INFO", fmt.Sprintf("Resolved %s '%s' to field %s", setting.setting, value, resolvedID))
+		}
+		*setting.value = resolvedID
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// detectJiraServer identifies the product behind an instance, falling back to a guess from its URL
+//
+// Parameters:
+//   instance - Jira instance to identify
+//
+// Returns:
+//   JiraServerInfo - the detected product and version, or Cloud or Server guessed from the URL with Detected false
+//
+// Side effects:
+//   - Makes one request to /rest/api/2/serverInfo
+//   - Logs the deployment type and version, or a WARNING when they cannot be read
+func (rs *runState) detectJiraServer(instance JiraInstance) JiraServerInfo {
+	server, err := rs.fetchServerInfo(instance.JiraBaseURL, instance.AuthToken)
+	if err != nil {
+		server = JiraServerInfo{BaseURL: instance.JiraBaseURL, DeploymentType: "Server"}
+		if IsCloudURL(instance.JiraBaseURL) {
+			server.DeploymentType = "Cloud"
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("Could not identify the Jira product at %s (%v); assuming %s from the URL, with conservative defaults",
+			instance.JiraBaseURL, err, server.DeploymentType))
+	} else {
+		rs.writeLog("INFO", fmt.Sprintf("Jira at %s is %s version %s", instance.JiraBaseURL, server.DeploymentType, server.Version))
+	}
+	server.Instance = instance.Label
+	return server
+}
+
+/***********************************************************************************************************************************/
+// decideCapabilities chooses the behaviour that depends on an instance's product
+//
+// Detected Cloud and Data Center instances support updatedBy(); Server instances, and any instance whose
+// product was only guessed, are probed instead. Cloud instances (detected, or guessed from an Atlassian URL)
+// use Cloud sprint report links.
+//
+// Parameters:
+//   server - the instance's detected or guessed product
+//
+// Returns:
+//   jiraCapabilities - the choices for the instance
+func decideCapabilities(server JiraServerInfo) jiraCapabilities {
+	cloud := strings.EqualFold(server.DeploymentType, "Cloud")
+	dataCenter := strings.EqualFold(server.DeploymentType, "DataCenter")
+	return jiraCapabilities{
+		CloudLinks:   cloud,
+		UpdatedByJQL: server.Detected && (cloud || dataCenter),
+	}
+}
+
+/***********************************************************************************************************************************/
+// warnUnsupportedFlags warns about requested options an instance's product is not expected to support
+//
+// Parameters:
+//   cfg     - run configuration
+//   servers - detected or guessed product of each instance
+//
+// Side effects:
+//   - Writes a WARNING for each option and instance that do not match
+func (rs *runState) warnUnsupportedFlags(cfg Config, servers []JiraServerInfo) {
+	for _, server := range servers {
+		capabilities := decideCapabilities(server)
+		if cfg.SprintLinks && cfg.CloudLinks && !capabilities.CloudLinks {
+			rs.writeLog("WARNING", fmt.Sprintf("-cloudlinks was requested but %s is Jira %s; its sprint report links may not open", server.BaseURL, server.DeploymentType))
+		}
+		if cfg.JQLUpdatedBy && !capabilities.UpdatedByJQL {
+			rs.writeLog("WARNING", fmt.Sprintf("-jqlupdatedby skips the updatedBy() probe but %s is Jira %s, which may not support it; the search fails if it does not",
+				server.BaseURL, strings.TrimSpace(server.DeploymentType+" "+server.Version)))
+		}
+	}
+}
+
+/***********************************************************************************************************************************/
+// jiraServerRecords converts detected Jira products for the posted report and -statsfile documents
+//
+// Parameters:
+//   servers - products from Report.JiraServers
+//
+// Returns:
+//   []jiraServerRecord - one record per instance (nil if servers is empty)
+func jiraServerRecords(servers []JiraServerInfo) []jiraServerRecord {
+	var records []jiraServerRecord
+	for _, server := range servers {
+		records = append(records, jiraServerRecord{
+			Instance:       server.Instance,
+			BaseURL:        server.BaseURL,
+			DeploymentType: server.DeploymentType,
+			Version:        server.Version,
+			Detected:       server.Detected,
+		})
+	}
+	return records
+}
+
+/***********************************************************************************************************************************/
+// fetchIssueCountEstimate asks Jira how many issues match a JQL query without fetching any of them
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   jqlQuery    - JQL query string
+//
+// Returns:
+//   int   - total number of matching issues
+//   error - any error encountered during the request
+func (rs *runState) fetchIssueCountEstimate(jiraBaseURL, authToken, jqlQuery string) (int, error) {
+	req, err := rs.newSearchRequest(jiraBaseURL, authToken, jqlQuery, 0, 0, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create issue count request: %w", err)
+	}
+
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate issue count: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read issue count response: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("HTTP %d estimating issue count", resp.StatusCode)
+	}
+
+	var searchResponse SearchResponse
+	if err := json.Unmarshal(body, &searchResponse); err != nil {
+		return 0, fmt.Errorf("failed to parse issue count response: %w", err)
+	}
+	return searchResponse.Total, nil
+}
+
+/***********************************************************************************************************************************/
+// planRun describes the run for Config.Confirm: who it runs as and how many issues its query matches
+//
+// Parameters:
+//   instances - Jira instances queried by the run (the plan is checked against the first)
+//   jqlQuery  - JQL query the run will execute (one query per line for key chunks)
+//
+// Returns:
+//   RunPlan - the plan, with an "unknown" user or -1 issues where Jira could not say
+//
+// Side effects:
+//   - Makes two lightweight requests to Jira (current user and issue count)
+func (rs *runState) planRun(instances []JiraInstance, jqlQuery string) RunPlan {
+	instance := rs.useInstance(instances, instances[0].Label)
+	plan := RunPlan{JQL: jqlQuery}
+	user, err := rs.fetchCurrentUser(instance.JiraBaseURL, instance.AuthToken)
+	if err != nil {
+		rs.writeLog("WARNING", err.Error())
+		user = "unknown"
+	}
+	plan.User = user
+
+	// Key chunks (-keysfile) are separate queries, so their estimates are summed
+	for _, query := range strings.Split(jqlQuery, "\n") {
+		total, err := rs.fetchIssueCountEstimate(instance.JiraBaseURL, instance.AuthToken, query)
+		if err != nil {
+			rs.writeLog("WARNING", err.Error())
+			plan.EstimatedIssues = -1
+			break
+		}
+		plan.EstimatedIssues += total
+	}
+	return plan
+}
+
+/***********************************************************************************************************************************/
+// fetchRawIssue fetches a single issue with all of its fields and returns the raw JSON response
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   issueKey    - Jira issue key (e.g., "EXPD-1234")
+//   fields      - comma-separated list of fields to retrieve, or empty string for all fields
+//
+// Returns:
+//   []byte - raw JSON response body
+//   error  - any error encountered during the request; errIssueNotFound if Jira returns 404
+func (rs *runState) fetchRawIssue(jiraBaseURL, authToken, issueKey, fields string) ([]byte, error) {
+	issueURL := fmt.Sprintf("%s/rest/api/2/issue/%s", jiraBaseURL, url.PathEscape(issueKey))
+	if fields != "" {
+		issueURL += "?fields=" + url.QueryEscape(fields)
+	}
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", issueURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create issue request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch issue %s: %w", issueKey, err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issue response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errIssueNotFound, issueKey)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d fetching issue %s: %s", resp.StatusCode, issueKey, string(body))
+	}
+	return body, nil
+}
+
+/***********************************************************************************************************************************/
+// DumpIssue writes one issue's raw JSON followed by the values a report run would derive from it
+//
+// Parameters:
+//   ctx        - context that cancels in-flight requests
+//   cfg        - settings; JiraBaseURL and authentication are required, and the field settings (PairField,
+//                IdentityMode, ResolveSprintIDs, StrictNames, FlaggedField) shape the derived values
+//   issueKey   - Jira issue key to dump
+//   outputFile - file to write the dump to, or "" for stdout
+//
+// Returns:
+//   error - any error encountered resolving fields, fetching, parsing, or writing
+func DumpIssue(ctx context.Context, cfg Config, issueKey, outputFile string) error {
+	rs, err := newRunState(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := rs.resolveFieldSettings([]JiraInstance{{JiraBaseURL: cfg.JiraBaseURL, AuthToken: cfg.AuthToken}}); err != nil {
+		return err
+	}
+	return rs.dumpIssue(cfg.JiraBaseURL, cfg.AuthToken, issueKey, outputFile)
+}
+
+/***********************************************************************************************************************************/
+// dumpIssue writes one issue's raw JSON followed by the values the report pipeline derives from it (-dumpissue)
+//
+// The derived values are listed next to the Jira field they come from, so a missing or unexpected
+// column can be traced back to the raw data.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   issueKey    - Jira issue key to dump
+//   outputFile  - file to write the dump to, or "" for stdout
+//
+// Returns:
+//   error - any error encountered fetching, parsing, or writing
+func (rs *runState) dumpIssue(jiraBaseURL, authToken, issueKey, outputFile string) error {
+	body, err := rs.fetchRawIssue(jiraBaseURL, authToken, issueKey, "")
+	if err != nil {
+		return err
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "  "); err != nil {
+		return fmt.Errorf("failed to format issue JSON: %w", err)
+	}
+	var issue Issue
+	if err := json.Unmarshal(body, &issue); err != nil {
+		return fmt.Errorf("failed to parse issue %s: %w", issueKey, err)
+	}
+
+	// Resolve bare sprint IDs the same way the report does
+	if rs.resolveSprintIDsEnabled || hasBareSprintIDField([]Issue{issue}) {
+		rs.resolvedSprints = rs.fetchSprintDetails(jiraBaseURL, authToken, []Issue{issue})
+	}
+	sprintInfo := rs.parseSprintField(issue.Fields.SprintField)
+	epicLink := getEpicLink(issue.Fields.EpicLinkField)
+	values := rs.extractFieldValues(issue)
+
+	pairSource := "(no -pair field)"
+	if rs.pairFieldName != "" {
+		pairSource = rs.pairFieldName
+	}
+	rows := [][3]string{
+		{"SprintCount", strconv.Itoa(sprintInfo.SprintCount), defaultSprintField},
+		{"Spillover", strconv.FormatBool(sprintInfo.SprintCount > 1), defaultSprintField},
+		{"FirstSprint", sprintInfo.FirstSprint, defaultSprintField},
+		{"FirstSprintGoal", sanitizeCellValue(sprintInfo.FirstGoal), defaultSprintField},
+		{"LastSprint", sprintInfo.LastSprint, defaultSprintField},
+		{"AllSprints", sprintInfo.AllSprints, defaultSprintField},
+		{"CurrentSprint", strings.Join(sprintInfo.ActiveSprints, ", "), defaultSprintField},
+	}
+	for i, sprint := range sprintInfo.Sprints {
+		rows = append(rows, [3]string{fmt.Sprintf("Sprint[%d]", i),
+			fmt.Sprintf("%s (id %s, board %s, state %s, %s to %s)", sprint.Name, sprint.ID, sprint.BoardID, sprint.State,
+				rs.formatSprintDate(sprint.StartDate), rs.formatSprintDate(sprint.EndDate)), defaultSprintField})
+	}
+	rows = append(rows,
+		[3]string{"EpicLink", epicLink, defaultEpicLinkField},
+		[3]string{"IsEpic", strconv.FormatBool(isEpic(issue)), "issuetype"},
+		[3]string{"IssueType", values["IssueType"], "issuetype"},
+		[3]string{"Status", values["Status"], "status"},
+		[3]string{"StatusCategory", values["StatusCategory"], "status.statusCategory"},
+		[3]string{"Flagged", values["Flagged"], rs.flaggedFieldName},
+		[3]string{"ProjectName", values["ProjectName"], "project"},
+		[3]string{"CreatedDate", values["CreatedDate"], "created"},
+		[3]string{"UpdatedDate", values["UpdatedDate"], "updated"},
+		[3]string{"ResolvedDate", values["ResolvedDate"], "resolutiondate"},
+		[3]string{"Resolution", values["Resolution"], "resolution"},
+		[3]string{"Assignee", values["Assignee"], "assignee"},
+		[3]string{"Reporter", values["Reporter"], "creator"},
+		[3]string{"StoryPoints", values["StoryPoints"], defaultStoryPointsField},
+		[3]string{"FixVersions", values["FixVersions"], "fixVersions"},
+		[3]string{"Components", values["Components"], "components"},
+		[3]string{"Labels", values["Labels"], "labels"},
+		[3]string{"Pair", values["Pair"], pairSource},
+		[3]string{"BlockedBy", values["BlockedBy"], "issuelinks (" + strings.Join(rs.linkTypeNames, ", ") + ")"},
+		[3]string{"Blocks", values["Blocks"], "issuelinks (" + strings.Join(rs.linkTypeNames, ", ") + ")"},
+	)
+
+	var dump strings.Builder
+	fmt.Fprintf(&dump, "Raw JSON for %s:\n%s\n\n", issue.Key, pretty.String())
+	fmt.Fprintf(&dump, "Derived values for %s:\n", issue.Key)
+	fmt.Fprintf(&dump, "  %-14s %-50s %s\n", "Value", "Derived", "Source field")
+	for _, row := range rows {
+		derived := row[1]
+		if derived == "" {
+			derived = "(empty)"
+		}
+		fmt.Fprintf(&dump, "  %-14s %-50s %s\n", row[0], derived, row[2])
+	}
+
+	if outputFile == "" {
+		fmt.Print(dump.String())
+		return nil
+	}
+	if err := os.WriteFile(outputFile, []byte(dump.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write issue dump: %w", err)
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Issue dump for %s written to %s", issue.Key, outputFile))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// selfTestGet performs an authenticated GET for -selftest through the shared HTTP layer
+//
+// Parameters:
+//   requestURL - full URL to fetch
+//   authToken  - Base64 encoded authentication token
+//
+// Returns:
+//   int    - HTTP status code
+//   []byte - response body
+//   error  - any error sending the request or reading the response
+func (rs *runState) selfTestGet(requestURL, authToken string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(rs.runContext, "GET", requestURL, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authToken)
+	req.Header.Set("Accept", "application/json")
+	return rs.selfTestSend(req)
+}
+
+/***********************************************************************************************************************************/
+// selfTestSearch performs a one-page search for -selftest, built by newSearchRequest
+//
+// The search is sent exactly as a report run sends it, as POST or with -searchget as GET, so the check
+// exercises the same request method.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   jqlQuery    - JQL query string
+//   maxResults  - maximum number of issues to return
+//   fields      - comma-separated list of fields to retrieve
+//
+// Returns:
+//   int    - HTTP status code
+//   []byte - response body
+//   error  - any error building or sending the request or reading the response
+func (rs *runState) selfTestSearch(jiraBaseURL, authToken, jqlQuery string, maxResults int, fields string) (int, []byte, error) {
+	req, err := rs.newSearchRequest(jiraBaseURL, authToken, jqlQuery, 0, maxResults, fields)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return rs.selfTestSend(req)
+}
+
+/***********************************************************************************************************************************/
+// selfTestSend sends a -selftest request through the shared HTTP layer and reads the whole response
+//
+// Parameters:
+//   req - request ready to send
+//
+// Returns:
+//   int    - HTTP status code
+//   []byte - response body
+//   error  - any error sending the request or reading the response
+func (rs *runState) selfTestSend(req *http.Request) (int, []byte, error) {
+	client := rs.newJiraClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); cerr != nil {
+		rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+/***********************************************************************************************************************************/
+// SelfTest checks connectivity and configuration without producing a report
+//
+// A PairField or GroupByField name that cannot be resolved is logged as a warning and checked as given, so
+// its field check fails with the usual hint.
+//
+// Parameters:
+//   ctx        - context that cancels in-flight requests
+//   cfg        - settings; JiraBaseURL and authentication are required
+//   projectKey - project key to check (empty to skip the project checks)
+//   outputFile - output filename to check (empty to skip the output check)
+//
+// Returns:
+//   []SelfTestCheck - the result of each check, in the order run
+//   error           - invalid settings in cfg
+func SelfTest(ctx context.Context, cfg Config, projectKey, outputFile string) ([]SelfTestCheck, error) {
+	rs, err := newRunState(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.resolveFieldSettings([]JiraInstance{{JiraBaseURL: cfg.JiraBaseURL, AuthToken: cfg.AuthToken}}); err != nil {
+		rs.writeLog("WARNING", err.Error())
+	}
+	return rs.runSelfTest(cfg.JiraBaseURL, cfg.AuthToken, projectKey, outputFile), nil
+}
+
+/***********************************************************************************************************************************/
+// runSelfTest checks connectivity and configuration without producing a report (-selftest)
+//
+// Every request goes through the shared HTTP layer, so proxy, TLS, and rate limit settings are exercised
+// exactly as a report run would use them. Checks that depend on an earlier failed check are skipped.
+//
+// Parameters:
+//   jiraBaseURL - base URL of the Jira instance
+//   authToken   - Base64 encoded authentication token
+//   projectKey  - project key to check (empty if -project was not given)
+//   outputFile  - output filename to check (empty if -outputfile was not given)
+//
+// Returns:
+//   []SelfTestCheck - the result of each check, in the order run
+func (rs *runState) runSelfTest(jiraBaseURL, authToken, projectKey, outputFile string) []SelfTestCheck {
+	var checks []SelfTestCheck
+	add := func(check SelfTestCheck, err error) bool {
+		check.Passed = err == nil && !check.Skipped
+		if err != nil {
+			check.Detail = err.Error()
+		}
+		checks = append(checks, check)
+		return check.Passed
+	}
+	skip := func(name string, required bool, reason string) {
+		checks = append(checks, SelfTestCheck{Name: name, Required: required, Skipped: true, Detail: reason})
+	}
+
+	// The server info endpoint answers without a project and identifies the product
+	reachable := SelfTestCheck{Name: "Jira reachable", Required: true,
+		Hint: "Check -url (scheme, host, port, and any context path such as /jira) and the HTTPS_PROXY setting"}
+	statusCode, body, err := rs.selfTestGet(jiraBaseURL+"/rest/api/2/serverInfo", authToken)
+	if err == nil && statusCode != 200 {
+		err = fmt.Errorf("HTTP %d from %s/rest/api/2/serverInfo", statusCode, jiraBaseURL)
+	}
+	var serverInfo struct {
+		Version        string `json:"version"`
+		DeploymentType string `json:"deploymentType"`
+	}
+	if err == nil && (json.Unmarshal(body, &serverInfo) != nil || serverInfo.Version == "") {
+		err = fmt.Errorf("the response from %s is not from Jira", jiraBaseURL)
+	}
+	if err != nil && strings.Contains(err.Error(), "certificate") {
+		reachable.Hint = "The server certificate is not trusted: add the issuing CA to the system certificate store"
+	}
+	reachable.Detail = strings.TrimSpace(fmt.Sprintf("Jira %s %s", serverInfo.Version, serverInfo.DeploymentType))
+	if !add(reachable, err) {
+		for _, name := range []string{"Authentication", "Project visible", "Search permission", "Sprint field", "Story points field", "Epic link field"} {
+			skip(name, true, "skipped, Jira is not reachable")
+		}
+	} else {
+		user, err := rs.fetchCurrentUser(jiraBaseURL, authToken)
+		authenticated := add(SelfTestCheck{Name: "Authentication", Required: true, Detail: "authenticated as " + user,
+			Hint: "Check the token file: Cloud needs email:api-token, Server/Data Center a personal access token; tokens expire"}, err)
+
+		// Always ask Jira rather than trusting the project cache
+		projectVisible := false
+		switch {
+		case !authenticated:
+			skip("Project visible", true, "skipped, authentication failed")
+		case projectKey == "":
+			add(SelfTestCheck{Name: "Project visible", Required: true, Hint: "Give the project to check with -project KEY"},
+				errors.New("no -project given"))
+		default:
+			rs.refreshProjectCache = true
+			err := rs.validateProject(jiraBaseURL, authToken, projectKey)
+			projectVisible = add(SelfTestCheck{Name: "Project visible", Required: true, Detail: "project " + projectKey + " found",
+				Hint: "Check the project key and that the token's user has the Browse Projects permission on it"}, err)
+		}
+
+		// A one-issue search proves the search API is usable for this project
+		searchWorks := false
+		if projectVisible {
+			jql := fmt.Sprintf("project = %s ORDER BY updated DESC", projectKey)
+			statusCode, body, err := rs.selfTestSearch(jiraBaseURL, authToken, jql, 1, "summary")
+			var searchResponse SearchResponse
+			if err == nil && statusCode != 200 {
+				err = fmt.Errorf("HTTP %d from search%s: %s", statusCode, rs.searchMethodHint(statusCode),
+					truncateWithEllipsis(string(body), 200))
+			} else if err == nil && json.Unmarshal(body, &searchResponse) != nil {
+				err = errors.New("failed to parse search response")
+			}
+			searchWorks = add(SelfTestCheck{Name: "Search permission", Required: true,
+				Detail: fmt.Sprintf("%d issues visible in %s", searchResponse.Total, projectKey),
+				Hint:   "The token's user needs Browse Projects permission and access to the search API"}, err)
+		} else {
+			skip("Search permission", true, "skipped, project not checked")
+		}
+
+		// Recent issues that have been in a sprint show whether the configured fields hold data
+		var sprintIssues []Issue
+		if searchWorks {
+			fields := []string{defaultSprintField, defaultStoryPointsField, defaultEpicLinkField}
+			if rs.pairFieldName != "" {
+				fields = append(fields, rs.pairFieldName)
+			}
+			if rs.groupByFieldName != "" {
+				fields = append(fields, rs.groupByFieldName)
+			}
+			jql := fmt.Sprintf("project = %s AND Sprint is not EMPTY ORDER BY updated DESC", projectKey)
+			statusCode, body, err := rs.selfTestSearch(jiraBaseURL, authToken, jql, 20, strings.Join(fields, ","))
+			var searchResponse SearchResponse
+			if err == nil && statusCode != 200 {
+				err = fmt.Errorf("HTTP %d searching for issues in sprints (is Jira Software installed?)", statusCode)
+			} else if err == nil && json.Unmarshal(body, &searchResponse) != nil {
+				err = errors.New("failed to parse search response")
+			} else if err == nil && len(searchResponse.Issues) == 0 {
+				err = fmt.Errorf("no issues in %s have ever been in a sprint", projectKey)
+			}
+			sprintIssues = searchResponse.Issues
+			withSprints := 0
+			for _, issue := range sprintIssues {
+				if issue.Fields.SprintField != nil {
+					withSprints++
+				}
+			}
+			if err == nil && withSprints == 0 {
+				err = fmt.Errorf("%s is empty on all %d recent issues that are in sprints", defaultSprintField, len(sprintIssues))
+			}
+			add(SelfTestCheck{Name: "Sprint field", Required: true,
+				Detail: fmt.Sprintf("%s set on %d of %d recent issues in sprints", defaultSprintField, withSprints, len(sprintIssues)),
+				Hint:   fmt.Sprintf("The report reads sprints from %s; compare with an issue's fields using -dumpissue KEY", defaultSprintField)}, err)
+		} else {
+			skip("Sprint field", true, "skipped, search not available")
+		}
+
+		// fieldCheck reports how many of the recent sprint issues have a value in a field
+		fieldCheck := func(name, fieldID, hint string, required bool, present func(Issue) bool) {
+			if len(sprintIssues) == 0 {
+				skip(name, required, "skipped, no recent issues in sprints to inspect")
+				return
+			}
+			found := 0
+			for _, issue := range sprintIssues {
+				if present(issue) {
+					found++
+				}
+			}
+			var err error
+			if found == 0 {
+				err = fmt.Errorf("%s is empty on all %d recent issues in sprints", fieldID, len(sprintIssues))
+			}
+			add(SelfTestCheck{Name: name, Required: required, Hint: hint,
+				Detail: fmt.Sprintf("%s set on %d of %d recent issues in sprints", fieldID, found, len(sprintIssues))}, err)
+		}
+		fieldCheck("Story points field", defaultStoryPointsField,
+			fmt.Sprintf("Story points are read from %s; check the field ID with -dumpissue KEY and that estimates are entered", defaultStoryPointsField),
+			false, func(issue Issue) bool { return issue.Fields.StoryPoints != nil })
+		fieldCheck("Epic link field", defaultEpicLinkField,
+			fmt.Sprintf("Epic links are read from %s (company-managed projects); check the field ID with -dumpissue KEY", defaultEpicLinkField),
+			false, func(issue Issue) bool { return issue.Fields.EpicLinkField != nil })
+		if rs.pairFieldName != "" {
+			fieldCheck("Pair field", rs.pairFieldName, "Check the -pair field ID with -dumpissue KEY", false,
+				func(issue Issue) bool { _, ok := issue.Fields.additionalField(rs.pairFieldName); return ok })
+		}
+		if rs.groupByFieldName != "" {
+			fieldCheck("Group by field", rs.groupByFieldName, "Check the -groupbyfield field ID with -dumpissue KEY", false,
+				func(issue Issue) bool { _, ok := issue.Fields.additionalField(rs.groupByFieldName); return ok })
+		}
+	}
+
+	// The output path is checked the same way a report run checks it before fetching
+	if outputFile == "" {
+		skip("Output file writable", true, "skipped, no -outputfile given")
+	} else {
+		outputPath := outputFilePath(outputFile, rs.outputFormat, false)
+		add(SelfTestCheck{Name: "Output file writable", Required: true, Detail: outputPath + " can be written",
+			Hint: "Choose a folder you can write to, and close the file if it is open in Excel"}, rs.validateOutputPath(outputPath))
+	}
+
+	return checks
+}
+
+/***********************************************************************************************************************************/
+// buildPostedReport converts a completed run into the JSON document sent to -posturl
+//
+// Parameters:
+//   cfg    - settings the report was run with
+//   report - results returned by Run
+//
+// Returns:
+//   PostedReport - run metadata, one record per spillover issue, and summary statistics
+func (rs *runState) buildPostedReport(cfg Config, report Report) PostedReport {
+	posted := PostedReport{
+		Run: postedRunInfo{
+			Program:              cfg.Program,
+			Version:              cfg.Version,
+			JiraBaseURL:          cfg.JiraBaseURL,
+			Project:              cfg.ProjectKey,
+			JQL:                  report.JQL,
+			Timezone:             DescribeLocation(rs.reportLocation),
+			StartedAt:            report.StartedAt.Format(time.RFC3339),
+			DurationSeconds:      report.Duration.Seconds(),
+			FetchDurationSeconds: report.FetchDuration.Seconds(),
+			Sampled:              report.Sampled,
+			SampleSize:           cfg.Sample,
+			ScoreFormula:         SpilloverScoreFormula(rs.spilloverWeights),
+			NotVisibleFields:     report.NotVisibleFields,
+			JiraServers:          jiraServerRecords(report.JiraServers),
+		},
+		Issues: make([]postedIssue, 0, len(report.Issues)),
+		Summary: postedSummary{
+			FetchedCount:          report.FetchedCount,
+			SpilloverCount:        len(report.Issues),
+			IgnoredCount:          len(report.IgnoredIssues),
+			ResolvedExcludedCount: report.ResolvedExcludedCount,
+			OverdueCount:          report.OverdueCount,
+			FlaggedCount:          report.FlaggedCount,
+			ActiveSprintCount:     report.ActiveSprintCount,
+			ResolvedEpicCount:     report.ResolvedEpicCount,
+			StalenessCounts:       report.StalenessCounts,
+			StatusCategoryCounts:  report.StatusCategoryCounts,
+			MissingKeys:           report.MissingKeys,
+		},
+	}
+	if rs.includeLinks {
+		posted.Summary.BlockedChainCount = &report.BlockedChainCount
+	}
+	if rs.includeTime {
+		posted.Summary.TimeSpentHours = &report.TimeSpentHours
+	}
+
+	for _, multisprintIssue := range report.Issues {
+		issue := multisprintIssue.Issue
+		values := rs.extractFieldValues(issue)
+		epic := report.Epics[multisprintIssue.EpicLink]
+		epicTitle := epic.Summary
+		if epicTitle == "" {
+			epicTitle = "No Epic Summary"
+		}
+		record := postedIssue{
+			Key:                issue.Key,
+			IssueType:          values["IssueType"],
+			Summary:            issue.Fields.Summary,
+			Status:             values["Status"],
+			StatusCategory:     values["StatusCategory"],
+			Flagged:            values["Flagged"] == "yes",
+			Priority:           values["Priority"],
+			Assignee:           values["Assignee"],
+			Reporter:           values["Reporter"],
+			Created:            values["CreatedDate"],
+			Updated:            values["UpdatedDate"],
+			Resolved:           values["ResolvedDate"],
+			DueDate:            values["DueDate"],
+			Overdue:            multisprintIssue.Overdue,
+			EpicLink:           multisprintIssue.EpicLink,
+			EpicSummary:        epicTitle,
+			EpicStatus:         epic.Status,
+			EpicResolved:       rs.formatDate(multisprintIssue.EpicLink, epic.ResolutionDate),
+			EpicCurrentKey:     epic.CurrentKey,
+			Labels:             append([]string{}, issue.Fields.Labels...),
+			FixVersions:        make([]string, 0, len(issue.Fields.FixVersions)),
+			SprintCount:        multisprintIssue.SprintInfo.SprintCount,
+			Sprints:            append([]string{}, multisprintIssue.SprintInfo.SprintNames...),
+			FirstSprint:        multisprintIssue.SprintInfo.FirstSprint,
+			FirstSprintGoal:    sanitizeCellValue(multisprintIssue.SprintInfo.FirstGoal),
+			LastSprint:         multisprintIssue.SprintInfo.LastSprint,
+			FirstSprintStart:   rs.formatSprintDate(multisprintIssue.SprintInfo.FirstStart),
+			FirstSprintEnd:     rs.formatSprintDate(multisprintIssue.SprintInfo.FirstEnd),
+			LastSprintStart:    rs.formatSprintDate(multisprintIssue.SprintInfo.LastStart),
+			LastSprintEnd:      rs.formatSprintDate(multisprintIssue.SprintInfo.LastEnd),
+			Staleness:          multisprintIssue.Staleness,
+			CommittedAtStart:   multisprintIssue.CommittedAtStart,
+			EstimateChanged:    multisprintIssue.EstimateChanged,
+			OriginalSP:         multisprintIssue.OriginalSP,
+			AssigneeChanges:    multisprintIssue.AssigneeChanges,
+			Assignees:          multisprintIssue.Assignees,
+			Escalated:          multisprintIssue.Escalated,
+			UnestimatedSprints: multisprintIssue.UnestimatedSprints,
+			EstimatedLate:      multisprintIssue.EstimatedLate,
+			TimeInStatus:       multisprintIssue.TimeInStatus,
+			SinceLast:          multisprintIssue.SinceLast,
+			ChangesSinceLast:   multisprintIssue.ChangesSinceLast,
+			FirstReported:      multisprintIssue.FirstReported,
+			WeeksOnReport:      multisprintIssue.WeeksOnReport,
+			CurrentSprint:      strings.Join(multisprintIssue.SprintInfo.ActiveSprints, ", "),
+			InActiveSprint:     len(multisprintIssue.SprintInfo.ActiveSprints) > 0,
+			Group:              multisprintIssue.Group,
+			Instance:           issue.Instance,
+		}
+		if points, ok := StoryPointsValue(issue.Fields.StoryPoints); ok {
+			record.StoryPoints = &points
+		}
+		if score, ok := churnScore(multisprintIssue); ok {
+			score = math.Round(score*100) / 100
+			record.ChurnScore = &score
+		}
+		record.SpilloverScore, _ = rs.issueSpilloverScore(multisprintIssue, rs.startTime)
+		if rs.includeDescription {
+			record.Description = values["Description"]
+		}
+		if rs.includeComments {
+			commentCount := 0
+			if issue.Fields.Comment != nil {
+				commentCount = issue.Fields.Comment.Total
+			}
+			record.CommentCount = &commentCount
+		}
+		if rs.includeTime {
+			record.EstimateHours = secondsToHours(issue.Fields.TimeOriginalEstimate)
+			record.TimeSpentHours = secondsToHours(issue.Fields.TimeSpent)
+			record.TotalSpentHours = secondsToHours(issue.Fields.AggregateTimeSpent)
+		}
+		if rs.includeLinks {
+			record.BlockedBy, record.Blocks = rs.issueLinkKeys(issue)
+		}
+		if rs.sprintLinksEnabled {
+			record.FirstSprintURL = multisprintIssue.FirstSprintReportURL
+			record.LastSprintURL = multisprintIssue.LastSprintReportURL
+		}
+		for _, version := range issue.Fields.FixVersions {
+			record.FixVersions = append(record.FixVersions, version.Name)
+		}
+		posted.Issues = append(posted.Issues, record)
+	}
+
+	for _, stat := range report.ReleaseStats {
+		posted.Summary.Releases = append(posted.Summary.Releases, postedRelease{
+			Version: stat.Version, IssueCount: stat.IssueCount, StoryPoints: stat.StoryPoints,
+		})
+	}
+	return posted
+}
+
+/***********************************************************************************************************************************/
+// postReport POSTs a JSON document to -posturl, retrying on server errors
+//
+// Each attempt times out after 30 seconds. 5xx responses and connection failures are retried up to
+// three times, waiting 1, 2, then 4 seconds. Other responses are final.
+//
+// Parameters:
+//   postURL    - endpoint to POST to
+//   authHeader - optional "Name: value" header (a bare value is sent as Authorization)
+//   payload    - JSON document to send
+//
+// Returns:
+//   error - nil on a 2xx response, otherwise the last failure
+//
+// Side effects:
+//   - Logs the response status and any error body of each attempt
+func (rs *runState) postReport(postURL, authHeader string, payload []byte) error {
+	headerName, headerValue := "", ""
+	if authHeader != "" {
+		headerName, headerValue = "Authorization", authHeader
+		if name, value, found := strings.Cut(authHeader, ":"); found && !strings.Contains(name, " ") {
+			headerName, headerValue = strings.TrimSpace(name), strings.TrimSpace(value)
+		}
+	}
+
+	// A plain client so Jira credentials and the Jira rate limit never apply to the report endpoint
+	client := &http.Client{Timeout: 30 * time.Second}
+	const maxRetries = 3
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(1<<(attempt-1)) * time.Second
+			rs.writeLog("WARNING", fmt.Sprintf("Retrying report POST in %s (retry %d of %d)", delay, attempt, maxRetries))
+			rs.runHooks.retry(postURL, attempt+1, delay)
+			select {
+			case <-rs.runContext.Done():
+				return rs.runContext.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(rs.runContext, "POST", postURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create report POST request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if headerName != "" {
+			req.Header.Set(headerName, headerValue)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if rs.runContext.Err() != nil {
+				return rs.runContext.Err()
+			}
+			lastErr = fmt.Errorf("failed to POST report: %w", err)
+			rs.writeLog("WARNING", lastErr.Error())
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		if cerr := resp.Body.Close(); cerr != nil {
+			rs.writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+		}
+		if err != nil {
+			body = nil
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			rs.writeLog("INFO", fmt.Sprintf("Report POSTed to %s (HTTP %d)", postURL, resp.StatusCode))
+			return nil
+		}
+		lastErr = fmt.Errorf("HTTP %d posting report: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		rs.writeLog("WARNING", lastErr.Error())
+		if resp.StatusCode < 500 {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+/***********************************************************************************************************************************/
+// readSQLiteVarint decodes a SQLite variable-length integer
+//
+// Parameters:
+//   data - bytes starting with the varint
+//
+// Returns:
+//   uint64 - the value (rowids are this value as an int64)
+//   int    - bytes used, 0 if data ends before the varint does
+func readSQLiteVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(data) {
+			return 0, 0
+		}
+		if i == 8 {
+			return value<<8 | uint64(data[i]), 9
+		}
+		value = value<<7 | uint64(data[i]&0x7f)
+		if data[i] < 0x80 {
+			return value, i + 1
+		}
+	}
+	return value, 9
+}
+
+/***********************************************************************************************************************************/
+// appendSQLiteVarint encodes a SQLite variable-length integer: big-endian groups of 7 bits, with the ninth
+// byte (if needed) carrying a full 8 bits
+//
+// Parameters:
+//   data  - buffer to append to
+//   value - value to encode
+//
+// Returns:
+//   []byte - data with the varint appended
+func appendSQLiteVarint(data []byte, value uint64) []byte {
+	if value > 0x00ffffffffffffff {
+		for shift := 57; shift >= 8; shift -= 7 {
+			data = append(data, byte(value>>shift)|0x80)
+		}
+		return append(data, byte(value))
+	}
+	var groups [8]byte
+	n := 0
+	for {
+		groups[n] = byte(value&0x7f) | 0x80
+		n++
+		value >>= 7
+		if value == 0 {
+			break
+		}
+	}
+	groups[0] &= 0x7f // The lowest group ends the varint
+	for i := n - 1; i >= 0; i-- {
+		data = append(data, groups[i])
+	}
+	return data
+}
+
+/***********************************************************************************************************************************/
+// encodeSQLiteRecord encodes row values in the SQLite record format
+//
+// Parameters:
+//   values - column values: nil, int64, float64, string, or []byte; an INTEGER PRIMARY KEY column is nil,
+//            as its value is the rowid
+//
+// Returns:
+//   []byte - header of serial types followed by the values
+func encodeSQLiteRecord(values []any) []byte {
+	var types, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			types = appendSQLiteVarint(types, 0)
+		case int64:
+			switch {
+			case v == 0 || v == 1:
+				types = appendSQLiteVarint(types, uint64(8+v))
+			case v >= math.MinInt8 && v <= math.MaxInt8:
+				types, body = appendSQLiteVarint(types, 1), append(body, byte(v))
+			case v >= math.MinInt16 && v <= math.MaxInt16:
+				types, body = appendSQLiteVarint(types, 2), binary.BigEndian.AppendUint16(body, uint16(v))
+			case v >= math.MinInt32 && v <= math.MaxInt32:
+				types, body = appendSQLiteVarint(types, 4), binary.BigEndian.AppendUint32(body, uint32(v))
+			default:
+				types, body = appendSQLiteVarint(types, 6), binary.BigEndian.AppendUint64(body, uint64(v))
+			}
+		case float64:
+			types, body = appendSQLiteVarint(types, 7), binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types, body = appendSQLiteVarint(types, uint64(len(v))*2+13), append(body, v...)
+		case []byte:
+			types, body = appendSQLiteVarint(types, uint64(len(v))*2+12), append(body, v...)
+		}
+	}
+
+	// The header size counts itself, so its own varint may need more than one byte
+	headerSize := len(types) + 1
+	for len(appendSQLiteVarint(nil, uint64(headerSize))) > headerSize-len(types) {
+		headerSize++
+	}
+	record := appendSQLiteVarint(nil, uint64(headerSize))
+	record = append(record, types...)
+	return append(record, body...)
+}
+
+/***********************************************************************************************************************************/
+// decodeSQLiteRecord decodes a record in the SQLite record format
+//
+// Parameters:
+//   record - encoded record
+//
+// Returns:
+//   []any - column values: nil, int64, float64, string, or []byte
+//   error - the record is malformed
+func decodeSQLiteRecord(record []byte) ([]any, error) {
+	headerSize, n := readSQLiteVarint(record)
+	if n == 0 || headerSize > uint64(len(record)) {
+		return nil, errors.New("malformed record header")
+	}
+	var values []any
+	body := record[headerSize:]
+	for position := n; position < int(headerSize); {
+		serialType, used := readSQLiteVarint(record[position:int(headerSize)])
+		if used == 0 {
+			return nil, errors.New("malformed record header")
+		}
+		position += used
+
+		size := 0
+		switch {
+		case serialType >= 12:
+			size = int((serialType - 12) / 2)
+		case serialType >= 1 && serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6 || serialType == 7:
+			size = 8
+		case serialType == 10 || serialType == 11:
+			return nil, fmt.Errorf("reserved serial type %d", serialType)
+		}
+		if size > len(body) {
+			return nil, errors.New("record value runs past the end of the record")
+		}
+		field := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType == 8 || serialType == 9:
+			values = append(values, int64(serialType-8))
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(field)))
+		case serialType >= 12 && serialType%2 == 0:
+			values = append(values, append([]byte{}, field...))
+		case serialType >= 13:
+			values = append(values, string(field))
+		default:
+			// Big-endian two's complement integer of 1 to 8 bytes
+			value := int64(int8(field[0]))
+			for _, b := range field[1:] {
+				value = value<<8 | int64(b)
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+/***********************************************************************************************************************************/
+// sqliteLocalPayload returns how much of a table row's payload is stored in its b-tree cell, the rest going to
+// overflow pages
+//
+// Parameters:
+//   payloadSize - size of the record
+//   usableSize  - page size less the reserved bytes at the end of each page
+//
+// Returns:
+//   int - bytes stored in the cell
+func sqliteLocalPayload(payloadSize, usableSize int) int {
+	maxLocal := usableSize - 35
+	if payloadSize <= maxLocal {
+		return payloadSize
+	}
+	minLocal := (usableSize-12)*32/255 - 23
+	local := minLocal + (payloadSize-minLocal)%(usableSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	return local
+}
+
+/***********************************************************************************************************************************/
+// readSQLiteDatabase reads the schema and table rows of a SQLite database for -sqlitefile
+//
+// Only what rewriteSQLiteDatabase can write back is accepted: a UTF-8 database without WITHOUT ROWID tables,
+// whose indexes are CREATE INDEX statements on plain columns (see parseSQLiteIndex), and without a write-ahead
+// log or rollback journal holding changes not yet in the file.
+//
+// Parameters:
+//   filename - path of the database
+//
+// Returns:
+//   []sqliteObject - sqlite_schema entries in file order, with the rows of each table
+//   []byte         - the 100-byte database header
+//   error          - the file could not be read or cannot be preserved
+func readSQLiteDatabase(filename string) ([]sqliteObject, []byte, error) {
+	for _, suffix := range []string{"-wal", "-journal"} {
+		if info, err := os.Stat(filename + suffix); err == nil && info.Size() > 0 {
+			return nil, nil, fmt.Errorf("%s has a %s file with changes not yet in the database; open it with sqlite3 once to apply them", filename, suffix)
+		}
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < 100 || string(data[:16]) != sqliteHeaderMagic {
+		return nil, nil, fmt.Errorf("%s is not a SQLite database", filename)
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, nil, fmt.Errorf("%s has an invalid page size %d", filename, pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding != 1 {
+		return nil, nil, fmt.Errorf("%s uses a UTF-16 text encoding; only UTF-8 databases can be updated", filename)
+	}
+	reader := sqliteReader{data: data, pageSize: pageSize, usableSize: pageSize - int(data[20])}
+
+	schemaRows, err := reader.readTable(1, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the schema of %s: %w", filename, err)
+	}
+	var objects []sqliteObject
+	for _, row := range schemaRows {
+		values, err := decodeSQLiteRecord(row.Payload)
+		if err != nil || len(values) < 5 {
+			return nil, nil, fmt.Errorf("%s has a corrupt schema entry", filename)
+		}
+		object := sqliteObject{}
+		object.Type, _ = values[0].(string)
+		object.Name, _ = values[1].(string)
+		object.TableName, _ = values[2].(string)
+		object.RootPage, _ = values[3].(int64)
+		object.SQL, _ = values[4].(string)
+		if object.Type == "table" && object.RootPage > 0 {
+			if object.Rows, err = reader.readTable(int(object.RootPage), 0); err != nil {
+				return nil, nil, fmt.Errorf("table %s in %s cannot be preserved: %w", object.Name, filename, err)
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	// Indexes are not read but rebuilt from their table's rows, so the rewritten database can change the rows
+	for i := range objects {
+		if objects[i].Type != "index" {
+			continue
+		}
+		tableSQL := ""
+		for _, table := range objects {
+			if table.Type == "table" && strings.EqualFold(table.Name, objects[i].TableName) {
+				tableSQL = table.SQL
+			}
+		}
+		if objects[i].Index, err = parseSQLiteIndex(objects[i].SQL, tableSQL); err != nil {
+			return nil, nil, fmt.Errorf("%s has index %s, which -sqlitefile cannot rebuild (%v); drop it or use a new database file", filename, objects[i].Name, err)
+		}
+	}
+	return objects, data[:100], nil
+}
+
+/***********************************************************************************************************************************/
+// readTable reads every row of a table b-tree in rowid order
+//
+// Parameters:
+//   pageNumber - root page of the table, or of the subtree being read
+//   depth      - levels above this page, to stop on a corrupt file whose pages form a cycle
+//
+// Returns:
+//   []sqliteRow - rows with their payloads (overflow pages included)
+//   error       - the page is not a table b-tree page or is corrupt
+func (r sqliteReader) readTable(pageNumber, depth int) ([]sqliteRow, error) {
+	page, offset, err := r.page(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	if depth > 40 {
+		return nil, errors.New("b-tree too deep")
+	}
+	cellCount := int(binary.BigEndian.Uint16(page[offset+3:]))
+
+	var rows []sqliteRow
+	switch page[offset] {
+	case sqliteLeafTablePage:
+		for i := 0; i < cellCount; i++ {
+			pointerAt := offset + 8 + 2*i
+			if pointerAt+2 > len(page) {
+				return nil, fmt.Errorf("page %d is corrupt", pageNumber)
+			}
+			cell := int(binary.BigEndian.Uint16(page[pointerAt:]))
+			row, err := r.leafCell(page, cell)
+			if err != nil {
+				return nil, fmt.Errorf("page %d: %w", pageNumber, err)
+			}
+			rows = append(rows, row)
+		}
+	case sqliteInteriorTablePage:
+		children := make([]int, 0, cellCount+1)
+		for i := 0; i < cellCount; i++ {
+			pointerAt := offset + 12 + 2*i
+			if pointerAt+2 > len(page) {
+				return nil, fmt.Errorf("page %d is corrupt", pageNumber)
+			}
+			cell := int(binary.BigEndian.Uint16(page[pointerAt:]))
+			if cell+4 > len(page) {
+				return nil, fmt.Errorf("page %d is corrupt", pageNumber)
+			}
+			children = append(children, int(binary.BigEndian.Uint32(page[cell:])))
+		}
+		children = append(children, int(binary.BigEndian.Uint32(page[offset+8:])))
+		for _, child := range children {
+			childRows, err := r.readTable(child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, childRows...)
+		}
+	default:
+		return nil, fmt.Errorf("page %d is not a table b-tree page", pageNumber)
+	}
+	return rows, nil
+}
+
+/***********************************************************************************************************************************/
+// leafCell reads one row from a table leaf page, following its overflow pages
+//
+// Parameters:
+//   page - the leaf page
+//   cell - offset of the cell in the page
+//
+// Returns:
+//   sqliteRow - the row
+//   error     - the cell or its overflow chain is corrupt
+func (r sqliteReader) leafCell(page []byte, cell int) (sqliteRow, error) {
+	if cell >= len(page) {
+		return sqliteRow{}, errors.New("cell outside the page")
+	}
+	payloadSize, n := readSQLiteVarint(page[cell:])
+	rowID, m := readSQLiteVarint(page[cell+n:])
+	if n == 0 || m == 0 || payloadSize > uint64(len(r.data)) {
+		return sqliteRow{}, errors.New("corrupt cell")
+	}
+	start := cell + n + m
+	local := sqliteLocalPayload(int(payloadSize), r.usableSize)
+	if start+local > len(page) {
+		return sqliteRow{}, errors.New("corrupt cell")
+	}
+	payload := append(make([]byte, 0, payloadSize), page[start:start+local]...)
+
+	if local < int(payloadSize) {
+		if start+local+4 > len(page) {
+			return sqliteRow{}, errors.New("corrupt cell")
+		}
+		next := int(binary.BigEndian.Uint32(page[start+local:]))
+		for len(payload) < int(payloadSize) {
+			overflow, _, err := r.page(next)
+			if err != nil {
+				return sqliteRow{}, fmt.Errorf("overflow chain: %w", err)
+			}
+			chunk := min(int(payloadSize)-len(payload), r.usableSize-4)
+			payload = append(payload, overflow[4:4+chunk]...)
+			next = int(binary.BigEndian.Uint32(overflow))
+		}
+	}
+	return sqliteRow{RowID: int64(rowID), Payload: payload}, nil
+}
+
+/***********************************************************************************************************************************/
+// page returns a page of the database being read
+//
+// Parameters:
+//   pageNumber - page number, from 1
+//
+// Returns:
+//   []byte - the page
+//   int    - offset of the b-tree page header (100 on page 1, after the database header)
+//   error  - the page is outside the file
+func (r sqliteReader) page(pageNumber int) ([]byte, int, error) {
+	if pageNumber < 1 || pageNumber*r.pageSize > len(r.data) {
+		return nil, 0, fmt.Errorf("page %d is outside the file", pageNumber)
+	}
+	offset := 0
+	if pageNumber == 1 {
+		offset = 100
+	}
+	return r.data[(pageNumber-1)*r.pageSize : pageNumber*r.pageSize], offset, nil
+}
+
+/***********************************************************************************************************************************/
+// allocate adds an empty page to the database being written
+//
+// Returns:
+//   int - number of the new page
+func (w *sqliteWriter) allocate() int {
+	w.pages = append(w.pages, make([]byte, w.pageSize))
+	return len(w.pages)
+}
+
+/***********************************************************************************************************************************/
+// leafCell builds a table leaf cell, moving the part of the payload that does not fit to overflow pages
+//
+// Parameters:
+//   row - the row
+//
+// Returns:
+//   []byte - the cell
+func (w *sqliteWriter) leafCell(row sqliteRow) []byte {
+	cell := appendSQLiteVarint(nil, uint64(len(row.Payload)))
+	cell = appendSQLiteVarint(cell, uint64(row.RowID))
+	return w.payloadCell(cell, row.Payload, sqliteLocalPayload(len(row.Payload), w.pageSize))
+}
+
+/***********************************************************************************************************************************/
+// payloadCell completes a b-tree cell with its payload, moving the part that does not fit to overflow pages
+//
+// Parameters:
+//   cell    - the cell so far (its payload size, and rowid for a table cell)
+//   payload - the record
+//   local   - bytes of the payload kept in the cell
+//
+// Returns:
+//   []byte - the cell, ending with the first overflow page number if the payload overflows
+func (w *sqliteWriter) payloadCell(cell, payload []byte, local int) []byte {
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+
+	rest := payload[local:]
+	first := 0
+	var link []byte // Next-page field of the previous overflow page
+	for len(rest) > 0 {
+		pageNumber := w.allocate()
+		page := w.pages[pageNumber-1]
+		if link == nil {
+			first = pageNumber
+		} else {
+			binary.BigEndian.PutUint32(link, uint32(pageNumber))
+		}
+		rest = rest[copy(page[4:], rest):]
+		link = page[:4]
+	}
+	return binary.BigEndian.AppendUint32(cell, uint32(first))
+}
+
+/***********************************************************************************************************************************/
+// writePage lays out a b-tree page: header, cell pointers from the front, and cells from the back
+//
+// Parameters:
+//   pageNumber - page to write
+//   pageType   - sqliteLeafTablePage, sqliteInteriorTablePage, sqliteLeafIndexPage, or sqliteInteriorIndexPage
+//   cells      - cells in key order
+//   rightChild - right-most child page (interior pages only)
+func (w *sqliteWriter) writePage(pageNumber int, pageType byte, cells [][]byte, rightChild int) {
+	page := w.pages[pageNumber-1]
+	offset := 0
+	if pageNumber == 1 {
+		offset = 100
+	}
+	pointer := offset + sqlitePageHeaderSize(pageType)
+	content := w.pageSize
+	for _, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointer:], uint16(content))
+		pointer += 2
+	}
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content)) // 65536 is stored as 0
+	if pageType == sqliteInteriorTablePage || pageType == sqliteInteriorIndexPage {
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightChild))
+	}
+}
+
+/***********************************************************************************************************************************/
+// writeTable writes rows as a table b-tree, adding interior levels until the root page holds them all
+//
+// Parameters:
+//   rows - rows in ascending rowid order
+//   root - page to use as the root (1 for sqlite_schema), or 0 to allocate one
+//
+// Returns:
+//   int - root page of the table
+func (w *sqliteWriter) writeTable(rows []sqliteRow, root int) int {
+	rootOffset := 0
+	if root == 1 {
+		rootOffset = 100
+	}
+
+	// Leaf level: pack the cells into as few pages as possible
+	cells := make([][]byte, len(rows))
+	size := 0
+	for i, row := range rows {
+		cells[i] = w.leafCell(row)
+		size += len(cells[i]) + 2
+	}
+	if size <= w.pageSize-rootOffset-sqlitePageHeaderSize(sqliteLeafTablePage) {
+		if root == 0 {
+			root = w.allocate()
+		}
+		w.writePage(root, sqliteLeafTablePage, cells, 0)
+		return root
+	}
+	type child struct {
+		page int
+		key  int64 // Largest rowid under the page
+	}
+	var children []child
+	for start := 0; start < len(cells); {
+		end, used := start, 0
+		for end < len(cells) && used+len(cells[end])+2 <= w.pageSize-sqlitePageHeaderSize(sqliteLeafTablePage) {
+			used += len(cells[end]) + 2
+			end++
+		}
+		page := w.allocate()
+		w.writePage(page, sqliteLeafTablePage, cells[start:end], 0)
+		children = append(children, child{page: page, key: rows[end-1].RowID})
+		start = end
+	}
+
+	// Interior levels: each page points to its last child with the right-child pointer and to the others with cells
+	interiorCell := func(c child) []byte {
+		return appendSQLiteVarint(binary.BigEndian.AppendUint32(nil, uint32(c.page)), uint64(c.key))
+	}
+	for {
+		size = 0
+		for _, c := range children[:len(children)-1] {
+			size += len(interiorCell(c)) + 2
+		}
+		if size <= w.pageSize-rootOffset-sqlitePageHeaderSize(sqliteInteriorTablePage) {
+			cells = cells[:0]
+			for _, c := range children[:len(children)-1] {
+				cells = append(cells, interiorCell(c))
+			}
+			if root == 0 {
+				root = w.allocate()
+			}
+			w.writePage(root, sqliteInteriorTablePage, cells, children[len(children)-1].page)
+			return root
+		}
+		var parents []child
+		for start := 0; start < len(children); {
+			end, used := start+1, 0
+			for end < len(children) && used+len(interiorCell(children[end-1]))+2 <= w.pageSize-sqlitePageHeaderSize(sqliteInteriorTablePage) {
+				used += len(interiorCell(children[end-1])) + 2
+				end++
+			}
+			// Leave at least two children for the last page of the level
+			if len(children)-end == 1 && end-start >= 3 {
+				end--
+			}
+			cells = cells[:0]
+			for _, c := range children[start : end-1] {
+				cells = append(cells, interiorCell(c))
+			}
+			page := w.allocate()
+			w.writePage(page, sqliteInteriorTablePage, cells, children[end-1].page)
+			parents = append(parents, child{page: page, key: children[end-1].key})
+			start = end
+		}
+		children = parents
+	}
+}
+
+/***********************************************************************************************************************************/
+// sqlitePageHeaderSize returns the size of a b-tree page header
+//
+// Parameters:
+//   pageType - sqliteLeafTablePage, sqliteInteriorTablePage, sqliteLeafIndexPage, or sqliteInteriorIndexPage
+//
+// Returns:
+//   int - 8 for leaf pages, 12 for interior pages (which add the right-child pointer)
+func sqlitePageHeaderSize(pageType byte) int {
+	if pageType == sqliteInteriorTablePage || pageType == sqliteInteriorIndexPage {
+		return 12
+	}
+	return 8
+}
+
+/***********************************************************************************************************************************/
+// tokenizeSQLiteSQL splits a CREATE statement from sqlite_schema into tokens
+//
+// Parameters:
+//   sql - the statement
+//
+// Returns:
+//   []sqliteToken - identifiers, keywords, numbers, quoted names and strings, and punctuation; comments are dropped
+func tokenizeSQLiteSQL(sql string) []sqliteToken {
+	var tokens []sqliteToken
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+		case c == '"' || c == '`' || c == '\'' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			var text strings.Builder
+			i++
+			for i < len(sql) {
+				if sql[i] == closing {
+					// A doubled quote stands for the quote itself ([names] have no escape)
+					if closing != ']' && i+1 < len(sql) && sql[i+1] == closing {
+						text.WriteByte(closing)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text.WriteByte(sql[i])
+				i++
+			}
+			tokens = append(tokens, sqliteToken{Text: text.String(), Quoted: true})
+		case c == '_' || c == '$' || c == '.' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			start := i
+			for i < len(sql) && (sql[i] == '_' || sql[i] == '$' || sql[i] == '.' || sql[i] >= 0x80 ||
+				unicode.IsLetter(rune(sql[i])) || unicode.IsDigit(rune(sql[i]))) {
+				i++
+			}
+			tokens = append(tokens, sqliteToken{Text: sql[start:i]})
+		default:
+			tokens = append(tokens, sqliteToken{Text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+/***********************************************************************************************************************************/
+// isSQLiteKeyword reports whether a token is the given keyword (unquoted, any case)
+//
+// Parameters:
+//   token   - the token
+//   keyword - upper case keyword
+//
+// Returns:
+//   bool - true if the token is the keyword
+func isSQLiteKeyword(token sqliteToken, keyword string) bool {
+	return !token.Quoted && strings.EqualFold(token.Text, keyword)
+}
+
+/***********************************************************************************************************************************/
+// splitSQLiteList returns the comma-separated items of the parenthesised list that starts at tokens[open]
+//
+// Parameters:
+//   tokens - statement tokens
+//   open   - index of the "(" token
+//
+// Returns:
+//   [][]sqliteToken - the items, nested parentheses kept whole
+//   int             - index just after the closing ")"
+//   error           - the list is not closed
+func splitSQLiteList(tokens []sqliteToken, open int) ([][]sqliteToken, int, error) {
+	var items [][]sqliteToken
+	var item []sqliteToken
+	depth := 0
+	for i := open + 1; i < len(tokens); i++ {
+		token := tokens[i]
+		if !token.Quoted {
+			switch token.Text {
+			case "(":
+				depth++
+			case ")":
+				if depth == 0 {
+					return append(items, item), i + 1, nil
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					items = append(items, item)
+					item = nil
+					continue
+				}
+			}
+		}
+		item = append(item, token)
+	}
+	return nil, 0, errors.New("unbalanced parentheses")
+}
+
+/***********************************************************************************************************************************/
+// parseSQLiteTableColumns reads the columns of a CREATE TABLE statement
+//
+// Parameters:
+//   sql - the CREATE TABLE statement
+//
+// Returns:
+//   []sqliteColumn - the columns in record order
+//   error          - the statement has no column list, or has generated columns (which are not stored in order)
+func parseSQLiteTableColumns(sql string) ([]sqliteColumn, error) {
+	tokens := tokenizeSQLiteSQL(sql)
+	open := -1
+	for i, token := range tokens {
+		if !token.Quoted && token.Text == "(" {
+			open = i
+			break
+		}
+	}
+	if open < 0 {
+		return nil, errors.New("its table has no column list")
+	}
+	definitions, _, err := splitSQLiteList(tokens, open)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keywords that end a column's declared type and start its constraints
+	constraintKeywords := []string{"CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK", "DEFAULT", "COLLATE", "REFERENCES", "GENERATED", "AS"}
+	isConstraint := func(token sqliteToken) bool {
+		for _, keyword := range constraintKeywords {
+			if isSQLiteKeyword(token, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var columns []sqliteColumn
+	var integer []bool // Whether each column's declared type is exactly INTEGER
+	tablePrimaryKey := ""
+	for _, definition := range definitions {
+		if len(definition) == 0 {
+			return nil, errors.New("its table has an empty column definition")
+		}
+		first := definition[0]
+		if isSQLiteKeyword(first, "CONSTRAINT") || isSQLiteKeyword(first, "PRIMARY") || isSQLiteKeyword(first, "UNIQUE") ||
+			isSQLiteKeyword(first, "CHECK") || isSQLiteKeyword(first, "FOREIGN") {
+			// A table PRIMARY KEY of one INTEGER column makes that column the rowid, like a column PRIMARY KEY
+			for i := 0; i+3 < len(definition); i++ {
+				if isSQLiteKeyword(definition[i], "PRIMARY") && isSQLiteKeyword(definition[i+1], "KEY") && definition[i+2].Text == "(" {
+					if i+4 < len(definition) && definition[i+4].Text == ")" ||
+						i+5 < len(definition) && definition[i+5].Text == ")" && (isSQLiteKeyword(definition[i+4], "ASC") || isSQLiteKeyword(definition[i+4], "DESC")) {
+						tablePrimaryKey = definition[i+3].Text
+					}
+				}
+			}
+			continue
+		}
+
+		column := sqliteColumn{Name: first.Text, Collation: "BINARY"}
+		var declaredType []string
+		i := 1
+		for ; i < len(definition) && !isConstraint(definition[i]); i++ {
+			if definition[i].Text == "(" {
+				// Type arguments such as VARCHAR(20) do not change the type name
+				for i < len(definition) && definition[i].Text != ")" {
+					i++
+				}
+				continue
+			}
+			declaredType = append(declaredType, strings.ToUpper(definition[i].Text))
+		}
+		for ; i < len(definition); i++ {
+			switch {
+			case isSQLiteKeyword(definition[i], "GENERATED") || isSQLiteKeyword(definition[i], "AS"):
+				return nil, fmt.Errorf("its table has generated column %s", column.Name)
+			case isSQLiteKeyword(definition[i], "DEFAULT"):
+				column.HasDefault = true
+			case isSQLiteKeyword(definition[i], "COLLATE") && i+1 < len(definition):
+				column.Collation = strings.ToUpper(definition[i+1].Text)
+			case isSQLiteKeyword(definition[i], "PRIMARY") && i+1 < len(definition) && isSQLiteKeyword(definition[i+1], "KEY"):
+				// INTEGER PRIMARY KEY DESC is the one spelling that does not make the column the rowid
+				descending := i+2 < len(definition) && isSQLiteKeyword(definition[i+2], "DESC")
+				column.RowID = strings.Join(declaredType, " ") == "INTEGER" && !descending
+			}
+		}
+		columns = append(columns, column)
+		integer = append(integer, strings.Join(declaredType, " ") == "INTEGER")
+	}
+	for i := range columns {
+		if tablePrimaryKey != "" && strings.EqualFold(columns[i].Name, tablePrimaryKey) {
+			columns[i].RowID = integer[i]
+		}
+	}
+	return columns, nil
+}
+
+/***********************************************************************************************************************************/
+// parseSQLiteIndex reads a CREATE INDEX statement so the index can be rebuilt
+//
+// Indexes on plain columns, with ASC or DESC and the BINARY, NOCASE, or RTRIM collation, are supported.
+// Indexes on expressions, partial indexes (WHERE), and the automatic indexes behind UNIQUE and PRIMARY KEY
+// constraints are not.
+//
+// Parameters:
+//   indexSQL - the CREATE INDEX statement ("" for an automatic index)
+//   tableSQL - the CREATE TABLE statement of the indexed table
+//
+// Returns:
+//   *sqliteIndex - how to rebuild the index
+//   error        - the index is not supported
+func parseSQLiteIndex(indexSQL, tableSQL string) (*sqliteIndex, error) {
+	if indexSQL == "" {
+		return nil, errors.New("it is created by a UNIQUE or PRIMARY KEY constraint")
+	}
+	if tableSQL == "" {
+		return nil, errors.New("its table was not found")
+	}
+	columns, err := parseSQLiteTableColumns(tableSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := tokenizeSQLiteSQL(indexSQL)
+	index := &sqliteIndex{}
+	open := -1
+	for i, token := range tokens {
+		if isSQLiteKeyword(token, "UNIQUE") && open < 0 {
+			index.Unique = true
+		}
+		if isSQLiteKeyword(token, "ON") && i+2 < len(tokens) && tokens[i+2].Text == "(" {
+			open = i + 2
+			break
+		}
+	}
+	if open < 0 {
+		return nil, errors.New("its CREATE INDEX statement was not understood")
+	}
+	items, end, err := splitSQLiteList(tokens, open)
+	if err != nil {
+		return nil, err
+	}
+	if end < len(tokens) {
+		return nil, errors.New("it is a partial index")
+	}
+
+	for _, item := range items {
+		if len(item) == 0 {
+			return nil, errors.New("it has an empty column")
+		}
+		column := sqliteIndexColumn{Name: item[0].Text, Position: -2}
+		for position, tableColumn := range columns {
+			if strings.EqualFold(tableColumn.Name, column.Name) {
+				column.Position, column.Collation, column.HasDefault = position, tableColumn.Collation, tableColumn.HasDefault
+				if tableColumn.RowID {
+					column.Position = -1
+				}
+			}
+		}
+		if column.Position == -2 {
+			return nil, fmt.Errorf("it is on an expression or unknown column %s", column.Name)
+		}
+		rest := item[1:]
+		if len(rest) >= 2 && isSQLiteKeyword(rest[0], "COLLATE") {
+			column.Collation = strings.ToUpper(rest[1].Text)
+			rest = rest[2:]
+		}
+		if len(rest) == 1 && (isSQLiteKeyword(rest[0], "ASC") || isSQLiteKeyword(rest[0], "DESC")) {
+			column.Descending = isSQLiteKeyword(rest[0], "DESC")
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("it is on an expression of column %s", column.Name)
+		}
+		if column.Collation != "BINARY" && column.Collation != "NOCASE" && column.Collation != "RTRIM" {
+			return nil, fmt.Errorf("it uses collation %s", column.Collation)
+		}
+		index.Columns = append(index.Columns, column)
+	}
+	return index, nil
+}
+
+/***********************************************************************************************************************************/
+// compareSQLiteValues orders two values the way SQLite orders index keys
+//
+// NULL sorts first, then numbers (integers and reals compared by value), then text by collation, then blobs.
+//
+// Parameters:
+//   a, b      - values as decodeSQLiteRecord returns them
+//   collation - BINARY, NOCASE (ASCII letters compare without case), or RTRIM (trailing spaces are ignored)
+//
+// Returns:
+//   int - negative if a sorts first, positive if b does, 0 if they are equal
+func compareSQLiteValues(a, b any, collation string) int {
+	class := func(value any) int {
+		switch value.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		}
+		return 3
+	}
+	if classA, classB := class(a), class(b); classA != classB {
+		return classA - classB
+	}
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+		return compareSQLiteValues(float64(a), b, collation)
+	case float64:
+		bValue, ok := b.(float64)
+		if !ok {
+			bValue = float64(b.(int64))
+		}
+		switch {
+		case a < bValue:
+			return -1
+		case a > bValue:
+			return 1
+		}
+		return 0
+	case string:
+		b := b.(string)
+		switch collation {
+		case "NOCASE":
+			return bytes.Compare(sqliteNoCase(a), sqliteNoCase(b))
+		case "RTRIM":
+			return strings.Compare(strings.TrimRight(a, " "), strings.TrimRight(b, " "))
+		}
+		return strings.Compare(a, b)
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
+
+/***********************************************************************************************************************************/
+// sqliteNoCase folds the ASCII letters of text to lower case, as the SQLite NOCASE collation does
+//
+// Parameters:
+//   text - the text
+//
+// Returns:
+//   []byte - the folded text
+func sqliteNoCase(text string) []byte {
+	folded := []byte(text)
+	for i, c := range folded {
+		if c >= 'A' && c <= 'Z' {
+			folded[i] = c + 'a' - 'A'
+		}
+	}
+	return folded
+}
+
+/***********************************************************************************************************************************/
+// buildSQLiteIndex returns the keys of an index over a table's rows, in index order
+//
+// Each key is a record of the indexed column values followed by the rowid, which also orders equal values.
+//
+// Parameters:
+//   name  - index name, for error messages
+//   index - how the index is built
+//   rows  - the table's rows
+//
+// Returns:
+//   [][]byte - the keys in the SQLite record format
+//   error    - a row cannot be indexed, or two rows have the same key in a UNIQUE index
+func buildSQLiteIndex(name string, index *sqliteIndex, rows []sqliteRow) ([][]byte, error) {
+	keys := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values, err := decodeSQLiteRecord(row.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("row %d of the table of index %s: %w", row.RowID, name, err)
+		}
+		key := make([]any, 0, len(index.Columns)+1)
+		for _, column := range index.Columns {
+			switch {
+			case column.Position < 0:
+				key = append(key, row.RowID)
+			case column.Position < len(values):
+				key = append(key, values[column.Position])
+			case column.HasDefault:
+				// SQLite supplies the default to rows written before ALTER TABLE added the column
+				return nil, fmt.Errorf("index %s cannot be rebuilt: row %d predates column %s, whose default is not known", name, row.RowID, column.Name)
+			default:
+				key = append(key, nil)
+			}
+		}
+		keys = append(keys, append(key, row.RowID))
+	}
+
+	// compareColumns orders two keys by their indexed columns, without the rowid
+	compareColumns := func(a, b []any) int {
+		for i, column := range index.Columns {
+			if order := compareSQLiteValues(a[i], b[i], column.Collation); order != 0 {
+				if column.Descending {
+					return -order
+				}
+				return order
+			}
+		}
+		return 0
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if order := compareColumns(keys[i], keys[j]); order != 0 {
+			return order < 0
+		}
+		return keys[i][len(keys[i])-1].(int64) < keys[j][len(keys[j])-1].(int64)
+	})
+
+	records := make([][]byte, len(keys))
+	for i, key := range keys {
+		if index.Unique && i > 0 && compareColumns(keys[i-1], key) == 0 {
+			// NULLs never equal each other, so only keys without one collide
+			hasNull := false
+			for _, value := range key[:len(key)-1] {
+				hasNull = hasNull || value == nil
+			}
+			if !hasNull {
+				return nil, fmt.Errorf("rows %d and %d have the same key in UNIQUE index %s", keys[i-1][len(key)-1], key[len(key)-1], name)
+			}
+		}
+		records[i] = encodeSQLiteRecord(key)
+	}
+	return records, nil
+}
+
+/***********************************************************************************************************************************/
+// sqliteIndexLocalPayload returns how much of an index key is stored in its b-tree cell, the rest going to
+// overflow pages
+//
+// Parameters:
+//   payloadSize - size of the key record
+//   usableSize  - page size less the reserved bytes at the end of each page
+//
+// Returns:
+//   int - bytes stored in the cell
+func sqliteIndexLocalPayload(payloadSize, usableSize int) int {
+	maxLocal := (usableSize-12)*64/255 - 23
+	if payloadSize <= maxLocal {
+		return payloadSize
+	}
+	minLocal := (usableSize-12)*32/255 - 23
+	local := minLocal + (payloadSize-minLocal)%(usableSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	return local
+}
+
+/***********************************************************************************************************************************/
+// writeIndex writes index keys as an index b-tree
+//
+// Unlike a table, an index keeps keys on its interior pages: the key after each full page moves up a level to
+// separate that page from the next.
+//
+// Parameters:
+//   keys - keys in index order
+//
+// Returns:
+//   int - root page of the index
+func (w *sqliteWriter) writeIndex(keys [][]byte) int {
+	// Cells without the left-child pointer, which interior cells add in front
+	cells := make([][]byte, len(keys))
+	for i, key := range keys {
+		cells[i] = w.payloadCell(appendSQLiteVarint(nil, uint64(len(key))), key, sqliteIndexLocalPayload(len(key), w.pageSize))
+	}
+
+	// Leaf level: fill each page, leaving the next key as the separator
+	leafSpace := w.pageSize - sqlitePageHeaderSize(sqliteLeafIndexPage)
+	type leaf struct{ start, end int } // The page holds cells[start:end]; cells[end] follows it
+	var leaves []leaf
+	for start := 0; start < len(cells); {
+		end, used := start, 0
+		for end < len(cells) && used+len(cells[end])+2 <= leafSpace {
+			used += len(cells[end]) + 2
+			end++
+		}
+		leaves = append(leaves, leaf{start, end})
+		start = end + 1
+	}
+	if len(leaves) <= 1 {
+		root := w.allocate()
+		w.writePage(root, sqliteLeafIndexPage, cells, 0)
+		return root
+	}
+	// The last separator needs a page after it: give that page the last key and use the one before as the separator
+	if last := &leaves[len(leaves)-1]; last.end == len(cells)-1 && last.end-last.start >= 2 {
+		last.end--
+		leaves = append(leaves, leaf{len(cells) - 1, len(cells)})
+	}
+
+	type child struct {
+		page      int
+		separator []byte // Cell of the key that follows the page (nil for the last page)
+	}
+	children := make([]child, len(leaves))
+	for i, l := range leaves {
+		children[i].page = w.allocate()
+		w.writePage(children[i].page, sqliteLeafIndexPage, cells[l.start:l.end], 0)
+		if l.end < len(cells) {
+			children[i].separator = cells[l.end]
+		}
+	}
+
+	// Interior levels: each page points to its last child with the right-child pointer and to the others with cells
+	interiorSpace := w.pageSize - sqlitePageHeaderSize(sqliteInteriorIndexPage)
+	interiorCell := func(c child) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(c.page)), c.separator...)
+	}
+	for {
+		size := 0
+		for _, c := range children[:len(children)-1] {
+			size += len(interiorCell(c)) + 2
+		}
+		if size <= interiorSpace {
+			pageCells := make([][]byte, 0, len(children)-1)
+			for _, c := range children[:len(children)-1] {
+				pageCells = append(pageCells, interiorCell(c))
+			}
+			root := w.allocate()
+			w.writePage(root, sqliteInteriorIndexPage, pageCells, children[len(children)-1].page)
+			return root
+		}
+		var parents []child
+		for start := 0; start < len(children); {
+			end, used := start+1, 0
+			for end < len(children) && used+len(interiorCell(children[end-1]))+2 <= interiorSpace {
+				used += len(interiorCell(children[end-1])) + 2
+				end++
+			}
+			// Leave at least two children for the last page of the level
+			if len(children)-end == 1 && end-start >= 3 {
+				end--
+			}
+			var pageCells [][]byte
+			for _, c := range children[start : end-1] {
+				pageCells = append(pageCells, interiorCell(c))
+			}
+			page := w.allocate()
+			w.writePage(page, sqliteInteriorIndexPage, pageCells, children[end-1].page)
+			// The separator after this page's last child moves up to separate this page from the next
+			parents = append(parents, child{page: page, separator: children[end-1].separator})
+			start = end
+		}
+		children = parents
+	}
+}
+
+/***********************************************************************************************************************************/
+// rewriteSQLiteDatabase writes a complete SQLite database file holding the given schema and rows
+//
+// The database is written to a temporary file beside filename and renamed over it, so the update is all or
+// nothing: an interrupted run leaves the previous database untouched. The user version and application ID of
+// the previous database are kept, and its change counter and schema cookie are advanced so open connections
+// notice the change. Indexes are rebuilt from the rows of their tables.
+//
+// Parameters:
+//   filename - path of the database
+//   objects  - sqlite_schema entries in order, with the rows of each table
+//   previous - the previous database header (nil for a new database)
+//
+// Returns:
+//   error - any error writing or replacing the file, or an index that cannot be rebuilt from the rows
+func rewriteSQLiteDatabase(filename string, objects []sqliteObject, previous []byte) error {
+	writer := &sqliteWriter{pageSize: sqlitePageSize}
+	writer.allocate() // Page 1 is the sqlite_schema root, written last once the table roots are known
+
+	schemaRows := make([]sqliteRow, 0, len(objects))
+	for i, object := range objects {
+		rootPage := object.RootPage
+		switch {
+		case object.Type == "table" && rootPage > 0:
+			rootPage = int64(writer.writeTable(object.Rows, 0))
+		case object.Type == "index" && object.Index != nil:
+			var rows []sqliteRow
+			for _, table := range objects {
+				if table.Type == "table" && strings.EqualFold(table.Name, object.TableName) {
+					rows = table.Rows
+				}
+			}
+			keys, err := buildSQLiteIndex(object.Name, object.Index, rows)
+			if err != nil {
+				return err
+			}
+			rootPage = int64(writer.writeIndex(keys))
+		}
+		values := []any{object.Type, object.Name, object.TableName, rootPage, object.SQL}
+		schemaRows = append(schemaRows, sqliteRow{RowID: int64(i + 1), Payload: encodeSQLiteRecord(values)})
+	}
+	writer.writeTable(schemaRows, 1)
+
+	var changeCounter, schemaCookie uint32
+	header := writer.pages[0][:100]
+	copy(header, sqliteHeaderMagic)
+	if previous != nil {
+		changeCounter = binary.BigEndian.Uint32(previous[24:28])
+		schemaCookie = binary.BigEndian.Uint32(previous[40:44])
+		copy(header[48:52], previous[48:52]) // Suggested cache size
+		copy(header[60:64], previous[60:64]) // User version
+		copy(header[68:72], previous[68:72]) // Application ID
+	}
+	binary.BigEndian.PutUint16(header[16:], uint16(sqlitePageSize))
+	header[18], header[19] = 1, 1 // Rollback journal, not WAL
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], changeCounter+1)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(writer.pages)))
+	binary.BigEndian.PutUint32(header[40:], schemaCookie+1)
+	binary.BigEndian.PutUint32(header[44:], 4) // Schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[92:], changeCounter+1)
+	binary.BigEndian.PutUint32(header[96:], sqliteWriterVersion)
+
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	tempPath := filename + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	for _, page := range writer.pages {
+		if _, err = file.Write(page); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, filename)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+	}
+	return err
+}
+
+/***********************************************************************************************************************************/
+// exportSQLite adds a run and its spillover issues to a SQLite database (-sqlitefile)
+//
+// The runs, issues, and sprints tables are created if the database does not have them, so the same file
+// collects every run. Each run adds one runs row, one issues row per spillover issue (run_id refers to the
+// run), and one sprints row per issue and sprint (issue_id refers to the issue). Other tables, views, and
+// triggers already in the database are kept.
+//
+// Parameters:
+//   filename - path of the database, created if it does not exist
+//   posted   - the run as it would be sent to -posturl
+//
+// Returns:
+//   error - the database could not be read or written
+func (rs *runState) exportSQLite(filename string, posted PostedReport) error {
+	var objects []sqliteObject
+	var previous []byte
+	if _, err := os.Stat(filename); err == nil {
+		if objects, previous, err = readSQLiteDatabase(filename); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Create the tables the database does not have yet
+	tables := make(map[string]*sqliteObject)
+	for _, table := range sqliteExportTables {
+		found := false
+		for i := range objects {
+			if objects[i].Type == "table" && strings.EqualFold(objects[i].Name, table.Name) {
+				found = true
+			}
+		}
+		if !found {
+			objects = append(objects, sqliteObject{Type: "table", Name: table.Name, TableName: table.Name, RootPage: 1, SQL: table.SQL})
+		}
+	}
+	for i := range objects {
+		if objects[i].Type == "table" {
+			tables[strings.ToLower(objects[i].Name)] = &objects[i]
+		}
+	}
+
+	// nextRowID continues a table's rowids after its last row
+	nextRowID := func(table *sqliteObject) int64 {
+		if len(table.Rows) == 0 {
+			return 1
+		}
+		return table.Rows[len(table.Rows)-1].RowID + 1
+	}
+	// optional stores an empty string as NULL
+	optional := func(value string) any {
+		if value == "" {
+			return nil
+		}
+		return value
+	}
+	// number stores a missing number as NULL
+	number := func(value *float64) any {
+		if value == nil {
+			return nil
+		}
+		return *value
+	}
+	boolean := func(value bool) any {
+		if value {
+			return int64(1)
+		}
+		return int64(0)
+	}
+
+	runs, issues, sprints := tables["runs"], tables["issues"], tables["sprints"]
+	jiraBaseURL := posted.Run.JiraBaseURL
+	if jiraBaseURL == "" {
+		var urls []string
+		for _, server := range posted.Run.JiraServers {
+			urls = append(urls, server.BaseURL)
+		}
+		jiraBaseURL = strings.Join(urls, " ")
+	}
+	runID := nextRowID(runs)
+	runs.Rows = append(runs.Rows, sqliteRow{RowID: runID, Payload: encodeSQLiteRecord([]any{
+		nil, posted.Run.StartedAt, posted.Run.Program, posted.Run.Version, optional(jiraBaseURL), optional(posted.Run.Project),
+		posted.Run.JQL, posted.Run.Timezone, posted.Run.DurationSeconds, boolean(posted.Run.Sampled),
+		int64(posted.Summary.FetchedCount), int64(posted.Summary.SpilloverCount), int64(posted.Summary.IgnoredCount),
+		int64(posted.Summary.ResolvedExcludedCount),
+	})})
+
+	issueID, sprintID := nextRowID(issues), nextRowID(sprints)
+	firstSprintID := sprintID
+	for _, issue := range posted.Issues {
+		issues.Rows = append(issues.Rows, sqliteRow{RowID: issueID, Payload: encodeSQLiteRecord([]any{
+			nil, runID, issue.Key, issue.IssueType, issue.Summary, issue.Status, issue.StatusCategory, issue.Priority,
+			issue.Assignee, issue.Reporter, issue.Created, issue.Updated, optional(issue.Resolved), optional(issue.DueDate),
+			number(issue.StoryPoints), number(issue.ChurnScore), int64(issue.SprintCount), optional(issue.FirstSprint),
+			optional(issue.LastSprint), optional(issue.EpicLink), optional(issue.EpicSummary), issue.Staleness,
+			boolean(issue.Flagged), boolean(issue.Overdue), optional(issue.Instance),
+		})})
+		for position, sprint := range issue.Sprints {
+			sprints.Rows = append(sprints.Rows, sqliteRow{RowID: sprintID, Payload: encodeSQLiteRecord([]any{
+				nil, issueID, runID, issue.Key, int64(position + 1), sprint,
+			})})
+			sprintID++
+		}
+		issueID++
+	}
+
+	if err := rewriteSQLiteDatabase(filename, objects, previous); err != nil {
+		return fmt.Errorf("failed to write SQLite database %s: %w", filename, err)
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Added run %d to SQLite database %s (%d issues, %d issue sprints)",
+		runID, filename, len(posted.Issues), sprintID-firstSprintID))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// ScoreFormula describes the Spillover Score formula the run used, e.g. "3 x sprints + 1 x points + 0.1 x age"
+//
+// Returns:
+//   string - the formula with the run's weights
+func (r Report) ScoreFormula() string {
+	return SpilloverScoreFormula(r.run.spilloverWeights)
+}
+
+/***********************************************************************************************************************************/
+// FormatSpilloverScore formats a spillover issue's Spillover Score as the run wrote it
+//
+// Parameters:
+//   multisprintIssue - one of the Report's Issues
+//
+// Returns:
+//   string - the score, or empty if the issue has no story point estimate
+func (r Report) FormatSpilloverScore(multisprintIssue MultisprintIssue) string {
+	return r.run.formatSpilloverScore(multisprintIssue)
+}
+
+/***********************************************************************************************************************************/
+// Posted converts the Report into the JSON document sent to -posturl
+//
+// Parameters:
+//   cfg - settings the report was run with
+//
+// Returns:
+//   PostedReport - run metadata, one record per spillover issue, and summary statistics
+func (r Report) Posted(cfg Config) PostedReport {
+	return r.run.buildPostedReport(cfg, r)
+}
+
+/***********************************************************************************************************************************/
+// Post POSTs a JSON document, normally the encoded Posted document, retrying on server errors
+//
+// Parameters:
+//   postURL    - endpoint to POST to
+//   authHeader - optional "Name: value" header (a bare value is sent as Authorization)
+//   payload    - JSON document to send
+//
+// Returns:
+//   error - nil on a 2xx response, otherwise the last failure
+func (r Report) Post(postURL, authHeader string, payload []byte) error {
+	return r.run.postReport(postURL, authHeader, payload)
+}
+
+/***********************************************************************************************************************************/
+// ExportSQLite adds the Report's run and spillover issues to a SQLite database (-sqlitefile)
+//
+// Parameters:
+//   cfg      - settings the report was run with
+//   filename - path of the database, created if it does not exist
+//
+// Returns:
+//   error - the database could not be read or written
+func (r Report) ExportSQLite(cfg Config, filename string) error {
+	return r.run.exportSQLite(filename, r.Posted(cfg))
+}
+
+/***********************************************************************************************************************************/
+// Run produces one spillover report from a fully populated Config
+//
+// Run holds the report orchestration without any prompting, printing of results, or process exit, so it
+// can be called from other code. The CLI layer (main) gathers the Config from flags and prompts, then
+// maps the returned error to an exit status and prints the summary from the Report. Every setting lives
+// in the run's own state, so several runs may go on at once.
+//
+// Parameters:
+//   ctx - context that cancels rate limiter waits and in-flight requests
+//   cfg - report settings; JiraBaseURL, ProjectKey, OutputFile, and authentication are required
+//
+// Returns:
+//   Report - the spillover issues, epic titles, counts, summaries, and timings (FetchedCount 0 if nothing matched)
+//   error  - errMissingConfig, ErrProjectValidation, ErrRunAborted, or any fetch or write failure
+//
+// Side effects:
+//   - Makes Jira API requests and writes the output file and any optional report files
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	report := Report{StartedAt: time.Now()}
+
+	// The library never prompts, so missing required settings are errors; a saved report (-input) needs no Jira settings
+	switch {
+	case cfg.OutputFile == "" && (!cfg.AuditFields || cfg.InputFile != ""):
+		return report, fmt.Errorf("%w: output filename", errMissingConfig)
+	case cfg.InputFile != "":
+	case cfg.JiraBaseURL == "" && len(cfg.Instances) == 0:
+		return report, fmt.Errorf("%w: Jira base URL", errMissingConfig)
+	case cfg.AuthToken == "" && cfg.OAuth == nil && len(cfg.Instances) == 0:
+		return report, fmt.Errorf("%w: authentication token", errMissingConfig)
+	case cfg.ProjectKey == "" && len(cfg.IssueKeys) == 0:
+		return report, fmt.Errorf("%w: project key", errMissingConfig)
+	}
+
+	// Validate project key format (uppercase letters and numbers only)
+	if cfg.InputFile == "" && len(cfg.IssueKeys) == 0 && !ProjectKeyRegex.MatchString(cfg.ProjectKey) {
+		return report, fmt.Errorf("project key '%s' must consist only of uppercase letters and numbers", cfg.ProjectKey)
+	}
+
+	rs, err := newRunState(ctx, cfg)
+	if err != nil {
+		return report, err
+	}
+	report.StartedAt = rs.startTime
+	report.run = rs
+	err = rs.run(cfg, &report)
+	report.Stats = rs.runStats
+	return report, err
+}
+
+/***********************************************************************************************************************************/
+// newRunState applies a Config to the state of a new run, filling in the defaults of unset settings
+//
+// Parameters:
+//   ctx - context that cancels rate limiter waits and in-flight requests
+//   cfg - report settings (required settings are checked by Run, not here)
+//
+// Returns:
+//   *runState - the run's settings, with empty progress and caches
+//   error     - an unsupported OrderBy, Format, SplitBy, or DateField, or settings that cannot be combined
+func newRunState(ctx context.Context, cfg Config) (*runState, error) {
+	rs := &runState{
+		runContext:       ctx,
+		runHooks:         cfg.Hooks,
+		startTime:        timeNow(),
+		activeOAuth:      cfg.OAuth,
+		reloadToken:      cfg.ReloadToken,
+		tokenInstance:    JiraInstance{JiraBaseURL: cfg.JiraBaseURL, AuthToken: cfg.AuthToken},
+		debug:            cfg.Debug,
+		debugIssueKeys:   make(map[string]bool),
+		reportLocation:   cfg.Location,
+		staleBuckets:     defaultStaleBuckets,
+		spilloverWeights: DefaultScoreWeights,
+	}
+	rs.sharedTransport = &jiraTransport{base: http.DefaultTransport, rs: rs}
+	for _, issueKey := range cfg.DebugIssues {
+		rs.debugIssueKeys[issueKey] = true
+	}
+	if rs.reportLocation == nil {
+		rs.reportLocation = time.Local
+	}
+	rs.dedupeEnabled = cfg.Dedupe && cfg.AppendMode
+	rs.repairEnabled = cfg.Repair && cfg.AppendMode
+	if cfg.StaleBuckets != [3]int{} {
+		rs.staleBuckets = cfg.StaleBuckets
+	}
+	if cfg.ScoreWeights != (ScoreWeights{}) {
+		rs.spilloverWeights = cfg.ScoreWeights
+	}
+	if cfg.RequestsPerSecond > 0 {
+		rs.apiRateLimiter = newRateLimiter(cfg.RequestsPerSecond)
+	}
+	rs.resolveSprintIDsEnabled = cfg.ResolveSprintIDs
+	rs.strictSprintNames = cfg.StrictNames
+	rs.allSprintsMaxLength = cfg.AllSprintsMax
+	rs.maxCellWidth = cfg.MaxCellWidth
+	rs.pairFieldName = cfg.PairField
+	rs.pairFieldProvided = cfg.PairField != ""
+	rs.commitmentAnalysis = cfg.Commitment
+	rs.estimateAnalysis = cfg.EstimateChanges
+	rs.assigneeAnalysis = cfg.AssigneeChanges
+	rs.escalationAnalysis = cfg.Escalations
+	rs.lateEstimateAnalysis = cfg.EstimatedLate
+	rs.timeInStatusAnalysis = cfg.TimeInStatus || len(cfg.StatusColumns) > 0
+	rs.compareEnabled = cfg.CompareFile != ""
+	rs.registryEnabled = cfg.RegistryFile != ""
+	rs.statusColumnNames = cfg.StatusColumns
+	rs.identityMode = cfg.IdentityMode
+	if rs.identityMode == "" {
+		rs.identityMode = "display"
+	}
+	rs.includeEpics = cfg.IncludeEpics
+	rs.groupByFieldName = cfg.GroupByField
+	rs.flaggedFieldName = cfg.FlaggedField
+	if rs.flaggedFieldName == "" {
+		rs.flaggedFieldName = defaultFlaggedField
+	}
+	rs.subtotalsEnabled = cfg.Subtotals && cfg.GroupByField != ""
+	rs.includeDescription = cfg.IncludeDescription
+	rs.descriptionMaxLength = cfg.DescriptionLength
+	if rs.descriptionMaxLength <= 0 {
+		rs.descriptionMaxLength = 200
+	}
+	rs.includeComments = cfg.IncludeComments
+	rs.includeTime = cfg.IncludeTime
+	rs.includeLinks = cfg.IncludeLinks
+	rs.linkTypeNames = cfg.LinkTypes
+	if len(rs.linkTypeNames) == 0 {
+		rs.linkTypeNames = []string{defaultLinkType}
+	}
+	rs.sprintLinksEnabled = cfg.SprintLinks
+	rs.projectCacheTTL = cfg.ProjectCacheTTL
+	if rs.projectCacheTTL == 0 {
+		rs.projectCacheTTL = DefaultProjectCacheTTL
+	}
+	rs.refreshProjectCache = cfg.RefreshCache
+	rs.lockFallbackEnabled = !cfg.NoLockFallback
+	rs.verifyOutputEnabled = !cfg.NoVerify
+	rs.atomicOutput = !cfg.NoAtomic
+	rs.flushEvery = cfg.FlushEvery
+	if rs.flushEvery == 0 {
+		rs.flushEvery = DefaultFlushEvery
+	}
+	rs.searchBatchSize = cfg.BatchSize
+	if rs.searchBatchSize <= 0 {
+		rs.searchBatchSize = DefaultBatchSize
+	}
+	rs.fixedBatchSize = cfg.FixedBatch
+	rs.searchUsesGET = cfg.SearchGET
+	rs.orderByField = cfg.OrderBy
+	switch rs.orderByField {
+	case "":
+		rs.orderByField = "key"
+	case "key", "updated", "created", "priority", "score":
+	default:
+		return nil, fmt.Errorf("unsupported order by field '%s' (use key, updated, created, priority, or score)", cfg.OrderBy)
+	}
+	rs.outputFormat = cfg.Format
+	if rs.outputFormat == "" {
+		rs.outputFormat = "tsv"
+	}
+	if cfg.SummaryOnly {
+		// -format names the summary document; the -excludedfile rows stay TSV
+		if _, known := SummaryFormats[SummaryFormatName(cfg.Format)]; !known {
+			return nil, fmt.Errorf("unsupported summary format '%s' (use text or html)", cfg.Format)
+		}
+		rs.outputFormat = "tsv"
+	}
+	format, formatKnown := ReportFormats[rs.outputFormat]
+	if !formatKnown {
+		return nil, fmt.Errorf("unsupported output format '%s' (use %s)", cfg.Format, strings.Join(ReportFormatNames(), ", "))
+	}
+	if cfg.AppendMode && !format.NewWriter(io.Discard).SupportsAppend() {
+		return nil, fmt.Errorf("output format '%s' does not support -append", rs.outputFormat)
+	}
+	rs.writeBOM = cfg.BOM && format.AllowsBOM
+	if cfg.Compress && cfg.AppendMode {
+		return nil, fmt.Errorf("-compress cannot be combined with -append (a compressed file cannot be appended to)")
+	}
+	rs.compressOutput = cfg.Compress
+	rs.splitBy = cfg.SplitBy
+	switch rs.splitBy {
+	case "none":
+		rs.splitBy = ""
+	case "", "lastsprint", "month":
+	default:
+		return nil, fmt.Errorf("unsupported split '%s' (use none, lastsprint, or month)", cfg.SplitBy)
+	}
+	if cfg.SummaryOnly && (cfg.AppendMode || cfg.Compress || rs.splitBy != "") {
+		return nil, fmt.Errorf("-summaryonly cannot be combined with -append, -compress, or -splitby (it writes a single summary document)")
+	}
+	var dateFieldValid bool
+	if rs.dateFieldName, dateFieldValid = NormalizeDateField(cfg.DateField); !dateFieldValid {
+		return nil, fmt.Errorf("unsupported date field '%s' (use updated, statusCategoryChangedDate, or resolved)", cfg.DateField)
+	}
+	rs.skipFailedPages = cfg.SkipFailedPages
+	rs.sampleSize = cfg.Sample
+	return rs, nil
+}
+
+/***********************************************************************************************************************************/
+// run carries out a report run once Run has checked the Config and set up the run's state
+//
+// Parameters:
+//   cfg    - report settings
+//   report - report to fill in (StartedAt is already set)
+//
+// Returns:
+//   error - ErrProjectValidation, ErrRunAborted, or any fetch or write failure
+//
+// Side effects:
+//   - Makes Jira API requests and writes the output file and any optional report files
+func (rs *runState) run(cfg Config, report *Report) error {
+	daysPrior := cfg.DaysPrior
+	if daysPrior <= 0 {
+		daysPrior = DefaultDaysPrior
+	}
+
+	// Record staleness thresholds so every report is self-describing
+	rs.writeLog("INFO", fmt.Sprintf("Staleness buckets: Fresh < %d days, Aging %d-%d, Stale %d-%d, Abandoned > %d (by days since last update)",
+		rs.staleBuckets[0], rs.staleBuckets[0], rs.staleBuckets[1]-1, rs.staleBuckets[1], rs.staleBuckets[2], rs.staleBuckets[2]))
+	rs.writeLog("INFO", fmt.Sprintf("Spillover Score: %s (-scoreweights)", SpilloverScoreFormula(rs.spilloverWeights)))
+
+	// A single-instance run is one unlabelled instance; several instances each get their own HTTP layer
+	instances := []JiraInstance{{JiraBaseURL: cfg.JiraBaseURL, AuthToken: cfg.AuthToken}}
+	preferredInstance := ""
+	rs.instanceColumnEnabled = len(cfg.Instances) > 0
+	if rs.instanceColumnEnabled {
+		instances = append([]JiraInstance{}, cfg.Instances...)
+		labels := make(map[string]bool)
+		for i := range instances {
+			if instances[i].JiraBaseURL == "" || instances[i].AuthToken == "" {
+				return fmt.Errorf("%w: Jira base URL and authentication token for instance '%s'", errMissingConfig, instances[i].Label)
+			}
+			if instances[i].Label == "" || labels[instances[i].Label] {
+				return fmt.Errorf("Jira instance labels must be unique and not empty (got '%s')", instances[i].Label)
+			}
+			labels[instances[i].Label] = true
+			instances[i].transport = &jiraTransport{base: http.DefaultTransport.(*http.Transport).Clone(), rs: rs}
+		}
+		preferredInstance = cfg.PreferInstance
+		if preferredInstance == "" {
+			for _, instance := range instances {
+				if IsCloudURL(instance.JiraBaseURL) {
+					preferredInstance = instance.Label
+					break
+				}
+			}
+		}
+		if preferredInstance == "" {
+			preferredInstance = instances[0].Label
+		} else if !labels[preferredInstance] {
+			return fmt.Errorf("preferred instance '%s' is not one of the instance labels", preferredInstance)
+		}
+		rs.writeLog("INFO", fmt.Sprintf("Querying %d Jira instances; the %s copy is kept when instances return the same issue key", len(instances), preferredInstance))
+	}
+
+	excludedFile := cfg.ExcludedFile
+	if len(cfg.IgnoreLabels) == 0 {
+		excludedFile = ""
+	}
+
+	// Validate every output path before any API work so failures happen immediately
+	var outputPaths []string
+	if cfg.OutputFile != "" {
+		outputPaths = append(outputPaths, ReportFilePath(cfg))
+	}
+	if cfg.SprintPairsFile != "" {
+		outputPaths = append(outputPaths, ensureTSVExtension(cfg.SprintPairsFile))
+	}
+	if cfg.EpicRollupFile != "" {
+		outputPaths = append(outputPaths, ensureExtension(cfg.EpicRollupFile, reportFormatExtension(rs.outputFormat)))
+	}
+	if cfg.PerSprintFile != "" {
+		outputPaths = append(outputPaths, ensureExtension(cfg.PerSprintFile, reportFormatExtension(rs.outputFormat)))
+	}
+	if excludedFile != "" {
+		outputPaths = append(outputPaths, outputFilePath(excludedFile, rs.outputFormat, rs.compressOutput))
+	}
+	if cfg.AllIssuesFile != "" {
+		outputPaths = append(outputPaths, ensureTSVExtension(cfg.AllIssuesFile))
+	}
+	outputPaths = append(outputPaths, cfg.ExtraOutputFiles...)
+	for _, outputPath := range outputPaths {
+		if err := rs.validateOutputPath(outputPath); err != nil {
+			// The output and excluded files are retried, then substituted, when written, so a file
+			// left open in Excel does not stop the run
+			lockTolerant := outputPath == outputFilePath(cfg.OutputFile, rs.outputFormat, rs.compressOutput) ||
+				outputPath == outputFilePath(excludedFile, rs.outputFormat, rs.compressOutput)
+			if lockTolerant && rs.lockFallbackEnabled && isFileLockedError(err) {
+				rs.writeLog("WARNING", fmt.Sprintf("Output file %s is locked by another program; it will be retried when results are written", outputPath))
+				continue
+			}
+			return fmt.Errorf("output path validation failed: %w", err)
+		}
+	}
+
+	// Rebuild the spillover issues from a saved report instead of querying Jira (-input); only the output stages run
+	if cfg.InputFile != "" {
+		multisprintIssues, epics, err := rs.readInputReport(cfg.InputFile)
+		if err != nil {
+			return err
+		}
+		report.FetchedCount = len(multisprintIssues)
+		if rs.sampleLimited {
+			report.Sampled = true
+			rs.writeLog("WARNING", fmt.Sprintf("SAMPLED RUN: %s was written by a -sample run; counts cover the sample and are not scaled", cfg.InputFile))
+		}
+
+		// Without a JQL query the fix version filter is applied to the Fix Versions column
+		if len(cfg.FixVersions) > 0 {
+			matchingIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+			for _, multisprintIssue := range multisprintIssues {
+				targeted := false
+				for _, version := range multisprintIssue.Issue.Fields.FixVersions {
+					for _, fixVersion := range cfg.FixVersions {
+						targeted = targeted || strings.EqualFold(version.Name, fixVersion)
+					}
+				}
+				if targeted {
+					matchingIssues = append(matchingIssues, multisprintIssue)
+				}
+			}
+			rs.writeLog("INFO", fmt.Sprintf("%d issues excluded because they are not targeted at fix version %s",
+				len(multisprintIssues)-len(matchingIssues), strings.Join(cfg.FixVersions, ", ")))
+			multisprintIssues = matchingIssues
+		}
+
+		if len(cfg.ExcludeUpdatedBy) > 0 {
+			rs.writeLog("WARNING", "-excludeupdatedby is ignored with -input: a saved report does not record who last updated each issue")
+		}
+
+		multisprintIssues = rs.applySpilloverFilters(cfg, report, multisprintIssues)
+		if err := rs.completeReport(cfg, report, multisprintIssues, epics, excludedFile); err != nil {
+			return err
+		}
+		report.Duration = timeNow().Sub(report.StartedAt)
+		return nil
+	}
+
+	// Identify the product behind each instance, since Cloud, Server, and Data Center differ in what they support
+	for i := range instances {
+		rs.useInstance(instances, instances[i].Label)
+		instances[i].server = rs.detectJiraServer(instances[i])
+		report.JiraServers = append(report.JiraServers, instances[i].server)
+	}
+	rs.runStats.JiraServers = jiraServerRecords(report.JiraServers)
+	rs.warnUnsupportedFlags(cfg, report.JiraServers)
+
+	// Accept a field's display name wherever a field ID is asked for
+	if err := rs.resolveFieldSettings(instances); err != nil {
+		return err
+	}
+
+	// Build JQL query: the given issue keys (which may span projects), or the project's spillover candidates
+	// Explicit issue keys have no date range for updatedBy(), so -excludeupdatedby reads their changelogs instead
+	updatedByChangelog := len(cfg.IssueKeys) > 0 && len(cfg.ExcludeUpdatedBy) > 0
+	var keyChunks [][]string
+	var keyQueries []string
+	var jqlQuery string
+	if len(cfg.IssueKeys) > 0 {
+		keyChunks, keyQueries = rs.buildKeysJQLQueries(cfg.IssueKeys, rs.orderByField)
+		jqlQuery = strings.Join(keyQueries, "\n")
+	} else {
+		// Validate project exists in every instance
+		for _, instance := range instances {
+			rs.useInstance(instances, instance.Label)
+			if err := rs.validateProject(instance.JiraBaseURL, instance.AuthToken, cfg.ProjectKey); err != nil {
+				if instance.Label != "" {
+					err = fmt.Errorf("%s instance: %v", instance.Label, err)
+				}
+				return fmt.Errorf("%w: %v", ErrProjectValidation, err)
+			}
+		}
+		jqlQuery = buildJQLQuery(cfg.ProjectKey, daysPrior, rs.dateFieldName, rs.includeEpics, cfg.FixVersions, cfg.OpenCategoryOnly, rs.orderByField, nil)
+
+		// Leave out issues updated by automation users in the query itself when every instance supports updatedBy()
+		if len(cfg.ExcludeUpdatedBy) > 0 && (cfg.JQLUpdatedBy || rs.jqlSupportsUpdatedBy(instances, cfg.ProjectKey, cfg.ExcludeUpdatedBy[0])) {
+			filteredQuery := buildJQLQuery(cfg.ProjectKey, daysPrior, rs.dateFieldName, rs.includeEpics, cfg.FixVersions, cfg.OpenCategoryOnly, rs.orderByField, cfg.ExcludeUpdatedBy)
+			rs.writeLog("INFO", fmt.Sprintf("Excluding issues updated by %s in the date range via the JQL updatedBy() function",
+				strings.Join(cfg.ExcludeUpdatedBy, ", ")))
+			report.UpdatedByExcluded = rs.countUpdatedByExclusions(instances, jqlQuery, filteredQuery)
+			report.UpdatedBySummary = fmt.Sprintf("%d candidate issues excluded because they were updated by %s in the date range (-excludeupdatedby, JQL updatedBy())",
+				report.UpdatedByExcluded, strings.Join(cfg.ExcludeUpdatedBy, ", "))
+			rs.writeLog("INFO", report.UpdatedBySummary)
+			jqlQuery = filteredQuery
+		} else if len(cfg.ExcludeUpdatedBy) > 0 {
+			updatedByChangelog = true
+		}
+		rs.writeLog("INFO", fmt.Sprintf("Using JQL query: %s", jqlQuery))
+	}
+	report.JQL = jqlQuery
+
+	// Give the caller the chance to stop before any issues are fetched
+	if cfg.Confirm != nil && !cfg.Confirm(rs.planRun(instances, jqlQuery)) {
+		return ErrRunAborted
+	}
+
+	// Define required fields for API request
+	// Build list of fields to request from Jira. Only include the custom Pair field if the user supplied -Pair.
+	requiredFields := []string{
+		"issuetype", "key", "summary", "status", "updated", "created", "resolutiondate",
+		"assignee",
+		// the pair field (if configured) will be inserted after "assignee"
+		"fixVersions", "components", defaultStoryPointsField,
+		defaultEpicLinkField, "labels", "resolution", defaultSprintField, "creator", "project",
+		"priority", "duedate", rs.flaggedFieldName,
+	}
+	if rs.pairFieldProvided && rs.pairFieldName != "" {
+		// insert the user-specified field name after "assignee"
+		// find index of "assignee"
+		idx := -1
+		for i, v := range requiredFields {
+			if v == "assignee" {
+				idx = i
+				break
+			}
+		}
+		if idx >= 0 {
+			// insert at idx+1
+			before := append([]string{}, requiredFields[:idx+1]...)
+			after := append([]string{}, requiredFields[idx+1:]...)
+			requiredFields = append(append(before, rs.pairFieldName), after...)
+		} else {
+			// fallback: append to the front
+			requiredFields = append([]string{rs.pairFieldName}, requiredFields...)
+		}
+	}
+	if rs.groupByFieldName != "" {
+		requiredFields = append(requiredFields, rs.groupByFieldName)
+	}
+	// Descriptions and comments make responses much larger, so they are only requested when asked for
+	if rs.includeDescription {
+		requiredFields = append(requiredFields, "description")
+	}
+	if rs.includeComments {
+		requiredFields = append(requiredFields, "comment")
+	}
+	if rs.includeLinks {
+		requiredFields = append(requiredFields, "issuelinks")
+	}
+	fieldsParam := strings.Join(requiredFields, ",")
+	// Time tracking fields are absent when time tracking is switched off, which is not a visibility problem,
+	// so they are requested without being checked by findNotVisibleFields
+	if rs.includeTime {
+		fieldsParam += "," + strings.Join(timeTrackingFields, ",")
+	}
+
+	// Fetch all issues
+	rs.runHooks.phase("Fetching issues from Jira")
+	fetchStart := time.Now()
+	var err error
+	issuesByInstance := make([][]Issue, 0, len(instances))
+	missingCounts := make(map[string]int)
+	for _, instance := range instances {
+		rs.useInstance(instances, instance.Label)
+		if instance.Label != "" {
+			rs.writeLog("INFO", fmt.Sprintf("Fetching issues from the %s instance (%s)", instance.Label, instance.JiraBaseURL))
+		}
+		var instanceIssues []Issue
+		var missingKeys []string
+		if len(cfg.IssueKeys) > 0 {
+			instanceIssues, missingKeys, err = rs.fetchIssuesByKeys(instance.JiraBaseURL, instance.AuthToken, keyChunks, keyQueries, fieldsParam)
+		} else {
+			instanceIssues, err = rs.fetchAllJiraIssues(instance.JiraBaseURL, instance.AuthToken, jqlQuery, fieldsParam)
+		}
+		if err != nil {
+			if instance.Label != "" {
+				err = fmt.Errorf("%s instance: %w", instance.Label, err)
+			}
+			report.FetchDuration = time.Since(fetchStart)
+			return fmt.Errorf("failed to fetch issues: %w", err)
+		}
+		for i := range instanceIssues {
+			instanceIssues[i].Instance = instance.Label
+		}
+		issuesByInstance = append(issuesByInstance, instanceIssues)
+		for _, issueKey := range missingKeys {
+			missingCounts[issueKey]++
+		}
+	}
+	report.FetchDuration = time.Since(fetchStart)
+	report.SkippedPages = rs.skippedPageCount
+	report.SkippedIssues = rs.skippedIssueCount
+	if rs.skippedPageCount > 0 {
+		rs.writeLog("ERROR", fmt.Sprintf("%d pages (approx. %d issues) could not be fetched, the report is incomplete (-skipfailedpages)",
+			rs.skippedPageCount, rs.skippedIssueCount))
+	}
+
+	// Merge the instances' results, keeping the preferred copy of any key found in several
+	issues := issuesByInstance[0]
+	if len(instances) > 1 {
+		var duplicates int
+		issues, duplicates = mergeInstanceIssues(issuesByInstance, preferredInstance)
+		rs.writeLog("INFO", fmt.Sprintf("Merged %d issues from %d instances, dropping %d duplicate keys in favour of the %s copy",
+			len(issues), len(instances), duplicates, preferredInstance))
+	}
+	// A key is only missing if no instance has it
+	for _, issueKey := range cfg.IssueKeys {
+		if missingCounts[issueKey] == len(instances) {
+			report.MissingKeys = append(report.MissingKeys, issueKey)
+		}
+	}
+	// Each instance stops at the sample size, so their merged results are cut to it again
+	if rs.sampleSize > 0 && len(issues) > rs.sampleSize {
+		issues = issues[:rs.sampleSize]
+		rs.sampleLimited = true
+	}
+	if rs.sampleLimited {
+		report.Sampled = true
+		rs.runStats.Sampled = true
+		rs.writeLog("WARNING", fmt.Sprintf("SAMPLED RUN: only %d of %d matching issues were fetched (-sample); counts cover the sample and are not scaled",
+			len(issues), rs.runStats.JQLTotal))
+	} else if rs.sampleSize > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("All %d matching issues fit in the sample of %d (-sample), so this is a full run", len(issues), rs.sampleSize))
+	}
+	report.FetchedCount = len(issues)
+	if rs.includeTime && len(findNotVisibleFields(issues, timeTrackingFields)) == len(timeTrackingFields) {
+		rs.writeLog("WARNING", "No issue returned any time tracking field: time tracking is probably switched off on the instance "+
+			"(or hidden from the reporting account), so the time columns are blank")
+	}
+	report.NotVisibleFields = findNotVisibleFields(issues, requiredFields)
+	rs.runStats.NotVisibleFields = report.NotVisibleFields
+	rs.notVisibleFields = make(map[string]bool, len(report.NotVisibleFields))
+	for _, fieldID := range report.NotVisibleFields {
+		rs.notVisibleFields[fieldID] = true
+	}
+	rs.warnNotVisibleFields(report.NotVisibleFields)
+
+	// Summarise what the configured custom fields hold (-auditfields), stopping here unless a report was asked for too
+	if cfg.AuditFields {
+		report.FieldAudits = rs.auditCustomFields(issues)
+		for _, audit := range report.FieldAudits {
+			rs.writeLog("INFO", strings.Join(FormatFieldAudit(audit), "\n"))
+		}
+		if cfg.OutputFile == "" {
+			rs.logAPIRequestStats()
+			report.Duration = timeNow().Sub(report.StartedAt)
+			return nil
+		}
+	}
+	if len(report.MissingKeys) > 0 {
+		rs.writeLog("WARNING", fmt.Sprintf("%d of %d issue keys could not be found: %s",
+			len(report.MissingKeys), len(cfg.IssueKeys), strings.Join(report.MissingKeys, ", ")))
+	}
+
+	if len(issues) == 0 {
+		rs.writeLog("WARNING", "No issues found matching the criteria")
+		report.Duration = timeNow().Sub(report.StartedAt)
+		return nil
+	}
+
+	// Team-managed projects can return the sprint field as bare sprint IDs only, so resolve them even without -resolvesprintids
+	if !rs.resolveSprintIDsEnabled && hasBareSprintIDField(issues) {
+		rs.writeLog("INFO", "Some sprint fields hold only sprint IDs (team-managed projects), resolving them via the Agile API")
+		rs.resolveSprintIDsEnabled = true
+	}
+
+	// Resolve bare sprint IDs before parsing sprint fields; IDs are only unique within an instance
+	instanceSprints := make(map[string]map[string]SprintDetail)
+	if rs.resolveSprintIDsEnabled {
+		for i, instance := range instances {
+			rs.useInstance(instances, instance.Label)
+			instanceSprints[instance.Label] = rs.fetchSprintDetails(instance.JiraBaseURL, instance.AuthToken, issuesByInstance[i])
+		}
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Processing %d issues to identify multi-sprint items...", len(issues)))
+
+	// Process issues to find spillovers
+	var multisprintIssues []MultisprintIssue
+	instanceBaseURLs := make(map[string]string) // Base URL per instance label, for sprint report links
+	instanceCloudLinks := make(map[string]bool) // Sprint report link format per instance label
+	for _, instance := range instances {
+		instanceBaseURLs[instance.Label] = instance.JiraBaseURL
+		instanceCloudLinks[instance.Label] = cfg.CloudLinks || decideCapabilities(instance.server).CloudLinks
+	}
+	epicKeysToLookup := make(map[string][]string) // Epic keys to look up, per instance label
+	epicKeySet := make(map[string]bool)           // To avoid duplicates
+
+	// Epics fetched alongside their children (-includeepics) already carry their summary, so never look them up
+	fetchedEpics := make(map[string]EpicMeta)
+	if rs.includeEpics {
+		for _, issue := range issues {
+			if isEpic(issue) {
+				fetchedEpics[issue.Key] = EpicMeta{Summary: issue.Fields.Summary, Status: issue.Fields.Status.Name, ResolutionDate: issue.Fields.ResolutionDate}
+			}
+		}
+	}
+
+	// Stream every processed issue to the all issues file as it is processed (-allissuesfile)
+	var allIssuesFileHandle *os.File
+	var allIssuesWriter *bufio.Writer
+	allIssuesLastSync := time.Now()
+	allIssuesCount := 0
+	if cfg.AllIssuesFile != "" {
+		allIssuesFileHandle, allIssuesWriter, err = rs.openAllIssuesFile(cfg.AllIssuesFile)
+		if err != nil {
+			return fmt.Errorf("failed to open all issues file: %w", err)
+		}
+		defer func() {
+			if allIssuesFileHandle != nil {
+				_ = allIssuesFileHandle.Close()
+			}
+		}()
+	}
+
+	// With -datefield resolved the JQL already limits the resolution date, so the post-filter only applies if it is
+	// narrower; otherwise its calendar-day count could drop issues the rolling JQL window deliberately returned
+	resolvedWithin := cfg.ResolvedWithin
+	if rs.dateFieldName == "resolved" && len(cfg.IssueKeys) == 0 && resolvedWithin >= daysPrior {
+		resolvedWithin = 0
+	}
+
+	// Resolved issues are kept only if resolved inside the window: from the start of the date range (-fromdate)
+	// or midnight resolvedWithin days ago, to the end of today. Comparing instants rather than counting days
+	// from now keeps the window on the date the user asked for however long the run takes.
+	var resolvedWindowStart, resolvedWindowEnd time.Time
+	if resolvedWithin > 0 {
+		today := rs.startTime.In(rs.reportLocation)
+		resolvedWindowStart = cfg.ResolvedFrom
+		if resolvedWindowStart.IsZero() {
+			resolvedWindowStart = time.Date(today.Year(), today.Month(), today.Day()-resolvedWithin, 0, 0, 0, 0, rs.reportLocation)
+		}
+		resolvedWindowEnd = time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, rs.reportLocation)
+	}
+
+	for i, issue := range issues {
+		rs.runHooks.issueProcessed(i, len(issues))
+		if i%100 == 0 {
+			rs.writeLogWithContext("INFO", LogContext{Issue: issue.Key}, fmt.Sprintf("Processing issue %d of %d: %s", i+1, len(issues), issue.Key))
+		}
+
+		// Skip issues resolved too long ago if they have resolution date (-resolvedwithin 0 disables this)
+		if resolvedWithin > 0 && issue.Fields.ResolutionDate != nil {
+			if resolvedTime, ok := rs.parseJiraTime(*issue.Fields.ResolutionDate); ok {
+				if resolvedTime.Before(resolvedWindowStart) || !resolvedTime.Before(resolvedWindowEnd) {
+					report.ResolvedExcludedCount++
+					continue
+				}
+			}
+		}
+		// DEBUG: Log the raw SprintField value for this issue if debug flag is set
+		if rs.wantIssueDebug(issue.Key) {
+			var debugLines []string
+			debugLines = append(debugLines, fmt.Sprintf("Issue %s raw SprintField:", issue.Key))
+			switch sprints := issue.Fields.SprintField.(type) {
+			case []interface{}:
+				for _, sprint := range sprints {
+					if sprintMap, ok := sprint.(map[string]interface{}); ok {
+						// Get id and name for header
+						idVal := sprintMap["id"]
+						nameVal := sprintMap["name"]
+						idStr := fmt.Sprintf("%v", idVal)
+						nameStr := fmt.Sprintf("%v", nameVal)
+						debugLines = append(debugLines, fmt.Sprintf("Sprint %s: %s", idStr, nameStr))
+						// Board ID
+						if val := sprintMap["boardId"]; val != nil {
+							debugLines = append(debugLines, fmt.Sprintf("  - Board ID: %v", val))
+						}
+						// State
+						if val, ok := sprintMap["state"]; ok {
+							debugLines = append(debugLines, fmt.Sprintf("  - State: %v", val))
+						}
+						// Goal
+						if val, ok := sprintMap["goal"]; ok {
+							goalStr := fmt.Sprintf("%v", val)
+							if goalStr == "" {
+								goalStr = "(empty)"
+							}
+							debugLines = append(debugLines, fmt.Sprintf("  - Goal: %s", goalStr))
+						} else {
+							debugLines = append(debugLines, "  - Goal: (empty)")
+						}
+						// Start Date
+						if val, ok := sprintMap["startDate"]; ok {
+							debugLines = append(debugLines, fmt.Sprintf("  - Start Date: %v", val))
+						}
+						// End Date
+						if val, ok := sprintMap["endDate"]; ok {
+							debugLines = append(debugLines, fmt.Sprintf("  - End Date:   %v", val))
+						}
+						// Complete Date
+						if val, ok := sprintMap["completeDate"]; ok {
+							debugLines = append(debugLines, fmt.Sprintf("  - Complete Date: %v", val))
+						}
+					}
+				}
+			case nil:
+				debugLines = append(debugLines, "  [No sprint data]")
+			default:
+				debugLines = append(debugLines, fmt.Sprintf("  [Unrecognized SprintField type: %T]", sprints))
+			}
+			rs.runHooks.issueDebug(issue.Key, strings.Join(debugLines, "\n"))
+		}
+
+		// Parse sprint information
+		if rs.resolveSprintIDsEnabled {
+			rs.resolvedSprints = instanceSprints[issue.Instance]
+		}
+		sprintInfo := rs.parseSprintField(issue.Fields.SprintField)
+		if problems := checkSprintInfo(sprintInfo); len(problems) > 0 {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issue.Key}, fmt.Sprintf("Inconsistent sprint data for %s: %s", issue.Key, strings.Join(problems, "; ")))
+		}
+		if rs.debug && len(sprintInfo.ActiveSprints) > 1 {
+			rs.writeLog("DEBUG", fmt.Sprintf("%s is in %d active sprints (%s); Current Sprint lists them all", issue.Key,
+				len(sprintInfo.ActiveSprints), strings.Join(sprintInfo.ActiveSprints, ", ")))
+		}
+
+		if allIssuesWriter != nil {
+			epicLink := getEpicLink(issue.Fields.EpicLinkField)
+			if rs.includeEpics && isEpic(issue) {
+				epicLink = issue.Key
+			}
+			spillover := sprintInfo.SprintCount > 1 && !hasIgnoreLabel(issue, cfg.IgnoreLabels)
+			if err := rs.writeAllIssuesRow(allIssuesWriter, issue, sprintInfo.SprintCount, spillover, epicLink); err != nil {
+				return err
+			}
+			allIssuesCount++
+			if err := rs.flushProgress(allIssuesWriter.Flush, allIssuesFileHandle, allIssuesCount, &allIssuesLastSync); err != nil {
+				return fmt.Errorf("failed to write all issues file: %w", err)
+			}
+		}
+
+		// Only include issues that have been in more than one sprint
+		if sprintInfo.SprintCount > 1 {
+			epicLink := getEpicLink(issue.Fields.EpicLinkField)
+			if rs.includeEpics && isEpic(issue) {
+				// An epic is reported as its own epic
+				epicLink = issue.Key
+			}
+
+			// Add to multi-sprint issues
+			multisprintIssue := MultisprintIssue{
+				Issue:         issue,
+				WorkedSprints: sprintInfo.SprintCount,
+				EpicLink:      epicLink,
+				SprintInfo:    sprintInfo,
+				Staleness:     rs.getStalenessBucket(issue, rs.startTime),
+				Overdue:       rs.isOverdue(issue, rs.startTime),
+			}
+			if rs.groupByFieldName != "" {
+				multisprintIssue.Group = rs.getGroupValue(issue)
+			}
+			if rs.sprintLinksEnabled && len(sprintInfo.Sprints) > 0 {
+				baseURL := instanceBaseURLs[issue.Instance]
+				projectKey := issue.Fields.Project.Key
+				cloudLinks := instanceCloudLinks[issue.Instance]
+				multisprintIssue.FirstSprintReportURL = buildSprintReportURL(baseURL, projectKey, sprintInfo.Sprints[0], cloudLinks)
+				multisprintIssue.LastSprintReportURL = buildSprintReportURL(baseURL, projectKey, sprintInfo.Sprints[len(sprintInfo.Sprints)-1], cloudLinks)
+			}
+
+			// Set resolved date if available
+			if issue.Fields.ResolutionDate != nil {
+				if resolvedTime, ok := rs.parseJiraTime(*issue.Fields.ResolutionDate); ok {
+					multisprintIssue.ResolvedDate = &resolvedTime
+				}
+			}
+
+			multisprintIssues = append(multisprintIssues, multisprintIssue)
+
+			// Collect epic keys for lookup in the instance the issue came from
+			lookupKey := issue.Instance + "\x00" + epicLink
+			if _, fetched := fetchedEpics[epicLink]; epicLink != "No Epic" && !fetched && !epicKeySet[lookupKey] {
+				epicKeySet[lookupKey] = true
+				epicKeysToLookup[issue.Instance] = append(epicKeysToLookup[issue.Instance], epicLink)
+			}
+		}
+	}
+	rs.runHooks.issueProcessed(len(issues), len(issues))
+
+	if allIssuesWriter != nil {
+		if err := allIssuesWriter.Flush(); err != nil {
+			return fmt.Errorf("failed to write all issues file: %w", err)
+		}
+		err := allIssuesFileHandle.Close()
+		allIssuesFileHandle = nil
+		if err != nil {
+			return fmt.Errorf("failed to close all issues file: %w", err)
+		}
+		rs.writeLog("INFO", fmt.Sprintf("Successfully wrote %d processed issues to %s", allIssuesCount, ensureTSVExtension(cfg.AllIssuesFile)))
+		report.WrittenFiles = append(report.WrittenFiles, ensureTSVExtension(cfg.AllIssuesFile))
+	}
+
+	rs.writeLog("INFO", fmt.Sprintf("Found %d issues that have been worked on in multiple sprints", len(multisprintIssues)))
+
+	multisprintIssues = rs.applySpilloverFilters(cfg, report, multisprintIssues)
+
+	// Without JQL updatedBy() support, automation updates are found from each spillover issue's latest change
+	if updatedByChangelog && len(multisprintIssues) > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("Excluding issues last updated by %s using each spillover issue's changelog (JQL updatedBy() not used)",
+			strings.Join(cfg.ExcludeUpdatedBy, ", ")))
+		remaining := rs.excludeLastUpdatedBy(instances, multisprintIssues, cfg.ExcludeUpdatedBy)
+		report.UpdatedByExcluded = len(multisprintIssues) - len(remaining)
+		report.UpdatedBySummary = fmt.Sprintf("%d spillover issues excluded because their most recent change was made by %s (-excludeupdatedby, changelogs)",
+			report.UpdatedByExcluded, strings.Join(cfg.ExcludeUpdatedBy, ", "))
+		rs.writeLog("INFO", report.UpdatedBySummary)
+		multisprintIssues = remaining
+	}
+
+	// Fetch epic summaries from each issue's own instance
+	epics := make(map[string]EpicMeta)
+	for _, instance := range instances {
+		if len(epicKeysToLookup[instance.Label]) == 0 {
+			continue
+		}
+		rs.useInstance(instances, instance.Label)
+		instanceEpics, err := rs.fetchEpicTitles(instance.JiraBaseURL, instance.AuthToken, epicKeysToLookup[instance.Label])
+		if errors.Is(err, errAuthRejected) || errors.Is(err, ErrRefreshTokenRejected) || errors.Is(err, context.Canceled) {
+			return fmt.Errorf("failed to fetch epic summaries: %w", err)
+		} else if err != nil {
+			// Continue without this instance's epic summaries
+			rs.writeLog("WARNING", fmt.Sprintf("Failed to fetch some epic summaries: %v", err))
+			continue
+		}
+		for epicKey, epic := range instanceEpics {
+			if _, exists := epics[epicKey]; !exists || instance.Label == preferredInstance {
+				epics[epicKey] = epic
+			}
+		}
+	}
+	for epicKey, epic := range fetchedEpics {
+		if epic.Summary == "" {
+			epic.Summary = "No Epic Title"
+		}
+		epics[epicKey] = epic
+	}
+
+	// Check whether each spillover issue was committed at the start of its first sprint
+	if rs.commitmentAnalysis && len(multisprintIssues) > 0 {
+		midSprintAdditions := rs.analyseSprintCommitment(instances, multisprintIssues)
+		report.CommitmentSummary = fmt.Sprintf("%d spillover issues were added to their first sprint after it started", midSprintAdditions)
+		rs.writeLog("INFO", report.CommitmentSummary)
+	}
+
+	// Check whether each spillover issue was re-estimated after it entered its first sprint
+	if rs.estimateAnalysis && len(multisprintIssues) > 0 {
+		estimateChanges := rs.analyseEstimateChanges(instances, multisprintIssues)
+		report.EstimateSummary = fmt.Sprintf("%d spillover issues had their story points changed in flight", estimateChanges)
+		rs.writeLog("INFO", report.EstimateSummary)
+	}
+
+	// Count how often each spillover issue changed hands after it entered its first sprint
+	if rs.assigneeAnalysis && len(multisprintIssues) > 0 {
+		report.AssigneeSummary = rs.analyseAssigneeChanges(instances, multisprintIssues)
+		rs.writeLog("INFO", report.AssigneeSummary)
+	}
+
+	// Check whether each spillover issue's priority was raised after it entered its first sprint
+	if rs.escalationAnalysis && len(multisprintIssues) > 0 {
+		escalations := rs.analyseEscalations(instances, multisprintIssues)
+		report.EscalationSummary = fmt.Sprintf("%d spillover issues had their priority raised in flight", escalations)
+		rs.writeLog("INFO", report.EscalationSummary)
+	}
+
+	// Count the sprints each spillover issue spent without an estimate
+	if rs.lateEstimateAnalysis && len(multisprintIssues) > 0 {
+		lateEstimates := rs.analyseLateEstimates(instances, multisprintIssues)
+		report.EstimatedLateSummary = fmt.Sprintf("%d spillover issues were in a sprint that started before they were estimated", lateEstimates)
+		rs.writeLog("INFO", report.EstimatedLateSummary)
+	}
+
+	// Work out where each spillover issue's time went, status by status
+	if rs.timeInStatusAnalysis && len(multisprintIssues) > 0 {
+		report.TimeInStatusSummary = rs.analyseTimeInStatus(instances, multisprintIssues)
+		rs.writeLog("INFO", report.TimeInStatusSummary)
+	}
+
+	if err := rs.completeReport(cfg, report, multisprintIssues, epics, excludedFile); err != nil {
+		return err
+	}
+
+	// Report request volume so Jira admins can verify the rate limit was honoured
+	rs.logAPIRequestStats()
+	rs.logUnparseableTimes()
+	rs.logFailedEpics()
+
+	// Explain any difference between fetched and processed counts caused by the resolved date filter
+	if resolvedWithin > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("Excluded %d issues resolved outside %s to %s (-resolvedwithin %d)",
+			report.ResolvedExcludedCount, resolvedWindowStart.Format("2006-01-02 15:04"),
+			resolvedWindowEnd.Add(-time.Minute).Format("2006-01-02 15:04"), resolvedWithin))
+	} else if cfg.ResolvedWithin > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("Resolved date window applied by the JQL (-datefield resolved), -resolvedwithin %d not needed",
+			cfg.ResolvedWithin))
+	}
+
+	report.Duration = timeNow().Sub(report.StartedAt)
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// applySpilloverFilters removes the spillover issues excluded by -ignorelabel, -goalcontains, -graceperiod, -opencategoryonly,
+// -flaggedonly, -activeonly, -prioritiesonly, and -minchurn
+//
+// Parameters:
+//   cfg               - run settings holding the filters
+//   report            - report receiving the ignored issues and each filter's summary
+//   multisprintIssues - spillover issues to filter
+//
+// Returns:
+//   []MultisprintIssue - the issues that remain
+func (rs *runState) applySpilloverFilters(cfg Config, report *Report, multisprintIssues []MultisprintIssue) []MultisprintIssue {
+	// Remove issues carrying an ignore label (e.g., enablers that are expected to span sprints)
+	if len(cfg.IgnoreLabels) > 0 {
+		var labelCounts map[string]int
+		multisprintIssues, report.IgnoredIssues, labelCounts = filterIgnoredLabels(multisprintIssues, cfg.IgnoreLabels)
+		countParts := make([]string, 0, len(cfg.IgnoreLabels))
+		for _, label := range cfg.IgnoreLabels {
+			countParts = append(countParts, fmt.Sprintf("%s (%d)", label, labelCounts[strings.ToLower(label)]))
+		}
+		report.IgnoreSummary = fmt.Sprintf("%d issues excluded due to ignore labels: %s", len(report.IgnoredIssues), strings.Join(countParts, ", "))
+		rs.writeLog("INFO", report.IgnoreSummary)
+	}
+
+	// Keep only issues whose first sprint was working towards a given goal (e.g., one initiative)
+	if cfg.GoalContains != "" {
+		goalText := strings.ToLower(cfg.GoalContains)
+		matchingIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if strings.Contains(strings.ToLower(multisprintIssue.SprintInfo.FirstGoal), goalText) {
+				matchingIssues = append(matchingIssues, multisprintIssue)
+			}
+		}
+		report.GoalFilterSummary = fmt.Sprintf("%d issues excluded because their first sprint goal does not mention '%s'",
+			len(multisprintIssues)-len(matchingIssues), cfg.GoalContains)
+		rs.writeLog("INFO", report.GoalFilterSummary)
+		multisprintIssues = matchingIssues
+	}
+
+	// Leave out issues rolled over by a sprint that has only just started; they are reported next time if they persist
+	if cfg.GracePeriod > 0 {
+		settledIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if !rs.isWithinGracePeriod(multisprintIssue.SprintInfo, cfg.GracePeriod, rs.startTime) {
+				settledIssues = append(settledIssues, multisprintIssue)
+			}
+		}
+		report.GraceSummary = fmt.Sprintf("%d issues within grace period excluded (second sprint started less than %d days ago)",
+			len(multisprintIssues)-len(settledIssues), cfg.GracePeriod)
+		rs.writeLog("INFO", report.GraceSummary)
+		multisprintIssues = settledIssues
+	}
+
+	// Keep only issues that are still actionable, however the workflow names its finished statuses
+	if cfg.OpenCategoryOnly {
+		openIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if multisprintIssue.Issue.Fields.Status.StatusCategory.Key != "done" {
+				openIssues = append(openIssues, multisprintIssue)
+			}
+		}
+		report.OpenCategorySummary = fmt.Sprintf("%d issues excluded because their status is in the Done category (-opencategoryonly)",
+			len(multisprintIssues)-len(openIssues))
+		rs.writeLog("INFO", report.OpenCategorySummary)
+		multisprintIssues = openIssues
+	}
+
+	// Keep only issues flagged as an impediment
+	if cfg.FlaggedOnly {
+		flaggedIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if rs.isFlagged(multisprintIssue.Issue) {
+				flaggedIssues = append(flaggedIssues, multisprintIssue)
+			}
+		}
+		report.FlaggedOnlySummary = fmt.Sprintf("%d issues excluded because they are not flagged (-flaggedonly)",
+			len(multisprintIssues)-len(flaggedIssues))
+		rs.writeLog("INFO", report.FlaggedOnlySummary)
+		multisprintIssues = flaggedIssues
+	}
+
+	// Keep only issues in an active sprint now, leaving out the ones waiting in the backlog
+	if cfg.ActiveOnly {
+		activeIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if len(multisprintIssue.SprintInfo.ActiveSprints) > 0 {
+				activeIssues = append(activeIssues, multisprintIssue)
+			}
+		}
+		report.ActiveOnlySummary = fmt.Sprintf("%d issues excluded because they are not in an active sprint (-activeonly)",
+			len(multisprintIssues)-len(activeIssues))
+		rs.writeLog("INFO", report.ActiveOnlySummary)
+		multisprintIssues = activeIssues
+	}
+
+	// Keep only issues of the given priorities, warning about names no spillover issue has
+	if len(cfg.PrioritiesOnly) > 0 {
+		wanted := make(map[string]bool, len(cfg.PrioritiesOnly))
+		for _, priority := range cfg.PrioritiesOnly {
+			wanted[strings.ToLower(priority)] = true
+		}
+		observed := make(map[string]string)
+		priorityIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			priority := ""
+			if multisprintIssue.Issue.Fields.Priority != nil {
+				priority = multisprintIssue.Issue.Fields.Priority.Name
+			}
+			if priority != "" {
+				observed[strings.ToLower(priority)] = priority
+			}
+			if wanted[strings.ToLower(priority)] {
+				priorityIssues = append(priorityIssues, multisprintIssue)
+			}
+		}
+		var unknown []string
+		for _, priority := range cfg.PrioritiesOnly {
+			if _, ok := observed[strings.ToLower(priority)]; !ok {
+				unknown = append(unknown, priority)
+			}
+		}
+		if len(unknown) > 0 {
+			names := make([]string, 0, len(observed))
+			for _, name := range observed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			rs.writeLog("WARNING", fmt.Sprintf("No spillover issue has priority %s (-prioritiesonly); priorities seen: %s",
+				strings.Join(unknown, ", "), strings.Join(names, ", ")))
+		}
+		report.PrioritiesOnlySummary = fmt.Sprintf("%d issues excluded because their priority is not %s (-prioritiesonly)",
+			len(multisprintIssues)-len(priorityIssues), strings.Join(cfg.PrioritiesOnly, ", "))
+		rs.writeLog("INFO", report.PrioritiesOnlySummary)
+		multisprintIssues = priorityIssues
+	}
+
+	// Keep only the estimation outliers: issues spanning many sprints for their size
+	if cfg.MinChurn > 0 {
+		outlierIssues := make([]MultisprintIssue, 0, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			if score, ok := churnScore(multisprintIssue); ok && score >= cfg.MinChurn {
+				outlierIssues = append(outlierIssues, multisprintIssue)
+			}
+		}
+		report.MinChurnSummary = fmt.Sprintf("%d issues excluded because their churn score is below %s or their story points are not numeric (-minchurn)",
+			len(multisprintIssues)-len(outlierIssues), strconv.FormatFloat(cfg.MinChurn, 'f', -1, 64))
+		rs.writeLog("INFO", report.MinChurnSummary)
+		multisprintIssues = outlierIssues
+	}
+
+	return multisprintIssues
+}
+
+/***********************************************************************************************************************************/
+// jqlSupportsUpdatedBy probes whether every instance accepts the updatedBy() JQL function
+//
+// The function is available on Jira Data Center and Jira Cloud, but not on older Jira Server versions, and an
+// instance without it rejects the whole query. Instances detected as Cloud or Data Center are not probed.
+//
+// Parameters:
+//   instances  - Jira instances the run queries
+//   projectKey - project the probe query is restricted to
+//   user       - one of the -excludeupdatedby users
+//
+// Returns:
+//   bool - true if every instance answered the probe query
+//
+// Side effects:
+//   - Makes one lightweight search request per instance not known to support the function
+//   - Logs why the changelog fallback is needed when an instance rejects the probe
+func (rs *runState) jqlSupportsUpdatedBy(instances []JiraInstance, projectKey, user string) bool {
+	probeQuery := fmt.Sprintf("project = %s AND issue in updatedBy(%s)", projectKey, quoteJQLValue(user))
+	for _, instance := range instances {
+		if decideCapabilities(instance.server).UpdatedByJQL {
+			continue
+		}
+		rs.useInstance(instances, instance.Label)
+		if _, err := rs.fetchIssueCountEstimate(instance.JiraBaseURL, instance.AuthToken, probeQuery); err != nil {
+			rs.writeLog("INFO", fmt.Sprintf("JQL updatedBy() probe failed on %s (%v); falling back to changelogs", instance.JiraBaseURL, err))
+			return false
+		}
+	}
+	return true
+}
+
+/***********************************************************************************************************************************/
+// countUpdatedByExclusions counts the candidate issues the updatedBy() clauses remove from the query
+//
+// Parameters:
+//   instances     - Jira instances the run queries
+//   baseQuery     - JQL query without the updatedBy() clauses
+//   filteredQuery - the same query with them
+//
+// Returns:
+//   int - difference between the two queries' issue counts, summed over the instances (0 if a count failed)
+//
+// Side effects:
+//   - Makes two lightweight search requests per instance
+func (rs *runState) countUpdatedByExclusions(instances []JiraInstance, baseQuery, filteredQuery string) int {
+	excluded := 0
+	for _, instance := range instances {
+		rs.useInstance(instances, instance.Label)
+		baseCount, err := rs.fetchIssueCountEstimate(instance.JiraBaseURL, instance.AuthToken, baseQuery)
+		if err != nil {
+			rs.writeLog("WARNING", fmt.Sprintf("Failed to count the issues excluded by -excludeupdatedby: %v", err))
+			return 0
+		}
+		filteredCount, err := rs.fetchIssueCountEstimate(instance.JiraBaseURL, instance.AuthToken, filteredQuery)
+		if err != nil {
+			rs.writeLog("WARNING", fmt.Sprintf("Failed to count the issues excluded by -excludeupdatedby: %v", err))
+			return 0
+		}
+		excluded += baseCount - filteredCount
+	}
+	return excluded
+}
+
+/***********************************************************************************************************************************/
+// latestChangeAuthor returns the author of the most recent changelog entry
+//
+// Parameters:
+//   histories - issue changelog entries, in any order
+//
+// Returns:
+//   *Assignee - author of the latest entry, or nil if there are no entries or the latest has no author
+func (rs *runState) latestChangeAuthor(histories []ChangelogHistory) *Assignee {
+	var latest *ChangelogHistory
+	var latestTime time.Time
+	for i := range histories {
+		created, ok := rs.parseJiraTime(histories[i].Created)
+		if !ok {
+			continue
+		}
+		if latest == nil || !created.Before(latestTime) {
+			latest = &histories[i]
+			latestTime = created
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return latest.Author
+}
+
+/***********************************************************************************************************************************/
+// excludeLastUpdatedBy drops the spillover issues whose most recent change was made by one of the given users
+//
+// Users are matched case-insensitively against the change author's display name, account ID, email address,
+// and username. Changelogs are shared with the other changelog analyses through changelogCache.
+//
+// Parameters:
+//   instances         - Jira instances queried; each changelog is fetched from the instance its issue came from
+//   multisprintIssues - spillover issues to filter
+//   users             - -excludeupdatedby users
+//
+// Returns:
+//   []MultisprintIssue - the issues kept; an issue whose changelog cannot be fetched is kept
+func (rs *runState) excludeLastUpdatedBy(instances []JiraInstance, multisprintIssues []MultisprintIssue, users []string) []MultisprintIssue {
+	changelogs := rs.fetchChangelogs(instances, multisprintIssues)
+	kept := make([]MultisprintIssue, 0, len(multisprintIssues))
+	for i, multisprintIssue := range multisprintIssues {
+		issueKey := multisprintIssue.Issue.Key
+		histories, err := changelogs[i].histories, changelogs[i].err
+		if err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Failed to fetch changelog for %s, keeping it: %v", issueKey, err))
+			kept = append(kept, multisprintIssue)
+			continue
+		}
+		excluded := false
+		if author := rs.latestChangeAuthor(histories); author != nil {
+			for _, user := range users {
+				for _, identity := range []string{author.DisplayName, author.AccountID, author.EmailAddress, author.Name} {
+					excluded = excluded || (identity != "" && strings.EqualFold(identity, user))
+				}
+			}
+		}
+		if !excluded {
+			kept = append(kept, multisprintIssue)
+		}
+	}
+	return kept
+}
+
+/***********************************************************************************************************************************/
+// findNotVisibleFields returns the requested fields Jira did not return for any issue
+//
+// Jira returns a requested field that exists as null when it is empty, but leaves out a field that does not exist
+// or that the account cannot see (field-level security). A field missing from every issue is therefore not
+// unset but unknown, and reporting its default (N/A, no, Unassigned) would be misleading.
+//
+// Parameters:
+//   issues          - issues fetched with the fields requested
+//   requestedFields - field IDs that were requested
+//
+// Returns:
+//   []string - field IDs missing from every issue, in request order (nil if there are no issues)
+func findNotVisibleFields(issues []Issue, requestedFields []string) []string {
+	if len(issues) == 0 {
+		return nil
+	}
+	var notVisible []string
+	for _, fieldID := range requestedFields {
+		// The key is returned beside the fields, not among them
+		if fieldID == "key" {
+			continue
+		}
+		returned := false
+		for _, issue := range issues {
+			if issue.Fields.ReturnedFields[fieldID] {
+				returned = true
+				break
+			}
+		}
+		if !returned {
+			notVisible = append(notVisible, fieldID)
+		}
+	}
+	return notVisible
+}
+
+/***********************************************************************************************************************************/
+// requestedFieldColumns names the output column a requested field fills and the extractFieldValues keys it sets
+//
+// Parameters:
+//   fieldID - requested field ID
+//
+// Returns:
+//   string   - column name for messages
+//   []string - extractFieldValues keys filled from the field (none for fields not shown as a single column)
+func (rs *runState) requestedFieldColumns(fieldID string) (string, []string) {
+	switch fieldID {
+	case defaultStoryPointsField:
+		return "Story Points", []string{"StoryPoints"}
+	case rs.flaggedFieldName:
+		return "Flagged", []string{"Flagged"}
+	case rs.pairFieldName:
+		return "Pair", []string{"Pair"}
+	case rs.groupByFieldName:
+		return "Group", nil // getGroupValue checks notVisibleFields itself
+	case defaultSprintField:
+		return "Sprints", nil
+	case defaultEpicLinkField:
+		return "Epic Link", nil
+	case "issuetype":
+		return "Issue Type", []string{"IssueType"}
+	case "status":
+		return "Status", []string{"Status", "StatusCategory"}
+	case "updated":
+		return "Updated", []string{"UpdatedDate"}
+	case "created":
+		return "Created", []string{"CreatedDate"}
+	case "resolutiondate":
+		return "Resolved", []string{"ResolvedDate"}
+	case "assignee":
+		return "Assignee", []string{"Assignee"}
+	case "creator":
+		return "Reporter", []string{"Reporter"}
+	case "project":
+		return "Project", []string{"ProjectName"}
+	case "fixVersions":
+		return "Fix Versions", []string{"FixVersions"}
+	case "components":
+		return "Components", []string{"Components"}
+	case "labels":
+		return "Labels", []string{"Labels"}
+	case "resolution":
+		return "Resolution", []string{"Resolution"}
+	case "priority":
+		return "Priority", []string{"Priority"}
+	case "duedate":
+		return "Due Date", []string{"DueDate"}
+	case "description":
+		return "Description", []string{"Description"}
+	case "comment":
+		return "Comments", []string{"Comments"}
+	case "issuelinks":
+		return "Blocked By/Blocks", []string{"BlockedBy", "Blocks"}
+	}
+	return fieldID, nil
+}
+
+/***********************************************************************************************************************************/
+// warnNotVisibleFields warns about each requested field Jira returned for no issue
+//
+// Parameters:
+//   fieldIDs - fields from findNotVisibleFields
+//
+// Side effects:
+//   - Logs a WARNING naming each field, with the setting to check for configurable fields
+func (rs *runState) warnNotVisibleFields(fieldIDs []string) {
+	for _, fieldID := range fieldIDs {
+		column, _ := rs.requestedFieldColumns(fieldID)
+		message := fmt.Sprintf("NOT VISIBLE: field '%s' (%s) was requested but returned for no issue, so the reporting account "+
+			"probably cannot see it (field-level security) or the field does not exist; its column shows \"%s\" rather than a default value.",
+			fieldID, column, notVisibleValue)
+		switch fieldID {
+		case rs.flaggedFieldName:
+			message += " Find your instance's Flagged field with -dumpissue KEY on a flagged issue and pass it with -flaggedfield."
+		case rs.pairFieldName:
+			message += " Check the -pair field ID with -dumpissue KEY."
+		case rs.groupByFieldName:
+			message += " Check the -groupbyfield field ID with -dumpissue KEY."
+		}
+		rs.writeLog("WARNING", message)
+	}
+}
+
+/***********************************************************************************************************************************/
+// auditCustomFields tallies the values of each configured custom field across the fetched issues (-auditfields)
+//
+// The fields audited are the -pair field, the -groupbyfield field, and the Flagged field. Values are extracted
+// exactly as the report extracts them, so the audit shows what the report's columns would contain.
+//
+// Parameters:
+//   issues - issues fetched from Jira
+//
+// Returns:
+//   []FieldAudit - one audit per configured field
+func (rs *runState) auditCustomFields(issues []Issue) []FieldAudit {
+	type auditedField struct {
+		setting string
+		fieldID string
+		extract func(Issue) string
+	}
+	var fields []auditedField
+	if rs.pairFieldProvided && rs.pairFieldName != "" {
+		fields = append(fields, auditedField{"-pair", rs.pairFieldName, func(issue Issue) string { return rs.extractFieldValues(issue)["Pair"] }})
+	}
+	if rs.groupByFieldName != "" {
+		fields = append(fields, auditedField{"-groupbyfield", rs.groupByFieldName, rs.getGroupValue})
+	}
+	fields = append(fields, auditedField{"-flaggedfield", rs.flaggedFieldName, func(issue Issue) string {
+		if rs.isFlagged(issue) {
+			return "Flagged"
+		}
+		return ""
+	}})
+
+	audits := make([]FieldAudit, 0, len(fields))
+	for _, field := range fields {
+		audit := FieldAudit{Setting: field.setting, FieldID: field.fieldID, Values: make(map[string]int)}
+		for _, issue := range issues {
+			raw, present := issue.Fields.AdditionalFields[field.fieldID]
+			switch strings.TrimSpace(string(raw)) {
+			case "null":
+				audit.Null++
+				continue
+			case "", `""`, "[]", "{}":
+				if present {
+					audit.Empty++
+				} else {
+					audit.Missing++
+				}
+				continue
+			}
+			value := field.extract(issue)
+			if value == "" || value == "(none)" {
+				audit.Empty++
+				continue
+			}
+			audit.Values[value]++
+		}
+		audits = append(audits, audit)
+	}
+	return audits
+}
+
+/***********************************************************************************************************************************/
+// readPreviousReport reads the rows of an earlier report for -compare
+//
+// Columns are found by their header name, so reports with other optional columns (or from older versions)
+// can be compared. Subtotal rows are skipped, and a key appearing more than once (an appended report) keeps
+// its last row. A ".gz" file (-compress) is decompressed.
+//
+// Parameters:
+//   filename - path of the earlier report
+//
+// Returns:
+//   map[string]map[string]string - cells of each row keyed by column name, by issue key
+//   error                        - any error reading the file, or a file without an Issue Key column
+func readPreviousReport(filename string) (map[string]map[string]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read -compare report: %w", err)
+	}
+	if strings.HasSuffix(filename, ".gz") {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -compare report: %w", err)
+		}
+		if content, err = io.ReadAll(gzipReader); err != nil {
+			return nil, fmt.Errorf("failed to read -compare report: %w", err)
+		}
+	}
+	lines := strings.Split(strings.TrimPrefix(string(content), utf8BOM), "\n")
+	header := strings.Split(strings.TrimRight(lines[0], "\r"), "\t")
+	keyColumn := -1
+	for c, column := range header {
+		if column == "Issue Key" {
+			keyColumn = c
+		}
+	}
+	if keyColumn < 0 {
+		return nil, fmt.Errorf("%s is not a spillover report (no Issue Key column in the header row)", filename)
+	}
+
+	rows := make(map[string]map[string]string)
+	for _, line := range lines[1:] {
+		cells := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(cells) <= keyColumn || cells[0] == "Subtotal" || cells[keyColumn] == "" {
+			continue
+		}
+		values := make(map[string]string, len(header))
+		for c, column := range header {
+			if c < len(cells) {
+				values[column] = cells[c]
+			}
+		}
+		rows[cells[keyColumn]] = values
+	}
+	return rows, nil
+}
+
+/***********************************************************************************************************************************/
+// compareWithPreviousReport marks each spillover issue New or Carried against an earlier report (-compare)
+//
+// For carried issues, the compareColumns are diffed and the changes listed in ChangesSinceLast, e.g.
+// "status: In Progress→In Review; sprints: 2→3". A column the earlier report lacks is not compared.
+//
+// Parameters:
+//   filename          - path of the earlier report
+//   multisprintIssues - spillover issues to mark (updated in place)
+//
+// Returns:
+//   string - new, carried, and dropped counts with the status and sprint changes formatted for display
+//   int    - number of spillover issues in the earlier report
+//   error  - any error reading the earlier report
+func (rs *runState) compareWithPreviousReport(filename string, multisprintIssues []MultisprintIssue) (string, int, error) {
+	previous, err := readPreviousReport(filename)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// currentValue is the cell this run writes for a tracked column
+	currentValue := func(multisprintIssue MultisprintIssue, values map[string]string, column string) string {
+		if multisprintIssue.InputValues != nil {
+			return multisprintIssue.InputValues[column]
+		}
+		switch column {
+		case "Status":
+			return values["Status"]
+		case "Assignee":
+			return values["Assignee"]
+		case "Story Points":
+			return values["StoryPoints"]
+		case "Number of Sprints":
+			return strconv.Itoa(multisprintIssue.SprintInfo.SprintCount)
+		default:
+			return multisprintIssue.SprintInfo.LastSprint
+		}
+	}
+
+	newCount, carriedCount, statusChanges, sprintsGained := 0, 0, 0, 0
+	current := make(map[string]bool, len(multisprintIssues))
+	for i := range multisprintIssues {
+		multisprintIssue := &multisprintIssues[i]
+		current[multisprintIssue.Issue.Key] = true
+		old, carried := previous[multisprintIssue.Issue.Key]
+		if !carried {
+			multisprintIssue.SinceLast, multisprintIssue.ChangesSinceLast = "New", ""
+			newCount++
+		} else {
+			multisprintIssue.SinceLast = "Carried"
+			carriedCount++
+			values := rs.extractFieldValues(multisprintIssue.Issue)
+			var changes []string
+			for _, tracked := range compareColumns {
+				oldValue, known := old[tracked.Column]
+				newValue := currentValue(*multisprintIssue, values, tracked.Column)
+				if !known || oldValue == newValue {
+					continue
+				}
+				changes = append(changes, fmt.Sprintf("%s: %s→%s", tracked.Label, oldValue, newValue))
+				switch tracked.Column {
+				case "Status":
+					statusChanges++
+				case "Number of Sprints":
+					oldSprints, oldErr := strconv.Atoi(oldValue)
+					newSprints, newErr := strconv.Atoi(newValue)
+					if oldErr == nil && newErr == nil && newSprints > oldSprints {
+						sprintsGained++
+					}
+				}
+			}
+			multisprintIssue.ChangesSinceLast = strings.Join(changes, "; ")
+		}
+		// Rows read from a saved report (-input) are written from their cells
+		if multisprintIssue.InputValues != nil {
+			multisprintIssue.InputValues["Since Last"] = multisprintIssue.SinceLast
+			multisprintIssue.InputValues["Changes Since Last"] = multisprintIssue.ChangesSinceLast
+		}
+	}
+	dropped := 0
+	for issueKey := range previous {
+		if !current[issueKey] {
+			dropped++
+		}
+	}
+
+	return fmt.Sprintf("Since %s: %d new, %d carried (%d changed status, %d gained another sprint), %d no longer reported",
+		filepath.Base(filename), newCount, carriedCount, statusChanges, sprintsGained, dropped), len(previous), nil
+}
+
+/***********************************************************************************************************************************/
+// readIssueRegistry reads the -registry file
+//
+// A missing file is an empty registry, so the first run creates it. A corrupt file is renamed to
+// <registry>.corrupt-YYYYMMDD-HHMMSS with a WARNING and the registry is rebuilt from this run, rather than
+// failing every later run.
+//
+// Parameters:
+//   filename - path of the registry file
+//
+// Returns:
+//   issueRegistry - registered issues (empty if the file is missing or was corrupt)
+//   error         - the file exists but could not be read, or a corrupt file could not be backed up
+func (rs *runState) readIssueRegistry(filename string) (issueRegistry, error) {
+	registry := issueRegistry{Issues: make(map[string]registryEntry)}
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		rs.writeLog("INFO", fmt.Sprintf("Registry %s does not exist yet; it will be created", filename))
+		return registry, nil
+	} else if err != nil {
+		return registry, fmt.Errorf("failed to read registry %s: %w", filename, err)
+	}
+	if err := json.Unmarshal(content, &registry); err != nil || registry.Issues == nil {
+		backup := filename + ".corrupt-" + time.Now().Format("20060102-150405")
+		if renameErr := os.Rename(filename, backup); renameErr != nil {
+			return registry, fmt.Errorf("registry %s is corrupt and could not be backed up: %w", filename, renameErr)
+		}
+		rs.writeLog("WARNING", fmt.Sprintf("Registry %s is corrupt; moved it to %s and rebuilding it from this run", filename, backup))
+		return issueRegistry{Issues: make(map[string]registryEntry)}, nil
+	}
+	return registry, nil
+}
+
+/***********************************************************************************************************************************/
+// applyIssueRegistry sets FirstReported and WeeksOnReport on each spillover issue and records this run in the registry
+//
+// Issues seen for the first time are added with today's date; issues already registered keep their first-seen
+// date and get today as their last-seen date. Registered issues not in this run are left untouched. Weeks On
+// Report is the days since first reported divided by 7, rounded up, so an issue first reported today is in its
+// first week.
+//
+// Parameters:
+//   registry          - registry read by readIssueRegistry (updated in place)
+//   multisprintIssues - spillover issues to mark (updated in place)
+//   now               - time of the run; dates are taken in the report timezone
+//
+// Returns:
+//   string - newly and previously reported counts and the longest-reported issue, formatted for display
+func (rs *runState) applyIssueRegistry(registry issueRegistry, multisprintIssues []MultisprintIssue, now time.Time) string {
+	today := now.In(rs.reportLocation).Format("2006-01-02")
+	newCount, returningCount, longestWeeks, longestKey := 0, 0, 0, ""
+	for i := range multisprintIssues {
+		multisprintIssue := &multisprintIssues[i]
+		issueKey := multisprintIssue.Issue.Key
+		entry, registered := registry.Issues[issueKey]
+		firstSeen, err := time.ParseInLocation("2006-01-02", entry.FirstSeen, rs.reportLocation)
+		if registered && err != nil {
+			rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Registry entry for %s has an invalid first-seen date '%s'; registering it again from today", issueKey, entry.FirstSeen))
+			registered = false
+		}
+		if !registered {
+			entry = registryEntry{FirstSeen: today}
+			firstSeen = now
+			newCount++
+		} else {
+			returningCount++
+		}
+		entry.LastSeen = today
+		registry.Issues[issueKey] = entry
+
+		weeks := max((CalendarDaysBetween(firstSeen, now, rs.reportLocation)+6)/7, 1)
+		if weeks > longestWeeks || (weeks == longestWeeks && issueKey < longestKey) {
+			longestWeeks, longestKey = weeks, issueKey
+		}
+		multisprintIssue.FirstReported, multisprintIssue.WeeksOnReport = entry.FirstSeen, strconv.Itoa(weeks)
+		// Rows read from a saved report (-input) are written from their cells
+		if multisprintIssue.InputValues != nil {
+			multisprintIssue.InputValues["First Reported"] = multisprintIssue.FirstReported
+			multisprintIssue.InputValues["Weeks On Report"] = multisprintIssue.WeeksOnReport
+		}
+	}
+
+	summary := fmt.Sprintf("Registry: %d issues reported for the first time, %d reported before", newCount, returningCount)
+	if longestKey != "" && longestWeeks > 1 {
+		summary += fmt.Sprintf(" (longest: %s, %d weeks since %s)", longestKey, longestWeeks, registry.Issues[longestKey].FirstSeen)
+	}
+	return summary
+}
+
+/***********************************************************************************************************************************/
+// writeIssueRegistry saves the -registry file
+//
+// The file is replaced atomically so an interrupted run never leaves a half-written registry behind.
+//
+// Parameters:
+//   filename - path of the registry file
+//   registry - registry to save
+//
+// Returns:
+//   error - any error writing the file
+func (rs *runState) writeIssueRegistry(filename string, registry issueRegistry) error {
+	content, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registry: %w", err)
+	}
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create registry directory %s: %w", dir, err)
+		}
+	}
+	tempPath := filename + ".tmp"
+	if err := os.WriteFile(tempPath, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write registry %s: %w", filename, err)
+	}
+	if err := os.Rename(tempPath, filename); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace registry %s: %w", filename, err)
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Registry %s updated (%d issues)", filename, len(registry.Issues)))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// verifyReportRows checks every spillover issue's derived fields before the output is written
+//
+// Each disagreement is logged as a WARNING naming the issue, so it reaches the problems file (-problemsfile).
+// Sprint columns are then re-derived from the issue's unique sprint list, so the row written is consistent.
+// Rows read from a saved report (-input) are written from their cells and only their analysis columns are checked.
+//
+// Parameters:
+//   multisprintIssues - spillover issues about to be written (sprint columns corrected in place)
+//
+// Returns:
+//   int - number of issues with at least one disagreement
+func (rs *runState) verifyReportRows(multisprintIssues []MultisprintIssue) int {
+	inconsistent, rederived := 0, 0
+	for i := range multisprintIssues {
+		multisprintIssue := &multisprintIssues[i]
+		var problems []string
+		if multisprintIssue.InputValues == nil {
+			problems = checkSprintInfo(multisprintIssue.SprintInfo)
+			if multisprintIssue.SprintInfo.SprintCount < 2 {
+				problems = append(problems, fmt.Sprintf("reported as spillover with %d sprints", multisprintIssue.SprintInfo.SprintCount))
+			}
+		}
+		if unestimated, err := strconv.Atoi(multisprintIssue.UnestimatedSprints); err == nil {
+			if unestimated > multisprintIssue.SprintInfo.SprintCount {
+				problems = append(problems, fmt.Sprintf("Unestimated Sprints %d exceeds Number of Sprints %d", unestimated, multisprintIssue.SprintInfo.SprintCount))
+			}
+			if (unestimated > 0) != (multisprintIssue.EstimatedLate == "yes") {
+				problems = append(problems, fmt.Sprintf("Estimated Late is '%s' with %d unestimated sprints", multisprintIssue.EstimatedLate, unestimated))
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+
+		inconsistent++
+		issueKey := multisprintIssue.Issue.Key
+		rs.writeLogWithContext("WARNING", LogContext{Issue: issueKey}, fmt.Sprintf("Integrity check failed for %s: %s", issueKey, strings.Join(problems, "; ")))
+		if multisprintIssue.InputValues == nil {
+			rederived++
+			info := &multisprintIssue.SprintInfo
+			info.SprintCount = len(info.SprintNames)
+			info.AllSprints = strings.Join(info.SprintNames, ", ")
+			if len(info.SprintNames) > 0 {
+				info.FirstSprint, info.LastSprint = info.SprintNames[0], info.SprintNames[len(info.SprintNames)-1]
+			}
+		}
+	}
+	if inconsistent > 0 {
+		message := fmt.Sprintf("Integrity check: %d of %d rows had inconsistent derived fields", inconsistent, len(multisprintIssues))
+		if rederived > 0 {
+			message += fmt.Sprintf("; the sprint columns of %d were re-derived from their sprint lists", rederived)
+		}
+		rs.writeLog("WARNING", message)
+	} else if rs.debug {
+		rs.writeLog("DEBUG", fmt.Sprintf("Integrity check: all %d rows consistent", len(multisprintIssues)))
+	}
+	return inconsistent
+}
+
+/***********************************************************************************************************************************/
+// SummaryFormatName returns the SummaryFormats key for the -format given with -summaryonly
+//
+// Parameters:
+//   format - the -format value; empty or tsv (the per-issue default) means text
+//
+// Returns:
+//   string - the summary format name
+func SummaryFormatName(format string) string {
+	if format == "" || format == "tsv" {
+		return "text"
+	}
+	return format
+}
+
+/***********************************************************************************************************************************/
+// summaryFilePath returns the path a -summaryonly summary document is written to
+//
+// Parameters:
+//   filename - output filename; a ".tsv" extension is replaced by the summary format's
+//   format   - the -format value given with -summaryonly
+//
+// Returns:
+//   string - filename ending in the summary format's extension (empty for an empty filename)
+func summaryFilePath(filename, format string) string {
+	if filename == "" {
+		return ""
+	}
+	extension := SummaryFormats[SummaryFormatName(format)].Extension
+	filename = strings.TrimSuffix(filename, ".tsv")
+	if !strings.HasSuffix(filename, extension) {
+		filename += extension
+	}
+	return filename
+}
+
+/***********************************************************************************************************************************/
+// ReportFilePath returns the path the run writes its report to: the summary document with -summaryonly, otherwise the output file
+//
+// Parameters:
+//   cfg - run settings naming the output file and format
+//
+// Returns:
+//   string - the report path (empty without an output file)
+func ReportFilePath(cfg Config) string {
+	if cfg.SummaryOnly {
+		return summaryFilePath(cfg.OutputFile, cfg.Format)
+	}
+	return outputFilePath(cfg.OutputFile, cfg.Format, cfg.Compress)
+}
+
+/***********************************************************************************************************************************/
+// buildSummary collects the -summaryonly overview of a completed run
+//
+// Only the content is decided here; the renderers in SummaryFormats lay it out, so every format shows the same
+// figures. Everything comes from the report, so no further requests are made.
+//
+// Parameters:
+//   cfg    - run settings, for the run metadata
+//   report - the completed report with its issues, epics, and totals
+//
+// Returns:
+//   Summary - run metadata, spillover totals, top-ten breakdowns, and the trend against CompareFile
+func (rs *runState) buildSummary(cfg Config, report Report) Summary {
+	summary := Summary{Title: "Spillover summary"}
+
+	// Run metadata, naming the source the same way as the -tui parameter pane
+	jira := cfg.JiraBaseURL
+	if len(cfg.Instances) > 0 {
+		var urls []string
+		for _, instance := range cfg.Instances {
+			urls = append(urls, instance.Label+"="+instance.JiraBaseURL)
+		}
+		jira = strings.Join(urls, ", ")
+	}
+	summary.Run = append(summary.Run, SummaryItem{"Program", cfg.Program + " " + cfg.Version})
+	switch {
+	case cfg.InputFile != "":
+		summary.Run = append(summary.Run, SummaryItem{"Input", cfg.InputFile})
+	case len(cfg.IssueKeys) > 0:
+		summary.Run = append(summary.Run, SummaryItem{"Jira", jira}, SummaryItem{"Issues", fmt.Sprintf("%d keys from the keys file", len(cfg.IssueKeys))})
+	default:
+		summary.Title += ": " + cfg.ProjectKey
+		summary.Run = append(summary.Run, SummaryItem{"Jira", jira}, SummaryItem{"Project", cfg.ProjectKey})
+	}
+	if report.JQL != "" {
+		summary.Run = append(summary.Run, SummaryItem{"JQL", report.JQL})
+	}
+	summary.Run = append(summary.Run, SummaryItem{"Started", report.StartedAt.In(rs.reportLocation).Format("2006-01-02 15:04 MST")})
+	if report.Sampled {
+		summary.Run = append(summary.Run, SummaryItem{"Sample", fmt.Sprintf("first %d matching issues only; every count covers the sample", cfg.Sample)})
+	}
+	summary.Run = append(summary.Run, SummaryItem{"Spillover Score", SpilloverScoreFormula(rs.spilloverWeights)})
+
+	// Spillover counts, rate, and story points
+	spillover := len(report.Issues)
+	processed := report.FetchedCount - report.ResolvedExcludedCount
+	rate := "n/a (no issues processed)"
+	if processed > 0 {
+		rate = fmt.Sprintf("%.1f%% of %d processed issues", float64(spillover)*100/float64(processed), processed)
+	}
+	var storyPoints float64
+	estimated := 0
+	for _, multisprintIssue := range report.Issues {
+		if points, ok := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints); ok {
+			storyPoints += points
+			estimated++
+		}
+	}
+	summary.Totals = []SummaryItem{
+		{"Issues fetched", strconv.Itoa(report.FetchedCount)},
+		{"Spillover issues", strconv.Itoa(spillover)},
+		{"Spillover rate", rate},
+		{"Story points", fmt.Sprintf("%s across %d estimated issues (%d unestimated)",
+			strconv.FormatFloat(storyPoints, 'f', -1, 64), estimated, spillover-estimated)},
+		{"In an active sprint", fmt.Sprintf("%d (%d not in one)", report.ActiveSprintCount, spillover-report.ActiveSprintCount)},
+		{"Overdue", strconv.Itoa(report.OverdueCount)},
+		{"Flagged", strconv.Itoa(report.FlaggedCount)},
+	}
+	if len(report.IgnoredIssues) > 0 {
+		summary.Totals = append(summary.Totals, SummaryItem{"Ignored", fmt.Sprintf("%d excluded by ignore labels", len(report.IgnoredIssues))})
+	}
+	if report.TimeSpentSummary != "" {
+		summary.Totals = append(summary.Totals, SummaryItem{"Time spent", strings.TrimPrefix(report.TimeSpentSummary, "Time spent: ")})
+	}
+
+	// The most severe spillover issues
+	for _, multisprintIssue := range report.TopScores {
+		summary.TopScores = append(summary.TopScores, SummaryItem{multisprintIssue.Issue.Key, fmt.Sprintf("%s (%d sprints, %s story points)",
+			rs.formatSpilloverScore(multisprintIssue), multisprintIssue.SprintInfo.SprintCount, FormatStoryPoints(multisprintIssue.Issue.Fields.StoryPoints))})
+	}
+
+	// The biggest spillover groups, the same fallbacks as the output columns
+	summary.Breakdowns = []SummaryBreakdown{
+		buildSummaryBreakdown("By issue type", "Issue type", report.Issues, func(multisprintIssue MultisprintIssue) string {
+			if name := multisprintIssue.Issue.Fields.IssueType.Name; name != "" {
+				return name
+			}
+			return "Unknown"
+		}),
+		buildSummaryBreakdown("By priority", "Priority", report.Issues, func(multisprintIssue MultisprintIssue) string {
+			if priority := multisprintIssue.Issue.Fields.Priority; priority != nil && priority.Name != "" {
+				return priority.Name
+			}
+			return "No priority"
+		}),
+		buildSummaryBreakdown("By epic", "Epic", report.Issues, func(multisprintIssue MultisprintIssue) string {
+			if multisprintIssue.EpicLink == "" {
+				return "No Epic"
+			}
+			epicSummary := report.Epics[multisprintIssue.EpicLink].Summary
+			if epicSummary == "" {
+				epicSummary = "No Epic Summary"
+			}
+			return multisprintIssue.EpicLink + " " + epicSummary
+		}),
+		buildSummaryBreakdown("By assignee", "Assignee", report.Issues, func(multisprintIssue MultisprintIssue) string {
+			if assignee := multisprintIssue.Issue.Fields.Assignee; assignee != nil {
+				return rs.formatIdentity(assignee.DisplayName, assignee.AccountID, assignee.EmailAddress)
+			}
+			return "Unassigned"
+		}),
+	}
+
+	// Trend against the earlier report (-compare)
+	if cfg.CompareFile != "" {
+		summary.Trend = []string{
+			fmt.Sprintf("Spillover issues: %d, %+d against %d in %s", spillover, spillover-report.PreviousCount,
+				report.PreviousCount, filepath.Base(cfg.CompareFile)),
+			report.CompareSummary,
+		}
+	}
+	return summary
+}
+
+/***********************************************************************************************************************************/
+// buildSummaryBreakdown groups the spillover issues one way and ranks the groups for a Summary
+//
+// Parameters:
+//   title             - section heading, e.g. "By issue type"
+//   group             - heading for the group names, e.g. "Issue type"
+//   multisprintIssues - the final spillover issues
+//   name              - returns the group an issue belongs to
+//
+// Returns:
+//   SummaryBreakdown - the first summaryTopCount groups by issue count, then story points, then name
+func buildSummaryBreakdown(title, group string, multisprintIssues []MultisprintIssue, name func(MultisprintIssue) string) SummaryBreakdown {
+	rowIndex := make(map[string]int)
+	var rows []SummaryBreakdownRow
+	for _, multisprintIssue := range multisprintIssues {
+		groupName := name(multisprintIssue)
+		idx, exists := rowIndex[groupName]
+		if !exists {
+			idx = len(rows)
+			rowIndex[groupName] = idx
+			rows = append(rows, SummaryBreakdownRow{Name: groupName})
+		}
+		points, _ := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints)
+		rows[idx].Issues++
+		rows[idx].StoryPoints += points
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Issues != rows[j].Issues {
+			return rows[i].Issues > rows[j].Issues
+		}
+		if rows[i].StoryPoints != rows[j].StoryPoints {
+			return rows[i].StoryPoints > rows[j].StoryPoints
+		}
+		return rows[i].Name < rows[j].Name
+	})
+
+	breakdown := SummaryBreakdown{Title: title, Group: group, Rows: rows}
+	if len(rows) > summaryTopCount {
+		breakdown.Rows, breakdown.More = rows[:summaryTopCount], len(rows)-summaryTopCount
+	}
+	return breakdown
+}
+
+/***********************************************************************************************************************************/
+// renderSummaryText writes a Summary as plain text, aligned for a fixed-width font (-summaryonly -format text)
+//
+// Parameters:
+//   w       - destination of the document
+//   summary - content from buildSummary
+//
+// Returns:
+//   error - any error writing the document
+func renderSummaryText(w io.Writer, summary Summary) error {
+	var b strings.Builder
+	b.WriteString(summary.Title + "\n" + strings.Repeat("=", DisplayWidth(summary.Title)) + "\n")
+
+	items := func(heading string, items []SummaryItem) {
+		width := 0
+		for _, item := range items {
+			width = max(width, len(item.Label)+1)
+		}
+		b.WriteString("\n" + heading + "\n")
+		for _, item := range items {
+			fmt.Fprintf(&b, "  %-*s  %s\n", width, item.Label+":", item.Value)
+		}
+	}
+	items("Run", summary.Run)
+	items("Spillover", summary.Totals)
+	if len(summary.TopScores) > 0 {
+		items("Highest spillover scores", summary.TopScores)
+	}
+
+	for _, breakdown := range summary.Breakdowns {
+		b.WriteString("\n" + breakdown.Title + "\n")
+		if len(breakdown.Rows) == 0 {
+			b.WriteString("  (no spillover issues)\n")
+			continue
+		}
+		width := DisplayWidth(breakdown.Group)
+		for _, row := range breakdown.Rows {
+			width = max(width, DisplayWidth(row.Name))
+		}
+		pad := func(value string) string {
+			return value + strings.Repeat(" ", width-DisplayWidth(value))
+		}
+		fmt.Fprintf(&b, "  %s  %6s  %12s\n", pad(breakdown.Group), "Issues", "Story points")
+		for _, row := range breakdown.Rows {
+			fmt.Fprintf(&b, "  %s  %6d  %12s\n", pad(row.Name), row.Issues, strconv.FormatFloat(row.StoryPoints, 'f', -1, 64))
+		}
+		if breakdown.More > 0 {
+			fmt.Fprintf(&b, "  ... and %d more\n", breakdown.More)
+		}
+	}
+
+	if len(summary.Trend) > 0 {
+		b.WriteString("\nTrend\n")
+		for _, line := range summary.Trend {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/***********************************************************************************************************************************/
+// renderSummaryHTML writes a Summary as a self-contained HTML page (-summaryonly -format html)
+//
+// Parameters:
+//   w       - destination of the document
+//   summary - content from buildSummary
+//
+// Returns:
+//   error - any error writing the document
+func renderSummaryHTML(w io.Writer, summary Summary) error {
+	const style = "body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:1em}" +
+		"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}td.number{text-align:right}"
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n<h1>%s</h1>\n",
+		html.EscapeString(summary.Title), style, html.EscapeString(summary.Title))
+
+	items := func(heading string, items []SummaryItem) {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n", html.EscapeString(heading))
+		for _, item := range items {
+			fmt.Fprintf(&b, "<tr><th>%s</th><td>%s</td></tr>\n", html.EscapeString(item.Label), html.EscapeString(item.Value))
+		}
+		b.WriteString("</table>\n")
+	}
+	items("Run", summary.Run)
+	items("Spillover", summary.Totals)
+	if len(summary.TopScores) > 0 {
+		items("Highest spillover scores", summary.TopScores)
+	}
+
+	for _, breakdown := range summary.Breakdowns {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(breakdown.Title))
+		if len(breakdown.Rows) == 0 {
+			b.WriteString("<p>No spillover issues</p>\n")
+			continue
+		}
+		fmt.Fprintf(&b, "<table>\n<tr><th>%s</th><th>Issues</th><th>Story points</th></tr>\n", html.EscapeString(breakdown.Group))
+		for _, row := range breakdown.Rows {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td class=\"number\">%d</td><td class=\"number\">%s</td></tr>\n",
+				html.EscapeString(row.Name), row.Issues, strconv.FormatFloat(row.StoryPoints, 'f', -1, 64))
+		}
+		b.WriteString("</table>\n")
+		if breakdown.More > 0 {
+			fmt.Fprintf(&b, "<p>... and %d more</p>\n", breakdown.More)
+		}
+	}
+
+	if len(summary.Trend) > 0 {
+		b.WriteString("<h2>Trend</h2>\n<ul>\n")
+		for _, line := range summary.Trend {
+			fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(line))
+		}
+		b.WriteString("</ul>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/***********************************************************************************************************************************/
+// writeSummaryFile writes the -summaryonly summary document in the requested format
+//
+// Parameters:
+//   filename - path from summaryFilePath
+//   format   - the -format value given with -summaryonly
+//   summary  - content from buildSummary
+//
+// Returns:
+//   error - any error creating or writing the file
+func (rs *runState) writeSummaryFile(filename, format string, summary Summary) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create summary file: %w", err)
+	}
+	if err := SummaryFormats[SummaryFormatName(format)].Render(file, summary); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Successfully wrote the spillover summary to %s (-summaryonly)", filename))
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// completeReport writes the final spillover issues to the output file and every optional output, and totals them for the report
+//
+// Parameters:
+//   cfg               - run settings naming the optional outputs
+//   report            - report receiving the epics, output file name, and totals
+//   multisprintIssues - final spillover issues, sorted in place into output order
+//   epics             - epic summary, status, and resolution date per epic key
+//   excludedFile      - filename for the issues excluded by ignore labels (empty for none)
+//
+// Returns:
+//   error - any error writing an output file
+func (rs *runState) completeReport(cfg Config, report *Report, multisprintIssues []MultisprintIssue, epics map[string]EpicMeta, excludedFile string) error {
+	report.Epics = epics
+
+	// An epic closed while its children are still spilling over suggests it was closed prematurely
+	for _, multisprintIssue := range multisprintIssues {
+		if resolutionDate := epics[multisprintIssue.EpicLink].ResolutionDate; resolutionDate != nil && *resolutionDate != "" {
+			report.ResolvedEpicCount++
+		}
+	}
+	if report.ResolvedEpicCount > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("%d spillover issues belong to an epic that is already resolved", report.ResolvedEpicCount))
+	}
+
+	// Order output rows once all filtering is complete so subtotals match the rows written and reruns are diffable
+	rs.sortMultisprintIssues(multisprintIssues)
+	rs.sortMultisprintIssues(report.IgnoredIssues)
+	report.Issues = multisprintIssues
+
+	// Mark each issue New or Carried against the earlier report, noting what changed on the carried ones (-compare)
+	if cfg.CompareFile != "" {
+		summary, previousCount, err := rs.compareWithPreviousReport(cfg.CompareFile, multisprintIssues)
+		if err != nil {
+			return err
+		}
+		report.CompareSummary, report.PreviousCount = summary, previousCount
+		rs.writeLog("INFO", report.CompareSummary)
+	}
+
+	// Date each issue from the registry; the registry itself is only saved once the output file is written (-registry)
+	var registry issueRegistry
+	if cfg.RegistryFile != "" {
+		var err error
+		if registry, err = rs.readIssueRegistry(cfg.RegistryFile); err != nil {
+			return err
+		}
+		report.RegistrySummary = rs.applyIssueRegistry(registry, multisprintIssues, report.StartedAt)
+		rs.writeLog("INFO", report.RegistrySummary)
+	}
+
+	// Never write a row whose derived fields disagree without saying so
+	report.InconsistentRows = rs.verifyReportRows(multisprintIssues)
+
+	// Write output file; -summaryonly writes its summary document instead, once every total below is known
+	if !cfg.SummaryOnly {
+		rs.runHooks.phase("Formatting output data")
+		writtenFiles, err := rs.writeOutputFile(cfg.OutputFile, multisprintIssues, epics, cfg.AppendMode)
+		report.OutputFiles = writtenFiles
+		if rs.splitBy == "" {
+			report.OutputFile = writtenFiles[0].Path
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		if cfg.RegistryFile != "" {
+			if err := rs.writeIssueRegistry(cfg.RegistryFile, registry); err != nil {
+				return err
+			}
+		}
+		for _, written := range writtenFiles {
+			report.WrittenFiles = append(report.WrittenFiles, written.Path)
+		}
+	}
+	// Report identities that could not be shown in the requested form
+	if rs.identityFallbackCount > 0 {
+		missingField := "emailAddress"
+		if rs.identityMode == "accountid" {
+			missingField = "accountId"
+		}
+		rs.writeLog("INFO", fmt.Sprintf("%d identities fell back to display name because %s was not available (-identityfields %s)",
+			rs.identityFallbackCount, missingField, rs.identityMode))
+	}
+
+	// Write issues excluded by ignore labels for auditability
+	if excludedFile != "" {
+		writtenExcludedFiles, err := rs.writeOutputFile(excludedFile, report.IgnoredIssues, epics, false)
+		if err != nil {
+			return fmt.Errorf("failed to write excluded issues file: %w", err)
+		}
+		for _, written := range writtenExcludedFiles {
+			report.WrittenFiles = append(report.WrittenFiles, written.Path)
+		}
+	}
+
+	// Report cell truncation once rather than per cell
+	report.TruncatedCells = rs.truncatedCellCount
+	report.TruncatedIssues = rs.truncatedRowCount
+	if rs.truncatedCellCount > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("Truncated %d cells across %d issues to %d characters (-maxcellwidth %d)",
+			rs.truncatedCellCount, rs.truncatedRowCount, rs.maxCellWidth, rs.maxCellWidth))
+	}
+
+	// Debug: Show how many issues had a non-empty Pair field
+	pairFieldFoundCount := rs.countPairFieldFound(multisprintIssues)
+	if rs.debug && rs.pairFieldProvided && rs.pairFieldName != "" {
+		rs.writeLog("DEBUG", fmt.Sprintf("pairFieldFoundCount after processing: %d", pairFieldFoundCount))
+	}
+	// If a Pair field was requested but wasn't found on any issue, warn the caller
+	if rs.pairFieldProvided && rs.pairFieldName != "" && pairFieldFoundCount == 0 {
+		report.PairFieldMissing = true
+		rs.writeLog("WARNING", fmt.Sprintf("Pair field '%s' was requested but not found on any issues. Check the field name.", rs.pairFieldName))
+	}
+
+	// Write sprint pair boundary totals if requested
+	if cfg.SprintPairsFile != "" {
+		if err := rs.writeSprintPairsFile(cfg.SprintPairsFile, rs.buildSprintPairs(multisprintIssues)); err != nil {
+			return fmt.Errorf("failed to write sprint pairs file: %w", err)
+		}
+		report.WrittenFiles = append(report.WrittenFiles, ensureTSVExtension(cfg.SprintPairsFile))
+	}
+
+	// Write spillover into and out of each sprint if requested
+	if cfg.PerSprintFile != "" {
+		if err := rs.writePerSprintFile(cfg.PerSprintFile, rs.buildSprintFlow(multisprintIssues)); err != nil {
+			return fmt.Errorf("failed to write per-sprint file: %w", err)
+		}
+		report.WrittenFiles = append(report.WrittenFiles, ensureExtension(cfg.PerSprintFile, reportFormatExtension(rs.outputFormat)))
+	}
+
+	// Total spillover per epic from the final issue set and the epic summaries already fetched
+	if cfg.EpicRollupFile != "" {
+		report.EpicRollup = rs.buildEpicRollup(multisprintIssues, epics)
+		if err := rs.writeEpicRollupFile(cfg.EpicRollupFile, report.EpicRollup); err != nil {
+			return fmt.Errorf("failed to write epic rollup file: %w", err)
+		}
+		report.WrittenFiles = append(report.WrittenFiles, ensureExtension(cfg.EpicRollupFile, reportFormatExtension(rs.outputFormat)))
+	}
+
+	// Summarise spillover per fix version from the values already fetched
+	if cfg.ByRelease {
+		report.ReleaseStats = buildReleaseStats(multisprintIssues)
+		for _, stat := range report.ReleaseStats {
+			rs.writeLog("INFO", "Spillover by release - "+FormatReleaseStat(stat))
+		}
+	}
+
+	// Count spillover issues per staleness bucket
+	report.StalenessCounts = make(map[string]int)
+	for _, multisprintIssue := range multisprintIssues {
+		report.StalenessCounts[multisprintIssue.Staleness]++
+	}
+	report.StalenessSummary = fmt.Sprintf("Staleness: %d Fresh, %d Aging, %d Stale, %d Abandoned",
+		report.StalenessCounts["Fresh"], report.StalenessCounts["Aging"], report.StalenessCounts["Stale"], report.StalenessCounts["Abandoned"])
+	if report.StalenessCounts["Unknown"] > 0 {
+		report.StalenessSummary += fmt.Sprintf(", %d Unknown", report.StalenessCounts["Unknown"])
+	}
+	rs.writeLog("INFO", report.StalenessSummary)
+
+	// Count spillover issues per status category, separating work still open from work finished late
+	report.StatusCategoryCounts = make(map[string]int)
+	for _, multisprintIssue := range multisprintIssues {
+		category := multisprintIssue.Issue.Fields.Status.StatusCategory.Name
+		if category == "" {
+			category = "Unknown"
+		}
+		report.StatusCategoryCounts[category]++
+	}
+	report.StatusCategorySummary = formatStatusCategorySummary(report.StatusCategoryCounts)
+	rs.writeLog("INFO", report.StatusCategorySummary)
+
+	// Count spillover issues per priority, showing whether spillover skews toward high-priority work
+	report.PriorityCounts = make(map[string]int)
+	for _, multisprintIssue := range multisprintIssues {
+		priority := "No priority"
+		if multisprintIssue.Issue.Fields.Priority != nil && multisprintIssue.Issue.Fields.Priority.Name != "" {
+			priority = multisprintIssue.Issue.Fields.Priority.Name
+		}
+		report.PriorityCounts[priority]++
+	}
+	report.PrioritySummary = formatPrioritySummary(report.PriorityCounts, len(multisprintIssues))
+	rs.writeLog("INFO", report.PrioritySummary)
+
+	// Count spillover issues that have blown past their due date
+	for _, multisprintIssue := range multisprintIssues {
+		if multisprintIssue.Overdue {
+			report.OverdueCount++
+		}
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Overdue: %d spillover issues past their due date", report.OverdueCount))
+
+	// Count spillover issues flagged as impediments, the prime suspects for why work spills over
+	for _, multisprintIssue := range multisprintIssues {
+		if rs.isFlagged(multisprintIssue.Issue) {
+			report.FlaggedCount++
+		}
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Flagged: %d spillover issues flagged as an impediment", report.FlaggedCount))
+
+	// Split spillover into work in a sprint now and work left in the backlog
+	for _, multisprintIssue := range multisprintIssues {
+		if len(multisprintIssue.SprintInfo.ActiveSprints) > 0 {
+			report.ActiveSprintCount++
+		}
+	}
+	rs.writeLog("INFO", fmt.Sprintf("Active sprint: %d spillover issues in an active sprint, %d not in one",
+		report.ActiveSprintCount, len(multisprintIssues)-report.ActiveSprintCount))
+
+	// Rank the estimation outliers: many sprints for few story points
+	report.TopChurn = topChurnIssues(multisprintIssues, 5)
+	for _, multisprintIssue := range report.TopChurn {
+		rs.writeLog("INFO", fmt.Sprintf("High churn: %s churn score %s (%d sprints, %s story points)", multisprintIssue.Issue.Key,
+			FormatChurnScore(multisprintIssue), multisprintIssue.SprintInfo.SprintCount, FormatStoryPoints(multisprintIssue.Issue.Fields.StoryPoints)))
+	}
+
+	// Rank by severity, noting the scores that could not count story points
+	report.TopScores = rs.topScoreIssues(multisprintIssues, summaryTopCount)
+	for _, multisprintIssue := range report.TopScores {
+		rs.writeLog("INFO", fmt.Sprintf("High spillover score: %s score %s (%d sprints, %s story points)", multisprintIssue.Issue.Key,
+			rs.formatSpilloverScore(multisprintIssue), multisprintIssue.SprintInfo.SprintCount, FormatStoryPoints(multisprintIssue.Issue.Fields.StoryPoints)))
+	}
+	for _, multisprintIssue := range multisprintIssues {
+		if _, ok := StoryPointsValue(multisprintIssue.Issue.Fields.StoryPoints); !ok {
+			report.UnscoredPointsCount++
+		}
+	}
+	if report.UnscoredPointsCount > 0 {
+		rs.writeLog("INFO", fmt.Sprintf("Spillover Score: %d spillover issues without numeric story points are scored on sprints and age only",
+			report.UnscoredPointsCount))
+	}
+
+	// Count spillover issues held up by another spillover issue: the dependency chains that spill over together
+	if rs.includeLinks {
+		spilloverKeys := make(map[string]bool, len(multisprintIssues))
+		for _, multisprintIssue := range multisprintIssues {
+			spilloverKeys[multisprintIssue.Issue.Key] = true
+		}
+		for _, multisprintIssue := range multisprintIssues {
+			blockedBy, _ := rs.issueLinkKeys(multisprintIssue.Issue)
+			for _, key := range blockedBy {
+				if spilloverKeys[key] {
+					report.BlockedChainCount++
+					break
+				}
+			}
+		}
+		report.BlockedChainSummary = fmt.Sprintf("Blocked chains: %d spillover issues are blocked by another spillover issue (link types: %s)",
+			report.BlockedChainCount, strings.Join(rs.linkTypeNames, ", "))
+		rs.writeLog("INFO", report.BlockedChainSummary)
+	}
+
+	// Turn spillover into hours for cost analysis: aggregate time includes sub-tasks, so nothing is counted twice
+	if rs.includeTime {
+		logged := 0
+		var seconds float64
+		for _, multisprintIssue := range multisprintIssues {
+			if secondsToHours(multisprintIssue.Issue.Fields.AggregateTimeSpent) != nil {
+				seconds += *multisprintIssue.Issue.Fields.AggregateTimeSpent
+				logged++
+			}
+		}
+		report.TimeSpentHours = *secondsToHours(&seconds)
+		report.TimeSpentSummary = fmt.Sprintf("Time spent: %s hours logged on %d of %d spillover issues (including sub-tasks)",
+			strconv.FormatFloat(report.TimeSpentHours, 'f', 1, 64), logged, len(multisprintIssues))
+		rs.writeLog("INFO", report.TimeSpentSummary)
+	}
+
+	// Write the summary document in place of the per-issue rows (-summaryonly)
+	if cfg.SummaryOnly {
+		rs.runHooks.phase("Formatting output data")
+		report.OutputFile = summaryFilePath(cfg.OutputFile, cfg.Format)
+		if err := rs.writeSummaryFile(report.OutputFile, cfg.Format, rs.buildSummary(cfg, *report)); err != nil {
+			return err
+		}
+		report.WrittenFiles = append(report.WrittenFiles, report.OutputFile)
+		if cfg.RegistryFile != "" {
+			if err := rs.writeIssueRegistry(cfg.RegistryFile, registry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// formatStatusCategorySummary formats the spillover count per status category for the summary
+//
+// Jira's own categories come first in workflow order, always shown so runs compare at a glance; any other
+// names (a localised site, or "Unknown") follow alphabetically.
+//
+// Parameters:
+//   counts - spillover issue count per status category name
+//
+// Returns:
+//   string - e.g. "Status categories: 3 To Do, 5 In Progress, 1 Done"
+func formatStatusCategorySummary(counts map[string]int) string {
+	standard := []string{"To Do", "In Progress", "Done"}
+	parts := make([]string, 0, len(counts)+len(standard))
+	for _, category := range standard {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[category], category))
+	}
+	var others []string
+	for category := range counts {
+		if category != "To Do" && category != "In Progress" && category != "Done" {
+			others = append(others, category)
+		}
+	}
+	sort.Strings(others)
+	for _, category := range others {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[category], category))
+	}
+	return "Status categories: " + strings.Join(parts, ", ")
+}
+
+/***********************************************************************************************************************************/
+// formatPrioritySummary formats the spillover count per priority for the summary
+//
+// Jira's default priorities come first, highest first, then any others alphabetically, then issues without
+// a priority. Names are matched case-insensitively against the defaults, as each instance names its own.
+//
+// Parameters:
+//   counts - spillover issue count per priority name
+//   total  - number of spillover issues
+//
+// Returns:
+//   string - e.g. "Priorities: 3 Highest (30%), 5 High (50%), 2 Medium (20%)"
+func formatPrioritySummary(counts map[string]int, total int) string {
+	defaultRank := map[string]int{"blocker": 0, "highest": 1, "critical": 2, "high": 3, "major": 4, "medium": 5, "minor": 6, "low": 7, "lowest": 8, "trivial": 9}
+	priorities := make([]string, 0, len(counts))
+	for priority := range counts {
+		priorities = append(priorities, priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		a, b := priorities[i], priorities[j]
+		if (a == "No priority") != (b == "No priority") {
+			return b == "No priority"
+		}
+		rankA, knownA := defaultRank[strings.ToLower(a)]
+		rankB, knownB := defaultRank[strings.ToLower(b)]
+		if knownA != knownB {
+			return knownA
+		}
+		if knownA && rankA != rankB {
+			return rankA < rankB
+		}
+		return a < b
+	})
+
+	parts := make([]string, 0, len(priorities))
+	for _, priority := range priorities {
+		parts = append(parts, fmt.Sprintf("%d %s (%.0f%%)", counts[priority], priority, 100*float64(counts[priority])/float64(max(total, 1))))
+	}
+	if len(parts) == 0 {
+		return "Priorities: no spillover issues"
+	}
+	return "Priorities: " + strings.Join(parts, ", ")
+}
+
+/***********************************************************************************************************************************/
+// FormatReleaseStat formats one -byrelease summary line
+//
+// Parameters:
+//   stat - spillover totals for one fix version
+//
+// Returns:
+//   string - "<version>: <n> issues, <points> story points"
+func FormatReleaseStat(stat ReleaseStat) string {
+	return fmt.Sprintf("%s: %d issues, %s story points",
+		stat.Version, stat.IssueCount, strconv.FormatFloat(stat.StoryPoints, 'f', -1, 64))
+}
+
+/***********************************************************************************************************************************/
+// FormatFieldAudit formats a custom field audit as a frequency table, most common value first
+//
+// The fieldAuditTopValues most common values are listed, and any others are counted on one "other" line.
+// Values longer than fieldAuditValueWidth characters are truncated.
+//
+// Parameters:
+//   audit - audit from auditCustomFields
+//
+// Returns:
+//   []string - the heading line followed by the table lines
+func FormatFieldAudit(audit FieldAudit) []string {
+	withValue := 0
+	values := make([]string, 0, len(audit.Values))
+	for value, count := range audit.Values {
+		withValue += count
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if audit.Values[values[i]] != audit.Values[values[j]] {
+			return audit.Values[values[i]] > audit.Values[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	lines := []string{
+		fmt.Sprintf("Field audit for %s %s: %d issues with a value (%d distinct), %d missing, %d null, %d empty",
+			audit.Setting, audit.FieldID, withValue, len(values), audit.Missing, audit.Null, audit.Empty),
+	}
+	if len(values) == 0 {
+		return lines
+	}
+	lines = append(lines, fmt.Sprintf("  %6s  %s", "Issues", "Value"))
+	for _, value := range values[:min(fieldAuditTopValues, len(values))] {
+		lines = append(lines, fmt.Sprintf("  %6d  %s", audit.Values[value], truncateWithEllipsis(sanitizeCellValue(value), fieldAuditValueWidth)))
+	}
+	if len(values) > fieldAuditTopValues {
+		otherCount := 0
+		for _, value := range values[fieldAuditTopValues:] {
+			otherCount += audit.Values[value]
+		}
+		lines = append(lines, fmt.Sprintf("  %6d  (%d other values)", otherCount, len(values)-fieldAuditTopValues))
+	}
+	return lines
+}
+
+/***********************************************************************************************************************************/
+// RuneWidth returns the number of console columns a character occupies
+//
+// East Asian wide and fullwidth characters and emoji take two columns, combining marks and
+// control characters none.
+//
+// Parameters:
+//   r - character to measure
+//
+// Returns:
+//   int - 0, 1, or 2
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.IsControl(r) || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && r <= 0x115F, // Hangul Jamo
+		r >= 0x2E80 && r <= 0x303E, // CJK radicals and punctuation
+		r >= 0x3041 && r <= 0x33FF, // Kana, CJK compatibility
+		r >= 0x3400 && r <= 0x4DBF, // CJK extension A
+		r >= 0x4E00 && r <= 0x9FFF, // CJK unified ideographs
+		r >= 0xA000 && r <= 0xA4CF, // Yi
+		r >= 0xAC00 && r <= 0xD7A3, // Hangul syllables
+		r >= 0xF900 && r <= 0xFAFF, // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F, // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60, // Fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F, // Emoji and pictographs
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD: // CJK extensions B onwards
+		return 2
+	}
+	return 1
+}
+
+/***********************************************************************************************************************************/
+// DisplayWidth returns the number of console columns a string occupies
+//
+// Parameters:
+//   value - string to measure
+//
+// Returns:
+//   int - total width of its characters
+func DisplayWidth(value string) int {
+	width := 0
+	for _, r := range value {
+		width += RuneWidth(r)
+	}
+	return width
+}
@@ -0,0 +1,1516 @@
+package spillover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRunState returns a runState with default settings whose log messages go to recorder.
+func newTestRunState(t testing.TB, recorder *logRecorder) *runState {
+	t.Helper()
+	rs, err := newRunState(context.Background(), Config{Hooks: Hooks{OnLog: recorder.log}})
+	if err != nil {
+		t.Fatalf("newRunState: %v", err)
+	}
+	return rs
+}
+
+func TestPrepareAppendFile(t *testing.T) {
+	header := []string{"Issue Type", "Issue Key", "Summary"}
+	headerLine := strings.Join(header, "\t")
+	row := "Story\tEXPD-1\tImport customer CSV files"
+
+	tests := []struct {
+		name        string
+		content     *string // nil: no file
+		repair      bool
+		wantHeader  bool   // Whether the header must be written
+		wantErr     string // Text the error must contain ("" for no error)
+		wantContent string // File content afterwards (unchecked when the file is missing)
+		wantWarning bool
+	}{
+		{name: "missing file", wantHeader: true},
+		{name: "empty file", content: ptr(""), wantHeader: true, wantContent: ""},
+		{name: "blank file", content: ptr(" \n\n"), wantHeader: true, wantContent: " \n\n"},
+		{name: "header only", content: ptr(headerLine + "\n"), wantContent: headerLine + "\n"},
+		{name: "header only without newline", content: ptr(headerLine), wantContent: headerLine + "\n", wantWarning: true},
+		{name: "header with byte order mark", content: ptr(utf8BOM + headerLine + "\n" + row + "\n"), wantContent: utf8BOM + headerLine + "\n" + row + "\n"},
+		{name: "complete rows", content: ptr(headerLine + "\n" + row + "\n"), wantContent: headerLine + "\n" + row + "\n"},
+		{name: "complete row without newline", content: ptr(headerLine + "\n" + row), wantContent: headerLine + "\n" + row + "\n", wantWarning: true},
+		{name: "short row with newline is kept", content: ptr(headerLine + "\n" + "Story\tEXPD-1\n"), wantContent: headerLine + "\n" + "Story\tEXPD-1\n"},
+		{name: "long row without newline is not partial", content: ptr(headerLine + "\n" + row + "\textra"), wantContent: headerLine + "\n" + row + "\textra\n", wantWarning: true},
+		{name: "comment without newline", content: ptr(headerLine + "\n" + row + "\n# note"), wantContent: headerLine + "\n" + row + "\n# note\n", wantWarning: true},
+		{
+			name:        "short final row refused",
+			content:     ptr(headerLine + "\n" + row + "\nStory\tEXP"),
+			wantErr:     "at byte offset 68, has 2 columns instead of 3 and no newline",
+			wantContent: headerLine + "\n" + row + "\nStory\tEXP",
+		},
+		{
+			name:        "short final row repaired",
+			content:     ptr(headerLine + "\n" + row + "\nStory\tEXP"),
+			repair:      true,
+			wantContent: headerLine + "\n" + row + "\n",
+			wantWarning: true,
+		},
+		{name: "partial header refused", content: ptr("Issue Type\tIss"), wantErr: "only part of a header", wantContent: "Issue Type\tIss"},
+		{name: "partial header repaired", content: ptr("Issue Type\tIss"), repair: true, wantHeader: true, wantContent: "", wantWarning: true},
+		{
+			name:        "extra column",
+			content:     ptr(headerLine + "\tTime Spent\n" + row + "\t1.5\n"),
+			wantErr:     "the file has 4 columns and this run writes 3",
+			wantContent: headerLine + "\tTime Spent\n" + row + "\t1.5\n",
+		},
+		{
+			name:        "renamed column",
+			content:     ptr("Issue Type\tKey\tSummary\n" + row + "\n"),
+			repair:      true,
+			wantErr:     `column 2 is "Key" in the file but "Issue Key" in this run`,
+			wantContent: "Issue Type\tKey\tSummary\n" + row + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "spillover.tsv")
+			if test.content != nil {
+				if err := os.WriteFile(filename, []byte(*test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			recorder := &logRecorder{}
+			rs := newTestRunState(t, recorder)
+
+			writeHeader, err := rs.prepareAppendFile(filename, header, test.repair)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatalf("prepareAppendFile: %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Fatalf("prepareAppendFile error = %v, want one containing %q", err, test.wantErr)
+			}
+			if writeHeader != test.wantHeader {
+				t.Errorf("write header = %t, want %t", writeHeader, test.wantHeader)
+			}
+			if test.content != nil {
+				content, err := os.ReadFile(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != test.wantContent {
+					t.Errorf("file afterwards = %q, want %q", content, test.wantContent)
+				}
+			}
+			if warned := recorder.Count("WARNING") > 0; warned != test.wantWarning {
+				t.Errorf("warned = %t, want %t", warned, test.wantWarning)
+			}
+		})
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+// sprintAt returns a sprint detail starting on the given date, or undated when date is empty.
+func sprintAt(id, name, date string) SprintDetail {
+	sprint := SprintDetail{ID: id, Name: name}
+	if date != "" {
+		start, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			panic(err)
+		}
+		sprint.StartDate = &start
+	}
+	return sprint
+}
+
+func TestSprintBeforeIsTransitive(t *testing.T) {
+	sprints := []SprintDetail{
+		sprintAt("1", "Zulu", "2026-09-01"),
+		sprintAt("2", "Mike", ""),
+		sprintAt("3", "Alpha", "2026-09-15"),
+		sprintAt("4", "Bravo", ""),
+		sprintAt("5", "Echo", "2026-09-15"),
+		sprintAt("6", "Bravo", ""),
+	}
+	// With dates compared only when both sprints have one, Zulu < Alpha (dates), Alpha < Mike (names) but
+	// Mike < Zulu (names): a cycle that left the sort order depending on the input order
+	for _, a := range sprints {
+		for _, b := range sprints {
+			for _, c := range sprints {
+				if sprintBefore(a, b) && sprintBefore(b, c) && !sprintBefore(a, c) {
+					t.Errorf("%s < %s < %s but not %s < %s", a.Name, b.Name, c.Name, a.Name, c.Name)
+				}
+			}
+			if sprintBefore(a, b) && sprintBefore(b, a) {
+				t.Errorf("%s and %s are each before the other", a.Name, b.Name)
+			}
+		}
+	}
+
+	want := "Zulu Alpha Echo Bravo Bravo Mike"
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5}, {5, 4, 3, 2, 1, 0}, {1, 3, 5, 0, 2, 4}} {
+		shuffled := make([]SprintDetail, 0, len(sprints))
+		for _, i := range order {
+			shuffled = append(shuffled, sprints[i])
+		}
+		sort.Slice(shuffled, func(i, j int) bool { return sprintBefore(shuffled[i], shuffled[j]) })
+		var names []string
+		for _, sprint := range shuffled {
+			names = append(names, sprint.Name)
+		}
+		if got := strings.Join(names, " "); got != want {
+			t.Errorf("sorted from order %v = %s, want %s", order, got, want)
+		}
+		if shuffled[3].ID != "4" || shuffled[4].ID != "6" {
+			t.Errorf("sprints sharing a name ordered %s, %s, want by ID 4, 6", shuffled[3].ID, shuffled[4].ID)
+		}
+	}
+}
+
+func TestBuildSprintPairsOrder(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	issue := func(key string, points float64, sprints ...SprintDetail) MultisprintIssue {
+		return MultisprintIssue{
+			Issue:      Issue{Key: key, Fields: IssueFields{StoryPoints: points}},
+			SprintInfo: SprintInfo{Sprints: sprints, SprintCount: len(sprints)},
+		}
+	}
+	s41, s42, s43 := sprintAt("41", "Sprint 41", "2026-09-01"), sprintAt("42", "Sprint 42", "2026-09-15"), sprintAt("43", "Sprint 43", "2026-09-29")
+	legacyA, legacyB := sprintAt("", "Legacy A", ""), sprintAt("", "Legacy B", "")
+
+	pairs := rs.buildSprintPairs([]MultisprintIssue{
+		issue("EXPD-1", 3, s43, s41, s42), // Array order is not chronological
+		issue("EXPD-2", 2, s42, s43),
+		issue("EXPD-3", 1, legacyB, s41, legacyA),
+	})
+
+	var got []string
+	for _, pair := range pairs {
+		got = append(got, fmt.Sprintf("%s>%s %d %g", pair.FromSprint.Name, pair.ToSprint.Name, pair.IssueCount, pair.StoryPoints))
+	}
+	want := []string{
+		"Sprint 41>Sprint 42 1 3",
+		"Sprint 41>Legacy A 1 1",
+		"Sprint 42>Sprint 43 2 5",
+		"Legacy A>Legacy B 1 1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sprint pairs:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// sprintMap returns a sprint as the sprint field's JSON decodes; empty dates are left out.
+func sprintMap(id float64, name, state, start, end, complete, goal string) map[string]interface{} {
+	sprint := map[string]interface{}{"id": id, "name": name, "state": state, "boardId": float64(7), "goal": goal}
+	for key, value := range map[string]string{"startDate": start, "endDate": end, "completeDate": complete} {
+		if value != "" {
+			sprint[key] = value
+		}
+	}
+	return sprint
+}
+
+func TestParseSprintFieldChronological(t *testing.T) {
+	s40 := sprintMap(40, "Sprint 40", "closed", "2026-08-18T09:00:00.000Z", "2026-08-31T17:00:00.000Z", "2026-08-31T17:00:00.000Z", "Ship search")
+	s41 := sprintMap(41, "Sprint 41", "closed", "2026-09-01T09:00:00.000Z", "2026-09-14T17:00:00.000Z", "2026-09-14T17:00:00.000Z", "Stabilise imports")
+	s42 := sprintMap(42, "Sprint 42", "closed", "2026-09-15T09:00:00.000Z", "2026-09-28T17:00:00.000Z", "2026-09-28T17:00:00.000Z", "Reporting")
+	tests := []struct {
+		name        string
+		sprintField interface{}
+		want        string // First Sprint | Last Sprint | All Sprints | First Sprint Start | First Sprint End | Last Sprint Start | Last Sprint End
+	}{
+		{
+			name:        "in order",
+			sprintField: []interface{}{s40, s41, s42},
+			want:        "Sprint 40 | Sprint 42 | Sprint 40, Sprint 41, Sprint 42 | 2026-08-18 | 2026-08-31 | 2026-09-15 | 2026-09-28",
+		},
+		{
+			name:        "out of order",
+			sprintField: []interface{}{s42, s40, s41},
+			want:        "Sprint 40 | Sprint 42 | Sprint 40, Sprint 41, Sprint 42 | 2026-08-18 | 2026-08-31 | 2026-09-15 | 2026-09-28",
+		},
+		{
+			name: "active sprint without end date",
+			sprintField: []interface{}{
+				sprintMap(43, "Sprint 43", "active", "2026-09-29T09:00:00.000Z", "", "", "Polish"),
+				s42,
+			},
+			want: "Sprint 42 | Sprint 43 | Sprint 42, Sprint 43 | 2026-09-15 | 2026-09-28 | 2026-09-29 | ",
+		},
+		{
+			name: "completed after the planned end date",
+			sprintField: []interface{}{
+				sprintMap(41, "Sprint 41", "closed", "2026-09-01T09:00:00.000Z", "2026-09-14T17:00:00.000Z", "2026-09-17T12:00:00.000Z", ""),
+				sprintMap(42, "Sprint 42", "closed", "2026-09-15T09:00:00.000Z", "2026-09-28T17:00:00.000Z", "2026-09-25T12:00:00.000Z", ""),
+			},
+			want: "Sprint 41 | Sprint 42 | Sprint 41, Sprint 42 | 2026-09-01 | 2026-09-14 | 2026-09-15 | 2026-09-28",
+		},
+		{
+			name: "legacy strings with and without dates",
+			sprintField: []interface{}{
+				"com.atlassian.greenhopper.service.sprint.Sprint@1[id=12,rapidViewId=7,state=CLOSED,name=Old Sprint,startDate=<null>,endDate=<null>,completeDate=<null>,sequence=12,goal=]",
+				"com.atlassian.greenhopper.service.sprint.Sprint@2[id=41,rapidViewId=7,state=CLOSED,name=Sprint 41,startDate=2026-09-01T09:00:00.000Z,endDate=2026-09-14T17:00:00.000Z,completeDate=2026-09-14T17:00:00.000Z,sequence=41,goal=]",
+				s40,
+			},
+			want: "Sprint 40 | Old Sprint | Sprint 40, Sprint 41, Old Sprint | 2026-08-18 | 2026-08-31 |  | ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := newTestRunState(t, &logRecorder{})
+			rs.reportLocation = time.UTC
+			info := rs.parseSprintField(test.sprintField)
+
+			got := strings.Join([]string{info.FirstSprint, info.LastSprint, info.AllSprints,
+				rs.formatSprintDate(info.FirstStart), rs.formatSprintDate(info.FirstEnd),
+				rs.formatSprintDate(info.LastStart), rs.formatSprintDate(info.LastEnd)}, " | ")
+			if got != test.want {
+				t.Errorf("sprint columns = %q\n                    want %q", got, test.want)
+			}
+			if problems := checkSprintInfo(info); len(problems) > 0 {
+				t.Errorf("checkSprintInfo: %v", problems)
+			}
+		})
+	}
+}
+
+func TestParseSprintFieldDuplicateNames(t *testing.T) {
+	// onBoard returns a sprint on the given board
+	onBoard := func(id float64, name string, boardID float64, start string) map[string]interface{} {
+		sprint := sprintMap(id, name, "closed", start, "", "", "")
+		sprint["boardId"] = boardID
+		return sprint
+	}
+	legacy := func(id, boardID int, name string) string {
+		return fmt.Sprintf("com.atlassian.greenhopper.service.sprint.Sprint@1[id=%d,rapidViewId=%d,state=CLOSED,name=%s,"+
+			"startDate=<null>,endDate=<null>,completeDate=<null>,sequence=%d,goal=]", id, boardID, name, id)
+	}
+	tests := []struct {
+		name        string
+		sprintField interface{}
+		wantCount   int
+		want        string // All Sprints
+	}{
+		{
+			name: "same name on two boards",
+			sprintField: []interface{}{
+				onBoard(101, "Sprint 12", 34, "2026-09-01T09:00:00.000Z"),
+				onBoard(202, "Sprint 12", 35, "2026-09-15T09:00:00.000Z"),
+			},
+			wantCount: 2,
+			want:      "Sprint 12 (board 34), Sprint 12 (board 35)",
+		},
+		{
+			name: "same name on one board",
+			sprintField: []interface{}{
+				onBoard(101, "Sprint 12", 34, "2026-09-01T09:00:00.000Z"),
+				onBoard(102, "Sprint 12", 34, "2026-09-15T09:00:00.000Z"),
+			},
+			wantCount: 2,
+			want:      "Sprint 12 (id 101), Sprint 12 (id 102)",
+		},
+		{
+			name: "same sprint listed twice",
+			sprintField: []interface{}{
+				onBoard(101, "Sprint 12", 34, "2026-09-01T09:00:00.000Z"),
+				onBoard(101, "Sprint 12", 34, "2026-09-01T09:00:00.000Z"),
+			},
+			wantCount: 1,
+			want:      "Sprint 12",
+		},
+		{
+			name: "shared name beside a unique one",
+			sprintField: []interface{}{
+				onBoard(202, "Sprint 12", 35, "2026-09-15T09:00:00.000Z"),
+				onBoard(150, "Sprint 13", 34, "2026-09-29T09:00:00.000Z"),
+				onBoard(101, "Sprint 12", 34, "2026-09-01T09:00:00.000Z"),
+			},
+			wantCount: 3,
+			want:      "Sprint 12 (board 34), Sprint 12 (board 35), Sprint 13",
+		},
+		{
+			name:        "legacy strings with IDs on two boards",
+			sprintField: []interface{}{legacy(101, 34, "Sprint 12"), legacy(202, 35, "Sprint 12")},
+			wantCount:   2,
+			want:        "Sprint 12 (board 34), Sprint 12 (board 35)",
+		},
+		{
+			name: "legacy strings without IDs fall back to the name",
+			sprintField: []interface{}{
+				"com.atlassian.greenhopper.service.sprint.Sprint@1[state=CLOSED,name=Sprint 12,startDate=<null>]",
+				"com.atlassian.greenhopper.service.sprint.Sprint@2[state=CLOSED,name=Sprint 12,startDate=<null>]",
+			},
+			wantCount: 1,
+			want:      "Sprint 12",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := newTestRunState(t, &logRecorder{})
+			info := rs.parseSprintField(test.sprintField)
+			if info.SprintCount != test.wantCount || info.AllSprints != test.want {
+				t.Errorf("%d sprints: %q; want %d: %q", info.SprintCount, info.AllSprints, test.wantCount, test.want)
+			}
+			if problems := checkSprintInfo(info); len(problems) > 0 {
+				t.Errorf("checkSprintInfo: %v", problems)
+			}
+		})
+	}
+}
+
+func TestParseSprintFieldFirstGoal(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	info := rs.parseSprintField([]interface{}{
+		sprintMap(42, "Sprint 42", "closed", "2026-09-15T09:00:00.000Z", "2026-09-28T17:00:00.000Z", "", "Reporting"),
+		sprintMap(40, "Sprint 40", "closed", "2026-08-18T09:00:00.000Z", "2026-08-31T17:00:00.000Z", "", "Ship search\tand\r\nexports"),
+		sprintMap(41, "Sprint 41", "closed", "2026-09-01T09:00:00.000Z", "2026-09-14T17:00:00.000Z", "", ""),
+	})
+	if info.FirstSprint != "Sprint 40" || info.FirstGoal != "Ship search\tand\r\nexports" {
+		t.Errorf("first sprint %q with goal %q, want Sprint 40 with its goal", info.FirstSprint, info.FirstGoal)
+	}
+	if got := sanitizeCellValue(info.FirstGoal); got != "Ship search and exports" {
+		t.Errorf("First Sprint Goal cell = %q, want %q", got, "Ship search and exports")
+	}
+
+	info = rs.parseSprintField([]interface{}{
+		sprintMap(42, "Sprint 42", "closed", "2026-09-15T09:00:00.000Z", "", "", "Reporting"),
+		sprintMap(41, "Sprint 41", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+	})
+	if info.FirstGoal != "" {
+		t.Errorf("goal of a first sprint without one = %q, want blank rather than a later sprint's goal", info.FirstGoal)
+	}
+}
+
+// loadFixtureIssues returns the issues on the fake Jira's search pages: Cloud sprint objects, legacy greenhopper
+// strings, an active sprint, and issues without points or priority.
+func loadFixtureIssues(tb testing.TB) []Issue {
+	tb.Helper()
+	pages, err := filepath.Glob(filepath.Join("testdata", "jira", "search-*.json"))
+	if err != nil || len(pages) == 0 {
+		tb.Fatalf("no search fixtures: %v", err)
+	}
+	var issues []Issue
+	for _, page := range pages {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		var response SearchResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			tb.Fatalf("%s: %v", page, err)
+		}
+		issues = append(issues, response.Issues...)
+	}
+	return issues
+}
+
+func BenchmarkParseSprintField(b *testing.B) {
+	rs := newTestRunState(b, &logRecorder{})
+	issues := loadFixtureIssues(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, issue := range issues {
+			rs.parseSprintField(issue.Fields.SprintField)
+		}
+	}
+}
+
+func BenchmarkExtractFieldValues(b *testing.B) {
+	rs := newTestRunState(b, &logRecorder{})
+	issues := loadFixtureIssues(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, issue := range issues {
+			rs.extractFieldValues(issue)
+		}
+	}
+}
+
+func TestParseSQLiteIndex(t *testing.T) {
+	tableSQL := `CREATE TABLE notes (
+  id INTEGER PRIMARY KEY, -- the rowid
+  "issue key" TEXT NOT NULL COLLATE NOCASE,
+  points REAL DEFAULT 0,
+  note VARCHAR(200),
+  CONSTRAINT one_note UNIQUE (note)
+)`
+	tests := []struct {
+		sql     string
+		want    string // Columns as name/position/collation/order, "" when the index is refused
+		unique  bool
+		wantErr string
+	}{
+		{sql: `CREATE INDEX ix ON notes(note)`, want: "note/3/BINARY/asc"},
+		{sql: `CREATE UNIQUE INDEX IF NOT EXISTS "ix" ON notes ("issue key" DESC, id)`, want: "issue key/1/NOCASE/desc id/-1/BINARY/asc", unique: true},
+		{sql: `create index ix on NOTES(Note collate rtrim asc, points)`, want: "Note/3/RTRIM/asc points/2/BINARY/asc"},
+		{sql: `CREATE INDEX ix ON notes(lower(note))`, wantErr: "expression"},
+		{sql: `CREATE INDEX ix ON notes(note) WHERE points > 0`, wantErr: "partial index"},
+		{sql: `CREATE INDEX ix ON notes(note COLLATE german)`, wantErr: "collation GERMAN"},
+		{sql: `CREATE INDEX ix ON notes(missing)`, wantErr: "unknown column missing"},
+		{sql: "", wantErr: "UNIQUE or PRIMARY KEY constraint"},
+	}
+	for _, test := range tests {
+		t.Run(test.sql, func(t *testing.T) {
+			index, err := parseSQLiteIndex(test.sql, tableSQL)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var columns []string
+			for _, column := range index.Columns {
+				order := "asc"
+				if column.Descending {
+					order = "desc"
+				}
+				columns = append(columns, fmt.Sprintf("%s/%d/%s/%s", column.Name, column.Position, column.Collation, order))
+			}
+			if got := strings.Join(columns, " "); got != test.want || index.Unique != test.unique {
+				t.Errorf("columns = %q unique %v, want %q unique %v", got, index.Unique, test.want, test.unique)
+			}
+		})
+	}
+}
+
+// TestWriteSQLiteIndexLevels writes an index too big for one page, with keys long enough to overflow, and reads
+// it back in order. When the sqlite3 shell is installed it also checks the database with PRAGMA integrity_check.
+func TestWriteSQLiteIndexLevels(t *testing.T) {
+	tableSQL := "CREATE TABLE notes (id INTEGER PRIMARY KEY, note TEXT, points REAL)"
+	index, err := parseSQLiteIndex("CREATE INDEX ix ON notes(note COLLATE NOCASE, points DESC)", tableSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []sqliteRow
+	for id := int64(1); id <= 3000; id++ {
+		note := any(strings.Repeat(fmt.Sprintf("Note %d ", id%97), int(id%11)*40))
+		if id%13 == 0 {
+			note = nil
+		}
+		var points any = float64(id%5) / 2
+		if id%2 == 0 {
+			points = id % 7
+		}
+		rows = append(rows, sqliteRow{RowID: id, Payload: encodeSQLiteRecord([]any{nil, note, points})})
+	}
+	database := filepath.Join(t.TempDir(), "notes.db")
+	objects := []sqliteObject{
+		{Type: "table", Name: "notes", TableName: "notes", RootPage: 1, SQL: tableSQL, Rows: rows},
+		{Type: "index", Name: "ix", TableName: "notes", RootPage: 1, SQL: "CREATE INDEX ix ON notes(note COLLATE NOCASE, points DESC)", Index: index},
+	}
+	if err := rewriteSQLiteDatabase(database, objects, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, _, err = readSQLiteDatabase(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := readSQLiteIndexKeys(t, sqliteReader{data: data, pageSize: sqlitePageSize, usableSize: sqlitePageSize}, int(objects[1].RootPage))
+	if len(keys) != len(rows) {
+		t.Fatalf("index has %d keys, want %d", len(keys), len(rows))
+	}
+	for i := 1; i < len(keys); i++ {
+		order := compareSQLiteValues(keys[i-1][0], keys[i][0], "NOCASE")
+		if order == 0 {
+			order = -compareSQLiteValues(keys[i-1][1], keys[i][1], "BINARY")
+		}
+		if order == 0 {
+			order = compareSQLiteValues(keys[i-1][2], keys[i][2], "BINARY")
+		}
+		if order >= 0 {
+			t.Fatalf("index keys %d and %d are out of order", i-1, i)
+		}
+	}
+
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Log("sqlite3 not installed, integrity_check skipped")
+		return
+	}
+	output, err := exec.Command("sqlite3", database, "PRAGMA integrity_check").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(output)) != "ok" {
+		t.Errorf("integrity_check: %v\n%s", err, output)
+	}
+}
+
+func TestBuildSQLiteIndexUnique(t *testing.T) {
+	index, err := parseSQLiteIndex("CREATE UNIQUE INDEX ux ON issues(issue_key)", "CREATE TABLE issues (id INTEGER PRIMARY KEY, issue_key TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := func(id int64, key any) sqliteRow {
+		return sqliteRow{RowID: id, Payload: encodeSQLiteRecord([]any{nil, key})}
+	}
+	if _, err := buildSQLiteIndex("ux", index, []sqliteRow{row(1, "EXPD-1"), row(2, nil), row(3, nil)}); err != nil {
+		t.Errorf("NULL keys are never equal, yet: %v", err)
+	}
+	if _, err := buildSQLiteIndex("ux", index, []sqliteRow{row(1, "EXPD-1"), row(2, "EXPD-2"), row(3, "EXPD-1")}); err == nil ||
+		!strings.Contains(err.Error(), "rows 1 and 3") {
+		t.Errorf("duplicate key error = %v, want rows 1 and 3 named", err)
+	}
+}
+
+func TestSpilloverScore(t *testing.T) {
+	defaults := DefaultScoreWeights
+	tests := []struct {
+		name        string
+		sprintCount int
+		storyPoints float64
+		ageDays     int
+		weights     ScoreWeights
+		want        float64
+	}{
+		{name: "documented example", sprintCount: 3, storyPoints: 5, ageDays: 40, weights: defaults, want: 15},
+		{name: "first sprint scores nothing", sprintCount: 1, weights: defaults, want: 0},
+		{name: "no sprints is not negative", sprintCount: 0, weights: defaults, want: 0},
+		{name: "each extra sprint", sprintCount: 4, weights: defaults, want: 9},
+		{name: "non-numeric points contribute zero", sprintCount: 3, storyPoints: 0, ageDays: 10, weights: defaults, want: 7},
+		{name: "points weight only", sprintCount: 4, storyPoints: 3.5, ageDays: 30, weights: ScoreWeights{Points: 2}, want: 7},
+		{name: "age weight only", sprintCount: 2, storyPoints: 8, ageDays: 9, weights: ScoreWeights{Age: 0.5}, want: 4.5},
+		{name: "sprints weight only", sprintCount: 3, storyPoints: 8, ageDays: 9, weights: ScoreWeights{Sprints: 1.5}, want: 3},
+		{name: "rounds half up", sprintCount: 1, storyPoints: 2.25, weights: defaults, want: 2.3},
+		{name: "rounds down", sprintCount: 1, storyPoints: 2.24, weights: defaults, want: 2.2},
+		{name: "hides float error", sprintCount: 2, storyPoints: 0.5, ageDays: 3, weights: defaults, want: 3.8},
+		{name: "age fraction", sprintCount: 1, ageDays: 7, weights: defaults, want: 0.7},
+		{name: "all weights zero", sprintCount: 5, storyPoints: 13, ageDays: 100, weights: ScoreWeights{}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spilloverScore(tt.sprintCount, tt.storyPoints, tt.ageDays, tt.weights); got != tt.want {
+				t.Errorf("spilloverScore(%d, %v, %d, %+v) = %v, want %v",
+					tt.sprintCount, tt.storyPoints, tt.ageDays, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueSpilloverScore(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	rs.reportLocation = time.UTC
+	// 40 calendar days before testNow
+	created := "2026-09-05T10:00:00.000+0000"
+
+	tests := []struct {
+		name        string
+		storyPoints interface{}
+		created     *string
+		want        float64
+		wantNumeric bool
+	}{
+		{name: "number", storyPoints: 5.0, created: &created, want: 15, wantNumeric: true},
+		{name: "numeric string", storyPoints: " 5 ", created: &created, want: 15, wantNumeric: true},
+		{name: "text", storyPoints: "large", created: &created, want: 10},
+		{name: "missing points", storyPoints: nil, created: &created, want: 10},
+		{name: "missing created date", storyPoints: 5.0, want: 11, wantNumeric: true},
+		{name: "unparseable created date", storyPoints: 5.0, created: ptr("last week"), want: 11, wantNumeric: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := MultisprintIssue{SprintInfo: SprintInfo{SprintCount: 3}}
+			issue.Issue.Fields.StoryPoints = tt.storyPoints
+			issue.Issue.Fields.Created = tt.created
+			got, numeric := rs.issueSpilloverScore(issue, testNow)
+			if got != tt.want || numeric != tt.wantNumeric {
+				t.Errorf("issueSpilloverScore = %v, %t; want %v, %t", got, numeric, tt.want, tt.wantNumeric)
+			}
+		})
+	}
+}
+
+func TestSpilloverScoreFormula(t *testing.T) {
+	for _, tt := range []struct {
+		weights ScoreWeights
+		want    string
+	}{
+		{DefaultScoreWeights, "3 x (sprints - 1) + 1 x story points + 0.1 x age in days"},
+		{ScoreWeights{Sprints: 2.5, Age: 1}, "2.5 x (sprints - 1) + 0 x story points + 1 x age in days"},
+	} {
+		if got := SpilloverScoreFormula(tt.weights); got != tt.want {
+			t.Errorf("SpilloverScoreFormula(%+v) = %q, want %q", tt.weights, got, tt.want)
+		}
+	}
+}
+
+// pairTestIssue decodes an issue whose customfield_10186 (the Pair field) has the raw JSON value pairJSON, or
+// that does not have the field at all when pairJSON is empty.
+func pairTestIssue(t *testing.T, key, pairJSON string) Issue {
+	t.Helper()
+	fields := `{"summary": "Pair test"}`
+	if pairJSON != "" {
+		fields = `{"summary": "Pair test", "customfield_10186": ` + pairJSON + `}`
+	}
+	var issue Issue
+	if err := json.Unmarshal([]byte(`{"key": "`+key+`", "fields": `+fields+`}`), &issue); err != nil {
+		t.Fatalf("decoding %s: %v", key, err)
+	}
+	return issue
+}
+
+func TestPairFieldValues(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	rs.pairFieldName = "customfield_10186"
+	rs.pairFieldProvided = true
+
+	tests := []struct {
+		name        string
+		pairJSON    string // "" for a missing field
+		wantPresent bool   // additionalField finds a value
+		want        string
+	}{
+		{name: "missing", pairJSON: ""},
+		{name: "null", pairJSON: "null"},
+		{name: "null with spaces", pairJSON: " null "},
+		{name: "empty array", pairJSON: "[]", wantPresent: true},
+		{name: "empty string", pairJSON: `""`, wantPresent: true},
+		{name: "blank string", pairJSON: `"   "`, wantPresent: true},
+		{name: "empty object", pairJSON: "{}", wantPresent: true},
+		{name: "array of nulls", pairJSON: "[null]", wantPresent: true},
+		{name: "users", pairJSON: `[{"displayName": "Alice"}, {"displayName": "Bob"}]`, wantPresent: true, want: "Alice, Bob"},
+		{name: "user", pairJSON: `{"displayName": "Alice"}`, wantPresent: true, want: "Alice"},
+		{name: "names", pairJSON: `["Alice", "Bob"]`, wantPresent: true, want: "Alice, Bob"},
+		{name: "name", pairJSON: `" Alice "`, wantPresent: true, want: "Alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := pairTestIssue(t, "EXPD-1", tt.pairJSON)
+			if _, present := issue.Fields.additionalField(rs.pairFieldName); present != tt.wantPresent {
+				t.Errorf("additionalField present = %t, want %t", present, tt.wantPresent)
+			}
+			if got := rs.extractFieldValues(issue)["Pair"]; got != tt.want {
+				t.Errorf("Pair = %q, want %q", got, tt.want)
+			}
+			wantFound := 0
+			if tt.want != "" {
+				wantFound = 1
+			}
+			if got := rs.countPairFieldFound([]MultisprintIssue{{Issue: issue}}); got != wantFound {
+				t.Errorf("countPairFieldFound = %d, want %d", got, wantFound)
+			}
+		})
+	}
+}
+
+func TestCountPairFieldFound(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	issues := func(pairJSONs ...string) []MultisprintIssue {
+		var multisprintIssues []MultisprintIssue
+		for i, pairJSON := range pairJSONs {
+			multisprintIssues = append(multisprintIssues, MultisprintIssue{Issue: pairTestIssue(t, fmt.Sprintf("EXPD-%d", i+1), pairJSON)})
+		}
+		return multisprintIssues
+	}
+	empty := issues("", "null", "[]", `""`)
+
+	// No -pair field: nothing is counted, so no warning
+	if got := rs.countPairFieldFound(empty); got != 0 {
+		t.Errorf("countPairFieldFound without -pair = %d, want 0", got)
+	}
+
+	rs.pairFieldName = "customfield_10186"
+	rs.pairFieldProvided = true
+	// Missing, null, and empty values on every issue: the "not found on any issues" warning must fire
+	if got := rs.countPairFieldFound(empty); got != 0 {
+		t.Errorf("countPairFieldFound with only empty values = %d, want 0", got)
+	}
+	// One populated value among the empty ones is enough to silence it
+	if got := rs.countPairFieldFound(issues("", "null", `[{"displayName": "Alice"}]`, "[]", `"Bob"`, `""`)); got != 2 {
+		t.Errorf("countPairFieldFound = %d, want 2", got)
+	}
+}
+
+func TestFormatDateTimezone(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*3600)
+	newYork := time.FixedZone("EDT", -4*3600)
+	tests := []struct {
+		date     string
+		location *time.Location
+		want     string
+	}{
+		{"2026-10-14T23:30:00.000+0000", time.UTC, "2026-10-14"},
+		{"2026-10-14T23:30:00.000+0000", sydney, "2026-10-15"},
+		{"2026-10-14T23:30:00.000+0000", newYork, "2026-10-14"},
+		{"2026-10-15T00:30:00.000+1100", time.UTC, "2026-10-14"},
+		{"2026-10-15T00:30:00.000+1100", sydney, "2026-10-15"},
+		{"2026-10-15T02:00:00.000+0000", newYork, "2026-10-14"},
+		{"2026-10-15T04:00:00.000+0000", newYork, "2026-10-15"},
+		{"2026-10-14T13:00:00.000+0000", sydney, "2026-10-15"}, // exactly midnight in Sydney
+		{"2026-10-14T12:59:59.999+0000", sydney, "2026-10-14"},
+	}
+	for _, tt := range tests {
+		rs := newTestRunState(t, &logRecorder{})
+		rs.reportLocation = tt.location
+		date := tt.date
+		if got := rs.formatDate("EXPD-1", &date); got != tt.want {
+			t.Errorf("formatDate(%s) in %s = %s, want %s", tt.date, tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarDaysBetweenMidnight(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*3600)
+	losAngeles := time.FixedZone("PDT", -7*3600)
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		from, to string
+		location *time.Location
+		want     int
+	}{
+		// Resolved at 23:30 and run at 00:30 the next morning, both UTC
+		{"2026-10-14T23:30:00Z", "2026-10-15T00:30:00Z", time.UTC, 1},
+		// The same instants are 10:30 and 11:30 on one day in Sydney, and 16:30 and 17:30 on one day in Los Angeles
+		{"2026-10-14T23:30:00Z", "2026-10-15T00:30:00Z", sydney, 0},
+		{"2026-10-14T23:30:00Z", "2026-10-15T00:30:00Z", losAngeles, 0},
+		// Twelve hours apart on one UTC day cross Sydney's midnight
+		{"2026-10-15T01:00:00Z", "2026-10-15T13:00:00Z", time.UTC, 0},
+		{"2026-10-15T01:00:00Z", "2026-10-15T13:00:00Z", sydney, 1},
+		// A minute either side of Los Angeles midnight
+		{"2026-10-15T06:59:00Z", "2026-10-15T07:01:00Z", losAngeles, 1},
+		{"2026-10-15T07:01:00Z", "2026-10-15T06:59:00Z", losAngeles, -1},
+		{"2026-10-01T12:00:00Z", "2026-10-15T12:00:00Z", sydney, 14},
+	}
+	for _, tt := range tests {
+		if got := CalendarDaysBetween(at(tt.from), at(tt.to), tt.location); got != tt.want {
+			t.Errorf("CalendarDaysBetween(%s, %s) in %s = %d, want %d", tt.from, tt.to, tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestReadExistingIssueKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string // Sorted keys, space separated
+	}{
+		{name: "current layout",
+			content: "Issue Type\tIssue Key\tSummary\nStory\tEXPD-1\tImport\nBug\tEXPD-3\tExport\n",
+			want:    "EXPD-1 EXPD-3"},
+		{name: "older layout with the key first",
+			content: "Issue Key\tSummary\tNumber of Sprints\nEXPD-1\tImport\t2\nEXPD-4\tExport\t3\n",
+			want:    "EXPD-1 EXPD-4"},
+		{name: "older layout with the key later",
+			content: "Issue Type\tSummary\tStatus\tIssue Key\nStory\tImport\tDone\tEXPD-2\n",
+			want:    "EXPD-2"},
+		{name: "no header uses the original layout",
+			content: "Story\tEXPD-7\tImport\nBug\tEXPD-8\tExport\n",
+			want:    "EXPD-7 EXPD-8"},
+		{name: "byte order mark and CRLF",
+			content: utf8BOM + "Issue Type\tIssue Key\r\nStory\tEXPD-1\r\nBug\tEXPD-2\r\n",
+			want:    "EXPD-1 EXPD-2"},
+		{name: "subtotal, blank and short rows",
+			content: "Issue Type\tSummary\tIssue Key\nStory\tImport\tEXPD-1\nSubtotal\t\tEXPD-9\n\nBug\tExport\n",
+			want:    "EXPD-1"},
+		{name: "no trailing newline",
+			content: "Issue Type\tIssue Key\nStory\tEXPD-5",
+			want:    "EXPD-5"},
+		{name: "header only", content: "Issue Type\tIssue Key\n", want: ""},
+		{name: "empty file", content: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "spillover.tsv")
+			if err := os.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			existingKeys, err := readExistingIssueKeys(filename)
+			if err != nil {
+				t.Fatalf("readExistingIssueKeys: %v", err)
+			}
+			keys := make([]string, 0, len(existingKeys))
+			for key := range existingKeys {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if got := strings.Join(keys, " "); got != tt.want {
+				t.Errorf("keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readExistingIssueKeys(filepath.Join(t.TempDir(), "missing.tsv")); err == nil {
+		t.Error("readExistingIssueKeys succeeded for a missing file")
+	}
+}
+
+func TestBuildJQLQueryOrderBy(t *testing.T) {
+	for _, tt := range []struct {
+		orderBy string
+		want    string
+	}{
+		{"", " ORDER BY key ASC"},
+		{"key", " ORDER BY key ASC"},
+		{"score", " ORDER BY key ASC"}, // calculated after fetching
+		{"updated", " ORDER BY updated ASC, key ASC"},
+		{"created", " ORDER BY created ASC, key ASC"},
+		{"priority", " ORDER BY priority ASC, key ASC"},
+	} {
+		jql := buildJQLQuery("EXPD", 10, "updated", false, nil, false, tt.orderBy, nil)
+		if !strings.HasSuffix(jql, tt.want) || strings.Count(jql, "ORDER BY") != 1 {
+			t.Errorf("buildJQLQuery with orderBy %q = %q, want it to end with %q", tt.orderBy, jql, tt.want)
+		}
+		rs := newTestRunState(t, &logRecorder{})
+		if _, queries := rs.buildKeysJQLQueries([]string{"EXPD-1"}, tt.orderBy); !strings.HasSuffix(queries[0], tt.want) {
+			t.Errorf("buildKeysJQLQueries with orderBy %q = %q, want it to end with %q", tt.orderBy, queries[0], tt.want)
+		}
+	}
+}
+
+func TestSortMultisprintIssues(t *testing.T) {
+	rs := newTestRunState(t, &logRecorder{})
+	rs.reportLocation = time.UTC
+	issue := func(key, group, updated, created string, sprintCount int) MultisprintIssue {
+		multisprintIssue := MultisprintIssue{Group: group, SprintInfo: SprintInfo{SprintCount: sprintCount}}
+		multisprintIssue.Issue.Key = key
+		if updated != "" {
+			multisprintIssue.Issue.Fields.Updated = &updated
+		}
+		if created != "" {
+			multisprintIssue.Issue.Fields.Created = &created
+		}
+		return multisprintIssue
+	}
+	// In the order a paged, multi-query fetch might return them
+	issues := []MultisprintIssue{
+		issue("EXPD-10", "", "2026-10-01T10:00:00.000+0000", "2026-09-01T10:00:00.000+0000", 2),
+		issue("EXPD-2", "", "", "2026-09-03T10:00:00.000+0000", 4),
+		issue("EXPD-9", "", "2026-10-01T10:00:00.000+0000", "2026-09-02T10:00:00.000+0000", 3),
+		issue("ABC-7", "", "2026-09-20T10:00:00.000+0000", "2026-09-01T10:00:00.000+0000", 2),
+		issue("EXPD-1", "", "2026-10-05T10:00:00.000+0000", "not a date", 2),
+	}
+
+	tests := []struct {
+		orderBy string
+		group   bool
+		want    string
+	}{
+		{orderBy: "key", want: "ABC-7 EXPD-1 EXPD-2 EXPD-9 EXPD-10"},
+		// Equal dates fall back to the key; missing or unparseable dates go last
+		{orderBy: "updated", want: "ABC-7 EXPD-9 EXPD-10 EXPD-1 EXPD-2"},
+		{orderBy: "created", want: "ABC-7 EXPD-10 EXPD-9 EXPD-2 EXPD-1"},
+		// Jira's order is kept: only Jira knows the priority scheme
+		{orderBy: "priority", want: "EXPD-10 EXPD-2 EXPD-9 ABC-7 EXPD-1"},
+		// Highest first: 13.2, 10.3, then 7.4 twice (so by key), then 3 (no age without a created date)
+		{orderBy: "score", want: "EXPD-2 EXPD-9 ABC-7 EXPD-10 EXPD-1"},
+		{orderBy: "key", group: true, want: "EXPD-9 EXPD-10 ABC-7 EXPD-1 EXPD-2"},
+		{orderBy: "updated", group: true, want: "EXPD-9 EXPD-10 ABC-7 EXPD-1 EXPD-2"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s group=%t", tt.orderBy, tt.group), func(t *testing.T) {
+			rs.orderByField = tt.orderBy
+			sorted := append([]MultisprintIssue(nil), issues...)
+			if tt.group {
+				groups := map[string]string{"EXPD-10": "Team A", "EXPD-9": "Team A", "EXPD-2": "Team B", "ABC-7": "Team B", "EXPD-1": "Team B"}
+				for i := range sorted {
+					sorted[i].Group = groups[sorted[i].Issue.Key]
+				}
+			}
+			rs.sortMultisprintIssues(sorted)
+			var keys []string
+			for _, multisprintIssue := range sorted {
+				keys = append(keys, multisprintIssue.Issue.Key)
+			}
+			if got := strings.Join(keys, " "); got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSprintReportURL(t *testing.T) {
+	sprint := SprintDetail{ID: "42", BoardID: "7", Name: "Sprint 42"}
+	tests := []struct {
+		name       string
+		baseURL    string
+		projectKey string
+		sprint     SprintDetail
+		cloud      bool
+		want       string
+	}{
+		{name: "server", baseURL: "https://jira.company.com", projectKey: "EXPD", sprint: sprint,
+			want: "https://jira.company.com/secure/RapidBoard.jspa?rapidView=7&view=reporting&chart=sprintRetrospective&sprint=42"},
+		{name: "server with context path", baseURL: "https://intranet.company.com/jira", sprint: sprint,
+			want: "https://intranet.company.com/jira/secure/RapidBoard.jspa?rapidView=7&view=reporting&chart=sprintRetrospective&sprint=42"},
+		{name: "cloud", baseURL: "https://example.atlassian.net", projectKey: "EXPD", sprint: sprint, cloud: true,
+			want: "https://example.atlassian.net/jira/software/c/projects/EXPD/boards/7/reports/sprint-retrospective?sprint=42"},
+		{name: "server without board", baseURL: "https://jira.company.com", projectKey: "EXPD", sprint: SprintDetail{ID: "42"}},
+		{name: "server without sprint ID", baseURL: "https://jira.company.com", projectKey: "EXPD", sprint: SprintDetail{BoardID: "7"}},
+		{name: "cloud without board", baseURL: "https://example.atlassian.net", projectKey: "EXPD", sprint: SprintDetail{ID: "42"}, cloud: true},
+		{name: "cloud without sprint ID", baseURL: "https://example.atlassian.net", projectKey: "EXPD", sprint: SprintDetail{BoardID: "7"}, cloud: true},
+		{name: "cloud without project", baseURL: "https://example.atlassian.net", sprint: sprint, cloud: true},
+		// Server links need no project key
+		{name: "server without project", baseURL: "https://jira.company.com", sprint: sprint,
+			want: "https://jira.company.com/secure/RapidBoard.jspa?rapidView=7&view=reporting&chart=sprintRetrospective&sprint=42"},
+		{name: "escaped", baseURL: "https://jira.company.com", projectKey: "EX PD", sprint: SprintDetail{ID: "4&2", BoardID: "7/8"}, cloud: true,
+			want: "https://jira.company.com/jira/software/c/projects/EX%20PD/boards/7%2F8/reports/sprint-retrospective?sprint=4%262"},
+	}
+	for _, tt := range tests {
+		if got := buildSprintReportURL(tt.baseURL, tt.projectKey, tt.sprint, tt.cloud); got != tt.want {
+			t.Errorf("%s: buildSprintReportURL = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsWithinGracePeriod(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*3600)
+	// lastStart returns two-sprint info whose latest sprint started at start
+	lastStart := func(start string) SprintInfo {
+		started, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SprintInfo{SprintCount: 2, LastStart: &started}
+	}
+	tests := []struct {
+		name       string
+		sprintInfo SprintInfo
+		graceDays  int
+		location   *time.Location
+		want       bool
+	}{
+		// The run is at 2026-10-15 12:00 UTC
+		{"started today", lastStart("2026-10-15T09:00:00Z"), 1, time.UTC, true},
+		{"started yesterday, one day", lastStart("2026-10-14T13:00:00Z"), 1, time.UTC, false},
+		{"started two days ago, three days", lastStart("2026-10-13T09:00:00Z"), 3, time.UTC, true},
+		{"started three days ago, three days", lastStart("2026-10-12T23:59:00Z"), 3, time.UTC, false},
+		{"started three days ago at midnight, three days", lastStart("2026-10-12T00:00:00Z"), 3, time.UTC, false},
+		{"started just after midnight two days ago, three days", lastStart("2026-10-13T00:00:00Z"), 3, time.UTC, true},
+		// 23:59 UTC yesterday is 10:59 today in Sydney, where the run is at 23:00
+		{"yesterday in UTC, today in Sydney", lastStart("2026-10-14T23:59:00Z"), 1, sydney, true},
+		{"yesterday in UTC, today in Sydney, UTC", lastStart("2026-10-14T23:59:00Z"), 1, time.UTC, false},
+		{"starts in the future", lastStart("2026-10-20T09:00:00Z"), 1, time.UTC, true},
+		{"no grace period", lastStart("2026-10-15T09:00:00Z"), 0, time.UTC, false},
+		{"negative grace period", lastStart("2026-10-15T09:00:00Z"), -1, time.UTC, false},
+		{"three sprints", SprintInfo{SprintCount: 3, LastStart: lastStart("2026-10-15T09:00:00Z").LastStart}, 5, time.UTC, false},
+		{"no start date", SprintInfo{SprintCount: 2}, 5, time.UTC, false},
+	}
+	for _, tt := range tests {
+		rs := newTestRunState(t, &logRecorder{})
+		rs.reportLocation = tt.location
+		if got := rs.isWithinGracePeriod(tt.sprintInfo, tt.graceDays, testNow); got != tt.want {
+			t.Errorf("%s: isWithinGracePeriod = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestIssueLinksUnmarshal decodes an issuelinks field as Jira returns it and reads the Blocks links from it.
+func TestIssueLinksUnmarshal(t *testing.T) {
+	var issue Issue
+	err := json.Unmarshal([]byte(`{"key": "EXPD-4", "fields": {"issuelinks": [
+		{"id": "1", "type": {"id": "10000", "name": "Blocks", "inward": "is blocked by", "outward": "blocks"},
+		 "inwardIssue": {"id": "10001", "key": "EXPD-1", "fields": {"summary": "Blocker"}}},
+		{"id": "2", "type": {"id": "10000", "name": "Blocks", "inward": "is blocked by", "outward": "blocks"},
+		 "outwardIssue": {"id": "10005", "key": "EXPD-5"}},
+		{"id": "3", "type": {"id": "10003", "name": "Relates", "inward": "relates to", "outward": "relates to"},
+		 "outwardIssue": {"id": "10002", "key": "EXPD-2"}},
+		{"id": "4", "type": {"id": "10004", "name": "Dependency", "inward": "depends on", "outward": "is depended on by"},
+		 "inwardIssue": {"id": "10003", "key": "EXPD-3"}}
+	]}}`), &issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := issue.Fields.IssueLinks
+	if len(links) != 4 {
+		t.Fatalf("decoded %d links, want 4", len(links))
+	}
+	if got := links[0].Type; got != (IssueLinkType{Name: "Blocks", Inward: "is blocked by", Outward: "blocks"}) {
+		t.Errorf("link type = %+v", got)
+	}
+	if links[0].InwardIssue == nil || links[0].InwardIssue.Key != "EXPD-1" || links[0].OutwardIssue != nil {
+		t.Errorf("first link = %+v, want only inward issue EXPD-1", links[0])
+	}
+	if links[1].OutwardIssue == nil || links[1].OutwardIssue.Key != "EXPD-5" || links[1].InwardIssue != nil {
+		t.Errorf("second link = %+v, want only outward issue EXPD-5", links[1])
+	}
+
+	// An issue without the field decodes to no links
+	var unlinked Issue
+	if err := json.Unmarshal([]byte(`{"key": "EXPD-2", "fields": {"summary": "No links"}}`), &unlinked); err != nil {
+		t.Fatal(err)
+	}
+	if unlinked.Fields.IssueLinks != nil {
+		t.Errorf("IssueLinks = %+v, want none", unlinked.Fields.IssueLinks)
+	}
+
+	tests := []struct {
+		linkTypes     []string
+		wantBlockedBy string
+		wantBlocks    string
+	}{
+		// Only Blocks by default
+		{nil, "EXPD-1", "EXPD-5"},
+		// -linktypes names are matched case-insensitively and replace the default
+		{[]string{"dependency"}, "EXPD-3", ""},
+		{[]string{"Blocks", "Relates"}, "EXPD-1", "EXPD-5, EXPD-2"},
+	}
+	for _, tt := range tests {
+		rs, err := newRunState(context.Background(), Config{LinkTypes: tt.linkTypes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockedBy, blocks := rs.issueLinkKeys(issue)
+		if strings.Join(blockedBy, ", ") != tt.wantBlockedBy || strings.Join(blocks, ", ") != tt.wantBlocks {
+			t.Errorf("-linktypes %v: blocked by %v, blocks %v; want %s and %s",
+				tt.linkTypes, blockedBy, blocks, tt.wantBlockedBy, tt.wantBlocks)
+		}
+	}
+}
+
+// TestParseLegacySprintAttributes reads greenhopper sprint strings as Jira Server and Data Center versions
+// return them, including sprint names and goals holding commas, brackets, and equals signs.
+func TestParseLegacySprintAttributes(t *testing.T) {
+	const prefix = "com.atlassian.greenhopper.service.sprint.Sprint@1f7f"
+	tests := []struct {
+		name   string
+		sprint string
+		want   map[string]string // Expected attributes; others are not checked
+	}{
+		{"server 7",
+			prefix + "[id=412,rapidViewId=33,state=CLOSED,name=Sprint 12,startDate=2026-09-01T09:00:00.000Z,endDate=2026-09-14T17:00:00.000Z,completeDate=2026-09-14T16:45:12.345Z,sequence=412,goal=Finish checkout]",
+			map[string]string{"id": "412", "rapidViewId": "33", "state": "CLOSED", "name": "Sprint 12",
+				"startDate": "2026-09-01T09:00:00.000Z", "endDate": "2026-09-14T17:00:00.000Z", "goal": "Finish checkout"}},
+		{"brackets in the name",
+			prefix + "[id=412,rapidViewId=33,state=CLOSED,name=Sprint 12 [Payments],startDate=2026-09-01T09:00:00.000Z,endDate=<null>]",
+			map[string]string{"id": "412", "name": "Sprint 12 [Payments]", "startDate": "2026-09-01T09:00:00.000Z", "endDate": ""}},
+		{"comma in the name",
+			prefix + "[id=413,rapidViewId=33,state=ACTIVE,name=Sprint 13, Payments,startDate=2026-09-15T09:00:00.000Z]",
+			map[string]string{"id": "413", "state": "ACTIVE", "name": "Sprint 13, Payments", "startDate": "2026-09-15T09:00:00.000Z"}},
+		{"brackets and comma in the name, last attribute",
+			prefix + "[id=414,rapidViewId=33,state=FUTURE,name=Sprint 14 [Payments, EU]]",
+			map[string]string{"id": "414", "state": "FUTURE", "name": "Sprint 14 [Payments, EU]"}},
+		{"equals signs in the name",
+			prefix + "[id=415,rapidViewId=33,state=CLOSED,name=Team=Payments (a=b),sequence=415]",
+			map[string]string{"id": "415", "name": "Team=Payments (a=b)", "sequence": "415"}},
+		{"punctuation in the goal",
+			prefix + "[id=416,rapidViewId=33,state=CLOSED,name=Sprint 16,goal=Ship [X], then Y=done,endDate=2026-10-12T17:00:00.000Z]",
+			map[string]string{"name": "Sprint 16", "goal": "Ship [X], then Y=done", "endDate": "2026-10-12T17:00:00.000Z"}},
+		{"jira 6 without rapidViewId or goal",
+			prefix + "[rapidViewId=<null>,state=CLOSED,name=Sprint 3,startDate=<null>,endDate=<null>,completeDate=<null>,sequence=<null>,id=3]",
+			map[string]string{"id": "3", "rapidViewId": "", "name": "Sprint 3", "startDate": "", "endDate": ""}},
+		// A comma followed by something shaped like an attribute is taken as the next attribute (documented limit)
+		{"attribute-like text after a comma",
+			prefix + "[id=417,name=Sprint 17,phase=2,state=CLOSED]",
+			map[string]string{"id": "417", "name": "Sprint 17", "phase": "2", "state": "CLOSED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLegacySprintAttributes(tt.sprint)
+			for key, want := range tt.want {
+				if value, ok := got[key]; !ok || value != want {
+					t.Errorf("%s = %q (present %t), want %q", key, value, ok, want)
+				}
+			}
+		})
+	}
+
+	// The attributes become a SprintDetail with a lower-case state and parsed dates
+	rs := newTestRunState(t, &logRecorder{})
+	detail := rs.sprintDetailFromLegacyString(parseLegacySprintAttributes(tests[1].sprint))
+	if detail.ID != "412" || detail.BoardID != "33" || detail.State != "closed" || detail.Name != "Sprint 12 [Payments]" {
+		t.Errorf("detail = %+v", detail)
+	}
+	if detail.StartDate == nil || !detail.StartDate.Equal(time.Date(2026, time.September, 1, 9, 0, 0, 0, time.UTC)) || detail.EndDate != nil {
+		t.Errorf("dates = %v, %v; want 2026-09-01 09:00 UTC and none", detail.StartDate, detail.EndDate)
+	}
+}
+
+// TestDetermineTimeInStatus works out the days per status from status transitions, packed into the Time In Status
+// cell and split into -statuscolumns columns.
+func TestDetermineTimeInStatus(t *testing.T) {
+	// move records a status transition at a Jira timestamp
+	move := func(at, from, to string) ChangelogHistory {
+		return ChangelogHistory{Created: at, Items: []ChangelogItem{{Field: "status", FieldID: "status", FromString: from, ToString: to}}}
+	}
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		created   string
+		resolved  string // Resolution date ("" when unresolved)
+		status    string // Current status
+		histories []ChangelogHistory
+		want      string
+	}{
+		{name: "never moved", created: "2026-10-05T12:00:00.000+0000", status: "To Do", want: "To Do=10.0"},
+		{name: "every status", created: "2026-10-01T00:00:00.000+0000", resolved: "2026-10-06T00:00:00.000+0000", status: "Done",
+			histories: []ChangelogHistory{
+				move("2026-10-02T00:00:00.000+0000", "To Do", "In Progress"),
+				move("2026-10-05T12:00:00.000+0000", "In Progress", "In Review"),
+				move("2026-10-06T00:00:00.000+0000", "In Review", "Done"),
+			},
+			want: "To Do=1.0;In Progress=3.5;In Review=0.5;Done=0.0"},
+		// Skipped statuses are simply absent; the -statuscolumns columns show them as 0.0
+		{name: "skips statuses", created: "2026-10-01T00:00:00.000+0000", resolved: "2026-10-03T07:12:00.000+0000", status: "Done",
+			histories: []ChangelogHistory{move("2026-10-03T07:12:00.000+0000", "To Do", "Done")},
+			want:      "To Do=2.3;Done=0.0"},
+		// Reopening clears the resolution date, so the reopened issue counts until now, and In Progress is summed over
+		// both visits; the histories arrive out of order
+		{name: "resolved then reopened", created: "2026-10-01T00:00:00.000+0000", status: "In Progress",
+			histories: []ChangelogHistory{
+				move("2026-10-10T12:00:00.000+0000", "Done", "In Progress"),
+				move("2026-10-02T00:00:00.000+0000", "To Do", "In Progress"),
+				move("2026-10-04T00:00:00.000+0000", "In Progress", "Done"),
+			},
+			want: "To Do=1.0;In Progress=7.0;Done=6.5"},
+		// Time after the resolution date is not counted, even for a later transition such as Done to Closed
+		{name: "moved after resolution", created: "2026-10-01T00:00:00.000+0000", resolved: "2026-10-04T00:00:00.000+0000", status: "Closed",
+			histories: []ChangelogHistory{
+				move("2026-10-02T00:00:00.000+0000", "To Do", "In Progress"),
+				move("2026-10-04T00:00:00.000+0000", "In Progress", "Done"),
+				move("2026-10-08T00:00:00.000+0000", "Done", "Closed"),
+			},
+			want: "To Do=1.0;In Progress=2.0;Done=0.0;Closed=0.0"},
+		// Offsets other than UTC give the same durations: only instants are compared
+		{name: "mixed offsets", created: "2026-10-01T10:00:00.000+1000", resolved: "2026-10-02T00:00:00.000+0000", status: "Done",
+			histories: []ChangelogHistory{move("2026-10-01T12:00:00.000+0000", "To Do", "Done")},
+			want:      "To Do=0.5;Done=0.5"},
+		// Changes to other fields are ignored
+		{name: "other fields", created: "2026-10-13T12:00:00.000+0000", status: "To Do",
+			histories: []ChangelogHistory{{Created: "2026-10-14T00:00:00.000+0000",
+				Items: []ChangelogItem{{Field: "assignee", FromString: "Alice", ToString: "Bob"}}}},
+			want: "To Do=2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newTestRunState(t, &logRecorder{})
+			var issue Issue
+			issue.Fields.Created = &tt.created
+			if tt.resolved != "" {
+				issue.Fields.ResolutionDate = &tt.resolved
+			}
+			issue.Fields.Status.Name = tt.status
+			durations, ok := rs.determineTimeInStatus(issue, tt.histories, now)
+			if !ok {
+				t.Fatal("determineTimeInStatus could not read the created date")
+			}
+			got := formatTimeInStatus(durations)
+			if got != tt.want {
+				t.Errorf("time in status = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	rs := newTestRunState(t, &logRecorder{})
+	if _, ok := rs.determineTimeInStatus(Issue{}, nil, now); ok {
+		t.Error("an issue without a created date was given durations")
+	}
+
+	for _, tt := range []struct {
+		timeInStatus, status, want string
+	}{
+		{"To Do=1.0;In Progress=3.5", "In Progress", "3.5"},
+		{"To Do=1.0;In Progress=3.5", "in progress", "3.5"},
+		{"To Do=1.0;In Progress=3.5", "Blocked", "0.0"},
+		{"Ready=For=Test=2.0", "Ready=For=Test", "2.0"},
+		{"unknown", "Blocked", "unknown"},
+		{"", "Blocked", ""},
+	} {
+		if got := statusColumnValue(tt.timeInStatus, tt.status); got != tt.want {
+			t.Errorf("statusColumnValue(%q, %q) = %q, want %q", tt.timeInStatus, tt.status, got, tt.want)
+		}
+	}
+}
+
+// TestParseJiraTime parses the timestamps Jira Cloud, Server/Data Center 8.x and 9.x, and the Agile API send, and
+// counts the ones it cannot.
+func TestParseJiraTime(t *testing.T) {
+	tests := []struct {
+		source string
+		value  string
+		want   time.Time // Zero when the value must not parse
+	}{
+		{"Cloud issue field", "2025-08-01T04:22:07.000+0000", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Cloud changelog", "2025-08-01T14:22:07.123+1000", time.Date(2025, time.August, 1, 4, 22, 7, 123e6, time.UTC)},
+		{"Server 8.x issue field", "2025-08-01T14:22:07.000+1000", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Server 8.x negative offset", "2025-07-31T23:22:07.000-0500", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Server 9.x RFC3339 offset", "2025-08-01T14:22:07+10:00", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Server 9.x without milliseconds", "2025-08-01T14:22:07+1000", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Agile API sprint date", "2025-08-01T04:22:07.000Z", time.Date(2025, time.August, 1, 4, 22, 7, 0, time.UTC)},
+		{"Agile API nanoseconds", "2025-08-01T04:22:07.123456789Z", time.Date(2025, time.August, 1, 4, 22, 7, 123456789, time.UTC)},
+		{"due date", "2025-08-01", time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC)},
+		{"display format", "01/Aug/25 2:22 PM", time.Time{}},
+		{"no offset", "2025-08-01T14:22:07.000", time.Time{}},
+		{"garbage", "not a date", time.Time{}},
+	}
+	rs := newTestRunState(t, &logRecorder{})
+	for _, tt := range tests {
+		got, ok := rs.parseJiraTime(tt.value)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s: parseJiraTime(%q) = %v, %t; want %v", tt.source, tt.value, got, ok, tt.want)
+		}
+	}
+
+	// A blank value is not a failure; each distinct unparseable value is counted once
+	rs.parseJiraTime("")
+	rs.parseJiraTime("not a date")
+	recorder := &logRecorder{}
+	rs.runHooks.OnLog = recorder.log
+	rs.logUnparseableTimes()
+	if !recorder.Contains(`3 distinct Jira timestamps could not be parsed (e.g. "01/Aug/25 2:22 PM", "2025-08-01T14:22:07.000", "not a date")`) {
+		t.Error("the unparseable timestamps were not logged")
+	}
+}
+
+func TestDecideCapabilities(t *testing.T) {
+	tests := []struct {
+		server JiraServerInfo
+		want   jiraCapabilities
+	}{
+		{JiraServerInfo{DeploymentType: "Cloud", Detected: true}, jiraCapabilities{CloudLinks: true, UpdatedByJQL: true}},
+		{JiraServerInfo{DeploymentType: "DataCenter", Version: "9.4.2", Detected: true}, jiraCapabilities{UpdatedByJQL: true}},
+		{JiraServerInfo{DeploymentType: "Server", Version: "8.20.1", Detected: true}, jiraCapabilities{}},
+		{JiraServerInfo{DeploymentType: "datacenter", Detected: true}, jiraCapabilities{UpdatedByJQL: true}},
+		// Guessed from the URL: Cloud links still follow the guess, but updatedBy() is probed
+		{JiraServerInfo{DeploymentType: "Cloud"}, jiraCapabilities{CloudLinks: true}},
+		{JiraServerInfo{DeploymentType: "Server"}, jiraCapabilities{}},
+	}
+	for _, tt := range tests {
+		if got := decideCapabilities(tt.server); got != tt.want {
+			t.Errorf("decideCapabilities(%+v) = %+v, want %+v", tt.server, got, tt.want)
+		}
+	}
+}
+
+// TestDetermineUnestimatedSprints counts the sprints that started before an issue was first estimated, for an
+// issue in sprints starting 2026-09-01, 09-15, and 09-29 and a future sprint that has not started.
+func TestDetermineUnestimatedSprints(t *testing.T) {
+	// points records a story points change at a Jira timestamp
+	points := func(at, from, to string) ChangelogHistory {
+		return ChangelogHistory{Created: at, Items: []ChangelogItem{{Field: "Story Points", FieldID: defaultStoryPointsField, FromString: from, ToString: to}}}
+	}
+	sprintInfo := SprintInfo{SprintCount: 4}
+	for _, start := range []string{"2026-09-01", "2026-09-15", "2026-09-29"} {
+		startDate, _ := time.Parse("2006-01-02", start)
+		sprintInfo.Sprints = append(sprintInfo.Sprints, SprintDetail{Name: "Sprint " + start, StartDate: &startDate})
+	}
+	sprintInfo.Sprints = append(sprintInfo.Sprints, SprintDetail{Name: "Future sprint"})
+
+	tests := []struct {
+		name        string
+		storyPoints interface{}
+		histories   []ChangelogHistory
+		want        string // Unestimated Sprints, Estimated Late
+	}{
+		{name: "estimated before any sprint", storyPoints: 3.0,
+			histories: []ChangelogHistory{points("2026-08-20T10:00:00.000+0000", "", "3")}, want: "0 no"},
+		{name: "estimated mid-sprint", storyPoints: 3.0,
+			histories: []ChangelogHistory{points("2026-09-20T10:00:00.000+0000", "", "3")}, want: "2 yes"},
+		{name: "estimated as a sprint starts", storyPoints: 3.0,
+			histories: []ChangelogHistory{points("2026-09-15T00:00:00.000+0000", "", "3")}, want: "1 yes"},
+		// Never estimated counts every sprint, including one that has not started
+		{name: "never estimated", want: "4 yes"},
+		{name: "estimate added and removed", storyPoints: nil,
+			histories: []ChangelogHistory{
+				points("2026-09-05T10:00:00.000+0000", "5", ""),
+				points("2026-08-20T10:00:00.000+0000", "", "5"),
+			},
+			want: "0 no"},
+		// Points set when the issue was created: the first change is from a value, or there is no change at all
+		{name: "estimated at creation, changed later", storyPoints: 8.0,
+			histories: []ChangelogHistory{points("2026-09-20T10:00:00.000+0000", "3", "8")}, want: "0 no"},
+		{name: "estimated at creation, never changed", storyPoints: 3.0, want: "0 no"},
+		// Older Server versions name the field without its ID
+		{name: "field named only", storyPoints: 3.0,
+			histories: []ChangelogHistory{{Created: "2026-09-20T10:00:00.000+0000",
+				Items: []ChangelogItem{{Field: "story points", FromString: "", ToString: "3"}}}},
+			want: "2 yes"},
+		{name: "other fields ignored",
+			histories: []ChangelogHistory{{Created: "2026-08-20T10:00:00.000+0000",
+				Items: []ChangelogItem{{Field: "Rank", FromString: "", ToString: "Ranked higher"}}}},
+			want: "4 yes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newTestRunState(t, &logRecorder{})
+			var issue Issue
+			issue.Fields.StoryPoints = tt.storyPoints
+			count, late := rs.determineUnestimatedSprints(issue, sprintInfo, tt.histories)
+			if got := count + " " + late; got != tt.want {
+				t.Errorf("unestimated sprints, estimated late = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseSprintFieldNameVariants counts sprints listed under renamed and whitespace variants of one name, with
+// and without -strictnames.
+func TestParseSprintFieldNameVariants(t *testing.T) {
+	// unnamed returns a legacy sprint string without an ID, so the sprint can only be told apart by its name
+	unnamed := func(name string) string {
+		return "com.atlassian.greenhopper.service.sprint.Sprint@1[state=CLOSED,name=" + name + ",startDate=<null>]"
+	}
+	tests := []struct {
+		name        string
+		sprintField interface{}
+		want        string // Number of Sprints: All Sprints
+		wantStrict  string // The same with -strictnames
+	}{
+		{
+			name:        "trailing space and case",
+			sprintField: []interface{}{unnamed("Sprint 14 "), unnamed("sprint 14")},
+			want:        `1: "sprint 14"`,
+			wantStrict:  `2: "Sprint 14 , sprint 14"`,
+		},
+		{
+			name:        "runs of internal whitespace",
+			sprintField: []interface{}{unnamed("Sprint  14"), unnamed(" Sprint\t14"), unnamed("Sprint 15")},
+			want:        `2: "Sprint 14, Sprint 15"`,
+			wantStrict:  `3: " Sprint\t14, Sprint  14, Sprint 15"`,
+		},
+		// With IDs the sprint is recognised by its ID, and the later entry's name is shown
+		{
+			name: "renamed mid-sprint",
+			sprintField: []interface{}{
+				sprintMap(14, "Sprint 14", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+				sprintMap(15, "Sprint 15", "closed", "2026-09-15T09:00:00.000Z", "", "", ""),
+				sprintMap(14, "Sprint 14 - Payments", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+			},
+			want:       `2: "Sprint 14 - Payments, Sprint 15"`,
+			wantStrict: `2: "Sprint 14 - Payments, Sprint 15"`,
+		},
+		{
+			name: "renamed to a whitespace variant",
+			sprintField: []interface{}{
+				sprintMap(14, "Sprint 14", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+				sprintMap(14, "sprint  14 ", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+			},
+			want:       `1: "sprint 14"`,
+			wantStrict: `1: "sprint  14 "`,
+		},
+		// Distinct IDs stay distinct sprints, however alike their names
+		{
+			name: "different sprints with alike names",
+			sprintField: []interface{}{
+				sprintMap(14, "Sprint 14", "closed", "2026-09-01T09:00:00.000Z", "", "", ""),
+				sprintMap(114, "Sprint 14 ", "closed", "2026-09-15T09:00:00.000Z", "", "", ""),
+			},
+			want:       `2: "Sprint 14 (id 14), Sprint 14 (id 114)"`,
+			wantStrict: `2: "Sprint 14, Sprint 14 "`,
+		},
+	}
+	for _, test := range tests {
+		for _, strict := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s strict=%t", test.name, strict), func(t *testing.T) {
+				rs := newTestRunState(t, &logRecorder{})
+				rs.strictSprintNames = strict
+				info := rs.parseSprintField(test.sprintField)
+				want := test.want
+				if strict {
+					want = test.wantStrict
+				}
+				if got := fmt.Sprintf("%d: %q", info.SprintCount, info.AllSprints); got != want {
+					t.Errorf("sprints = %s, want %s", got, want)
+				}
+				if problems := checkSprintInfo(info); len(problems) > 0 {
+					t.Errorf("checkSprintInfo: %v", problems)
+				}
+			})
+		}
+	}
+}
+
+func TestFormatHours(t *testing.T) {
+	tests := []struct {
+		seconds *float64
+		want    string
+	}{
+		{nil, ""},
+		{ptr(0.0), "0.0"},
+		{ptr(0.4), "0.0"}, // Sub-second values from instances that store fractions
+		{ptr(179.0), "0.0"},
+		{ptr(180.0), "0.1"}, // Three minutes is the first tenth of an hour
+		{ptr(27000.0), "7.5"},
+		{ptr(28799.9), "8.0"},
+		{ptr(3.6e9), "1000000.0"},
+		// Values that cannot be a duration are blank rather than negative or a panic
+		{ptr(-1.0), ""},
+		{ptr(-0.4), ""},
+		{ptr(math.NaN()), ""},
+		{ptr(math.Inf(1)), ""},
+		{ptr(math.Inf(-1)), ""},
+	}
+	for _, tt := range tests {
+		name := "nil"
+		if tt.seconds != nil {
+			name = fmt.Sprint(*tt.seconds)
+		}
+		if got := formatHours(tt.seconds); got != tt.want {
+			t.Errorf("formatHours(%s) = %q, want %q", name, got, tt.want)
+		}
+		// A saved report's hours read back (-input) format the same
+		if got := formatHours(hoursToSeconds(tt.want)); got != tt.want {
+			t.Errorf("formatHours(hoursToSeconds(%q)) = %q", tt.want, got)
+		}
+	}
+
+	// The time tracking fields decode whether Jira sends whole seconds, fractions, null, or nothing
+	var issue Issue
+	if err := json.Unmarshal([]byte(`{"key": "EXPD-1", "fields": {"timeoriginalestimate": 28800, "timespent": 1.5, "aggregatetimespent": null}}`), &issue); err != nil {
+		t.Fatal(err)
+	}
+	fields := issue.Fields
+	if fields.TimeOriginalEstimate == nil || *fields.TimeOriginalEstimate != 28800 || fields.TimeSpent == nil || *fields.TimeSpent != 1.5 ||
+		fields.AggregateTimeSpent != nil {
+		t.Errorf("time fields = %v, %v, %v; want 28800, 1.5, nil", fields.TimeOriginalEstimate, fields.TimeSpent, fields.AggregateTimeSpent)
+	}
+}
+
+func TestMatchFieldName(t *testing.T) {
+	fields := []jiraField{
+		{ID: "summary", Name: "Summary"},
+		{ID: "customfield_10186", Name: "Pair"},
+		{ID: "customfield_10187", Name: "Pair Reviewer"},
+		{ID: "customfield_10300", Name: "Squad "},
+		{ID: "customfield_10400", Name: "Team"},
+		{ID: "customfield_10401", Name: "team"},
+		{ID: "customfield_10021", Name: "Flagged"},
+	}
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string // Text the error must contain ("" for no error)
+	}{
+		{value: "customfield_10186", want: "customfield_10186"},
+		{value: "summary", want: "summary"},
+		{value: "Pair", want: "customfield_10186"},
+		{value: "pair", want: "customfield_10186"},
+		{value: "CUSTOMFIELD_10021", want: "customfield_10021"},
+		{value: "squad", want: "customfield_10300"}, // Names are compared without surrounding whitespace
+		{value: "Team", wantErr: "-pair 'Team' matches 2 fields (Team (customfield_10400), team (customfield_10401)); give the field ID instead"},
+		// Close matches differ by up to two typos, or contain the value
+		{value: "Pear", wantErr: "(close matches: Pair (customfield_10186), Team (customfield_10400), team (customfield_10401))"},
+		{value: "Reviewer", wantErr: "close matches: Pair Reviewer (customfield_10187)"},
+		{value: "Velocity", wantErr: "-pair 'Velocity' is not the ID or name of any Jira field; find the field with -dumpissue KEY"},
+	}
+	for _, tt := range tests {
+		got, err := matchFieldName("-pair", tt.value, fields)
+		switch {
+		case tt.wantErr == "" && (err != nil || got != tt.want):
+			t.Errorf("matchFieldName(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		case tt.wantErr != "" && (err == nil || !errors.Is(err, errFieldResolution) || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("matchFieldName(%q) = %q, %v; want an error containing %q", tt.value, got, err, tt.wantErr)
+		}
+	}
+}
@@ -20,9 +20,9 @@
 //	Output: Tab-separated text file with Jira issues that have been worked on in multiple sprints
 //	        Logs all activity to timestamped log file
 //
-// Example usage, see function showUsage for details:
+// Example usage, showing the common parameters only; run with -help, or see function showUsage, for every parameter:
 //
-//	.\jira-spillover-get.exe [-TokenFile token_file_path] [-url jira_base_url] [-project project_key] [-fromdate yyyy-mm-dd] [-daysprior #] [-outputfile filename] [-pair customfield_10186] [-append] [-log] [-debug][-? | /? | --help | -help]
+//	.\jira-spillover-get.exe [-TokenFile token_file_path] [-url jira_base_url] [-project project_key] [-fromdate yyyy-mm-dd] [-daysprior #] [-outputfile filename] [-append] [-log] [-debug] [other parameters] [-? | /? | --help | -help]
 //
 //	 With no supplied command line parameters, you will be prompted interactively.
 //
@@ -34,10 +34,127 @@
 // API Endpoints:
 //
 //	/rest/api/2/project/{projectKey} - Validates project exists and user has access
-//	/rest/api/2/search - Retrieves issues matching JQL query with pagination
+//	/rest/api/2/search - Retrieves issues matching JQL query with pagination (POST, or GET with -searchget)
 //	/rest/api/2/issue/{issueKey} - Retrieves epic title information
 //
 // History (update version string on line ~95):
+//	1.3.3 New output files are written to a temporary file and renamed into place when complete; -noatomic writes them in place
+//	1.3.2 Changelogs for the changelog analyses are fetched four at a time
+//	1.3.1 A run cut short by -sample adds a Sampled column to the output file
+//	1.3.0 A field name that cannot be resolved because the field list is unreadable stops the run with an error naming the option
+//	1.2.9 Time in status counts unresolved issues up to the run clock rather than the wall clock
+//	1.2.8 The -pair not found warning fires when Jira returns the field on no issue
+//	1.2.7 -selftest searches use POST, or GET with -searchget, like a report run
+//	1.2.6 Report durations are measured on the run clock, so they can no longer be negative when it is replaced
+//	1.2.5 -sqlitefile rebuilds CREATE INDEX indexes instead of refusing the database
+//	1.2.4 -noninteractive uses the default date range and output file instead of exiting
+//	1.2.3 command line parsers read flags through the commandLineFlags registry
+//	1.2.2 The run lock file is written in full before it appears, and one without a readable PID counts as held for a minute
+//	1.2.1 First Sprint Goal and -goalcontains use the chronologically first sprint
+//	1.2.0 First Sprint, Last Sprint, All Sprints and the sprint date columns all come from the chronologically sorted sprint list
+//	1.1.9 Sprint ordering puts dated sprints before undated ones, then orders by name, so sorting is transitive
+//	1.1.8 -append refuses a file whose header differs from this run's; only an unterminated last row with too few columns is treated as partial
+//	1.1.7 A search page rejected with HTTP 429 that cannot shrink is retried once after the wait Jira asks for; golden file tests
+//	1.1.6 Moved the report pipeline into the internal/spillover package; each Run keeps its settings in its own state and logs through Config.Hooks
+//	1.1.5 -pair, -groupbyfield and -flaggedfield accept a field's display name as well as its ID
+//	1.1.4 Epic lookups answered HTTP 403 or 404 show 'Epic not accessible' or 'Epic not found' instead of failing the run or a generic message, and are counted by cause at the end of the run
+//	1.1.3 Added -includetime with Original Estimate, Time Spent, and Aggregate Time Spent hour columns and total time spent in the summary
+//	1.1.2 Per-issue debug dumps go to a separate debug file; added -debugissues
+//	1.1.1 Added Spillover Score column with -scoreweights, -orderby score, and the top ten scores in the summary
+//	1.1.0 Sprint names are normalized (whitespace, and case for sprints without an ID) before counting; added -strictnames
+//	1.0.9 Added -completion to print a bash, zsh, or PowerShell completion script, and a warning for unknown parameters
+//	1.0.8 Added Current Sprint and In Active Sprint columns, -activeonly filter, and the active sprint split in the summary
+//	1.0.7 Added -summaryonly: a one-page summary document in text or html (-format) instead of the per-issue rows
+//	1.0.6 Added sprint data invariant checks and an integrity check of every output row before writing
+//	1.0.5 Added -sqlitefile: append each run, its spillover issues, and their sprints to a SQLite database without cgo
+//	1.0.4 Added -registry: persistent first/last reported registry with First Reported and Weeks On Report columns
+//	1.0.3 Added -tui: full-screen parameter pane, phase progress bars, warnings pane, and a results table that opens issues in the browser
+//	1.0.2 Added -estimatedlate: Unestimated Sprints and Estimated Late columns from the changelog's first story points estimate
+//	1.0.1 Jira deployment type and version are read from serverInfo at startup, logged, and recorded as jiraServers in the stats and posted report; they choose sprint link format and whether updatedBy() needs a probe
+//	1.0.0 Append mode checks the output file ends in a complete row: adds a missing newline, refuses a partial last row unless -repair removes it, and writes a header to an empty file
+//	0.9.9 Added -excludeupdatedby to leave out issues updated by automation users (JQL updatedBy() or changelog fallback) and -jqlupdatedby
+//	0.9.8 Added a <outputfile>.lock run lock so overlapping runs stop with exit status 6 (stale locks are removed), and -nolock to disable it
+//	0.9.7 Added Config.Hooks progress callbacks (batches fetched, epic lookups, retries, phases) for embedding Run; the CLI writes its progress messages through them
+//	0.9.6 Added -splitby lastsprint|month to write one output file per last sprint or resolution month, with a Split Group column
+//	0.9.5 Requested fields Jira returns for no issue (e.g. hidden by field-level security) are warned about, shown as (not visible) and listed as notVisibleFields in the stats and report
+//	0.9.4 Added -archivedir to copy each run's outputs, stats and log into a dated archive directory, and -retentiondays to prune old archives
+//	0.9.3 Added -escalations (Escalated column for priorities raised in flight), -prioritiesonly filter, and spillover count per priority in the summary
+//	0.9.2 Added -credstore to read the token from Windows Credential Manager, macOS Keychain or libsecret, and -storecred to save it
+//	0.9.1 Added Churn Score column (sprints per story point), top five churn issues in the summary, and -minchurn filter
+//	0.9.0 Searches are POSTed with the JQL in a JSON body to avoid URL length limits; added -searchget for the GET fallback
+//	0.8.9 Added -compare to mark spillover issues New or Carried against an earlier report, with a Changes Since Last column
+//	0.8.8 Added -compress gzip output files; Jira responses are requested gzip-compressed and their sizes logged
+//	0.8.7 Parse every Jira timestamp through parseJiraTime (resolved-date filter and Resolved date included) and log unparseable timestamps
+//	0.8.6 Added -sample to fetch only the first N matching issues, marking the run SAMPLED
+//	0.8.5 Added -timeinstatus and -statuscolumns: days per status from the changelog
+//	0.8.4 Added -auditfields custom field value audit
+//	0.8.3 Fixed legacy sprint strings: names and goals containing commas, brackets, or equals signs are read whole
+//	0.8.2 Added Flagged column, -flaggedonly filter, -flaggedfield, and flagged spillover count
+//	0.8.1 Added -flushevery: output files are flushed while they are written so they can be followed with tail -f
+//	0.8.0 Added -includelinks Blocked By and Blocks columns with a blocked chain count, and -linktypes
+//	0.7.9 Fixed -resolvedwithin to compare resolution dates against the window start (the -fromdate date when given) and log the window used
+//	0.7.8 Added embedded JSON Schemas for the report and stats documents, -printschema, and validation before writing
+//	0.7.7 Added Status Category column, -opencategoryonly filter, and spillover count per status category
+//	0.7.6 Added -input to rebuild the outputs and summary from a saved report without contacting Jira
+//	0.7.5 Logging is safe for concurrent use; added -loglevel to set the console threshold independently of the log file
+//	0.7.4 Added -persprint output of spillover into and out of each sprint, with story points
+//	0.7.3 Added -selftest to check connectivity, authentication, project access, search, field IDs, and the output path with a pass/fail table
+//	0.7.2 Added -assigneechanges: Assignee Changes and Distinct Assignees columns from the changelog, with the average churn in the summary
+//	0.7.1 Added -bom/-nobom to start new output files with a UTF-8 byte order mark (default on Windows); truncation no longer separates combining marks or joined emoji
+//	0.7.0 Added -epicrollup output of spillover issues and story points per epic, with the top five epics in the console summary
+//	0.6.9 Sprint fields holding only bare sprint IDs (team-managed projects) are resolved automatically, with concurrent Agile API lookups
+//	0.6.8 Added -skipfailedpages: a failing search page is retried once then skipped, the gap reported, and the run exits with status 5
+//	0.6.7 Added -estimatechanges: SP Changed In Flight and Original SP columns from the changelog, with a count of re-estimated spillover issues
+//	0.6.6 Output is written through a ReportWriter registered per -format (tsv only for now); TSV output is unchanged
+//	0.6.5 Added -graceperiod to leave out issues that have only just rolled over into a second sprint
+//	0.6.4 Added First/Last Sprint Start and End date columns from the chronologically ordered sprints
+//	0.6.3 Added -maxcellwidth to truncate over-long output cells, reporting the truncation count at the end of the run
+//	0.6.2 Added -datefield to choose the JQL date range field (updated, statusCategoryChangedDate, or resolved)
+//	0.6.1 Epics moved between projects are found by their old key and shown in a new Epic Key (current) column
+//	0.6.0 Validated projects are cached per Jira instance for 24h; added -projectcachettl and -refreshcache
+//	0.5.9 Added -sprintlinks (and -cloudlinks) sprint report URL columns
+//	0.5.8 Jira base URLs are normalised: context paths kept, pasted page paths removed, non-http(s) schemes and embedded credentials rejected
+//	0.5.7 Added Epic Status and Epic Resolved columns and a count of spillover issues under already resolved epics
+//	0.5.6 Added -noninteractive (automatic when stdin is not a terminal): missing parameters exit with status 4 instead of prompting
+//	0.5.5 Search batch size adapts to timeouts, HTTP 429 and latency; added -batchsize and -fixedbatch
+//	0.5.4 Added -statsfile writing a JSON summary of run counts (partial on failure or interrupt)
+//	0.5.3 Added First Sprint Goal column and -goalcontains filter; tabs and line breaks in summaries no longer split TSV rows
+//	0.5.2 Output files are synced and re-read to verify the row count (-noverify to skip)
+//	0.5.1 Added multi-instance runs: repeat -url/-tokenfile to merge results with an Instance column (-instancelabel, -preferinstance)
+//	0.5.0 Added a console preview table of the top spillover issues (-preview N, 0 disables)
+//	0.4.9 Added -keysfile to check an explicit list of issue keys instead of a project query
+//	0.4.8 Output file locked by another program (Excel) is retried, then written to a timestamped substitute; -nolockfallback to fail instead
+//	0.4.7 Added -profile, -profilesfile and -listprofiles for saved sets of flag values
+//	0.4.6 Added -includedescription, -descriptionlength and -includecomments columns; the average search response size is logged
+//	0.4.5 Sprints are now told apart by ID, and same-named sprints on different boards are shown as "Sprint 12 (board 34)"
+//	0.4.4 Added -posturl to POST the full report as JSON, with -postauthheader and -postrequired
+//	0.4.3 Added Priority, Due Date and Overdue columns and an overdue count in the summary
+//	0.4.2 Added -orderby; the JQL and output rows are now ordered deterministically
+//	0.4.1 Added -dumpissue to print one issue's raw JSON and derived values for diagnosis
+//	0.4.0 Extracted report orchestration into Run(ctx, Config) returning a Report; main is now a thin CLI wrapper
+//	0.3.9 null custom field values treated as absent for -pair and -groupbyfield
+//	0.3.8 token file permission warning (-stricttoken to fail), encrypted token files with -tokenpass and -encrypttoken
+//	0.3.7 added Staleness column with -stalebuckets thresholds and per-bucket summary
+//	0.3.6 added -logformat/-consolelogformat json logging with structured issue, epic, sprint, and batch fields
+//	0.3.5 added -fixversion JQL filter and -byrelease spillover summary per fix version
+//	0.3.4 added -dedupe to skip issue keys already present when appending
+//	0.3.3 added run plan confirmation (interactive or -confirm, skipped with -yes), exit status 2 when declined
+//	0.3.2 added -oauthconfig OAuth 2.0 (3LO) Bearer auth with refresh, retry, and refresh token rotation
+//	0.3.1 added -allissuesfile streaming every processed issue with spillover yes/no
+//	0.3.0 added -timezone for output dates, from-date, and resolved day counts
+//	0.2.9 validate output paths up front, create missing directories
+//	0.2.8 added -commitment changelog analysis with Committed At Sprint Start column and mid-sprint addition count
+//	0.2.7 added -ratelimit token-bucket limiter in a shared HTTP transport, Ctrl-C cancels waits, API request rate logged
+//	0.2.6 added accountId/emailAddress capture and -identityfields to control Assignee/Reporter/Pair identity output
+//	0.2.5 added -problemsfile collecting all warnings and errors (with issue/epic/sprint key) into a TSV, fatal exits now run cleanup
+//	0.2.4 added -includeepics to report Epics placed directly into sprints
+//	0.2.3 retry 401/403 once per request after re-reading the token file during issue and epic fetches
+//	0.2.2 added -ignorelabel to exclude issues expected to span sprints, with optional -excludedfile audit output
+//	0.2.1 added -resolvedwithin to control (or disable) skipping of issues resolved before the date range, logs excluded count
+//	0.2.0 added -groupbyfield to group output rows by a custom field, with optional -subtotals rows
+//	0.1.9 precompiled sprint name regex, pre-sized per-issue slices and maps, removed fmt.Sprintf from row hot paths
+//	0.1.8 handle bare numeric sprint IDs (optionally resolved with -resolvesprintids), added -allsprintsmax, debug uninterpretable sprint entries
+//	0.1.7 added -sprintpairs output of spillover issue count and story points per consecutive sprint pair
 //	0.1.6 packaged for release
 //	0.1.5 updated productName & README
 //	0.1.4a cosmetic comment format changes
@@ -78,218 +195,412 @@
 // You should have received a copy of the GNU General Public License along with this program.
 // If not, see <https://www.gnu.org/licenses/>.
 // *************************************************************************************************
-//go:generate goversioninfo
+//go:generate goversioninfo -64 -o resource_windows_amd64.syso
 // *************************************************************************************************
 
 package main
 
 import (
 	"bufio"           // For reading user input from stdin
+	"bytes"           // For encoding the stats file and capturing credential store errors
+	"context"         // For cancelling requests and rate limiter waits on Ctrl-C
+	"crypto/aes"      // For encrypted token files
+	"crypto/cipher"   // For AES-GCM authenticated encryption of token files
+	"crypto/rand"     // For encrypted token file salts and nonces
 	"encoding/base64" // For Base64 encoding of authentication credentials
-	"encoding/json"   // For parsing JSON responses from Jira API
+	"encoding/json"   // For the stats file, JSON log lines, and report profiles
+	"errors"          // For identifying authentication failures
 	"fmt"             // For formatted printing and string formatting
-	"io"              // For reading HTTP response bodies
+	"io"              // For copying files into the run archive (-archivedir)
 	"log"             // For logging to file
-	"net/http"        // For making HTTP requests to Jira API
-	"net/url"         // For URL encoding JQL queries
+	"math"            // For rejecting infinite -scoreweights
+	"net/url"         // For normalising Jira base URLs
 	"os"              // For command line arguments and file operations
-	"regexp"          // For parsing sprint field values
+	"os/exec"         // For reading tokens from the OS credential store (-credstore)
+	"os/signal"       // For cancelling in-flight work on Ctrl-C
+	"path/filepath"   // For archive, profile, and lock file paths
+	"regexp"          // For validating issue keys and Jira base URLs
+	"runtime"         // For skipping Unix permission checks on Windows
+	"sort"            // For ordering sprints and report rows
 	"strconv"         // For string to number conversion
 	"strings"         // For string manipulation and processing
+	"sync"            // For serialising log writes
+	"syscall"         // For checking whether the process holding a lock file is still running
 	"time"            // For date validation and timestamp formatting
+	_ "time/tzdata"   // Embedded IANA zone database so -timezone works on Windows
+
+	"golang.org/x/crypto/scrypt" // For deriving encrypted token file keys from a passphrase
+	"golang.org/x/term"          // For reading passphrases without echo
+
+	"jira-spillover-get/internal/spillover" // The report pipeline (Run) the command line drives
 )
 
 // Program metadata - update these values when changing the program
 const (
 	programName    = "jira-spillover-get"
-	programVersion = "0.1.6"
+	programVersion = "1.3.3"
 )
 
-// Default configuration constants
+// Exit statuses and console defaults
 const (
-	defaultStoryPointsField = "customfield_10059" // Default story points field
-	defaultSprintField      = "customfield_10020" // Default sprint field
-	defaultEpicLinkField    = "customfield_10014" // Default epic link field
-	batchSize               = 100                 // Number of issues to fetch per API call
-	defaultDaysPrior        = 10                  // Default number of days to look back
+	exitCodeUserAborted    = 2   // Exit status when the user declines the confirmation prompt
+	exitCodePostFailed     = 3   // Exit status when the report could not be POSTed and -postrequired is set
+	exitCodeInvalidArgs    = 4   // Exit status when the URL, token file, or project is missing and prompting is disabled (-noninteractive)
+	exitCodePartialResults = 5   // Exit status when search pages were skipped after failing (-skipfailedpages)
+	exitCodeRunInProgress  = 6   // Exit status when another run holds the output file's lock file (see -nolock)
+	defaultPreviewCount    = 10  // Spillover issues shown in the console preview table (-preview)
+	defaultTerminalWidth   = 120 // Console width assumed when it cannot be detected
 )
 
-// IssueFields contains all standard and custom fields for a Jira issue.
-type IssueFields struct {
-	IssueType        IssueType                  `json:"issuetype"`         // Type of the issue (e.g., Story, Task)
-	Status           Status                     `json:"status"`            // Current status
-	Summary          string                     `json:"summary"`           // Issue summary/title
-	Updated          *string                    `json:"updated"`           // Last updated date (RFC3339)
-	Created          *string                    `json:"created"`           // Creation date (RFC3339)
-	ResolutionDate   *string                    `json:"resolutiondate"`    // Resolution date (nullable)
-	Assignee         *Assignee                  `json:"assignee"`          // Current assignee (nullable)
-	Creator          *Creator                   `json:"creator"`           // Issue creator
-	Project          Project                    `json:"project"`           // Project details
-	FixVersions      []FixVersion               `json:"fixVersions"`       // Target release versions
-	Components       []Component                `json:"components"`        // Associated components
-	Labels           []string                   `json:"labels"`            // Issue labels
-	Resolution       *Resolution                `json:"resolution"`        // Resolution status (nullable)
-	StoryPoints      interface{}                `json:"customfield_10059"` // Story points (type varies)
-	SprintField      interface{}                `json:"customfield_10020"` // Sprint field (array or null)
-	EpicLinkField    interface{}                `json:"customfield_10014"` // Epic link (string or null)
-	AdditionalFields map[string]json.RawMessage `json:"-"`                 // Unmapped custom fields
-}
-
-// Issue represents a Jira issue from the search API response
-type Issue struct {
-	Key    string      `json:"key"`    // Issue key (e.g., "EXPD-1234")
-	Fields IssueFields `json:"fields"` // Issue field data
-}
-
-// UnmarshalJSON implements custom unmarshalling to capture both known fields and any additional custom fields
-func (f *IssueFields) UnmarshalJSON(data []byte) error {
-	// Define an alias type to avoid recursion
-	type Alias IssueFields
-	aux := &struct {
-		*Alias
-	}{Alias: (*Alias)(f)}
-
-	// First unmarshal into a generic map to capture raw fields
-	var rawMap map[string]json.RawMessage
-	if err := json.Unmarshal(data, &rawMap); err != nil {
-		return err
-	}
-
-	// Unmarshal known fields from the raw map into aux
-	if err := json.Unmarshal(data, &aux); err != nil {
-		return err
-	}
-
-	// Store any additional fields (those not represented by the struct tags above)
-	f.AdditionalFields = make(map[string]json.RawMessage)
-	// Known keys from IssueFields tags
-	knownKeys := map[string]bool{
-		"issuetype":         true,
-		"status":            true,
-		"summary":           true,
-		"updated":           true,
-		"created":           true,
-		"resolutiondate":    true,
-		"assignee":          true,
-		"creator":           true,
-		"project":           true,
-		"fixVersions":       true,
-		"components":        true,
-		"labels":            true,
-		"resolution":        true,
-		"customfield_10002": true,
-		"customfield_14181": true,
-		"customfield_14182": true,
-		// Do NOT include customfield_10186 (Pair) so it is added to AdditionalFields
-	}
-
-	for k, v := range rawMap {
-		if !knownKeys[k] {
-			f.AdditionalFields[k] = v
-		}
-	}
+// staleRunLockAge is the age after which a lock file without a readable PID is treated as stale (see createRunLock)
+const staleRunLockAge = time.Minute
 
-	return nil
-}
-
-// IssueType represents issue type information
-type IssueType struct {
-	Name string `json:"name"` // Issue type name (Story, Task, Bug, etc.)
-}
+// defaultOutputFile is the report file used when -outputfile is not given (offered at the prompt, or used as-is when
+// running non-interactively)
+const defaultOutputFile = "spillover_rpt.tsv"
 
-// Status represents issue status information
-type Status struct {
-	Name string `json:"name"` // Status name (Closed, Story Done, etc.)
-}
+// reportProfile is a saved set of flag values (-profile), keyed by flag name without the leading "-".
+// Values may be strings, numbers, booleans (true adds a switch), or arrays joined with commas.
+type reportProfile map[string]interface{}
 
-// Assignee contains the display name of the assignee.
-type Assignee struct {
-	DisplayName string `json:"displayName"`
+// commandLineFlag describes one command line flag in commandLineFlags.
+//
+// A flag given on the command line is stored through the one target set (Switch, Text, Int, Float, List, or Parse)
+// by readCommandLineFlags; flags without a target are read by main itself.
+type commandLineFlag struct {
+	Name       string   // Lowercase name, including the leading "-" (flags are matched case-insensitively)
+	Value      string   // Value the flag takes: empty for none, "text" (any text, number, or list), "file", or "dir"
+	Choices    []string // Accepted values, for a flag with a fixed set of them
+	Repeatable bool     // May be given more than once
+
+	Switch *bool                   // Set to true when the flag is given (flags that take no value)
+	Text   *string                 // Set to the value; a value matching one of the Choices is spelled as the choice is
+	Int    *int                    // Set to the value, a whole number of zero or more
+	Float  *float64                // Set to the value, a number of zero or more
+	List   *[]string               // Set to the comma-separated values (every value, blank ones included, if Repeatable)
+	Parse  func(value string) bool // Stores a value of any other form, returning false if it is invalid
+
+	Upper    bool   // Text and List values are uppercased (project and issue keys)
+	Positive bool   // Int and Float values must be above zero
+	Early    bool   // Read before logging starts, as it sets logging up (see main)
+	Message  string // INFO message logged when the flag is given, %s standing for the value
+	Invalid  string // What is done instead with an invalid value, ending the WARNING "Invalid -flag value '...', "
 }
 
-// Creator contains the display name of the creator/reporter.
-type Creator struct {
-	DisplayName string `json:"displayName"`
+// commandLineSettings holds the flag values read by readCommandLineFlags: the Config the run starts from and the
+// settings only the command line layer uses.
+type commandLineSettings struct {
+	spillover.Config
+
+	OAuthConfig    string   // -oauthconfig file
+	EncryptToken   string   // -encrypttoken plaintext token file
+	CredStore      string   // -credstore credential name
+	StoreCred      string   // -storecred credential name
+	URLs           []string // -url values, in command line order
+	TokenFiles     []string // -tokenfile values, in command line order
+	InstanceLabels []string // -instancelabel values, in command line order
+	FromDate       string   // -fromdate (yyyy-mm-dd)
+	Timezone       string   // -timezone IANA name, or Local
+	NoBOM          bool     // -nobom, which wins over -bom
+	Preview        int      // Spillover issues in the console preview table (-preview)
+	TUI            bool     // -tui
+	KeysFile       string   // -keysfile
+	NoLock         bool     // -nolock
+	Confirm        bool     // -confirm
+	Yes            bool     // -yes
+	LogLevel       string   // -loglevel, empty for the default
+	ArchiveDir     string   // -archivedir
+	RetentionDays  int      // -retentiondays, 0 to keep every archived run
+	DumpIssue      string   // -dumpissue issue key
+	SelfTest       bool     // -selftest
+	PostURL        string   // -posturl
+	PostAuthHeader string   // -postauthheader
+	PostRequired   bool     // -postrequired
+	SQLiteFile     string   // -sqlitefile
+
+	given map[string]bool // Flags given with a valid value, by name
 }
 
-// Project contains the key and name of a Jira project.
-type Project struct {
-	Key  string `json:"key"`
-	Name string `json:"name"`
+// commandLine is filled in from the command line by readCommandLineFlags, starting from the defaults here
+var commandLine = commandLineSettings{
+	Config: spillover.Config{
+		DateField:         "updated",
+		Format:            "tsv",
+		IdentityMode:      "display",
+		OrderBy:           "key",
+		DescriptionLength: 200,
+		BatchSize:         spillover.DefaultBatchSize,
+		FlushEvery:        spillover.DefaultFlushEvery,
+		ProjectCacheTTL:   spillover.DefaultProjectCacheTTL,
+		StaleBuckets:      [3]int{7, 21, 60},
+		ScoreWeights:      spillover.DefaultScoreWeights,
+	},
+	Timezone: "Local",
+	Preview:  defaultPreviewCount,
 }
 
-// FixVersion contains the name of a fix version.
-type FixVersion struct {
-	Name string `json:"name"`
+// commandLineFlags is the registry of command line flags, in -? order. readCommandLineFlags reads the flags into
+// commandLine through it, arguments that look like flags but are not listed are reported by checkCommandLineFlags,
+// and the -completion scripts are generated from it.
+var commandLineFlags = []commandLineFlag{
+	{Name: "-tokenfile", Value: "file", Repeatable: true, List: &commandLine.TokenFiles},
+	{Name: "-tokenpass", Switch: &tokenPassEnabled, Message: "Encrypted token file enabled from command line"},
+	{Name: "-stricttoken", Switch: &strictTokenFile, Message: "Strict token file permission checking enabled from command line"},
+	{Name: "-encrypttoken", Value: "file", Text: &commandLine.EncryptToken},
+	{Name: "-credstore", Value: "text", Text: &commandLine.CredStore, Message: "Using credential store entry from command line: %s"},
+	{Name: "-storecred", Value: "text", Text: &commandLine.StoreCred},
+	{Name: "-oauthconfig", Value: "file", Text: &commandLine.OAuthConfig, Message: "Using OAuth config file from command line: %s"},
+	{Name: "-url", Value: "text", Repeatable: true, List: &commandLine.URLs},
+	{Name: "-instancelabel", Value: "text", Repeatable: true, List: &commandLine.InstanceLabels},
+	{Name: "-preferinstance", Value: "text", Text: &commandLine.PreferInstance, Message: "Preferring the %s instance for duplicate issue keys"},
+	{Name: "-project", Value: "text", Text: &commandLine.ProjectKey, Upper: true, Message: "Using project key from command line: %s"},
+	{Name: "-pair", Value: "text", Text: &commandLine.PairField},
+	{Name: "-fromdate", Value: "text", Text: &commandLine.FromDate, Message: "Using from date from command line: %s"},
+	{Name: "-daysprior", Value: "text", Int: &commandLine.DaysPrior, Message: "Using days prior from command line: %s", Invalid: "it is ignored"},
+	{Name: "-resolvedwithin", Value: "text", Int: &commandLine.ResolvedWithin, Message: "Using resolved within days from command line: %s", Invalid: "using the date range instead"},
+	{Name: "-datefield", Value: "text", Choices: []string{"updated", "statusCategoryChangedDate", "resolved"}, Text: &commandLine.DateField, Message: "Using date field from command line: %s"},
+	{Name: "-timezone", Value: "text", Text: &commandLine.Timezone, Message: "Using timezone from command line: %s"},
+	{Name: "-outputfile", Value: "file", Text: &commandLine.OutputFile, Message: "Using output file from command line: %s"},
+	{Name: "-format", Value: "text", Choices: append(spillover.ReportFormatNames(), "text", "html"), Text: &commandLine.Format, Message: "Using output format from command line: %s"},
+	{Name: "-summaryonly", Switch: &commandLine.SummaryOnly, Message: "Summary document only (no per-issue rows) enabled from command line"},
+	{Name: "-bom", Switch: &commandLine.BOM, Message: "UTF-8 byte order mark enabled from command line"},
+	{Name: "-nobom", Switch: &commandLine.NoBOM, Message: "UTF-8 byte order mark disabled from command line"},
+	{Name: "-compress", Switch: &commandLine.Compress, Message: "Output compression enabled from command line"},
+	{Name: "-append", Switch: &commandLine.AppendMode, Message: "Append mode enabled from command line"},
+	{Name: "-dedupe", Switch: &commandLine.Dedupe, Message: "Duplicate issue detection for append mode enabled from command line"},
+	{Name: "-repair", Switch: &commandLine.Repair, Message: "Repair of a partial last row in append mode enabled from command line"},
+	{Name: "-preview", Value: "text", Int: &commandLine.Preview, Message: "Using preview table size from command line: %s", Invalid: "using default " + strconv.Itoa(defaultPreviewCount)},
+	{Name: "-tui", Switch: &commandLine.TUI, Message: "Full-screen display enabled from command line"},
+	{Name: "-keysfile", Value: "file", Text: &commandLine.KeysFile, Message: "Using issue keys file from command line: %s"},
+	{Name: "-noverify", Switch: &commandLine.NoVerify, Message: "Output file verification disabled from command line"},
+	{Name: "-nolock", Switch: &commandLine.NoLock, Message: "Run lock file disabled from command line"},
+	{Name: "-flushevery", Value: "text", Parse: parseFlushEvery, Message: "Flushing output files every %s rows from command line (0: only when complete)", Invalid: "using " + strconv.Itoa(spillover.DefaultFlushEvery)},
+	{Name: "-noatomic", Switch: &commandLine.NoAtomic, Message: "Output files will be written in place, so they can be followed while written (-noatomic)"},
+	{Name: "-projectcachettl", Value: "text", Parse: parseProjectCacheTTL, Message: "Using project cache TTL from command line: %s (0 disables the cache)", Invalid: "using default " + spillover.DefaultProjectCacheTTL.String() + " (e.g., 24h, 90m, or 0 to disable)"},
+	{Name: "-refreshcache", Switch: &commandLine.RefreshCache, Message: "Project cache refresh requested from command line"},
+	{Name: "-batchsize", Value: "text", Int: &commandLine.BatchSize, Positive: true, Message: "Using search batch size from command line: %s", Invalid: "using default " + strconv.Itoa(spillover.DefaultBatchSize)},
+	{Name: "-fixedbatch", Switch: &commandLine.FixedBatch, Message: "Search batch size fixed from command line"},
+	{Name: "-searchget", Switch: &commandLine.SearchGET, Message: "Searching with GET requests from command line (JQL sent in the URL)"},
+	{Name: "-skipfailedpages", Switch: &commandLine.SkipFailedPages, Message: "Search pages that still fail after a retry will be skipped (-skipfailedpages)"},
+	{Name: "-sample", Value: "text", Int: &commandLine.Sample, Positive: true, Message: "Using sample size from command line: %s issues", Invalid: "all matching issues will be fetched"},
+	{Name: "-nolockfallback", Switch: &commandLine.NoLockFallback, Message: "Locked output file fallback disabled from command line"},
+	{Name: "-noninteractive", Switch: &nonInteractive, Message: "Non-interactive mode enabled from command line (missing parameters will not be prompted for)"},
+	{Name: "-confirm", Switch: &commandLine.Confirm, Message: "Confirmation before running enabled from command line"},
+	{Name: "-yes", Switch: &commandLine.Yes, Message: "Confirmation prompt skipped from command line (-yes)"},
+	{Name: "-ratelimit", Value: "text", Float: &commandLine.RequestsPerSecond, Positive: true, Message: "Using rate limit from command line: %s requests/second", Invalid: "requests will not be rate limited"},
+	{Name: "-resolvesprintids", Switch: &commandLine.ResolveSprintIDs, Message: "Sprint ID resolution enabled from command line"},
+	{Name: "-strictnames", Switch: &commandLine.StrictNames, Message: "Sprint names will be compared exactly (case and whitespace are significant)"},
+	{Name: "-allsprintsmax", Value: "text", Int: &commandLine.AllSprintsMax, Message: "Using All Sprints maximum length from command line: %s", Invalid: "All Sprints will not be truncated"},
+	{Name: "-maxcellwidth", Value: "text", Int: &commandLine.MaxCellWidth, Message: "Using maximum cell width from command line: %s", Invalid: "cells will not be truncated"},
+	{Name: "-commitment", Switch: &commandLine.Commitment, Message: "Sprint commitment analysis enabled from command line"},
+	{Name: "-estimatechanges", Switch: &commandLine.EstimateChanges, Message: "In-flight estimate change analysis enabled from command line"},
+	{Name: "-assigneechanges", Switch: &commandLine.AssigneeChanges, Message: "Assignee change analysis enabled from command line"},
+	{Name: "-escalations", Switch: &commandLine.Escalations, Message: "Priority escalation analysis enabled from command line"},
+	{Name: "-estimatedlate", Switch: &commandLine.EstimatedLate, Message: "Late estimate analysis enabled from command line"},
+	{Name: "-timeinstatus", Switch: &commandLine.TimeInStatus, Message: "Time in status analysis enabled from command line"},
+	{Name: "-statuscolumns", Value: "text", List: &commandLine.StatusColumns, Message: "Using status columns from command line: %s"},
+	{Name: "-identityfields", Value: "text", Choices: []string{"display", "email", "accountid", "display+email"}, Text: &commandLine.IdentityMode, Message: "Using identity fields from command line: %s", Invalid: "using display names"},
+	{Name: "-includeepics", Switch: &commandLine.IncludeEpics, Message: "Epic inclusion enabled from command line"},
+	{Name: "-fixversion", Value: "text", List: &commandLine.FixVersions, Message: "Using fix versions from command line: %s"},
+	{Name: "-byrelease", Switch: &commandLine.ByRelease, Message: "Spillover summary by release enabled from command line"},
+	{Name: "-stalebuckets", Value: "text", Parse: parseStaleBuckets, Message: "Using staleness buckets from command line: %s", Invalid: "using defaults (expected three increasing day counts, e.g. 7,21,60)"},
+	{Name: "-scoreweights", Value: "text", Parse: parseScoreWeights, Message: "Using spillover score weights from command line: %s", Invalid: "using defaults (expected non-negative sprints=, points= and age= weights, not all zero, e.g. sprints=3,points=1,age=0.1)"},
+	{Name: "-ignorelabel", Value: "text", List: &commandLine.IgnoreLabels, Message: "Using ignore labels from command line: %s"},
+	{Name: "-goalcontains", Value: "text", Text: &commandLine.GoalContains, Message: "Only reporting issues whose first sprint goal mentions: %s"},
+	{Name: "-graceperiod", Value: "text", Int: &commandLine.GracePeriod, Message: "Using grace period from command line: %s days", Invalid: "no grace period will be applied"},
+	{Name: "-opencategoryonly", Switch: &commandLine.OpenCategoryOnly, Message: "Only spillover issues outside the Done status category will be reported"},
+	{Name: "-flaggedonly", Switch: &commandLine.FlaggedOnly, Message: "Only spillover issues flagged as an impediment will be reported"},
+	{Name: "-activeonly", Switch: &commandLine.ActiveOnly, Message: "Only spillover issues in an active sprint will be reported"},
+	{Name: "-prioritiesonly", Value: "text", List: &commandLine.PrioritiesOnly, Message: "Only reporting priorities from command line: %s"},
+	{Name: "-excludeupdatedby", Value: "text", List: &commandLine.ExcludeUpdatedBy, Message: "Excluding issues updated by users from command line: %s"},
+	{Name: "-jqlupdatedby", Switch: &commandLine.JQLUpdatedBy, Message: "JQL updatedBy() support assumed from command line"},
+	{Name: "-minchurn", Value: "text", Float: &commandLine.MinChurn, Positive: true, Message: "Using minimum churn score from command line: %s", Invalid: "issues will not be filtered by churn score"},
+	{Name: "-flaggedfield", Value: "text", Text: &commandLine.FlaggedField, Message: "Using Flagged field from command line: %s"},
+	{Name: "-auditfields", Switch: &commandLine.AuditFields, Message: "Custom field audit enabled from command line"},
+	{Name: "-excludedfile", Value: "file", Text: &commandLine.ExcludedFile, Message: "Using excluded issues file from command line: %s"},
+	{Name: "-groupbyfield", Value: "text", Text: &commandLine.GroupByField, Message: "Grouping output by field from command line: %s"},
+	{Name: "-includedescription", Switch: &commandLine.IncludeDescription, Message: "Description excerpts enabled from command line (larger Jira responses)"},
+	{Name: "-descriptionlength", Value: "text", Int: &commandLine.DescriptionLength, Positive: true, Message: "Using description length from command line: %s", Invalid: "using 200"},
+	{Name: "-includecomments", Switch: &commandLine.IncludeComments, Message: "Comment counts enabled from command line (larger Jira responses)"},
+	{Name: "-includetime", Switch: &commandLine.IncludeTime, Message: "Time tracking columns enabled from command line"},
+	{Name: "-includelinks", Switch: &commandLine.IncludeLinks, Message: "Issue link columns enabled from command line"},
+	{Name: "-linktypes", Value: "text", List: &commandLine.LinkTypes, Message: "Using link types from command line: %s"},
+	{Name: "-sprintlinks", Switch: &commandLine.SprintLinks, Message: "Sprint report URL columns enabled from command line"},
+	{Name: "-cloudlinks", Switch: &commandLine.CloudLinks, Message: "Using Jira Cloud sprint report URLs from command line"},
+	{Name: "-orderby", Value: "text", Choices: []string{"key", "updated", "created", "priority", "score"}, Text: &commandLine.OrderBy, Message: "Using order by from command line: %s", Invalid: "ordering by issue key"},
+	{Name: "-subtotals", Switch: &commandLine.Subtotals, Message: "Group subtotals enabled from command line"},
+	{Name: "-splitby", Value: "text", Choices: []string{"none", "lastsprint", "month"}, Text: &commandLine.SplitBy, Message: "Using output file split from command line: %s", Invalid: "writing a single output file"},
+	{Name: "-allissuesfile", Value: "file", Text: &commandLine.AllIssuesFile, Message: "Using all issues output file from command line: %s"},
+	{Name: "-sprintpairs", Value: "file", Text: &commandLine.SprintPairsFile, Message: "Using sprint pairs output file from command line: %s"},
+	{Name: "-persprint", Value: "file", Text: &commandLine.PerSprintFile, Message: "Using per-sprint output file from command line: %s"},
+	{Name: "-epicrollup", Value: "file", Text: &commandLine.EpicRollupFile, Message: "Using epic rollup output file from command line: %s"},
+	{Name: "-input", Value: "file", Text: &commandLine.InputFile, Message: "Using saved report from command line: %s"},
+	{Name: "-compare", Value: "file", Text: &commandLine.CompareFile, Message: "Comparing with earlier report from command line: %s"},
+	{Name: "-registry", Value: "file", Text: &commandLine.RegistryFile, Message: "Using issue registry from command line: %s"},
+	{Name: "-log", Switch: &enableLogging, Early: true, Message: "Logging enabled from command line"},
+	{Name: "-loglevel", Value: "text", Choices: []string{"debug", "info", "warning", "error"}, Text: &commandLine.LogLevel, Early: true, Message: "Using -loglevel %s from command line", Invalid: "using the default"},
+	{Name: "-logformat", Value: "text", Choices: []string{"text", "json"}, Text: &fileLogFormat, Early: true, Message: "Using -logformat %s from command line", Invalid: "using text"},
+	{Name: "-consolelogformat", Value: "text", Choices: []string{"text", "json"}, Text: &consoleLogFormat, Early: true, Message: "Using -consolelogformat %s from command line", Invalid: "using text"},
+	{Name: "-problemsfile", Value: "file", Text: &problemsFileName, Early: true, Message: "Problems file enabled from command line: %s"},
+	{Name: "-statsfile", Value: "file", Text: &statsFileName, Early: true, Message: "Stats file enabled from command line: %s"},
+	{Name: "-archivedir", Value: "dir", Text: &commandLine.ArchiveDir, Message: "Archiving outputs from command line: %s"},
+	{Name: "-retentiondays", Value: "text", Int: &commandLine.RetentionDays, Positive: true, Message: "Using archive retention from command line: %s days", Invalid: "archived outputs will be kept"},
+	{Name: "-dumpissue", Value: "text", Text: &commandLine.DumpIssue, Upper: true, Message: "Dumping issue from command line: %s"},
+	{Name: "-selftest", Switch: &commandLine.SelfTest, Message: "Self-test requested from command line, no report will be produced"},
+	{Name: "-posturl", Value: "text", Parse: parsePostURL, Message: "Using report POST URL from command line: %s", Invalid: "the report will not be posted"},
+	{Name: "-sqlitefile", Value: "file", Text: &commandLine.SQLiteFile, Message: "Using SQLite database from command line: %s"},
+	{Name: "-postauthheader", Value: "text", Text: &commandLine.PostAuthHeader, Message: "Using report POST auth header from command line"},
+	{Name: "-postrequired", Switch: &commandLine.PostRequired, Message: "Report POST failures will fail the run (-postrequired)"},
+	{Name: "-profile", Value: "text"},
+	{Name: "-profilesfile", Value: "file"},
+	{Name: "-listprofiles"},
+	{Name: "-printschema", Value: "text", Choices: []string{"report", "stats"}},
+	{Name: "-completion", Value: "text", Choices: []string{"bash", "zsh", "powershell"}},
+	{Name: "-debug", Switch: &enableDebug, Early: true, Message: "Debug output enabled from command line"},
+	{Name: "-debugissues", Value: "text", List: &commandLine.DebugIssues, Upper: true, Message: "Debug details shown on the console for: %s"},
+	{Name: "-help"},
+	{Name: "-?"},
 }
 
-// Component contains the name of a component.
-type Component struct {
-	Name string `json:"name"`
+// archiveDirLayout names each run's archive directory (-archivedir) after the run's start time
+const archiveDirLayout = "2006-01-02-150405"
+
+// archiveDirPattern matches archive directory names, so -retentiondays never removes anything else
+var archiveDirPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-\d{6}$`)
+
+// encryptedTokenPrefix marks a token file written by -encrypttoken: the prefix is followed by
+// Base64 of salt (16 bytes), AES-GCM nonce (12 bytes), and ciphertext.
+const encryptedTokenPrefix = "jsg-enc-v1:"
+
+// windowsCredentialAPI declares the Credential Manager functions for the PowerShell scripts of -credstore and -storecred
+const windowsCredentialAPI = `Add-Type -TypeDefinition @"
+using System;
+using System.Runtime.InteropServices;
+public static class JiraSpilloverCredential {
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    public struct CREDENTIAL {
+        public int Flags;
+        public int Type;
+        public string TargetName;
+        public string Comment;
+        public System.Runtime.InteropServices.ComTypes.FILETIME LastWritten;
+        public int CredentialBlobSize;
+        public IntPtr CredentialBlob;
+        public int Persist;
+        public int AttributeCount;
+        public IntPtr Attributes;
+        public string TargetAlias;
+        public string UserName;
+    }
+    [DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+    public static extern bool CredRead(string target, int type, int flags, out IntPtr credential);
+    [DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+    public static extern bool CredWrite(ref CREDENTIAL credential, int flags);
+    [DllImport("advapi32.dll")]
+    public static extern void CredFree(IntPtr buffer);
 }
-
-// Resolution contains the name of a resolution.
-type Resolution struct {
-	Name string `json:"name"`
+"@
+`
+
+// windowsCredentialRead prints the user name and secret of the generic credential named by JSG_CREDENTIAL_TARGET
+const windowsCredentialRead = windowsCredentialAPI + `$pointer = [IntPtr]::Zero
+if (-not [JiraSpilloverCredential]::CredRead($env:JSG_CREDENTIAL_TARGET, 1, 0, [ref]$pointer)) {
+    [Console]::Error.WriteLine("no generic credential named '" + $env:JSG_CREDENTIAL_TARGET + "' in Credential Manager")
+    exit 2
 }
-
-// PairMember contains the display name of a pair programming member.
-type PairMember struct {
-	DisplayName string `json:"displayName"`
+$credential = [Runtime.InteropServices.Marshal]::PtrToStructure($pointer, [type][JiraSpilloverCredential+CREDENTIAL])
+$secret = [Runtime.InteropServices.Marshal]::PtrToStringUni($credential.CredentialBlob, $credential.CredentialBlobSize / 2)
+[JiraSpilloverCredential]::CredFree($pointer)
+[Console]::Out.WriteLine($credential.UserName)
+[Console]::Out.WriteLine($secret)
+`
+
+// windowsCredentialWrite saves the secret read from stdin as a generic credential named by JSG_CREDENTIAL_TARGET
+const windowsCredentialWrite = windowsCredentialAPI + `$bytes = [Text.Encoding]::Unicode.GetBytes([Console]::In.ReadLine())
+$credential = New-Object JiraSpilloverCredential+CREDENTIAL
+$credential.Type = 1
+$credential.Persist = 2
+$credential.TargetName = $env:JSG_CREDENTIAL_TARGET
+$credential.UserName = $env:JSG_CREDENTIAL_USER
+$credential.CredentialBlobSize = $bytes.Length
+$credential.CredentialBlob = [Runtime.InteropServices.Marshal]::AllocHGlobal($bytes.Length)
+[Runtime.InteropServices.Marshal]::Copy($bytes, 0, $credential.CredentialBlob, $bytes.Length)
+$saved = [JiraSpilloverCredential]::CredWrite([ref]$credential, 0)
+[Runtime.InteropServices.Marshal]::FreeHGlobal($credential.CredentialBlob)
+if (-not $saved) {
+    [Console]::Error.WriteLine("CredWrite failed with error " + [Runtime.InteropServices.Marshal]::GetLastWin32Error())
+    exit 1
 }
+`
 
-// SearchResponse is the response from Jira's search API.
-type SearchResponse struct {
-	Issues     []Issue `json:"issues"`
-	Total      int     `json:"total"`
-	StartAt    int     `json:"startAt"`
-	MaxResults int     `json:"maxResults"`
-}
+// Report profiles (-profile) are read from this file and from NAME.json files in the profiles directory
+const (
+	defaultProfilesFile = "jira-spillover-get-profiles.json"
+	profilesDirName     = "profiles.d"
+)
 
-// ProjectInfo contains basic project information for validation.
-type ProjectInfo struct {
-	Key  string `json:"key"`
-	Name string `json:"name"`
-}
+// Errors returned by Run so callers can tell configuration and user aborts apart from Jira failures
+var (
+	errRunInProgress = errors.New("another run is in progress")
+)
 
-// EpicInfo contains epic information for title lookups.
-type EpicInfo struct {
-	Key    string           `json:"key"`
-	Fields EpicFieldsLookup `json:"fields"`
-}
+// Precompiled regular expressions used in per-issue processing
+var (
+	issueKeyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`) // Validates issue keys read from -keysfile
 
-// EpicFieldsLookup contains only the summary for epic title lookup.
-type EpicFieldsLookup struct {
-	Summary string `json:"summary"`
-}
+	// Finds where a browser page path starts in a pasted Jira URL (see normalizeJiraBaseURL)
+	jiraPagePathRegex = regexp.MustCompile(`(?i)/(secure|browse|projects|issues|plugins|rest)(/|$)|/[^/]*\.jspa?$`)
+)
 
-// SprintInfo contains parsed sprint information for an issue.
-type SprintInfo struct {
-	SprintCount int      // Number of sprints the issue has been in
-	SprintNames []string // List of unique sprint names
-	FirstSprint string   // Name of the first sprint
-	LastSprint  string   // Name of the last sprint
-	AllSprints  string   // Comma-separated list of all sprint names
+// ProblemRecord is a WARNING or ERROR captured during the run for the problems file.
+type ProblemRecord struct {
+	Timestamp time.Time // When the message was logged
+	Severity  string    // WARNING or ERROR
+	Key       string    // Issue, epic, or sprint key the message relates to (empty if not applicable)
+	Message   string    // Log message text
 }
 
-// MultisprintIssue represents an issue that has been in multiple sprints.
-type MultisprintIssue struct {
-	Issue         Issue      // The original issue data
-	WorkedSprints int        // Number of sprints worked
-	EpicLink      string     // Epic key or "No Epic"
-	ResolvedDate  *time.Time // When issue was resolved (if applicable)
-	SprintInfo    SprintInfo // Sprint information for the issue
+// jsonLogEntry is one line of JSON log output (-logformat json / -consolelogformat json).
+type jsonLogEntry struct {
+	Timestamp string `json:"ts"`
+	Level     string `json:"level"`
+	Message   string `json:"msg"`
+	Issue     string `json:"issue,omitempty"`
+	Epic      string `json:"epic,omitempty"`
+	Sprint    string `json:"sprint,omitempty"`
+	Batch     int    `json:"batch,omitempty"`
 }
 
 // Global variables for logging
 var (
-	logFile           *os.File
-	logger            *log.Logger
-	startTime         time.Time
-	enableLogging     bool   // Add flag to control logging
-	enableDebug       bool   // Add flag to control debug output
-	pairFieldName     string // pairFieldName is the JSON field name to look up for Pair information when provided
-	pairFieldProvided bool   // pairFieldProvided is true when the -Pair command line switch was provided
+	logFile          *os.File
+	activeTokenFile  string // activeTokenFile is the token file in use, passed to Run to re-read if Jira rejects authentication mid-run
+	activeCredStore  string // activeCredStore is the -credstore credential in use, passed to Run to re-read if Jira rejects authentication mid-run
+	strictTokenFile  bool   // strictTokenFile is true when -stricttoken was provided, failing on group/other readable token files
+	tokenPassEnabled bool   // tokenPassEnabled is true when -tokenpass was provided, so the token file is decrypted
+	tokenPassphrase  []byte // tokenPassphrase is kept after the first prompt so the token file can be re-read without prompting again
+	logger           *log.Logger
+	problemsFileName string             // problemsFileName is the -problemsfile path; WARNING/ERROR messages are recorded when set
+	problemRecords   []ProblemRecord    // problemRecords holds the WARNING/ERROR messages recorded for the problems file
+	statsFileName    string             // statsFileName is the -statsfile path; run statistics are written there at exit when set
+	runLockFileName  string             // runLockFileName is the lock file this run created next to the output file, removed at exit
+	runStats         spillover.RunStats // runStats is the -statsfile document, filled in from Report.Stats once Run returns
+	startTime        time.Time          // startTime is when the program started, for the log file name and the execution time
+	enableLogging    bool               // Add flag to control logging
+	fileLogFormat    string             // fileLogFormat is the log file format: text (default) or json
+	consoleLogFormat string             // consoleLogFormat is the console log format: text (default, coloured) or json
+	enableDebug      bool               // Add flag to control debug output
+
+	logMutex        sync.Mutex               // logMutex serialises log writes, so concurrent callers never interleave lines or race on the logger
+	consoleLogLevel int                      // consoleLogLevel is the lowest logLevelRank printed to the console (-loglevel); the log file gets every message
+	consoleSink     func(level, line string) // consoleSink receives console log lines instead of standard output while the -tui screen is shown; called with logMutex held
+	activeTUI       *tuiScreen               // activeTUI is the -tui screen while it is shown, so cleanup can restore the terminal
+	debugFile       *os.File                 // debugFile receives the per-issue debug dumps with -debug (nil otherwise); written under logMutex
+	debugIssueKeys  map[string]bool          // debugIssueKeys are the -debugissues issues whose debug dumps are shown on the console
+	warningCount    int                      // warningCount counts the WARNING messages logged by the process, for the -statsfile; updated under logMutex
+
+	interactiveMode bool // interactiveMode is true when any parameter was prompted for, enabling the confirmation step
+	nonInteractive  bool // nonInteractive is true with -noninteractive or when stdin is not a terminal, so missing parameters fail or take their defaults instead of prompting
 )
 
 /********************************************************************************************************************************/
@@ -340,6 +651,75 @@ func initLogging() error {
 	return nil
 }
 
+/********************************************************************************************************************************/
+// initDebugFile creates the file the per-issue debug dumps are written to (only if debug output is enabled)
+//
+// Dumps such as each issue's raw sprint field run to many lines per issue, so on a large run they would hide
+// the progress messages on the console and swell the log file. The file is named like the log file:
+// jira-spillover-get-debug-YYYYMMDD-HHMMSS.txt
+//
+// Returns:
+//   error - any error encountered creating the file
+//
+// Side effects:
+//   - Creates the debug file in the current directory (only if enableDebug is true)
+//   - Logs the name of the file
+func initDebugFile() error {
+	if !enableDebug {
+		return nil
+	}
+	debugFileName := fmt.Sprintf("%s-debug-%s.txt", programName, startTime.Format("20060102-150405"))
+	file, err := os.Create(debugFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create debug file: %w", err)
+	}
+	logMutex.Lock()
+	debugFile = file
+	logMutex.Unlock()
+	writeLog("INFO", fmt.Sprintf("Debug details written to %s", debugFileName))
+	return nil
+}
+
+/********************************************************************************************************************************/
+// wantIssueDebug reports whether the per-issue debug dumps of an issue are written anywhere
+//
+// Parameters:
+//   issueKey - key of the issue
+//
+// Returns:
+//   bool - true with -debug, or when the issue is one of the -debugissues issues
+func wantIssueDebug(issueKey string) bool {
+	return enableDebug || debugIssueKeys[issueKey]
+}
+
+/********************************************************************************************************************************/
+// writeIssueDebug writes a per-issue debug dump to the debug file, and to the console for -debugissues issues
+//
+// Parameters:
+//   issueKey - key of the issue the dump describes
+//   message  - the dump, which may run to several lines
+//
+// Safe for concurrent use: the debug file is written under logMutex, like the log file.
+//
+// Side effects:
+//   - Appends the dump to the debug file (only with -debug)
+//   - Logs the dump at DEBUG level, shown on the console whatever -loglevel says (only for -debugissues issues)
+func writeIssueDebug(issueKey, message string) {
+	if debugIssueKeys[issueKey] {
+		writeLogWithContext("DEBUG", spillover.LogContext{Issue: issueKey}, message)
+	}
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if debugFile != nil {
+		if _, err := fmt.Fprintf(debugFile, "[%s] [DEBUG] %s\n", time.Now().Format("2006-01-02 15:04:05"), message); err != nil {
+			// Stop writing rather than fail the report over diagnostics
+			debugFile.Close()
+			debugFile = nil
+			fmt.Printf("\033[33mDebug file write failed, debug details stopped: %v\033[0m\n", err)
+		}
+	}
+}
+
 /********************************************************************************************************************************/
 // writeLog writes a log message to both console and log file
 //
@@ -354,26 +734,326 @@ func initLogging() error {
 //   - Prints formatted message to stdout with appropriate coloring
 //   - Writes plain text message to log file with timestamp (only if logging is enabled)
 func writeLog(level, message string) {
+	writeLogWithContext(level, spillover.LogContext{}, message)
+}
+
+/********************************************************************************************************************************/
+// writeLogWithContext writes a log message like writeLog, attaching structured fields
+//
+// In text format the fields do not change the message; in JSON format they are emitted as
+// separate fields. The issue, epic, or sprint key is also recorded alongside WARNING and ERROR
+// messages when -problemsfile is in use, so problems can be triaged per issue.
+//
+// Parameters:
+//   level      - log level ("INFO", "WARNING", "ERROR")
+//   logContext - structured fields for the message (zero value for none)
+//   message    - message to log
+//
+// Safe for concurrent use: messages are written one at a time under logMutex.
+//
+// Side effects:
+//   - Same as writeLog, except that console output below -loglevel is suppressed
+//   - Records WARNING and ERROR messages for writeProblemsFile (only if a problems file was requested)
+func writeLogWithContext(level string, logContext spillover.LogContext, message string) {
+	// One message at a time, so lines from concurrent callers stay whole
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
 	// Create timestamp for consistent formatting
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now()
+	timestamp := now.Format("2006-01-02 15:04:05")
 	logMessage := fmt.Sprintf("[%s] [%s] %s", timestamp, level, message)
 
-	// Print to console with appropriate colors based on log level
-	switch level {
-	case "INFO":
-		fmt.Println(logMessage) // Default color for info
-	case "WARNING":
-		fmt.Printf("\033[33m%s\033[0m\n", logMessage) // Yellow for warnings
-	case "ERROR":
-		fmt.Printf("\033[31m%s\033[0m\n", logMessage) // Red for errors
-	default:
-		fmt.Println(logMessage) // Default color for unknown levels
+	// JSON line built only when a destination uses it
+	jsonMessage := ""
+	if consoleLogFormat == "json" || (fileLogFormat == "json" && enableLogging && logger != nil) {
+		jsonMessage = formatJSONLogEntry(now, level, logContext, message)
+	}
+
+	// Print to console with appropriate colors based on log level, unless below -loglevel (-debugissues dumps are always shown)
+	consoleVisible := logLevelRank(level) >= consoleLogLevel || (level == "DEBUG" && logContext.Issue != "" && debugIssueKeys[logContext.Issue])
+	if consoleVisible && consoleSink != nil {
+		// The -tui screen owns the terminal and shows the line itself
+		if consoleLogFormat == "json" {
+			consoleSink(level, jsonMessage)
+		} else {
+			consoleSink(level, logMessage)
+		}
+	} else if consoleVisible && consoleLogFormat == "json" {
+		fmt.Println(jsonMessage)
+	} else if consoleVisible {
+		switch level {
+		case "INFO":
+			fmt.Println(logMessage) // Default color for info
+		case "WARNING":
+			fmt.Printf("\033[33m%s\033[0m\n", logMessage) // Yellow for warnings
+		case "ERROR":
+			fmt.Printf("\033[31m%s\033[0m\n", logMessage) // Red for errors
+		default:
+			fmt.Println(logMessage) // Default color for unknown levels
+		}
 	}
 
 	// Write to log file if logging is enabled
 	if enableLogging && logger != nil {
-		logger.Println(logMessage)
+		if fileLogFormat == "json" {
+			logger.Println(jsonMessage)
+		} else {
+			logger.Println(logMessage)
+		}
+	}
+
+	if level == "WARNING" {
+		warningCount++
+	}
+
+	// Record problems for the problems file if one was requested
+	if problemsFileName != "" && (level == "WARNING" || level == "ERROR") {
+		key := logContext.Issue
+		if key == "" {
+			key = logContext.Epic
+		}
+		if key == "" {
+			key = logContext.Sprint
+		}
+		problemRecords = append(problemRecords, ProblemRecord{Timestamp: now, Severity: level, Key: key, Message: message})
+	}
+}
+
+/********************************************************************************************************************************/
+// logLevelRank orders log levels for the -loglevel console threshold
+//
+// Parameters:
+//   level - log level ("DEBUG", "INFO", "WARNING", "ERROR"), case-insensitive
+//
+// Returns:
+//   int - 0 for DEBUG up to 3 for ERROR; unknown levels rank as INFO
+func logLevelRank(level string) int {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return 0
+	case "WARNING":
+		return 2
+	case "ERROR":
+		return 3
+	}
+	return 1
+}
+
+/********************************************************************************************************************************/
+// formatJSONLogEntry formats a log message as a single JSON object for log pipelines
+//
+// Parameters:
+//   now        - time the message was logged
+//   level      - log level
+//   logContext - structured fields (empty fields are omitted)
+//   message    - message to log
+//
+// Returns:
+//   string - JSON object without a trailing newline
+func formatJSONLogEntry(now time.Time, level string, logContext spillover.LogContext, message string) string {
+	entry := jsonLogEntry{
+		Timestamp: now.Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:     level,
+		Message:   message,
+		Issue:     logContext.Issue,
+		Epic:      logContext.Epic,
+		Sprint:    logContext.Sprint,
+		Batch:     logContext.Batch,
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		// Strings and ints always marshal, but never lose the message
+		return fmt.Sprintf(`{"ts":%q,"level":%q,"msg":%q}`, entry.Timestamp, level, message)
+	}
+	return string(encoded)
+}
+
+/********************************************************************************************************************************/
+// writeProblemsFile writes every recorded WARNING and ERROR to a tab-separated problems file
+//
+// Columns are timestamp, severity, key (issue/epic/sprint, when applicable) and message.
+// A count summary is printed to the console pointing at the file.
+//
+// Returns:
+//   error - any error encountered during file writing
+func writeProblemsFile() error {
+	file, err := os.Create(problemsFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create problems file: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
+		}
+	}()
+
+	// Tabs and line breaks inside messages would break the TSV layout
+	sanitizer := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+	// Copy the records under the log lock, as a late goroutine could still be logging
+	logMutex.Lock()
+	records := append([]ProblemRecord(nil), problemRecords...)
+	logMutex.Unlock()
+
+	warningCount, errorCount := 0, 0
+	if _, err := file.WriteString("Timestamp\tSeverity\tKey\tMessage\n"); err != nil {
+		return fmt.Errorf("failed to write problems header: %w", err)
+	}
+	for _, record := range records {
+		if record.Severity == "ERROR" {
+			errorCount++
+		} else {
+			warningCount++
+		}
+		row := []string{
+			record.Timestamp.Format("2006-01-02 15:04:05"),
+			record.Severity,
+			record.Key,
+			sanitizer.Replace(record.Message),
+		}
+		if _, err := file.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+			return fmt.Errorf("failed to write problems row: %w", err)
+		}
+	}
+
+	fmt.Printf("Run completed with %d warnings and %d errors - see %s\n", warningCount, errorCount, problemsFileName)
+	return nil
+}
+
+/********************************************************************************************************************************/
+// writeStatsFile writes the -statsfile JSON document (see RunStats)
+//
+// Returns:
+//   error - any error encountered during encoding or file writing
+func writeStatsFile() error {
+	runStats.Program = programName
+	runStats.Version = programVersion
+	runStats.DurationSeconds = time.Since(startTime).Seconds()
+	logMutex.Lock()
+	runStats.WarningCount = warningCount
+	logMutex.Unlock()
+
+	// Keep JQL operators such as >= readable rather than HTML-escaped
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(runStats); err != nil {
+		return fmt.Errorf("failed to encode run statistics: %w", err)
+	}
+	if err := spillover.ValidateJSONDocument("stats", encoded.Bytes()); err != nil {
+		return err
+	}
+	if err := os.WriteFile(statsFileName, encoded.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// archiveRunOutputs copies the run's output files into a dated directory under the archive directory (-archivedir)
+//
+// Files are copied, not moved, into {archiveDir}/{project}/{yyyy-mm-dd-hhmmss}/ named after the run's start
+// time. A file that cannot be copied is a warning, not a failure. With retentionDays, the project's archive
+// directories older than that many days are then removed.
+//
+// Parameters:
+//   archiveDir    - root of the archive
+//   projectKey    - project the run reported on ("no-project" is used when empty, e.g. with -keysfile)
+//   files         - paths of the files to copy
+//   retentionDays - age in days beyond which archive directories are removed (0 keeps everything)
+//
+// Side effects:
+//   - Creates the archive directories and copies the files
+//   - Logs the archive location and each directory removed
+func archiveRunOutputs(archiveDir, projectKey string, files []string, retentionDays int) {
+	if projectKey == "" {
+		projectKey = "no-project"
+	}
+	projectDir := filepath.Join(archiveDir, projectKey)
+	runDir := filepath.Join(projectDir, startTime.Format(archiveDirLayout))
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		writeLog("WARNING", fmt.Sprintf("Failed to create archive directory %s: %v", runDir, err))
+		return
+	}
+
+	copied := 0
+	for _, file := range files {
+		if err := copyFile(file, filepath.Join(runDir, filepath.Base(file))); err != nil {
+			writeLog("WARNING", fmt.Sprintf("Failed to archive %s: %v", file, err))
+			continue
+		}
+		copied++
+	}
+	writeLog("INFO", fmt.Sprintf("Archived %d of %d output files to %s", copied, len(files), runDir))
+
+	if retentionDays > 0 {
+		pruneArchive(projectDir, retentionDays, time.Now())
+	}
+}
+
+/***********************************************************************************************************************************/
+// pruneArchive removes a project's archive directories older than the retention period (-retentiondays)
+//
+// Only directories whose name is an archive timestamp (yyyy-mm-dd-hhmmss) are considered; any other file or
+// directory is left alone. A directory's age is taken from its name.
+//
+// Parameters:
+//   projectDir    - the project's archive directory
+//   retentionDays - age in days beyond which archive directories are removed
+//   now           - current time
+//
+// Side effects:
+//   - Removes expired archive directories, logging each one
+func pruneArchive(projectDir string, retentionDays int, now time.Time) {
+	entries, err := os.ReadDir(projectDir)
+	if err != nil {
+		writeLog("WARNING", fmt.Sprintf("Failed to read archive directory %s: %v", projectDir, err))
+		return
+	}
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	for _, entry := range entries {
+		if !entry.IsDir() || !archiveDirPattern.MatchString(entry.Name()) {
+			continue
+		}
+		archivedAt, err := time.ParseInLocation(archiveDirLayout, entry.Name(), time.Local)
+		if err != nil || !archivedAt.Before(cutoff) {
+			continue
+		}
+		expiredDir := filepath.Join(projectDir, entry.Name())
+		if err := os.RemoveAll(expiredDir); err != nil {
+			writeLog("WARNING", fmt.Sprintf("Failed to remove expired archive %s: %v", expiredDir, err))
+			continue
+		}
+		writeLog("INFO", fmt.Sprintf("Removed archive %s, older than %d days (-retentiondays)", expiredDir, retentionDays))
+	}
+}
+
+/***********************************************************************************************************************************/
+// copyFile copies a file's contents to a new file
+//
+// Parameters:
+//   source      - file to copy
+//   destination - path of the copy (overwritten if it exists)
+//
+// Returns:
+//   error - any error reading the source or writing the copy
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
 	}
+	return out.Close()
 }
 
 /********************************************************************************************************************************/
@@ -397,10 +1077,18 @@ func writeLog(level, message string) {
 //   username@company.com:abc123def456ghi789
 func readTokenFile(tokenFilePath string) (string, error) {
 	// Check if file exists
-	if _, err := os.Stat(tokenFilePath); os.IsNotExist(err) {
+	info, err := os.Stat(tokenFilePath)
+	if os.IsNotExist(err) {
 		return "", fmt.Errorf("token file not found: %s", tokenFilePath)
 	}
 
+	// Check the token file is not readable by other users
+	if err == nil {
+		if err := checkTokenFilePermissions(tokenFilePath, info); err != nil {
+			return "", err
+		}
+	}
+
 	// Read file content
 	content, err := os.ReadFile(tokenFilePath)
 	if err != nil {
@@ -410,11 +1098,41 @@ func readTokenFile(tokenFilePath string) (string, error) {
 	// Convert to string and trim whitespace
 	tokenString := strings.TrimSpace(string(content))
 
+	// Decrypt token files written by -encrypttoken
+	if strings.HasPrefix(tokenString, encryptedTokenPrefix) {
+		if !tokenPassEnabled {
+			return "", fmt.Errorf("token file %s is encrypted, add -tokenpass to be prompted for its passphrase", tokenFilePath)
+		}
+		if tokenPassphrase == nil {
+			if tokenPassphrase, err = promptPassphrase("Enter the token file passphrase: "); err != nil {
+				return "", err
+			}
+		}
+		if tokenString, err = decryptToken(tokenString, tokenPassphrase); err != nil {
+			tokenPassphrase = nil
+			return "", err
+		}
+	} else if tokenPassEnabled {
+		return "", fmt.Errorf("-tokenpass was given but token file %s is not encrypted (create one with -encrypttoken)", tokenFilePath)
+	}
+
 	// Validate that token is not empty
 	if tokenString == "" {
 		return "", fmt.Errorf("token file is empty")
 	}
 
+	return encodeAuthToken(tokenString), nil
+}
+
+/***********************************************************************************************************************************/
+// encodeAuthToken encodes "username:api-token" credentials for HTTP Basic Authentication
+//
+// Parameters:
+//   tokenString - credentials in "username:api-token" form (never logged)
+//
+// Returns:
+//   string - Base64 encoded token string for use in Authorization headers
+func encodeAuthToken(tokenString string) string {
 	// Validate format (should contain colon separator)
 	if !strings.Contains(tokenString, ":") {
 		writeLog("WARNING", "API token might not be in expected format (username:token)")
@@ -423,242 +1141,580 @@ func readTokenFile(tokenFilePath string) (string, error) {
 	// Encode as Base64 for HTTP Basic Authentication
 	encoded := base64.StdEncoding.EncodeToString([]byte(tokenString))
 	writeLog("INFO", "Successfully read and encoded API token")
-
-	return encoded, nil
+	return encoded
 }
 
 /***********************************************************************************************************************************/
-// getJiraBaseURL gets the Jira base URL from command line arguments or prompts user
+// checkTokenFilePermissions warns, or fails with -stricttoken, when a token file is readable by group or other users
 //
-// This function implements a flexible URL acquisition strategy:
-// 1. First checks command line arguments for -url or -URL parameter (case-insensitive)
-// 2. If found, validates and uses the provided URL
-// 3. If not found, prompts the user interactively for the URL
-// 4. Performs URL cleanup by removing trailing slashes
-// 5. Validates that a URL was provided (exits program if empty)
+// Unix permission bits are not meaningful on Windows, where the check is skipped (see the icacls
+// instructions in the README instead).
 //
-// Parameters: None (reads from os.Args and stdin)
+// Parameters:
+//   tokenFilePath - path to the token file
+//   info          - file information from os.Stat
 //
 // Returns:
-//   string - validated Jira base URL (without trailing slash)
-//
-// Side effects:
-//   - May prompt user for input via stdin
-//   - May call os.Exit(1) if URL validation fails
-//   - Prints status messages to stdout
-func getJiraBaseURL() string {
-	// Check command line arguments for URL parameter
-	args := os.Args[1:]
-	for i, arg := range args {
-		if strings.ToLower(arg) == "-url" && i+1 < len(args) {
-			url := strings.TrimSpace(args[i+1])
-			if url != "" {
-				writeLog("INFO", fmt.Sprintf("Using Jira base URL from command line: %s", url))
-				return strings.TrimRight(url, "/")
-			}
-		}
+//   error - permission error when -stricttoken is set and the file is group/other readable, otherwise nil
+func checkTokenFilePermissions(tokenFilePath string, info os.FileInfo) error {
+	if runtime.GOOS == "windows" {
+		return nil
 	}
 
-	// Prompt user for URL if not found in command line
-	fmt.Print("Enter the Jira base URL (e.g., https://jira.company.com): ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		url := strings.TrimSpace(scanner.Text())
-		if url == "" {
-			writeLog("ERROR", "Jira base URL is required")
-			os.Exit(1)
-		}
-		writeLog("INFO", fmt.Sprintf("Using Jira base URL from user input: %s", url))
-		return strings.TrimRight(url, "/")
+	mode := info.Mode().Perm()
+	if mode&0044 == 0 {
+		return nil
 	}
 
-	writeLog("ERROR", "Failed to read Jira base URL")
-	os.Exit(1)
-	return ""
+	message := fmt.Sprintf("token file %s is readable by group/other users (mode %04o), restrict it with: chmod 600 %s", tokenFilePath, mode, tokenFilePath)
+	if strictTokenFile {
+		return errors.New(message)
+	}
+	writeLog("WARNING", message)
+	return nil
 }
 
 /***********************************************************************************************************************************/
-// getAuthToken gets the authentication token from command line or prompts user
+// promptPassphrase reads a passphrase from the terminal without echoing it
 //
-// This function implements a flexible authentication token acquisition strategy:
-// 1. First checks command line arguments for -TokenFile or -tokenfile parameter (case-insensitive)
-// 2. If found, attempts to read the specified token file
-// 3. If not found, prompts the user interactively for the token file path
-// 4. Delegates to readTokenFile() for actual file reading and validation
-// 5. Returns the Base64 encoded token ready for HTTP Basic Authentication
+// When stdin is not a terminal (e.g., piped in a scheduled job) a single line is read instead.
 //
-// Parameters: None (reads from os.Args and stdin)
+// Parameters:
+//   prompt - text to display before reading
 //
 // Returns:
-//   string - Base64 encoded authentication token
-//   error  - any error from file reading, validation, or user input
-//
-// Side effects:
-//   - May prompt user for input via stdin
-//   - Prints status messages to stdout indicating token file source
-func getAuthToken() (string, error) {
-	// Check command line arguments for token file parameter
-	args := os.Args[1:]
-	for i, arg := range args {
-		if strings.ToLower(arg) == "-tokenfile" && i+1 < len(args) {
-			tokenFile := strings.TrimSpace(args[i+1])
-			if tokenFile != "" {
-				writeLog("INFO", fmt.Sprintf("Using token file from command line: %s", tokenFile))
-				return readTokenFile(tokenFile)
-			}
+//   []byte - passphrase (never empty)
+//   error  - any error reading the passphrase, or an empty passphrase
+func promptPassphrase(prompt string) ([]byte, error) {
+	fmt.Print(prompt)
+
+	var passphrase []byte
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		input, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase: %w", err)
 		}
+		passphrase = input
+	} else {
+		passphrase = []byte(readUnbufferedLine())
 	}
 
-	// Prompt user for token file path if not found in command line
-	fmt.Print("Enter the path to your Jira API token file: ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		tokenFile := strings.TrimSpace(scanner.Text())
-		if tokenFile == "" {
-			return "", fmt.Errorf("token file path is required")
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase is required")
+	}
+	return passphrase, nil
+}
+
+/***********************************************************************************************************************************/
+// readUnbufferedLine reads one line from stdin
+//
+// Stdin is read byte by byte so input meant for later prompts is not buffered away.
+//
+// Returns:
+//   string - the line without its line ending
+func readUnbufferedLine() string {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil || buf[0] == '\n' {
+			break
 		}
-		writeLog("INFO", fmt.Sprintf("Using token file from user input: %s", tokenFile))
-		return readTokenFile(tokenFile)
+		line = append(line, buf[0])
 	}
+	return strings.TrimRight(string(line), "\r")
+}
 
-	return "", fmt.Errorf("failed to read token file path")
+/***********************************************************************************************************************************/
+// deriveTokenKey derives an AES-256 key from a passphrase with scrypt
+//
+// Parameters:
+//   passphrase - user supplied passphrase
+//   salt       - random salt stored in the encrypted token file
+//
+// Returns:
+//   []byte - 32 byte key
+//   error  - any error from key derivation
+func deriveTokenKey(passphrase, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token file key: %w", err)
+	}
+	return key, nil
 }
 
 /***********************************************************************************************************************************/
-// getProjectFromCommandLine checks for -project parameter in command line arguments
+// encryptToken encrypts token file content with AES-GCM under a passphrase-derived key
 //
-// This function scans command line arguments for a -project parameter and returns
-// the specified project code if found. Supports case-insensitive parameter matching.
+// Parameters:
+//   plaintext  - token file content (username:api-token)
+//   passphrase - user supplied passphrase
+//
+// Returns:
+//   string - encrypted token file content, starting with encryptedTokenPrefix
+//   error  - any error during key derivation or encryption
+func encryptToken(plaintext string, passphrase []byte) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := deriveTokenKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	payload := append(append(salt, nonce...), sealed...)
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+/***********************************************************************************************************************************/
+// decryptToken decrypts token file content written by encryptToken
+//
+// Errors never include any part of the file content or passphrase.
+//
+// Parameters:
+//   content    - encrypted token file content (starting with encryptedTokenPrefix)
+//   passphrase - user supplied passphrase
+//
+// Returns:
+//   string - decrypted token file content
+//   error  - a generic error if the file is malformed or the passphrase is wrong
+func decryptToken(content string, passphrase []byte) (string, error) {
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, encryptedTokenPrefix))
+	if err != nil || len(payload) < 16+12+16 {
+		return "", fmt.Errorf("encrypted token file is malformed")
+	}
+
+	key, err := deriveTokenKey(passphrase, payload[:16])
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	plaintext, err := gcm.Open(nil, payload[16:16+gcm.NonceSize()], payload[16+gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token file: wrong passphrase or corrupted file")
+	}
+	return strings.TrimSpace(string(plaintext)), nil
+}
+
+/***********************************************************************************************************************************/
+// encryptTokenFile implements -encrypttoken: reads a plaintext token file and writes its encrypted form alongside it
 //
-// Parameters: None (reads from os.Args)
+// Parameters:
+//   plainPath - path to the plaintext token file; the encrypted file is written to plainPath + ".enc"
 //
 // Returns:
-//   string - project code from command line (uppercase), or empty string if not found
+//   string - path of the encrypted token file
+//   error  - any error reading, encrypting, or writing
 //
 // Side effects:
-//   - Prints status message if project parameter is found
-func getProjectFromCommandLine() string {
-	args := os.Args[1:]
-	for i, arg := range args {
-		if strings.ToLower(arg) == "-project" && i+1 < len(args) {
-			project := strings.TrimSpace(strings.ToUpper(args[i+1]))
-			if project != "" {
-				writeLog("INFO", fmt.Sprintf("Using project key from command line: %s", project))
-				return project
-			}
-		}
+//   - Prompts twice for the passphrase
+//   - Creates the .enc file readable only by the current user
+func encryptTokenFile(plainPath string) (string, error) {
+	content, err := os.ReadFile(plainPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
 	}
-	return ""
+	plaintext := strings.TrimSpace(string(content))
+	if plaintext == "" {
+		return "", fmt.Errorf("token file is empty")
+	}
+	if strings.HasPrefix(plaintext, encryptedTokenPrefix) {
+		return "", fmt.Errorf("token file %s is already encrypted", plainPath)
+	}
+
+	passphrase, err := promptPassphrase("Enter a passphrase for the encrypted token file: ")
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := promptPassphrase("Enter the passphrase again: ")
+	if err != nil {
+		return "", err
+	}
+	if string(passphrase) != string(confirmation) {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+
+	encrypted, err := encryptToken(plaintext, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	encryptedPath := plainPath + ".enc"
+	if err := os.WriteFile(encryptedPath, []byte(encrypted+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write encrypted token file: %w", err)
+	}
+	return encryptedPath, nil
 }
 
 /***********************************************************************************************************************************/
-// getDateAndDaysFromCommandLine checks for date and days prior parameters in command line arguments
+// readCredentialStore reads Jira credentials from the OS credential store (-credstore)
 //
-// This function scans command line arguments for -fromdate and -daysprior parameters
-// and returns them if found. Supports case-insensitive parameter matching.
+// Windows reads the generic credential with this target name from Credential Manager, macOS the
+// generic password with this service name from the login Keychain (security CLI), and Linux the
+// secret with attribute service=name through libsecret's secret-tool. The secret is either
+// "username:api-token", or the API token alone with the username held in the credential's own
+// user name (Windows) or account (macOS) field.
 //
-// Parameters: None (reads from os.Args)
+// Parameters:
+//   ctx  - context whose cancellation stops the credential store tool
+//   name - credential target (Windows) or service (macOS, Linux) name
 //
 // Returns:
-//   fromDate - from date from command line (yyyy-mm-dd format), or empty string if not found
-//   daysPrior - days prior from command line, or 0 if not found
-//   fromDateProvided - true if fromdate parameter was explicitly provided on command line
-//   daysPriorProvided - true if daysprior parameter was explicitly provided on command line
+//   string - Base64 encoded token string for use in Authorization headers
+//   error  - an unsupported platform, a missing store tool or credential, or an empty secret (the secret is never included)
 //
 // Side effects:
-//   - Prints status messages if parameters are found
-func getDateAndDaysFromCommandLine() (string, int, bool, bool) {
-	args := os.Args[1:]
-	var fromDate string
-	var daysPrior int
-	var fromDateProvided, daysPriorProvided bool
-
-	for i, arg := range args {
-		switch strings.ToLower(arg) {
-		case "-fromdate":
-			if i+1 < len(args) {
-				fromDate = strings.TrimSpace(args[i+1])
-				if fromDate != "" {
-					fromDateProvided = true
-					writeLog("INFO", fmt.Sprintf("Using from date from command line: %s", fromDate))
-				}
+//   - Runs powershell, security, or secret-tool
+func readCredentialStore(ctx context.Context, name string) (string, error) {
+	var user, secret string
+	switch runtime.GOOS {
+	case "windows":
+		command := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsCredentialRead)
+		command.Env = append(os.Environ(), "JSG_CREDENTIAL_TARGET="+name)
+		output, err := runCredentialCommand(command)
+		if err != nil {
+			return "", err
+		}
+		user, secret, _ = strings.Cut(strings.ReplaceAll(output, "\r", ""), "\n")
+	case "darwin":
+		output, err := runCredentialCommand(exec.CommandContext(ctx, "security", "find-generic-password", "-s", name, "-w"))
+		if err != nil {
+			return "", err
+		}
+		secret = output
+		// The account is the username when the secret is the API token alone
+		if !strings.Contains(secret, ":") {
+			attributes, err := runCredentialCommand(exec.CommandContext(ctx, "security", "find-generic-password", "-s", name))
+			if err != nil {
+				return "", err
 			}
-		case "-daysprior":
-			if i+1 < len(args) {
-				if days, err := strconv.Atoi(strings.TrimSpace(args[i+1])); err == nil {
-					daysPrior = days
-					daysPriorProvided = true
-					writeLog("INFO", fmt.Sprintf("Using days prior from command line: %d", daysPrior))
-				}
+			if match := regexp.MustCompile(`"acct"<blob>="([^"]*)"`).FindStringSubmatch(attributes); match != nil {
+				user = match[1]
 			}
 		}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return "", fmt.Errorf("-credstore needs libsecret's secret-tool on %s (install libsecret-tools), or use -tokenfile", runtime.GOOS)
+		}
+		output, err := runCredentialCommand(exec.CommandContext(ctx, "secret-tool", "lookup", "service", name))
+		if err != nil {
+			return "", err
+		}
+		secret = output
+	default:
+		return "", fmt.Errorf("-credstore is not supported on %s, use -tokenfile", runtime.GOOS)
 	}
 
-	return fromDate, daysPrior, fromDateProvided, daysPriorProvided
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("credential '%s' was not found in the credential store or is empty", name)
+	}
+	if user = strings.TrimSpace(user); user != "" && !strings.HasPrefix(secret, user+":") {
+		secret = user + ":" + secret
+	}
+	return encodeAuthToken(secret), nil
+}
+
+/***********************************************************************************************************************************/
+// runCredentialCommand runs a credential store command and returns its output
+//
+// Parameters:
+//   command - powershell, security, or secret-tool command
+//
+// Returns:
+//   string - standard output (may hold the secret, so it is never logged)
+//   error  - any error running the command, with its standard error output
+func runCredentialCommand(command *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s failed: %s", filepath.Base(command.Path), message)
+		}
+		return "", fmt.Errorf("%s failed: %w", filepath.Base(command.Path), err)
+	}
+	return string(output), nil
 }
 
 /***********************************************************************************************************************************/
-// getOutputFileFromCommandLine checks for -outputfile parameter in command line arguments
+// storeCredential implements -storecred: prompts for a username and API token and saves them in the OS credential store
 //
-// This function scans command line arguments for a -outputfile parameter and returns
-// the specified filename if found. Supports case-insensitive parameter matching.
+// Windows saves a generic credential (user name and token) to Credential Manager, macOS a generic
+// password (account and token) to the login Keychain, and Linux the secret "username:api-token"
+// through secret-tool. The token is passed to the store on standard input, never as an argument.
 //
-// Parameters: None (reads from os.Args)
+// Parameters:
+//   ctx  - context whose cancellation stops the credential store tool
+//   name - credential target (Windows) or service (macOS, Linux) name, as later given to -credstore
 //
 // Returns:
-//   string - output filename from command line, or empty string if not found
+//   error - an unsupported platform, a missing store tool, mismatched tokens, or any error saving
 //
 // Side effects:
-//   - Prints status message if parameter is found
-func getOutputFileFromCommandLine() string {
-	args := os.Args[1:]
-	for i, arg := range args {
-		if strings.ToLower(arg) == "-outputfile" && i+1 < len(args) {
-			outputFile := strings.TrimSpace(args[i+1])
-			if outputFile != "" {
-				writeLog("INFO", fmt.Sprintf("Using output file from command line: %s", outputFile))
-				return outputFile
+//   - Prompts for the username, then twice for the API token without echo
+//   - Runs powershell, security, or secret-tool
+func storeCredential(ctx context.Context, name string) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "windows", "darwin":
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return fmt.Errorf("-storecred needs libsecret's secret-tool on %s (install libsecret-tools)", runtime.GOOS)
+		}
+	default:
+		return fmt.Errorf("-storecred is not supported on %s, use a token file", runtime.GOOS)
+	}
+
+	fmt.Print("Enter your Jira username (e.g., email address): ")
+	user := strings.TrimSpace(readUnbufferedLine())
+	if user == "" {
+		return fmt.Errorf("username is required")
+	}
+	token, err := promptPassphrase("Enter the Jira API token: ")
+	if err != nil {
+		return fmt.Errorf("API token is required")
+	}
+	confirmation, err := promptPassphrase("Enter the API token again: ")
+	if err != nil || string(token) != string(confirmation) {
+		return fmt.Errorf("API tokens do not match")
+	}
+
+	var input string
+	switch runtime.GOOS {
+	case "windows":
+		command = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsCredentialWrite)
+		command.Env = append(os.Environ(), "JSG_CREDENTIAL_TARGET="+name, "JSG_CREDENTIAL_USER="+user)
+		input = string(token) + "\n"
+	case "darwin":
+		// security -i reads the command from stdin, keeping the token out of the process list
+		for _, value := range []string{name, user, string(token)} {
+			if strings.ContainsAny(value, "\"\\\n") {
+				return fmt.Errorf("the credential name, username and token cannot contain quotes or backslashes on macOS")
 			}
 		}
+		command = exec.CommandContext(ctx, "security", "-i")
+		input = fmt.Sprintf("add-generic-password -U -s \"%s\" -a \"%s\" -l \"%s\" -w \"%s\"\n", name, user, name, string(token))
+	default:
+		command = exec.CommandContext(ctx, "secret-tool", "store", "--label=Jira API token ("+name+")", "service", name)
+		input = user + ":" + string(token)
 	}
-	return ""
+	command.Stdin = strings.NewReader(input)
+	_, err = runCredentialCommand(command)
+	return err
+}
+
+/***********************************************************************************************************************************/
+// defaultInstanceLabel chooses the Instance column label for a Jira base URL with no -instancelabel
+//
+// Parameters:
+//   jiraBaseURL - Jira base URL
+//
+// Returns:
+//   string - "cloud" for Atlassian Cloud sites, "server" otherwise
+func defaultInstanceLabel(jiraBaseURL string) string {
+	if spillover.IsCloudURL(jiraBaseURL) {
+		return "cloud"
+	}
+	return "server"
+}
+
+/***********************************************************************************************************************************/
+// requirePrompt exits with exitCodeInvalidArgs instead of prompting when running non-interactively
+//
+// A scheduled task has nobody to answer a prompt, so waiting on stdin would hang it until killed.
+//
+// Parameters:
+//   parameter - description of the missing value (e.g., "Jira base URL")
+//   flag      - command line parameter that supplies it
+//
+// Side effects:
+//   - In non-interactive mode, logs an ERROR naming the parameter and exits the program
+func requirePrompt(parameter, flag string) {
+	if !nonInteractive {
+		return
+	}
+	writeLog("ERROR", fmt.Sprintf("Missing %s: supply it with %s (prompting is disabled in non-interactive mode)", parameter, flag))
+	exitProgram(exitCodeInvalidArgs)
+}
+
+/***********************************************************************************************************************************/
+// normalizeJiraBaseURL turns a pasted Jira URL into the base URL that REST paths are appended to
+//
+// A context path is kept (https://host/jira), while a page path copied from the browser is removed
+// (https://host/jira/secure/Dashboard.jspa or https://host/browse/EXPD-1 give https://host/jira and
+// https://host), as are any query string, fragment, and trailing slashes. Errors never include the URL,
+// as it may contain credentials.
+//
+// Parameters:
+//   rawURL - URL as given on the command line or typed at the prompt
+//
+// Returns:
+//   string - normalised base URL (scheme://host[:port][/context])
+//   error  - unparseable URL, scheme other than http/https, missing host, or embedded credentials
+func normalizeJiraBaseURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("the URL could not be parsed, expected e.g. https://jira.company.com")
+	}
+	if parsed.User != nil {
+		return "", fmt.Errorf("the URL must not contain a username or password, use -TokenFile for credentials")
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("the URL must start with https:// or http://, e.g. https://jira.company.com")
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("the URL has no host name, expected e.g. https://jira.company.com")
+	}
+
+	// Cut the path where a Jira page or API path starts; Cloud sites never have a context path
+	contextPath := parsed.Path
+	if location := jiraPagePathRegex.FindStringIndex(contextPath); location != nil {
+		contextPath = contextPath[:location[0]]
+	}
+	if spillover.IsCloudURL(scheme+"://"+parsed.Host) && !strings.EqualFold(parsed.Hostname(), "api.atlassian.com") {
+		contextPath = ""
+	}
+	contextPath = strings.TrimRight(contextPath, "/")
+
+	return scheme + "://" + strings.ToLower(parsed.Host) + contextPath, nil
 }
 
 /***********************************************************************************************************************************/
-// getAppendFlagFromCommandLine checks for -append parameter in command line arguments
+// getJiraBaseURL gets the Jira base URL from command line arguments or prompts user
 //
-// This function scans command line arguments for a -append parameter and returns
-// true if found, enabling append mode for output file.
+// This function implements a flexible URL acquisition strategy:
+// 1. First checks the -url values read from the command line (commandLine.URLs)
+// 2. If found, validates and uses the provided URL
+// 3. If not found, prompts the user interactively for the URL
+// 4. Normalises the URL with normalizeJiraBaseURL (context path kept, browser page paths removed)
+// 5. Validates that a URL was provided (exits program if empty)
 //
-// Parameters: None (reads from os.Args)
+// Parameters: None (uses commandLine and reads from stdin)
 //
 // Returns:
-//   bool - true if -append flag is present, false otherwise
+//   string - validated Jira base URL (without trailing slash)
 //
 // Side effects:
-//   - Prints status message if append flag is found
-func getAppendFlagFromCommandLine() bool {
-	args := os.Args[1:]
-	for _, arg := range args {
-		if strings.ToLower(arg) == "-append" {
-			writeLog("INFO", "Append mode enabled from command line")
-			return true
+//   - May prompt user for input via stdin
+//   - May call exitProgram(1) if URL validation fails, or exitProgram(exitCodeInvalidArgs) if non-interactive
+//   - Prints status messages to stdout
+func getJiraBaseURL() string {
+	// Check command line arguments for URL parameter
+	if rawURL, ok := firstNonBlank(commandLine.URLs); ok {
+		jiraBaseURL, err := normalizeJiraBaseURL(rawURL)
+		if err != nil {
+			writeLog("ERROR", fmt.Sprintf("Invalid -url: %v", err))
+			exitProgram(1)
 		}
+		writeLog("INFO", fmt.Sprintf("Using Jira base URL from command line: %s", jiraBaseURL))
+		return jiraBaseURL
 	}
-	return false
+
+	// Prompt user for URL if not found in command line
+	requirePrompt("Jira base URL", "-url")
+	interactiveMode = true
+	fmt.Print("Enter the Jira base URL (e.g., https://jira.company.com): ")
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		rawURL := strings.TrimSpace(scanner.Text())
+		if rawURL == "" {
+			writeLog("ERROR", "Jira base URL is required")
+			exitProgram(1)
+		}
+		jiraBaseURL, err := normalizeJiraBaseURL(rawURL)
+		if err != nil {
+			writeLog("ERROR", fmt.Sprintf("Invalid Jira base URL: %v", err))
+			exitProgram(1)
+		}
+		writeLog("INFO", fmt.Sprintf("Using Jira base URL from user input: %s", jiraBaseURL))
+		return jiraBaseURL
+	}
+
+	writeLog("ERROR", "Failed to read Jira base URL")
+	exitProgram(1)
+	return ""
 }
 
 /***********************************************************************************************************************************/
-// getDebugFlagFromCommandLine checks for -debug parameter in command line arguments
-func getDebugFlagFromCommandLine() bool {
-	args := os.Args[1:]
-	for _, arg := range args {
-		if strings.ToLower(arg) == "-debug" {
-			fmt.Println("Debug output enabled from command line")
-			return true
+// getAuthToken gets the authentication token from command line or prompts user
+//
+// This function implements a flexible authentication token acquisition strategy:
+// 1. First checks command line arguments for -credstore, reading the credentials from the OS credential store
+// 2. Then checks the -tokenfile values read from the command line (commandLine.TokenFiles) and reads the first one
+// 3. If not found, prompts the user interactively for the token file path
+// 4. Delegates to readTokenFile() for actual file reading and validation
+// 5. Returns the Base64 encoded token ready for HTTP Basic Authentication
+//
+// Parameters:
+//   ctx - context whose cancellation stops the credential store tool
+//
+// Returns:
+//   string - Base64 encoded authentication token
+//   error  - any error from file reading, validation, or user input
+//
+// Side effects:
+//   - May prompt user for input via stdin (exits with exitCodeInvalidArgs instead if non-interactive)
+//   - Prints status messages to stdout indicating token file source
+//   - Sets activeTokenFile or activeCredStore to the token file or credential read
+func getAuthToken(ctx context.Context) (string, error) {
+	// Read the credentials from the OS credential store instead of a file (-credstore)
+	if name := commandLine.CredStore; name != "" {
+		if len(commandLine.TokenFiles) > 0 {
+			return "", fmt.Errorf("-credstore and -tokenfile cannot be used together")
 		}
+		activeCredStore = name
+		return readCredentialStore(ctx, name)
 	}
-	return false
+
+	// Check command line arguments for token file parameter
+	if tokenFile, ok := firstNonBlank(commandLine.TokenFiles); ok {
+		writeLog("INFO", fmt.Sprintf("Using token file from command line: %s", tokenFile))
+		activeTokenFile = tokenFile
+		return readTokenFile(tokenFile)
+	}
+
+	// Prompt user for token file path if not found in command line
+	requirePrompt("token file", "-tokenfile (or -credstore or -oauthconfig)")
+	interactiveMode = true
+	fmt.Print("Enter the path to your Jira API token file: ")
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		tokenFile := strings.TrimSpace(scanner.Text())
+		if tokenFile == "" {
+			return "", fmt.Errorf("token file path is required")
+		}
+		writeLog("INFO", fmt.Sprintf("Using token file from user input: %s", tokenFile))
+		activeTokenFile = tokenFile
+		return readTokenFile(tokenFile)
+	}
+
+	return "", fmt.Errorf("failed to read token file path")
 }
 
 /***********************************************************************************************************************************/
@@ -674,9 +1730,11 @@ func getDebugFlagFromCommandLine() bool {
 //   error  - any error encountered during user input reading
 //
 // Side effects:
-//   - Prompts user for input via stdin
+//   - Prompts user for input via stdin (exits with exitCodeInvalidArgs instead if non-interactive)
 //   - Prints status message when project key is entered
 func getProjectKeyInteractively() (string, error) {
+	requirePrompt("project key", "-project (or -keysfile)")
+	interactiveMode = true
 	fmt.Print("Enter the Jira Project ID (e.g., EXPD): ")
 	scanner := bufio.NewScanner(os.Stdin)
 	if scanner.Scan() {
@@ -704,9 +1762,14 @@ func getProjectKeyInteractively() (string, error) {
 //   error - any error encountered during user input reading
 //
 // Side effects:
-//   - Prompts user for input via stdin
+//   - Prompts user for input via stdin (uses the default instead if non-interactive)
 //   - Prints status messages when parameters are entered or left blank
 func getDateRangeInteractively() (string, int, error) {
+	if nonInteractive {
+		writeLog("INFO", fmt.Sprintf("Using default days prior: %d (no -daysprior or -fromdate given)", spillover.DefaultDaysPrior))
+		return "", spillover.DefaultDaysPrior, nil
+	}
+	interactiveMode = true
 	fmt.Print("Enter a specific date to check from (yyyy-mm-dd), or leave blank: ")
 	scanner := bufio.NewScanner(os.Stdin)
 	if scanner.Scan() {
@@ -717,7 +1780,7 @@ func getDateRangeInteractively() (string, int, error) {
 		}
 	}
 
-	fmt.Printf("Enter number of days prior to check from (default = %d): ", defaultDaysPrior)
+	fmt.Printf("Enter number of days prior to check from (default = %d): ", spillover.DefaultDaysPrior)
 	if scanner.Scan() {
 		daysInput := strings.TrimSpace(scanner.Text())
 		if daysInput != "" {
@@ -725,13 +1788,13 @@ func getDateRangeInteractively() (string, int, error) {
 				writeLog("INFO", fmt.Sprintf("Using days prior from user input: %d", days))
 				return "", days, nil
 			} else {
-				writeLog("WARNING", fmt.Sprintf("Invalid input '%s'. Using default of %d days", daysInput, defaultDaysPrior))
+				writeLog("WARNING", fmt.Sprintf("Invalid input '%s'. Using default of %d days", daysInput, spillover.DefaultDaysPrior))
 			}
 		}
 	}
 
-	writeLog("INFO", fmt.Sprintf("Using default days prior: %d", defaultDaysPrior))
-	return "", defaultDaysPrior, nil
+	writeLog("INFO", fmt.Sprintf("Using default days prior: %d", spillover.DefaultDaysPrior))
+	return "", spillover.DefaultDaysPrior, nil
 }
 
 /***********************************************************************************************************************************/
@@ -747,10 +1810,15 @@ func getDateRangeInteractively() (string, int, error) {
 //   error - any error encountered during user input reading
 //
 // Side effects:
-//   - Prompts user for input via stdin
+//   - Prompts user for input via stdin (uses the default instead if non-interactive)
 //   - Prints status message when filename is entered or default is used
 func getOutputFileInteractively() (string, error) {
-	fmt.Print("Enter the filename to save the results (default *overwrites* spillover_rpt.tsv): ")
+	if nonInteractive {
+		writeLog("INFO", fmt.Sprintf("Using default output file: %s (no -outputfile given)", defaultOutputFile))
+		return defaultOutputFile, nil
+	}
+	interactiveMode = true
+	fmt.Printf("Enter the filename to save the results (default *overwrites* %s): ", defaultOutputFile)
 	scanner := bufio.NewScanner(os.Stdin)
 	if scanner.Scan() {
 		outputFile := strings.TrimSpace(scanner.Text())
@@ -760,9 +1828,8 @@ func getOutputFileInteractively() (string, error) {
 		}
 	}
 
-	defaultFile := "spillover_rpt.tsv"
-	writeLog("INFO", fmt.Sprintf("Using default output file: %s", defaultFile))
-	return defaultFile, nil
+	writeLog("INFO", fmt.Sprintf("Using default output file: %s", defaultOutputFile))
+	return defaultOutputFile, nil
 }
 
 /***********************************************************************************************************************************/
@@ -792,835 +1859,2316 @@ func validateDate(dateStr, fieldName string) error {
 }
 
 /***********************************************************************************************************************************/
-// validateProject checks if a Jira project exists and is accessible
+// cliHooks returns the Hooks the command line uses to write its log and progress messages
+//
+// Retries are not included, as the run logs each retry where it happens, with its reason.
 //
-// This function makes an HTTP GET request to the project endpoint to verify
-// that the project exists and the user has permission to view it.
+// Returns:
+//   Hooks - callbacks passing the run's messages and debug dumps to the console and log files, and logging
+//           fetch, epic lookup, and phase progress as INFO messages
+func cliHooks() spillover.Hooks {
+	return spillover.Hooks{
+		OnLog:        writeLogWithContext,
+		OnIssueDebug: writeIssueDebug,
+		OnBatchFetched: func(fetched, total int) {
+			writeLog("INFO", fmt.Sprintf("Fetched %d of %d issues", fetched, total))
+		},
+		OnEpicLookup: func(done, total int) {
+			if done < total {
+				writeLog("INFO", fmt.Sprintf("Looking up Epic summary %d of %d", done+1, total))
+			}
+		},
+		OnPhase: func(name string) {
+			writeLog("INFO", name+"...")
+		},
+	}
+}
+
+/***********************************************************************************************************************************/
+// reloadCLIToken re-reads the token the command line authenticated with, for Config.ReloadToken
 //
 // Parameters:
-//   jiraBaseURL - base URL of the Jira instance
-//   authToken   - Base64 encoded authentication token
-//   projectKey  - project key to validate
+//   ctx      - context whose cancellation stops the credential store tool
+//   instance - the Jira instance whose token was rejected
 //
 // Returns:
-//   error - any error if project doesn't exist or isn't accessible, nil if valid
-//
-// Side effects:
-//   - Makes HTTP request to Jira API
-//   - Writes log messages about validation results
-func validateProject(jiraBaseURL, authToken, projectKey string) error {
-	// Build project validation URL
-	projectURL := fmt.Sprintf("%s/rest/api/2/project/%s", jiraBaseURL, projectKey)
-
-	// Create HTTP request
-	req, err := http.NewRequest("GET", projectURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create project validation request: %w", err)
+//   string - the newly read token, or "" if it did not come from a file or the credential store
+//   error  - any error re-reading the token file or credential
+func reloadCLIToken(ctx context.Context, instance spillover.JiraInstance) (string, error) {
+	switch {
+	case instance.TokenFile != "":
+		return readTokenFile(instance.TokenFile)
+	case activeCredStore != "":
+		return readCredentialStore(ctx, activeCredStore)
+	case activeTokenFile != "":
+		return readTokenFile(activeTokenFile)
 	}
+	return "", nil
+}
 
-	// Set authentication header
-	req.Header.Set("Authorization", "Basic "+authToken)
-	req.Header.Set("Accept", "application/json")
-
-	// Make HTTP request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+/***********************************************************************************************************************************/
+// readIssueKeysFile reads the issue keys to check from a -keysfile
+//
+// The file holds one issue key per line. Blank lines and lines starting with # are ignored, keys
+// are upper-cased, and repeated keys are read once.
+//
+// Parameters:
+//   filename - path of the keys file
+//
+// Returns:
+//   []string - issue keys in file order
+//   error    - any error reading the file, an invalid key (with its line number), or an empty file
+func readIssueKeysFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to validate project: %w", err)
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
 	}
 	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("failed to close response body: %v", err)
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close file: %v", err)
 		}
 	}()
 
-	// Check response status
-	if resp.StatusCode == 404 {
-		return fmt.Errorf("project '%s' does not exist (HTTP 404 Not Found)", projectKey)
-	} else if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to validate project '%s' (HTTP %d)", projectKey, resp.StatusCode)
-	}
-
-	// Parse response to validate project data
-	var projectInfo ProjectInfo
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read project validation response: %w", err)
+	var issueKeys []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		issueKey := strings.ToUpper(line)
+		if !issueKeyRegex.MatchString(issueKey) {
+			return nil, fmt.Errorf("keys file %s line %d: '%s' is not an issue key (expected e.g. EXPD-1234)", filename, lineNumber, line)
+		}
+		if !seen[issueKey] {
+			seen[issueKey] = true
+			issueKeys = append(issueKeys, issueKey)
+		}
 	}
-
-	if err := json.Unmarshal(body, &projectInfo); err != nil {
-		return fmt.Errorf("failed to parse project validation response: %w", err)
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
 	}
-
-	// Validate that the project key matches
-	if projectInfo.Key != projectKey {
-		return fmt.Errorf("project key mismatch: expected '%s', got '%s'", projectKey, projectInfo.Key)
+	if len(issueKeys) == 0 {
+		return nil, fmt.Errorf("keys file %s contains no issue keys", filename)
 	}
-
-	writeLog("INFO", fmt.Sprintf("Project '%s' found: %s", projectKey, projectInfo.Name))
-	return nil
+	return issueKeys, nil
 }
 
 /***********************************************************************************************************************************/
-// buildJQLQuery constructs a JQL (Jira Query Language) query string for retrieving spillover issues
-//
-// This function creates a properly formatted JQL query to filter issues based on:
-// - Project key (required)
-// - Issue types (excludes Epic, Risk, Sub Task)
-// - Sprint field is not empty (only issues that have been in sprints)
-// - Updated date range (based on days prior)
+// confirmRunPlan shows what the run is about to do and asks the user to confirm
 //
 // Parameters:
-//   projectKey - the Jira project key to filter by (e.g., "PROJ", "TEAM")
-//   daysPrior  - number of days to look back for updated issues
+//   jiraBaseURL - base URL of the Jira instance
+//   plan        - the run plan from Run (JQL, authenticated user and estimated issues)
+//   projectKey  - Jira project key, or empty string when checking issue keys from -keysfile
+//   outputFile  - output filename (with .tsv extension)
+//   appendMode  - true if the output file will be appended to
 //
 // Returns:
-//   string - complete JQL query ready for use with Jira REST API
+//   bool - true to proceed, false if the user declined
 //
 // Side effects:
-//   - Writes log message with the constructed JQL query for debugging and audit purposes
-func buildJQLQuery(projectKey string, daysPrior int) string {
-	// Build JQL query to find spillover candidates
-	// Excludes Epics, Risks, and Sub Tasks
-	// Only includes issues with Sprint field populated
-	// Only includes issues updated within the specified time frame
-	jqlQuery := fmt.Sprintf("project = %s AND issuetype not in (Epic, Risk, 'Sub-Task') AND Sprint is not EMPTY AND updated >= -%dd",
-		projectKey, daysPrior)
+//   - Prompts user for input via stdin
+func confirmRunPlan(jiraBaseURL string, plan spillover.RunPlan, projectKey, outputFile string, appendMode bool) bool {
+	host := jiraBaseURL
+	if parsedURL, err := url.Parse(jiraBaseURL); err == nil && parsedURL.Host != "" {
+		host = parsedURL.Host
+	}
+
+	estimate := "unknown"
+	if plan.EstimatedIssues >= 0 {
+		estimate = strconv.Itoa(plan.EstimatedIssues)
+	}
+	queries := strings.Split(plan.JQL, "\n")
+	displayedJQL := queries[0]
+	if len(queries) > 1 {
+		displayedJQL += fmt.Sprintf(" (and %d more key queries)", len(queries)-1)
+	}
+	if projectKey == "" {
+		projectKey = "(issue keys file)"
+	}
+
+	_, statErr := os.Stat(outputFile)
+	outputAction := "will be created"
+	if outputFile == "" {
+		outputFile, outputAction = "none", "-auditfields only"
+	} else if statErr == nil {
+		if appendMode {
+			outputAction = "exists and will be appended to"
+		} else {
+			outputAction = "exists and will be overwritten"
+		}
+	}
+
+	fmt.Println("\nAbout to run:")
+	fmt.Printf("  Jira host:          %s\n", host)
+	fmt.Printf("  Authenticated user: %s\n", plan.User)
+	fmt.Printf("  Project:            %s\n", projectKey)
+	fmt.Printf("  JQL:                %s\n", displayedJQL)
+	fmt.Printf("  Estimated issues:   %s\n", estimate)
+	fmt.Printf("  Output file:        %s (%s)\n", outputFile, outputAction)
+	fmt.Print("Proceed? [Y/n]: ")
 
-	writeLog("INFO", fmt.Sprintf("Using JQL query: %s", jqlQuery))
-	return jqlQuery
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "n", "no":
+			return false
+		}
+	}
+	return true
 }
 
 /***********************************************************************************************************************************/
-// fetchAllJiraIssues retrieves all issues matching the JQL query using pagination
-//
-// This function handles Jira's REST API pagination limits by making multiple requests
-// to fetch all matching issues. Jira typically limits responses to 50-100 issues per
-// request for performance reasons, so this function automatically handles batching.
+// printSelfTestResults prints the -selftest results table, with a remediation hint under each failure
 //
 // Parameters:
-//   jiraBaseURL - base URL of the Jira instance
-//   authToken   - Base64 encoded authentication token
-//   jqlQuery    - JQL query string to execute
-//   fields      - comma-separated list of fields to retrieve
+//   jiraBaseURL - base URL that was checked
+//   checks      - results from runSelfTest
 //
 // Returns:
-//   []Issue - slice containing all matching issues from all paginated requests
-//   error   - any error encountered during fetching
-//
-// Side effects:
-//   - Makes multiple HTTP requests to Jira REST API
-//   - Prints progress messages to console for large result sets
-//   - Writes detailed log messages about fetch progress and completion
-func fetchAllJiraIssues(jiraBaseURL, authToken, jqlQuery, fields string) ([]Issue, error) {
-	var allIssues []Issue
-	startAt := 0
-	batchCount := 0
-
-	for {
-		batchCount++
-		writeLog("INFO", fmt.Sprintf("Fetching batch %d, starting at record %d...", batchCount, startAt))
-
-		// Build URL with pagination parameters
-		encodedJQL := url.QueryEscape(jqlQuery)
-		requestURL := fmt.Sprintf("%s/rest/api/2/search?jql=%s&startAt=%d&maxResults=%d",
-			jiraBaseURL, encodedJQL, startAt, batchSize)
+//   bool - true if no required check failed
+func printSelfTestResults(jiraBaseURL string, checks []spillover.SelfTestCheck) bool {
+	nameWidth := len("Check")
+	for _, check := range checks {
+		nameWidth = max(nameWidth, len(check.Name))
+	}
 
-		if fields != "" {
-			requestURL += "&fields=" + url.QueryEscape(fields)
+	allPassed := true
+	fmt.Printf("\nSelf-test results for %s:\n", jiraBaseURL)
+	fmt.Printf("  %-*s  %-6s %s\n", nameWidth, "Check", "Result", "Detail")
+	for _, check := range checks {
+		result := "\033[32mPASS\033[0m  "
+		switch {
+		case check.Skipped:
+			result = "SKIP  "
+		case !check.Passed && check.Required:
+			result = "\033[31mFAIL\033[0m  "
+			allPassed = false
+		case !check.Passed:
+			result = "\033[33mWARN\033[0m  "
 		}
-
-		// Create HTTP request
-		req, err := http.NewRequest("GET", requestURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request for batch %d: %w", batchCount, err)
+		fmt.Printf("  %-*s  %s %s\n", nameWidth, check.Name, result, check.Detail)
+		if !check.Passed && !check.Skipped && check.Hint != "" {
+			fmt.Printf("  %-*s         -> %s\n", nameWidth, "", check.Hint)
 		}
+	}
 
-		// Set headers
-		req.Header.Set("Authorization", "Basic "+authToken)
-		req.Header.Set("Accept", "application/json")
+	if allPassed {
+		fmt.Println("\n\033[32mAll required checks passed.\033[0m")
+	} else {
+		fmt.Println("\n\033[31mOne or more required checks failed.\033[0m")
+	}
+	return allPassed
+}
 
-		// Make HTTP request
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, err := client.Do(req)
+/***********************************************************************************************************************************/
+// jiraInstances builds the Jira instances to query when -url is given more than once
+//
+// The Nth -url is paired with the Nth -tokenfile and the Nth -instancelabel. Labels default to
+// "cloud" for Atlassian Cloud URLs and "server" otherwise, numbered if that would repeat a label.
+//
+// Parameters: None (uses the -url, -tokenfile, -instancelabel and -oauthconfig values in commandLine)
+//
+// Returns:
+//   []JiraInstance - instances with their tokens read, or nil when -url is given at most once
+//   error          - mismatched -url/-tokenfile counts, -oauthconfig, or a token file error
+//
+// Side effects:
+//   - Reads each token file (prompting once for the passphrase with -tokenpass)
+//   - Prints status message for each instance
+func jiraInstances() ([]spillover.JiraInstance, error) {
+	var urls []string
+	for _, value := range commandLine.URLs {
+		jiraBaseURL, err := normalizeJiraBaseURL(value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch batch %d: %w", batchCount, err)
-		}
-
-		// Read response body
-		body, err := io.ReadAll(resp.Body)
-		if cerr := resp.Body.Close(); cerr != nil {
-			writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
+			return nil, fmt.Errorf("invalid -url value %d: %w", len(urls)+1, err)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response body for batch %d: %w", batchCount, err)
+		urls = append(urls, jiraBaseURL)
+	}
+	tokenFiles := commandLine.TokenFiles
+	labels := commandLine.InstanceLabels
+	if len(urls) < 2 {
+		if len(labels) > 0 {
+			writeLog("WARNING", "-instancelabel has no effect unless -url is given more than once")
 		}
+		return nil, nil
+	}
+	if commandLine.OAuthConfig != "" {
+		return nil, fmt.Errorf("-oauthconfig cannot be used with several -url instances, give each its own -tokenfile")
+	}
+	if len(tokenFiles) != len(urls) {
+		return nil, fmt.Errorf("%d -url values were given with %d -tokenfile values, each Jira instance needs its own token file", len(urls), len(tokenFiles))
+	}
+	if len(labels) > len(urls) {
+		return nil, fmt.Errorf("%d -instancelabel values were given for %d -url instances", len(labels), len(urls))
+	}
 
-		// Check HTTP status
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("HTTP %d error in batch %d: %s", resp.StatusCode, batchCount, string(body))
+	instances := make([]spillover.JiraInstance, 0, len(urls))
+	usedLabels := make(map[string]int)
+	for i, jiraBaseURL := range urls {
+		label := ""
+		if i < len(labels) {
+			label = labels[i]
 		}
-
-		// Parse JSON response
-		var searchResponse SearchResponse
-		if err := json.Unmarshal(body, &searchResponse); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON response for batch %d: %w", batchCount, err)
+		if label == "" {
+			label = defaultInstanceLabel(jiraBaseURL)
+			if usedLabels[label] > 0 {
+				label = fmt.Sprintf("%s%d", label, usedLabels[label]+1)
+			}
 		}
+		usedLabels[label]++
 
-		// Add issues to collection
-		allIssues = append(allIssues, searchResponse.Issues...)
-		writeLog("INFO", fmt.Sprintf("Fetched %d issues (Total: %d/%d)",
-			len(searchResponse.Issues), len(allIssues), searchResponse.Total))
-
-		// Check if we've fetched all issues
-		if startAt+batchSize >= searchResponse.Total {
-			break
+		authToken, err := readTokenFile(tokenFiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file for the %s instance: %w", label, err)
 		}
-
-		// Move to next batch
-		startAt += batchSize
+		writeLog("INFO", fmt.Sprintf("Using Jira instance '%s': %s (token file %s)", label, jiraBaseURL, tokenFiles[i]))
+		instances = append(instances, spillover.JiraInstance{Label: label, JiraBaseURL: jiraBaseURL, AuthToken: authToken, TokenFile: tokenFiles[i]})
 	}
-
-	writeLog("INFO", fmt.Sprintf("Completed fetching %d issues in %d batches", len(allIssues), batchCount))
-	return allIssues, nil
+	return instances, nil
 }
 
 /***********************************************************************************************************************************/
-// parseSprintField extracts sprint information from the Jira sprint field
+// loadProfiles reads every available report profile
 //
-// This function parses the sprint field which can contain multiple sprint objects
-// and extracts sprint names and other information.
+// Profiles come from the profiles file, a JSON object of profile name to flag values:
+//   {"weekly": {"project": "EXPD", "daysprior": 7, "outputfile": "weekly.tsv", "byrelease": true}}
+// and from profiles.d/NAME.json files next to it, each holding one profile's flag values. A profile in
+// profiles.d replaces a profile of the same name in the profiles file.
 //
 // Parameters:
-//   sprintField - the sprint field value from Jira (can be array or null)
+//   profilesFile - path of the profiles file (a missing file is not an error)
 //
 // Returns:
-//   SprintInfo - parsed sprint information including names and counts
-func parseSprintField(sprintField interface{}) SprintInfo {
-	info := SprintInfo{
-		SprintNames: []string{},
+//   map[string]reportProfile - profiles keyed by name
+//   map[string]string        - file each profile was read from, keyed by name
+//   error                    - any error reading or parsing a profile file
+func loadProfiles(profilesFile string) (map[string]reportProfile, map[string]string, error) {
+	profiles := make(map[string]reportProfile)
+	sources := make(map[string]string)
+
+	data, err := os.ReadFile(profilesFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read profiles file: %w", err)
 	}
-
-	if sprintField == nil {
-		return info
+	if err == nil {
+		if err := json.Unmarshal(data, &profiles); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse profiles file %s: %w", profilesFile, err)
+		}
+		for name := range profiles {
+			sources[name] = profilesFile
+		}
 	}
 
-	uniqueNames := make(map[string]bool)
-
-	switch v := sprintField.(type) {
-	case []interface{}:
-		for _, sprint := range v {
-			// Handle map[string]interface{} (Jira API format)
-			if sprintMap, ok := sprint.(map[string]interface{}); ok {
-				if nameVal, exists := sprintMap["name"]; exists {
-					if sprintName, ok := nameVal.(string); ok {
-						if !uniqueNames[sprintName] {
-							uniqueNames[sprintName] = true
-							info.SprintNames = append(info.SprintNames, sprintName)
-						}
-					}
-				}
-			} else if sprintStr, ok := sprint.(string); ok {
-				// Fallback: handle string format (legacy)
-				// Try to extract name=... from string
-				nameRegex := regexp.MustCompile(`name=([^,]+)`)
-				matches := nameRegex.FindStringSubmatch(sprintStr)
-				if len(matches) > 1 {
-					sprintName := matches[1]
-					if !uniqueNames[sprintName] {
-						uniqueNames[sprintName] = true
-						info.SprintNames = append(info.SprintNames, sprintName)
-					}
-				}
-			}
+	profilesDir := filepath.Join(filepath.Dir(profilesFile), profilesDirName)
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
 		}
-	case []string:
-		for _, sprintStr := range v {
-			nameRegex := regexp.MustCompile(`name=([^,]+)`)
-			matches := nameRegex.FindStringSubmatch(sprintStr)
-			if len(matches) > 1 {
-				sprintName := matches[1]
-				if !uniqueNames[sprintName] {
-					uniqueNames[sprintName] = true
-					info.SprintNames = append(info.SprintNames, sprintName)
-				}
-			}
+		profilePath := filepath.Join(profilesDir, entry.Name())
+		data, err := os.ReadFile(profilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read profile file: %w", err)
 		}
-	case string:
-		nameRegex := regexp.MustCompile(`name=([^,]+)`)
-		matches := nameRegex.FindStringSubmatch(v)
-		if len(matches) > 1 {
-			sprintName := matches[1]
-			if !uniqueNames[sprintName] {
-				uniqueNames[sprintName] = true
-				info.SprintNames = append(info.SprintNames, sprintName)
-			}
+		var profile reportProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse profile file %s: %w", profilePath, err)
 		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		profiles[name] = profile
+		sources[name] = profilePath
 	}
-
-	// Set sprint information
-	info.SprintCount = len(info.SprintNames)
-	if len(info.SprintNames) > 0 {
-		info.FirstSprint = info.SprintNames[0]
-		info.LastSprint = info.SprintNames[len(info.SprintNames)-1]
-		info.AllSprints = strings.Join(info.SprintNames, ", ")
-	}
-
-	return info
+	return profiles, sources, nil
 }
 
 /***********************************************************************************************************************************/
-// getEpicLink safely extracts epic link from issue fields
+// profileArgs converts a profile's flag values to command line arguments
+//
+// Flags are returned in name order so the expansion is repeatable. A false boolean adds nothing, and
+// flags in skipFlags are left out.
 //
 // Parameters:
-//   epicLinkField - the epic link field value from Jira
+//   profile   - flag values to convert
+//   skipFlags - lowercase flag names (with "-") to leave out, or nil
 //
 // Returns:
-//   string - epic key or "No Epic" if not found
-func getEpicLink(epicLinkField interface{}) string {
-	if epicLinkField == nil {
-		return "No Epic"
+//   []string - command line arguments (e.g., "-daysprior", "7", "-byrelease")
+//   error    - if a flag value has an unsupported type or names a profile flag
+func profileArgs(profile reportProfile, skipFlags map[string]bool) ([]string, error) {
+	names := make([]string, 0, len(profile))
+	for name := range profile {
+		names = append(names, name)
 	}
-
-	if epicKey, ok := epicLinkField.(string); ok && epicKey != "" {
-		return epicKey
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		flagName := "-" + strings.TrimPrefix(strings.ToLower(name), "-")
+		switch flagName {
+		case "-profile", "-profilesfile", "-listprofiles":
+			return nil, fmt.Errorf("profiles cannot set %s", flagName)
+		}
+		if skipFlags[flagName] {
+			continue
+		}
+		switch value := profile[name].(type) {
+		case bool:
+			if value {
+				args = append(args, flagName)
+			}
+		case string:
+			args = append(args, flagName, value)
+		case float64:
+			args = append(args, flagName, strconv.FormatFloat(value, 'f', -1, 64))
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprintf("%v", item))
+			}
+			args = append(args, flagName, strings.Join(items, ","))
+		default:
+			return nil, fmt.Errorf("unsupported value for %s: %v", flagName, value)
+		}
 	}
-
-	return "No Epic"
+	return args, nil
 }
 
 /***********************************************************************************************************************************/
-// fetchEpicTitles retrieves epic summaries for the given epic keys
+// applyProfile adds a named profile's flag values to the command line
 //
-// This function makes API calls to fetch epic summary information for multiple epics.
+// Flags already given on the command line are not added, so explicit flags always override the profile.
 //
 // Parameters:
-//   jiraBaseURL - base URL of the Jira instance
-//   authToken   - Base64 encoded authentication token
-//   epicKeys    - slice of epic keys to look up
+//   name         - profile name from -profile
+//   profilesFile - path of the profiles file
 //
 // Returns:
-//   map[string]string - mapping of epic key to epic summary
-//   error - any error encountered during fetching
-func fetchEpicTitles(jiraBaseURL, authToken string, epicKeys []string) (map[string]string, error) {
-	epicTitles := make(map[string]string)
-
-	if len(epicKeys) == 0 {
-		return epicTitles, nil
+//   string - file the profile was read from
+//   error  - if the profile does not exist (listing those that do) or cannot be read
+//
+// Side effects:
+//   - Appends the profile's arguments to os.Args
+func applyProfile(name, profilesFile string) (string, error) {
+	profiles, sources, err := loadProfiles(profilesFile)
+	if err != nil {
+		return "", err
 	}
-
-	writeLog("INFO", fmt.Sprintf("Looking up %d unique Epic titles", len(epicKeys)))
-
-	for i, epicKey := range epicKeys {
-		writeLog("INFO", fmt.Sprintf("Looking up Epic summary %d of %d: %s", i+1, len(epicKeys), epicKey))
-
-		// Build epic lookup URL: request only summary
-		epicURL := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary", jiraBaseURL, epicKey)
-
-		// Create HTTP request
-		req, err := http.NewRequest("GET", epicURL, nil)
-		if err != nil {
-			writeLog("WARNING", fmt.Sprintf("Failed to create request for Epic %s: %v", epicKey, err))
-			epicTitles[epicKey] = "Epic Summary Lookup Failed"
-			continue
-		}
-
-		// Set headers
-		req.Header.Set("Authorization", "Basic "+authToken)
-		req.Header.Set("Accept", "application/json")
-
-		// Make HTTP request
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			writeLog("WARNING", fmt.Sprintf("Failed to lookup Epic %s: %v", epicKey, err))
-			epicTitles[epicKey] = "Epic Summary Lookup Failed"
-			continue
-		}
-
-		// Read response body
-		body, err := io.ReadAll(resp.Body)
-		// using a separate variable cerr avoids overwriting the main err from ReadAll.
-		if cerr := resp.Body.Close(); cerr != nil {
-			writeLog("WARNING", fmt.Sprintf("failed to close response body: %v", cerr))
-		}
-		if err != nil {
-			writeLog("WARNING", fmt.Sprintf("Failed to read response for Epic %s: %v", epicKey, err))
-			epicTitles[epicKey] = "Epic Summary Lookup Failed"
-			continue
+	profile, ok := profiles[name]
+	if !ok {
+		available := make([]string, 0, len(profiles))
+		for profileName := range profiles {
+			available = append(available, profileName)
 		}
-
-		// Check HTTP status
-		if resp.StatusCode != 200 {
-			writeLog("WARNING", fmt.Sprintf("HTTP %d error looking up Epic %s", resp.StatusCode, epicKey))
-			epicTitles[epicKey] = "Epic Summary Lookup Failed"
-			continue
-		}
-
-		// Parse JSON response
-		var epicInfo EpicInfo
-		if err := json.Unmarshal(body, &epicInfo); err != nil {
-			writeLog("WARNING", fmt.Sprintf("Failed to parse Epic response for %s: %v", epicKey, err))
-			epicTitles[epicKey] = "Epic Summary Lookup Failed"
-			continue
+		sort.Strings(available)
+		if len(available) == 0 {
+			return "", fmt.Errorf("profile '%s' not found: no profiles are defined in %s or %s", name, profilesFile,
+				filepath.Join(filepath.Dir(profilesFile), profilesDirName))
 		}
-
-		// Extract epic title from summary only
-		var epicTitle string
-		if epicInfo.Fields.Summary != "" {
-			epicTitle = epicInfo.Fields.Summary
-		} else {
-			epicTitle = "No Epic Title"
+		return "", fmt.Errorf("profile '%s' not found, available profiles: %s", name, strings.Join(available, ", "))
+	}
+	explicitFlags := make(map[string]bool)
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			explicitFlags[strings.ToLower(arg)] = true
 		}
-		epicTitles[epicKey] = epicTitle
 	}
-
-	writeLog("INFO", fmt.Sprintf("Retrieved %d Epic summaries", len(epicTitles)))
-	return epicTitles, nil
+	args, err := profileArgs(profile, explicitFlags)
+	if err != nil {
+		return "", fmt.Errorf("profile '%s' in %s: %w", name, sources[name], err)
+	}
+	os.Args = append(os.Args, args...)
+	return sources[name], nil
 }
 
 /***********************************************************************************************************************************/
-// formatDate formats a date pointer to string in yyyy-MM-dd format
+// listProfiles prints the available report profiles and their key settings (-listprofiles)
 //
 // Parameters:
-//   datePtr - pointer to date string from Jira API
+//   profilesFile - path of the profiles file
 //
 // Returns:
-//   string - formatted date or empty string if null/invalid
-func formatDate(datePtr *string) string {
-	if datePtr == nil || *datePtr == "" {
-		return ""
+//   error - any error reading the profiles
+//
+// Side effects:
+//   - Prints the profile list to stdout
+func listProfiles(profilesFile string) error {
+	profiles, sources, err := loadProfiles(profilesFile)
+	if err != nil {
+		return err
 	}
-
-	// Try to parse the Jira date format and convert to yyyy-MM-dd
-	if parsedTime, err := time.Parse(time.RFC3339, *datePtr); err == nil {
-		return parsedTime.Format("2006-01-02")
+	if len(profiles) == 0 {
+		fmt.Printf("No profiles defined in %s or %s\n", profilesFile, filepath.Join(filepath.Dir(profilesFile), profilesDirName))
+		return nil
 	}
 
-	// If parsing fails, try other common formats
-	formats := []string{
-		"2006-01-02T15:04:05.000-0700",
-		"2006-01-02T15:04:05.000Z",
-		"2006-01-02T15:04:05-0700",
-		"2006-01-02T15:04:05Z",
-		"2006-01-02",
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	for _, format := range formats {
-		if parsedTime, err := time.Parse(format, *datePtr); err == nil {
-			return parsedTime.Format("2006-01-02")
+	fmt.Println("Available profiles:")
+	for _, name := range names {
+		args, err := profileArgs(profiles[name], nil)
+		if err != nil {
+			fmt.Printf("  %s (%s): invalid, %v\n", name, sources[name], err)
+			continue
 		}
+		fmt.Printf("  %s (%s)\n", name, sources[name])
+		fmt.Printf("    %s\n", strings.Join(args, " "))
 	}
-
-	writeLog("WARNING", fmt.Sprintf("Error formatting date '%s'", *datePtr))
-	return ""
+	return nil
 }
 
 /***********************************************************************************************************************************/
-// extractFieldValues safely extracts various field values from issue with defaults
+// cleanup performs cleanup operations before program exit
 //
-// Parameters:
-//   issue - the Jira issue to extract values from
+// This function ensures proper cleanup of resources, particularly the log file,
+// and calculates execution time statistics.
 //
-// Returns:
-//   map[string]string - map of field names to values with appropriate defaults
-func extractFieldValues(issue Issue) map[string]string {
-	values := make(map[string]string)
-
-	// Basic fields
-	values["IssueType"] = issue.Fields.IssueType.Name
-	values["Status"] = issue.Fields.Status.Name
-	values["ProjectName"] = issue.Fields.Project.Name
-	values["UpdatedDate"] = formatDate(issue.Fields.Updated)
-	values["CreatedDate"] = formatDate(issue.Fields.Created)
-	values["ResolvedDate"] = formatDate(issue.Fields.ResolutionDate)
-
-	// Assignee
-	if issue.Fields.Assignee != nil {
-		values["Assignee"] = issue.Fields.Assignee.DisplayName
-	} else {
-		values["Assignee"] = "Unassigned"
+// Parameters: None
+// Returns: None
+// Side effects: Closes log file, displays execution time to console
+func cleanup() {
+	// Restore the terminal first so the messages below are visible (-tui)
+	if activeTUI != nil {
+		activeTUI.close()
 	}
 
-	// Creator/Reporter
-	if issue.Fields.Creator != nil {
-		values["Reporter"] = issue.Fields.Creator.DisplayName
-	} else {
-		values["Reporter"] = "Unknown"
+	// Write the problems file before the log file is closed so its own errors are logged
+	if problemsFileName != "" {
+		if err := writeProblemsFile(); err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to write problems file: %v", err))
+		}
 	}
-
-	// Story Points
-	if issue.Fields.StoryPoints != nil {
-		values["StoryPoints"] = fmt.Sprintf("%v", issue.Fields.StoryPoints)
-	} else {
-		values["StoryPoints"] = "N/A"
+	if statsFileName != "" {
+		if err := writeStatsFile(); err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to write stats file: %v", err))
+		}
 	}
+	releaseRunLock()
 
-	// Fix Versions
-	var fixVersions []string
-	for _, version := range issue.Fields.FixVersions {
-		fixVersions = append(fixVersions, version.Name)
+	// Close the debug file once any dump being written has finished
+	logMutex.Lock()
+	if debugFile != nil {
+		if err := debugFile.Close(); err != nil {
+			log.Printf("failed to close debug file: %v", err)
+		}
+		debugFile = nil
 	}
-	values["FixVersions"] = strings.Join(fixVersions, ", ")
+	logMutex.Unlock()
 
-	// Components
-	var components []string
-	for _, component := range issue.Fields.Components {
-		components = append(components, component.Name)
+	// Nothing ran (-?, -printschema, -completion), so keep the printed output clean for redirection
+	if startTime.IsZero() {
+		return
 	}
-	values["Components"] = strings.Join(components, ", ")
-
-	// Labels
-	values["Labels"] = strings.Join(issue.Fields.Labels, ", ")
 
-	// Resolution
-	if issue.Fields.Resolution != nil {
-		values["Resolution"] = issue.Fields.Resolution.Name
-	} else {
-		values["Resolution"] = ""
-	}
+	// Calculate and display execution time
+	duration := time.Since(startTime)
+	fmt.Printf("\nExecution completed in %.2f seconds\n", duration.Seconds())
 
-	// Pair information
-	if pairFieldProvided && pairFieldName != "" {
-		// DEBUG: Show keys in AdditionalFields if debug is enabled
-		if enableDebug {
-			var keys []string
-			for k := range issue.Fields.AdditionalFields {
-				keys = append(keys, k)
-			}
-			writeLog("DEBUG", fmt.Sprintf("Issue %s AdditionalFields keys: %v", issue.Key, keys))
+	// Log execution time if logging is enabled
+	if enableLogging && logFile != nil {
+		writeLog("INFO", fmt.Sprintf("Script execution completed in %.2f seconds", duration.Seconds()))
+		// Close log file once any message being written has finished; later messages go to the console only
+		logMutex.Lock()
+		logger = nil
+		err := logFile.Close()
+		logMutex.Unlock()
+		if err != nil {
+			log.Printf("failed to close log file: %v", err)
 		}
-		// Attempt to read the configured custom field from AdditionalFields
-		if raw, ok := issue.Fields.AdditionalFields[pairFieldName]; ok && raw != nil {
-			if enableDebug {
-				writeLog("DEBUG", fmt.Sprintf("Issue %s raw Pair field (%s): %s", issue.Key, pairFieldName, string(raw)))
-			}
-			// Try several possible shapes: array of objects, single object, array of strings, or single string
-			// 1) array of objects [{"displayName": "Alice"}, ...]
-			var pairNames []string
-			var parsed bool
-
-			// attempt array of objects
-			var pairMembers []PairMember
-			if err := json.Unmarshal(raw, &pairMembers); err == nil && len(pairMembers) > 0 {
-				for _, pair := range pairMembers {
-					pairNames = append(pairNames, pair.DisplayName)
-				}
-				parsed = true
-			}
-
-			if !parsed {
-				// attempt single object {"displayName":"Alice"}
-				var single PairMember
-				if err := json.Unmarshal(raw, &single); err == nil && single.DisplayName != "" {
-					pairNames = append(pairNames, single.DisplayName)
-					parsed = true
-				}
-			}
-
-			if !parsed {
-				// attempt array of strings ["Alice","Bob"]
-				var strArr []string
-				if err := json.Unmarshal(raw, &strArr); err == nil && len(strArr) > 0 {
-					pairNames = append(pairNames, strArr...)
-					parsed = true
-				}
-			}
-
-			if !parsed {
-				// attempt single string "Alice"
-				var s string
-				if err := json.Unmarshal(raw, &s); err == nil && s != "" {
-					pairNames = append(pairNames, s)
-					parsed = true
-				}
-			}
+	}
+}
 
-			if parsed {
-				values["Pair"] = strings.Join(pairNames, ", ")
-			} else {
-				values["Pair"] = ""
-			}
-		} else {
-			// Field not present in this issue
-			values["Pair"] = ""
+/***********************************************************************************************************************************/
+// acquireRunLock creates a lock file next to the output file so two runs cannot write the same files at once
+//
+// See createRunLock for how the lock is taken and when an existing lock file is stale.
+//
+// Parameters:
+//   outputPath - output file path as written (see outputFilePath)
+//
+// Returns:
+//   error - errRunInProgress naming the PID and start time of the run holding the lock, or any error creating the lock file
+//
+// Side effects:
+//   - Creates the lock file (and the output directory if missing) and sets runLockFileName
+func acquireRunLock(outputPath string) error {
+	lockPath := outputPath + ".lock"
+	if dir := filepath.Dir(lockPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory '%s' for lock file: %w", dir, err)
 		}
-	} else {
-		// No custom field configured; use literal header/placeholder
-		values["Pair"] = "Pair"
 	}
-	return values
+	if err := createRunLock(lockPath, os.Getpid(), startTime); err != nil {
+		return err
+	}
+	runLockFileName = lockPath
+	writeLog("DEBUG", fmt.Sprintf("Created lock file %s", lockPath))
+	return nil
 }
 
 /***********************************************************************************************************************************/
-// writeOutputFile writes the spillover issues to a tab-separated file
+// createRunLock creates a lock file holding a PID and start time, unless a run that is still going holds it
+//
+// The content is written to a temporary file first, which is then hard linked to the lock path: the link fails
+// if the lock file exists, and otherwise the lock file appears with its content complete, so another run never
+// reads a lock file that is still empty (where hard links are not supported, the lock file is created exclusively
+// and then written). A lock file naming a process that is no longer running is stale: it is
+// removed, with a WARNING, and the lock is taken. A lock file without a readable PID (written by hand, or by a
+// version that wrote the PID after creating the file) is treated as held until it is staleRunLockAge old.
 //
 // Parameters:
-//   filename        - output filename
-//   multisprintIssues - slice of issues that span multiple sprints
-//   epicTitles      - map of epic keys to titles
-//   appendMode      - if true, append to existing file; if false, create new file
+//   lockPath - lock file path
+//   pid      - PID recorded in the lock file
+//   started  - start time recorded in the lock file
 //
 // Returns:
-//   error - any error encountered during file writing
-func writeOutputFile(filename string, multisprintIssues []MultisprintIssue, epicTitles map[string]string, appendMode bool) (int, error) {
-	// Ensure filename has .tsv extension
-	if !strings.HasSuffix(filename, ".tsv") {
-		filename += ".tsv"
+//   error - errRunInProgress naming the PID and start time of the run holding the lock, or any error creating the lock file
+func createRunLock(lockPath string, pid int, started time.Time) error {
+	lockContent := fmt.Sprintf("%d\n%s\n", pid, started.Format(time.RFC3339))
+	temp, err := os.CreateTemp(filepath.Dir(lockPath), filepath.Base(lockPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create lock file %s: %w", lockPath, err)
+	}
+	defer os.Remove(temp.Name())
+	_, writeErr := temp.WriteString(lockContent)
+	if closeErr := temp.Close(); writeErr != nil || closeErr != nil {
+		return fmt.Errorf("failed to write lock file %s: %v", lockPath, errors.Join(writeErr, closeErr))
 	}
 
-	var file *os.File
-	var err error
-	var writeHeader bool
-
-	if appendMode {
-		// Check if file exists to determine if we need to write header
-		if _, statErr := os.Stat(filename); os.IsNotExist(statErr) {
-			writeHeader = true
-		}
-
-		// Open file in append mode
-		file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return 0, fmt.Errorf("failed to open output file for append: %w", err)
+	for attempt := 1; ; attempt++ {
+		err := os.Link(temp.Name(), lockPath)
+		if err != nil && !os.IsExist(err) {
+			// Filesystems without hard links (e.g. FAT): create the file exclusively, then write it. A run that
+			// reads it before the PID is written sees a lock file without a PID, which counts as held.
+			err = writeExclusiveFile(lockPath, lockContent)
 		}
-		writeLog("INFO", fmt.Sprintf("Appending to existing file: %s", filename))
-	} else {
-		// Create new file (overwrites existing)
-		file, err = os.Create(filename)
-		if err != nil {
-			return 0, fmt.Errorf("failed to create output file: %w", err)
+		if err == nil {
+			return nil
 		}
-		writeHeader = true
-		writeLog("INFO", fmt.Sprintf("Creating new file: %s", filename))
-	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf("failed to close file: %v", err)
+		if !os.IsExist(err) || attempt > 1 {
+			return fmt.Errorf("failed to create lock file %s: %w", lockPath, err)
 		}
-	}()
 
-	// Write header row only if needed (new file or append to empty file)
-	if writeHeader {
-		header := []string{
-			"Issue Type",
-			"Issue Key",
-			"Summary",
-			"Status",
-			"Updated Date",
-			"Created Date",
-			"Resolved Date",
-			"Assignee",
-			"Pair",
-			"Project",
-			"Fix Versions",
-			"Components",
-			"Story Points",
-			"Epic Link",
-			"Epic Summary",
-			"Labels",
-			"Resolution",
-			"Reporter",
-			"Number of Sprints",
-			"First Sprint",
-			"Last Sprint",
-			"All Sprints",
-		}
-
-		// Write header
-		if _, err := file.WriteString(strings.Join(header, "\t") + "\n"); err != nil {
-			return 0, fmt.Errorf("failed to write header: %w", err)
-		}
-	}
-
-	// Write data rows
-	pairFieldFoundCount := 0
-	for _, multisprintIssue := range multisprintIssues {
-		issue := multisprintIssue.Issue
-		values := extractFieldValues(issue)
-		// Debug: log the Pair value for each issue
-		if enableDebug && pairFieldProvided && pairFieldName != "" {
-			writeLog("DEBUG", fmt.Sprintf("writeOutputFile: Issue %s Pair value: '%s'", issue.Key, values["Pair"]))
+		// Another run holds the lock unless the process that created it has gone
+		info, statErr := os.Stat(lockPath)
+		content, readErr := os.ReadFile(lockPath)
+		if os.IsNotExist(statErr) || os.IsNotExist(readErr) {
+			continue // Released meanwhile
 		}
-		// Count non-empty Pair field for found count (trim whitespace)
-		if pairFieldProvided && pairFieldName != "" && strings.TrimSpace(values["Pair"]) != "" {
-			pairFieldFoundCount++
+		pidText, startedText, _ := strings.Cut(strings.TrimSpace(string(content)), "\n")
+		holder, pidErr := strconv.Atoi(strings.TrimSpace(pidText))
+		startedText = strings.TrimSpace(startedText)
+		if readErr != nil || pidErr != nil {
+			if statErr != nil || time.Since(info.ModTime()) < staleRunLockAge {
+				return fmt.Errorf("%w: %s exists but does not name the run holding it yet. "+
+					"If no other run is writing these files, delete the lock file", errRunInProgress, lockPath)
+			}
+		} else if processRunning(holder) {
+			return fmt.Errorf("%w (PID %d, started %s); it holds %s. "+
+				"If that is wrong, delete the lock file, or use -nolock for runs that write different files", errRunInProgress, holder, startedText, lockPath)
 		}
-		// Get epic summary
-		epicTitle := epicTitles[multisprintIssue.EpicLink]
-		if epicTitle == "" {
-			epicTitle = "No Epic Summary"
+
+		// Only remove the lock file read above: another run may have replaced a stale one meanwhile
+		if current, err := os.ReadFile(lockPath); err == nil && string(current) != string(content) {
+			return fmt.Errorf("%w: %s was just taken by another run", errRunInProgress, lockPath)
 		}
-		// Build row data
-		row := []string{
-			values["IssueType"],
-			issue.Key,
-			issue.Fields.Summary,
-			values["Status"],
-			values["UpdatedDate"],
-			values["CreatedDate"],
-			values["ResolvedDate"],
-			values["Assignee"],
-			values["Pair"],
-			values["ProjectName"],
-			values["FixVersions"],
-			values["Components"],
-			values["StoryPoints"],
-			multisprintIssue.EpicLink,
-			epicTitle,
-			values["Labels"],
-			values["Resolution"],
-			values["Reporter"],
-			fmt.Sprintf("%d", multisprintIssue.SprintInfo.SprintCount),
-			multisprintIssue.SprintInfo.FirstSprint,
-			multisprintIssue.SprintInfo.LastSprint,
-			multisprintIssue.SprintInfo.AllSprints,
-		}
-		// Write row
-		if _, err := file.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
-			return 0, fmt.Errorf("failed to write data row: %w", err)
+		if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock file %s: %w", lockPath, err)
 		}
+		writeLog("WARNING", fmt.Sprintf("Removed stale lock file %s left by PID %s (started %s), which is no longer running", lockPath, pidText, startedText))
 	}
-
-	if appendMode {
-		writeLog("INFO", fmt.Sprintf("Successfully appended %d issues to %s", len(multisprintIssues), filename))
-	} else {
-		writeLog("INFO", fmt.Sprintf("Successfully wrote %d issues to %s", len(multisprintIssues), filename))
-	}
-	return pairFieldFoundCount, nil
 }
 
 /***********************************************************************************************************************************/
-// getLoggingFlagFromCommandLine checks for -log parameter in command line arguments
+// writeExclusiveFile creates a file that must not exist yet and writes its content
 //
-// This function scans command line arguments for a -log parameter and returns
-// true if found, enabling log file creation.
-//
-// Parameters: None (reads from os.Args)
+// Parameters:
+//   filename - file to create
+//   content  - content to write
 //
 // Returns:
-//   bool - true if -log flag is present, false otherwise
+//   error - an os.IsExist error if the file exists, or any error creating or writing it (the file is then removed)
+func writeExclusiveFile(filename, content string) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, writeErr := file.WriteString(content)
+	if closeErr := file.Close(); writeErr != nil || closeErr != nil {
+		os.Remove(filename)
+		return errors.Join(writeErr, closeErr)
+	}
+	return nil
+}
+
+/***********************************************************************************************************************************/
+// releaseRunLock removes the lock file created by acquireRunLock, if any
 //
 // Side effects:
-//   - Prints status message if logging flag is found
-func getLoggingFlagFromCommandLine() bool {
-	args := os.Args[1:]
-	for _, arg := range args {
-		if strings.ToLower(arg) == "-log" {
-			fmt.Println("Logging enabled from command line")
-			return true
-		}
+//   - Deletes the lock file and clears runLockFileName
+func releaseRunLock() {
+	if runLockFileName == "" {
+		return
 	}
-	return false
+	if err := os.Remove(runLockFileName); err != nil && !os.IsNotExist(err) {
+		writeLog("WARNING", fmt.Sprintf("Failed to remove lock file %s: %v", runLockFileName, err))
+	}
+	runLockFileName = ""
 }
 
-// getPairFromCommandLine checks for -Pair <fieldname> parameter in command line arguments
-// If provided, it returns the field name and sets pairFieldProvided to true
-func getPairFromCommandLine() string {
-	args := os.Args[1:]
-	for i, arg := range args {
-		if strings.ToLower(arg) == "-pair" && i+1 < len(args) {
-			// record provided flag
-			pairFieldProvided = true
-			// User-supplied field name should be used as-is (case-sensitive in Jira JSON)
-			return args[i+1]
-		}
+/***********************************************************************************************************************************/
+// processRunning reports whether a process with the given PID is running
+//
+// Parameters:
+//   pid - process ID
+//
+// Returns:
+//   bool - true if the process exists (including one owned by another user)
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
 	}
-	pairFieldProvided = false
-	return ""
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// On Windows FindProcess opens the process, so it only succeeds while the process exists
+	if runtime.GOOS == "windows" {
+		process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
 }
 
 /***********************************************************************************************************************************/
-// cleanup performs cleanup operations before program exit
+// exitProgram runs cleanup and exits with the given status code
 //
-// This function ensures proper cleanup of resources, particularly the log file,
-// and calculates execution time statistics.
+// os.Exit does not run deferred functions, so fatal error paths use this to ensure the problems
+// file and log file are still written and closed.
 //
-// Parameters: None
-// Returns: None
-// Side effects: Closes log file, displays execution time to console
-func cleanup() {
-	// Calculate and display execution time
-	duration := time.Since(startTime)
-	fmt.Printf("\nExecution completed in %.2f seconds\n", duration.Seconds())
+// Parameters:
+//   code - process exit status
+func exitProgram(code int) {
+	cleanup()
+	os.Exit(code)
+}
 
-	// Log execution time if logging is enabled
-	if enableLogging && logFile != nil {
-		writeLog("INFO", fmt.Sprintf("Script execution completed in %.2f seconds", duration.Seconds()))
-		// Close log file
-		if err := logFile.Close(); err != nil {
-			log.Printf("failed to close log file: %v", err)
+/***********************************************************************************************************************************/
+// buildStatsParameters records the effective settings of a run for the -statsfile document
+//
+// Parameters:
+//   cfg - settings the run uses
+//
+// Returns:
+//   StatsParameters - the settings in their -statsfile form
+func buildStatsParameters(cfg spillover.Config) spillover.StatsParameters {
+	parameters := spillover.StatsParameters{
+		Project:        cfg.ProjectKey,
+		IssueKeyCount:  len(cfg.IssueKeys),
+		Sample:         cfg.Sample,
+		DaysPrior:      cfg.DaysPrior,
+		ResolvedWithin: cfg.ResolvedWithin,
+		OutputFile:     spillover.ReportFilePath(cfg),
+		Append:         cfg.AppendMode,
+		FixVersions:    cfg.FixVersions,
+		IgnoreLabels:   cfg.IgnoreLabels,
+		GoalContains:   cfg.GoalContains,
+		OrderBy:        cfg.OrderBy,
+		DateField:      cfg.DateField,
+	}
+	weights := spillover.DefaultScoreWeights
+	if cfg.ScoreWeights != (spillover.ScoreWeights{}) {
+		weights = cfg.ScoreWeights
+	}
+	parameters.ScoreFormula = spillover.SpilloverScoreFormula(weights)
+	if len(cfg.IssueKeys) > 0 {
+		parameters.Project = ""
+	}
+	if parameters.DaysPrior == 0 {
+		parameters.DaysPrior = spillover.DefaultDaysPrior
+	}
+	if parameters.OrderBy == "" {
+		parameters.OrderBy = "key"
+	}
+	if len(cfg.Instances) > 0 {
+		for _, instance := range cfg.Instances {
+			parameters.JiraBaseURLs = append(parameters.JiraBaseURLs, instance.JiraBaseURL)
+		}
+	} else {
+		parameters.JiraBaseURLs = []string{cfg.JiraBaseURL}
+	}
+	return parameters
+}
+
+/***********************************************************************************************************************************/
+// showUsage displays program usage information and help text
+//
+// This function provides comprehensive help information including:
+// 1. Program name and version information
+// 2. Author attribution
+// 3. Command line usage syntax
+// 4. Detailed parameter descriptions
+// 5. Practical usage examples
+//
+// Called when user specifies -?, /?, --help, or -help flags
+//
+// Parameters: None
+// Returns: None
+// Side effects: Prints formatted help text to stdout
+func showUsage() {
+	fmt.Printf(`
+%s v%s
+
+Identifies and reports on Jira "spillover" issues - work items that weren't completed within their originally planned sprint. This tool helps teams track delivery efficiency and improve planning.
+
+Usage:
+  %s.exe [-TokenFile token_file_path] [-url jira_base_url] [-project project_key] [-fromdate yyyy-mm-dd] [-daysprior #] [-outputfile filename] [-append] [-log] [-?]
+
+Parameters:
+  -TokenFile    Path to file containing Jira API token (username:api-token format)
+  -tokenpass    Token file is encrypted (see -encrypttoken); prompts for its passphrase, which is never taken as an argument
+  -stricttoken  Fail instead of warning when the token file is readable by group/other users (Unix)
+  -encrypttoken Encrypt a plaintext token file to <file>.enc (AES-GCM, scrypt passphrase key) and exit
+  -credstore    Read the credentials from the OS credential store entry with this name instead of -TokenFile
+                (Windows Credential Manager, macOS Keychain, or libsecret's secret-tool on Linux)
+  -storecred    Prompt for a username and API token, save them to the OS credential store under this name, and exit
+  -oauthconfig  Path to an OAuth 2.0 (3LO) JSON config used instead of -TokenFile (client_id, client_secret, refresh_token, token_endpoint)
+  -url          Jira base URL (e.g., https://jira.company.com); repeat -url and -TokenFile to query and merge several instances
+  -instancelabel  Optional short name for the Instance column, one per -url when -url/-tokenfile are given more than once
+  -preferinstance  With several instances, label whose copy is kept when both return an issue key (default: the Cloud instance)
+  -project      Jira project key (e.g., EXPD)
+  -pair         Optional custom field to use for Pair data, by ID (e.g., customfield_22311) or name (e.g., "Pair")
+  -fromdate     Optional start date in yyyy-mm-dd format. Overrides daysprior if supplied
+  -daysprior    Optional number of days prior to today to check (default: %d)
+  -resolvedwithin  Optional days; skip issues resolved longer ago than this (default: same as daysprior, 0 = never skip)
+  -datefield    Optional JQL date range field: updated (default), statusCategoryChangedDate, or resolved
+  -timezone     Optional IANA timezone for output dates and day counts (e.g., Australia/Sydney, default: Local)
+  -outputfile   Optional name for output file (default: spillover_rpt.tsv)
+  -format       Optional output file format (default: tsv; text or html with -summaryonly)
+  -summaryonly  Write a one-page summary (counts, rate, story points, top tens, trend) instead of the per-issue rows
+  -bom / -nobom  Start new output files with a UTF-8 byte order mark so Excel shows emoji and CJK text (default: on for Windows)
+  -compress     Write the output file gzip-compressed, appending .gz to its name (cannot be combined with -append)
+  -append       Append to existing output file instead of overwriting
+  -dedupe       With -append, skip issues whose key is already in the output file
+  -repair       With -append, remove a partial last row left by an interrupted run instead of refusing to append
+  -preview      Optional number of top spillover issues shown in a console table after the run (default: %d, 0 = none)
+  -tui          Full-screen progress bars, warnings, and a results table that opens issues in the browser (plain console if unsupported)
+  -keysfile     Optional file of issue keys (one per line, # comments) to check instead of a project query
+  -noverify     Skip re-reading the output file to check every row was written (for unusual filesystems)
+  -nolock       Do not create the <outputfile>.lock file that stops two runs writing the same output at once
+  -flushevery   Optional rows between flushes of the output files to disk, for following them with tail -f (default: 50, 0 = at the end)
+  -noatomic     Write the output file in place instead of to a temporary file renamed over it when complete (needed to tail it)
+  -projectcachettl  Optional time a validated project is remembered between runs, e.g. 24h (default), 90m, or 0 to disable
+  -refreshcache Revalidate the project with Jira even if it was validated recently
+  -batchsize    Optional starting number of issues per search request (default: 100); halved after timeouts or HTTP 429
+  -fixedbatch   Keep the search batch size fixed instead of adapting it (for reproducible runs)
+  -searchget    Search with GET and the JQL in the URL instead of POST, for old instances that reject the POST search
+  -skipfailedpages  Retry a failing search page once, then skip it and continue; exits with status 5 if any were skipped
+  -sample       Optional number of matching issues to fetch for a quick trial run; the run is marked SAMPLED (default: all)
+  -nolockfallback  Fail if the output file is locked (e.g. open in Excel) instead of writing a timestamped copy beside it
+  -noninteractive  Never prompt: a missing URL, token file, or project exits with status 4, a missing date range or output file takes its default (automatic when stdin is not a terminal)
+  -confirm      Show the run plan (host, user, JQL, estimated issues, output file) and ask before running
+  -yes          Skip the confirmation prompt (interactive runs confirm by default)
+  -ratelimit    Optional maximum Jira requests per second shared by all lookups (default: unlimited)
+  -resolvesprintids  Resolve sprint entries supplied as bare sprint IDs via the Agile API (default: named "Sprint <id>")
+  -strictnames       Compare sprint names exactly (default: ignore case and extra whitespace when a sprint has no ID)
+  -allsprintsmax     Optional maximum length of the All Sprints column, truncated with "..." (default: 0, no limit)
+  -maxcellwidth      Optional maximum length of every output cell, truncated with "..." (default: 0, no limit)
+  -commitment   Fetch changelogs to add a "Committed At Sprint Start" column (yes, no, unknown) and mid-sprint addition count
+  -estimatechanges  Fetch changelogs to add "SP Changed In Flight" (yes, no, unknown) and "Original SP" columns
+  -assigneechanges  Fetch changelogs to add "Assignee Changes" and "Distinct Assignees" columns since first sprint entry
+  -escalations      Fetch changelogs to add an "Escalated" column (yes, no, unknown): priority raised since first sprint entry
+  -estimatedlate    Fetch changelogs to add "Unestimated Sprints" (sprints started before first estimate) and "Estimated Late" columns
+  -timeinstatus     Fetch changelogs to add a "Time In Status" column of days per status ("In Progress=3.5;Blocked=1.0")
+  -statuscolumns    Optional comma-separated statuses given their own "<status> (days)" column instead (implies -timeinstatus)
+  -identityfields  Optional people column format: display (default), email, accountid, or display+email
+  -includeepics Include Epics placed directly into sprints; an Epic's Epic Link and Epic Summary are its own
+  -fixversion   Optional comma-separated fix version names; only issues targeted at these versions are checked
+  -byrelease    Summarise spillover issue counts and story points per fix version
+  -stalebuckets Optional Staleness column thresholds in days for Fresh/Aging/Stale/Abandoned (default: 7,21,60)
+  -scoreweights Optional Spillover Score weights (default: sprints=3,points=1,age=0.1); the score, to one decimal, is
+                sprints x (Number of Sprints - 1) + points x Story Points (0 if not numeric) + age x days since created
+  -ignorelabel  Optional comma-separated labels (case-insensitive); spillover issues carrying them are excluded
+  -goalcontains Optional text; only report spillover issues whose first sprint goal contains it (case-insensitive)
+  -graceperiod  Optional days; leave out issues in exactly two sprints whose latest sprint started fewer days ago (default: 0)
+  -opencategoryonly  Only report spillover issues whose status is not in the Done category (still actionable)
+  -flaggedonly  Only report spillover issues flagged as an impediment
+  -activeonly   Only report spillover issues in an active sprint now
+  -prioritiesonly  Optional comma-separated priorities (e.g., "Highest,High"); only spillover issues with these are reported
+  -excludeupdatedby  Optional comma-separated users (e.g., "automation-bot"); issues they updated are excluded (JQL or changelogs)
+  -jqlupdatedby With -excludeupdatedby, use the JQL updatedBy() function without first probing the instance for it
+  -minchurn     Only report spillover issues with at least this churn score (sprints per story point), e.g. 1.5
+  -flaggedfield Optional Flagged field ID or name for the Flagged column (default: customfield_10021)
+  -auditfields  Print the distinct values of the -pair, -groupbyfield and Flagged fields; no report unless -outputfile is given
+  -excludedfile With -ignorelabel, optional filename to write the excluded issues to
+  -groupbyfield Optional field ID or name (e.g., customfield_10300 or "Squad") to group output rows by, adds a Group column
+  -includedescription  Add a Description column with a one-line excerpt (increases response size)
+  -descriptionlength   With -includedescription, maximum excerpt length in characters (default: 200)
+  -includecomments     Add a Comments column with each issue's comment count (increases response size)
+  -includetime         Add Original Estimate, Time Spent and Aggregate Time Spent columns in hours, and total time spent to the summary
+  -includelinks Add Blocked By and Blocks columns listing linked issue keys, and count blocked chains in the summary
+  -linktypes    With -includelinks, optional comma-separated link type names to read instead (default: Blocks)
+  -sprintlinks  Add First/Last Sprint Report URL columns linking to each sprint's report (blank when the board is unknown)
+  -cloudlinks   With -sprintlinks, use Jira Cloud sprint report URLs (automatic for instances identified as Cloud)
+  -orderby      Optional row order: key (default), updated, created, priority, or score; applied to the JQL and the output
+  -subtotals    With -groupbyfield, write a subtotal row (issue count, story points) after each group
+  -splitby      Optional: none (default), lastsprint, or month; write one output file per last sprint or resolution month
+  -allissuesfile  Optional filename for every processed issue (key, type, status, assignee, points, sprints, spillover, epic)
+  -sprintpairs  Optional filename for spillover totals per consecutive sprint pair (from sprint, to sprint, issues, story points)
+  -persprint    Optional filename for spillover per sprint (spilled in and spilled out issue counts and story points)
+  -epicrollup   Optional filename for spillover totals per epic (epic, summary, issues, story points, earliest created, issue keys)
+  -input        Optional saved report to rebuild the output files and summary from, without contacting Jira
+  -compare      Optional earlier report; adds "Since Last" (New/Carried) and "Changes Since Last" columns and a summary
+  -registry     Optional JSON file recording when each issue was first and last reported; adds "First Reported" and "Weeks On Report" columns
+  -log          Enable logging to file
+  -loglevel    Lowest level shown on the console: debug, info (default; debug with -debug), warning, or error; the log file gets every level
+  -logformat   Log file format: text (default) or json (one JSON object per line with ts, level, msg, issue, epic, sprint, batch)
+  -consolelogformat  Console log format: text (default, coloured) or json
+  -problemsfile Optional filename to collect every warning and error (timestamp, severity, key, message) as TSV
+  -statsfile   Optional filename for a JSON summary of the run's counts, written even if the run fails (see Stats file)
+  -archivedir  Optional directory; after a successful run, copy the outputs, stats and log file to <dir>/<project>/<yyyy-mm-dd-hhmmss>/
+  -retentiondays  With -archivedir, remove the project's archive directories older than this many days
+  -dumpissue   Fetch one issue with all fields, print its raw JSON and the values derived from it, then exit (uses -outputfile if given)
+  -selftest    Check the URL, token, project, search permission, field IDs, and output path, print a pass/fail table, then exit
+  -posturl     Optional URL to POST the full report as JSON to once the run completes (retried on 5xx)
+  -sqlitefile  Optional SQLite database to add the run to (tables runs, issues, sprints; created if missing)
+  -postauthheader  With -posturl, optional header sent with the POST ("Name: value", or a bare Authorization value)
+  -postrequired    With -posturl, exit with status 3 if the report could not be posted (otherwise a warning)
+  -profile     Optional saved set of flag values to run with; explicit flags override it
+  -profilesfile  Optional profiles file (default: jira-spillover-get-profiles.json, plus NAME.json files in profiles.d beside it)
+  -listprofiles  List the available profiles and their settings, then exit
+  -printschema  Print the JSON Schema of the -posturl report (report) or the -statsfile document (stats), then exit
+  -completion  Print a completion script for bash, zsh, or powershell covering every parameter, then exit
+  -debug		Enable debug output; each work item's sprint data goes to jira-spillover-get-debug-<timestamp>.txt
+  -debugissues  Optional comma-separated issue keys (e.g., "EXPD-12,EXPD-40") whose sprint data is shown on the console
+  -?            Show this help message
+
+Examples:
+  %s.exe -project EXPD -daysprior 14 -outputfile spillover_report.tsv -log
+  %s.exe -TokenFile c:\tokens\jira.tsv -url https://jira.company.com -project TEAM -fromdate 2025-01-01
+  %s.exe -project EXPD -daysprior 7 -outputfile weekly_report.tsv -append
+
+With no command line parameters, you will be prompted interactively for required values
+(unless -noninteractive is given or stdin is not a terminal, e.g. under Task Scheduler).
+
+Authentication:
+  Token file must contain a single line in format: username:api-token
+  Create API tokens from your Jira profile settings.
+
+Output:
+  Tab-separated text file containing issues that have been worked on in multiple sprints.
+  File includes issue details, sprint information, epic data, and assignment information.
+
+Stats file (-statsfile):
+  JSON object with program, version, partial (true if the run failed or was interrupted), durationSeconds,
+  jqlTotal (issues matching the JQL as reported by Jira), processedCount (after -resolvedwithin), spilloverCount,
+  batchesFetched, epicLookups, epicLookupsFailed, warningCount, skippedPages, skippedIssues, and parameters (jiraBaseUrls, project,
+  issueKeyCount, daysPrior, resolvedWithin, outputFile, append, fixVersions, ignoreLabels, goalContains,
+  orderBy, dateField, jql).
+
+`, programName, programVersion, programName, spillover.DefaultDaysPrior, defaultPreviewCount, programName, programName, programName)
+}
+
+/***********************************************************************************************************************************/
+// lookupCommandLineFlag returns the commandLineFlags entry for a flag read from the command line
+//
+// Every flag is read through the registry so that checkCommandLineFlags and the -completion scripts cannot miss one;
+// reading an unregistered flag, or reading a switch as a value (or the reverse), is a programming error.
+//
+// Parameters:
+//   name     - lowercase flag name, including the leading "-"
+//   hasValue - whether the caller reads a value after the flag
+//
+// Returns:
+//   commandLineFlag - the registered flag
+func lookupCommandLineFlag(name string, hasValue bool) commandLineFlag {
+	for _, flag := range commandLineFlags {
+		if flag.Name == name {
+			if (flag.Value != "") != hasValue {
+				panic(fmt.Sprintf("command line flag %s is registered with value %q", name, flag.Value))
+			}
+			return flag
+		}
+	}
+	panic(fmt.Sprintf("command line flag %s is not in commandLineFlags", name))
+}
+
+/***********************************************************************************************************************************/
+// commandLineSwitch reports whether a registered flag that takes no value is on the command line
+//
+// Parameters:
+//   name - lowercase flag name, including the leading "-" (matched case-insensitively)
+//
+// Returns:
+//   bool - true if the flag is present
+func commandLineSwitch(name string) bool {
+	flag := lookupCommandLineFlag(name, false)
+	for _, arg := range os.Args[1:] {
+		if strings.ToLower(arg) == flag.Name {
+			return true
+		}
+	}
+	return false
+}
+
+/***********************************************************************************************************************************/
+// commandLineValues returns the value after each occurrence of a registered flag that takes one
+//
+// Values are trimmed and kept in command line order, blank ones included, so repeated flags such as -url and
+// -tokenfile stay aligned. A flag given as the last argument has no value and is skipped.
+//
+// Parameters:
+//   name - lowercase flag name, including the leading "-" (matched case-insensitively)
+//
+// Returns:
+//   []string - the values, nil if the flag is absent
+func commandLineValues(name string) []string {
+	flag := lookupCommandLineFlag(name, true)
+	args := os.Args[1:]
+	var values []string
+	for i, arg := range args {
+		if strings.ToLower(arg) == flag.Name && i+1 < len(args) {
+			values = append(values, strings.TrimSpace(args[i+1]))
+		}
+	}
+	return values
+}
+
+/***********************************************************************************************************************************/
+// commandLineValue returns the first non-blank value of a registered flag that takes one
+//
+// Parameters:
+//   name - lowercase flag name, including the leading "-" (matched case-insensitively)
+//
+// Returns:
+//   string - the value, or empty string if not found
+//   bool   - true if a non-blank value was found
+func commandLineValue(name string) (string, bool) {
+	return firstNonBlank(commandLineValues(name))
+}
+
+/***********************************************************************************************************************************/
+// commandLineOptionalValue returns the lowercased value of a registered flag whose value may be left out
+//
+// Used by -printschema and -completion, which list their choices when given without one.
+//
+// Parameters:
+//   name - lowercase flag name, including the leading "-" (matched case-insensitively)
+//
+// Returns:
+//   string - the value, "" if the flag is absent, or "?" if it has no value (last argument or followed by a flag)
+func commandLineOptionalValue(name string) string {
+	flag := lookupCommandLineFlag(name, true)
+	args := os.Args[1:]
+	for i, arg := range args {
+		if strings.ToLower(arg) == flag.Name {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				return strings.ToLower(strings.TrimSpace(args[i+1]))
+			}
+			return "?"
+		}
+	}
+	return ""
+}
+
+/***********************************************************************************************************************************/
+// firstNonBlank returns the first non-blank value of a flag given more than once
+//
+// Parameters:
+//   values - the flag's values, in command line order
+//
+// Returns:
+//   string - the value, or empty string if there is none
+//   bool   - true if a non-blank value was found
+func firstNonBlank(values []string) (string, bool) {
+	for _, value := range values {
+		if value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+/***********************************************************************************************************************************/
+// readCommandLineFlags reads the registered flags into their targets (commandLine and the logging settings)
+//
+// main reads the flags that set logging up first (early), then the rest once logging has started, so their messages
+// reach the log file. A flag given without a valid value leaves its target at the default; the first non-blank value
+// of a flag given more than once is the one used, except for repeatable flags, which keep every value.
+//
+// Parameters:
+//   early - true to read the Early flags, false to read the others
+//
+// Side effects:
+//   - Sets the targets of the flags given and records them in commandLine.given
+//   - Logs the flag's INFO message for each flag given, or a WARNING for an invalid value
+func readCommandLineFlags(early bool) {
+	if commandLine.given == nil {
+		commandLine.given = make(map[string]bool)
+	}
+	for _, flag := range commandLineFlags {
+		if flag.Early != early {
+			continue
+		}
+		var shown string
+		switch {
+		case flag.Switch != nil:
+			if !commandLineSwitch(flag.Name) {
+				continue
+			}
+			*flag.Switch = true
+		case flag.Repeatable && flag.List != nil:
+			values := commandLineValues(flag.Name)
+			if len(values) == 0 {
+				continue
+			}
+			*flag.List = values
+		case flag.Text != nil || flag.Int != nil || flag.Float != nil || flag.List != nil || flag.Parse != nil:
+			value, ok := commandLineValue(flag.Name)
+			if !ok {
+				continue
+			}
+			if shown, ok = storeCommandLineValue(flag, value); !ok {
+				if flag.Invalid != "" {
+					writeLog("WARNING", fmt.Sprintf("Invalid %s value '%s', %s", flag.Name, value, flag.Invalid))
+				}
+				continue
+			}
+		default:
+			// Read by main itself
+			continue
+		}
+		commandLine.given[flag.Name] = true
+		if flag.Message != "" {
+			writeLog("INFO", strings.Replace(flag.Message, "%s", shown, 1))
+		}
+	}
+}
+
+/***********************************************************************************************************************************/
+// storeCommandLineValue converts a flag's value for its target and stores it
+//
+// Parameters:
+//   flag  - the registered flag, which has a Text, Int, Float, List, or Parse target
+//   value - the non-blank value given after the flag
+//
+// Returns:
+//   string - the value as stored, for the flag's INFO message
+//   bool   - false if the value is invalid, leaving the target unchanged
+func storeCommandLineValue(flag commandLineFlag, value string) (string, bool) {
+	switch {
+	case flag.Text != nil:
+		if flag.Upper {
+			value = strings.ToUpper(value)
+		}
+		chosen := false
+		for _, choice := range flag.Choices {
+			if strings.EqualFold(value, choice) {
+				value, chosen = choice, true
+				break
+			}
+		}
+		// Without an Invalid message, main checks the value itself (e.g. -format depends on -summaryonly)
+		if len(flag.Choices) > 0 && flag.Invalid != "" && !chosen {
+			return "", false
+		}
+		*flag.Text = value
+		return value, true
+	case flag.Int != nil:
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 || (flag.Positive && number == 0) {
+			return "", false
+		}
+		*flag.Int = number
+		return strconv.Itoa(number), true
+	case flag.Float != nil:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < 0 || math.IsInf(number, 0) || math.IsNaN(number) || (flag.Positive && number == 0) {
+			return "", false
+		}
+		*flag.Float = number
+		return strconv.FormatFloat(number, 'f', -1, 64), true
+	case flag.List != nil:
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				if flag.Upper {
+					item = strings.ToUpper(item)
+				}
+				values = append(values, item)
+			}
+		}
+		if len(values) == 0 {
+			return "", false
+		}
+		*flag.List = values
+		return strings.Join(values, ", "), true
+	default:
+		return value, flag.Parse(value)
+	}
+}
+
+/***********************************************************************************************************************************/
+// parseFlushEvery stores a -flushevery row count, where 0 flushes the output files only when they are complete
+//
+// Parameters:
+//   value - the -flushevery value
+//
+// Returns:
+//   bool - false if the value is not a whole number of zero or more
+func parseFlushEvery(value string) bool {
+	rows, err := strconv.Atoi(value)
+	if err != nil || rows < 0 {
+		return false
+	}
+	commandLine.FlushEvery = rows
+	if rows == 0 {
+		commandLine.FlushEvery = -1 // Config.FlushEvery is negative for "only when complete", 0 being the default
+	}
+	return true
+}
+
+/***********************************************************************************************************************************/
+// parseProjectCacheTTL stores a -projectcachettl duration, where 0 disables the project cache
+//
+// Parameters:
+//   value - the -projectcachettl value (e.g., 24h or 90m)
+//
+// Returns:
+//   bool - false if the value is not 0 or a positive duration
+func parseProjectCacheTTL(value string) bool {
+	if value == "0" {
+		commandLine.ProjectCacheTTL = -1 // Config.ProjectCacheTTL is negative to disable the cache, 0 being the default
+		return true
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return false
+	}
+	commandLine.ProjectCacheTTL = ttl
+	return true
+}
+
+/***********************************************************************************************************************************/
+// parseStaleBuckets stores the -stalebuckets staleness thresholds
+//
+// Parameters:
+//   value - three increasing day counts, e.g. 7,21,60
+//
+// Returns:
+//   bool - false if the value is not three increasing day counts above zero
+func parseStaleBuckets(value string) bool {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return false
+	}
+	var buckets [3]int
+	for j, part := range parts {
+		days, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || days <= 0 || (j > 0 && days <= buckets[j-1]) {
+			return false
+		}
+		buckets[j] = days
+	}
+	commandLine.StaleBuckets = buckets
+	return true
+}
+
+/***********************************************************************************************************************************/
+// parseScoreWeights stores the -scoreweights Spillover Score weights
+//
+// Weights not named keep their defaults.
+//
+// Parameters:
+//   value - name=weight pairs, e.g. sprints=3,points=1,age=0.1
+//
+// Returns:
+//   bool - false for an unknown name, a negative or infinite weight, or weights that are all zero
+func parseScoreWeights(value string) bool {
+	weights := spillover.DefaultScoreWeights
+	for _, part := range strings.Split(value, ",") {
+		name, weightText, found := strings.Cut(part, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightText), 64)
+		if !found || err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "sprints":
+			weights.Sprints = weight
+		case "points":
+			weights.Points = weight
+		case "age":
+			weights.Age = weight
+		default:
+			return false
+		}
+	}
+	if weights == (spillover.ScoreWeights{}) {
+		return false
+	}
+	commandLine.ScoreWeights = weights
+	return true
+}
+
+/***********************************************************************************************************************************/
+// parsePostURL stores the -posturl collector endpoint
+//
+// Parameters:
+//   value - the -posturl value
+//
+// Returns:
+//   bool - false if the value is not an http or https URL with a host
+func parsePostURL(value string) bool {
+	parsedURL, err := url.Parse(value)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return false
+	}
+	commandLine.PostURL = value
+	return true
+}
+
+/***********************************************************************************************************************************/
+// checkCommandLineFlags warns about arguments that look like flags but are not in commandLineFlags
+//
+// Each flag is looked for wherever it appears, so a mistyped flag would otherwise be ignored without a word.
+// The argument after a flag that takes a value is that value, so it is never reported.
+//
+// Parameters: None (reads from os.Args, including any -profile arguments)
+//
+// Side effects:
+//   - Logs a WARNING for each unknown flag
+func checkCommandLineFlags() {
+	flags := make(map[string]commandLineFlag, len(commandLineFlags))
+	for _, flag := range commandLineFlags {
+		flags[flag.Name] = flag
+	}
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		flag, known := flags[strings.ToLower(args[i])]
+		if !known {
+			writeLog("WARNING", fmt.Sprintf("Unknown parameter '%s' ignored (-? lists the parameters)", args[i]))
+			continue
+		}
+		if flag.Value != "" {
+			i++
+		}
+	}
+}
+
+/***********************************************************************************************************************************/
+// completionScript generates the shell completion script for -completion from commandLineFlags
+//
+// Parameters:
+//   shell - bash, zsh, or powershell
+//
+// Returns:
+//   string - the completion script
+//   error  - if the shell is not supported
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletionScript(), nil
+	case "zsh":
+		return zshCompletionScript(), nil
+	case "powershell":
+		return powerShellCompletionScript(), nil
+	}
+	return "", fmt.Errorf("-completion needs a shell: bash, zsh, or powershell")
+}
+
+/***********************************************************************************************************************************/
+// bashCompletionScript generates the bash completion script (-completion bash)
+//
+// File and directory values complete paths, enumerated values complete their choices, and other values complete
+// nothing, so a flag is never offered where a value belongs.
+//
+// Returns:
+//   string - script defining the completion function and registering it for the program
+func bashCompletionScript() string {
+	function := "_" + strings.ReplaceAll(programName, "-", "_")
+	var fileFlags, dirFlags, textFlags, allFlags []string
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s, generated by %s -completion bash\n", programName, programName)
+	fmt.Fprintf(&b, "# Load it with: source <(%s -completion bash)\n", programName)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1],,}\" word\n")
+	b.WriteString("    local -a words\n")
+	b.WriteString("    COMPREPLY=()\n")
+	b.WriteString("    case \"$prev\" in\n")
+	for _, flag := range commandLineFlags {
+		allFlags = append(allFlags, "'"+flag.Name+"'")
+		switch {
+		case len(flag.Choices) > 0:
+			fmt.Fprintf(&b, "    %s)\n        words=('%s')\n        ;;\n", flag.Name, strings.Join(flag.Choices, "' '"))
+		case flag.Value == "file":
+			fileFlags = append(fileFlags, flag.Name)
+		case flag.Value == "dir":
+			dirFlags = append(dirFlags, flag.Name)
+		case flag.Value != "":
+			textFlags = append(textFlags, flag.Name)
+		}
+	}
+	fmt.Fprintf(&b, "    %s)\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n        return\n        ;;\n", strings.Join(fileFlags, "|"))
+	fmt.Fprintf(&b, "    %s)\n        COMPREPLY=($(compgen -d -- \"$cur\"))\n        return\n        ;;\n", strings.Join(dirFlags, "|"))
+	fmt.Fprintf(&b, "    %s)\n        return\n        ;;\n", strings.Join(textFlags, "|"))
+	fmt.Fprintf(&b, "    *)\n        words=(%s)\n        ;;\n", strings.Join(allFlags, " "))
+	b.WriteString("    esac\n")
+	b.WriteString("    for word in \"${words[@]}\"; do\n")
+	b.WriteString("        [[ $word == \"$cur\"* ]] && COMPREPLY+=(\"$word\")\n")
+	b.WriteString("    done\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s %s.exe\n", function, programName, programName)
+	return b.String()
+}
+
+/***********************************************************************************************************************************/
+// zshCompletionScript generates the zsh completion script (-completion zsh)
+//
+// Returns:
+//   string - script defining the completion function with _arguments and registering it with compdef
+func zshCompletionScript() string {
+	function := "_" + strings.ReplaceAll(programName, "-", "_")
+	var b strings.Builder
+	fmt.Fprintf(&b, "# zsh completion for %s, generated by %s -completion zsh\n", programName, programName)
+	fmt.Fprintf(&b, "# Load it after compinit with: source <(%s -completion zsh)\n", programName)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString("    _arguments")
+	for _, flag := range commandLineFlags {
+		spec := flag.Name
+		if flag.Repeatable {
+			spec = "*" + spec
+		}
+		switch {
+		case len(flag.Choices) > 0:
+			spec += ":value:(" + strings.Join(flag.Choices, " ") + ")"
+		case flag.Value == "file":
+			spec += ":file:_files"
+		case flag.Value == "dir":
+			spec += ":directory:_files -/"
+		case flag.Value != "":
+			spec += ":value: "
+		}
+		fmt.Fprintf(&b, " \\\n        '%s'", spec)
+	}
+	b.WriteString("\n}\n")
+	fmt.Fprintf(&b, "compdef %s %s %s.exe\n", function, programName, programName)
+	return b.String()
+}
+
+/***********************************************************************************************************************************/
+// powerShellCompletionScript generates the PowerShell completion script (-completion powershell)
+//
+// File, directory, and free text values return no suggestions, so PowerShell falls back to completing paths.
+//
+// Returns:
+//   string - script registering a native argument completer for the program
+func powerShellCompletionScript() string {
+	quote := func(values []string) string {
+		return "@('" + strings.Join(values, "', '") + "')"
+	}
+	var allFlags, choiceLines []string
+	for _, flag := range commandLineFlags {
+		allFlags = append(allFlags, flag.Name)
+		if len(flag.Choices) > 0 {
+			choiceLines = append(choiceLines, fmt.Sprintf("        '%s' = %s", flag.Name, quote(flag.Choices)))
+		}
+	}
+	valueFlags := make([]string, 0, len(commandLineFlags))
+	for _, flag := range commandLineFlags {
+		if flag.Value != "" && len(flag.Choices) == 0 {
+			valueFlags = append(valueFlags, flag.Name)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# PowerShell completion for %s, generated by %s -completion powershell\n", programName, programName)
+	fmt.Fprintf(&b, "# Load it with: %s -completion powershell | Out-String | Invoke-Expression\n", programName)
+	fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName '%s', '%s.exe' -ScriptBlock {\n", programName, programName)
+	b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprintf(&b, "    $flags = %s\n", quote(allFlags))
+	fmt.Fprintf(&b, "    $valueFlags = %s\n", quote(valueFlags))
+	b.WriteString("    $choices = @{\n" + strings.Join(choiceLines, "\n") + "\n    }\n")
+	b.WriteString("    $before = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -lt $cursorPosition })\n")
+	b.WriteString("    $previous = ''\n")
+	b.WriteString("    if ($before.Count -gt 1) { $previous = $before[-1].ToString().ToLower() }\n")
+	b.WriteString("    if ($valueFlags -contains $previous) { return }\n")
+	b.WriteString("    $candidates = $flags\n")
+	b.WriteString("    $kind = 'ParameterName'\n")
+	b.WriteString("    if ($choices.ContainsKey($previous)) {\n")
+	b.WriteString("        $candidates = $choices[$previous]\n")
+	b.WriteString("        $kind = 'ParameterValue'\n")
+	b.WriteString("    }\n")
+	b.WriteString("    $candidates | Where-Object { $_.StartsWith($wordToComplete, [System.StringComparison]::OrdinalIgnoreCase) } | ForEach-Object {\n")
+	b.WriteString("        [System.Management.Automation.CompletionResult]::new($_, $_, $kind, $_)\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+/***********************************************************************************************************************************/
+// printSpilloverPreview prints a console table of the worst spillover issues (-preview)
+//
+// Issues are ranked by number of sprints, then story points, both descending. Column widths are
+// taken from the data, with the Summary column shrunk (and truncated with "...") so each row fits
+// the terminal width.
+//
+// Parameters:
+//   multisprintIssues - spillover issues from the report
+//   limit             - maximum number of issues to show
+//
+// Side effects:
+//   - Prints the table to stdout
+func printSpilloverPreview(multisprintIssues []spillover.MultisprintIssue, limit int) {
+	if limit <= 0 || len(multisprintIssues) == 0 {
+		return
+	}
+
+	ranked := append([]spillover.MultisprintIssue{}, multisprintIssues...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].SprintInfo.SprintCount != ranked[j].SprintInfo.SprintCount {
+			return ranked[i].SprintInfo.SprintCount > ranked[j].SprintInfo.SprintCount
+		}
+		pointsI, _ := spillover.StoryPointsValue(ranked[i].Issue.Fields.StoryPoints)
+		pointsJ, _ := spillover.StoryPointsValue(ranked[j].Issue.Fields.StoryPoints)
+		if pointsI != pointsJ {
+			return pointsI > pointsJ
+		}
+		return ranked[i].Issue.Key < ranked[j].Issue.Key
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// Build the cells, flattening whitespace so every issue stays on one line
+	header := []string{"Key", "Type", "Sprints", "Points", "Assignee", "Summary"}
+	rows := make([][]string, 0, len(ranked))
+	for _, multisprintIssue := range ranked {
+		issue := multisprintIssue.Issue
+		assignee := "Unassigned"
+		if issue.Fields.Assignee != nil && issue.Fields.Assignee.DisplayName != "" {
+			assignee = issue.Fields.Assignee.DisplayName
+		}
+		rows = append(rows, []string{
+			issue.Key,
+			strings.Join(strings.Fields(issue.Fields.IssueType.Name), " "),
+			strconv.Itoa(multisprintIssue.SprintInfo.SprintCount),
+			spillover.FormatStoryPoints(issue.Fields.StoryPoints),
+			strings.Join(strings.Fields(assignee), " "),
+			strings.Join(strings.Fields(issue.Fields.Summary), " "),
+		})
+	}
+
+	// Size columns to their widest cell; Type and Assignee are capped so Summary keeps some room
+	widths := make([]int, len(header))
+	for col, title := range header {
+		widths[col] = spillover.DisplayWidth(title)
+		for _, row := range rows {
+			if w := spillover.DisplayWidth(row[col]); w > widths[col] {
+				widths[col] = w
+			}
+		}
+	}
+	widths[1] = min(widths[1], 12)
+	widths[4] = min(widths[4], 20)
+	summaryCol := len(header) - 1
+	used := 2 * summaryCol // two spaces between columns
+	for col := 0; col < summaryCol; col++ {
+		used += widths[col]
+	}
+	widths[summaryCol] = max(min(widths[summaryCol], terminalWidth()-1-used), 10)
+
+	// formatRow pads (or truncates) each cell to its column width; numbers are right-aligned
+	formatRow := func(cells []string) []string {
+		formatted := make([]string, len(cells))
+		for col, cell := range cells {
+			cell = truncateToWidth(cell, widths[col])
+			padding := strings.Repeat(" ", widths[col]-spillover.DisplayWidth(cell))
+			if col == 2 || col == 3 {
+				formatted[col] = padding + cell
+			} else if col == summaryCol {
+				formatted[col] = cell
+			} else {
+				formatted[col] = cell + padding
+			}
+		}
+		return formatted
+	}
+
+	fmt.Printf("\nTop %d spillover issues (by sprints, then story points):\n", len(rows))
+	fmt.Printf("\033[36m%s\033[0m\n", strings.Join(formatRow(header), "  "))
+	for i, row := range rows {
+		cells := formatRow(row)
+		// Highlight issues that have spilled over more than once
+		if ranked[i].SprintInfo.SprintCount >= 3 {
+			cells[2] = "\033[31m" + cells[2] + "\033[0m"
+		} else {
+			cells[2] = "\033[33m" + cells[2] + "\033[0m"
+		}
+		fmt.Println(strings.Join(cells, "  "))
+	}
+}
+
+/***********************************************************************************************************************************/
+// terminalWidth returns the width of the console in columns
+//
+// Returns:
+//   int - detected width, or defaultTerminalWidth when stdout is not a terminal or the size is unknown
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+/***********************************************************************************************************************************/
+// truncateToWidth shortens a string to at most width console columns, ending with "..." when truncated
+//
+// Parameters:
+//   value - string to shorten
+//   width - maximum number of columns
+//
+// Returns:
+//   string - the original string, or a truncated copy ending in "..."
+func truncateToWidth(value string, width int) string {
+	if spillover.DisplayWidth(value) <= width {
+		return value
+	}
+	if width <= 3 {
+		return strings.Repeat(".", max(width, 0))
+	}
+	var truncated strings.Builder
+	used := 0
+	for _, r := range value {
+		w := spillover.RuneWidth(r)
+		if used+w > width-3 {
+			break
+		}
+		truncated.WriteRune(r)
+		used += w
+	}
+	return truncated.String() + "..."
+}
+
+/***********************************************************************************************************************************/
+// Phases of a run shown as progress bars by -tui, in the order the run reaches them
+const (
+	tuiPhaseFetch = iota
+	tuiPhaseProcess
+	tuiPhaseEpics
+	tuiPhaseWrite
+)
+
+// tuiPhase is one progress bar of the -tui screen.
+type tuiPhase struct {
+	Name     string // Label shown before the bar
+	Done     int    // Units of work completed
+	Total    int    // Units of work in the phase (0 while unknown)
+	Started  bool   // The run has reached the phase
+	Finished bool   // The run has moved past the phase
+}
+
+// tuiScreen is the full-screen terminal display of -tui. It is only a frontend: it shows what the Hooks, the
+// console log, and the final Report give it, so a run behaves the same with or without it.
+type tuiScreen struct {
+	mutex      sync.Mutex
+	parameters [][2]string                  // Name and value rows of the parameter pane
+	phases     []tuiPhase                   // Fetch, Process, Epics, and Write progress bars
+	warnings   []string                     // WARNING and ERROR console lines, oldest first
+	status     string                       // Latest INFO console line
+	prompt     string                       // Question waiting for a key press (empty when none)
+	issues     []spillover.MultisprintIssue // Results table rows once the run has finished (nil while running)
+	selected   int                          // Results table row under the cursor
+	offset     int                          // First results table row on screen
+	message    string                       // Outcome of the last results table action, e.g. the URL opened
+	fallback   string                       // Jira base URL for issues of an instance with no detected base URL
+	baseURLs   map[string]string            // Jira base URL keyed by instance label, for opening issues
+	closed     bool
+	stopRender chan struct{}
+	renderDone chan struct{}
+}
+
+/***********************************************************************************************************************************/
+// tuiUnsupportedReason checks whether the terminal can show the -tui screen
+//
+// Returns:
+//   string - why the screen cannot be shown (e.g. "standard output is not a terminal"), empty if it can
+func tuiUnsupportedReason() string {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return "standard output is not a terminal"
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "standard input is not a terminal"
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return "TERM is dumb"
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return "the terminal size cannot be read"
+	}
+	if width < 60 || height < 20 {
+		return fmt.Sprintf("the terminal is %dx%d, smaller than 60x20", width, height)
+	}
+	return ""
+}
+
+/***********************************************************************************************************************************/
+// tuiParameters builds the rows of the -tui parameter pane
+//
+// Parameters:
+//   cfg - configuration of the run about to start
+//
+// Returns:
+//   [][2]string - name and value rows: Jira, source, date range, output, and the analyses enabled
+func tuiParameters(cfg spillover.Config) [][2]string {
+	jira := cfg.JiraBaseURL
+	if len(cfg.Instances) > 0 {
+		var urls []string
+		for _, instance := range cfg.Instances {
+			urls = append(urls, instance.Label+"="+instance.JiraBaseURL)
+		}
+		jira = strings.Join(urls, ", ")
+	}
+	daysPrior := cfg.DaysPrior
+	if daysPrior == 0 {
+		daysPrior = spillover.DefaultDaysPrior
+	}
+
+	var rows [][2]string
+	switch {
+	case cfg.InputFile != "":
+		rows = append(rows, [2]string{"Input", cfg.InputFile})
+	case len(cfg.IssueKeys) > 0:
+		rows = append(rows, [2]string{"Jira", jira}, [2]string{"Issues", fmt.Sprintf("%d keys from the keys file", len(cfg.IssueKeys))})
+	default:
+		rows = append(rows, [2]string{"Jira", jira}, [2]string{"Project", cfg.ProjectKey},
+			[2]string{"Date range", fmt.Sprintf("last %d days", daysPrior)})
+	}
+
+	output := spillover.ReportFilePath(cfg)
+	if cfg.OutputFile == "" {
+		output = "none"
+	} else if cfg.AppendMode {
+		output += " (append)"
+	}
+	format := cfg.Format
+	if format == "" {
+		format = "tsv"
+	}
+	if cfg.SummaryOnly {
+		format = "summary " + spillover.SummaryFormatName(cfg.Format)
+	}
+	rows = append(rows, [2]string{"Output", output + ", " + format})
+
+	var analyses []string
+	for _, analysis := range []struct {
+		enabled bool
+		flag    string
+	}{
+		{cfg.Commitment, "-commitment"},
+		{cfg.EstimateChanges, "-estimatechanges"},
+		{cfg.AssigneeChanges, "-assigneechanges"},
+		{cfg.Escalations, "-escalations"},
+		{cfg.EstimatedLate, "-estimatedlate"},
+		{cfg.TimeInStatus, "-timeinstatus"},
+		{cfg.ByRelease, "-byrelease"},
+	} {
+		if analysis.enabled {
+			analyses = append(analyses, analysis.flag)
+		}
+	}
+	if len(analyses) > 0 {
+		rows = append(rows, [2]string{"Analyses", strings.Join(analyses, " ")})
+	}
+	return rows
+}
+
+/***********************************************************************************************************************************/
+// startTUI switches the terminal to the -tui screen and starts redrawing it
+//
+// Parameters:
+//   parameters - rows of the parameter pane, from tuiParameters
+//
+// Returns:
+//   *tuiScreen - the screen, shown until close is called
+//
+// Side effects:
+//   - Switches to the terminal's alternate screen and hides the cursor
+//   - Routes console log lines to the screen instead of standard output (consoleSink)
+//   - Sets activeTUI so cleanup restores the terminal if the program exits early
+func startTUI(parameters [][2]string) *tuiScreen {
+	screen := &tuiScreen{
+		parameters: parameters,
+		phases: []tuiPhase{
+			tuiPhaseFetch:   {Name: "Fetch"},
+			tuiPhaseProcess: {Name: "Process"},
+			tuiPhaseEpics:   {Name: "Epics"},
+			tuiPhaseWrite:   {Name: "Write"},
+		},
+		stopRender: make(chan struct{}),
+		renderDone: make(chan struct{}),
+	}
+
+	fmt.Print("\033[?1049h\033[?25l")
+	logMutex.Lock()
+	consoleSink = screen.log
+	logMutex.Unlock()
+	activeTUI = screen
+
+	go func() {
+		defer close(screen.renderDone)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			screen.render()
+			select {
+			case <-screen.stopRender:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return screen
+}
+
+/***********************************************************************************************************************************/
+// hooks returns the Hooks that drive the -tui progress bars
+//
+// Retries are not included, as each retry is logged where it happens and so reaches the warnings pane.
+//
+// Returns:
+//   Hooks - callbacks moving the Fetch, Process, Epics, and Write bars, with the run's messages logged as usual
+func (s *tuiScreen) hooks() spillover.Hooks {
+	return spillover.Hooks{
+		OnLog:        writeLogWithContext,
+		OnIssueDebug: writeIssueDebug,
+		OnBatchFetched: func(fetched, total int) {
+			s.advance(tuiPhaseFetch, fetched, total)
+		},
+		OnIssueProcessed: func(done, total int) {
+			s.advance(tuiPhaseProcess, done, total)
+		},
+		OnEpicLookup: func(done, total int) {
+			s.advance(tuiPhaseEpics, done, total)
+		},
+		OnPhase: func(name string) {
+			switch name {
+			case "Fetching issues from Jira":
+				s.advance(tuiPhaseFetch, 0, 0)
+			case "Formatting output data":
+				s.advance(tuiPhaseWrite, 0, 0)
+			}
+		},
+	}
+}
+
+/***********************************************************************************************************************************/
+// advance records the progress of a phase, marking every earlier phase finished
+//
+// Phases the run skips (e.g. Epics when no spillover issue has an epic) are marked finished once a later one starts.
+//
+// Parameters:
+//   phase - tuiPhaseFetch, tuiPhaseProcess, tuiPhaseEpics, or tuiPhaseWrite
+//   done  - units of work completed in the phase
+//   total - units of work in the phase (0 while unknown)
+func (s *tuiScreen) advance(phase, done, total int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range phase {
+		s.phases[i].Started = true
+		s.phases[i].Finished = true
+	}
+	s.phases[phase].Started = true
+	s.phases[phase].Done = done
+	s.phases[phase].Total = total
+}
+
+/***********************************************************************************************************************************/
+// log receives a console log line while the screen is shown
+//
+// It is the consoleSink, so it is called with logMutex held and must not log.
+//
+// Parameters:
+//   level - log level of the line
+//   line  - formatted console line
+func (s *tuiScreen) log(level, line string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if level == "WARNING" || level == "ERROR" {
+		s.warnings = append(s.warnings, level+"\x00"+line)
+	} else {
+		s.status = line
+	}
+}
+
+/***********************************************************************************************************************************/
+// tuiProgressBar draws a progress bar with its count
+//
+// Parameters:
+//   phase - the phase to draw
+//   width - columns available for the bar and its count
+//
+// Returns:
+//   string - e.g. "[#########-----------]  45/100", with "done" or "waiting" instead of the count when it has none
+func tuiProgressBar(phase tuiPhase, width int) string {
+	count := "waiting"
+	filled := 0.0
+	switch {
+	case phase.Finished:
+		count, filled = "done", 1
+	case phase.Started && phase.Total > 0:
+		count, filled = fmt.Sprintf("%d/%d", phase.Done, phase.Total), float64(phase.Done)/float64(phase.Total)
+	case phase.Started:
+		count = "running"
+	}
+	barWidth := max(width-len(count)-3, 10)
+	hashes := min(int(filled*float64(barWidth)), barWidth)
+	return "[" + strings.Repeat("#", hashes) + strings.Repeat("-", barWidth-hashes) + "] " + count
+}
+
+/***********************************************************************************************************************************/
+// tuiRule draws a pane heading across the screen
+//
+// Parameters:
+//   title - heading text
+//   width - screen width in columns
+//
+// Returns:
+//   string - e.g. "-- Progress ----------"
+func tuiRule(title string, width int) string {
+	rule := "-- " + title + " "
+	return rule + strings.Repeat("-", max(width-spillover.DisplayWidth(rule), 0))
+}
+
+/***********************************************************************************************************************************/
+// render redraws the whole screen: the progress view while the run is going, the results table once it has finished
+//
+// Side effects:
+//   - Writes the screen to standard output, clearing what was there before
+func (s *tuiScreen) render() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = defaultTerminalWidth, 24
+	}
+
+	var lines []string
+	title := fmt.Sprintf(" %s %s  %s elapsed", programName, programVersion, time.Since(startTime).Round(time.Second))
+	lines = append(lines, "\033[7m"+truncateToWidth(title, width)+strings.Repeat(" ", max(width-spillover.DisplayWidth(title), 0))+"\033[0m")
+	if s.issues == nil {
+		lines = append(lines, s.progressLines(width, height)...)
+	} else {
+		lines = append(lines, s.resultLines(width, height)...)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\033[H")
+	for i, line := range lines {
+		screen.WriteString(line + "\033[K")
+		if i < len(lines)-1 {
+			screen.WriteString("\r\n")
+		}
+	}
+	screen.WriteString("\033[J")
+	fmt.Print(screen.String())
+}
+
+/***********************************************************************************************************************************/
+// tuiWarningLine colours a line of the warnings pane
+//
+// Parameters:
+//   warning - level and line as stored by log
+//   width   - screen width in columns
+//
+// Returns:
+//   string - the line truncated to the width, yellow for warnings and red for errors
+func tuiWarningLine(warning string, width int) string {
+	level, line, _ := strings.Cut(warning, "\x00")
+	colour := "\033[33m"
+	if level == "ERROR" {
+		colour = "\033[31m"
+	}
+	return colour + truncateToWidth(line, width) + "\033[0m"
+}
+
+/***********************************************************************************************************************************/
+// progressLines builds the parameter, progress, and warnings panes shown while the run is going
+//
+// Parameters:
+//   width  - screen width in columns
+//   height - screen height in lines
+//
+// Returns:
+//   []string - screen lines below the title bar
+func (s *tuiScreen) progressLines(width, height int) []string {
+	lines := []string{tuiRule("Parameters", width)}
+	for _, parameter := range s.parameters {
+		lines = append(lines, truncateToWidth(fmt.Sprintf("  %-10s  %s", parameter[0], parameter[1]), width))
+	}
+	lines = append(lines, "", tuiRule("Progress", width))
+	for _, phase := range s.phases {
+		lines = append(lines, fmt.Sprintf("  %-8s  %s", phase.Name, tuiProgressBar(phase, width-13)))
+	}
+	lines = append(lines, "", tuiRule(fmt.Sprintf("Warnings (%d)", len(s.warnings)), width))
+
+	// The warnings pane scrolls: it fills the lines left above the status and prompt lines with the latest warnings
+	room := max(height-len(lines)-3, 1)
+	shown := s.warnings[max(len(s.warnings)-room, 0):]
+	for _, warning := range shown {
+		lines = append(lines, tuiWarningLine(warning, width))
+	}
+	for range room - len(shown) {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "", "\033[2m"+truncateToWidth(s.status, width)+"\033[0m")
+	if s.prompt != "" {
+		lines = append(lines, "\033[1m"+truncateToWidth(s.prompt, width)+"\033[0m")
+	}
+	return lines
+}
+
+/***********************************************************************************************************************************/
+// resultLines builds the results table shown once the run has finished
+//
+// Parameters:
+//   width  - screen width in columns
+//   height - screen height in lines
+//
+// Returns:
+//   []string - screen lines below the title bar, with the selected row in reverse video
+func (s *tuiScreen) resultLines(width, height int) []string {
+	header := []string{"Key", "Sprints", "Points", "Status", "Assignee", "Summary"}
+	rows := make([][]string, 0, len(s.issues))
+	for _, multisprintIssue := range s.issues {
+		issue := multisprintIssue.Issue
+		assignee := "Unassigned"
+		if issue.Fields.Assignee != nil && issue.Fields.Assignee.DisplayName != "" {
+			assignee = issue.Fields.Assignee.DisplayName
+		}
+		rows = append(rows, []string{
+			issue.Key,
+			strconv.Itoa(multisprintIssue.SprintInfo.SprintCount),
+			spillover.FormatStoryPoints(issue.Fields.StoryPoints),
+			strings.Join(strings.Fields(issue.Fields.Status.Name), " "),
+			strings.Join(strings.Fields(assignee), " "),
+			strings.Join(strings.Fields(issue.Fields.Summary), " "),
+		})
+	}
+
+	// Size columns to their widest cell; Status and Assignee are capped so Summary keeps some room
+	widths := make([]int, len(header))
+	for col, title := range header {
+		widths[col] = spillover.DisplayWidth(title)
+		for _, row := range rows {
+			widths[col] = max(widths[col], spillover.DisplayWidth(row[col]))
+		}
+	}
+	widths[3] = min(widths[3], 14)
+	widths[4] = min(widths[4], 18)
+	summaryCol := len(header) - 1
+	used := 2 + 2*summaryCol
+	for col := 0; col < summaryCol; col++ {
+		used += widths[col]
+	}
+	widths[summaryCol] = max(width-used, 10)
+	formatRow := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for col, cell := range cells {
+			cell = truncateToWidth(cell, widths[col])
+			padding := strings.Repeat(" ", widths[col]-spillover.DisplayWidth(cell))
+			if col == 1 || col == 2 {
+				padded[col] = padding + cell
+			} else {
+				padded[col] = cell + padding
+			}
+		}
+		return truncateToWidth("  "+strings.Join(padded, "  "), width)
+	}
+
+	// Keep the selected row on screen, scrolling by as little as possible
+	visible := max(height-6, 1)
+	s.selected = min(max(s.selected, 0), len(rows)-1)
+	if s.selected < s.offset {
+		s.offset = s.selected
+	} else if s.selected >= s.offset+visible {
+		s.offset = s.selected - visible + 1
+	}
+
+	lines := []string{
+		tuiRule(fmt.Sprintf("%d spillover issues (%d-%d)", len(rows), s.offset+1, min(s.offset+visible, len(rows))), width),
+		"\033[1m" + formatRow(header) + "\033[0m",
+	}
+	for i := s.offset; i < min(s.offset+visible, len(rows)); i++ {
+		line := formatRow(rows[i])
+		if i == s.selected {
+			line = "\033[7m" + line + strings.Repeat(" ", max(width-spillover.DisplayWidth(line), 0)) + "\033[0m"
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < visible+2 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "", "\033[2m"+truncateToWidth(s.message, width)+"\033[0m",
+		truncateToWidth("Up/Down or j/k move  PgUp/PgDn page  Home/End  Enter or o open in browser  q quit", width))
+	return lines
+}
+
+/***********************************************************************************************************************************/
+// readTUIKey waits for a key press on standard input, which must be in raw mode
+//
+// Returns:
+//   string - "up", "down", "pgup", "pgdn", "home", "end", "enter", "open", or "quit"; empty for any other key
+//   error  - failure reading standard input
+func readTUIKey() (string, error) {
+	buffer := make([]byte, 16)
+	n, err := os.Stdin.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	switch string(buffer[:n]) {
+	case "\033[A", "\033OA", "k":
+		return "up", nil
+	case "\033[B", "\033OB", "j":
+		return "down", nil
+	case "\033[5~":
+		return "pgup", nil
+	case "\033[6~", " ":
+		return "pgdn", nil
+	case "\033[H", "\033OH", "\033[1~", "g":
+		return "home", nil
+	case "\033[F", "\033OF", "\033[4~", "G":
+		return "end", nil
+	case "\r", "\n":
+		return "enter", nil
+	case "o", "O":
+		return "open", nil
+	case "q", "Q", "\033", "\x03":
+		return "quit", nil
+	}
+	return "", nil
+}
+
+/***********************************************************************************************************************************/
+// confirm shows the run plan in the parameter pane and waits for the user to start or abort the run
+//
+// It replaces confirmRunPlan as Config.Confirm while the screen is shown.
+//
+// Parameters:
+//   plan - the run plan from Run (key queries in its JQL separated by newlines)
+//
+// Returns:
+//   bool - true if the user pressed Enter, false for q, Esc, or Ctrl-C
+func (s *tuiScreen) confirm(plan spillover.RunPlan) bool {
+	queries := strings.Split(plan.JQL, "\n")
+	displayedJQL := queries[0]
+	if len(queries) > 1 {
+		displayedJQL += fmt.Sprintf(" (and %d more key queries)", len(queries)-1)
+	}
+	estimate := "unknown"
+	if plan.EstimatedIssues >= 0 {
+		estimate = strconv.Itoa(plan.EstimatedIssues)
+	}
+	s.mutex.Lock()
+	s.parameters = append(s.parameters, [2]string{"User", plan.User}, [2]string{"JQL", displayedJQL}, [2]string{"Estimated issues", estimate})
+	s.prompt = "Press Enter to start the run, q to abort"
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.prompt = ""
+		s.mutex.Unlock()
+	}()
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return true
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+	for {
+		key, err := readTUIKey()
+		if err != nil || key == "quit" {
+			return false
+		}
+		if key == "enter" {
+			return true
 		}
 	}
 }
 
 /***********************************************************************************************************************************/
-// showUsage displays program usage information and help text
-//
-// This function provides comprehensive help information including:
-// 1. Program name and version information
-// 2. Author attribution
-// 3. Command line usage syntax
-// 4. Detailed parameter descriptions
-// 5. Practical usage examples
+// showResults shows the spillover issues in a navigable table until the user quits
 //
-// Called when user specifies -?, /?, --help, or -help flags
+// Parameters:
+//   report      - result of the run; its JiraServers give the base URL each issue is opened at
+//   jiraBaseURL - base URL for issues whose instance has none (e.g. with -input)
 //
-// Parameters: None
-// Returns: None
-// Side effects: Prints formatted help text to stdout
-func showUsage() {
-	fmt.Printf(`
-%s v%s
-
-Identifies and reports on Jira "spillover" issues - work items that weren't completed within their originally planned sprint. This tool helps teams track delivery efficiency and improve planning.
-
-Usage:
-  %s.exe [-TokenFile token_file_path] [-url jira_base_url] [-project project_key] [-fromdate yyyy-mm-dd] [-daysprior #] [-outputfile filename] [-append] [-log] [-?]
-
-Parameters:
-  -TokenFile    Path to file containing Jira API token (username:api-token format)
-  -url          Jira base URL (e.g., https://jira.company.com)
-  -project      Jira project key (e.g., EXPD)
-  -pair         Optional custom field name to use for Pair data (e.g., customfield_22311)
-  -fromdate     Optional start date in yyyy-mm-dd format. Overrides daysprior if supplied
-  -daysprior    Optional number of days prior to today to check (default: %d)
-  -outputfile   Optional name for output file (default: spillover_rpt.tsv)
-  -append       Append to existing output file instead of overwriting
-  -log          Enable logging to file
-  -debug		Enable display of each work item's sprint data during processing
-  -?            Show this help message
-
-Examples:
-  %s.exe -project EXPD -daysprior 14 -outputfile spillover_report.tsv -log
-  %s.exe -TokenFile c:\tokens\jira.tsv -url https://jira.company.com -project TEAM -fromdate 2025-01-01
-  %s.exe -project EXPD -daysprior 7 -outputfile weekly_report.tsv -append
+// Side effects:
+//   - Opens issues in the default browser on request
+func (s *tuiScreen) showResults(report spillover.Report, jiraBaseURL string) {
+	if len(report.Issues) == 0 {
+		return
+	}
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
 
-With no command line parameters, you will be prompted interactively for required values.
+	s.mutex.Lock()
+	for i := range s.phases {
+		s.phases[i].Started = true
+		s.phases[i].Finished = true
+	}
+	s.issues = report.Issues
+	s.fallback = strings.TrimRight(jiraBaseURL, "/")
+	s.baseURLs = make(map[string]string)
+	for _, server := range report.JiraServers {
+		s.baseURLs[server.Instance] = strings.TrimRight(server.BaseURL, "/")
+	}
+	s.message = fmt.Sprintf("%d warnings; they are listed again when the screen closes", len(s.warnings))
+	s.mutex.Unlock()
 
-Authentication:
-  Token file must contain a single line in format: username:api-token
-  Create API tokens from your Jira profile settings.
+	for {
+		s.render()
+		key, err := readTUIKey()
+		if err != nil || key == "quit" {
+			return
+		}
+		_, height, sizeErr := term.GetSize(int(os.Stdout.Fd()))
+		if sizeErr != nil {
+			height = 24
+		}
+		page := max(height-7, 1)
+
+		s.mutex.Lock()
+		switch key {
+		case "up":
+			s.selected--
+		case "down":
+			s.selected++
+		case "pgup":
+			s.selected -= page
+		case "pgdn":
+			s.selected += page
+		case "home":
+			s.selected = 0
+		case "end":
+			s.selected = len(s.issues) - 1
+		case "enter", "open":
+			s.selected = min(max(s.selected, 0), len(s.issues)-1)
+			issue := s.issues[s.selected].Issue
+			baseURL, ok := s.baseURLs[issue.Instance]
+			if !ok || baseURL == "" {
+				baseURL = s.fallback
+			}
+			if baseURL == "" {
+				s.message = "No Jira URL is known for " + issue.Key
+			} else if err := openInBrowser(baseURL + "/browse/" + issue.Key); err != nil {
+				s.message = fmt.Sprintf("Failed to open %s: %v", issue.Key, err)
+			} else {
+				s.message = "Opened " + baseURL + "/browse/" + issue.Key
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
 
-Output:
-  Tab-separated text file containing issues that have been worked on in multiple sprints.
-  File includes issue details, sprint information, epic data, and assignment information.
+/***********************************************************************************************************************************/
+// openInBrowser opens a URL in the default browser
+//
+// Parameters:
+//   link - URL to open
+//
+// Returns:
+//   error - failure starting the browser
+func openInBrowser(link string) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	case "darwin":
+		command = exec.Command("open", link)
+	default:
+		command = exec.Command("xdg-open", link)
+	}
+	if err := command.Start(); err != nil {
+		return err
+	}
+	go command.Wait()
+	return nil
+}
 
-`, programName, programVersion, programName, defaultDaysPrior, programName, programName, programName)
+/***********************************************************************************************************************************/
+// close restores the terminal and lists the warnings collected while the screen was shown
+//
+// Side effects:
+//   - Stops redrawing, leaves the alternate screen, and shows the cursor
+//   - Sends console log lines to standard output again and clears activeTUI
+//   - Prints the WARNING and ERROR lines logged while the screen was shown
+func (s *tuiScreen) close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.mutex.Unlock()
+	close(s.stopRender)
+	<-s.renderDone
+
+	logMutex.Lock()
+	consoleSink = nil
+	logMutex.Unlock()
+	activeTUI = nil
+	fmt.Print("\033[?25h\033[?1049l")
+
+	for _, warning := range s.warnings {
+		level, line, _ := strings.Cut(warning, "\x00")
+		if level == "ERROR" {
+			fmt.Printf("\033[31m%s\033[0m\n", line)
+		} else {
+			fmt.Printf("\033[33m%s\033[0m\n", line)
+		}
+	}
 }
 
 /***********************************************************************************************************************************/
 // main is the entry point of the application
 //
-// The function handles both interactive prompts and command-line argument processing,
-// providing flexibility for both manual use and automated scripting scenarios.
+// main is the CLI layer around Run: it reads the flags into commandLine (readCommandLineFlags), completes the
+// Config with the interactive prompts, then maps Run's error to an exit status and prints the summary.
 //
 // Parameters: None (uses command line arguments via os.Args)
-// Returns: None (exits with status 0 on success, 1 on error)
+// Returns: None (exits with status 0 on success, 1 on error, 2 if the user aborts at the confirmation prompt)
 func main() {
 	// Register cleanup function to ensure proper resource cleanup
 	defer cleanup()
 
+	// Cancel rate limiter waits and in-flight requests on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Check for help flags first
 	args := os.Args[1:]
 	for _, arg := range args {
@@ -1630,303 +4178,702 @@ func main() {
 		}
 	}
 
-	// Check if logging should be enabled
-	enableLogging = getLoggingFlagFromCommandLine()
+	// Print the JSON Schema of a document the tool writes (-printschema)
+	if schemaName := commandLineOptionalValue("-printschema"); schemaName != "" {
+		schemaJSON, err := spillover.JSONSchema(schemaName)
+		if errors.Is(err, spillover.ErrUnknownSchema) {
+			fmt.Println("Error: -printschema needs a schema name: report or stats")
+			os.Exit(exitCodeInvalidArgs)
+		} else if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(schemaJSON))
+		return
+	}
+
+	// Print a shell completion script (-completion)
+	if shell := commandLineOptionalValue("-completion"); shell != "" {
+		script, err := completionScript(shell)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitCodeInvalidArgs)
+		}
+		fmt.Print(script)
+		return
+	}
+
+	// List saved report profiles (-listprofiles)
+	profilesFile, ok := commandLineValue("-profilesfile")
+	if !ok {
+		profilesFile = defaultProfilesFile
+	}
+	if commandLineSwitch("-listprofiles") {
+		if err := listProfiles(profilesFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exitProgram(1)
+		}
+		return
+	}
+
+	// Expand a saved report profile before any other flag is read (-profile)
+	profileName, _ := commandLineValue("-profile")
+	profileSource := ""
+	if profileName != "" {
+		var err error
+		profileSource, err = applyProfile(profileName, profilesFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exitProgram(1)
+		}
+	}
+
+	// Read the flags that set logging up: -log, -debug, -loglevel, the log formats, and the problems and stats files
+	readCommandLineFlags(true)
 
-	// Check if debug output should be enabled
-	enableDebug = getDebugFlagFromCommandLine()
+	// Get the console log threshold: DEBUG with -debug, otherwise INFO, unless -loglevel says otherwise
+	consoleLogLevel = logLevelRank("INFO")
+	if enableDebug {
+		consoleLogLevel = logLevelRank("DEBUG")
+	}
+	if commandLine.LogLevel != "" {
+		consoleLogLevel = logLevelRank(commandLine.LogLevel)
+		if commandLine.LogLevel == "debug" {
+			enableDebug = true
+		}
+	}
+	runStats.Partial = true // Cleared once the run completes
 
 	// Initialize logging system
 	if err := initLogging(); err != nil {
 		fmt.Printf("Error initializing logging: %v\n", err)
-		os.Exit(1)
+		exitProgram(1)
 	}
 
 	// Display program banner
 	fmt.Printf("\n\033[36m%s v%s\033[0m\n", programName, programVersion)
 	writeLog("INFO", fmt.Sprintf("Starting %s v%s", programName, programVersion))
+	if profileName != "" {
+		writeLog("INFO", fmt.Sprintf("Using profile '%s' from %s (command line flags override it)", profileName, profileSource))
+	}
+	checkCommandLineFlags()
+
+	// Read every other flag; the run's Config starts from commandLine.Config
+	readCommandLineFlags(false)
+	cfg := commandLine.Config
+	cfg.Program = programName
+	cfg.Version = programVersion
+	cfg.Debug = enableDebug
+	cfg.ReloadToken = reloadCLIToken
+	cfg.Hooks = cliHooks()
+
+	// Check if this run's outputs should be copied into a dated archive directory
+	if commandLine.RetentionDays > 0 && commandLine.ArchiveDir == "" {
+		writeLog("WARNING", "-retentiondays has no effect without -archivedir")
+	}
 
-	// Get Jira base URL
-	jiraBaseURL := getJiraBaseURL()
+	// Per-issue debug dumps go to a file of their own (-debug), or to the console for the -debugissues issues
+	debugIssueKeys = make(map[string]bool)
+	for _, issueKey := range cfg.DebugIssues {
+		debugIssueKeys[issueKey] = true
+	}
+	if err := initDebugFile(); err != nil {
+		writeLog("ERROR", fmt.Sprintf("Failed to create debug file: %v", err))
+		exitProgram(1)
+	}
 
-	// Get authentication token
-	authToken, err := getAuthToken()
+	// Resolve the timezone used for output dates and day calculations
+	location, err := time.LoadLocation(commandLine.Timezone)
 	if err != nil {
-		writeLog("ERROR", fmt.Sprintf("Failed to get authentication token: %v", err))
-		os.Exit(1)
+		writeLog("ERROR", fmt.Sprintf("Invalid -timezone '%s' (use an IANA name such as Australia/Sydney, UTC, or Local): %v", commandLine.Timezone, err))
+		exitProgram(1)
 	}
+	cfg.Location = location
+	writeLog("INFO", fmt.Sprintf("Using timezone: %s", spillover.DescribeLocation(location)))
 
-	// Get project key
-	projectKey := getProjectFromCommandLine()
-	if projectKey == "" {
-		projectKey, err = getProjectKeyInteractively()
+	// Fail on missing parameters instead of prompting when nobody can answer (e.g., under Task Scheduler)
+	if !nonInteractive && !term.IsTerminal(int(os.Stdin.Fd())) {
+		nonInteractive = true
+		writeLog("INFO", "Standard input is not a terminal, running non-interactively (missing parameters will not be prompted for)")
+	}
+
+	// Encrypt a plaintext token file and exit (-encrypttoken)
+	if plainTokenFile := commandLine.EncryptToken; plainTokenFile != "" {
+		encryptedPath, err := encryptTokenFile(plainTokenFile)
+		if err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to encrypt token file: %v", err))
+			exitProgram(1)
+		}
+		writeLog("INFO", fmt.Sprintf("Encrypted token file written to %s, use it with -tokenfile %s -tokenpass", encryptedPath, encryptedPath))
+		fmt.Println("Delete the plaintext token file once the encrypted file has been tested.")
+		return
+	}
+
+	// Save a username and API token in the OS credential store and exit (-storecred)
+	if credentialName := commandLine.StoreCred; credentialName != "" {
+		if err := storeCredential(ctx, credentialName); err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to save credential: %v", err))
+			exitProgram(1)
+		}
+		writeLog("INFO", fmt.Sprintf("Credential '%s' saved to the credential store, use it with -credstore %s", credentialName, credentialName))
+		return
+	}
+
+	// Check that the earlier report to compare this run with can be read (optional)
+	if cfg.CompareFile != "" {
+		if _, err := os.Stat(cfg.CompareFile); err != nil {
+			writeLog("ERROR", fmt.Sprintf("Cannot read -compare report: %v", err))
+			exitProgram(1)
+		}
+	}
+
+	// Get the Jira instances to query when -url and -tokenfile are given more than once
+	cfg.Instances, err = jiraInstances()
+	if err != nil {
+		writeLog("ERROR", err.Error())
+		exitProgram(1)
+	}
+
+	// Get Jira base URL (the first instance's when querying several)
+	if len(cfg.Instances) > 0 {
+		cfg.JiraBaseURL = cfg.Instances[0].JiraBaseURL
+	} else if cfg.InputFile == "" {
+		cfg.JiraBaseURL = getJiraBaseURL()
+	}
+
+	// Get authentication: each instance's token file, an OAuth 2.0 (3LO) config if supplied, otherwise an API token file
+	if cfg.InputFile != "" {
+		writeLog("INFO", "Jira will not be contacted, the report is rebuilt from the saved report (-input)")
+	} else if len(cfg.Instances) > 0 {
+		cfg.AuthToken = cfg.Instances[0].AuthToken
+	} else if commandLine.OAuthConfig != "" {
+		cfg.OAuth, err = spillover.LoadOAuthConfig(commandLine.OAuthConfig, writeLog)
+		if err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to load OAuth config: %v", err))
+			exitProgram(1)
+		}
+		if _, err := cfg.OAuth.CurrentAccessToken(ctx); err != nil {
+			if errors.Is(err, spillover.ErrRefreshTokenRejected) {
+				writeLog("ERROR", err.Error())
+			} else {
+				writeLog("ERROR", fmt.Sprintf("Failed to obtain OAuth access token: %v", err))
+			}
+			exitProgram(1)
+		}
+		if cfg.OAuth.Config.CloudID != "" {
+			cfg.JiraBaseURL = "https://api.atlassian.com/ex/jira/" + cfg.OAuth.Config.CloudID
+			writeLog("INFO", fmt.Sprintf("Using OAuth API base URL for cloud_id: %s", cfg.JiraBaseURL))
+		}
+	} else {
+		cfg.AuthToken, err = getAuthToken(ctx)
+		if err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to get authentication token: %v", err))
+			exitProgram(1)
+		}
+	}
+
+	// Dump a single issue and the values derived from it instead of running the report (-dumpissue)
+	if commandLine.DumpIssue != "" {
+		dumpCfg := spillover.Config{
+			JiraBaseURL:      cfg.JiraBaseURL,
+			AuthToken:        cfg.AuthToken,
+			OAuth:            cfg.OAuth,
+			Location:         location,
+			PairField:        cfg.PairField,
+			IdentityMode:     cfg.IdentityMode,
+			ResolveSprintIDs: cfg.ResolveSprintIDs,
+			StrictNames:      cfg.StrictNames,
+			FlaggedField:     cfg.FlaggedField,
+			Debug:            cfg.Debug,
+			DebugIssues:      cfg.DebugIssues,
+			ReloadToken:      cfg.ReloadToken,
+			Hooks:            cfg.Hooks,
+		}
+		if err := spillover.DumpIssue(ctx, dumpCfg, commandLine.DumpIssue, cfg.OutputFile); err != nil {
+			writeLog("ERROR", fmt.Sprintf("Failed to dump issue: %v", err))
+			exitProgram(1)
+		}
+		return
+	}
+
+	// Check connectivity and configuration instead of running the report (-selftest)
+	if commandLine.SelfTest {
+		checks, err := spillover.SelfTest(ctx, spillover.Config{
+			JiraBaseURL:  cfg.JiraBaseURL,
+			AuthToken:    cfg.AuthToken,
+			OAuth:        cfg.OAuth,
+			Location:     location,
+			PairField:    cfg.PairField,
+			GroupByField: cfg.GroupByField,
+			Debug:        cfg.Debug,
+			ReloadToken:  cfg.ReloadToken,
+			Hooks:        cfg.Hooks,
+		}, cfg.ProjectKey, cfg.OutputFile)
+		if err != nil {
+			writeLog("ERROR", err.Error())
+			exitProgram(1)
+		}
+		if !printSelfTestResults(cfg.JiraBaseURL, checks) {
+			exitProgram(1)
+		}
+		return
+	}
+
+	// Get an explicit set of issue keys to check instead of querying a project (-keysfile)
+	if keysFile := commandLine.KeysFile; keysFile != "" {
+		cfg.IssueKeys, err = readIssueKeysFile(keysFile)
+		if err != nil {
+			writeLog("ERROR", err.Error())
+			exitProgram(1)
+		}
+		writeLog("INFO", fmt.Sprintf("Read %d issue keys from %s; project validation and the date range are skipped", len(cfg.IssueKeys), keysFile))
+	}
+
+	// Get project key (not needed when the issue keys are given)
+	if len(cfg.IssueKeys) > 0 {
+		if cfg.ProjectKey != "" {
+			writeLog("WARNING", "-project has no effect with -keysfile")
+			cfg.ProjectKey = ""
+		}
+	} else if cfg.ProjectKey == "" && cfg.InputFile == "" {
+		cfg.ProjectKey, err = getProjectKeyInteractively()
 		if err != nil {
 			writeLog("ERROR", fmt.Sprintf("Failed to get project key: %v", err))
-			os.Exit(1)
+			exitProgram(1)
 		}
 	}
 
 	// Validate project key format (uppercase letters and numbers only)
-	if !regexp.MustCompile(`^[A-Z0-9]+$`).MatchString(projectKey) {
-		writeLog("ERROR", fmt.Sprintf("Project key '%s' must consist only of uppercase letters and numbers", projectKey))
-		os.Exit(1)
+	if len(cfg.IssueKeys) == 0 && cfg.InputFile == "" && !spillover.ProjectKeyRegex.MatchString(cfg.ProjectKey) {
+		writeLog("ERROR", fmt.Sprintf("Project key '%s' must consist only of uppercase letters and numbers", cfg.ProjectKey))
+		exitProgram(1)
 	}
 
 	// Get date range parameters
-	fromDate, daysPrior, fromDateProvided, daysPriorProvided := getDateAndDaysFromCommandLine()
+	fromDate := commandLine.FromDate
+	fromDateProvided, daysPriorProvided := commandLine.given["-fromdate"], commandLine.given["-daysprior"]
+	if len(cfg.IssueKeys) > 0 && (fromDateProvided || daysPriorProvided) {
+		writeLog("WARNING", "-fromdate and -daysprior have no effect with -keysfile")
+		fromDate = ""
+	}
 
 	// If neither parameter was provided via command line, prompt interactively
-	if !fromDateProvided && !daysPriorProvided {
-		fromDate, daysPrior, err = getDateRangeInteractively()
+	if !fromDateProvided && !daysPriorProvided && len(cfg.IssueKeys) == 0 && cfg.InputFile == "" {
+		fromDate, cfg.DaysPrior, err = getDateRangeInteractively()
 		if err != nil {
 			writeLog("ERROR", fmt.Sprintf("Failed to get date range: %v", err))
-			os.Exit(1)
+			exitProgram(1)
 		}
 	}
 
 	// Validate from date if provided
+	var fromDateStart time.Time
 	if fromDate != "" {
 		if err := validateDate(fromDate, "from date"); err != nil {
 			writeLog("ERROR", err.Error())
-			os.Exit(1)
+			exitProgram(1)
 		}
 
 		// Calculate days prior from the provided date
-		if parsedDate, err := time.Parse("2006-01-02", fromDate); err == nil {
-			daysPrior = int(time.Since(parsedDate).Hours() / 24)
-			writeLog("INFO", fmt.Sprintf("Using date range: %s to present (%d days)", fromDate, daysPrior))
+		if parsedDate, err := time.ParseInLocation("2006-01-02", fromDate, location); err == nil {
+			fromDateStart = parsedDate
+			cfg.DaysPrior = spillover.CalendarDaysBetween(parsedDate, time.Now(), location)
+			writeLog("INFO", fmt.Sprintf("Using date range: %s to present (%d days)", fromDate, cfg.DaysPrior))
 		} else {
 			writeLog("ERROR", fmt.Sprintf("Failed to parse from date: %v", err))
-			os.Exit(1)
+			exitProgram(1)
 		}
-	} else {
+	} else if len(cfg.IssueKeys) == 0 && cfg.InputFile == "" {
 		// Use days prior
-		if daysPrior <= 0 {
-			daysPrior = defaultDaysPrior
+		if cfg.DaysPrior <= 0 {
+			cfg.DaysPrior = spillover.DefaultDaysPrior
 		}
-		fromDateTime := time.Now().AddDate(0, 0, -daysPrior)
+		fromDateTime := time.Now().In(location).AddDate(0, 0, -cfg.DaysPrior)
 		writeLog("INFO", fmt.Sprintf("Using date range: %s to present (%d days)",
-			fromDateTime.Format("2006-01-02"), daysPrior))
+			fromDateTime.Format("2006-01-02"), cfg.DaysPrior))
+	}
+
+	// Get resolved date window, defaulting to the same number of days as the date range
+	// (an explicit set of issue keys has no date range, so resolved issues are kept unless asked)
+	if !commandLine.given["-resolvedwithin"] && len(cfg.IssueKeys) > 0 {
+		cfg.ResolvedWithin = 0
+	} else if !commandLine.given["-resolvedwithin"] {
+		// Defaulted to the date range, so the resolved window starts exactly where the range does
+		cfg.ResolvedWithin = cfg.DaysPrior
+		cfg.ResolvedFrom = fromDateStart
+	} else if cfg.ResolvedWithin == 0 {
+		writeLog("INFO", "Resolved issues will not be skipped (-resolvedwithin 0)")
+	}
+
+	// Check the date field the date range applies to, rejecting unknown fields before anything is fetched
+	dateField, dateFieldValid := spillover.NormalizeDateField(cfg.DateField)
+	if !dateFieldValid {
+		writeLog("ERROR", fmt.Sprintf("Invalid -datefield '%s' (use updated, statusCategoryChangedDate, or resolved)", cfg.DateField))
+		exitProgram(1)
+	}
+	cfg.DateField = dateField
+	if dateField != "updated" && len(cfg.IssueKeys) > 0 {
+		writeLog("WARNING", "-datefield has no effect with -keysfile")
+	}
+
+	// Check the output format, rejecting unknown formats before anything is fetched; with -summaryonly it names the
+	// summary document's format
+	if cfg.SummaryOnly {
+		if _, known := spillover.SummaryFormats[spillover.SummaryFormatName(cfg.Format)]; !known {
+			writeLog("ERROR", fmt.Sprintf("Invalid -format '%s' with -summaryonly (use text or html)", cfg.Format))
+			exitProgram(1)
+		}
+	} else if _, known := spillover.ReportFormats[cfg.Format]; !known {
+		writeLog("ERROR", fmt.Sprintf("Invalid -format '%s' (use %s)", cfg.Format, strings.Join(spillover.ReportFormatNames(), ", ")))
+		exitProgram(1)
+	}
+
+	// Byte order mark: on by default on Windows only, -bom and -nobom override the default (-nobom wins)
+	cfg.BOM = (cfg.BOM || runtime.GOOS == "windows") && !commandLine.NoBOM
+
+	// Custom field audit mode; without -outputfile only the audit is run (optional)
+	if cfg.AuditFields && cfg.InputFile != "" {
+		writeLog("WARNING", "-auditfields has no effect with -input (a saved report has no raw field values)")
+		cfg.AuditFields = false
 	}
 
 	// Get output filename
-	outputFile := getOutputFileFromCommandLine()
-	if outputFile == "" {
-		outputFile, err = getOutputFileInteractively()
+	if cfg.OutputFile == "" && !cfg.AuditFields {
+		cfg.OutputFile, err = getOutputFileInteractively()
 		if err != nil {
 			writeLog("ERROR", fmt.Sprintf("Failed to get output filename: %v", err))
-			os.Exit(1)
+			exitProgram(1)
 		}
 	}
 
-	// Get append flag
-	appendMode := getAppendFlagFromCommandLine()
-
-	// Get Pair field from command line (optional)
-	pairFieldName = getPairFromCommandLine()
+	// Duplicate detection and repair only apply to append mode
+	if cfg.Dedupe && !cfg.AppendMode {
+		writeLog("WARNING", "-dedupe has no effect without -append")
+		cfg.Dedupe = false
+	}
+	if cfg.Repair && !cfg.AppendMode {
+		writeLog("WARNING", "-repair has no effect without -append")
+		cfg.Repair = false
+	}
 
-	// Validate project exists
-	if err := validateProject(jiraBaseURL, authToken, projectKey); err != nil {
-		writeLog("ERROR", fmt.Sprintf("Project validation failed: %v", err))
-		fmt.Printf("\nProject '%s' not found in Jira. Please verify the project key is correct.\n", projectKey)
-		os.Exit(1)
+	// Confirmation flags (interactive runs always confirm unless -yes is given)
+	confirmRequested := commandLine.Confirm
+	if confirmRequested && nonInteractive && !commandLine.Yes {
+		writeLog("WARNING", "-confirm has no effect in non-interactive mode")
+		confirmRequested = false
 	}
 
-	// Build JQL query
-	jqlQuery := buildJQLQuery(projectKey, daysPrior)
+	// The excluded issues file lists the issues skipped for their labels
+	if cfg.ExcludedFile != "" && len(cfg.IgnoreLabels) == 0 {
+		writeLog("WARNING", "-excludedfile has no effect without -ignorelabel")
+		cfg.ExcludedFile = ""
+	}
 
-	// Define required fields for API request
-	// Build list of fields to request from Jira. Only include the custom Pair field if the user supplied -Pair.
-	requiredFields := []string{
-		"issuetype", "key", "summary", "status", "updated", "created", "resolutiondate",
-		"assignee",
-		// the pair field (if configured) will be inserted after "assignee"
-		"fixVersions", "components", defaultStoryPointsField,
-		defaultEpicLinkField, "labels", "resolution", defaultSprintField, "creator", "project",
+	// Grouping, link, and sprint link options that need another flag
+	if cfg.Subtotals && cfg.GroupByField == "" {
+		writeLog("WARNING", "-subtotals requires -groupbyfield, subtotals will not be written")
 	}
-	if pairFieldProvided && pairFieldName != "" {
-		// insert the user-specified field name after "assignee"
-		// find index of "assignee"
-		idx := -1
-		for i, v := range requiredFields {
-			if v == "assignee" {
-				idx = i
-				break
-			}
-		}
-		if idx >= 0 {
-			// insert at idx+1
-			before := append([]string{}, requiredFields[:idx+1]...)
-			after := append([]string{}, requiredFields[idx+1:]...)
-			requiredFields = append(append(before, pairFieldName), after...)
-		} else {
-			// fallback: append to the front
-			requiredFields = append([]string{pairFieldName}, requiredFields...)
-		}
+	if cfg.SplitBy == "none" {
+		cfg.SplitBy = ""
+	}
+	if len(cfg.LinkTypes) > 0 && !cfg.IncludeLinks {
+		writeLog("WARNING", "-linktypes has no effect without -includelinks")
+	}
+	if cfg.CloudLinks && !cfg.SprintLinks {
+		writeLog("WARNING", "-cloudlinks has no effect without -sprintlinks")
+		cfg.CloudLinks = false
 	}
-	fieldsParam := strings.Join(requiredFields, ",")
 
-	// Fetch all issues
-	writeLog("INFO", "Fetching issues from Jira...")
-	issues, err := fetchAllJiraIssues(jiraBaseURL, authToken, jqlQuery, fieldsParam)
-	if err != nil {
-		writeLog("ERROR", fmt.Sprintf("Failed to fetch issues: %v", err))
-		os.Exit(1)
+	// A sample only applies to a project query
+	if cfg.Sample > 0 && (cfg.InputFile != "" || len(cfg.IssueKeys) > 0) {
+		writeLog("WARNING", "-sample has no effect with -input or -keysfile")
+		cfg.Sample = 0
 	}
 
-	if len(issues) == 0 {
-		writeLog("WARNING", "No issues found matching the criteria")
-		return
+	// Report POST settings (optional)
+	postURL := commandLine.PostURL
+	if postURL == "" && (commandLine.PostAuthHeader != "" || commandLine.PostRequired) {
+		writeLog("WARNING", "-postauthheader and -postrequired have no effect without -posturl")
+	}
+	if postURL != "" && cfg.OutputFile == "" {
+		writeLog("WARNING", "-posturl has no effect with -auditfields alone, as no report is produced")
+		postURL = ""
 	}
 
-	writeLog("INFO", fmt.Sprintf("Processing %d issues to identify multi-sprint items...", len(issues)))
+	// Get the SQLite database to add the run to (optional)
+	sqliteFile := commandLine.SQLiteFile
+	if sqliteFile != "" && cfg.OutputFile == "" {
+		writeLog("WARNING", "-sqlitefile has no effect with -auditfields alone, as no report is produced")
+		sqliteFile = ""
+	}
 
-	// Process issues to find spillovers
-	var multisprintIssues []MultisprintIssue
-	var epicKeysToLookup []string
-	epicKeySet := make(map[string]bool) // To avoid duplicates
+	if problemsFileName != "" {
+		cfg.ExtraOutputFiles = append(cfg.ExtraOutputFiles, problemsFileName)
+	}
 
-	for i, issue := range issues {
-		if i%100 == 0 {
-			writeLog("INFO", fmt.Sprintf("Processing issue %d of %d: %s", i+1, len(issues), issue.Key))
+	// Show the plan and ask before running in interactive mode or with -confirm
+	if (interactiveMode || confirmRequested) && !commandLine.Yes {
+		cfg.Confirm = func(plan spillover.RunPlan) bool {
+			return confirmRunPlan(cfg.JiraBaseURL, plan, cfg.ProjectKey, spillover.ReportFilePath(cfg), cfg.AppendMode)
 		}
+	}
 
-		// Skip issues resolved too long ago if they have resolution date
-		if issue.Fields.ResolutionDate != nil {
-			if resolvedTime, err := time.Parse(time.RFC3339, *issue.Fields.ResolutionDate); err == nil {
-				daysSinceResolved := int(time.Since(resolvedTime).Hours() / 24)
-				if daysSinceResolved > daysPrior {
-					continue
-				}
+	// Refuse to run while another run is writing the same output file (-nolock skips this)
+	if cfg.OutputFile != "" && !commandLine.NoLock {
+		if err := acquireRunLock(spillover.ReportFilePath(cfg)); err != nil {
+			writeLog("ERROR", err.Error())
+			if errors.Is(err, errRunInProgress) {
+				exitProgram(exitCodeRunInProgress)
 			}
+			exitProgram(1)
 		}
+	}
 
-		// DEBUG: Log the raw SprintField value for this issue if debug flag is set
-		if enableDebug {
-			var debugLines []string
-			debugLines = append(debugLines, fmt.Sprintf("Issue %s raw SprintField:", issue.Key))
-			switch sprints := issue.Fields.SprintField.(type) {
-			case []interface{}:
-				for _, sprint := range sprints {
-					if sprintMap, ok := sprint.(map[string]interface{}); ok {
-						// Get id and name for header
-						idVal := sprintMap["id"]
-						nameVal := sprintMap["name"]
-						idStr := fmt.Sprintf("%v", idVal)
-						nameStr := fmt.Sprintf("%v", nameVal)
-						debugLines = append(debugLines, fmt.Sprintf("Sprint %s: %s", idStr, nameStr))
-						// Board ID
-						if val := sprintMap["boardId"]; val != nil {
-							debugLines = append(debugLines, fmt.Sprintf("  - Board ID: %v", val))
-						}
-						// State
-						if val, ok := sprintMap["state"]; ok {
-							debugLines = append(debugLines, fmt.Sprintf("  - State: %v", val))
-						}
-						// Goal
-						if val, ok := sprintMap["goal"]; ok {
-							goalStr := fmt.Sprintf("%v", val)
-							if goalStr == "" {
-								goalStr = "(empty)"
-							}
-							debugLines = append(debugLines, fmt.Sprintf("  - Goal: %s", goalStr))
-						} else {
-							debugLines = append(debugLines, "  - Goal: (empty)")
-						}
-						// Start Date
-						if val, ok := sprintMap["startDate"]; ok {
-							debugLines = append(debugLines, fmt.Sprintf("  - Start Date: %v", val))
-						}
-						// End Date
-						if val, ok := sprintMap["endDate"]; ok {
-							debugLines = append(debugLines, fmt.Sprintf("  - End Date:   %v", val))
-						}
-						// Complete Date
-						if val, ok := sprintMap["completeDate"]; ok {
-							debugLines = append(debugLines, fmt.Sprintf("  - Complete Date: %v", val))
-						}
-					}
-				}
-			case nil:
-				debugLines = append(debugLines, "  [No sprint data]")
-			default:
-				debugLines = append(debugLines, fmt.Sprintf("  [Unrecognized SprintField type: %T]", sprints))
+	// Show progress and results full-screen if the terminal supports it (-tui), otherwise stay on the plain console
+	var screen *tuiScreen
+	if commandLine.TUI {
+		if reason := tuiUnsupportedReason(); reason != "" {
+			writeLog("INFO", "Using the plain console instead of -tui: "+reason)
+		} else {
+			screen = startTUI(tuiParameters(cfg))
+			cfg.Hooks = screen.hooks()
+			if cfg.Confirm != nil {
+				cfg.Confirm = screen.confirm
 			}
-			writeLog("DEBUG", strings.Join(debugLines, "\n"))
 		}
+	}
 
-		// Parse sprint information
-		sprintInfo := parseSprintField(issue.Fields.SprintField)
+	runStats.Parameters = buildStatsParameters(cfg)
+	report, err := spillover.Run(ctx, cfg)
+	parameters := runStats.Parameters
+	runStats = report.Stats
+	runStats.Parameters = parameters
+	runStats.Partial = true
+	runStats.Parameters.JQL = report.JQL
+	if screen != nil {
+		if err == nil {
+			screen.showResults(report, cfg.JiraBaseURL)
+		}
+		screen.close()
+	}
+	if errors.Is(err, spillover.ErrRunAborted) {
+		writeLog("INFO", "Run aborted by user at the confirmation prompt")
+		exitProgram(exitCodeUserAborted)
+	} else if err != nil {
+		writeLog("ERROR", err.Error())
+		if errors.Is(err, spillover.ErrProjectValidation) {
+			fmt.Printf("\nProject '%s' not found in Jira. Please verify the project key is correct.\n", cfg.ProjectKey)
+		}
+		exitProgram(1)
+	}
 
-		// Only include issues that have been in more than one sprint
-		if sprintInfo.SprintCount > 1 {
-			epicLink := getEpicLink(issue.Fields.EpicLinkField)
+	runStats.ProcessedCount = report.FetchedCount - report.ResolvedExcludedCount
+	runStats.SpilloverCount = len(report.Issues)
+	runStats.Partial = false
 
-			// Add to multi-sprint issues
-			multisprintIssue := MultisprintIssue{
-				Issue:         issue,
-				WorkedSprints: sprintInfo.SprintCount,
-				EpicLink:      epicLink,
-				SprintInfo:    sprintInfo,
-			}
+	// Print the custom field audit (-auditfields); without -outputfile it is the whole result
+	for _, audit := range report.FieldAudits {
+		fmt.Println()
+		fmt.Println(strings.Join(spillover.FormatFieldAudit(audit), "\n"))
+	}
 
-			// Set resolved date if available
-			if issue.Fields.ResolutionDate != nil {
-				if resolvedTime, err := time.Parse(time.RFC3339, *issue.Fields.ResolutionDate); err == nil {
-					multisprintIssue.ResolvedDate = &resolvedTime
+	if report.FetchedCount > 0 && cfg.OutputFile != "" {
+		if report.PairFieldMissing {
+			fmt.Printf("Warning: Pair field '%s' was requested but not found on any issues. Check the field name.\n", cfg.PairField)
+		}
+		printSpilloverPreview(report.Issues, commandLine.Preview)
+		fmt.Printf("\n\033[32mSuccess!\033[0m Processed %d issues and found %d spillover issues.\n",
+			report.FetchedCount, len(report.Issues))
+		fmt.Println(report.StalenessSummary)
+		fmt.Println(report.StatusCategorySummary)
+		fmt.Println(report.PrioritySummary)
+		fmt.Printf("Overdue: %d spillover issues past their due date\n", report.OverdueCount)
+		fmt.Printf("Flagged: %d spillover issues flagged as an impediment\n", report.FlaggedCount)
+		fmt.Printf("Active sprint: %d spillover issues in an active sprint, %d not in one\n",
+			report.ActiveSprintCount, len(report.Issues)-report.ActiveSprintCount)
+		if report.TimeSpentSummary != "" {
+			fmt.Println(report.TimeSpentSummary)
+		}
+		if report.BlockedChainSummary != "" {
+			fmt.Println(report.BlockedChainSummary)
+		}
+		if report.TruncatedCells > 0 {
+			fmt.Printf("Truncated %d cells across %d issues (-maxcellwidth)\n", report.TruncatedCells, report.TruncatedIssues)
+		}
+		if report.ResolvedEpicCount > 0 {
+			fmt.Printf("\033[33mResolved epics: %d spillover issues belong to an epic that is already resolved\033[0m\n", report.ResolvedEpicCount)
+		}
+		if report.InconsistentRows > 0 {
+			fmt.Printf("\033[33mIntegrity check: %d rows had inconsistent derived fields (see the warnings)\033[0m\n", report.InconsistentRows)
+		}
+		if len(report.MissingKeys) > 0 {
+			fmt.Printf("\033[33mNot found: %d issue keys from the keys file (%s)\033[0m\n",
+				len(report.MissingKeys), strings.Join(report.MissingKeys, ", "))
+		}
+		if report.IgnoreSummary != "" {
+			fmt.Println(report.IgnoreSummary)
+		}
+		if report.GoalFilterSummary != "" {
+			fmt.Println(report.GoalFilterSummary)
+		}
+		if report.GraceSummary != "" {
+			fmt.Println(report.GraceSummary)
+		}
+		if report.OpenCategorySummary != "" {
+			fmt.Println(report.OpenCategorySummary)
+		}
+		if report.FlaggedOnlySummary != "" {
+			fmt.Println(report.FlaggedOnlySummary)
+		}
+		if report.ActiveOnlySummary != "" {
+			fmt.Println(report.ActiveOnlySummary)
+		}
+		if report.PrioritiesOnlySummary != "" {
+			fmt.Println(report.PrioritiesOnlySummary)
+		}
+		if report.UpdatedBySummary != "" {
+			fmt.Println(report.UpdatedBySummary)
+		}
+		if report.MinChurnSummary != "" {
+			fmt.Println(report.MinChurnSummary)
+		}
+		if report.CommitmentSummary != "" {
+			fmt.Println(report.CommitmentSummary)
+		}
+		if report.EstimateSummary != "" {
+			fmt.Println(report.EstimateSummary)
+		}
+		if report.AssigneeSummary != "" {
+			fmt.Println(report.AssigneeSummary)
+		}
+		if report.EscalationSummary != "" {
+			fmt.Println(report.EscalationSummary)
+		}
+		if report.EstimatedLateSummary != "" {
+			fmt.Println(report.EstimatedLateSummary)
+		}
+		if report.TimeInStatusSummary != "" {
+			fmt.Println(report.TimeInStatusSummary)
+		}
+		if report.CompareSummary != "" {
+			fmt.Println(report.CompareSummary)
+		}
+		if report.RegistrySummary != "" {
+			fmt.Println(report.RegistrySummary)
+		}
+		if len(report.EpicRollup) > 0 {
+			fmt.Println("Top epics by spillover:")
+			for _, stat := range report.EpicRollup[:min(5, len(report.EpicRollup))] {
+				fmt.Printf("  %s: %d issues, %s story points\n",
+					stat.EpicKey, stat.IssueCount, strconv.FormatFloat(stat.StoryPoints, 'f', -1, 64))
+			}
+		}
+		if len(report.TopChurn) > 0 {
+			fmt.Println("Top issues by churn score (sprints per story point):")
+			for _, multisprintIssue := range report.TopChurn {
+				fmt.Printf("  %s: %s (%d sprints, %s story points)\n", multisprintIssue.Issue.Key, spillover.FormatChurnScore(multisprintIssue),
+					multisprintIssue.SprintInfo.SprintCount, spillover.FormatStoryPoints(multisprintIssue.Issue.Fields.StoryPoints))
+			}
+		}
+		if len(report.TopScores) > 0 {
+			fmt.Printf("Top issues by spillover score (%s):\n", report.ScoreFormula())
+			for _, multisprintIssue := range report.TopScores {
+				fmt.Printf("  %s: %s (%d sprints, %s story points)\n", multisprintIssue.Issue.Key, report.FormatSpilloverScore(multisprintIssue),
+					multisprintIssue.SprintInfo.SprintCount, spillover.FormatStoryPoints(multisprintIssue.Issue.Fields.StoryPoints))
+			}
+			if report.UnscoredPointsCount > 0 {
+				fmt.Printf("  (%d issues without numeric story points are scored on sprints and age only)\n", report.UnscoredPointsCount)
+			}
+		}
+		if len(report.ReleaseStats) > 0 {
+			fmt.Println("Spillover by release:")
+			for _, stat := range report.ReleaseStats {
+				fmt.Println("  " + spillover.FormatReleaseStat(stat))
+			}
+		}
+		if cfg.SplitBy != "" {
+			if len(report.OutputFiles) == 0 {
+				fmt.Println("No spillover issues, so no files were written (-splitby)")
+			} else {
+				action := "saved"
+				if cfg.AppendMode {
+					action = "appended"
+				}
+				fmt.Printf("Results %s to %d files, split by %s:\n", action, len(report.OutputFiles), cfg.SplitBy)
+				for _, written := range report.OutputFiles {
+					fmt.Printf("  %s: %d issues\n", written.Path, written.Rows)
 				}
 			}
+		} else if cfg.SummaryOnly {
+			fmt.Printf("Summary saved to: %s\n", report.OutputFile)
+		} else if report.OutputFile != spillover.ReportFilePath(cfg) {
+			fmt.Printf("\033[33mResults saved to: %s (%s was locked by another program)\033[0m\n", report.OutputFile, spillover.ReportFilePath(cfg))
+		} else if cfg.AppendMode {
+			fmt.Printf("Results appended to: %s\n", cfg.OutputFile)
+		} else {
+			fmt.Printf("Results saved to: %s\n", cfg.OutputFile)
+		}
+	}
 
-			multisprintIssues = append(multisprintIssues, multisprintIssue)
+	if report.SkippedPages > 0 {
+		fmt.Printf("\033[31m%d pages (approx. %d issues) could not be fetched; the report is incomplete\033[0m\n",
+			report.SkippedPages, report.SkippedIssues)
+	}
+	if report.Sampled {
+		fmt.Printf("\033[33mSAMPLED: only the first %d matching issues were fetched (-sample); this is not a full report and counts are not scaled\033[0m\n",
+			report.FetchedCount)
+	}
 
-			// Collect epic keys for lookup
-			if epicLink != "No Epic" && !epicKeySet[epicLink] {
-				epicKeySet[epicLink] = true
-				epicKeysToLookup = append(epicKeysToLookup, epicLink)
+	// POST the full report to the collector endpoint once the run is complete (-posturl)
+	if postURL != "" {
+		payload, err := json.Marshal(report.Posted(cfg))
+		if err == nil {
+			// A report that breaks its published schema is a bug, so it is never sent
+			if err := spillover.ValidateJSONDocument("report", payload); err != nil {
+				writeLog("ERROR", err.Error())
+				exitProgram(1)
 			}
+			err = report.Post(postURL, commandLine.PostAuthHeader, payload)
+		}
+		if err != nil && commandLine.PostRequired {
+			writeLog("ERROR", fmt.Sprintf("Failed to POST report to %s: %v", postURL, err))
+			exitProgram(exitCodePostFailed)
+		} else if err != nil {
+			writeLog("WARNING", fmt.Sprintf("Failed to POST report to %s: %v", postURL, err))
 		}
 	}
 
-	writeLog("INFO", fmt.Sprintf("Found %d issues that have been worked on in multiple sprints", len(multisprintIssues)))
-
-	// Debug: Show how many issues had a non-empty Pair field
-	// (moved to after writeOutputFile call, using local variable)
+	// Add the run to the SQLite database for querying history with SQL (-sqlitefile)
+	archiveFiles := report.WrittenFiles
+	if sqliteFile != "" {
+		if err := report.ExportSQLite(cfg, sqliteFile); err != nil {
+			writeLog("ERROR", err.Error())
+			exitProgram(1)
+		}
+		fmt.Printf("Run added to SQLite database: %s\n", sqliteFile)
+	}
 
-	// Fetch epic summaries
-	var epicTitles map[string]string
-	if len(epicKeysToLookup) > 0 {
-		epicTitles, err = fetchEpicTitles(jiraBaseURL, authToken, epicKeysToLookup)
+	// Write the stats file now rather than at exit, so a document that breaks its schema fails the run
+	if statsFileName != "" {
+		archiveFiles = append(archiveFiles, statsFileName)
+		err := writeStatsFile()
+		statsFileName = ""
 		if err != nil {
-			writeLog("WARNING", fmt.Sprintf("Failed to fetch some epic summaries: %v", err))
-			// Continue with empty epic summary map
-			epicTitles = make(map[string]string)
+			writeLog("ERROR", fmt.Sprintf("Failed to write stats file: %v", err))
+			exitProgram(1)
 		}
-	} else {
-		epicTitles = make(map[string]string)
 	}
 
-	// Write output file
-	writeLog("INFO", "Formatting output data...")
-	pairFieldFoundCount, err := writeOutputFile(outputFile, multisprintIssues, epicTitles, appendMode)
-	if err != nil {
-		writeLog("ERROR", fmt.Sprintf("Failed to write output file: %v", err))
-		os.Exit(1)
-	}
-	// Debug: Show how many issues had a non-empty Pair field
-	if enableDebug && pairFieldProvided && pairFieldName != "" {
-		writeLog("DEBUG", fmt.Sprintf("pairFieldFoundCount after processing: %d", pairFieldFoundCount))
-	}
-	// If user supplied -Pair but the field wasn't found on any issue, warn the user
-	if pairFieldProvided && pairFieldName != "" && pairFieldFoundCount == 0 {
-		writeLog("WARNING", fmt.Sprintf("Pair field '%s' was requested but not found on any issues. Check the field name.", pairFieldName))
-		fmt.Printf("Warning: Pair field '%s' was requested but not found on any issues. Check the field name.\n", pairFieldName)
+	// Keep a dated copy of every output for audit, now they have all been written (-archivedir)
+	if commandLine.ArchiveDir != "" {
+		if logFile != nil {
+			archiveFiles = append(archiveFiles, logFile.Name())
+		}
+		archiveRunOutputs(commandLine.ArchiveDir, cfg.ProjectKey, archiveFiles, commandLine.RetentionDays)
 	}
 
-	fmt.Printf("\n\033[32mSuccess!\033[0m Processed %d issues and found %d spillover issues.\n",
-		len(issues), len(multisprintIssues))
-	if appendMode {
-		fmt.Printf("Results appended to: %s\n", outputFile)
-	} else {
-		fmt.Printf("Results saved to: %s\n", outputFile)
+	// Let automation decide whether to accept a report with gaps
+	if report.SkippedPages > 0 {
+		exitProgram(exitCodePartialResults)
 	}
 }